@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/sar"
+)
+
+// policyActions returns the actions and not-actions of a policy's statements
+func policyActions(p *policy.IAMPolicy) []string {
+	var actions []string
+	for _, stmt := range p.Statement {
+		actions = append(actions, stmt.Action...)
+		actions = append(actions, stmt.NotAction...)
+	}
+	return actions
+}
+
+// unknownActions returns the actions of a policy's statements that are not
+// in the Service Authorization Reference
+func unknownActions(p *policy.IAMPolicy) []sar.Problem {
+	return sar.Validate(policyActions(p))
+}
+
+// unverifiedNote describes the services whose actions could not be
+// validated, or returns "" if every action was
+func unverifiedNote(actions []string) string {
+	services := sar.Unverified(actions)
+	if len(services) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("actions of %s are not validated (not in the embedded Service Authorization Reference): %s",
+		plural(len(services), "service"), strings.Join(services, ", "))
+}
+
+// warnUnknownActions warns about generated actions that do not exist, which
+// come from custom mappings or cached schemas
+func warnUnknownActions(p *policy.IAMPolicy) {
+	if note := unverifiedNote(policyActions(p)); note != "" {
+		fmt.Fprintf(os.Stderr, "Note: %s\n", note)
+	}
+
+	problems := unknownActions(p)
+	if len(problems) == 0 {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Warning: %s not in the Service Authorization Reference:\n", plural(len(problems), "action"))
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "  - %s\n", problem)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/github"
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/provider"
+	"github.com/mizzy/least/internal/risk"
+	"github.com/mizzy/least/internal/sar"
+)
+
+// grantLocations maps actions to the IaC policy granting them, recorded by
+// loadPolicyDir to place annotations about the existing policy
+var grantLocations map[string]provider.SourceLocation
+
+// recordGrantLocations records the first IaC policy that allows each action
+func recordGrantLocations(policies []provider.IAMPolicy) {
+	grantLocations = make(map[string]provider.SourceLocation)
+	for _, p := range policies {
+		for _, stmt := range p.Statements {
+			if !strings.EqualFold(stmt.Effect, "Allow") || stmt.IsTrust() {
+				continue
+			}
+			for _, action := range stmt.Actions {
+				if _, ok := grantLocations[action]; !ok {
+					grantLocations[action] = p.Location
+				}
+			}
+		}
+	}
+}
+
+// policyLocator returns a function locating an action in the existing
+// policy: the IaC policy granting it with --policy-dir, or the first line
+// mentioning it in the --policy file. Policies fetched from AWS have no
+// location.
+func policyLocator() func(action string) provider.SourceLocation {
+	if grantLocations != nil {
+		return func(action string) provider.SourceLocation {
+			return grantLocations[action]
+		}
+	}
+	if policyFile == "" || policyFile == stdinPath {
+		return func(string) provider.SourceLocation { return provider.SourceLocation{} }
+	}
+
+	data, err := os.ReadFile(policyFile)
+	if err != nil {
+		return func(string) provider.SourceLocation { return provider.SourceLocation{File: policyFile} }
+	}
+	lines := strings.Split(string(data), "\n")
+	return func(action string) provider.SourceLocation {
+		for i, line := range lines {
+			if strings.Contains(line, `"`+action+`"`) {
+				return provider.SourceLocation{File: policyFile, Line: i + 1}
+			}
+		}
+		return provider.SourceLocation{File: policyFile}
+	}
+}
+
+// printAnnotations prints check findings as GitHub Actions workflow
+// commands: missing permissions on the resources requiring them, and
+// excessive, broad and nonexistent grants on the existing policy
+func printAnnotations(required *policy.IAMPolicy, result *checker.Result, broadGrants []risk.BroadGrant, invalid []sar.Problem) {
+	var annotations []github.Annotation
+
+	// Missing permissions, one annotation per requiring resource
+	missing := make(map[string][]string)
+	locations := make(map[string]provider.SourceLocation)
+	for _, action := range result.Missing {
+		sources := required.SourcesOf(action)
+		if len(sources) == 0 {
+			missing[""] = append(missing[""], action)
+		}
+		for _, res := range sources {
+			missing[res.Address()] = append(missing[res.Address()], action)
+			locations[res.Address()] = res.Location
+		}
+	}
+	addresses := make([]string, 0, len(missing))
+	for address := range missing {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		message := fmt.Sprintf("%s requires %s, which the policy does not grant", address, strings.Join(missing[address], ", "))
+		if address == "" {
+			message = fmt.Sprintf("%s required but not granted", strings.Join(missing[address], ", "))
+		}
+		annotations = append(annotations, github.Annotation{
+			Level:   github.Error,
+			File:    locations[address].File,
+			Line:    locations[address].Line,
+			Title:   "Missing permissions",
+			Message: message,
+		})
+	}
+
+	locate := policyLocator()
+	for _, action := range result.Excessive {
+		loc := locate(action)
+		annotations = append(annotations, github.Annotation{
+			Level:   github.Warning,
+			File:    loc.File,
+			Line:    loc.Line,
+			Title:   "Excessive permission",
+			Message: fmt.Sprintf("%s is granted but not required by the IaC code (risk: %s)", action, risk.Classify(action)),
+		})
+	}
+	for _, g := range broadGrants {
+		loc := locate(g.Action)
+		annotations = append(annotations, github.Annotation{
+			Level:   github.Warning,
+			File:    loc.File,
+			Line:    loc.Line,
+			Title:   "Dangerously broad grant",
+			Message: fmt.Sprintf("[%s] %s", g.Severity, g),
+		})
+	}
+	for _, problem := range invalid {
+		loc := locate(problem.Action)
+		annotations = append(annotations, github.Annotation{
+			Level:   github.Warning,
+			File:    loc.File,
+			Line:    loc.Line,
+			Title:   "Nonexistent action",
+			Message: problem.String(),
+		})
+	}
+
+	for _, a := range annotations {
+		fmt.Println(a)
+	}
+	fmt.Printf("%d missing, %d excessive, %s\n",
+		len(result.Missing), len(result.Excessive), plural(len(broadGrants), "broad grant"))
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/apply"
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/policy"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply [path]",
+	Short: "Create or update an IAM managed policy in AWS",
+	Long: `Push the policy generated from IaC files (or a JSON policy file given with
+--policy) to AWS as a customer managed policy.
+
+If the policy exists and differs, a new default version is created and the
+oldest versions beyond --keep-versions are deleted.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runApply,
+}
+
+var (
+	applyName        string
+	applyPath        string
+	applyDescription string
+	applyRole        string
+	applyPolicyFile  string
+	applyKeep        int
+	applyDryRun      bool
+)
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().StringVar(&applyName, "name", "", "Name of the managed policy (required)")
+	applyCmd.Flags().StringVar(&applyPath, "path", "/", "IAM path of the managed policy")
+	applyCmd.Flags().StringVar(&applyDescription, "description", "", "Description used when creating the policy")
+	applyCmd.Flags().StringVar(&applyRole, "role", "", "Name of an IAM role to attach the policy to")
+	applyCmd.Flags().StringVarP(&applyPolicyFile, "policy", "p", "", "Apply a JSON policy file instead of generating from IaC")
+	applyCmd.Flags().IntVar(&applyKeep, "keep-versions", apply.MaxVersions, "Number of policy versions to keep, including the new one")
+	applyCmd.Flags().BoolVar(&applyDryRun, "dry-run", false, "Show a statement-level diff of what would change without modifying AWS")
+	_ = applyCmd.MarkFlagRequired("name")
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	if applyKeep < 1 || applyKeep > apply.MaxVersions {
+		return fmt.Errorf("--keep-versions must be between 1 and %d", apply.MaxVersions)
+	}
+
+	ctx := context.Background()
+	document, err := applyDocument(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	applier, err := apply.New(ctx)
+	if err != nil {
+		return err
+	}
+
+	if applyDryRun {
+		return printApplyPlan(ctx, applier, document)
+	}
+
+	result, err := applier.Apply(ctx, document, apply.Options{
+		Name:         applyName,
+		Path:         applyPath,
+		Description:  applyDescription,
+		Role:         applyRole,
+		KeepVersions: applyKeep,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case result.Created:
+		fmt.Printf("✓ Created policy %s\n", result.ARN)
+	case result.Unchanged:
+		fmt.Printf("✓ Policy %s is up to date (version %s)\n", result.ARN, result.VersionID)
+	default:
+		fmt.Printf("✓ Updated policy %s to version %s\n", result.ARN, result.VersionID)
+	}
+	if len(result.Pruned) > 0 {
+		fmt.Printf("  Deleted old versions: %s\n", strings.Join(result.Pruned, ", "))
+	}
+	if result.Attached {
+		fmt.Printf("  Attached to role: %s\n", applyRole)
+	}
+
+	return nil
+}
+
+// printApplyPlan prints what apply would change, making only read-only API calls
+func printApplyPlan(ctx context.Context, applier *apply.Applier, document string) error {
+	current, err := applier.GetCurrent(ctx, applyName, applyPath)
+	if err != nil {
+		return err
+	}
+
+	desired, err := policy.ParsePolicy([]byte(document))
+	if err != nil {
+		return fmt.Errorf("parsing policy: %w", err)
+	}
+
+	existing := &policy.IAMPolicy{}
+	switch {
+	case current == nil:
+		fmt.Printf("Would create policy %s%s\n", applyPath, applyName)
+	case apply.SameDocument(current.Document, document):
+		fmt.Printf("✓ Policy %s is up to date (version %s); no changes would be made\n", current.ARN, current.VersionID)
+		return nil
+	default:
+		fmt.Printf("Would update policy %s (current version %s)\n", current.ARN, current.VersionID)
+		existing, err = policy.ParsePolicy([]byte(current.Document))
+		if err != nil {
+			return fmt.Errorf("parsing current policy: %w", err)
+		}
+	}
+
+	for _, change := range checker.CompareStatements(existing, desired) {
+		switch {
+		case change.Added:
+			fmt.Printf("\n  + statement %s\n", change.Sid)
+		case change.Removed:
+			fmt.Printf("\n  - statement %s\n", change.Sid)
+		default:
+			fmt.Printf("\n  ~ statement %s\n", change.Sid)
+		}
+		printStatementChanges("action", change.Changes.AddedActions, change.Changes.RemovedActions)
+		printStatementChanges("resource", change.Changes.AddedResources, change.Changes.RemovedResources)
+	}
+
+	if current != nil {
+		fmt.Printf("\nOld versions beyond %d would be deleted\n", applyKeep)
+	}
+	if applyRole != "" {
+		fmt.Printf("Would attach to role: %s\n", applyRole)
+	}
+	return nil
+}
+
+// printStatementChanges prints the added and removed entries of a statement
+func printStatementChanges(kind string, added, removed []string) {
+	for _, s := range added {
+		fmt.Printf("      + %s %s\n", kind, s)
+	}
+	for _, s := range removed {
+		fmt.Printf("      - %s %s\n", kind, s)
+	}
+}
+
+// applyDocument returns the JSON policy document to apply
+func applyDocument(ctx context.Context, path string) (string, error) {
+	var iamPolicy *policy.IAMPolicy
+	var err error
+	if applyPolicyFile != "" {
+		iamPolicy, err = loadPolicyFile(applyPolicyFile)
+	} else {
+		iamPolicy, err = generateFromPath(ctx, path)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if len(iamPolicy.Statement) == 0 {
+		return "", fmt.Errorf("policy has no statements")
+	}
+	if n := iamPolicy.WidenReferences(); n > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s with Terraform references widened to \"*\"\n", plural(n, "resource"))
+	}
+
+	return iamPolicy.ToJSON()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mizzy/least/internal/managedpolicy"
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/provider"
+)
+
+// resolvePolicyAttachments loads the documents of attached managed policies
+// whose statements are not defined in the IaC code, so the effective existing
+// permissions are not undercounted. Unresolvable attachments are returned as errors.
+func resolvePolicyAttachments(ctx context.Context, result *provider.ParseResult) ([]*policy.IAMPolicy, []error) {
+	var resolved []*policy.IAMPolicy
+	var errs []error
+	seen := make(map[string]bool)
+
+	for _, att := range result.PolicyAttachments {
+		arn := att.PolicyARN
+
+		if arn == "" {
+			pol, ok := findPolicyByRef(result.Policies, att.PolicyRef)
+			if !ok {
+				// References to policy resources in the same configuration are
+				// already counted through their documents
+				if strings.HasPrefix(att.PolicyRef, "data.") {
+					errs = append(errs, fmt.Errorf("%s: cannot resolve policy reference %q", att.Address, att.PolicyRef))
+				}
+				continue
+			}
+			if pol.ARN == "" {
+				if len(pol.Statements) == 0 {
+					errs = append(errs, fmt.Errorf("%s: policy %s has no statements or literal ARN", att.Address, pol.Address))
+				}
+				continue
+			}
+			arn = pol.ARN
+		}
+
+		if seen[arn] {
+			continue
+		}
+		seen[arn] = true
+
+		pol, err := fetchManagedPolicy(ctx, arn)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", att.Address, err))
+			continue
+		}
+		resolved = append(resolved, pol)
+	}
+
+	return resolved, errs
+}
+
+// findPolicyByRef finds the policy a reference like "aws_iam_policy.deploy.arn" points to
+func findPolicyByRef(policies []provider.IAMPolicy, ref string) (provider.IAMPolicy, bool) {
+	for _, pol := range policies {
+		if pol.Address != "" && (ref == pol.Address || strings.HasPrefix(ref, pol.Address+".")) {
+			return pol, true
+		}
+	}
+	return provider.IAMPolicy{}, false
+}
+
+// fetchManagedPolicy loads a managed policy from the embedded catalog, falling back to the IAM API
+func fetchManagedPolicy(ctx context.Context, arn string) (*policy.IAMPolicy, error) {
+	if doc, ok := managedpolicy.Get(arn); ok {
+		return policy.ParsePolicy(doc)
+	}
+
+	doc, err := managedpolicy.Fetch(ctx, arn)
+	if err != nil {
+		if managedpolicy.IsAWSManaged(arn) {
+			return nil, fmt.Errorf("AWS managed policy %s is not in the embedded catalog of %d policies and could not be fetched: %w",
+				managedpolicy.NameFromARN(arn), len(managedpolicy.Names()), err)
+		}
+		return nil, fmt.Errorf("fetching managed policy: %w", err)
+	}
+	return policy.ParsePolicy(doc)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/mizzy/least/internal/browse"
+)
+
+var browseCmd = &cobra.Command{
+	Use:   "browse [path]",
+	Short: "Browse the generated policy in the terminal",
+	Long: `Generate the policy of the IaC files in path and browse its statements,
+grouped by the services of their actions or by the modules of the resources
+requiring them. Open a statement to see its resources and, for each action,
+the resources requiring it with their locations.
+
+Keys: up/down (or k/j) move, enter (or right) opens a group or statement,
+left (or backspace) closes it, g switches grouping, c copies the statement
+under the cursor as policy JSON, q quits. Copying uses the OSC 52 escape
+sequence, which most terminals (and tmux with set-clipboard on) pass to the
+system clipboard.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runBrowse,
+}
+
+func init() {
+	rootCmd.AddCommand(browseCmd)
+}
+
+func runBrowse(cmd *cobra.Command, args []string) error {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return fmt.Errorf("browse needs a terminal; use generate or explain to write the policy or trace an action")
+	}
+
+	p, err := generateFromPath(context.Background(), path)
+	if err != nil {
+		return err
+	}
+
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return fmt.Errorf("setting up the terminal: %w", err)
+	}
+	defer term.Restore(in, state)
+	// Switch to the alternate screen and hide the cursor until done
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	b := browse.New(p)
+	buf := make([]byte, 16)
+	for {
+		width, height, err := term.GetSize(out)
+		if err != nil {
+			width, height = 80, 24
+		}
+		var screen strings.Builder
+		screen.WriteString("\x1b[H\x1b[2J")
+		for i, line := range b.Render(height) {
+			if i > 0 {
+				screen.WriteString("\r\n")
+			}
+			screen.WriteString(truncate(line, width))
+		}
+		fmt.Print(screen.String())
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return fmt.Errorf("reading keys: %w", err)
+		}
+		key := browse.ParseKey(buf[:n])
+		if !b.Handle(key) {
+			return nil
+		}
+		if key == browse.Copy {
+			b.Message = copyStatement(b)
+		}
+	}
+}
+
+// copyStatement copies the selected statement to the clipboard with OSC 52,
+// and returns the status to show
+func copyStatement(b *browse.Browser) string {
+	stmt := b.Selected()
+	if stmt == nil {
+		return "Move to a statement to copy it"
+	}
+	text, err := browse.CopyText(stmt)
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	fmt.Printf("\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+	return fmt.Sprintf("Copied statement %s", stmt.Sid)
+}
+
+// truncate cuts a line to the width of the terminal
+func truncate(line string, width int) string {
+	runes := []rune(line)
+	if width <= 0 || len(runes) <= width {
+		return line
+	}
+	return string(runes[:width])
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/provider"
+	"github.com/mizzy/least/internal/provider/terraform"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the parse result cache",
+	Long: `Manage the cache of parse results. Results are stored per directory and
+keyed by file content, so repeated runs only re-parse files that changed.
+
+The cache directory is $LEAST_PARSE_CACHE, or least/parse under the user
+cache directory. Pass --no-cache to any command to bypass it.`,
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all cached parse results",
+	Args:  cobra.NoArgs,
+	RunE:  runCacheClear,
+}
+
+var (
+	noCache    bool
+	parseCache = terraform.NewCache(terraform.DefaultCacheDir())
+)
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Re-parse all files instead of using cached parse results")
+}
+
+// withCache enables the parse result cache on providers that support it
+func withCache(p provider.Provider) provider.Provider {
+	if tp, ok := p.(*terraform.Provider); ok {
+		if noCache {
+			tp.SetCache(nil)
+		} else {
+			tp.SetCache(parseCache)
+		}
+	}
+	return p
+}
+
+func runCacheClear(cmd *cobra.Command, args []string) error {
+	removed, err := parseCache.ClearCache()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removed %s from %s\n", plural(removed, "cached parse result"), parseCache.CacheDir())
+	return nil
+}
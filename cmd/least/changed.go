@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mizzy/least/internal/changes"
+	"github.com/mizzy/least/internal/provider/terraform"
+)
+
+// changedDirs are the directories whose resources --changed-only checks,
+// set by resolveChanged; nil checks every resource
+var changedDirs map[string]bool
+
+// changedExtensions are the extensions of files that define resources or
+// the values of their attributes
+var changedExtensions = []string{".tf", ".tf.json", ".tfvars"}
+
+// resolveChanged finds the directories affected by uncommitted changes. It
+// returns false when no change can affect the check, so it can be skipped.
+// A changed policy file or directory checks every resource.
+func resolveChanged(ctx context.Context, path string) (bool, error) {
+	if path == stdinPath {
+		return false, fmt.Errorf("--changed-only cannot be used with IaC files read from stdin")
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false, fmt.Errorf("resolving path: %w", err)
+	}
+	dir := absPath
+	if info, err := os.Stat(absPath); err == nil && !info.IsDir() {
+		dir = filepath.Dir(absPath)
+	}
+
+	// Changed modules outside path still affect the resources of roots
+	// under path that call them, so changes are not limited to path
+
+	files, err := changes.Files(ctx, dir)
+	if err != nil {
+		return false, fmt.Errorf("finding changed files: %w", err)
+	}
+
+	var policyPaths []string
+	for _, p := range []string{policyFile, policyDir} {
+		if p != "" && p != stdinPath {
+			if abs, err := filepath.Abs(p); err == nil {
+				policyPaths = append(policyPaths, abs)
+			}
+		}
+	}
+
+	var changed []string
+	for _, file := range files {
+		for _, p := range policyPaths {
+			if within(file, p) {
+				fmt.Fprintf(os.Stderr, "Policy changed (%s); checking every resource\n", file)
+				return true, nil
+			}
+		}
+		for _, ext := range changedExtensions {
+			if strings.HasSuffix(file, ext) {
+				changed = append(changed, file)
+				break
+			}
+		}
+	}
+	if len(changed) == 0 {
+		return false, nil
+	}
+
+	changedDirs = changes.Dirs(changed, terraform.LocalModules)
+	fmt.Fprintf(os.Stderr, "Checking resources affected by %s\n", plural(len(changed), "changed file"))
+	return true, nil
+}
+
+// within checks if path is root or below it
+func within(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
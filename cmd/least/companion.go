@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mizzy/least/internal/companion"
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/provider"
+)
+
+// resourcePolicies makes generate append the resource-based policies that
+// cross-service access in the IaC needs to the Terraform output
+var resourcePolicies bool
+
+// withResourcePolicies appends the resource policy snippets of the grants
+// implied by the resources, the policies of their VPC endpoints and ECR
+// repositories, the build role policy pushing to the repositories, and the
+// policies of the roles of schedules, pipes and EventBridge targets, to a
+// rendered Terraform policy
+func withResourcePolicies(rendered string, resources []provider.Resource, required *policy.IAMPolicy) string {
+	grants := companion.Grants(resources)
+	endpoints := companion.EndpointPolicies(resources, required)
+	repositories := companion.RepositoryAccesses(resources)
+	roles := companion.RoleGrants(resources)
+	if len(grants) == 0 && len(endpoints) == 0 && len(repositories) == 0 && len(roles) == 0 {
+		fmt.Fprintln(os.Stderr, "No cross-service access, VPC endpoints or ECR repositories needing resource policies found")
+		return rendered
+	}
+	for _, g := range grants {
+		fmt.Fprintf(os.Stderr, "Resource policy: %s access to %s for %s\n", g.Service, g.Target, g.Source)
+	}
+	for _, ep := range endpoints {
+		fmt.Fprintf(os.Stderr, "Endpoint policy: %s limited to %s on %s\n", ep.Endpoint, ep.Service, plural(len(ep.Resources), "resource"))
+	}
+	for _, r := range repositories {
+		fmt.Fprintf(os.Stderr, "Repository policy: %s pulled by %s\n", r.Repository, plural(len(r.Roles)+len(r.Functions), "consumer"))
+	}
+	for _, g := range roles {
+		fmt.Fprintf(os.Stderr, "Role policy: %s access to %s for %s\n", g.Role, g.Target, g.Source)
+	}
+	rendered += "\n# Resource policies for cross-service access; review and add them next to the resources\n\n" +
+		companion.ToTerraform(grants) + companion.EndpointPoliciesToTerraform(endpoints) +
+		companion.RepositoryAccessesToTerraform(repositories)
+	if len(roles) > 0 {
+		rendered += "# Policies of the roles schedules, pipes and EventBridge targets deliver with\n\n" +
+			companion.RoleGrantsToTerraform(roles)
+	}
+	return rendered
+}
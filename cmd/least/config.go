@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/config"
+	"github.com/mizzy/least/internal/ignore"
+	"github.com/mizzy/least/internal/provider"
+)
+
+var (
+	configFile      string
+	cfg             = &config.Config{}
+	includePatterns []string
+	excludePatterns []string
+	useGitIgnore    bool
+)
+
+// loadConfig reads the configuration file and applies its defaults to flags
+// not set on the command line. The default file is optional; an explicitly
+// given one must exist.
+func loadConfig(cmd *cobra.Command, args []string) error {
+	if _, err := os.Stat(configFile); os.IsNotExist(err) && !cmd.Flags().Changed("config") {
+		return nil
+	}
+
+	c, err := config.Load(configFile)
+	if err != nil {
+		return err
+	}
+	cfg = c
+
+	if c.Provider != "" && !cmd.Flags().Changed("provider") {
+		providerName = c.Provider
+	}
+	if c.Offline && !cmd.Flags().Changed("offline") {
+		offlineMode = true
+	}
+	if c.SchemaTTL != "" && !cmd.Flags().Changed("schema-ttl") {
+		schemaTTLValue = c.SchemaTTL
+	}
+	if c.PolicySentry != "" && !cmd.Flags().Changed("policy-sentry") {
+		policySentryFile = c.PolicySentry
+	}
+	if len(c.PolicySentryAccessLevels) > 0 && !cmd.Flags().Changed("policy-sentry-access-levels") {
+		policySentryLevels = c.PolicySentryAccessLevels
+	}
+	if cmd == generateCmd {
+		if c.Output != "" && !cmd.Flags().Changed("output") {
+			outputFile = c.Output
+		}
+		if c.Format != "" && !cmd.Flags().Changed("format") {
+			format = c.Format
+		}
+		if c.Strict && !cmd.Flags().Changed("strict") {
+			strict = true
+		}
+		if c.NoWildcardResources && !cmd.Flags().Changed("no-wildcard-resources") {
+			noWildcards = true
+		}
+	}
+
+	return nil
+}
+
+// pathFilter decides which files and directories below base are analyzed,
+// from the include and exclude patterns and the ignore files in base
+type pathFilter struct {
+	base     string
+	patterns *config.Config
+	ignored  *ignore.Rules
+}
+
+// newPathFilter loads the ignore files in base: .terraformignore, and
+// .gitignore with --gitignore
+func newPathFilter(base string) (*pathFilter, error) {
+	names := []string{ignore.TerraformIgnoreFile}
+	if useGitIgnore || cfg.GitIgnore {
+		names = append(names, ignore.GitIgnoreFile)
+	}
+	rules, err := ignore.Load(base, names...)
+	if err != nil {
+		return nil, fmt.Errorf("reading ignore files: %w", err)
+	}
+
+	return &pathFilter{
+		base: base,
+		patterns: &config.Config{
+			Include: append(append([]string{}, cfg.Include...), includePatterns...),
+			Exclude: append(append([]string{}, cfg.Exclude...), excludePatterns...),
+		},
+		ignored: rules,
+	}, nil
+}
+
+// empty checks if the filter keeps every file
+func (f *pathFilter) empty() bool {
+	return len(f.patterns.Include) == 0 && len(f.patterns.Exclude) == 0 && f.ignored.Empty()
+}
+
+// skip checks if a file, or a directory when dir is true, is excluded.
+// Include patterns select files and are not applied to directories.
+func (f *pathFilter) skip(path string, dir bool) bool {
+	rel, err := filepath.Rel(f.base, path)
+	if err != nil {
+		return false
+	}
+	if f.ignored.Match(rel, dir) {
+		return true
+	}
+	if dir {
+		return f.patterns.ExcludedDir(rel)
+	}
+	return f.patterns.Excluded(rel)
+}
+
+// excludingProvider skips files and directories excluded by the path filter
+type excludingProvider struct {
+	provider.Provider
+}
+
+// Parse parses the path, skipping excluded files and module directories
+// when the provider supports it, and removes results defined in excluded files
+func (p *excludingProvider) Parse(ctx context.Context, path string) (*provider.ParseResult, error) {
+	base := path
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		base = filepath.Dir(path)
+	}
+
+	filter, err := newPathFilter(base)
+	if err != nil {
+		return nil, err
+	}
+	if filter.empty() {
+		return p.Provider.Parse(ctx, path)
+	}
+
+	if f, ok := p.Provider.(provider.Filterable); ok {
+		f.SetPathFilter(filter.skip)
+		defer f.SetPathFilter(nil)
+	}
+
+	result, err := p.Provider.Parse(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Filter(func(loc provider.SourceLocation) bool {
+		return !filter.skip(loc.File, false)
+	})
+
+	return result, nil
+}
+
+// withExclusions wraps a provider to honor the include and exclude patterns
+// from the configuration file and flags, and the ignore files
+func withExclusions(p provider.Provider) provider.Provider {
+	return &excludingProvider{Provider: p}
+}
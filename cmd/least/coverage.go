@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/coverage"
+	"github.com/mizzy/least/internal/provider"
+)
+
+var coverageCmd = &cobra.Command{
+	Use:   "coverage [path]",
+	Short: "Report resources without permission mappings",
+	Long: `List every AWS resource type in the IaC files that has neither a fallback
+mapping nor a CloudFormation schema mapping. Such resources are skipped when
+generating policies, so the generated policy is incomplete for them.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runCoverage,
+}
+
+func init() {
+	rootCmd.AddCommand(coverageCmd)
+}
+
+func runCoverage(cmd *cobra.Command, args []string) error {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	p, err := getProvider(path)
+	if err != nil {
+		return err
+	}
+
+	result, err := p.Parse(context.Background(), path)
+	if err != nil {
+		return fmt.Errorf("parsing files: %w", err)
+	}
+
+	report := coverage.Analyze(result.Resources)
+	fmt.Printf("Mapped: %d of %s (%.1f%%)\n", report.Mapped, plural(report.Total, "resource"), report.Percent())
+
+	if len(report.Unmapped) == 0 {
+		fmt.Println("✓ All AWS resources have permission mappings")
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Println("✗ Unmapped resource types (skipped in generated policies):")
+	for _, tc := range report.Unmapped {
+		fmt.Printf("  %s (%d)\n", tc.Type, len(tc.Resources))
+		for _, res := range tc.Resources {
+			fmt.Printf("    - %s at %s\n", res.Address(), res.Location)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("Run 'least schema sync' to fetch CloudFormation schemas for these types")
+
+	return nil
+}
+
+// warnUnmapped summarizes the resource types skipped during generation
+// because they have no permission mapping
+func warnUnmapped(resources []provider.Resource) {
+	report := coverage.Analyze(resources)
+	if len(report.Unmapped) == 0 {
+		return
+	}
+
+	types := make([]string, 0, len(report.Unmapped))
+	for _, tc := range report.Unmapped {
+		types = append(types, fmt.Sprintf("%s (%d)", tc.Type, len(tc.Resources)))
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s not covered: %s\n",
+		plural(len(report.Unmapped), "resource type"), strings.Join(types, ", "))
+	fmt.Fprintf(os.Stderr, "The policy does not grant the permissions of these %s; run 'least coverage' for details\n",
+		plural(report.UnmappedCount(), "resource"))
+}
+
+// failUnmapped lists the resources without permission mappings and fails,
+// for --strict, since the policy would be missing their permissions
+func failUnmapped(resources []provider.Resource) error {
+	report := coverage.Analyze(resources)
+	if len(report.Unmapped) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr, "✗ Resource types without permission mappings:")
+	for _, tc := range report.Unmapped {
+		fmt.Fprintf(os.Stderr, "  %s (%d)\n", tc.Type, len(tc.Resources))
+		for _, res := range tc.Resources {
+			fmt.Fprintf(os.Stderr, "    - %s at %s\n", res.Address(), res.Location)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Add custom mappings or run 'least schema sync' to fetch CloudFormation schemas for these types")
+
+	return fmt.Errorf("%s without permission mappings (--strict)", plural(report.UnmappedCount(), "resource"))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/risk"
+)
+
+// denyUnused makes generate write Deny statements for the sensitive actions
+// the IaC does not require instead of the required policy
+var denyUnused bool
+
+// writeDenyPolicy writes the Deny statements for the sensitive actions the
+// required policy does not grant to output or stdout
+func writeDenyPolicy(required *policy.IAMPolicy, output string) error {
+	deny := risk.DenyPolicy(required, risk.High)
+	if len(deny.Statement) == 0 {
+		fmt.Fprintln(os.Stderr, "Warning: every sensitive action is required; nothing to deny")
+	}
+	for _, stmt := range deny.Statement {
+		fmt.Fprintf(os.Stderr, "%s denies %s\n", stmt.Sid, plural(len(stmt.Action), "action pattern"))
+	}
+
+	var rendered string
+	switch format {
+	case "json":
+		var err error
+		if rendered, err = deny.ToJSON(); err != nil {
+			return fmt.Errorf("converting policy to JSON: %w", err)
+		}
+	case "terraform", "tf":
+		rendered = deny.ToTerraformWithOptions(policy.TerraformOutputOptions{Name: "least_privilege_deny"})
+	default:
+		return fmt.Errorf("unsupported format for --deny-unused: %s (use 'json' or 'terraform')", format)
+	}
+	return writeOutput(output, rendered)
+}
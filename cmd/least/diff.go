@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/policy"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old> <new>",
+	Short: "Compare two IAM policies",
+	Long: `Report the actions and resources added and removed between two policies.
+
+Each policy can be a JSON file, a directory with IaC IAM policy definitions
+(as with check --policy-dir), or an IaC path prefixed with "gen:" to compare
+the policies generated from it (e.g., least diff gen:./v1 gen:./v2).`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDiff,
+}
+
+var diffExitCode bool
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().BoolVar(&diffExitCode, "exit-code", false, "Exit with 1 if the policies differ")
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	before, err := loadPolicyInput(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	after, err := loadPolicyInput(ctx, args[1])
+	if err != nil {
+		return err
+	}
+
+	changes := checker.Compare(before, after)
+	if changes.IsEmpty() {
+		fmt.Println("✓ No differences in actions or resources")
+		return nil
+	}
+
+	if len(changes.AddedActions) > 0 || len(changes.RemovedActions) > 0 {
+		fmt.Println("Actions:")
+		printChanges(changes.AddedActions, changes.RemovedActions)
+	}
+	if len(changes.AddedResources) > 0 || len(changes.RemovedResources) > 0 {
+		fmt.Println("Resources:")
+		printChanges(changes.AddedResources, changes.RemovedResources)
+	}
+
+	fmt.Printf("\n%s added, %d removed; %s added, %d removed\n",
+		plural(len(changes.AddedActions), "action"), len(changes.RemovedActions),
+		plural(len(changes.AddedResources), "resource"), len(changes.RemovedResources))
+
+	if diffExitCode {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// loadPolicyInput loads a policy from a JSON file, an IaC policy directory,
+// or generates it from an IaC path prefixed with "gen:"
+func loadPolicyInput(ctx context.Context, input string) (*policy.IAMPolicy, error) {
+	if path, ok := strings.CutPrefix(input, "gen:"); ok {
+		return generateFromPath(ctx, path)
+	}
+
+	if input == stdinPath {
+		return loadPolicyFile(input)
+	}
+
+	info, err := os.Stat(input)
+	if err != nil {
+		return nil, fmt.Errorf("reading policy input: %w", err)
+	}
+	if info.IsDir() {
+		return loadPolicyDir(ctx, input)
+	}
+	return loadPolicyFile(input)
+}
+
+// printChanges prints added and removed items in diff notation
+func printChanges(added, removed []string) {
+	for _, s := range added {
+		fmt.Printf("  + %s\n", s)
+	}
+	for _, s := range removed {
+		fmt.Printf("  - %s\n", s)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/docs"
+)
+
+var docsCmd = &cobra.Command{
+	Use:   "docs [path]",
+	Short: "Generate Markdown documentation of the permissions each module requires",
+	Long: `Generate a Markdown document per Terraform module (the root module and every
+local module it calls) describing the IAM actions its resources require,
+grouped by create, read, update and delete.
+
+With --write, each document is written to ` + docsFileName + ` in the module
+directory, ready to link from the module README.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runDocs,
+}
+
+// docsFileName is the file --write creates in each module directory
+const docsFileName = "PERMISSIONS.md"
+
+var docsWrite bool
+
+func init() {
+	rootCmd.AddCommand(docsCmd)
+
+	docsCmd.Flags().BoolVar(&docsWrite, "write", false, "Write "+docsFileName+" into each module directory")
+}
+
+func runDocs(cmd *cobra.Command, args []string) error {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	p, err := getProvider(path)
+	if err != nil {
+		return err
+	}
+
+	result, err := p.Parse(context.Background(), path)
+	if err != nil {
+		return fmt.Errorf("parsing files: %w", err)
+	}
+
+	base := path
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		base = filepath.Dir(path)
+	}
+
+	modules := docs.GroupByModule(base, result.Resources)
+	if len(modules) == 0 {
+		return fmt.Errorf("no resources found in %s", path)
+	}
+
+	for i, m := range modules {
+		content := docs.Markdown(m)
+		if !docsWrite {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Print(content)
+			continue
+		}
+
+		file := filepath.Join(m.Dir, docsFileName)
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			return fmt.Errorf("writing %s: %w", file, err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", file)
+	}
+
+	return nil
+}
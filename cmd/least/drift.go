@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/drift"
+)
+
+var driftCmd = &cobra.Command{
+	Use:   "drift [path]",
+	Short: "Reconcile a deployed IAM role with the policy the IaC requires",
+	Long: `Compare the inline and attached policies of a deployed IAM role with the
+policy generated from the IaC files in path, and print how to reconcile them:
+missing actions are added to an inline policy (--policy-name), and excessive
+actions are removed from every inline policy.
+
+Run it on a schedule against a checkout of the main branch to keep
+long-lived roles from drifting. With --fix, the reconciliation is applied.
+Attached managed policies may be shared with other roles, so those granting
+excessive actions are only reported.
+
+Exits with the missing exit code while the role drifts.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runDrift,
+}
+
+var (
+	driftRoleARN    string
+	driftPolicyName string
+	driftFix        bool
+)
+
+func init() {
+	rootCmd.AddCommand(driftCmd)
+
+	driftCmd.Flags().StringVar(&driftRoleARN, "role-arn", "", "ARN of the deployed IAM role (required)")
+	driftCmd.Flags().StringVar(&driftPolicyName, "policy-name", drift.DefaultPolicyName, "Inline policy of the role missing actions are added to")
+	driftCmd.Flags().BoolVar(&driftFix, "fix", false, "Apply the reconciliation to the inline policies of the role")
+	driftCmd.Flags().IntVar(&missingExit, "missing-exit-code", 1, "Exit code when the role drifts")
+	_ = driftCmd.MarkFlagRequired("role-arn")
+}
+
+func runDrift(cmd *cobra.Command, args []string) error {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	ctx := context.Background()
+	required, err := generateFromPath(ctx, path)
+	if err != nil {
+		return err
+	}
+	if n := required.WidenReferences(); n > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s with Terraform references widened to \"*\"\n", plural(n, "resource"))
+	}
+
+	api, err := drift.NewAPI(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Fetching the policies of role %s\n", driftRoleARN)
+	role, err := drift.Fetch(ctx, api, driftRoleARN)
+	if err != nil {
+		return err
+	}
+
+	plan := drift.Reconcile(role, required, driftPolicyName)
+	if !plan.HasDrift() {
+		fmt.Printf("✓ Role %s grants exactly the required permissions\n", role.Name)
+		return nil
+	}
+
+	fmt.Printf("✗ Role %s drifted: %d missing, %d excessive\n", role.Name, len(plan.Result.Missing), len(plan.Result.Excessive))
+	printReconciliation(plan)
+
+	if driftFix && len(plan.Changes) > 0 {
+		if err := drift.Apply(ctx, api, role, plan); err != nil {
+			return err
+		}
+		fmt.Printf("\n✓ Reconciled %s of role %s\n", plural(len(plan.Changes), "inline policy"), role.Name)
+		if len(plan.Attached) == 0 {
+			return nil
+		}
+	}
+	os.Exit(missingExit)
+	return nil
+}
+
+// printReconciliation prints the changes reconciling the policies of a role
+func printReconciliation(plan *drift.Plan) {
+	if len(plan.Changes) > 0 {
+		fmt.Println("\nReconciliation:")
+	}
+	for _, c := range plan.Changes {
+		switch {
+		case c.Document == nil:
+			fmt.Printf("  - inline policy %s (left empty)\n", c.Policy)
+		case c.New:
+			fmt.Printf("  + inline policy %s\n", c.Policy)
+		default:
+			fmt.Printf("  ~ inline policy %s\n", c.Policy)
+		}
+		for _, action := range c.Added {
+			fmt.Printf("      + %s\n", action)
+		}
+		for _, action := range c.Removed {
+			fmt.Printf("      - %s\n", action)
+		}
+	}
+
+	if len(plan.Attached) > 0 {
+		fmt.Println("\nAttached policies granting excessive permissions (not changed; detach or narrow them):")
+		for _, c := range plan.Attached {
+			fmt.Printf("  %s: %s\n", c.Policy, strings.Join(c.Removed, ", "))
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/provider"
+)
+
+// ecsExecutionRole makes generate write the task execution role policies of
+// the ECS task definitions instead of the deployer policy
+var ecsExecutionRole bool
+
+// writeExecutionRolePolicies writes the task execution role policy of each
+// aws_ecs_task_definition to output or stdout: one aws_iam_policy_document
+// per task definition, or with --format json the policy of the only one
+func writeExecutionRolePolicies(gen *policy.Generator, resources []provider.Resource, output string, opts policy.TerraformOutputOptions) error {
+	var tasks []provider.Resource
+	for _, res := range resources {
+		if res.Type == "aws_ecs_task_definition" {
+			tasks = append(tasks, res)
+		}
+	}
+	if len(tasks) == 0 {
+		return fmt.Errorf("no aws_ecs_task_definition resources found")
+	}
+
+	for _, task := range tasks {
+		if err := failWildcardResources(gen.ExecutionRolePolicy(task)); err != nil {
+			return fmt.Errorf("%s: %w", task.FullAddress(), err)
+		}
+	}
+
+	var rendered strings.Builder
+	switch format {
+	case "json":
+		if len(tasks) > 1 {
+			return fmt.Errorf("found %s; select one with --target for --format json", plural(len(tasks), "task definition"))
+		}
+		data, err := gen.ExecutionRolePolicy(tasks[0]).ToJSON()
+		if err != nil {
+			return fmt.Errorf("converting policy to JSON: %w", err)
+		}
+		rendered.WriteString(data)
+	case "terraform", "tf":
+		for _, task := range tasks {
+			p := gen.ExecutionRolePolicy(task)
+			if len(p.Statement) == 0 {
+				fmt.Fprintf(os.Stderr, "Warning: %s needs no task execution role permissions\n", task.FullAddress())
+				continue
+			}
+			opts.Name = task.Name + "_execution"
+			fmt.Fprintf(&rendered, "# Task execution role policy of %s\n", task.FullAddress())
+			rendered.WriteString(p.ToTerraformWithOptions(opts))
+			rendered.WriteString("\n")
+			// The data sources of the account and region are written once
+			opts.NeedCallerIdentity, opts.NeedRegion = false, false
+		}
+	default:
+		return fmt.Errorf("unsupported format for --ecs-execution-role: %s (use 'json' or 'terraform')", format)
+	}
+	return writeOutput(output, rendered.String())
+}
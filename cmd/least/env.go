@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/mizzy/least/internal/provider"
+	"github.com/mizzy/least/internal/provider/terraform"
+)
+
+// envFlags are the --env name=varfile values
+var envFlags []string
+
+// environment is a --env environment and the variables of its var-file
+type environment struct {
+	name    string
+	varFile string
+	vars    terraform.Variables
+}
+
+// environments are the environments to generate a policy for, in --env
+// order; currentEnv is the one being generated, nil without --env
+var (
+	environments []environment
+	currentEnv   *environment
+)
+
+// loadEnvironments parses --env and loads the var-files of the environments
+func loadEnvironments(paths []string) error {
+	environments = nil
+	if len(envFlags) == 0 {
+		return nil
+	}
+
+	if providerName != "" && providerName != "terraform" {
+		return fmt.Errorf("--env requires the terraform provider")
+	}
+	if slices.Contains(paths, stdinPath) {
+		return fmt.Errorf("--env cannot be used with input from stdin")
+	}
+	if len(envFlags) > 1 && !strings.Contains(outputFile, "{env}") {
+		return fmt.Errorf("--env with several environments requires --output with {env} (e.g., policy.{env}.json)")
+	}
+
+	for _, value := range envFlags {
+		name, varFile, ok := strings.Cut(value, "=")
+		if !ok || name == "" || varFile == "" {
+			return fmt.Errorf("invalid --env: %s (use name=varfile, e.g., prod=prod.tfvars)", value)
+		}
+		if slices.ContainsFunc(environments, func(e environment) bool { return e.name == name }) {
+			return fmt.Errorf("duplicate --env: %s", name)
+		}
+		vars, err := terraform.LoadVarFile(varFile)
+		if err != nil {
+			return fmt.Errorf("loading --env %s: %w", name, err)
+		}
+		environments = append(environments, environment{name: name, varFile: varFile, vars: vars})
+	}
+	return nil
+}
+
+// generateEnvironments generates the policies of each environment, with the
+// {env} placeholder of --output replaced with its name
+func generateEnvironments(paths []string) error {
+	defer func() { currentEnv = nil }()
+
+	for i := range environments {
+		currentEnv = &environments[i]
+		fmt.Fprintf(os.Stderr, "Environment %s (%s)\n", currentEnv.name, currentEnv.varFile)
+		if err := generatePathPolicies(paths); err != nil {
+			return fmt.Errorf("environment %s: %w", currentEnv.name, err)
+		}
+	}
+	return nil
+}
+
+// envOutput replaces {env} in an output file name with the environment
+// being generated
+func envOutput(output string) string {
+	if currentEnv == nil {
+		return output
+	}
+	return strings.ReplaceAll(output, "{env}", currentEnv.name)
+}
+
+// withVariables sets the variables of the environment being generated on
+// the Terraform provider, or clears them without --env
+func withVariables(p provider.Provider) provider.Provider {
+	if tp, ok := p.(*terraform.Provider); ok {
+		if currentEnv != nil {
+			tp.SetVariables(currentEnv.vars)
+		} else {
+			tp.SetVariables(nil)
+		}
+	}
+	return p
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/evidence"
+	"github.com/mizzy/least/internal/policy"
+)
+
+var evidenceCmd = &cobra.Command{
+	Use:   "evidence",
+	Short: "Verify compliance evidence reports written by check --evidence",
+	Long: `check --evidence writes a compliance evidence report: the result of the check
+mapped onto the least-privilege controls of CIS AWS Foundations (1.16),
+SOC 2 (CC6.1, CC6.3) and NIST 800-53 (AC-6), with the time of the check and
+the SHA-256 digests of the checked and the required policy. With
+--evidence-key, the report is signed with an Ed25519 private key, e.g.
+created with:
+
+  openssl genpkey -algorithm ed25519 -out evidence.pem
+  openssl pkey -in evidence.pem -pubout -out evidence.pub.pem`,
+}
+
+var evidenceVerifyCmd = &cobra.Command{
+	Use:   "verify <file>",
+	Short: "Verify the signature of an evidence report and print its controls",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runEvidenceVerify,
+}
+
+var (
+	evidenceFile      string
+	evidenceKey       string
+	evidencePublicKey string
+)
+
+func init() {
+	rootCmd.AddCommand(evidenceCmd)
+	evidenceCmd.AddCommand(evidenceVerifyCmd)
+
+	evidenceVerifyCmd.Flags().StringVar(&evidencePublicKey, "public-key", "", "PEM-encoded Ed25519 public key the report was signed with (required)")
+	_ = evidenceVerifyCmd.MarkFlagRequired("public-key")
+
+	checkCmd.Flags().StringVar(&evidenceFile, "evidence", "", "Write a compliance evidence report mapping the check onto CIS, SOC 2 and NIST controls to this file")
+	checkCmd.Flags().StringVar(&evidenceKey, "evidence-key", "", "PEM-encoded Ed25519 private key to sign the --evidence report with")
+}
+
+// writeEvidence writes the evidence report of a check, signed with
+// --evidence-key if given
+func writeEvidence(path, policySource string, existing, required *policy.IAMPolicy, result *checker.Result) error {
+	report, err := evidence.Build(evidence.Input{
+		Tool:         "least " + version,
+		Path:         path,
+		PolicySource: policySource,
+		Existing:     existing,
+		Required:     required,
+		Result:       result,
+		BroadGrants:  findBroadGrants(existing),
+		Time:         time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if evidenceKey != "" {
+		key, err := evidence.LoadPrivateKey(evidenceKey)
+		if err != nil {
+			return err
+		}
+		if err := report.Sign(key); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, "Note: the evidence report is not signed; pass --evidence-key so auditors can verify it")
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(evidenceFile, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Evidence report written to %s\n", evidenceFile)
+	return nil
+}
+
+func runEvidenceVerify(cmd *cobra.Command, args []string) error {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	pub, err := evidence.LoadPublicKey(evidencePublicKey)
+	if err != nil {
+		return err
+	}
+	report, err := evidence.Verify(data, pub)
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+
+	fmt.Printf("✓ Signature of %s is valid\n", args[0])
+	fmt.Printf("  Checked %s against %s at %s (%s)\n", report.PolicySource, report.Path, report.GeneratedAt, report.Tool)
+	for _, c := range report.Controls {
+		mark := "✓"
+		if c.Status != evidence.Pass {
+			mark = "✗"
+		}
+		fmt.Printf("  %s %s %s: %s\n", mark, c.Framework, c.ID, c.Title)
+		for _, d := range c.Details {
+			fmt.Printf("      - %s\n", d)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/explain"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <action> [path]",
+	Short: "Explain why an action appears in the generated policy",
+	Long: `Show which resources require an action, which mapping (CloudFormation schema
+or fallback) contributed it, and which statement it lands in.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runExplain,
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+}
+
+func runExplain(cmd *cobra.Command, args []string) error {
+	action := args[0]
+	path := "."
+	if len(args) > 1 {
+		path = args[1]
+	}
+
+	iamPolicy, err := generateFromPath(context.Background(), path)
+	if err != nil {
+		return err
+	}
+
+	reasons := explain.Explain(iamPolicy, action)
+	if len(reasons) == 0 {
+		return fmt.Errorf("%s is not in the policy generated from %s", action, path)
+	}
+
+	fmt.Printf("%s is required by %s:\n", action, plural(len(reasons), "resource"))
+	for _, r := range reasons {
+		fmt.Printf("\n  %s (%s)\n", r.Resource.Address(), r.Resource.Location)
+
+		statement := r.Statement
+		if r.MatchedBy != action {
+			statement += fmt.Sprintf(" (via %s)", r.MatchedBy)
+		}
+		fmt.Printf("    statement: %s\n", statement)
+
+		var source string
+		switch r.Source {
+		case "fallback":
+			source = "fallback mapping"
+		case "custom":
+			source = "local mappings file"
+		case "policy-sentry":
+			source = "policy_sentry dataset"
+		default:
+			source = "CloudFormation schema " + r.Source
+		}
+		if len(r.Operations) > 0 {
+			source += fmt.Sprintf(" (%s)", strings.Join(r.Operations, ", "))
+		}
+		fmt.Printf("    mapping:   %s\n", source)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/mizzy/least/internal/gcp"
+	"github.com/mizzy/least/internal/provider"
+)
+
+// supportedClouds are the --cloud values, in generation order
+var supportedClouds = []string{"aws", "gcp"}
+
+// validateClouds checks --cloud and the options that depend on it
+func validateClouds() error {
+	var selected []string
+	for _, cloud := range supportedClouds {
+		if slices.Contains(clouds, cloud) {
+			selected = append(selected, cloud)
+		}
+	}
+	for _, cloud := range clouds {
+		if !slices.Contains(supportedClouds, cloud) {
+			return fmt.Errorf("unsupported --cloud: %s (use %s)", cloud, strings.Join(supportedClouds, ", "))
+		}
+	}
+	clouds = selected
+
+	if len(clouds) > 1 && !strings.Contains(outputFile, "{cloud}") {
+		return fmt.Errorf("--cloud with several clouds requires --output with {cloud} (e.g., policy.{cloud}.tf)")
+	}
+	if slices.Contains(clouds, "gcp") {
+		if slices.Contains([]string{"rego", "session", "permission-set", "stackset", "roles-anywhere"}, format) {
+			return fmt.Errorf("--format %s is not supported for gcp", format)
+		}
+		if withMetadata {
+			return fmt.Errorf("--metadata is not supported for gcp")
+		}
+	}
+	return nil
+}
+
+// generateGCPRole generates the custom role of the GCP resources and writes
+// it to output or stdout
+func generateGCPRole(result *provider.ParseResult, output string) error {
+	role, unmapped := gcp.Generate(result.Resources)
+	if len(unmapped) > 0 {
+		if strict {
+			return fmt.Errorf("GCP resource types without permission mappings (--strict): %s", strings.Join(unmapped, ", "))
+		}
+		fmt.Fprintf(os.Stderr, "Warning: GCP resource types without permission mappings skipped: %s\n", strings.Join(unmapped, ", "))
+	}
+	fmt.Fprintf(os.Stderr, "GCP custom role requires %s\n", plural(len(role.IncludedPermissions), "permission"))
+
+	var rendered string
+	switch format {
+	case "json":
+		var err error
+		if rendered, err = role.ToJSON(); err != nil {
+			return fmt.Errorf("converting role to JSON: %w", err)
+		}
+	case "terraform", "tf":
+		rendered = role.ToTerraform()
+	default:
+		return fmt.Errorf("unsupported format: %s (use 'json' or 'terraform')", format)
+	}
+
+	return writeOutput(output, rendered)
+}
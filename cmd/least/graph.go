@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/graph"
+)
+
+var graphCmd = &cobra.Command{
+	Use:   "graph [path]",
+	Short: "Output a graph of resources, required actions and statements",
+	Long: `Output a graph of the generated policy: each resource points to the actions
+it requires, and each action to the statement granting it. iam:PassRole
+edges are highlighted.
+
+Render DOT output with Graphviz (e.g., least graph | dot -Tsvg > policy.svg),
+or embed Mermaid output in Markdown.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runGraph,
+}
+
+var (
+	graphFormat string
+	graphOutput string
+)
+
+func init() {
+	rootCmd.AddCommand(graphCmd)
+
+	graphCmd.Flags().StringVarP(&graphFormat, "format", "f", "dot", "Output format: dot, mermaid")
+	graphCmd.Flags().StringVarP(&graphOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+func runGraph(cmd *cobra.Command, args []string) error {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	var render func(*graph.Graph) string
+	switch graphFormat {
+	case "dot":
+		render = (*graph.Graph).DOT
+	case "mermaid":
+		render = (*graph.Graph).Mermaid
+	default:
+		return fmt.Errorf("unsupported format: %s (use dot or mermaid)", graphFormat)
+	}
+
+	p, err := generateFromPath(context.Background(), path)
+	if err != nil {
+		return err
+	}
+
+	output := render(graph.Build(p))
+
+	if graphOutput == "" {
+		fmt.Print(output)
+		return nil
+	}
+
+	if err := os.WriteFile(graphOutput, []byte(output), 0644); err != nil {
+		return fmt.Errorf("writing graph: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Graph written to: %s\n", graphOutput)
+	return nil
+}
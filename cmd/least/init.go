@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/mizzy/least/internal/config"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init [path]",
+	Short: "Create a starter configuration file",
+	Long: `Inspect the IaC files in path and write a starter configuration file
+(` + config.DefaultFile + ` unless --config is given) with the detected provider,
+a suggested output file, and exclusions for directories that should not be analyzed.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runInit,
+}
+
+var initForce bool
+
+// excludeCandidates are directories excluded by init when they exist
+var excludeCandidates = []string{"examples", "test", "tests", "fixtures"}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+
+	initCmd.Flags().BoolVar(&initForce, "force", false, "Overwrite an existing configuration file")
+}
+
+func runInit(cmd *cobra.Command, args []string) error {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	if _, err := os.Stat(configFile); err == nil && !initForce {
+		return fmt.Errorf("%s already exists (use --force to overwrite)", configFile)
+	}
+
+	providers, err := registry.Detect(path)
+	if err != nil {
+		return fmt.Errorf("detecting provider: %w", err)
+	}
+	if len(providers) == 0 {
+		return fmt.Errorf("no supported IaC files found in %s", path)
+	}
+
+	c := config.Config{
+		Provider: providers[0].Name(),
+		Format:   "json",
+		Output:   "least-policy.json",
+		Exclude:  []string{".terraform/**"},
+	}
+	if c.Provider == "terraform" {
+		c.Format = "terraform"
+		c.Output = "least-policy.tf"
+	}
+	for _, dir := range excludeCandidates {
+		if info, err := os.Stat(filepath.Join(path, dir)); err == nil && info.IsDir() {
+			c.Exclude = append(c.Exclude, dir+"/**")
+		}
+	}
+
+	data, err := yaml.Marshal(&c)
+	if err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+	header := "# least configuration: defaults for command-line flags\n" +
+		"# exclude patterns are relative to the analyzed path; ** matches any directories\n"
+
+	if err := os.WriteFile(configFile, append([]byte(header), data...), 0644); err != nil {
+		return fmt.Errorf("writing config: %w", err)
+	}
+
+	fmt.Printf("✓ Wrote %s\n", configFile)
+	fmt.Printf("  provider: %s\n", c.Provider)
+	fmt.Printf("  output:   %s (%s)\n", c.Output, c.Format)
+	fmt.Printf("  exclude:  %v\n", c.Exclude)
+	fmt.Printf("\nRun 'least generate %s' to create the policy\n", path)
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/irsa"
+	"github.com/mizzy/least/internal/policy"
+)
+
+var irsaCmd = &cobra.Command{
+	Use:   "irsa [path...]",
+	Short: "Generate candidate IRSA policies from Kubernetes manifests and Helm values",
+	Long: `Find the Kubernetes service accounts annotated with an IAM role
+(eks.amazonaws.com/role-arn), in manifests or in the serviceAccount section of
+Helm values, and generate a candidate policy for each from the AWS resources
+the workloads running under them refer to: ARNs, s3:// URIs and SQS queue URLs
+in environment variables and values, and names in variables such as
+ORDERS_TABLE or UPLOADS_BUCKET.
+
+The actions are those a workload typically needs to read and write the
+resources; review them before attaching the policy to the role.`,
+	RunE: runIRSA,
+}
+
+var (
+	irsaFormat string
+	irsaOutput string
+)
+
+func init() {
+	rootCmd.AddCommand(irsaCmd)
+
+	irsaCmd.Flags().StringVarP(&irsaFormat, "format", "f", "terraform", "Output format: terraform (or tf), json")
+	irsaCmd.Flags().StringVarP(&irsaOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+// irsaPolicy is the candidate policy of a service account in JSON output
+type irsaPolicy struct {
+	ServiceAccount string            `json:"service_account"`
+	RoleARN        string            `json:"role_arn"`
+	Policy         *policy.IAMPolicy `json:"policy"`
+}
+
+func runIRSA(cmd *cobra.Command, args []string) error {
+	paths := args
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	if irsaFormat != "terraform" && irsaFormat != "tf" && irsaFormat != "json" {
+		return fmt.Errorf("unsupported format: %s (use 'json' or 'terraform')", irsaFormat)
+	}
+
+	accounts, warnings, err := irsa.Scan(paths)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: skipping %s\n", w)
+	}
+	if err != nil {
+		return err
+	}
+	if len(accounts) == 0 {
+		return fmt.Errorf("no service accounts annotated with %s found", irsa.RoleAnnotation)
+	}
+	fmt.Fprintf(os.Stderr, "Found %s with IAM roles\n", plural(len(accounts), "service account"))
+
+	var policies []irsaPolicy
+	for _, sa := range accounts {
+		if len(sa.Resources) == 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %s (%s): no AWS resources found in its workloads\n", sa.ID(), sa.File)
+			continue
+		}
+		for _, res := range sa.Resources {
+			fmt.Fprintf(os.Stderr, "  %s: %s (%s)\n", sa.ID(), res.ARN, res.Source)
+		}
+		policies = append(policies, irsaPolicy{ServiceAccount: sa.ID(), RoleARN: sa.RoleARN, Policy: sa.Policy()})
+	}
+
+	var rendered string
+	if irsaFormat == "json" {
+		data, err := json.MarshalIndent(policies, "", "  ")
+		if err != nil {
+			return err
+		}
+		rendered = string(data) + "\n"
+	} else {
+		var b strings.Builder
+		for _, p := range policies {
+			fmt.Fprintf(&b, "# Candidate policy of service account %s, for %s\n", p.ServiceAccount, p.RoleARN)
+			b.WriteString(p.Policy.ToTerraformWithOptions(policy.TerraformOutputOptions{Name: irsaDocumentName(p.ServiceAccount)}))
+			b.WriteString("\n")
+		}
+		rendered = b.String()
+	}
+	return writeOutput(irsaOutput, rendered)
+}
+
+// irsaDocumentName returns the name of the policy document of a service
+// account (e.g., "payments_worker_irsa" for payments/worker)
+func irsaDocumentName(id string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(id))
+	return name + "_irsa"
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/mizzy/least/internal/policy"
+)
+
+// kmsKeyPolicyPrincipal is the ARN of the deploying principal to print a key
+// policy statement for, when resources use customer-managed keys
+var kmsKeyPolicyPrincipal string
+
+// warnKMSKeys warns that the key policies of the customer-managed keys the
+// policy grants the use of must also allow the deployer, and prints the key
+// policy statement for --kms-key-policy
+func warnKMSKeys(iamPolicy *policy.IAMPolicy) error {
+	stmt, ok := iamPolicy.KMSKeyPolicyStatement(kmsKeyPolicyPrincipal)
+	if !ok {
+		return nil
+	}
+
+	var keys []string
+	for _, s := range iamPolicy.Statement {
+		if s.Sid == policy.KMSKeysSid {
+			keys = s.Resource
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Warning: resources encrypt with %s; the key policies must also allow the deploying role:\n", plural(len(keys), "customer-managed KMS key"))
+	for _, key := range keys {
+		fmt.Fprintf(os.Stderr, "  - %s\n", key)
+	}
+
+	if kmsKeyPolicyPrincipal == "" {
+		fmt.Fprintln(os.Stderr, "  Use --kms-key-policy <role ARN> for the key policy statement to add")
+		return nil
+	}
+	data, err := json.MarshalIndent(stmt, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Key policy statement:\n%s\n", data)
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/lambdasrc"
+	"github.com/mizzy/least/internal/policy"
+)
+
+var lambdaCmd = &cobra.Command{
+	Use:   "lambda [path]",
+	Short: "Generate Lambda runtime policies from the AWS SDK calls in function source (experimental)",
+	Long: `Find the aws_lambda_function resources of a Terraform module whose package is
+built from source by an archive_file data source, scan the Go (aws-sdk-go-v2),
+Python (boto3) and Node.js (AWS SDK for JavaScript v2 and v3) files of the
+source for AWS SDK calls, and generate the runtime policy of each function's
+execution role: writing its logs, and the actions of the calls.
+
+The deployer policy least generate writes does not cover what functions do
+once deployed; this command does, but the analysis is static and experimental.
+The resources the calls act on are not known, so every action is granted on
+any resource; scope them before attaching the policy, and check that no calls
+were missed. With --source, the given directory is scanned instead.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runLambda,
+}
+
+var (
+	lambdaSource string
+	lambdaName   string
+	lambdaFormat string
+	lambdaOutput string
+)
+
+func init() {
+	rootCmd.AddCommand(lambdaCmd)
+
+	lambdaCmd.Flags().StringVar(&lambdaSource, "source", "", "Scan the source in this directory instead of the functions of the module")
+	lambdaCmd.Flags().StringVar(&lambdaName, "function-name", "", "Function name of the log group with --source (default: any function)")
+	lambdaCmd.Flags().StringVarP(&lambdaFormat, "format", "f", "terraform", "Output format: terraform (or tf), json")
+	lambdaCmd.Flags().StringVarP(&lambdaOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+// lambdaPolicy is the runtime policy of a function in JSON output
+type lambdaPolicy struct {
+	Function  string            `json:"function,omitempty"`
+	SourceDir string            `json:"source_dir"`
+	Calls     []string          `json:"calls"`
+	Policy    *policy.IAMPolicy `json:"policy"`
+
+	// name is the name of the policy document
+	name string
+}
+
+func runLambda(cmd *cobra.Command, args []string) error {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if lambdaFormat != "terraform" && lambdaFormat != "tf" && lambdaFormat != "json" {
+		return fmt.Errorf("unsupported format: %s (use 'json' or 'terraform')", lambdaFormat)
+	}
+
+	var functions []lambdasrc.Function
+	if lambdaSource != "" {
+		functions = []lambdasrc.Function{{Name: lambdaName, SourceDir: lambdaSource}}
+	} else {
+		var err error
+		functions, err = lambdasrc.Functions(path)
+		if err != nil {
+			return err
+		}
+		if len(functions) == 0 {
+			return fmt.Errorf("no aws_lambda_function resources found in %s", path)
+		}
+	}
+
+	var policies []lambdaPolicy
+	for _, fn := range functions {
+		id := fn.Address
+		if id == "" {
+			id = fn.SourceDir
+		}
+		if fn.SourceDir == "" {
+			fmt.Fprintf(os.Stderr, "Warning: %s (%s:%d): its package is not built from source by an archive_file data source; use --source\n", fn.Address, fn.File, fn.Line)
+			continue
+		}
+		calls, err := lambdasrc.Scan(fn.SourceDir)
+		if err != nil {
+			return err
+		}
+		if len(calls) == 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %s: no AWS SDK calls found in %s\n", id, fn.SourceDir)
+		}
+		p := lambdaPolicy{Function: fn.Address, SourceDir: fn.SourceDir, Policy: lambdasrc.Policy(fn.Name, calls)}
+		for _, call := range calls {
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", id, call)
+			p.Calls = append(p.Calls, call.String())
+		}
+		p.name = lambdaDocumentName(fn)
+		warnUnknownActions(p.Policy)
+		policies = append(policies, p)
+	}
+	if len(policies) == 0 {
+		return fmt.Errorf("no function source found")
+	}
+
+	var rendered string
+	if lambdaFormat == "json" {
+		data, err := json.MarshalIndent(policies, "", "  ")
+		if err != nil {
+			return err
+		}
+		rendered = string(data) + "\n"
+	} else {
+		var b strings.Builder
+		for _, p := range policies {
+			if p.Function != "" {
+				fmt.Fprintf(&b, "# Runtime policy of %s, from the AWS SDK calls in %s (experimental)\n", p.Function, p.SourceDir)
+			} else {
+				fmt.Fprintf(&b, "# Runtime policy from the AWS SDK calls in %s (experimental)\n", p.SourceDir)
+			}
+			b.WriteString(p.Policy.ToTerraformWithOptions(policy.TerraformOutputOptions{Name: p.name}))
+			b.WriteString("\n")
+		}
+		rendered = b.String()
+	}
+	return writeOutput(lambdaOutput, rendered)
+}
+
+// lambdaDocumentName returns the name of the policy document of a function:
+// the resource name, or the source directory with --source, with a _runtime
+// suffix (e.g., "api_runtime" for aws_lambda_function.api)
+func lambdaDocumentName(fn lambdasrc.Function) string {
+	name := filepath.Base(filepath.Clean(fn.SourceDir))
+	if fn.Address != "" {
+		name = fn.Address[strings.LastIndex(fn.Address, ".")+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(name))
+	return name + "_runtime"
+}
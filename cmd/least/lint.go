@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mizzy/least/internal/lint"
+	"github.com/mizzy/least/internal/policy"
+)
+
+// lintGenerated reports lint findings of a generated policy and fails on
+// errors, which point to a broken mapping rather than a problem in the IaC
+func lintGenerated(p *policy.IAMPolicy) error {
+	if note := unverifiedNote(policyActions(p)); note != "" {
+		fmt.Fprintf(os.Stderr, "Note: %s\n", note)
+	}
+
+	findings := lint.Lint(p)
+	if len(findings) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Lint found %s in the generated policy:\n", plural(len(findings), "problem"))
+	for _, f := range findings {
+		fmt.Fprintf(os.Stderr, "  - %s\n", f)
+	}
+
+	if lint.HasErrors(findings) {
+		return fmt.Errorf("generated policy failed lint")
+	}
+	return nil
+}
+
+// failWildcardResources lists the statements of a generated policy on
+// Resource "*" and fails, for --no-wildcard-resources
+func failWildcardResources(p *policy.IAMPolicy) error {
+	if !noWildcards {
+		return nil
+	}
+	wildcards := p.WildcardResources()
+	if len(wildcards) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr, `✗ Statements on Resource "*":`)
+	for _, w := range wildcards {
+		fmt.Fprintf(os.Stderr, "  - %s\n", w)
+	}
+	return fmt.Errorf(`%s on Resource "*" (--no-wildcard-resources)`, plural(len(wildcards), "statement"))
+}
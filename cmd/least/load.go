@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mizzy/least/internal/changes"
+	"github.com/mizzy/least/internal/managedpolicy"
+	"github.com/mizzy/least/internal/mapping"
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/provider"
+	"github.com/mizzy/least/internal/risk"
+)
+
+// generateFromPath parses IaC files and generates the policy they require
+func generateFromPath(ctx context.Context, path string) (*policy.IAMPolicy, error) {
+	p, err := getProvider(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(os.Stderr, "Using provider: %s\n", p.Name())
+
+	result, err := p.Parse(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("parsing files: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Found %d resources in: %s\n", len(result.Resources), path)
+	if err := reportParseErrors(result); err != nil {
+		return nil, err
+	}
+	selectMappings(result)
+	if changedDirs != nil {
+		result.Resources = changes.Affected(result.Resources, changedDirs)
+		fmt.Fprintf(os.Stderr, "%s affected by the changes\n", plural(len(result.Resources), "resource"))
+	}
+	warnUnmapped(result.Resources)
+
+	gen := policy.New()
+	requiredPolicy, err := gen.Generate(result.Resources)
+	if err != nil {
+		return nil, fmt.Errorf("generating required policy: %w", err)
+	}
+	requiredPolicy.AddRequirements(result.Requirements)
+	warnUnknownActions(requiredPolicy)
+	return requiredPolicy, nil
+}
+
+// selectMappings selects the mappings for the hashicorp/aws provider version
+// the parsed IaC uses
+func selectMappings(result *provider.ParseResult) {
+	mapping.SetAWSProviderVersion(result.AWSProviderVersion)
+	if result.AWSProviderVersion != "" {
+		fmt.Fprintf(os.Stderr, "Using mappings for hashicorp/aws %s\n", result.AWSProviderVersion)
+	}
+}
+
+// reportParseErrors prints the errors parsing left in result, such as HCL
+// syntax errors and unresolved modules, whose resources and policies are
+// missing from the analysis. With --fail-on-parse-errors, they are fatal.
+func reportParseErrors(result *provider.ParseResult) error {
+	if len(result.Errors) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "⚠ %s (resources and policies in the affected files or modules are not analyzed):\n", plural(len(result.Errors), "parse error"))
+	for _, err := range result.Errors {
+		fmt.Fprintf(os.Stderr, "  - %v\n", err)
+	}
+
+	if failOnParseErrors {
+		return fmt.Errorf("%s (--fail-on-parse-errors)", plural(len(result.Errors), "parse error"))
+	}
+	return nil
+}
+
+// stdinPath is the path argument that reads input from stdin
+const stdinPath = "-"
+
+// loadPolicyFile loads an IAM policy from a JSON file, or from stdin when
+// path is "-"
+func loadPolicyFile(path string) (*policy.IAMPolicy, error) {
+	var data []byte
+	var err error
+	if path == stdinPath {
+		fmt.Fprintln(os.Stderr, "Loading IAM policy from JSON: stdin")
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		fmt.Fprintf(os.Stderr, "Loading IAM policy from JSON: %s\n", path)
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading policy file: %w", err)
+	}
+	p, err := policy.ParsePolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing policy %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// stdinProvider parses a single IaC document read from stdin
+type stdinProvider struct {
+	provider.Provider
+	source provider.SourceParser
+}
+
+// Parse reads stdin and parses it; the path is ignored
+func (p *stdinProvider) Parse(ctx context.Context, path string) (*provider.ParseResult, error) {
+	src, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("reading stdin: %w", err)
+	}
+	return p.source.ParseSource(ctx, "stdin", src)
+}
+
+// getStdinProvider returns the provider for IaC read from stdin: --provider,
+// or terraform when not given
+func getStdinProvider() (provider.Provider, error) {
+	name := providerName
+	if name == "" {
+		name = "terraform"
+	}
+	p := registry.Get(name)
+	if p == nil {
+		return nil, fmt.Errorf("unknown provider: %s", name)
+	}
+	source, ok := p.(provider.SourceParser)
+	if !ok {
+		return nil, fmt.Errorf("provider %s cannot read from stdin", name)
+	}
+	return &stdinProvider{Provider: p, source: source}, nil
+}
+
+// loadPolicyARN fetches a managed IAM policy from AWS
+func loadPolicyARN(ctx context.Context, arn string) (*policy.IAMPolicy, error) {
+	fmt.Fprintf(os.Stderr, "Fetching IAM policy from AWS: %s\n", arn)
+	document, err := managedpolicy.Fetch(ctx, arn)
+	if err != nil {
+		return nil, fmt.Errorf("fetching managed policy: %w", err)
+	}
+	p, err := policy.ParsePolicy(document)
+	if err != nil {
+		return nil, fmt.Errorf("parsing managed policy: %w", err)
+	}
+	return p, nil
+}
+
+// loadPolicyDir loads the IAM policies defined in IaC files, including
+// attached managed policies defined outside the IaC code
+func loadPolicyDir(ctx context.Context, dir string) (*policy.IAMPolicy, error) {
+	policyProvider, err := getProvider(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(os.Stderr, "Loading IAM policies from %s: %s\n", policyProvider.Name(), dir)
+	policyResult, err := policyProvider.Parse(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("parsing IAM policies: %w", err)
+	}
+	if err := reportParseErrors(policyResult); err != nil {
+		return nil, err
+	}
+	if len(policyResult.Policies) == 0 && len(policyResult.PolicyAttachments) == 0 {
+		return nil, fmt.Errorf("no IAM policies found in %s", dir)
+	}
+	fmt.Fprintf(os.Stderr, "Found %d IAM policy documents\n", len(policyResult.Policies))
+	p := policy.FromProviderPolicies(policyResult.Policies)
+	recordGrantLocations(policyResult.Policies)
+	policyDirStatements = &sourcedStatements{}
+	for _, pol := range policyResult.Policies {
+		policyDirStatements.addProvider(pol)
+	}
+
+	attached, errs := resolvePolicyAttachments(ctx, policyResult)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if len(attached) > 0 {
+		fmt.Fprintf(os.Stderr, "Resolved %d attached managed policies\n", len(attached))
+	}
+	for _, pol := range attached {
+		p.Statement = append(p.Statement, pol.Statement...)
+		policyDirStatements.add(pol, "an attached managed policy")
+	}
+	return p, nil
+}
+
+// policyDirStatements holds the statements of the --policy-dir policies as
+// written. The checked policy combines their unconditional statements into a
+// synthetic one, which findings must not point users to.
+var policyDirStatements *sourcedStatements
+
+// sourcedStatements is a policy of statements collected from several
+// policies, labeled with the policy each was defined in
+type sourcedStatements struct {
+	policy policy.IAMPolicy
+	labels []string
+}
+
+// addProvider adds the permission statements of an IaC policy, labeled with
+// their position in it and its address and location
+func (s *sourcedStatements) addProvider(pol provider.IAMPolicy) {
+	source := pol.Address
+	if source == "" {
+		source = pol.Name
+	}
+	if loc := pol.Location.String(); loc != "" {
+		source += " (" + loc + ")"
+	}
+
+	for i, stmt := range pol.Statements {
+		if stmt.IsTrust() {
+			continue
+		}
+		s.append(policy.StatementFromProvider(stmt), i, source)
+	}
+}
+
+// add adds the statements of a policy defined outside the IaC files
+func (s *sourcedStatements) add(pol *policy.IAMPolicy, source string) {
+	for i, stmt := range pol.Statement {
+		s.append(stmt, i, source)
+	}
+}
+
+func (s *sourcedStatements) append(stmt policy.Statement, index int, source string) {
+	label := fmt.Sprintf("statement #%d", index+1)
+	if stmt.Sid != "" {
+		label = fmt.Sprintf("statement %q", stmt.Sid)
+	}
+	s.policy.Statement = append(s.policy.Statement, stmt)
+	s.labels = append(s.labels, label+" of "+source)
+}
+
+// findBroadGrants reports the dangerously broad grants of the existing
+// policy. With --policy-dir, the statements are checked as written rather
+// than as combined, which would make every grant look unscoped.
+func findBroadGrants(existing *policy.IAMPolicy) []risk.BroadGrant {
+	if policyDirStatements == nil {
+		return risk.FindBroadGrants(existing)
+	}
+
+	grants := risk.FindBroadGrants(&policyDirStatements.policy)
+	for i := range grants {
+		grants[i].Statement = policyDirStatements.labels[grants[i].Index]
+	}
+	return grants
+}
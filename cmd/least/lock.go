@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/lock"
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock [path]",
+	Short: "Write the permissions the IaC requires to a lockfile",
+	Long: `Write a normalized snapshot of the permissions the IaC files in path require
+(each action and the resources it is required on) to least.lock.
+
+Commit the lockfile next to the IaC: permission growth then shows up as a
+change to it in review, and least check --lock fails when the IaC requires
+permissions beyond it until least lock is run again.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runLock,
+}
+
+var (
+	lockOutput string
+	// lockFile is the lockfile check --lock compares with
+	lockFile string
+)
+
+func init() {
+	rootCmd.AddCommand(lockCmd)
+
+	lockCmd.Flags().StringVarP(&lockOutput, "output", "o", lock.DefaultFile, "Lockfile to write")
+
+	checkCmd.Flags().StringVar(&lockFile, "lock", "", "Instead of checking a policy, fail when the IaC requires permissions beyond the lockfile written by least lock (--lock=FILE for another file than "+lock.DefaultFile+")")
+	checkCmd.Flags().Lookup("lock").NoOptDefVal = lock.DefaultFile
+}
+
+func runLock(cmd *cobra.Command, args []string) error {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	required, err := generateFromPath(context.Background(), path)
+	if err != nil {
+		return err
+	}
+
+	l := lock.FromPolicy(required)
+	if previous, err := lock.Load(lockOutput); err == nil {
+		for _, g := range previous.Growth(l) {
+			fmt.Fprintf(os.Stderr, "  + %s%s\n", g.Action, describeGrowth(g))
+		}
+		for _, action := range previous.Unused(l) {
+			fmt.Fprintf(os.Stderr, "  - %s\n", action)
+		}
+	}
+	if err := l.Save(lockOutput); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Locked %s: %s\n", plural(len(l.Permissions), "action"), lockOutput)
+	return nil
+}
+
+// runLockCheck fails with the missing exit code when the IaC in path
+// requires permissions beyond the lockfile
+func runLockCheck(ctx context.Context, path string) error {
+	locked, err := lock.Load(lockFile)
+	if err != nil {
+		return err
+	}
+	required, err := generateFromPath(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	current := lock.FromPolicy(required)
+	growth := locked.Growth(current)
+	if unused := locked.Unused(current); len(unused) > 0 {
+		fmt.Fprintf(os.Stderr, "Note: %s in %s no longer required; run least lock to remove them\n", plural(len(unused), "action"), lockFile)
+	}
+	if len(growth) == 0 {
+		fmt.Printf("✓ Required permissions are within %s\n", lockFile)
+		return nil
+	}
+
+	fmt.Printf("✗ The IaC requires %s beyond %s:\n", plural(len(growth), "permission"), lockFile)
+	for _, g := range growth {
+		fmt.Printf("    + %s%s%s\n", g.Action, describeGrowth(g), describeSources(required.SourcesOf(g.Action)))
+	}
+	fmt.Println()
+	fmt.Println("Review the growth and run least lock to approve it")
+	os.Exit(missingExit)
+	return nil
+}
+
+// describeGrowth describes the resources of an action beyond the lockfile
+func describeGrowth(g lock.Growth) string {
+	if g.New {
+		return ""
+	}
+	return " on " + strings.Join(g.Resources, ", ")
+}
@@ -3,14 +3,30 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/mizzy/least/internal/accessadvisor"
+	"github.com/mizzy/least/internal/accessanalyzer"
+	"github.com/mizzy/least/internal/baseline"
 	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/config"
+	"github.com/mizzy/least/internal/diff"
 	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/prcomment"
 	"github.com/mizzy/least/internal/provider"
+	"github.com/mizzy/least/internal/provider/cloudformation"
 	"github.com/mizzy/least/internal/provider/terraform"
+	"github.com/mizzy/least/internal/risk"
+	"github.com/mizzy/least/internal/target"
 )
 
 var version = "dev"
@@ -22,8 +38,8 @@ func init() {
 	// Initialize provider registry
 	registry = provider.NewRegistry()
 	registry.Register(terraform.New())
+	registry.Register(cloudformation.New())
 	// Future providers can be registered here:
-	// registry.Register(cloudformation.New())
 	// registry.Register(pulumi.New())
 }
 
@@ -35,17 +51,17 @@ func main() {
 }
 
 var rootCmd = &cobra.Command{
-	Use:     "least",
-	Short:   "Generate least-privilege IAM policies from IaC code",
-	Long:    `least analyzes Infrastructure-as-Code configurations and generates minimal IAM policies required to manage the defined resources.`,
-	Version: version,
+	Use:               "least",
+	Short:             "Generate least-privilege IAM policies from IaC code",
+	Long:              `least analyzes Infrastructure-as-Code configurations and generates minimal IAM policies required to manage the defined resources.`,
+	Version:           version,
+	PersistentPreRunE: setup,
 }
 
 var generateCmd = &cobra.Command{
-	Use:   "generate [path]",
+	Use:   "generate [path]...",
 	Short: "Generate IAM policy from IaC files",
-	Long:  `Analyze IaC files and generate a minimal IAM policy JSON.`,
-	Args:  cobra.MaximumNArgs(1),
+	Long:  `Analyze IaC files and generate a minimal IAM policy JSON. Resources from several paths, or from every Terraform root found below them with --recursive, are merged into one policy, or written to one policy per path with --split-by path. Pass "-" as the path to read a single HCL document from stdin.`,
 	RunE:  runGenerate,
 }
 
@@ -58,35 +74,152 @@ var checkCmd = &cobra.Command{
 }
 
 var (
-	outputFile   string
-	policyFile   string
-	policyDir    string
-	format       string
-	providerName string
+	outputFile        string
+	policyFile        string
+	policyDir         string
+	policyARN         string
+	boundaryFile      string
+	boundaryARN       string
+	showDiff          bool
+	fixPolicy         bool
+	fixOutput         string
+	baselineFile      string
+	failOn            string
+	missingExit       int
+	excessExit        int
+	broadExit         int
+	format            string
+	providerName      string
+	mappingOverlays   []string
+	validate          bool
+	noNewAccess       string
+	watch             bool
+	splitBy           string
+	recursive         bool
+	strict            bool
+	noWildcards       bool
+	failOnParseErrors bool
+	clouds            []string
+	targets           []string
+	minCoverage       float64
+	suggestManaged    bool
+	withMetadata      bool
+	outputMeta        string
+	templateFile      string
+	regoPackage       string
+	permissionSetName string
+	checkFormat       string
+	postPRComment     bool
+	changedOnly       bool
+	notifyWebhook     string
+	reportURL         string
+
+	// outputTemplate is the parsed --template
+	outputTemplate *template.Template
+
+	roleARN           string
+	cloudtrailArchive string
+	cloudtrailLake    string
+	usageWindow       string
+	lastAccessed      bool
 )
 
+// regoPackagePattern matches a Rego package path (e.g., iam.requirements)
+var regoPackagePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
 func init() {
 	rootCmd.AddCommand(generateCmd)
 	rootCmd.AddCommand(checkCmd)
 
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&providerName, "provider", "", "IaC provider (auto-detected if not specified)")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", config.DefaultFile, "Configuration file with project defaults")
+	rootCmd.PersistentFlags().StringSliceVar(&includePatterns, "include", nil, "Only analyze files matching these path patterns (e.g., 'stacks/**')")
+	rootCmd.PersistentFlags().StringSliceVar(&excludePatterns, "exclude", nil, "Skip files matching these path patterns (e.g., 'examples/**', '.terraform/**')")
+	rootCmd.PersistentFlags().BoolVar(&useGitIgnore, "gitignore", false, "Also skip files matched by .gitignore (.terraformignore is always honored)")
+	rootCmd.PersistentFlags().StringSliceVar(&mappingOverlays, "mappings", nil, "Mapping overlay files that add or override resource mappings and ARN patterns")
 
 	generateCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file (default: stdout)")
-	generateCmd.Flags().StringVarP(&format, "format", "f", "terraform", "Output format: terraform (or tf), json")
+	generateCmd.Flags().StringVarP(&format, "format", "f", "terraform", "Output format: terraform (or tf), json, rego (conftest policy requiring the actions of an input policy document), session (compact JSON within the 2048-character inline session policy limit), permission-set (IAM Identity Center permission set with the policy inline), stackset (CloudFormation template of the AWSCloudFormationStackSetExecutionRole), roles-anywhere (Terraform role and IAM Roles Anywhere profile)")
+	generateCmd.Flags().StringVar(&permissionSetName, "permission-set-name", "LeastPrivilege", "Name of the --format permission-set permission set")
+	generateCmd.Flags().BoolVarP(&watch, "watch", "w", false, "Watch for IaC file changes and regenerate the output file")
+	generateCmd.Flags().StringVar(&splitBy, "split-by", "", "Write one policy per path instead of merging them: path (--output is written into each path, or named with {name} and {path})")
+	generateCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Discover Terraform roots (directories with a backend or provider block) below each path")
+	generateCmd.Flags().StringSliceVar(&clouds, "cloud", []string{"aws"}, "Clouds to generate policies for: aws (IAM policy), gcp (custom role); with several, --output must contain {cloud}")
+	generateCmd.Flags().StringArrayVar(&targets, "target", nil, "Only grant what a targeted apply of this resource or module address needs, including its dependencies (repeatable, e.g., module.app.aws_s3_bucket.logs)")
+	generateCmd.Flags().StringArrayVar(&envFlags, "env", nil, "Generate one policy per environment from its Terraform var-file (name=varfile, repeatable); with several, --output must contain {env}")
+	generateCmd.Flags().StringVar(&regoPackage, "rego-package", "main", "Package of the --format rego module (conftest reads package main by default)")
+	generateCmd.Flags().BoolVar(&withMetadata, "metadata", false, "With --format json, wrap the policy in an object that also records where each action's mapping comes from")
+	generateCmd.Flags().StringVar(&templateFile, "template", "", "Render the policy with this Go text/template file instead of the format, whose ARN style (Terraform references or JSON) it keeps")
+	generateCmd.Flags().StringVar(&outputMeta, "output-meta", "", "Also write the metadata of the policy to this JSON file: the resources (type, name, file, line) and mapping provenance behind each action, and the statements each resource requires")
+	generateCmd.Flags().BoolVar(&strict, "strict", false, "Fail instead of generating an incomplete policy when AWS resources have no permission mapping")
+	generateCmd.Flags().BoolVar(&noWildcards, "no-wildcard-resources", false, "Fail instead of generating a policy with statements on Resource \"*\", listing the resource types whose ARNs could not be constructed")
+	generateCmd.Flags().BoolVar(&failOnParseErrors, "fail-on-parse-errors", false, "Fail when files or modules cannot be parsed instead of generating a policy without their resources")
+	generateCmd.Flags().BoolVar(&denyUnused, "deny-unused", false, "Generate explicit Deny statements for the sensitive actions and services the IaC does not require, to layer on a broader existing role")
+	generateCmd.Flags().BoolVar(&resourcePolicies, "resource-policies", false, "Append the bucket policies, Lambda permissions and queue and topic policies that cross-service access in the IaC needs (e.g., CloudFront to an S3 origin), VPC endpoint policies limited to the resources of the configuration, ECR repository policies with a build role policy pushing to them, and the policies of the roles schedules, pipes and EventBridge targets deliver with")
+	generateCmd.Flags().BoolVar(&ecsExecutionRole, "ecs-execution-role", false, "Generate the task execution role policies of the ECS task definitions (ECR pulls, CloudWatch Logs, container secrets and environment files) instead of the deployer policy")
+	generateCmd.Flags().StringVar(&kmsKeyPolicyPrincipal, "kms-key-policy", "", "ARN of the deploying role to print a key policy statement for, when resources encrypt with customer-managed KMS keys")
+	generateCmd.Flags().BoolVar(&suggestManaged, "suggest-managed", false, "Report the AWS managed policies, alone or combined, closest to the generated policy")
+	generateCmd.Flags().BoolVar(&validate, "validate", false, "Validate the generated policy with IAM Access Analyzer")
+	generateCmd.Flags().StringVar(&noNewAccess, "check-no-new-access", "", "Reference policy JSON file the generated policy must not exceed (Access Analyzer)")
 
-	checkCmd.Flags().StringVarP(&policyFile, "policy", "p", "", "Existing IAM policy JSON file")
+	checkCmd.Flags().StringVarP(&policyFile, "policy", "p", "", "Existing IAM policy JSON file (- for stdin)")
 	checkCmd.Flags().StringVarP(&policyDir, "policy-dir", "d", "", "Directory with IaC IAM policy definitions")
+	checkCmd.Flags().StringVar(&policyARN, "policy-arn", "", "ARN of a managed IAM policy to fetch from AWS")
+	checkCmd.Flags().StringVar(&boundaryFile, "boundary", "", "Permissions boundary JSON file of the role; only actions it also allows are granted")
+	checkCmd.Flags().StringVar(&boundaryARN, "boundary-arn", "", "ARN of the managed policy used as the role's permissions boundary, fetched from AWS")
+	checkCmd.Flags().BoolVar(&showDiff, "diff", false, "Print a unified diff of the policy changes that resolve the findings")
+	checkCmd.Flags().BoolVar(&fixPolicy, "fix", false, "Write a corrected policy with excessive actions removed and missing ones added")
+	checkCmd.Flags().StringVarP(&fixOutput, "output", "o", "", "Output file for --fix (default: stdout, with the report on stderr)")
+	checkCmd.Flags().StringVar(&checkFormat, "format", "text", "Output format: text, github (GitHub Actions annotations on the resources and policy lines)")
+	checkCmd.Flags().BoolVar(&postPRComment, "comment", false, "Post or update the check report as a comment on the GitHub pull request or GitLab merge request of the CI run")
+	checkCmd.Flags().BoolVar(&changedOnly, "changed-only", false, "Only check resources affected by uncommitted changes (staged, unstaged and untracked files, for pre-commit hooks); excessive permissions are not reported")
+	checkCmd.Flags().StringVar(&notifyWebhook, "notify-webhook", "", "Webhook URL to post a summary to when the check fails (Slack-compatible payload with the findings as JSON)")
+	checkCmd.Flags().StringVar(&reportURL, "report-url", "", "Link to the full report in --notify-webhook messages (default: the CI job URL, when detected)")
+	checkCmd.Flags().BoolVar(&failOnParseErrors, "fail-on-parse-errors", false, "Fail when files or modules cannot be parsed instead of checking without their resources and policies")
+	checkCmd.Flags().StringVar(&baselineFile, "baseline", baseline.DefaultFile, "Baseline file with accepted findings")
+	checkCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Walk through the findings, accepting each into the baseline, queueing it for a fixed policy written like --fix, or skipping it")
+	checkCmd.Flags().StringVar(&failOn, "fail-on", "missing,excessive", "Comma-separated findings that cause a non-zero exit: missing, excessive, broad, any, none")
+	checkCmd.Flags().Float64Var(&minCoverage, "min-coverage", 0, "Fail with the missing exit code when less than this percentage of required actions is granted; missing permissions above it do not fail")
+	checkCmd.Flags().IntVar(&missingExit, "missing-exit-code", 1, "Exit code when missing permissions cause a failure")
+	checkCmd.Flags().IntVar(&excessExit, "excessive-exit-code", 2, "Exit code when only excessive permissions cause a failure")
+	checkCmd.Flags().IntVar(&broadExit, "broad-exit-code", 3, "Exit code when only dangerously broad grants cause a failure")
+	checkCmd.Flags().StringVar(&roleARN, "role-arn", "", "ARN of the IAM role that uses the policy")
+	checkCmd.Flags().StringVar(&cloudtrailArchive, "cloudtrail-archive", "", "CloudTrail log archive (directory or s3:// prefix) to find unused permissions of --role-arn")
+	checkCmd.Flags().StringVar(&cloudtrailLake, "cloudtrail-lake", "", "CloudTrail Lake event data store to find unused permissions of --role-arn")
+	checkCmd.Flags().StringVar(&usageWindow, "usage-window", "90d", "Time window for CloudTrail usage (e.g., 30d, 720h)")
+	checkCmd.Flags().BoolVar(&lastAccessed, "last-accessed", false, "Show IAM Access Advisor last-used data for --role-arn and prioritize excessive permissions")
+	checkCmd.MarkFlagsMutuallyExclusive("policy", "policy-dir", "policy-arn")
+	checkCmd.MarkFlagsMutuallyExclusive("cloudtrail-archive", "cloudtrail-lake")
+	checkCmd.MarkFlagsMutuallyExclusive("boundary", "boundary-arn")
+}
+
+// setup loads the configuration file and local mappings before any command runs
+func setup(cmd *cobra.Command, args []string) error {
+	if err := loadConfig(cmd, args); err != nil {
+		return err
+	}
+	if err := setupSchemas(); err != nil {
+		return err
+	}
+	if err := setupResolvers(); err != nil {
+		return err
+	}
+	return loadCustomMappings(cmd)
 }
 
 // getProvider returns the appropriate provider for the given path
 func getProvider(path string) (provider.Provider, error) {
+	if path == stdinPath {
+		return getStdinProvider()
+	}
+
 	if providerName != "" {
 		p := registry.Get(providerName)
 		if p == nil {
 			return nil, fmt.Errorf("unknown provider: %s", providerName)
 		}
-		return p, nil
+		return withExclusions(withVariables(withCache(p))), nil
 	}
 
 	// Auto-detect provider
@@ -107,30 +240,227 @@ func getProvider(path string) (provider.Provider, error) {
 		fmt.Fprintf(os.Stderr, "Multiple providers detected: %v, using %s\n", names, providers[0].Name())
 	}
 
-	return providers[0], nil
+	return withExclusions(withVariables(withCache(providers[0]))), nil
 }
 
 func runGenerate(cmd *cobra.Command, args []string) error {
-	path := "."
-	if len(args) > 0 {
-		path = args[0]
+	paths := args
+	if len(paths) == 0 {
+		paths = []string{"."}
 	}
 
-	p, err := getProvider(path)
-	if err != nil {
+	if splitBy != "" && splitBy != "path" {
+		return fmt.Errorf("unsupported --split-by: %s (use 'path')", splitBy)
+	}
+	if splitBy != "" && outputFile == "" {
+		return fmt.Errorf("--split-by requires --output, the file name written into each path")
+	}
+	if withMetadata && format != "json" {
+		return fmt.Errorf("--metadata requires --format json")
+	}
+	if resourcePolicies && format != "terraform" && format != "tf" {
+		return fmt.Errorf("--resource-policies requires --format terraform")
+	}
+	if ecsExecutionRole && (denyUnused || resourcePolicies || withMetadata || outputMeta != "") {
+		return fmt.Errorf("--ecs-execution-role cannot be combined with --deny-unused, --resource-policies, --metadata or --output-meta")
+	}
+	if denyUnused && (withMetadata || outputMeta != "" || suggestManaged || validate || noNewAccess != "") {
+		return fmt.Errorf("--deny-unused cannot be combined with --metadata, --output-meta, --suggest-managed, --validate or --check-no-new-access")
+	}
+	if outputMeta != "" && splitBy != "" {
+		return fmt.Errorf("--output-meta cannot be combined with --split-by")
+	}
+	// These formats replace resources they cannot resolve with "*" while rendering
+	if noWildcards && slices.Contains([]string{"session", "permission-set", "stackset", "roles-anywhere"}, format) {
+		return fmt.Errorf("--no-wildcard-resources cannot be combined with --format %s, which widens resources to \"*\"", format)
+	}
+	if err := loadTemplate(); err != nil {
 		return err
 	}
+	if format == "rego" && !regoPackagePattern.MatchString(regoPackage) {
+		return fmt.Errorf("invalid --rego-package: %s", regoPackage)
+	}
+	if err := validateClouds(); err != nil {
+		return err
+	}
+	if len(paths) > 1 && slices.Contains(paths, stdinPath) {
+		return fmt.Errorf("input from stdin cannot be combined with other paths")
+	}
+	if err := loadEnvironments(paths); err != nil {
+		return err
+	}
+	for i, address := range targets {
+		normalized, err := target.Normalize(address)
+		if err != nil {
+			return fmt.Errorf("invalid --target: %w", err)
+		}
+		targets[i] = normalized
+	}
 
-	fmt.Fprintf(os.Stderr, "Using provider: %s\n", p.Name())
-	fmt.Fprintf(os.Stderr, "Analyzing files in: %s\n", path)
+	if recursive {
+		if paths[0] == stdinPath {
+			return fmt.Errorf("--recursive cannot be used with input from stdin")
+		}
+		roots, err := findRoots(paths)
+		if err != nil {
+			return err
+		}
+		paths = roots
+	}
 
+	if watch {
+		if paths[0] == stdinPath {
+			return fmt.Errorf("--watch cannot be used with input from stdin")
+		}
+		if outputFile == "" {
+			return fmt.Errorf("--watch requires --output")
+		}
+		return watchAndGenerate(paths)
+	}
+
+	return generatePolicies(paths)
+}
+
+// generatePolicies generates the policies of paths, once per environment
+// with --env
+func generatePolicies(paths []string) error {
+	if len(environments) > 0 {
+		return generateEnvironments(paths)
+	}
+	return generatePathPolicies(paths)
+}
+
+// generatePathPolicies generates one policy for all paths, or one policy per
+// path with --split-by path
+func generatePathPolicies(paths []string) error {
+	if splitBy == "" {
+		return generatePolicy(paths, outputFile)
+	}
+
+	for _, path := range paths {
+		if err := generatePolicy([]string{path}, splitOutput(path)); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// findRoots returns the Terraform root modules below each of paths
+func findRoots(paths []string) ([]string, error) {
+	var roots []string
+	for _, path := range paths {
+		filter, err := newPathFilter(path)
+		if err != nil {
+			return nil, err
+		}
+		found, err := terraform.FindRoots(path, func(dir string, _ bool) bool {
+			return filter.skip(dir, true)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("discovering stacks: %w", err)
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("no Terraform roots (directories with a backend or provider block) found under %s", path)
+		}
+		fmt.Fprintf(os.Stderr, "Found %s under %s\n", plural(len(found), "stack"), path)
+		roots = append(roots, found...)
+	}
+	return roots, nil
+}
+
+// splitOutput returns the output file for path with --split-by. The {name}
+// and {path} placeholders in --output are replaced with the directory name
+// and its path joined with "-"; without them, --output is written into the
+// path's directory.
+func splitOutput(path string) string {
+	dir := path
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+
+	if !strings.Contains(outputFile, "{name}") && !strings.Contains(outputFile, "{path}") {
+		return filepath.Join(dir, outputFile)
+	}
+
+	dir = filepath.Clean(dir)
+	name := filepath.Base(dir)
+	if abs, err := filepath.Abs(dir); err == nil {
+		name = filepath.Base(abs)
+	}
+	slug := strings.ReplaceAll(filepath.ToSlash(dir), "/", "-")
+	if slug == "." || strings.HasPrefix(slug, "..") {
+		slug = name
+	}
+	return strings.NewReplacer("{name}", name, "{path}", slug).Replace(outputFile)
+}
+
+// parsePaths parses the IaC files in each path and merges the results
+func parsePaths(ctx context.Context, paths []string) (*provider.ParseResult, error) {
+	result := &provider.ParseResult{}
+	for _, path := range paths {
+		p, err := getProvider(path)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(os.Stderr, "Using provider: %s\n", p.Name())
+		fmt.Fprintf(os.Stderr, "Analyzing files in: %s\n", path)
+
+		r, err := p.Parse(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("parsing files in %s: %w", path, err)
+		}
+		result.Merge(r)
+	}
+	return result, nil
+}
+
+// generatePolicy generates the policy of each --cloud for the IaC files in
+// paths and writes it to output or stdout
+func generatePolicy(paths []string, output string) error {
 	ctx := context.Background()
-	result, err := p.Parse(ctx, path)
+	result, err := parsePaths(ctx, paths)
 	if err != nil {
-		return fmt.Errorf("parsing files: %w", err)
+		return err
 	}
 
 	fmt.Fprintf(os.Stderr, "Found %d resources\n", len(result.Resources))
+	if err := reportParseErrors(result); err != nil {
+		return err
+	}
+	selectMappings(result)
+	if len(targets) > 0 {
+		if result.Resources, err = target.Select(result.Resources, targets); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%s targeted, including dependencies\n", plural(len(result.Resources), "resource"))
+	}
+
+	for _, cloud := range clouds {
+		out := strings.ReplaceAll(envOutput(output), "{cloud}", cloud)
+		switch cloud {
+		case "aws":
+			err = generateAWSPolicy(ctx, result, out)
+		case "gcp":
+			err = generateGCPRole(result, out)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateAWSPolicy generates the IAM policy of the AWS resources and writes
+// it to output or stdout
+func generateAWSPolicy(ctx context.Context, result *provider.ParseResult, output string) error {
+	if strict {
+		if err := failUnmapped(result.Resources); err != nil {
+			return err
+		}
+	} else {
+		warnUnmapped(result.Resources)
+	}
 
 	// Determine account and region references
 	accountRef := result.AccountRef
@@ -149,45 +479,252 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		needRegion = !result.HasRegionData
 	}
 
+	// Formats written as Terraform HCL build ARNs from Terraform references
+	outputFormat := format
+	if format == "tf" || format == "roles-anywhere" {
+		outputFormat = "terraform"
+	}
+
 	// Create generator with options
 	gen := policy.NewWithOptions(policy.GeneratorOptions{
-		OutputFormat:       format,
+		OutputFormat:       outputFormat,
 		AccountRef:         accountRef,
 		RegionRef:          regionRef,
 		NeedCallerIdentity: needCallerIdentity,
 		NeedRegion:         needRegion,
 	})
 
+	if ecsExecutionRole {
+		return writeExecutionRolePolicies(gen, result.Resources, output, policy.TerraformOutputOptions{
+			NeedCallerIdentity: needCallerIdentity,
+			NeedRegion:         needRegion,
+		})
+	}
+
 	iamPolicy, err := gen.Generate(result.Resources)
 	if err != nil {
 		return fmt.Errorf("generating policy: %w", err)
 	}
+	iamPolicy.AddRequirements(result.Requirements)
+	if denyUnused {
+		return writeDenyPolicy(iamPolicy, output)
+	}
+	if err := lintGenerated(iamPolicy); err != nil {
+		return err
+	}
+	if err := failWildcardResources(iamPolicy); err != nil {
+		return err
+	}
+	if err := warnKMSKeys(iamPolicy); err != nil {
+		return err
+	}
 
-	var output string
-	switch format {
+	// A template replaces the rendering of the format, whose ARNs it keeps
+	renderAs := format
+	if outputTemplate != nil {
+		renderAs = "template"
+	}
+
+	var rendered string
+	switch renderAs {
+	case "template":
+		if rendered, err = iamPolicy.ExecuteTemplate(outputTemplate); err != nil {
+			return err
+		}
 	case "json":
-		output, err = iamPolicy.ToJSON()
+		if withMetadata {
+			rendered, err = iamPolicy.ToJSONWithMetadata()
+		} else {
+			rendered, err = iamPolicy.ToJSON()
+		}
 		if err != nil {
 			return fmt.Errorf("converting policy to JSON: %w", err)
 		}
 	case "terraform", "tf":
-		output = iamPolicy.ToTerraformWithOptions(policy.TerraformOutputOptions{
+		rendered = iamPolicy.ToTerraformWithOptions(policy.TerraformOutputOptions{
 			NeedCallerIdentity: needCallerIdentity,
 			NeedRegion:         needRegion,
 		})
+		if resourcePolicies {
+			rendered = withResourcePolicies(rendered, result.Resources, iamPolicy)
+		}
+	case "rego":
+		if rendered, err = iamPolicy.ToRego(regoPackage); err != nil {
+			return fmt.Errorf("converting policy to Rego: %w", err)
+		}
+	case "session":
+		var reductions []string
+		rendered, reductions, err = iamPolicy.ToSessionPolicy()
+		for _, reduction := range reductions {
+			fmt.Fprintf(os.Stderr, "Warning: to fit the %d-character session policy limit, %s\n", policy.SessionPolicyLimit, reduction)
+		}
+		if err != nil {
+			return err
+		}
+	case "permission-set":
+		var widened int
+		if rendered, widened, err = iamPolicy.ToPermissionSet(policy.PermissionSetOptions{Name: permissionSetName}); err != nil {
+			return err
+		}
+		if widened > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: Terraform references in %s replaced with \"*\", since a permission set applies to every account it is provisioned into\n", plural(widened, "resource"))
+		}
+	case "stackset":
+		var widened int
+		if rendered, widened, err = iamPolicy.ToStackSetExecutionRole(); err != nil {
+			return err
+		}
+		if widened > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: Terraform references in %s replaced with \"*\", since a stack set deploys into many accounts\n", plural(widened, "resource"))
+		}
+	case "roles-anywhere":
+		var reductions []string
+		rendered, reductions, err = iamPolicy.ToRolesAnywhere(policy.TerraformOutputOptions{
+			NeedCallerIdentity: needCallerIdentity,
+			NeedRegion:         needRegion,
+		})
+		for _, reduction := range reductions {
+			fmt.Fprintf(os.Stderr, "Warning: to fit the %d-character session policy limit of the profile, %s\n", policy.SessionPolicyLimit, reduction)
+		}
+		if err != nil {
+			return err
+		}
 	default:
-		return fmt.Errorf("unsupported format: %s (use 'json' or 'terraform')", format)
+		return fmt.Errorf("unsupported format: %s (use 'json', 'terraform', 'rego', 'session', 'permission-set', 'stackset' or 'roles-anywhere')", format)
 	}
 
-	if outputFile != "" {
-		if err := os.WriteFile(outputFile, []byte(output), 0644); err != nil {
-			return fmt.Errorf("writing output file: %w", err)
+	if err := writeOutput(output, rendered); err != nil {
+		return err
+	}
+	if outputMeta != "" {
+		if err := writeMetadata(envOutput(outputMeta), iamPolicy); err != nil {
+			return err
+		}
+	}
+
+	if suggestManaged {
+		suggestManagedPolicies(iamPolicy)
+	}
+
+	if validate || noNewAccess != "" {
+		if err := validateWithAccessAnalyzer(ctx, iamPolicy); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeOutput writes a generated policy to output, or stdout when it is empty
+func writeOutput(output, rendered string) error {
+	if output == "" {
+		fmt.Println(rendered)
+		return nil
+	}
+
+	if dir := filepath.Dir(output); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating output directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(output, []byte(rendered), 0644); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Policy written to: %s\n", output)
+	return nil
+}
+
+// loadTemplate parses the --template file
+func loadTemplate() error {
+	if templateFile == "" {
+		return nil
+	}
+	if format != "terraform" && format != "tf" && format != "json" {
+		return fmt.Errorf("--template requires --format terraform or json")
+	}
+	if withMetadata || resourcePolicies || denyUnused || ecsExecutionRole || slices.Contains(clouds, "gcp") {
+		return fmt.Errorf("--template cannot be combined with --metadata, --resource-policies, --deny-unused, --ecs-execution-role or --cloud gcp")
+	}
+	data, err := os.ReadFile(templateFile)
+	if err != nil {
+		return fmt.Errorf("reading template: %w", err)
+	}
+	outputTemplate, err = policy.ParseTemplate(filepath.Base(templateFile), string(data))
+	return err
+}
+
+// writeMetadata writes the metadata of a generated policy to output
+func writeMetadata(output string, iamPolicy *policy.IAMPolicy) error {
+	rendered, err := iamPolicy.MetadataJSON()
+	if err != nil {
+		return fmt.Errorf("converting metadata to JSON: %w", err)
+	}
+	if dir := filepath.Dir(output); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating metadata directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(output, []byte(rendered+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing metadata file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Metadata written to: %s\n", output)
+	return nil
+}
+
+// validateWithAccessAnalyzer reports Access Analyzer findings for the generated policy.
+// Errors and security warnings fail the command.
+func validateWithAccessAnalyzer(ctx context.Context, iamPolicy *policy.IAMPolicy) error {
+	document, err := iamPolicy.ToJSON()
+	if err != nil {
+		return fmt.Errorf("converting policy to JSON: %w", err)
+	}
+
+	validator, err := accessanalyzer.New(ctx)
+	if err != nil {
+		return err
+	}
+
+	failed := false
+
+	if validate {
+		findings, err := validator.ValidatePolicy(ctx, document)
+		if err != nil {
+			return err
+		}
+		if len(findings) == 0 {
+			fmt.Fprintln(os.Stderr, "✓ Access Analyzer reported no findings")
+		}
+		for _, f := range findings {
+			fmt.Fprintf(os.Stderr, "%s\n", f)
+			if f.Blocking() {
+				failed = true
+			}
 		}
-		fmt.Fprintf(os.Stderr, "Policy written to: %s\n", outputFile)
-	} else {
-		fmt.Println(output)
 	}
 
+	if noNewAccess != "" {
+		reference, err := os.ReadFile(noNewAccess)
+		if err != nil {
+			return fmt.Errorf("reading reference policy: %w", err)
+		}
+		result, err := validator.CheckNoNewAccess(ctx, document, string(reference))
+		if err != nil {
+			return err
+		}
+		if result.Pass {
+			fmt.Fprintf(os.Stderr, "✓ No new access compared to %s\n", noNewAccess)
+		} else {
+			failed = true
+			fmt.Fprintf(os.Stderr, "✗ %s\n", result.Message)
+			for _, r := range result.Reasons {
+				fmt.Fprintf(os.Stderr, "  - %s\n", r)
+			}
+		}
+	}
+
+	if failed {
+		return fmt.Errorf("access analyzer validation failed")
+	}
 	return nil
 }
 
@@ -197,96 +734,452 @@ func runCheck(cmd *cobra.Command, args []string) error {
 		path = args[0]
 	}
 
-	if policyFile == "" && policyDir == "" {
-		return fmt.Errorf("either --policy or --policy-dir must be specified")
+	if evidenceKey != "" && evidenceFile == "" {
+		return fmt.Errorf("--evidence-key requires --evidence")
+	}
+	if lockFile != "" {
+		if policyFile != "" || policyDir != "" || policyARN != "" {
+			return fmt.Errorf("--lock cannot be combined with --policy, --policy-dir or --policy-arn")
+		}
+		if evidenceFile != "" {
+			return fmt.Errorf("--evidence cannot be combined with --lock")
+		}
+		return runLockCheck(context.Background(), path)
+	}
+	if policyFile == "" && policyDir == "" && policyARN == "" {
+		return fmt.Errorf("one of --policy, --policy-dir, or --policy-arn must be specified")
+	}
+	if path == stdinPath && policyFile == stdinPath {
+		return fmt.Errorf("IaC files and --policy cannot both be read from stdin")
+	}
+	if boundaryFile == stdinPath && (path == stdinPath || policyFile == stdinPath) {
+		return fmt.Errorf("--boundary cannot be read from stdin with other input")
 	}
 
-	p, err := getProvider(path)
+	failClasses, err := parseFailOn(failOn)
 	if err != nil {
 		return err
 	}
+	if minCoverage < 0 || minCoverage > 100 {
+		return fmt.Errorf("--min-coverage must be between 0 and 100")
+	}
+	if checkFormat != "text" && checkFormat != "github" {
+		return fmt.Errorf("unsupported --format: %s (use text or github)", checkFormat)
+	}
+	if interactive && (path == stdinPath || policyFile == stdinPath || boundaryFile == stdinPath) {
+		return fmt.Errorf("--interactive reads answers from stdin, so no input can be read from it")
+	}
+	if interactive && checkFormat != "text" {
+		return fmt.Errorf("--interactive requires --format text")
+	}
 
-	fmt.Fprintf(os.Stderr, "Using provider: %s\n", p.Name())
+	checkUsage := cloudtrailArchive != "" || cloudtrailLake != ""
+	if checkUsage && roleARN == "" {
+		return fmt.Errorf("--role-arn is required with --cloudtrail-archive or --cloudtrail-lake")
+	}
+	if lastAccessed && roleARN == "" {
+		return fmt.Errorf("--role-arn is required with --last-accessed")
+	}
+
+	// Evidence covers the whole policy, not only the changed resources
+	if evidenceFile != "" && changedOnly {
+		return fmt.Errorf("--evidence cannot be combined with --changed-only")
+	}
 
-	// Parse IaC files for required permissions
 	ctx := context.Background()
-	result, err := p.Parse(ctx, path)
+	if changedOnly {
+		affected, err := resolveChanged(ctx, path)
+		if err != nil {
+			return err
+		}
+		if !affected {
+			fmt.Println("✓ No changed IaC files to check")
+			return nil
+		}
+	}
+
+	// Generate required policy from IaC files
+	requiredPolicy, err := generateFromPath(ctx, path)
 	if err != nil {
-		return fmt.Errorf("parsing files: %w", err)
+		return err
 	}
 
-	fmt.Fprintf(os.Stderr, "Found %d resources in: %s\n", len(result.Resources), path)
+	// Load existing policy from a JSON file, an IaC directory, or AWS
+	var existingPolicy *policy.IAMPolicy
+	policySource := policyFile
 
-	// Generate required policy
-	gen := policy.New()
-	requiredPolicy, err := gen.Generate(result.Resources)
+	switch {
+	case policyARN != "":
+		policySource = policyARN
+		existingPolicy, err = loadPolicyARN(ctx, policyARN)
+	case policyDir != "":
+		policySource = policyDir
+		existingPolicy, err = loadPolicyDir(ctx, policyDir)
+	default:
+		existingPolicy, err = loadPolicyFile(policyFile)
+	}
 	if err != nil {
-		return fmt.Errorf("generating required policy: %w", err)
+		return err
 	}
 
-	// Load existing policy from either JSON file or IaC directory
-	var existingPolicy *policy.IAMPolicy
+	// Load the permissions boundary limiting what the policy grants
+	var boundaryPolicy *policy.IAMPolicy
+	switch {
+	case boundaryARN != "":
+		boundaryPolicy, err = loadPolicyARN(ctx, boundaryARN)
+	case boundaryFile != "":
+		boundaryPolicy, err = loadPolicyFile(boundaryFile)
+	}
+	if err != nil {
+		return err
+	}
 
-	if policyDir != "" {
-		policyProvider, err := getProvider(policyDir)
-		if err != nil {
+	// Check the effective permissions
+	checkResult := checker.CheckWithBoundary(existingPolicy, boundaryPolicy, requiredPolicy)
+
+	// Auditors see findings accepted in the baseline too
+	if evidenceFile != "" {
+		if err := writeEvidence(path, policySource, existingPolicy, requiredPolicy, checkResult); err != nil {
 			return err
 		}
+	}
 
-		fmt.Fprintf(os.Stderr, "Loading IAM policies from %s: %s\n", policyProvider.Name(), policyDir)
-		policyResult, err := policyProvider.Parse(ctx, policyDir)
+	// Drop findings accepted in the baseline
+	checkResult, err = applyBaseline(cmd, checkResult)
+	if err != nil {
+		return err
+	}
+
+	// Only part of the resources were checked, so permissions they do not
+	// need may be required by others
+	if changedDirs != nil {
+		checkResult.Excessive = nil
+	}
+
+	// Walk through the findings; the queued ones are fixed instead of all
+	fixResult := checkResult
+	if interactive {
+		checkResult, fixResult, err = runTriage(checkResult, requiredPolicy)
 		if err != nil {
-			return fmt.Errorf("parsing IAM policies: %w", err)
-		}
-		if len(policyResult.Policies) == 0 {
-			return fmt.Errorf("no IAM policies found in %s", policyDir)
+			return err
 		}
-		fmt.Fprintf(os.Stderr, "Found %d IAM policy documents\n", len(policyResult.Policies))
-		existingPolicy = policy.FromProviderPolicies(policyResult.Policies)
-	} else {
-		fmt.Fprintf(os.Stderr, "Loading IAM policy from JSON: %s\n", policyFile)
-		existingData, err := os.ReadFile(policyFile)
-		if err != nil {
-			return fmt.Errorf("reading policy file: %w", err)
+	}
+
+	if fixPolicy && !interactive || fixResult != nil && interactive {
+		policyOut := io.Writer(os.Stdout)
+		if fixOutput == "" {
+			var restore func()
+			policyOut, restore = redirectReport()
+			defer restore()
 		}
-		existingPolicy, err = policy.ParsePolicy(existingData)
-		if err != nil {
-			return fmt.Errorf("parsing existing policy: %w", err)
+		if err := writeFixedPolicy(policyOut, existingPolicy, requiredPolicy, fixResult); err != nil {
+			return err
 		}
 	}
 
-	// Check policies
-	checkResult := checker.Check(existingPolicy, requiredPolicy)
+	broadGrants := findBroadGrants(existingPolicy)
+	invalid := unknownActions(existingPolicy)
 
 	// Output results
-	if checkResult.IsCompliant() {
+	switch {
+	case checkFormat == "github":
+		printAnnotations(requiredPolicy, checkResult, broadGrants, invalid)
+	case checkResult.IsCompliant():
 		fmt.Println("✓ Policy is compliant with least-privilege requirements")
-		return nil
+	default:
+		var report accessadvisor.Report
+		if lastAccessed && checkResult.HasExcessive() {
+			fmt.Fprintf(os.Stderr, "Fetching Access Advisor data for: %s\n", roleARN)
+			if report, err = accessadvisor.Fetch(ctx, roleARN); err != nil {
+				return err
+			}
+		}
+
+		printFindings(existingPolicy, requiredPolicy, checkResult, report)
+
+		if showDiff {
+			if err := printRemediationDiff(policySource, existingPolicy, requiredPolicy, checkResult); err != nil {
+				return err
+			}
+		}
+	}
+
+	if (checkResult.HasMissing() || minCoverage > 0) && checkFormat == "text" {
+		fmt.Println()
+		fmt.Printf("Requirement coverage: %.1f%% (%d of %s granted)\n", checkResult.Coverage(),
+			len(checkResult.Matched), plural(len(checkResult.Matched)+len(checkResult.Missing), "required action"))
+	}
+
+	if len(broadGrants) > 0 && checkFormat == "text" {
+		fmt.Println()
+		fmt.Println("⚠ Dangerously broad grants:")
+		for _, g := range broadGrants {
+			fmt.Printf("    ! [%s] %s\n", g.Severity, g)
+		}
+	}
+
+	if len(invalid) > 0 && checkFormat == "text" {
+		fmt.Println()
+		fmt.Println("⚠ Actions that do not exist (not in the Service Authorization Reference):")
+		for _, problem := range invalid {
+			fmt.Printf("    ? %s\n", problem)
+		}
+	}
+	if note := unverifiedNote(policyActions(existingPolicy)); note != "" && checkFormat == "text" {
+		fmt.Println()
+		fmt.Printf("Note: %s\n", note)
+	}
+
+	if checkUsage {
+		if err := reportUnusedActions(ctx, checkResult); err != nil {
+			return err
+		}
 	}
 
-	exitCode := 0
+	if postPRComment {
+		report := prcomment.Report{
+			Path:         path,
+			PolicySource: policySource,
+			Required:     requiredPolicy,
+			Result:       checkResult,
+			BroadGrants:  broadGrants,
+		}
+		if err := postComment(ctx, report); err != nil {
+			return err
+		}
+	}
+
+	if exitCode := checkExitCode(failClasses, checkResult, broadGrants); exitCode != 0 {
+		if notifyWebhook != "" {
+			notifyFailure(ctx, path, policySource, checkResult, broadGrants)
+		}
+		os.Exit(exitCode)
+	}
+
+	return nil
+}
+
+// printFindings prints missing and excessive permissions grouped by service,
+// and where the missing ones are best added to the existing policy.
+// With Access Advisor data, excessive services are annotated with when they
+// were last used and ordered so the best removal candidates come first.
+func printFindings(existingPolicy, requiredPolicy *policy.IAMPolicy, checkResult *checker.Result, report accessadvisor.Report) {
+	missingGroups := checker.GroupByService(checkResult.Missing)
+	excessiveGroups := checker.GroupByService(checkResult.Excessive)
 
 	if checkResult.HasMissing() {
 		fmt.Println("✗ Missing permissions (required but not granted):")
-		for _, action := range checkResult.Missing {
-			fmt.Printf("  - %s\n", action)
+		for _, group := range missingGroups {
+			fmt.Printf("  %s (%d):\n", group.Service, len(group.Actions))
+			for _, action := range group.Actions {
+				blocked := ""
+				if slices.Contains(checkResult.Blocked, action) {
+					blocked = " [blocked by the permissions boundary]"
+				}
+				fmt.Printf("    - %s%s%s\n", action, blocked, describeSources(requiredPolicy.SourcesOf(action)))
+			}
+		}
+		if ungranted := checker.Ungranted(existingPolicy, checkResult.Missing); len(ungranted) > 0 {
+			printTargets(existingPolicy, requiredPolicy, ungranted)
 		}
-		exitCode = 1
 	}
 
 	if checkResult.HasExcessive() {
 		fmt.Println("⚠ Excessive permissions (granted but not required):")
-		for _, action := range checkResult.Excessive {
-			fmt.Printf("  + %s\n", action)
+		if report != nil {
+			accessadvisor.SortGroups(excessiveGroups, report)
 		}
-		if exitCode == 0 {
-			exitCode = 2
+		for _, group := range excessiveGroups {
+			if access, ok := report[group.Service]; ok {
+				fmt.Printf("  %s (%d, %s):\n", group.Service, len(group.Actions), access.Describe(time.Now()))
+			} else {
+				fmt.Printf("  %s (%d):\n", group.Service, len(group.Actions))
+			}
+			risk.SortBySeverity(group.Actions)
+			for _, action := range group.Actions {
+				fmt.Printf("    + [%s] %s\n", risk.Classify(action), action)
+			}
 		}
 	}
 
-	if exitCode != 0 {
-		os.Exit(exitCode)
+	fmt.Printf("\n%d missing across %s, %d excessive across %s\n",
+		len(checkResult.Missing), plural(len(missingGroups), "service"),
+		len(checkResult.Excessive), plural(len(excessiveGroups), "service"))
+}
+
+// printTargets prints the existing statements the missing actions are best
+// added to and the new statements the rest need
+func printTargets(existing, required *policy.IAMPolicy, missing []string) {
+	fmt.Println("  Where to add them:")
+	for _, line := range describeTargets(existing, required, missing) {
+		fmt.Printf("    %s\n", line)
+	}
+}
+
+// describeTargets formats where the missing actions are best added. With
+// --policy-dir, the statements are those of the policy files rather than
+// the combined policy, which exists in none of them.
+func describeTargets(existing, required *policy.IAMPolicy, missing []string) []string {
+	statements := existing
+	if policyDirStatements != nil {
+		statements = &policyDirStatements.policy
 	}
 
+	var lines []string
+	for _, target := range checker.TargetMissing(statements, required, missing) {
+		var where string
+		switch {
+		case target.IsNew():
+			where = "New statement"
+		case policyDirStatements != nil:
+			where = "Statement " + strings.TrimPrefix(policyDirStatements.labels[target.Statement], "statement ")
+		case target.Sid != "":
+			where = fmt.Sprintf("Statement %q", target.Sid)
+		default:
+			where = fmt.Sprintf("Statement #%d", target.Statement+1)
+		}
+		lines = append(lines, fmt.Sprintf("%s (%s): %s", where, strings.Join(target.Resource, ", "), strings.Join(target.Actions, ", ")))
+	}
+	return lines
+}
+
+// parseFailOn parses the comma-separated --fail-on value into finding classes
+func parseFailOn(value string) (map[string]bool, error) {
+	classes := make(map[string]bool)
+	for _, class := range strings.Split(value, ",") {
+		switch class = strings.TrimSpace(class); class {
+		case "missing", "excessive", "broad":
+			classes[class] = true
+		case "any":
+			classes["missing"], classes["excessive"], classes["broad"] = true, true, true
+		case "none":
+		default:
+			return nil, fmt.Errorf("invalid --fail-on value: %s (use missing, excessive, broad, any, or none)", class)
+		}
+	}
+	return classes, nil
+}
+
+// checkExitCode returns the exit code for a check result according to --fail-on.
+// Missing permissions take precedence over excessive ones, which take
+// precedence over broad grants. With --min-coverage, missing permissions
+// only fail the check when the coverage of required actions is below it.
+func checkExitCode(failClasses map[string]bool, result *checker.Result, broadGrants []risk.BroadGrant) int {
+	if minCoverage > 0 {
+		if result.Coverage() < minCoverage {
+			return missingExit
+		}
+	} else if failClasses["missing"] && result.HasMissing() {
+		return missingExit
+	}
+	if failClasses["excessive"] && result.HasExcessive() {
+		return excessExit
+	}
+	if failClasses["broad"] && len(broadGrants) > 0 {
+		return broadExit
+	}
+	return 0
+}
+
+// printRemediationDiff prints the suggested policy changes as a unified diff
+func printRemediationDiff(source string, existing, required *policy.IAMPolicy, result *checker.Result) error {
+	fixed := checker.Remediate(existing, required, result)
+
+	before, err := existing.ToJSON()
+	if err != nil {
+		return fmt.Errorf("converting existing policy to JSON: %w", err)
+	}
+	after, err := fixed.ToJSON()
+	if err != nil {
+		return fmt.Errorf("converting remediated policy to JSON: %w", err)
+	}
+
+	fmt.Println()
+	fmt.Println("Suggested remediation:")
+	fmt.Print(diff.Unified(source, source+" (remediated)", before+"\n", after+"\n"))
 	return nil
 }
+
+// writeFixedPolicy writes the remediated policy JSON to --output or w
+func writeFixedPolicy(w io.Writer, existing, required *policy.IAMPolicy, result *checker.Result) error {
+	fixed := checker.Remediate(existing, required, result)
+
+	output, err := fixed.ToJSON()
+	if err != nil {
+		return fmt.Errorf("converting fixed policy to JSON: %w", err)
+	}
+
+	if fixOutput == "" {
+		fmt.Fprintln(w, output)
+		return nil
+	}
+
+	if err := os.WriteFile(fixOutput, []byte(output+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing fixed policy: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Fixed policy written to: %s\n", fixOutput)
+	return nil
+}
+
+// redirectReport sends the check report to stderr so that a fixed policy
+// written to stdout can be piped. It returns the original stdout and a
+// function restoring it.
+func redirectReport() (io.Writer, func()) {
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	return stdout, func() { os.Stdout = stdout }
+}
+
+// applyBaseline removes findings accepted in the baseline file.
+// The default baseline file is optional; an explicitly given one must exist.
+func applyBaseline(cmd *cobra.Command, result *checker.Result) (*checker.Result, error) {
+	if _, err := os.Stat(baselineFile); os.IsNotExist(err) && !cmd.Flags().Changed("baseline") {
+		return result, nil
+	}
+
+	b, err := baseline.Load(baselineFile)
+	if err != nil {
+		return nil, err
+	}
+
+	outcome := b.Apply(result, time.Now())
+	if outcome.Suppressed > 0 {
+		fmt.Fprintf(os.Stderr, "Accepted %d findings from baseline: %s\n", outcome.Suppressed, baselineFile)
+	}
+	for _, e := range outcome.Expired {
+		fmt.Fprintf(os.Stderr, "Warning: baseline entry for %s expired on %s\n", e.Action, e.Expires)
+	}
+	for _, e := range outcome.Stale {
+		fmt.Fprintf(os.Stderr, "Note: baseline entry for %s no longer matches a finding\n", e.Action)
+	}
+
+	return outcome.Result, nil
+}
+
+// plural formats a count with a singular or plural noun
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// maxListedSources limits how many requiring resources are shown per action
+const maxListedSources = 3
+
+// describeSources formats the resources requiring an action for check output
+func describeSources(sources []provider.Resource) string {
+	if len(sources) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, maxListedSources)
+	for i, res := range sources {
+		if i == maxListedSources {
+			parts = append(parts, fmt.Sprintf("and %d more", len(sources)-maxListedSources))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s at %s", res.Address(), res.Location))
+	}
+	return " (required by " + strings.Join(parts, ", ") + ")"
+}
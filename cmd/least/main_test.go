@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mizzy/least/internal/baseline"
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/mapping"
+	"github.com/mizzy/least/internal/offline"
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/risk"
+	"github.com/mizzy/least/internal/schema"
+)
+
+func TestWriteFixedPolicy(t *testing.T) {
+	existing, err := policy.ParsePolicy([]byte(`{
+		"Version": "2012-10-17",
+		"Statement": {"Effect": "Allow", "NotAction": ["iam:*", "organizations:*"], "Resource": "*"}
+	}`))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	required := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:CreateBucket", "sqs:CreateQueue"}, Resource: []string{"*"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeFixedPolicy(&buf, existing, required, checker.Check(existing, required)); err != nil {
+		t.Fatalf("writeFixedPolicy() error = %v", err)
+	}
+
+	fixed, err := policy.ParsePolicy(buf.Bytes())
+	if err != nil {
+		t.Fatalf("fixed policy is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if result := checker.Check(fixed, required); !result.IsCompliant() {
+		t.Errorf("fixed policy should be compliant, got missing=%v excessive=%v", result.Missing, result.Excessive)
+	}
+}
+
+func TestRedirectReport(t *testing.T) {
+	stdout := os.Stdout
+	w, restore := redirectReport()
+
+	if w != stdout {
+		t.Error("redirectReport() should return the original stdout")
+	}
+	if os.Stdout != os.Stderr {
+		t.Error("report output should go to stderr")
+	}
+
+	restore()
+	if os.Stdout != stdout {
+		t.Error("restore should reinstate stdout")
+	}
+}
+
+func TestParseFailOn(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "missing", want: []string{"missing"}},
+		{value: "excessive", want: []string{"excessive"}},
+		{value: "broad", want: []string{"broad"}},
+		{value: "missing, broad", want: []string{"missing", "broad"}},
+		{value: "any", want: []string{"missing", "excessive", "broad"}},
+		{value: "none", want: nil},
+		{value: "missing,unknown", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseFailOn(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseFailOn(%q) expected error", tt.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseFailOn(%q) error = %v", tt.value, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("parseFailOn(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+		for _, class := range tt.want {
+			if !got[class] {
+				t.Errorf("parseFailOn(%q) = %v, want %s set", tt.value, got, class)
+			}
+		}
+	}
+}
+
+func TestCheckExitCode(t *testing.T) {
+	broad := []risk.BroadGrant{{Kind: risk.FullWildcardAction, Action: "*", Severity: risk.Critical}}
+	missing := &checker.Result{Missing: []string{"s3:PutObject"}, Matched: []string{"s3:GetObject"}}
+	excessive := &checker.Result{Excessive: []string{"ec2:*"}}
+	both := &checker.Result{Missing: []string{"s3:PutObject"}, Excessive: []string{"ec2:*"}}
+
+	tests := []struct {
+		name        string
+		failOn      string
+		minCoverage float64
+		result      *checker.Result
+		broad       []risk.BroadGrant
+		want        int
+	}{
+		{name: "compliant", failOn: "any", result: &checker.Result{}, want: 0},
+		{name: "missing", failOn: "missing,excessive", result: missing, want: 1},
+		{name: "excessive", failOn: "missing,excessive", result: excessive, want: 2},
+		{name: "broad", failOn: "broad", result: &checker.Result{}, broad: broad, want: 3},
+		{name: "missing takes precedence", failOn: "any", result: both, broad: broad, want: 1},
+		{name: "excessive over broad", failOn: "any", result: excessive, broad: broad, want: 2},
+		{name: "broad not failing by default", failOn: "missing,excessive", result: &checker.Result{}, broad: broad, want: 0},
+		{name: "excessive ignored", failOn: "missing", result: excessive, want: 0},
+		{name: "none", failOn: "none", result: both, broad: broad, want: 0},
+		{name: "coverage below minimum", failOn: "none", minCoverage: 80, result: missing, want: 1},
+		{name: "coverage above minimum", failOn: "missing", minCoverage: 50, result: missing, want: 0},
+	}
+
+	defer func(saved float64) { minCoverage = saved }(minCoverage)
+	for _, tt := range tests {
+		classes, err := parseFailOn(tt.failOn)
+		if err != nil {
+			t.Fatalf("%s: parseFailOn() error = %v", tt.name, err)
+		}
+		minCoverage = tt.minCoverage
+		if got := checkExitCode(classes, tt.result, tt.broad); got != tt.want {
+			t.Errorf("%s: checkExitCode() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// Broad grants fail the check whatever their severity
+	minCoverage = 0
+	classes, _ := parseFailOn("broad")
+	for _, severity := range []risk.Severity{risk.Low, risk.Medium, risk.High, risk.Critical} {
+		grants := []risk.BroadGrant{{Kind: risk.WildcardResource, Action: "s3:PutObject", Severity: severity}}
+		if got := checkExitCode(classes, &checker.Result{}, grants); got != 3 {
+			t.Errorf("%s broad grant: checkExitCode() = %d, want 3", severity, got)
+		}
+	}
+}
+
+func TestCheckExitCodeWithBaseline(t *testing.T) {
+	result := &checker.Result{
+		Missing:   []string{"iam:PassRole"},
+		Excessive: []string{"ec2:DescribeInstances"},
+	}
+	classes, err := parseFailOn("missing,excessive")
+	if err != nil {
+		t.Fatalf("parseFailOn() error = %v", err)
+	}
+
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	accepted := &baseline.Baseline{
+		Missing:   []baseline.Entry{{Action: "iam:PassRole"}},
+		Excessive: []baseline.Entry{{Action: "ec2:DescribeInstances"}},
+	}
+	if got := checkExitCode(classes, accepted.Apply(result, now).Result, nil); got != 0 {
+		t.Errorf("fully accepted findings: exit code = %d, want 0", got)
+	}
+
+	// Only the missing finding is accepted, so the excessive one still fails
+	partial := &baseline.Baseline{Missing: []baseline.Entry{{Action: "iam:PassRole"}}}
+	if got := checkExitCode(classes, partial.Apply(result, now).Result, nil); got != 2 {
+		t.Errorf("partially accepted findings: exit code = %d, want 2", got)
+	}
+
+	// Expired entries no longer suppress their findings
+	expired := &baseline.Baseline{
+		Missing:   []baseline.Entry{{Action: "iam:PassRole", Expires: "2026-01-31"}},
+		Excessive: []baseline.Entry{{Action: "ec2:DescribeInstances"}},
+	}
+	if got := checkExitCode(classes, expired.Apply(result, now).Result, nil); got != 1 {
+		t.Errorf("expired baseline entry: exit code = %d, want 1", got)
+	}
+}
+
+func TestFindBroadGrantsPolicyDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "main.tf"), `
+data "aws_iam_policy_document" "deploy" {
+  statement {
+    sid       = "Objects"
+    actions   = ["s3:PutObject", "s3:DeleteObject"]
+    resources = ["arn:aws:s3:::app-data/*"]
+  }
+
+  statement {
+    actions   = ["sqs:SendMessage"]
+    resources = ["arn:aws:sqs:us-east-1:123456789012:jobs"]
+  }
+}
+
+resource "aws_iam_policy" "deploy" {
+  name   = "deploy"
+  policy = data.aws_iam_policy_document.deploy.json
+}
+
+resource "aws_iam_policy" "admin" {
+  name = "admin"
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [{
+      Effect   = "Allow"
+      Action   = ["iam:*"]
+      Resource = "*"
+    }]
+  })
+}
+`)
+
+	defer func() { policyDirStatements = nil }()
+	existing, err := loadPolicyDir(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("loadPolicyDir() error = %v", err)
+	}
+
+	// The combined statement grants every action on "*", but only the
+	// admin policy is actually unscoped
+	grants := findBroadGrants(existing)
+	if len(grants) != 2 {
+		t.Fatalf("findBroadGrants() = %v, want the iam:* grants of the admin policy", grants)
+	}
+	for _, g := range grants {
+		if g.Action != "iam:*" || !strings.Contains(g.Statement, "aws_iam_policy.admin") {
+			t.Errorf("unexpected broad grant: %s", g)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDescribeTargetsPolicyDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "main.tf"), `
+data "aws_iam_policy_document" "deploy" {
+  statement {
+    sid       = "Objects"
+    actions   = ["s3:GetObject"]
+    resources = ["arn:aws:s3:::app-data/*"]
+  }
+}
+
+resource "aws_iam_policy" "deploy" {
+  name   = "deploy"
+  policy = data.aws_iam_policy_document.deploy.json
+}
+`)
+
+	defer func() { policyDirStatements = nil }()
+	existing, err := loadPolicyDir(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("loadPolicyDir() error = %v", err)
+	}
+	required := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:GetObject", "s3:PutObject"}, Resource: []string{"arn:aws:s3:::app-data/*"}},
+			{Effect: "Allow", Action: []string{"sqs:SendMessage"}, Resource: []string{"arn:aws:sqs:*:*:jobs"}},
+		},
+	}
+
+	lines := describeTargets(existing, required, []string{"s3:PutObject", "sqs:SendMessage"})
+	if len(lines) != 2 {
+		t.Fatalf("describeTargets() = %q, want 2 lines", lines)
+	}
+	if !strings.HasPrefix(lines[0], `Statement "Objects" of data.aws_iam_policy_document.deploy`) || !strings.HasSuffix(lines[0], ": s3:PutObject") {
+		t.Errorf("lines[0] = %q, want s3:PutObject on the Objects statement of the document", lines[0])
+	}
+	if lines[1] != "New statement (arn:aws:sqs:*:*:jobs): sqs:SendMessage" {
+		t.Errorf("lines[1] = %q", lines[1])
+	}
+	for _, line := range lines {
+		if strings.Contains(line, "CombinedPolicy") {
+			t.Errorf("target refers to the synthetic combined statement: %q", line)
+		}
+	}
+}
+
+func TestRefreshSchemaOptIn(t *testing.T) {
+	store := schema.NewStore(t.TempDir())
+	stale := &schema.ResourceSchema{TypeName: "AWS::Glue::Job", FetchedAt: time.Now().Add(-60 * 24 * time.Hour)}
+	if err := store.SaveToCache(stale); err != nil {
+		t.Fatal(err)
+	}
+
+	savedStore, savedTTL, savedRefresh := schemaStore, schemaTTL, refreshSchemas
+	defer func() { schemaStore, schemaTTL, refreshSchemas = savedStore, savedTTL, savedRefresh }()
+	schemaStore, schemaTTL, refreshSchemas = store, 30*24*time.Hour, false
+
+	stderr := os.Stderr
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stderr = w
+	refreshSchema("AWS::Glue::Job")
+	os.Stderr = stderr
+	w.Close()
+
+	var out bytes.Buffer
+	out.ReadFrom(r)
+	if !strings.Contains(out.String(), "cached schema AWS::Glue::Job is older than --schema-ttl") {
+		t.Errorf("stale schema should be reported, got %q", out.String())
+	}
+	if strings.Contains(out.String(), "refreshing schema") {
+		t.Errorf("stale schema should not be fetched without --refresh-schemas, got %q", out.String())
+	}
+	if at, _ := store.FetchedAt("AWS::Glue::Job"); !at.Equal(stale.FetchedAt) {
+		t.Errorf("cached schema fetched at %v, want it unchanged", at)
+	}
+}
+
+func TestResolveFromSchemaBundleOffline(t *testing.T) {
+	savedStore := schemaStore
+	defer func() { schemaStore = savedStore }()
+	schemaStore = schema.NewStore(t.TempDir())
+	offline.Set(true)
+	defer offline.Set(false)
+
+	// aws_db_cluster is bundled as aws_rds_cluster; it resolves from the
+	// embedded bundle by its CloudFormation type without a cached schema
+	m, provenance, ok := resolveFromSchemaCache("aws_db_cluster")
+	if !ok {
+		t.Fatal("aws_db_cluster should resolve from the embedded schema bundle")
+	}
+	if provenance != (mapping.Provenance{Kind: mapping.ProvenanceSchema, CfnType: "AWS::RDS::DBCluster"}) {
+		t.Errorf("provenance = %+v, want the AWS::RDS::DBCluster schema", provenance)
+	}
+	if !slices.Contains(m.Create, "rds:CreateDBCluster") {
+		t.Errorf("Create = %v, want rds:CreateDBCluster", m.Create)
+	}
+
+	if _, _, ok := resolveFromSchemaCache("aws_example_widget"); ok {
+		t.Error("a type in neither the bundle nor the cache should not resolve")
+	}
+}
+
+func TestFetchManagedPolicyNotInCatalog(t *testing.T) {
+	offline.Set(true)
+	defer offline.Set(false)
+
+	if _, err := fetchManagedPolicy(context.Background(), "arn:aws:iam::aws:policy/AmazonSQSFullAccess"); err != nil {
+		t.Errorf("cataloged policy should resolve offline: %v", err)
+	}
+
+	_, err := fetchManagedPolicy(context.Background(), "arn:aws:iam::aws:policy/AWSGlueConsoleFullAccess")
+	if err == nil || !strings.Contains(err.Error(), "AWSGlueConsoleFullAccess is not in the embedded catalog") {
+		t.Errorf("fetchManagedPolicy() error = %v, want a not-in-catalog error", err)
+	}
+	if !errors.Is(err, offline.ErrOffline) {
+		t.Errorf("fetchManagedPolicy() error = %v, want it to wrap the fetch error", err)
+	}
+}
+
+func TestTriage(t *testing.T) {
+	result := &checker.Result{
+		Missing:   []string{"s3:GetObject", "sqs:SendMessage"},
+		Excessive: []string{"ec2:DescribeInstances", "iam:PassRole", "kms:Decrypt"},
+		Matched:   []string{"s3:CreateBucket"},
+	}
+	// Unknown answers are asked again; q leaves kms:Decrypt untriaged
+	answers := "a\nread by the reporting job\nmaybe\nf\ns\nfix\nq\n"
+
+	var out bytes.Buffer
+	got, err := triage(strings.NewReader(answers), &out, result, &policy.IAMPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := baseline.Entry{Action: "s3:GetObject", Justification: "read by the reporting job"}
+	if len(got.Accepted.Missing) != 1 || got.Accepted.Missing[0] != want || len(got.Accepted.Excessive) != 0 {
+		t.Errorf("accepted = %+v", got.Accepted)
+	}
+	if strings.Join(got.Fixes.Missing, ",") != "sqs:SendMessage" || strings.Join(got.Fixes.Excessive, ",") != "iam:PassRole" {
+		t.Errorf("fixes = %+v", got.Fixes)
+	}
+	if n := strings.Count(out.String(), "[a/f/s/q]"); n != 6 {
+		t.Errorf("asked %d times, want 6:\n%s", n, out.String())
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mizzy/least/internal/managedpolicy"
+	"github.com/mizzy/least/internal/policy"
+)
+
+// suggestManagedPolicies reports the AWS managed policies closest to a
+// generated policy, for --suggest-managed
+func suggestManagedPolicies(p *policy.IAMPolicy) {
+	required := p.GetAllActions()
+	catalog := fmt.Sprintf("%d AWS managed policies", len(managedpolicy.Names()))
+	suggestions := managedpolicy.Suggest(required)
+	if len(suggestions) == 0 {
+		fmt.Fprintf(os.Stderr, "None of the %s in the embedded catalog grants the required actions\n", catalog)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Closest of the %s in the embedded catalog to the %s (on all resources, without conditions):\n",
+		catalog, plural(len(required), "required action"))
+	for _, s := range suggestions {
+		line := fmt.Sprintf("  %s: grants %s, %d beyond the requirements",
+			strings.Join(s.Policies, " + "), plural(s.Granted, "action"), s.Excess)
+		if len(s.Uncovered) > 0 {
+			line += fmt.Sprintf("; does not grant %s", strings.Join(s.Uncovered, ", "))
+		}
+		fmt.Fprintln(os.Stderr, line)
+	}
+}
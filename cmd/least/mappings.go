@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/mapping"
+	"github.com/mizzy/least/internal/mappingpack"
+	"github.com/mizzy/least/internal/sar"
+)
+
+var mappingsCmd = &cobra.Command{
+	Use:   "mappings",
+	Short: "List, inspect and customize resource permission mappings",
+	Long: `List and inspect the mappings from resource types to IAM actions, and
+add or override mappings in a local mappings file (` + mapping.DefaultCustomFile + `).
+
+Mappings in the local file take precedence over built-in ones and are used by
+all commands.`,
+}
+
+var mappingsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List mapped resource types",
+	Args:  cobra.NoArgs,
+	RunE:  runMappingsList,
+}
+
+var mappingsShowCmd = &cobra.Command{
+	Use:   "show <type>",
+	Short: "Show the mapping for a resource type",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runMappingsShow,
+}
+
+var mappingsAddCmd = &cobra.Command{
+	Use:   "add <type>",
+	Short: "Add or override a mapping in the local mappings file",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runMappingsAdd,
+}
+
+var mappingsValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Validate a mappings file",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runMappingsValidate,
+}
+
+var mappingsInstallCmd = &cobra.Command{
+	Use:   "install <source>",
+	Short: "Install a mapping pack from a URL or an OCI registry",
+	Long: `Download a mapping pack, a mappings file published for niche services or
+third-party Terraform providers, from an https:// URL or an OCI registry
+(oci://registry/repository:tag, or @sha256:digest to pin it), verify its
+SHA-256 checksum, and install it into ` + mappingpack.DefaultDir + `.
+
+The checksum is given with --sha256. It may only be omitted for OCI references
+pinned with @sha256:digest, whose manifest vouches for the layer digest; a
+checksum served next to the pack would come from the same place as the pack.
+Plain http:// is only accepted for localhost. Installed packs are loaded by all commands under the local mappings file and
+--mappings overlays, which override them. Installing a pack of the same name
+replaces it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runMappingsInstall,
+}
+
+var (
+	mappingsFile        string
+	customOnly          bool
+	mappingCreate       []string
+	mappingRead         []string
+	mappingUpdate       []string
+	mappingDelete       []string
+	mappingARN          string
+	mappingARNAttribute string
+	packChecksum        string
+	packName            string
+
+	// localMappings is the content of the local mappings file edited by add
+	localMappings *mapping.CustomMappings
+	// customMappings merges the local mappings file and the overlay files
+	customMappings *mapping.CustomMappings
+	// customMappingFiles records the file each custom mapping comes from
+	customMappingFiles map[string]string
+)
+
+func init() {
+	rootCmd.AddCommand(mappingsCmd)
+	mappingsCmd.AddCommand(mappingsListCmd, mappingsShowCmd, mappingsAddCmd, mappingsValidateCmd, mappingsInstallCmd)
+
+	mappingsCmd.PersistentFlags().StringVar(&mappingsFile, "file", mapping.DefaultCustomFile, "Local mappings file")
+	mappingsListCmd.Flags().BoolVar(&customOnly, "custom", false, "List only custom mappings")
+	mappingsAddCmd.Flags().StringSliceVar(&mappingCreate, "create", nil, "Actions required to create the resource")
+	mappingsAddCmd.Flags().StringSliceVar(&mappingRead, "read", nil, "Actions required to read the resource")
+	mappingsAddCmd.Flags().StringSliceVar(&mappingUpdate, "update", nil, "Actions required to update the resource")
+	mappingsAddCmd.Flags().StringSliceVar(&mappingDelete, "delete", nil, "Actions required to delete the resource")
+	mappingsAddCmd.Flags().StringVar(&mappingARN, "arn", "", "ARN pattern with {account}, {region} and {attribute} placeholders")
+	mappingsAddCmd.Flags().StringVar(&mappingARNAttribute, "arn-attribute", "", "Resource attribute substituted into the ARN pattern")
+	mappingsInstallCmd.Flags().StringVar(&packChecksum, "sha256", "", "Expected hex SHA-256 checksum of the pack (required unless the OCI reference is pinned with @sha256:)")
+	mappingsInstallCmd.Flags().StringVar(&packName, "name", "", "Name of the pack if it does not name itself (default: from the source)")
+}
+
+// loadCustomMappings loads the installed mapping packs, the local mappings
+// file, if present, and the overlay files from --mappings or the
+// configuration file over the built-in mappings. Later files override
+// earlier ones.
+func loadCustomMappings(cmd *cobra.Command) error {
+	if cmd == mappingsValidateCmd || cmd == mappingsInstallCmd {
+		return nil
+	}
+
+	files := cfg.Mappings
+	if cmd.Flags().Changed("mappings") {
+		files = mappingOverlays
+	}
+	if _, err := os.Stat(mappingsFile); err == nil {
+		files = append([]string{mappingsFile}, files...)
+	}
+	packs, err := mappingpack.Files(mappingpack.DefaultDir)
+	if err != nil {
+		return err
+	}
+	files = append(packs, files...)
+	if len(files) == 0 && len(cfg.ARNPatterns) == 0 {
+		return nil
+	}
+
+	merged := &mapping.CustomMappings{}
+	customMappingFiles = make(map[string]string)
+	configured, err := configARNPatterns()
+	if err != nil {
+		return err
+	}
+	merged.Merge(configured)
+	for t := range configured.Mappings {
+		customMappingFiles[t] = configFile
+	}
+	for _, file := range files {
+		c, err := mapping.LoadCustomFile(file)
+		if err != nil {
+			return err
+		}
+		if errs := c.Validate(); len(errs) > 0 {
+			return fmt.Errorf("invalid mappings file %s: %w (run 'least mappings validate %s')", file, errs[0], file)
+		}
+		if file == mappingsFile {
+			localMappings = c
+		}
+		merged.Merge(c)
+		for t := range c.Mappings {
+			customMappingFiles[t] = file
+		}
+	}
+
+	// Entries without an ARN pattern keep the one from the config
+	for t, m := range merged.Mappings {
+		if prev, ok := configured.Mappings[t]; ok && m.ARN == nil {
+			m.ARN = prev.ARN
+			merged.Mappings[t] = m
+		}
+	}
+
+	customMappings = merged
+	mapping.SetCustomMappings(merged)
+	return nil
+}
+
+// configARNPatterns returns the ARN patterns of the configuration file as
+// ARN-only mappings
+func configARNPatterns() (*mapping.CustomMappings, error) {
+	c := &mapping.CustomMappings{Mappings: make(map[string]mapping.CustomMapping)}
+	for t, p := range cfg.ARNPatterns {
+		c.Mappings[t] = mapping.CustomMapping{ARN: &p}
+	}
+	if errs := c.Validate(); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid arn_patterns in %s: %w", configFile, errs[0])
+	}
+	return c, nil
+}
+
+func runMappingsList(cmd *cobra.Command, args []string) error {
+	seen := make(map[string]bool)
+	var types []string
+	if !customOnly {
+		for _, t := range mapping.GetSupportedResourceTypes() {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	if customMappings != nil {
+		for t := range customMappings.Mappings {
+			if !seen[t] {
+				types = append(types, t)
+			}
+		}
+	}
+	sort.Strings(types)
+
+	for _, t := range types {
+		source, _ := mapping.GetSource(t)
+		fmt.Printf("  %-40s %-30s %s\n", t, source, plural(len(mapping.GetActionsForResource(t)), "action"))
+	}
+	fmt.Printf("%s\n", plural(len(types), "resource type"))
+	return nil
+}
+
+func runMappingsShow(cmd *cobra.Command, args []string) error {
+	resourceType := args[0]
+	m, source, ok := mapping.GetMapping(resourceType)
+	if !ok {
+		return fmt.Errorf("no mapping for %s", resourceType)
+	}
+
+	switch source {
+	case "custom":
+		source = "custom mappings file " + customMappingFiles[resourceType]
+		if mapping.IsBuiltin(resourceType) {
+			source += " (overrides built-in)"
+		}
+	case "fallback":
+		source = "built-in"
+	case "policy-sentry":
+		source = "policy_sentry dataset " + policySentryFile
+	default:
+		source = "CloudFormation schema " + source
+	}
+
+	fmt.Printf("%s\n", resourceType)
+	fmt.Printf("  source: %s\n", source)
+	if p, ok := mapping.GetARNPattern(resourceType); ok {
+		arn := p.Pattern
+		if file, ok := customMappingFiles[resourceType]; ok && file == configFile {
+			arn += " (arn_patterns in " + file + ")"
+		} else if ok && source != "custom mappings file "+file {
+			arn += " (custom mappings file " + file + ")"
+		}
+		fmt.Printf("  arn:    %s\n", arn)
+		for _, child := range p.ChildPatterns {
+			fmt.Printf("          %s\n", child)
+		}
+		if p.Default != "" {
+			fmt.Printf("          {%s} defaults to %s\n", p.ResourceAttribute, p.Default)
+		}
+	}
+	for _, op := range []struct {
+		name    string
+		actions []string
+	}{
+		{"create", m.Create},
+		{"read", m.Read},
+		{"update", m.Update},
+		{"delete", m.Delete},
+	} {
+		if len(op.actions) == 0 {
+			continue
+		}
+		fmt.Printf("  %s:\n", op.name)
+		for _, a := range op.actions {
+			fmt.Printf("    - %s\n", a)
+		}
+	}
+	return nil
+}
+
+func runMappingsAdd(cmd *cobra.Command, args []string) error {
+	resourceType := args[0]
+	m := mapping.CustomMapping{
+		ResourceMapping: mapping.ResourceMapping{
+			Create: mappingCreate,
+			Read:   mappingRead,
+			Update: mappingUpdate,
+			Delete: mappingDelete,
+		},
+	}
+	if mappingARN != "" {
+		m.ARN = &mapping.ARNPattern{Pattern: mappingARN, ResourceAttribute: mappingARNAttribute}
+	}
+
+	added := &mapping.CustomMappings{Mappings: map[string]mapping.CustomMapping{resourceType: m}}
+	if errs := added.Validate(); len(errs) > 0 {
+		return errs[0]
+	}
+
+	c := localMappings
+	if c == nil {
+		c = &mapping.CustomMappings{}
+	}
+	_, exists := c.Mappings[resourceType]
+	c.Merge(added)
+
+	if err := c.Save(mappingsFile); err != nil {
+		return fmt.Errorf("writing mappings: %w", err)
+	}
+
+	verb := "Added"
+	switch {
+	case exists:
+		verb = "Replaced"
+	case mapping.IsBuiltin(resourceType):
+		verb = "Overrode built-in"
+	}
+	actions := len(m.Create) + len(m.Read) + len(m.Update) + len(m.Delete)
+	fmt.Printf("✓ %s mapping for %s (%s) in %s\n", verb, resourceType, plural(actions, "action"), mappingsFile)
+	return nil
+}
+
+func runMappingsInstall(cmd *cobra.Command, args []string) error {
+	source := args[0]
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	fmt.Fprintf(os.Stderr, "Downloading mapping pack: %s\n", source)
+	fetched, err := mappingpack.Fetch(ctx, http.DefaultClient, source, strings.ToLower(packChecksum))
+	if err != nil {
+		return err
+	}
+	name := packName
+	if name == "" {
+		name = fetched.Name
+	}
+	pack, err := mappingpack.Install(mappingpack.DefaultDir, source, name, fetched.Data, fetched.SHA256)
+	if err != nil {
+		return err
+	}
+
+	version := ""
+	if pack.Version != "" {
+		version = " " + pack.Version
+	}
+	fmt.Printf("✓ Installed mapping pack %s%s (sha256 %s) into %s\n", pack.Name, version, pack.SHA256, mappingpack.DefaultDir)
+	return nil
+}
+
+func runMappingsValidate(cmd *cobra.Command, args []string) error {
+	path := mappingsFile
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	c, err := mapping.LoadCustomFile(path)
+	if err != nil {
+		return err
+	}
+
+	errs := c.Validate()
+	if len(errs) > 0 {
+		fmt.Printf("✗ %s has %s:\n", path, plural(len(errs), "error"))
+		for _, err := range errs {
+			fmt.Printf("  - %s\n", err)
+		}
+		return fmt.Errorf("invalid mappings file %s", path)
+	}
+
+	var overrides []string
+	for t := range c.Mappings {
+		if mapping.IsBuiltin(t) {
+			overrides = append(overrides, t)
+		}
+	}
+	sort.Strings(overrides)
+
+	fmt.Printf("✓ %s is valid (%s)\n", path, plural(len(c.Mappings), "mapping"))
+	if len(overrides) > 0 {
+		fmt.Printf("  overrides built-in: %s\n", strings.Join(overrides, ", "))
+	}
+
+	// Unknown actions are likely typos, but the embedded reference may lag
+	// behind newly launched actions, so they only warn
+	names := make([]string, 0, len(c.Mappings))
+	for t := range c.Mappings {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+
+	var actions []string
+	for _, t := range names {
+		m := c.Mappings[t]
+		for _, list := range [][]string{m.Create, m.Read, m.Update, m.Delete} {
+			actions = append(actions, list...)
+		}
+	}
+	for _, problem := range sar.Validate(actions) {
+		fmt.Printf("  warning: %s\n", problem)
+	}
+	if note := unverifiedNote(actions); note != "" {
+		fmt.Printf("  note: %s\n", note)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/notify"
+	"github.com/mizzy/least/internal/risk"
+)
+
+// notifyFailure sends a failed check to --notify-webhook. A failed delivery
+// is reported but does not change the outcome of the check.
+func notifyFailure(ctx context.Context, path, policySource string, result *checker.Result, broadGrants []risk.BroadGrant) {
+	summary := notify.Summary{
+		Path:      path,
+		Policy:    policySource,
+		Missing:   result.Missing,
+		Excessive: result.Excessive,
+		ReportURL: reportURL,
+	}
+	if summary.ReportURL == "" {
+		summary.ReportURL = notify.RunURL(os.Getenv)
+	}
+	for _, g := range broadGrants {
+		summary.Broad = append(summary.Broad, g.String())
+	}
+
+	if err := notify.Send(ctx, notifyWebhook, summary); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Sent check failure to the webhook")
+}
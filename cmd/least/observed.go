@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/observed"
+)
+
+var observedCmd = &cobra.Command{
+	Use:   "observed [path]",
+	Short: "Compare the generated policy with API calls recorded during an apply",
+	Long: `Compare the policy generated from IaC files with the API calls recorded
+while they were applied, and report actions least missed and actions it
+over-predicted. Exits with 1 when actions were missed.
+
+Recordings are iamlive policy files (iamlive --output-file) or AWS SDK
+client-side monitoring events, one JSON object per line. With --merge, the
+generated policy is written with the missed actions added.`,
+	Example: `  iamlive --output-file iamlive.json &
+  AWS_CSM_ENABLED=true terraform apply
+  least observed ./terraform --calls iamlive.json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runObserved,
+}
+
+var (
+	observedCalls  []string
+	observedMerge  bool
+	observedOutput string
+)
+
+func init() {
+	rootCmd.AddCommand(observedCmd)
+
+	observedCmd.Flags().StringSliceVarP(&observedCalls, "calls", "c", nil, "iamlive policy or CSM event files with the recorded API calls")
+	observedCmd.Flags().BoolVar(&observedMerge, "merge", false, "Write the generated policy JSON with the missed actions added")
+	observedCmd.Flags().StringVarP(&observedOutput, "output", "o", "", "Output file for --merge (default: stdout)")
+	_ = observedCmd.MarkFlagRequired("calls")
+}
+
+func runObserved(cmd *cobra.Command, args []string) error {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	generated, err := generateFromPath(context.Background(), path)
+	if err != nil {
+		return err
+	}
+
+	var calls []string
+	for _, file := range observedCalls {
+		actions, err := observed.Load(file)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Loaded %s from: %s\n", plural(len(actions), "observed action"), file)
+		calls = append(calls, actions...)
+	}
+
+	c := observed.Compare(generated, calls)
+
+	report := os.Stdout
+	if observedMerge {
+		report = os.Stderr
+	}
+	printComparison(report, c)
+
+	if !observedMerge {
+		// Like check, findings exit non-zero without a usage message
+		if len(c.Missed) > 0 {
+			os.Exit(1)
+		}
+		return nil
+	}
+
+	output, err := observed.Merge(generated, c.Missed).ToJSON()
+	if err != nil {
+		return fmt.Errorf("converting merged policy to JSON: %w", err)
+	}
+	if observedOutput == "" {
+		fmt.Println(output)
+		return nil
+	}
+	if err := os.WriteFile(observedOutput, []byte(output+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing merged policy: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Merged policy written to: %s\n", observedOutput)
+	return nil
+}
+
+// printComparison prints missed and over-predicted actions grouped by service
+func printComparison(w *os.File, c observed.Comparison) {
+	fmt.Fprintf(w, "✓ %s generated and observed\n", plural(len(c.Confirmed), "action"))
+
+	if len(c.Missed) > 0 {
+		fmt.Fprintln(w, "✗ Missed (observed but not generated):")
+		for _, group := range checker.GroupByService(c.Missed) {
+			fmt.Fprintf(w, "  %s (%d):\n", group.Service, len(group.Actions))
+			for _, action := range group.Actions {
+				fmt.Fprintf(w, "    + %s\n", action)
+			}
+		}
+	}
+
+	if len(c.OverPredicted) > 0 {
+		fmt.Fprintln(w, "⚠ Over-predicted (generated but not observed; expected for update and delete actions):")
+		for _, group := range checker.GroupByService(c.OverPredicted) {
+			fmt.Fprintf(w, "  %s (%d):\n", group.Service, len(group.Actions))
+			for _, action := range group.Actions {
+				fmt.Fprintf(w, "    - %s\n", action)
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d missed, %d over-predicted\n", len(c.Missed), len(c.OverPredicted))
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mizzy/least/internal/mapping"
+	"github.com/mizzy/least/internal/policysentry"
+)
+
+var (
+	policySentryFile   string
+	policySentryLevels []string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&policySentryFile, "policy-sentry", "", "policy_sentry IAM definition file (iam-definition.json) used for resource types without a mapping or cached schema")
+	rootCmd.PersistentFlags().StringSliceVar(&policySentryLevels, "policy-sentry-access-levels", policysentry.DefaultAccessLevels, "Access levels of policy_sentry actions to include: read, list, write, tagging, permissions-management")
+}
+
+// setupResolvers adds the policy_sentry dataset, when given, as a resolver
+// consulted after the schema cache
+func setupResolvers() error {
+	if policySentryFile == "" {
+		return nil
+	}
+
+	dataset, err := policysentry.Load(policySentryFile)
+	if err != nil {
+		return err
+	}
+	if err := dataset.SetAccessLevels(policySentryLevels); err != nil {
+		return fmt.Errorf("--policy-sentry-access-levels: %w", err)
+	}
+	mapping.SetResolvers(resolveFromSchemaCache, dataset.Resolve)
+	return nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/mizzy/least/internal/prcomment"
+)
+
+// postComment posts the check report on the pull or merge request of the
+// CI run, updating the report of an earlier run
+func postComment(ctx context.Context, report prcomment.Report) error {
+	commenter, err := prcomment.FromEnv(os.Getenv)
+	if err != nil {
+		return fmt.Errorf("--comment: %w", err)
+	}
+	if err := commenter.Upsert(ctx, report.Markdown()); err != nil {
+		return fmt.Errorf("posting check report: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, "Posted check report to the pull request")
+	return nil
+}
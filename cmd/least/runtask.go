@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/metrics"
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/prcomment"
+	"github.com/mizzy/least/internal/provider/terraform"
+	"github.com/mizzy/least/internal/runtask"
+)
+
+var runTaskCmd = &cobra.Command{
+	Use:   "run-task",
+	Short: "Serve an HCP Terraform run task that checks plans",
+	Long: `Serve an HCP Terraform (Terraform Cloud) run task. For each run, the plan JSON
+is downloaded and the policy its resources require is generated. With --policy,
+--policy-dir or --policy-arn, the policy is checked like 'least check' and the
+run fails on the findings selected by --fail-on; otherwise the run passes and
+links to the generated policy.
+
+Register the task in HCP Terraform with the URL of the server and the same
+HMAC key, and attach it to workspaces in the post-plan stage. Requests without
+a valid signature are rejected, as are plan and callback URLs outside
+--hostname. Prometheus metrics of the runs are served at /metrics.`,
+	Args: cobra.NoArgs,
+	RunE: runRunTask,
+}
+
+var (
+	runTaskListen    string
+	runTaskHMACKey   string
+	runTaskInsecure  bool
+	runTaskHostname  string
+	runTaskPublicURL string
+)
+
+func init() {
+	rootCmd.AddCommand(runTaskCmd)
+
+	runTaskCmd.Flags().StringVar(&runTaskListen, "listen", ":8080", "Address to listen on")
+	runTaskCmd.Flags().StringVar(&runTaskHMACKey, "hmac-key", "", "HMAC key of the run task, to verify requests (default: $LEAST_RUN_TASK_HMAC_KEY)")
+	runTaskCmd.Flags().BoolVar(&runTaskInsecure, "insecure", false, "Accept unsigned requests when no HMAC key is set (for local testing only)")
+	runTaskCmd.Flags().StringVar(&runTaskHostname, "hostname", runtask.DefaultHostname, "Host of HCP Terraform or Terraform Enterprise that plans are downloaded from and results reported to")
+	runTaskCmd.Flags().StringVar(&runTaskPublicURL, "public-url", "", "URL HCP Terraform users reach the server at, to link the detailed results (required)")
+	runTaskCmd.Flags().StringVarP(&policyFile, "policy", "p", "", "Existing IAM policy JSON file to check plans against")
+	runTaskCmd.Flags().StringVarP(&policyDir, "policy-dir", "d", "", "Directory with IaC IAM policy definitions to check plans against")
+	runTaskCmd.Flags().StringVar(&policyARN, "policy-arn", "", "ARN of a managed IAM policy to check plans against")
+	runTaskCmd.Flags().StringVar(&failOn, "fail-on", "missing,excessive", "Comma-separated findings that fail the run: missing, excessive, broad, any, none")
+	runTaskCmd.MarkFlagsMutuallyExclusive("policy", "policy-dir", "policy-arn")
+	_ = runTaskCmd.MarkFlagRequired("public-url")
+}
+
+func runRunTask(cmd *cobra.Command, args []string) error {
+	failClasses, err := parseFailOn(failOn)
+	if err != nil {
+		return err
+	}
+	if policyFile == stdinPath {
+		return fmt.Errorf("--policy cannot be read from stdin by a run task")
+	}
+	if runTaskHMACKey == "" {
+		runTaskHMACKey = os.Getenv("LEAST_RUN_TASK_HMAC_KEY")
+	}
+	if runTaskHMACKey == "" {
+		if !runTaskInsecure {
+			return fmt.Errorf("--hmac-key or $LEAST_RUN_TASK_HMAC_KEY is required to verify requests (use --insecure to accept unsigned ones)")
+		}
+		fmt.Fprintln(os.Stderr, "Warning: no --hmac-key; requests are not verified")
+	}
+
+	// Evaluations share the policy loading state of the command
+	var mu sync.Mutex
+	runMetrics := metrics.New()
+	server := &runtask.Server{
+		HMACKey:   runTaskHMACKey,
+		Insecure:  runTaskInsecure,
+		Hostname:  runTaskHostname,
+		PublicURL: runTaskPublicURL,
+		Timeout:   10 * time.Minute,
+		Evaluate: func(ctx context.Context, req runtask.Request, plan []byte) (runtask.Result, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			result, err := evaluatePlan(ctx, req, plan, failClasses, runMetrics)
+			switch {
+			case err != nil:
+				runMetrics.ObserveRun(metrics.StatusError)
+			case result.Passed:
+				runMetrics.ObserveRun(metrics.StatusPassed)
+			default:
+				runMetrics.ObserveRun(metrics.StatusFailed)
+			}
+			return result, err
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", server.Handler())
+	mux.Handle("GET /metrics", runMetrics.Handler())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	httpServer := &http.Server{Addr: runTaskListen, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving run task on %s\n", runTaskListen)
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	server.Wait()
+	return nil
+}
+
+// evaluatePlan generates the policy a plan requires and checks the existing
+// policy against it, recording the results in the metrics of the workspace
+func evaluatePlan(ctx context.Context, req runtask.Request, plan []byte, failClasses map[string]bool, m *metrics.Metrics) (runtask.Result, error) {
+	project := req.OrganizationName + "/" + req.WorkspaceName
+	source := fmt.Sprintf("%s run %s", project, req.RunID)
+	fmt.Fprintf(os.Stderr, "Evaluating plan of %s\n", source)
+
+	start := time.Now()
+	result, err := terraform.ParsePlan(source, plan)
+	if err != nil {
+		return runtask.Result{}, err
+	}
+	required, err := policy.New().Generate(result.Resources)
+	if err != nil {
+		return runtask.Result{}, fmt.Errorf("generating required policy: %w", err)
+	}
+	m.ObserveParse(time.Since(start), len(result.Resources))
+
+	if policyFile == "" && policyDir == "" && policyARN == "" {
+		document, err := required.ToJSON()
+		if err != nil {
+			return runtask.Result{}, err
+		}
+		return runtask.Result{
+			Passed:  true,
+			Message: fmt.Sprintf("%s required by %s", plural(len(required.GetAllActions()), "action"), plural(len(result.Resources), "resource")),
+			Details: "### Policy required by " + source + "\n\n```json\n" + document + "\n```\n",
+		}, nil
+	}
+
+	var existing *policy.IAMPolicy
+	policySource := policyFile
+	switch {
+	case policyARN != "":
+		policySource = policyARN
+		existing, err = loadPolicyARN(ctx, policyARN)
+	case policyDir != "":
+		policySource = policyDir
+		existing, err = loadPolicyDir(ctx, policyDir)
+	default:
+		existing, err = loadPolicyFile(policyFile)
+	}
+	if err != nil {
+		return runtask.Result{}, err
+	}
+
+	checkResult := checker.Check(existing, required)
+	broadGrants := findBroadGrants(existing)
+	m.SetFindings(project, len(checkResult.Missing), len(checkResult.Excessive), len(broadGrants))
+	report := prcomment.Report{
+		Path:         source,
+		PolicySource: policySource,
+		Required:     required,
+		Result:       checkResult,
+		BroadGrants:  broadGrants,
+	}
+
+	message := "Policy is compliant with least-privilege requirements"
+	if !checkResult.IsCompliant() || len(broadGrants) > 0 {
+		message = fmt.Sprintf("%d missing, %d excessive, %d broad",
+			len(checkResult.Missing), len(checkResult.Excessive), len(broadGrants))
+	}
+	return runtask.Result{
+		Passed:  checkExitCode(failClasses, checkResult, broadGrants) == 0,
+		Message: message,
+		Details: report.Markdown(),
+	}, nil
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/mapping"
+	"github.com/mizzy/least/internal/offline"
+	"github.com/mizzy/least/internal/schema"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Manage the CloudFormation schema cache",
+	Long: `Manage the cache of CloudFormation resource schemas used to resolve
+permissions for resource types without a built-in mapping.
+
+The cache directory is $LEAST_SCHEMA_CACHE, or least/schemas under the user
+cache directory.`,
+}
+
+var schemaFetchCmd = &cobra.Command{
+	Use:   "fetch <type>...",
+	Short: "Fetch schemas for CloudFormation or Terraform resource types",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runSchemaFetch,
+}
+
+var schemaSyncCmd = &cobra.Command{
+	Use:   "sync [path]",
+	Short: "Fetch schemas for resource types used in IaC files",
+	Long: `Fetch schemas for the resource types used in IaC files that have no built-in
+mapping. Types covered by the fallback mappings or the schema bundle embedded in
+the binary are resolved offline and skipped unless --force is given.
+
+With --all, download the zip of all resource provider schemas AWS publishes for
+the region and unpack it into the cache in one request.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSchemaSync,
+}
+
+var (
+	forceSync    bool
+	syncAll      bool
+	syncRegion   string
+	fetchWorkers int
+	fetchRetries int
+)
+
+var schemaListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List cached schemas",
+	Args:  cobra.NoArgs,
+	RunE:  runSchemaList,
+}
+
+var schemaClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all cached schemas",
+	Args:  cobra.NoArgs,
+	RunE:  runSchemaClear,
+}
+
+// schemaStore is the on-disk schema cache shared by all commands
+var schemaStore *schema.Store
+
+var (
+	offlineMode    bool
+	refreshSchemas bool
+	schemaTTLValue string
+	schemaTTL      time.Duration
+
+	// refreshed records the schemas already re-fetched in this run
+	refreshed   = make(map[string]bool)
+	refreshedMu sync.Mutex
+)
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+	schemaCmd.AddCommand(schemaFetchCmd, schemaSyncCmd, schemaListCmd, schemaClearCmd)
+
+	schemaSyncCmd.Flags().BoolVar(&forceSync, "force", false, "Also fetch schemas for types with built-in mappings")
+	schemaSyncCmd.Flags().BoolVar(&syncAll, "all", false, "Download the schemas of all resource types from the regional schema bundle")
+	schemaSyncCmd.Flags().StringVar(&syncRegion, "region", "", "Region of the schema bundle (default: the region of the AWS configuration, or us-east-1)")
+	for _, c := range []*cobra.Command{schemaFetchCmd, schemaSyncCmd} {
+		c.Flags().IntVar(&fetchWorkers, "concurrency", schema.DefaultWorkers, "Number of schemas fetched at once")
+		c.Flags().IntVar(&fetchRetries, "retries", 3, "Number of times a failed fetch is retried, with exponential backoff")
+	}
+
+	rootCmd.PersistentFlags().BoolVar(&offlineMode, "offline", false, "Never call AWS or the AWS CLI; resolve permissions from built-in mappings, the embedded schema bundle and cached schemas only")
+	rootCmd.PersistentFlags().BoolVar(&refreshSchemas, "refresh-schemas", false, "Re-fetch the schemas of resource types resolved from the schema cache")
+	rootCmd.PersistentFlags().StringVar(&schemaTTLValue, "schema-ttl", "30d", "Age after which cached schemas are reported as stale (e.g., 30d, 720h, 0 to never expire)")
+
+	schemaStore = schema.NewStore(schema.DefaultCacheDir())
+	mapping.SetResolvers(resolveFromSchemaCache)
+}
+
+// setupSchemas applies --offline and --schema-ttl
+func setupSchemas() error {
+	if offlineMode && refreshSchemas {
+		return fmt.Errorf("--refresh-schemas cannot be used with --offline")
+	}
+	offline.Set(offlineMode)
+
+	schemaTTL = 0
+	if schemaTTLValue != "0" {
+		ttl, err := parseDuration("--schema-ttl", schemaTTLValue)
+		if err != nil {
+			return err
+		}
+		schemaTTL = ttl
+	}
+	return nil
+}
+
+// refreshSchema re-fetches the schema of a resource type once per run when
+// --refresh-schemas is given. Cached schemas older than --schema-ttl are only
+// reported, so that commands never call AWS unless asked to. If fetching
+// fails, the cached schema keeps being used.
+func refreshSchema(cfnType string) {
+	if offline.Enabled() || !(refreshSchemas || schemaStore.Stale(cfnType, schemaTTL)) {
+		return
+	}
+
+	refreshedMu.Lock()
+	defer refreshedMu.Unlock()
+	if refreshed[cfnType] {
+		return
+	}
+	refreshed[cfnType] = true
+
+	if !refreshSchemas {
+		fmt.Fprintf(os.Stderr, "Warning: cached schema %s is older than --schema-ttl; use --refresh-schemas or least schema fetch to update it\n", cfnType)
+		return
+	}
+	if _, err := schema.NewFetcher(schemaStore).FetchSchema(context.Background(), cfnType); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: refreshing schema %s: %v\n", cfnType, err)
+	}
+}
+
+// resolveFromSchemaCache builds a mapping from the schema bundle embedded in
+// the binary or, for CloudFormation types newer than the binary, a cached
+// schema. Mappings of CloudFormation types guessed from the Terraform type
+// name are marked as heuristic.
+func resolveFromSchemaCache(tfType string) (mapping.ResourceMapping, mapping.Provenance, bool) {
+	cfnType := schema.TerraformToCfnType(tfType)
+	if cfnType == "" {
+		return mapping.ResourceMapping{}, mapping.Provenance{}, false
+	}
+
+	provenance := mapping.Provenance{Kind: mapping.ProvenanceSchema, CfnType: cfnType}
+	if !schema.IsExplicitMapping(tfType) {
+		provenance.Kind = mapping.ProvenanceHeuristic
+	}
+	if m, ok := mapping.BundledSchema(cfnType); ok {
+		return m, provenance, true
+	}
+
+	refreshSchema(cfnType)
+	perms, err := schemaStore.GetPermissions(cfnType)
+	if err != nil {
+		return mapping.ResourceMapping{}, mapping.Provenance{}, false
+	}
+	return mapping.ResourceMapping{
+		Create: perms.Create,
+		Read:   perms.Read,
+		Update: perms.Update,
+		Delete: perms.Delete,
+	}, provenance, true
+}
+
+func runSchemaFetch(cmd *cobra.Command, args []string) error {
+	if offline.Enabled() {
+		return fmt.Errorf("fetching schemas: %w", offline.ErrOffline)
+	}
+
+	var cfnTypes []string
+	for _, t := range args {
+		cfnType := t
+		if schema.CfnToTerraformType(t) == "" {
+			// Not a CloudFormation type; treat it as a Terraform type
+			cfnType = schema.TerraformToCfnType(t)
+			if cfnType == "" {
+				return fmt.Errorf("no CloudFormation type for %s", t)
+			}
+		}
+		cfnTypes = append(cfnTypes, cfnType)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	failed, err := fetchSchemas(ctx, cfnTypes)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to fetch %s", plural(failed, "schema"))
+	}
+	return nil
+}
+
+func runSchemaSync(cmd *cobra.Command, args []string) error {
+	if offline.Enabled() {
+		return fmt.Errorf("syncing schemas: %w", offline.ErrOffline)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if syncAll {
+		if len(args) > 0 {
+			return fmt.Errorf("--all does not take a path")
+		}
+		return syncSchemaBundle(ctx)
+	}
+
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	p, err := getProvider(path)
+	if err != nil {
+		return err
+	}
+
+	result, err := p.Parse(ctx, path)
+	if err != nil {
+		return fmt.Errorf("parsing files: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var cfnTypes []string
+	builtin := 0
+	for _, res := range result.Resources {
+		if res.CloudProvider != "aws" {
+			continue
+		}
+		cfnType := schema.TerraformToCfnType(res.Type)
+		if cfnType == "" || seen[cfnType] {
+			continue
+		}
+		seen[cfnType] = true
+		if _, bundled := mapping.BundledSchema(cfnType); !forceSync && (bundled || mapping.IsBuiltin(res.Type)) {
+			builtin++
+			continue
+		}
+		cfnTypes = append(cfnTypes, cfnType)
+	}
+	sort.Strings(cfnTypes)
+
+	if builtin > 0 {
+		fmt.Fprintf(os.Stderr, "Skipping %s with built-in mappings (use --force to fetch them)\n", plural(builtin, "resource type"))
+	}
+
+	fmt.Fprintf(os.Stderr, "Syncing %s used in: %s\n", plural(len(cfnTypes), "resource type"), path)
+	failed, err := fetchSchemas(ctx, cfnTypes)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Fetched %d of %s into %s\n", len(cfnTypes)-failed, plural(len(cfnTypes), "schema"), schemaStore.CacheDir())
+	if failed > 0 {
+		return fmt.Errorf("failed to sync %s", plural(failed, "schema"))
+	}
+	return nil
+}
+
+// syncSchemaBundle downloads the regional schema bundle into the cache. If
+// the download fails, schemas are fetched one by one for the types listed by
+// the CloudFormation Registry.
+func syncSchemaBundle(ctx context.Context) error {
+	region := syncRegion
+	if region == "" {
+		region = schema.Region(ctx)
+	}
+
+	url := schema.BundleURL(region)
+	fmt.Fprintf(os.Stderr, "Downloading schema bundle: %s\n", url)
+	saved, err := schemaStore.DownloadBundle(ctx, url)
+	if err == nil {
+		fmt.Printf("Saved %s into %s\n", plural(saved, "schema"), schemaStore.CacheDir())
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %v; fetching schemas individually\n", err)
+
+	cfnTypes, err := schema.NewFetcher(schemaStore).ListTypes(ctx)
+	if err != nil {
+		return fmt.Errorf("listing resource types: %w", err)
+	}
+	failed, err := fetchSchemas(ctx, cfnTypes)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Fetched %d of %s into %s\n", len(cfnTypes)-failed, plural(len(cfnTypes), "schema"), schemaStore.CacheDir())
+	if failed > 0 {
+		return fmt.Errorf("failed to sync %s", plural(failed, "schema"))
+	}
+	return nil
+}
+
+// fetchSchemas fetches schemas into the cache with --concurrency workers,
+// reporting progress and failures, and returns the number of failures. It
+// fails if ctx is canceled before all are fetched.
+func fetchSchemas(ctx context.Context, cfnTypes []string) (int, error) {
+	if fetchWorkers < 1 {
+		return 0, fmt.Errorf("--concurrency must be at least 1")
+	}
+	if fetchRetries < 0 {
+		return 0, fmt.Errorf("--retries must not be negative")
+	}
+
+	// Failures are reported and counted as they happen
+	failed := 0
+	results, _ := schema.NewFetcher(schemaStore).FetchMultiple(ctx, cfnTypes, schema.FetchOptions{
+		Workers: fetchWorkers,
+		Retries: fetchRetries,
+		Backoff: time.Second,
+		Progress: func(p schema.Progress) {
+			if p.Err != nil {
+				fmt.Fprintf(os.Stderr, "[%d/%d] Warning: %s: %v\n", p.Done, p.Total, p.Type, p.Err)
+				failed++
+				return
+			}
+			perms, _ := schemaStore.GetPermissions(p.Schema.TypeName)
+			fmt.Fprintf(os.Stderr, "[%d/%d] Fetched %s (%s)\n", p.Done, p.Total, p.Type, plural(len(perms.All), "permission"))
+		},
+	})
+	if err := ctx.Err(); err != nil {
+		return failed, fmt.Errorf("fetching schemas: interrupted after %d of %d: %w", len(results)+failed, len(cfnTypes), err)
+	}
+	return failed, nil
+}
+
+func runSchemaList(cmd *cobra.Command, args []string) error {
+	types, err := schemaStore.ListCachedTypes()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Schema cache: %s\n", schemaStore.CacheDir())
+	fmt.Printf("Embedded schema bundle: %s\n", plural(mapping.BundledTypes(), "resource type"))
+	if len(types) == 0 {
+		fmt.Println("No cached schemas")
+		return nil
+	}
+
+	for _, t := range types {
+		fetched := ""
+		if at, ok := schemaStore.FetchedAt(t); ok {
+			fetched = "fetched " + at.Format("2006-01-02")
+			if schemaStore.Stale(t, schemaTTL) {
+				fetched += " (stale)"
+			}
+		}
+		fmt.Printf("  %-45s %-45s %s\n", t, schema.CfnToTerraformType(t), fetched)
+	}
+	fmt.Printf("%s cached\n", plural(len(types), "schema"))
+	return nil
+}
+
+func runSchemaClear(cmd *cobra.Command, args []string) error {
+	removed, err := schemaStore.ClearCache()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removed %s from %s\n", plural(removed, "cached schema"), schemaStore.CacheDir())
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/stats"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [path]",
+	Short: "Print aggregate least-privilege statistics",
+	Long: `Print statistics for the IaC files in path: resources by type, distinct
+actions, services touched, resources scoped to specific ARNs versus wildcards,
+and the size of the generated policy. Use --json to track them over time.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runStats,
+}
+
+// managedPolicyLimit is the maximum size of a managed policy document in bytes
+const managedPolicyLimit = 6144
+
+var statsJSON bool
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "Output statistics as JSON")
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	p, err := getProvider(path)
+	if err != nil {
+		return err
+	}
+
+	result, err := p.Parse(context.Background(), path)
+	if err != nil {
+		return fmt.Errorf("parsing files: %w", err)
+	}
+
+	generated, err := policy.New().Generate(result.Resources)
+	if err != nil {
+		return fmt.Errorf("generating policy: %w", err)
+	}
+
+	s, err := stats.Compute(result.Resources, generated)
+	if err != nil {
+		return err
+	}
+
+	if statsJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	}
+
+	fmt.Printf("Resources:      %d\n", s.Resources)
+	for _, tc := range s.ByType {
+		fmt.Printf("  %-40s %d\n", tc.Type, tc.Count)
+	}
+	fmt.Printf("Actions:        %d\n", s.Actions)
+	fmt.Printf("Services:       %d (%s)\n", len(s.Services), strings.Join(s.Services, ", "))
+	fmt.Printf("Specific ARNs:  %d of %s (%.1f%%), %d with Resource \"*\"\n",
+		s.SpecificARNs, plural(s.SpecificARNs+s.WildcardARNs, "mapped resource"), s.SpecificPercent(), s.WildcardARNs)
+	fmt.Printf("Policy size:    %d bytes (%.1f%% of the %d byte managed policy limit)\n",
+		s.PolicyBytes, float64(s.PolicyBytes)*100/managedPolicyLimit, managedPolicyLimit)
+	return nil
+}
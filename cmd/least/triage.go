@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mizzy/least/internal/baseline"
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/policy"
+)
+
+// interactive makes check walk through the findings, accepting them into
+// the baseline, queueing them for a fixed policy, or skipping them
+var interactive bool
+
+// triageResult is the outcome of walking through the findings of a check
+type triageResult struct {
+	// Accepted are the findings accepted into the baseline
+	Accepted baseline.Baseline
+	// Fixes are the findings queued for the fixed policy
+	Fixes checker.Result
+}
+
+// triage asks, for each missing then excessive finding, whether to accept it
+// into the baseline (with an optional justification), queue it for the fixed
+// policy, or skip it. Answering q skips the remaining findings.
+func triage(in io.Reader, out io.Writer, result *checker.Result, required *policy.IAMPolicy) (*triageResult, error) {
+	total := len(result.Missing) + len(result.Excessive)
+	t := &triageResult{Fixes: checker.Result{Matched: result.Matched}}
+	scanner := bufio.NewScanner(in)
+	ask := func(prompt string) (string, bool) {
+		fmt.Fprint(out, prompt)
+		if !scanner.Scan() {
+			return "", false
+		}
+		return strings.TrimSpace(scanner.Text()), true
+	}
+
+	n := 0
+	for _, kind := range []string{"missing", "excessive"} {
+		findings := result.Missing
+		if kind == "excessive" {
+			findings = result.Excessive
+		}
+		for _, action := range findings {
+			n++
+			fmt.Fprintf(out, "\n[%d/%d] %s: %s", n, total, kind, action)
+			if kind == "missing" {
+				fmt.Fprint(out, describeSources(required.SourcesOf(action)))
+				if slices.Contains(result.Blocked, action) {
+					fmt.Fprint(out, " [blocked by the permissions boundary]")
+				}
+			}
+			fmt.Fprintln(out)
+
+			answer, ok := choose(ask)
+			if !ok {
+				return t, scanner.Err()
+			}
+			switch answer {
+			case "a":
+				justification, _ := ask("Justification (optional): ")
+				entry := baseline.Entry{Action: action, Justification: justification}
+				if kind == "missing" {
+					t.Accepted.Missing = append(t.Accepted.Missing, entry)
+				} else {
+					t.Accepted.Excessive = append(t.Accepted.Excessive, entry)
+				}
+			case "f":
+				if kind == "missing" {
+					t.Fixes.Missing = append(t.Fixes.Missing, action)
+				} else {
+					t.Fixes.Excessive = append(t.Fixes.Excessive, action)
+				}
+			case "q":
+				return t, nil
+			}
+		}
+	}
+	return t, nil
+}
+
+// choose asks until the answer is one of a(ccept), f(ix), s(kip) or q(uit),
+// and returns its letter; an empty answer skips
+func choose(ask func(string) (string, bool)) (string, bool) {
+	for {
+		answer, ok := ask("Accept into the baseline, fix, skip, or quit? [a/f/s/q] ")
+		if !ok {
+			return "", false
+		}
+		switch strings.ToLower(answer) {
+		case "a", "accept":
+			return "a", true
+		case "f", "fix":
+			return "f", true
+		case "s", "skip", "":
+			return "s", true
+		case "q", "quit":
+			return "q", true
+		}
+	}
+}
+
+// runTriage walks through the findings on the terminal, appends the accepted
+// ones to the baseline file and drops them from the result. It returns the
+// remaining findings, and the findings queued for the fixed policy or nil.
+func runTriage(result *checker.Result, required *policy.IAMPolicy) (*checker.Result, *checker.Result, error) {
+	if result.IsCompliant() {
+		return result, nil, nil
+	}
+	fmt.Fprintf(os.Stderr, "Triaging %d missing and %d excessive findings\n", len(result.Missing), len(result.Excessive))
+	t, err := triage(os.Stdin, os.Stderr, result, required)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading answers: %w", err)
+	}
+	fmt.Fprintln(os.Stderr)
+
+	if accepted := len(t.Accepted.Missing) + len(t.Accepted.Excessive); accepted > 0 {
+		b := &baseline.Baseline{}
+		if _, err := os.Stat(baselineFile); err == nil {
+			if b, err = baseline.Load(baselineFile); err != nil {
+				return nil, nil, err
+			}
+		}
+		b.Missing = append(b.Missing, t.Accepted.Missing...)
+		b.Excessive = append(b.Excessive, t.Accepted.Excessive...)
+		if err := b.Save(baselineFile); err != nil {
+			return nil, nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Accepted %s into baseline: %s\n", plural(accepted, "finding"), baselineFile)
+		result = t.Accepted.Apply(result, time.Now()).Result
+	}
+
+	if len(t.Fixes.Missing)+len(t.Fixes.Excessive) == 0 {
+		return result, nil, nil
+	}
+	fmt.Fprintf(os.Stderr, "Queued %s for the fixed policy\n", plural(len(t.Fixes.Missing)+len(t.Fixes.Excessive), "finding"))
+	return result, &t.Fixes, nil
+}
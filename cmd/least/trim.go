@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/diff"
+	"github.com/mizzy/least/internal/policy"
+)
+
+var trimCmd = &cobra.Command{
+	Use:   "trim [path]",
+	Short: "Minimize an existing IAM policy to what IaC files require",
+	Long: `Remove the actions of an existing policy that the IaC files do not require.
+
+Statement order, Sids, resources and conditions are preserved and statements
+left without actions are dropped. Unlike check --fix, missing permissions are
+reported but not added.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runTrim,
+}
+
+var (
+	trimPolicyFile string
+	trimPolicyARN  string
+	trimOutput     string
+	trimDiff       bool
+)
+
+func init() {
+	rootCmd.AddCommand(trimCmd)
+
+	trimCmd.Flags().StringVarP(&trimPolicyFile, "policy", "p", "", "Existing IAM policy JSON file")
+	trimCmd.Flags().StringVar(&trimPolicyARN, "policy-arn", "", "ARN of a managed IAM policy to fetch from AWS")
+	trimCmd.Flags().StringVarP(&trimOutput, "output", "o", "", "Output file (default: stdout)")
+	trimCmd.Flags().BoolVar(&trimDiff, "diff", false, "Print a unified diff instead of the trimmed policy")
+	trimCmd.MarkFlagsOneRequired("policy", "policy-arn")
+	trimCmd.MarkFlagsMutuallyExclusive("policy", "policy-arn")
+}
+
+func runTrim(cmd *cobra.Command, args []string) error {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	ctx := context.Background()
+	required, err := generateFromPath(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	var existing *policy.IAMPolicy
+	source := trimPolicyFile
+	if trimPolicyARN != "" {
+		source = trimPolicyARN
+		existing, err = loadPolicyARN(ctx, trimPolicyARN)
+	} else {
+		existing, err = loadPolicyFile(trimPolicyFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	trimmed, result := checker.Trim(existing, required)
+
+	fmt.Fprintf(os.Stderr, "Removed %s\n", plural(len(result.Excessive), "action"))
+	for _, action := range result.Excessive {
+		fmt.Fprintf(os.Stderr, "  - %s\n", action)
+	}
+	if len(result.Missing) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: the trimmed policy is missing %s required by %s:\n", plural(len(result.Missing), "action"), path)
+		for _, action := range result.Missing {
+			fmt.Fprintf(os.Stderr, "  + %s\n", action)
+		}
+	}
+
+	output, err := trimmed.ToJSON()
+	if err != nil {
+		return fmt.Errorf("converting trimmed policy to JSON: %w", err)
+	}
+
+	if trimDiff {
+		before, err := existing.ToJSON()
+		if err != nil {
+			return fmt.Errorf("converting existing policy to JSON: %w", err)
+		}
+		fmt.Print(diff.Unified(source, source+" (trimmed)", before+"\n", output+"\n"))
+		return nil
+	}
+
+	if trimOutput == "" {
+		fmt.Println(output)
+		return nil
+	}
+
+	if err := os.WriteFile(trimOutput, []byte(output+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing trimmed policy: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Trimmed policy written to: %s\n", trimOutput)
+	return nil
+}
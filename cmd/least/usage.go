@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/cloudtrail"
+)
+
+// parseWindow parses a look-back duration such as 90d or 720h
+func parseWindow(value string) (time.Duration, error) {
+	return parseDuration("--usage-window", value)
+}
+
+// parseDuration parses a positive duration in days (30d) or Go syntax (720h)
+// given for flag
+func parseDuration(flag, value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid %s value: %s", flag, value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s value: %s", flag, value)
+	}
+	return d, nil
+}
+
+// reportUnusedActions prints the actions that are granted and required by IaC
+// but were never used by the role according to CloudTrail
+func reportUnusedActions(ctx context.Context, result *checker.Result) error {
+	d, err := parseWindow(usageWindow)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	window := cloudtrail.Window{Start: now.Add(-d), End: now}
+
+	var events []cloudtrail.Event
+	if cloudtrailLake != "" {
+		fmt.Fprintf(os.Stderr, "Querying CloudTrail Lake event data store: %s\n", cloudtrailLake)
+		events, err = cloudtrail.QueryLake(ctx, cloudtrailLake, roleARN, window)
+	} else {
+		fmt.Fprintf(os.Stderr, "Reading CloudTrail archive: %s\n", cloudtrailArchive)
+		events, err = cloudtrail.ReadArchive(ctx, cloudtrailArchive, window)
+	}
+	if err != nil {
+		return err
+	}
+
+	unused := cloudtrail.Unused(result.Matched, cloudtrail.UsedActions(events, roleARN))
+
+	fmt.Println()
+	if len(unused) == 0 {
+		fmt.Printf("✓ All granted and required permissions were used in the last %s\n", usageWindow)
+		return nil
+	}
+
+	fmt.Printf("⚠ Unused permissions (granted and required, but not used by %s in the last %s):\n", roleARN, usageWindow)
+	for _, group := range checker.GroupByService(unused) {
+		fmt.Printf("  %s (%d):\n", group.Service, len(group.Actions))
+		for _, action := range group.Actions {
+			fmt.Printf("    ~ %s\n", action)
+		}
+	}
+	return nil
+}
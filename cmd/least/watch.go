@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long to wait for more changes before regenerating,
+// so that editors saving several files at once trigger a single run
+const watchDebounce = 300 * time.Millisecond
+
+// watchExtensions are the file extensions that trigger regeneration
+var watchExtensions = []string{".tf", ".tf.json", ".tfvars"}
+
+// watchAndGenerate regenerates the policies whenever IaC files under paths change
+func watchAndGenerate(paths []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("creating file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	outputs := make(map[string]bool)
+	for _, path := range paths {
+		if err := addWatchDirs(watcher, path); err != nil {
+			return err
+		}
+
+		output := outputFile
+		if splitBy != "" {
+			output = splitOutput(path)
+		}
+		abs, err := filepath.Abs(output)
+		if err != nil {
+			return fmt.Errorf("resolving output path: %w", err)
+		}
+		outputs[abs] = true
+	}
+
+	regenerate := func() {
+		if err := generatePolicies(paths); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		fmt.Fprintf(os.Stderr, "Watching %s for changes (Ctrl+C to stop)\n", strings.Join(paths, ", "))
+	}
+	regenerate()
+
+	var timer *time.Timer
+	var fire <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			fmt.Fprintf(os.Stderr, "Warning: watch error: %v\n", err)
+		case event := <-watcher.Events:
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					_ = addWatchDirs(watcher, event.Name)
+					continue
+				}
+			}
+			if abs, _ := filepath.Abs(event.Name); outputs[abs] || !isWatchedFile(event.Name) {
+				continue
+			}
+			if timer == nil {
+				timer = time.NewTimer(watchDebounce)
+			} else {
+				timer.Reset(watchDebounce)
+			}
+			fire = timer.C
+		case <-fire:
+			fire = nil
+			fmt.Fprintln(os.Stderr, "Change detected, regenerating...")
+			regenerate()
+		}
+	}
+}
+
+// addWatchDirs watches root and its subdirectories, skipping hidden
+// directories such as .terraform and .git
+func addWatchDirs(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("watching %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// isWatchedFile checks if a changed file should trigger regeneration
+func isWatchedFile(name string) bool {
+	for _, ext := range watchExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+// Package accessadvisor fetches IAM Access Advisor (service last accessed) data
+package accessadvisor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/offline"
+)
+
+// ServiceAccess is the last-accessed information for a service namespace
+type ServiceAccess struct {
+	Namespace         string
+	Name              string
+	LastAuthenticated *time.Time
+}
+
+// Describe returns a short description of when the service was last used
+func (s ServiceAccess) Describe(now time.Time) string {
+	if s.LastAuthenticated == nil {
+		return "never used"
+	}
+	days := int(now.Sub(*s.LastAuthenticated).Hours() / 24)
+	return fmt.Sprintf("last used %s, %d days ago", s.LastAuthenticated.Format("2006-01-02"), days)
+}
+
+// Report maps service namespaces to their last-accessed information
+type Report map[string]ServiceAccess
+
+// API is the subset of the IAM client used to fetch last-accessed data
+type API interface {
+	GenerateServiceLastAccessedDetails(ctx context.Context, params *iam.GenerateServiceLastAccessedDetailsInput, optFns ...func(*iam.Options)) (*iam.GenerateServiceLastAccessedDetailsOutput, error)
+	GetServiceLastAccessedDetails(ctx context.Context, params *iam.GetServiceLastAccessedDetailsInput, optFns ...func(*iam.Options)) (*iam.GetServiceLastAccessedDetailsOutput, error)
+}
+
+// pollInterval is the delay between job status checks
+const pollInterval = 2 * time.Second
+
+// Fetch generates and retrieves the service last accessed details for an IAM entity
+func Fetch(ctx context.Context, arn string) (Report, error) {
+	if offline.Enabled() {
+		return nil, offline.ErrOffline
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+	return FetchWithAPI(ctx, iam.NewFromConfig(cfg), arn)
+}
+
+// FetchWithAPI retrieves the service last accessed details using the given client
+func FetchWithAPI(ctx context.Context, api API, arn string) (Report, error) {
+	job, err := api.GenerateServiceLastAccessedDetails(ctx, &iam.GenerateServiceLastAccessedDetailsInput{
+		Arn: aws.String(arn),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("generating service last accessed details: %w", err)
+	}
+
+	report := make(Report)
+	var marker *string
+	for {
+		details, err := api.GetServiceLastAccessedDetails(ctx, &iam.GetServiceLastAccessedDetailsInput{
+			JobId:  job.JobId,
+			Marker: marker,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("getting service last accessed details: %w", err)
+		}
+
+		switch details.JobStatus {
+		case types.JobStatusTypeInProgress:
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(pollInterval):
+			}
+			continue
+		case types.JobStatusTypeCompleted:
+		default:
+			message := "job failed"
+			if details.Error != nil {
+				message = aws.ToString(details.Error.Message)
+			}
+			return nil, fmt.Errorf("access advisor job %s: %s", aws.ToString(job.JobId), message)
+		}
+
+		for _, s := range details.ServicesLastAccessed {
+			report[aws.ToString(s.ServiceNamespace)] = ServiceAccess{
+				Namespace:         aws.ToString(s.ServiceNamespace),
+				Name:              aws.ToString(s.ServiceName),
+				LastAuthenticated: s.LastAuthenticated,
+			}
+		}
+
+		if !details.IsTruncated {
+			return report, nil
+		}
+		marker = details.Marker
+	}
+}
+
+// SortGroups orders service groups so the best removal candidates come first:
+// never-used services, then the least recently used, then services without data
+func SortGroups(groups []checker.ServiceGroup, report Report) {
+	rank := func(service string) (int, time.Time) {
+		s, ok := report[service]
+		switch {
+		case !ok:
+			return 2, time.Time{}
+		case s.LastAuthenticated == nil:
+			return 0, time.Time{}
+		default:
+			return 1, *s.LastAuthenticated
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		ri, ti := rank(groups[i].Service)
+		rj, tj := rank(groups[j].Service)
+		if ri != rj {
+			return ri < rj
+		}
+		return ti.Before(tj)
+	})
+}
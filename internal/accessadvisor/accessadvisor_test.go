@@ -0,0 +1,88 @@
+package accessadvisor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+
+	"github.com/mizzy/least/internal/checker"
+)
+
+type fakeIAM struct {
+	pages []*iam.GetServiceLastAccessedDetailsOutput
+}
+
+func (f *fakeIAM) GenerateServiceLastAccessedDetails(ctx context.Context, params *iam.GenerateServiceLastAccessedDetailsInput, optFns ...func(*iam.Options)) (*iam.GenerateServiceLastAccessedDetailsOutput, error) {
+	return &iam.GenerateServiceLastAccessedDetailsOutput{JobId: aws.String("job-1")}, nil
+}
+
+func (f *fakeIAM) GetServiceLastAccessedDetails(ctx context.Context, params *iam.GetServiceLastAccessedDetailsInput, optFns ...func(*iam.Options)) (*iam.GetServiceLastAccessedDetailsOutput, error) {
+	page := f.pages[0]
+	f.pages = f.pages[1:]
+	return page, nil
+}
+
+func lastAccessed(namespace string, last *time.Time) types.ServiceLastAccessed {
+	return types.ServiceLastAccessed{ServiceName: aws.String(namespace), ServiceNamespace: aws.String(namespace), LastAuthenticated: last}
+}
+
+func TestFetchAndSortGroups(t *testing.T) {
+	s3Used := time.Date(2026, 1, 10, 8, 0, 0, 0, time.UTC)
+	sqsUsed := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	api := &fakeIAM{pages: []*iam.GetServiceLastAccessedDetailsOutput{
+		{
+			JobStatus:            types.JobStatusTypeCompleted,
+			ServicesLastAccessed: []types.ServiceLastAccessed{lastAccessed("s3", &s3Used), lastAccessed("iam", nil)},
+			IsTruncated:          true,
+			Marker:               aws.String("next"),
+		},
+		{
+			JobStatus:            types.JobStatusTypeCompleted,
+			ServicesLastAccessed: []types.ServiceLastAccessed{lastAccessed("sqs", &sqsUsed)},
+		},
+	}}
+
+	report, err := FetchWithAPI(context.Background(), api, "arn:aws:iam::123456789012:role/deploy")
+	if err != nil {
+		t.Fatalf("FetchWithAPI() error = %v", err)
+	}
+	if len(report) != 3 {
+		t.Fatalf("got %d services, want 3", len(report))
+	}
+
+	now := time.Date(2026, 2, 9, 8, 0, 0, 0, time.UTC)
+	if got := report["s3"].Describe(now); got != "last used 2026-01-10, 30 days ago" {
+		t.Errorf("Describe(s3) = %q", got)
+	}
+	if got := report["iam"].Describe(now); got != "never used" {
+		t.Errorf("Describe(iam) = %q", got)
+	}
+
+	groups := []checker.ServiceGroup{{Service: "ec2"}, {Service: "s3"}, {Service: "sqs"}, {Service: "iam"}}
+	SortGroups(groups, report)
+
+	var got []string
+	for _, g := range groups {
+		got = append(got, g.Service)
+	}
+	want := []string{"iam", "sqs", "s3", "ec2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SortGroups() = %v, want %v", got, want)
+	}
+}
+
+func TestFetchFailedJob(t *testing.T) {
+	api := &fakeIAM{pages: []*iam.GetServiceLastAccessedDetailsOutput{{
+		JobStatus: types.JobStatusTypeFailed,
+		Error:     &types.ErrorDetails{Code: aws.String("InvalidInput"), Message: aws.String("entity not found")},
+	}}}
+	_, err := FetchWithAPI(context.Background(), api, "arn:aws:iam::123456789012:role/missing")
+	if err == nil || err.Error() != "access advisor job job-1: entity not found" {
+		t.Errorf("FetchWithAPI() error = %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+// Package accessanalyzer validates policies with IAM Access Analyzer
+package accessanalyzer
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	aa "github.com/aws/aws-sdk-go-v2/service/accessanalyzer"
+	"github.com/aws/aws-sdk-go-v2/service/accessanalyzer/types"
+
+	"github.com/mizzy/least/internal/offline"
+)
+
+// Finding types reported by ValidatePolicy
+const (
+	FindingError           = "ERROR"
+	FindingSecurityWarning = "SECURITY_WARNING"
+	FindingWarning         = "WARNING"
+	FindingSuggestion      = "SUGGESTION"
+)
+
+// API is the subset of the Access Analyzer client used for validation
+type API interface {
+	aa.ValidatePolicyAPIClient
+	CheckNoNewAccess(ctx context.Context, params *aa.CheckNoNewAccessInput, optFns ...func(*aa.Options)) (*aa.CheckNoNewAccessOutput, error)
+}
+
+// Finding is a policy validation finding
+type Finding struct {
+	Type          string
+	IssueCode     string
+	Details       string
+	LearnMoreLink string
+	Locations     []string
+}
+
+// Blocking returns true if the finding should stop the policy from being used
+func (f Finding) Blocking() bool {
+	return f.Type == FindingError || f.Type == FindingSecurityWarning
+}
+
+// String returns a human-readable description of the finding
+func (f Finding) String() string {
+	s := fmt.Sprintf("[%s] %s: %s", f.Type, f.IssueCode, f.Details)
+	if len(f.Locations) > 0 {
+		s += fmt.Sprintf(" (at %s)", strings.Join(f.Locations, ", "))
+	}
+	return s
+}
+
+// NoNewAccessResult is the outcome of a CheckNoNewAccess call
+type NoNewAccessResult struct {
+	Pass    bool
+	Message string
+	Reasons []string
+}
+
+// Validator validates identity policies with Access Analyzer
+type Validator struct {
+	api API
+}
+
+// New creates a Validator using the default AWS configuration
+func New(ctx context.Context) (*Validator, error) {
+	if offline.Enabled() {
+		return nil, offline.ErrOffline
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+	return NewWithAPI(aa.NewFromConfig(cfg)), nil
+}
+
+// NewWithAPI creates a Validator using the given client
+func NewWithAPI(api API) *Validator {
+	return &Validator{api: api}
+}
+
+// ValidatePolicy returns all validation findings for a policy document
+func (v *Validator) ValidatePolicy(ctx context.Context, document string) ([]Finding, error) {
+	paginator := aa.NewValidatePolicyPaginator(v.api, &aa.ValidatePolicyInput{
+		PolicyDocument: aws.String(Normalize(document)),
+		PolicyType:     types.PolicyTypeIdentityPolicy,
+	})
+
+	var findings []Finding
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("validating policy: %w", err)
+		}
+		for _, f := range page.Findings {
+			findings = append(findings, Finding{
+				Type:          string(f.FindingType),
+				IssueCode:     aws.ToString(f.IssueCode),
+				Details:       aws.ToString(f.FindingDetails),
+				LearnMoreLink: aws.ToString(f.LearnMoreLink),
+				Locations:     formatLocations(f.Locations),
+			})
+		}
+	}
+
+	return findings, nil
+}
+
+// CheckNoNewAccess checks that a policy grants no access beyond a reference policy
+func (v *Validator) CheckNoNewAccess(ctx context.Context, document, reference string) (*NoNewAccessResult, error) {
+	out, err := v.api.CheckNoNewAccess(ctx, &aa.CheckNoNewAccessInput{
+		NewPolicyDocument:      aws.String(Normalize(document)),
+		ExistingPolicyDocument: aws.String(Normalize(reference)),
+		PolicyType:             types.AccessCheckPolicyTypeIdentityPolicy,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("checking for new access: %w", err)
+	}
+
+	result := &NoNewAccessResult{
+		Pass:    out.Result == types.CheckNoNewAccessResultPass,
+		Message: aws.ToString(out.Message),
+	}
+	for _, r := range out.Reasons {
+		reason := aws.ToString(r.Description)
+		if r.StatementId != nil {
+			reason = fmt.Sprintf("%s: %s", aws.ToString(r.StatementId), reason)
+		} else if r.StatementIndex != nil {
+			reason = fmt.Sprintf("Statement[%d]: %s", aws.ToInt32(r.StatementIndex), reason)
+		}
+		result.Reasons = append(result.Reasons, reason)
+	}
+
+	return result, nil
+}
+
+// interpolationPattern matches Terraform interpolations such as ${data.aws_region.current.name}
+var interpolationPattern = regexp.MustCompile(`\$\{[^}]*\}`)
+
+// Normalize replaces Terraform interpolations with wildcards so that
+// generated ARNs are syntactically valid for Access Analyzer
+func Normalize(document string) string {
+	return interpolationPattern.ReplaceAllString(document, "*")
+}
+
+// formatLocations converts finding locations into JSON-path-like strings
+func formatLocations(locations []types.Location) []string {
+	var result []string
+	for _, loc := range locations {
+		var sb strings.Builder
+		for _, elem := range loc.Path {
+			switch e := elem.(type) {
+			case *types.PathElementMemberKey:
+				if sb.Len() > 0 {
+					sb.WriteString(".")
+				}
+				sb.WriteString(e.Value)
+			case *types.PathElementMemberIndex:
+				fmt.Fprintf(&sb, "[%d]", e.Value)
+			case *types.PathElementMemberValue:
+				fmt.Fprintf(&sb, "=%q", e.Value)
+			}
+		}
+		if sb.Len() > 0 {
+			result = append(result, sb.String())
+		}
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package accessanalyzer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	aa "github.com/aws/aws-sdk-go-v2/service/accessanalyzer"
+	"github.com/aws/aws-sdk-go-v2/service/accessanalyzer/types"
+)
+
+type fakeAPI struct {
+	validated string
+	findings  []types.ValidatePolicyFinding
+	noNew     *aa.CheckNoNewAccessOutput
+}
+
+func (f *fakeAPI) ValidatePolicy(_ context.Context, params *aa.ValidatePolicyInput, _ ...func(*aa.Options)) (*aa.ValidatePolicyOutput, error) {
+	f.validated = aws.ToString(params.PolicyDocument)
+	return &aa.ValidatePolicyOutput{Findings: f.findings}, nil
+}
+
+func (f *fakeAPI) CheckNoNewAccess(_ context.Context, _ *aa.CheckNoNewAccessInput, _ ...func(*aa.Options)) (*aa.CheckNoNewAccessOutput, error) {
+	return f.noNew, nil
+}
+
+func TestValidatePolicy(t *testing.T) {
+	api := &fakeAPI{
+		findings: []types.ValidatePolicyFinding{
+			{
+				FindingType:    types.ValidatePolicyFindingTypeError,
+				IssueCode:      aws.String("INVALID_ACTION"),
+				FindingDetails: aws.String("The action s3:GetObjects does not exist."),
+				Locations: []types.Location{{
+					Path: []types.PathElement{
+						&types.PathElementMemberKey{Value: "Statement"},
+						&types.PathElementMemberIndex{Value: 0},
+						&types.PathElementMemberKey{Value: "Action"},
+						&types.PathElementMemberIndex{Value: 1},
+					},
+				}},
+			},
+			{
+				FindingType:    types.ValidatePolicyFindingTypeSuggestion,
+				IssueCode:      aws.String("EMPTY_ARRAY_ACTION"),
+				FindingDetails: aws.String("Remove the empty array."),
+			},
+		},
+	}
+
+	doc := `{"Resource": "arn:aws:sqs:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:queue"}`
+	findings, err := NewWithAPI(api).ValidatePolicy(context.Background(), doc)
+	if err != nil {
+		t.Fatalf("ValidatePolicy() error = %v", err)
+	}
+
+	if strings.Contains(api.validated, "${") {
+		t.Errorf("interpolations were not normalized: %s", api.validated)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("got %d findings, want 2", len(findings))
+	}
+	if !findings[0].Blocking() || findings[1].Blocking() {
+		t.Errorf("Blocking() = %v, %v; want true, false", findings[0].Blocking(), findings[1].Blocking())
+	}
+	if got := findings[0].Locations; len(got) != 1 || got[0] != "Statement[0].Action[1]" {
+		t.Errorf("Locations = %v, want [Statement[0].Action[1]]", got)
+	}
+}
+
+func TestCheckNoNewAccess(t *testing.T) {
+	api := &fakeAPI{
+		noNew: &aa.CheckNoNewAccessOutput{
+			Result:  types.CheckNoNewAccessResultFail,
+			Message: aws.String("The modified permissions grant new access compared to your existing policy."),
+			Reasons: []types.ReasonSummary{
+				{StatementId: aws.String("AwsSqsQueueMain"), Description: aws.String("New access in the statement with id AwsSqsQueueMain")},
+			},
+		},
+	}
+
+	result, err := NewWithAPI(api).CheckNoNewAccess(context.Background(), "{}", "{}")
+	if err != nil {
+		t.Fatalf("CheckNoNewAccess() error = %v", err)
+	}
+	if result.Pass {
+		t.Error("Pass = true, want false")
+	}
+	if len(result.Reasons) != 1 || !strings.HasPrefix(result.Reasons[0], "AwsSqsQueueMain: ") {
+		t.Errorf("Reasons = %v", result.Reasons)
+	}
+}
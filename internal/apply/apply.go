@@ -0,0 +1,248 @@
+// Package apply creates and updates IAM managed policies in AWS
+package apply
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+
+	"github.com/mizzy/least/internal/offline"
+)
+
+// MaxVersions is the maximum number of versions IAM keeps for a managed policy
+const MaxVersions = 5
+
+// API is the subset of the IAM client used to apply policies
+type API interface {
+	iam.ListPoliciesAPIClient
+	CreatePolicy(ctx context.Context, params *iam.CreatePolicyInput, optFns ...func(*iam.Options)) (*iam.CreatePolicyOutput, error)
+	CreatePolicyVersion(ctx context.Context, params *iam.CreatePolicyVersionInput, optFns ...func(*iam.Options)) (*iam.CreatePolicyVersionOutput, error)
+	GetPolicyVersion(ctx context.Context, params *iam.GetPolicyVersionInput, optFns ...func(*iam.Options)) (*iam.GetPolicyVersionOutput, error)
+	ListPolicyVersions(ctx context.Context, params *iam.ListPolicyVersionsInput, optFns ...func(*iam.Options)) (*iam.ListPolicyVersionsOutput, error)
+	DeletePolicyVersion(ctx context.Context, params *iam.DeletePolicyVersionInput, optFns ...func(*iam.Options)) (*iam.DeletePolicyVersionOutput, error)
+	AttachRolePolicy(ctx context.Context, params *iam.AttachRolePolicyInput, optFns ...func(*iam.Options)) (*iam.AttachRolePolicyOutput, error)
+}
+
+// Options configures how a policy is applied
+type Options struct {
+	// Name is the name of the managed policy
+	Name string
+	// Path is the IAM path of the managed policy (default "/")
+	Path string
+	// Description is used when the policy is created
+	Description string
+	// Role is the name of a role to attach the policy to
+	Role string
+	// KeepVersions is the number of versions to keep, including the new one
+	// (default and maximum MaxVersions)
+	KeepVersions int
+}
+
+// Result describes what Apply changed
+type Result struct {
+	ARN       string
+	Created   bool
+	Unchanged bool
+	VersionID string
+	Pruned    []string
+	Attached  bool
+}
+
+// Current is the current default version of an existing managed policy
+type Current struct {
+	ARN       string
+	VersionID string
+	Document  string
+}
+
+// Applier applies policies with the IAM API
+type Applier struct {
+	api API
+}
+
+// New creates an Applier using the default AWS configuration
+func New(ctx context.Context) (*Applier, error) {
+	if offline.Enabled() {
+		return nil, offline.ErrOffline
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+	return NewWithAPI(iam.NewFromConfig(cfg)), nil
+}
+
+// NewWithAPI creates an Applier using the given client
+func NewWithAPI(api API) *Applier {
+	return &Applier{api: api}
+}
+
+// GetCurrent returns the default version of the customer managed policy with
+// the given name and path, or nil if the policy does not exist
+func (a *Applier) GetCurrent(ctx context.Context, name, path string) (*Current, error) {
+	paginator := iam.NewListPoliciesPaginator(a.api, &iam.ListPoliciesInput{
+		Scope:      types.PolicyScopeTypeLocal,
+		PathPrefix: aws.String(pathOrDefault(path)),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing policies: %w", err)
+		}
+		for _, p := range page.Policies {
+			if aws.ToString(p.PolicyName) != name || aws.ToString(p.Path) != pathOrDefault(path) {
+				continue
+			}
+
+			out, err := a.api.GetPolicyVersion(ctx, &iam.GetPolicyVersionInput{
+				PolicyArn: p.Arn,
+				VersionId: p.DefaultVersionId,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("getting policy version: %w", err)
+			}
+			document, err := url.QueryUnescape(aws.ToString(out.PolicyVersion.Document))
+			if err != nil {
+				return nil, fmt.Errorf("decoding policy document: %w", err)
+			}
+			return &Current{
+				ARN:       aws.ToString(p.Arn),
+				VersionID: aws.ToString(p.DefaultVersionId),
+				Document:  document,
+			}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Apply creates the managed policy or, if it exists and differs, adds a new
+// default version, pruning the oldest versions beyond KeepVersions. The policy
+// is then attached to Role if given.
+func (a *Applier) Apply(ctx context.Context, document string, opts Options) (*Result, error) {
+	current, err := a.GetCurrent(ctx, opts.Name, opts.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *Result
+	switch {
+	case current == nil:
+		out, err := a.api.CreatePolicy(ctx, &iam.CreatePolicyInput{
+			PolicyName:     aws.String(opts.Name),
+			Path:           aws.String(pathOrDefault(opts.Path)),
+			PolicyDocument: aws.String(document),
+			Description:    optionalString(opts.Description),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("creating policy: %w", err)
+		}
+		result = &Result{
+			ARN:       aws.ToString(out.Policy.Arn),
+			Created:   true,
+			VersionID: aws.ToString(out.Policy.DefaultVersionId),
+		}
+	case SameDocument(current.Document, document):
+		result = &Result{ARN: current.ARN, Unchanged: true, VersionID: current.VersionID}
+	default:
+		pruned, err := a.prune(ctx, current.ARN, opts.KeepVersions)
+		if err != nil {
+			return nil, err
+		}
+		out, err := a.api.CreatePolicyVersion(ctx, &iam.CreatePolicyVersionInput{
+			PolicyArn:      aws.String(current.ARN),
+			PolicyDocument: aws.String(document),
+			SetAsDefault:   true,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("creating policy version: %w", err)
+		}
+		result = &Result{
+			ARN:       current.ARN,
+			VersionID: aws.ToString(out.PolicyVersion.VersionId),
+			Pruned:    pruned,
+		}
+	}
+
+	if opts.Role != "" {
+		if _, err := a.api.AttachRolePolicy(ctx, &iam.AttachRolePolicyInput{
+			RoleName:  aws.String(opts.Role),
+			PolicyArn: aws.String(result.ARN),
+		}); err != nil {
+			return nil, fmt.Errorf("attaching policy to role %s: %w", opts.Role, err)
+		}
+		result.Attached = true
+	}
+
+	return result, nil
+}
+
+// prune deletes the oldest non-default versions so that, after a new version
+// is created, at most keep versions remain
+func (a *Applier) prune(ctx context.Context, policyARN string, keep int) ([]string, error) {
+	if keep <= 0 || keep > MaxVersions {
+		keep = MaxVersions
+	}
+
+	out, err := a.api.ListPolicyVersions(ctx, &iam.ListPolicyVersionsInput{PolicyArn: aws.String(policyARN)})
+	if err != nil {
+		return nil, fmt.Errorf("listing policy versions: %w", err)
+	}
+
+	var candidates []types.PolicyVersion
+	for _, v := range out.Versions {
+		if !v.IsDefaultVersion {
+			candidates = append(candidates, v)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return aws.ToTime(candidates[i].CreateDate).Before(aws.ToTime(candidates[j].CreateDate))
+	})
+
+	excess := len(out.Versions) + 1 - keep
+	var pruned []string
+	for i := 0; i < excess && i < len(candidates); i++ {
+		id := aws.ToString(candidates[i].VersionId)
+		if _, err := a.api.DeletePolicyVersion(ctx, &iam.DeletePolicyVersionInput{
+			PolicyArn: aws.String(policyARN),
+			VersionId: aws.String(id),
+		}); err != nil {
+			return pruned, fmt.Errorf("deleting policy version %s: %w", id, err)
+		}
+		pruned = append(pruned, id)
+	}
+
+	return pruned, nil
+}
+
+// SameDocument checks if two policy documents are semantically equal JSON
+func SameDocument(a, b string) bool {
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return a == b
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func pathOrDefault(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
@@ -0,0 +1,130 @@
+package apply
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+const policyARN = "arn:aws:iam::123456789012:policy/deploy"
+
+type fakeIAM struct {
+	policies []types.Policy
+	versions []types.PolicyVersion
+	document string
+
+	created  bool
+	newDoc   string
+	deleted  []string
+	attached string
+}
+
+func (f *fakeIAM) ListPolicies(_ context.Context, _ *iam.ListPoliciesInput, _ ...func(*iam.Options)) (*iam.ListPoliciesOutput, error) {
+	return &iam.ListPoliciesOutput{Policies: f.policies}, nil
+}
+
+func (f *fakeIAM) CreatePolicy(_ context.Context, params *iam.CreatePolicyInput, _ ...func(*iam.Options)) (*iam.CreatePolicyOutput, error) {
+	f.created = true
+	f.newDoc = aws.ToString(params.PolicyDocument)
+	return &iam.CreatePolicyOutput{Policy: &types.Policy{Arn: aws.String(policyARN), DefaultVersionId: aws.String("v1")}}, nil
+}
+
+func (f *fakeIAM) CreatePolicyVersion(_ context.Context, params *iam.CreatePolicyVersionInput, _ ...func(*iam.Options)) (*iam.CreatePolicyVersionOutput, error) {
+	f.newDoc = aws.ToString(params.PolicyDocument)
+	return &iam.CreatePolicyVersionOutput{PolicyVersion: &types.PolicyVersion{VersionId: aws.String("v6")}}, nil
+}
+
+func (f *fakeIAM) GetPolicyVersion(_ context.Context, _ *iam.GetPolicyVersionInput, _ ...func(*iam.Options)) (*iam.GetPolicyVersionOutput, error) {
+	return &iam.GetPolicyVersionOutput{PolicyVersion: &types.PolicyVersion{Document: aws.String(url.QueryEscape(f.document))}}, nil
+}
+
+func (f *fakeIAM) ListPolicyVersions(_ context.Context, _ *iam.ListPolicyVersionsInput, _ ...func(*iam.Options)) (*iam.ListPolicyVersionsOutput, error) {
+	return &iam.ListPolicyVersionsOutput{Versions: f.versions}, nil
+}
+
+func (f *fakeIAM) DeletePolicyVersion(_ context.Context, params *iam.DeletePolicyVersionInput, _ ...func(*iam.Options)) (*iam.DeletePolicyVersionOutput, error) {
+	f.deleted = append(f.deleted, aws.ToString(params.VersionId))
+	return &iam.DeletePolicyVersionOutput{}, nil
+}
+
+func (f *fakeIAM) AttachRolePolicy(_ context.Context, params *iam.AttachRolePolicyInput, _ ...func(*iam.Options)) (*iam.AttachRolePolicyOutput, error) {
+	f.attached = aws.ToString(params.RoleName)
+	return &iam.AttachRolePolicyOutput{}, nil
+}
+
+func existingPolicy() []types.Policy {
+	return []types.Policy{{
+		PolicyName:       aws.String("deploy"),
+		Path:             aws.String("/"),
+		Arn:              aws.String(policyARN),
+		DefaultVersionId: aws.String("v5"),
+	}}
+}
+
+func version(id string, day int, isDefault bool) types.PolicyVersion {
+	return types.PolicyVersion{
+		VersionId:        aws.String(id),
+		CreateDate:       aws.Time(time.Date(2026, 1, day, 0, 0, 0, 0, time.UTC)),
+		IsDefaultVersion: isDefault,
+	}
+}
+
+func TestApplyCreatesAndAttaches(t *testing.T) {
+	api := &fakeIAM{}
+	result, err := NewWithAPI(api).Apply(context.Background(), `{"Version":"2012-10-17"}`, Options{Name: "deploy", Role: "ci"})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if !result.Created || !api.created {
+		t.Error("expected the policy to be created")
+	}
+	if !result.Attached || api.attached != "ci" {
+		t.Errorf("attached = %q, want ci", api.attached)
+	}
+}
+
+func TestApplyPrunesOldVersions(t *testing.T) {
+	api := &fakeIAM{
+		policies: existingPolicy(),
+		document: `{"Version":"2012-10-17","Statement":[]}`,
+		versions: []types.PolicyVersion{
+			version("v5", 5, true),
+			version("v3", 3, false),
+			version("v1", 1, false),
+			version("v4", 4, false),
+			version("v2", 2, false),
+		},
+	}
+
+	result, err := NewWithAPI(api).Apply(context.Background(), `{"Version":"2012-10-17","Statement":[{}]}`, Options{Name: "deploy", KeepVersions: 3})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if result.Created || result.VersionID != "v6" {
+		t.Errorf("result = %+v, want new version v6", result)
+	}
+	if want := []string{"v1", "v2", "v3"}; !reflect.DeepEqual(api.deleted, want) {
+		t.Errorf("deleted = %v, want %v", api.deleted, want)
+	}
+}
+
+func TestApplyUnchanged(t *testing.T) {
+	api := &fakeIAM{
+		policies: existingPolicy(),
+		document: `{"Version": "2012-10-17", "Statement": []}`,
+	}
+
+	result, err := NewWithAPI(api).Apply(context.Background(), `{"Statement":[],"Version":"2012-10-17"}`, Options{Name: "deploy"})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if !result.Unchanged || api.newDoc != "" {
+		t.Errorf("result = %+v, expected no new version", result)
+	}
+}
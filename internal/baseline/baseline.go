@@ -0,0 +1,162 @@
+// Package baseline handles accepted check findings.
+//
+// A baseline file lists missing or excessive actions that have been
+// consciously accepted, each with a justification and an optional expiry
+// date, so check only fails on new findings:
+//
+//	excessive:
+//	  - action: ec2:DescribeInstances
+//	    justification: used by the legacy inventory job
+//	    expires: 2026-12-31
+//	missing:
+//	  - action: iam:PassRole
+//	    justification: granted through a separate role
+package baseline
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mizzy/least/internal/checker"
+)
+
+// DefaultFile is the baseline file used when none is specified
+const DefaultFile = ".least-baseline.yaml"
+
+// dateLayout is the format of expiry dates
+const dateLayout = "2006-01-02"
+
+// Baseline lists accepted check findings
+type Baseline struct {
+	Missing   []Entry `yaml:"missing,omitempty"`
+	Excessive []Entry `yaml:"excessive,omitempty"`
+}
+
+// Entry is a single accepted finding
+type Entry struct {
+	Action        string `yaml:"action"`
+	Justification string `yaml:"justification,omitempty"`
+	// Expires is the date (YYYY-MM-DD) after which the finding is reported again
+	Expires string `yaml:"expires,omitempty"`
+}
+
+// Expired returns true if the entry's expiry date has passed
+func (e Entry) Expired(now time.Time) bool {
+	if e.Expires == "" {
+		return false
+	}
+	expires, err := time.Parse(dateLayout, e.Expires)
+	if err != nil {
+		return true
+	}
+	// Entries are valid through the end of their expiry date
+	return !now.Before(expires.AddDate(0, 0, 1))
+}
+
+// Load reads a baseline file
+func Load(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading baseline: %w", err)
+	}
+
+	var b Baseline
+	if err := yaml.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("parsing baseline: %w", err)
+	}
+
+	for _, entries := range [][]Entry{b.Missing, b.Excessive} {
+		for _, e := range entries {
+			if e.Action == "" {
+				return nil, fmt.Errorf("baseline entry without action")
+			}
+			if e.Expires != "" {
+				if _, err := time.Parse(dateLayout, e.Expires); err != nil {
+					return nil, fmt.Errorf("baseline entry %s: invalid expires date %q (use YYYY-MM-DD)", e.Action, e.Expires)
+				}
+			}
+		}
+	}
+
+	return &b, nil
+}
+
+// Save writes the baseline to a file
+func (b *Baseline) Save(path string) error {
+	data, err := yaml.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("encoding baseline: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Outcome describes how a baseline was applied to a check result
+type Outcome struct {
+	// Result contains only the findings not accepted by the baseline
+	Result *checker.Result
+	// Suppressed is the number of findings accepted by the baseline
+	Suppressed int
+	// Expired are entries past their expiry date, whose findings are reported again
+	Expired []Entry
+	// Stale are entries that no longer match any finding and can be removed
+	Stale []Entry
+}
+
+// Apply removes accepted findings from a check result
+func (b *Baseline) Apply(result *checker.Result, now time.Time) *Outcome {
+	outcome := &Outcome{
+		Result: &checker.Result{
+			Matched: result.Matched,
+		},
+	}
+
+	outcome.Result.Missing = b.filter(result.Missing, b.Missing, now, outcome)
+	outcome.Result.Excessive = b.filter(result.Excessive, b.Excessive, now, outcome)
+
+	// Blocked actions are missing ones, accepted through the missing entries
+	remaining := make(map[string]bool)
+	for _, action := range outcome.Result.Missing {
+		remaining[action] = true
+	}
+	for _, action := range result.Blocked {
+		if remaining[action] {
+			outcome.Result.Blocked = append(outcome.Result.Blocked, action)
+		}
+	}
+
+	return outcome
+}
+
+// filter returns the findings not accepted by the entries, recording expired and stale entries
+func (b *Baseline) filter(findings []string, entries []Entry, now time.Time, outcome *Outcome) []string {
+	accepted := make(map[string]bool)
+	found := make(map[string]bool)
+	for _, f := range findings {
+		found[checker.NormalizeAction(f)] = true
+	}
+
+	for _, e := range entries {
+		if !found[checker.NormalizeAction(e.Action)] {
+			outcome.Stale = append(outcome.Stale, e)
+			continue
+		}
+		if e.Expired(now) {
+			outcome.Expired = append(outcome.Expired, e)
+			continue
+		}
+		accepted[checker.NormalizeAction(e.Action)] = true
+	}
+
+	var remaining []string
+	for _, f := range findings {
+		if accepted[checker.NormalizeAction(f)] {
+			outcome.Suppressed++
+			continue
+		}
+		remaining = append(remaining, f)
+	}
+	return remaining
+}
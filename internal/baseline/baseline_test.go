@@ -0,0 +1,124 @@
+package baseline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/policy"
+)
+
+func TestApply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFile)
+	content := `excessive:
+  - action: ec2:DescribeInstances
+    justification: used by inventory job
+  - action: s3:*
+    justification: temporary
+    expires: 2026-01-31
+  - action: sqs:SendMessage
+missing:
+  - action: iam:PassRole
+    expires: 2026-12-31
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	result := &checker.Result{
+		Missing:   []string{"iam:PassRole", "s3:CreateBucket"},
+		Excessive: []string{"ec2:DescribeInstances", "s3:*"},
+	}
+
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	outcome := b.Apply(result, now)
+
+	if len(outcome.Result.Missing) != 1 || outcome.Result.Missing[0] != "s3:CreateBucket" {
+		t.Errorf("unexpected missing: %v", outcome.Result.Missing)
+	}
+	if len(outcome.Result.Excessive) != 1 || outcome.Result.Excessive[0] != "s3:*" {
+		t.Errorf("expired entry should be reported again, got excessive: %v", outcome.Result.Excessive)
+	}
+	if outcome.Suppressed != 2 {
+		t.Errorf("got %d suppressed, want 2", outcome.Suppressed)
+	}
+	if len(outcome.Expired) != 1 || outcome.Expired[0].Action != "s3:*" {
+		t.Errorf("unexpected expired entries: %v", outcome.Expired)
+	}
+	if len(outcome.Stale) != 1 || outcome.Stale[0].Action != "sqs:SendMessage" {
+		t.Errorf("unexpected stale entries: %v", outcome.Stale)
+	}
+}
+
+func TestApplyActionCase(t *testing.T) {
+	b := &Baseline{Excessive: []Entry{{Action: "S3:GetObject"}}}
+	result := &checker.Result{Excessive: []string{"s3:getobject"}}
+
+	outcome := b.Apply(result, time.Now())
+	if len(outcome.Result.Excessive) != 0 || outcome.Suppressed != 1 || len(outcome.Stale) != 0 {
+		t.Errorf("entry should accept the finding regardless of case, got %+v", outcome)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	e := Entry{Action: "s3:*", Expires: "2026-01-31"}
+
+	if e.Expired(time.Date(2026, 1, 31, 23, 0, 0, 0, time.UTC)) {
+		t.Error("entry should be valid through its expiry date")
+	}
+	if !e.Expired(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("entry should be expired after its expiry date")
+	}
+}
+
+func TestLoadInvalidDate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFile)
+	if err := os.WriteFile(path, []byte("missing:\n  - action: s3:*\n    expires: next week\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for invalid expiry date")
+	}
+}
+
+func TestApplyWithBoundary(t *testing.T) {
+	existing := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:GetObject", "iam:PassRole", "sqs:SendMessage"}, Resource: []string{"*"}},
+		},
+	}
+	boundary := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:*"}, Resource: []string{"*"}},
+		},
+	}
+	required := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:GetObject", "iam:PassRole", "sqs:SendMessage"}, Resource: []string{"*"}},
+		},
+	}
+
+	result := checker.CheckWithBoundary(existing, boundary, required)
+	b := &Baseline{Missing: []Entry{{Action: "sqs:SendMessage", Justification: "boundary change requested"}}}
+	outcome := b.Apply(result, time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	if got := strings.Join(outcome.Result.Missing, ","); got != "iam:PassRole" {
+		t.Errorf("Missing = %v, want [iam:PassRole]", outcome.Result.Missing)
+	}
+	// Blocked findings survive the baseline unless they are accepted
+	if got := strings.Join(outcome.Result.Blocked, ","); got != "iam:PassRole" {
+		t.Errorf("Blocked = %v, want [iam:PassRole]", outcome.Result.Blocked)
+	}
+	if outcome.Suppressed != 1 || len(outcome.Stale) != 0 {
+		t.Errorf("Suppressed = %d, Stale = %v", outcome.Suppressed, outcome.Stale)
+	}
+}
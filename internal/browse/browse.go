@@ -0,0 +1,362 @@
+// Package browse is the model of the terminal browser of a generated policy:
+// its statements grouped by service or module, and the resources each action
+// of a statement is required by
+package browse
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/provider"
+)
+
+// Grouping is how statements are grouped
+type Grouping int
+
+const (
+	// ByService groups statements by the services of their actions
+	ByService Grouping = iota
+	// ByModule groups statements by the modules of the resources requiring
+	// them
+	ByModule
+)
+
+// rootModule is the name of the group of the root module's statements
+const rootModule = "(root module)"
+
+// Group is a service or module and the statements in it
+type Group struct {
+	Name string
+	// Statements are indexes into the statements of the policy
+	Statements []int
+}
+
+// Groups groups the Allow statements of a policy, sorted by name. A statement
+// with actions of several services, or required by resources of several
+// modules, is in each of their groups.
+func Groups(p *policy.IAMPolicy, by Grouping) []Group {
+	members := make(map[string][]int)
+	for i, stmt := range p.Statement {
+		if stmt.Effect != "Allow" {
+			continue
+		}
+		var names []string
+		if by == ByModule {
+			for _, res := range stmt.Sources {
+				names = append(names, moduleName(res))
+			}
+			if len(names) == 0 {
+				names = append(names, rootModule)
+			}
+		} else {
+			for _, action := range stmt.Action {
+				service, _, _ := strings.Cut(action, ":")
+				names = append(names, service)
+			}
+		}
+		slices.Sort(names)
+		for _, name := range slices.Compact(names) {
+			members[name] = append(members[name], i)
+		}
+	}
+
+	groups := make([]Group, 0, len(members))
+	for name, stmts := range members {
+		groups = append(groups, Group{Name: name, Statements: stmts})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
+
+// moduleName returns the module a resource is in, or the root module
+func moduleName(res provider.Resource) string {
+	if res.Module == "" {
+		return rootModule
+	}
+	return res.Module
+}
+
+// Key is a key press the browser handles
+type Key int
+
+const (
+	// None is a key the browser ignores
+	None Key = iota
+	// Up moves the cursor up, or scrolls the open statement up
+	Up
+	// Down moves the cursor down, or scrolls the open statement down
+	Down
+	// Enter opens the group or statement under the cursor
+	Enter
+	// Back closes the open statement, or the group under the cursor
+	Back
+	// Regroup switches between grouping by service and by module
+	Regroup
+	// Copy copies the statement under the cursor
+	Copy
+	// Quit leaves the browser
+	Quit
+)
+
+// ParseKey returns the key a read from a terminal in raw mode is
+func ParseKey(input []byte) Key {
+	switch string(input) {
+	case "\x1b[A", "\x1bOA", "k":
+		return Up
+	case "\x1b[B", "\x1bOB", "j":
+		return Down
+	case "\r", "\n", "\x1b[C", "\x1bOC", "l", " ":
+		return Enter
+	case "\x1b[D", "\x1bOD", "h", "\x1b", "\x7f":
+		return Back
+	case "g":
+		return Regroup
+	case "c", "y":
+		return Copy
+	case "q", "\x03", "\x04":
+		return Quit
+	}
+	return None
+}
+
+// row is a line of the list: a group, or a statement in an open group
+type row struct {
+	group     int
+	statement int // index into the statements of the policy, or -1
+}
+
+// Browser is the state of the browser
+type Browser struct {
+	policy   *policy.IAMPolicy
+	grouping Grouping
+	groups   []Group
+	open     map[string]bool
+	cursor   int
+	// detail is the index of the open statement, or -1
+	detail int
+	// scroll is the first line of the open statement shown
+	scroll int
+	// Message is shown in the status line until the next key
+	Message string
+}
+
+// New returns a browser of the policy, grouping by service
+func New(p *policy.IAMPolicy) *Browser {
+	b := &Browser{policy: p, detail: -1}
+	b.regroup(ByService)
+	return b
+}
+
+func (b *Browser) regroup(by Grouping) {
+	b.grouping = by
+	b.groups = Groups(b.policy, by)
+	b.open = make(map[string]bool)
+	b.cursor = 0
+}
+
+// rows returns the lines of the list
+func (b *Browser) rows() []row {
+	var rows []row
+	for i, g := range b.groups {
+		rows = append(rows, row{group: i, statement: -1})
+		if b.open[g.Name] {
+			for _, s := range g.Statements {
+				rows = append(rows, row{group: i, statement: s})
+			}
+		}
+	}
+	return rows
+}
+
+// Handle updates the browser for a key, and returns false on Quit
+func (b *Browser) Handle(key Key) bool {
+	b.Message = ""
+	if key == Quit {
+		return false
+	}
+
+	if b.detail >= 0 {
+		switch key {
+		case Up:
+			b.scroll = max(b.scroll-1, 0)
+		case Down:
+			b.scroll = min(b.scroll+1, max(len(b.detailLines())-1, 0))
+		case Back:
+			b.detail = -1
+		}
+		return true
+	}
+
+	rows := b.rows()
+	if len(rows) == 0 {
+		return true
+	}
+	current := rows[b.cursor]
+	switch key {
+	case Up:
+		b.cursor = max(b.cursor-1, 0)
+	case Down:
+		b.cursor = min(b.cursor+1, len(rows)-1)
+	case Enter:
+		if current.statement >= 0 {
+			b.detail, b.scroll = current.statement, 0
+		} else {
+			b.open[b.groups[current.group].Name] = true
+		}
+	case Back:
+		name := b.groups[current.group].Name
+		if b.open[name] {
+			b.open[name] = false
+			// Move to the group, as its statements are hidden
+			for i, r := range b.rows() {
+				if r.group == current.group {
+					b.cursor = i
+					break
+				}
+			}
+		}
+	case Regroup:
+		if b.grouping == ByService {
+			b.regroup(ByModule)
+		} else {
+			b.regroup(ByService)
+		}
+	}
+	return true
+}
+
+// Selected returns the open statement, or the statement under the cursor, or
+// nil if the cursor is on a group
+func (b *Browser) Selected() *policy.Statement {
+	if b.detail >= 0 {
+		return &b.policy.Statement[b.detail]
+	}
+	rows := b.rows()
+	if len(rows) == 0 || rows[b.cursor].statement < 0 {
+		return nil
+	}
+	return &b.policy.Statement[rows[b.cursor].statement]
+}
+
+// CopyText returns the policy document JSON of a statement, as copied
+func CopyText(stmt *policy.Statement) (string, error) {
+	data, err := json.MarshalIndent(stmt, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Render returns the screen, at most height lines: the list or the open
+// statement, and a status line
+func (b *Browser) Render(height int) []string {
+	height = max(height-1, 1)
+
+	var lines []string
+	if b.detail >= 0 {
+		lines = b.detailLines()
+		end := min(b.scroll+height, len(lines))
+		lines = lines[b.scroll:end]
+	} else {
+		lines = b.listLines(height)
+	}
+
+	status := b.Message
+	if status == "" {
+		if b.detail >= 0 {
+			status = "↑/↓ scroll  ← back  c copy  q quit"
+		} else {
+			by := "module"
+			if b.grouping == ByModule {
+				by = "service"
+			}
+			status = fmt.Sprintf("↑/↓ move  enter open  ← close  g group by %s  c copy  q quit", by)
+		}
+	}
+	return append(lines, status)
+}
+
+// listLines returns the lines of the list, scrolled to show the cursor
+func (b *Browser) listLines(height int) []string {
+	rows := b.rows()
+	if len(rows) == 0 {
+		return []string{"The policy has no statements"}
+	}
+
+	start := 0
+	if b.cursor >= height {
+		start = b.cursor - height + 1
+	}
+	var lines []string
+	for i := start; i < len(rows) && i < start+height; i++ {
+		r := rows[i]
+		cursor := "  "
+		if i == b.cursor {
+			cursor = "> "
+		}
+		g := b.groups[r.group]
+		if r.statement < 0 {
+			marker := "+"
+			if b.open[g.Name] {
+				marker = "-"
+			}
+			lines = append(lines, fmt.Sprintf("%s%s %s (%d)", cursor, marker, g.Name, len(g.Statements)))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s    %s", cursor, b.describe(b.policy.Statement[r.statement])))
+	}
+	return lines
+}
+
+// describe returns the list line of a statement: its Sid, number of actions
+// and the first resource requiring it
+func (b *Browser) describe(stmt policy.Statement) string {
+	name := stmt.Sid
+	if name == "" {
+		name = "(no Sid)"
+	}
+	line := fmt.Sprintf("%s: %d actions", name, len(stmt.Action))
+	if len(stmt.Sources) > 0 {
+		line += " for " + stmt.Sources[0].FullAddress()
+		if len(stmt.Sources) > 1 {
+			line += fmt.Sprintf(" and %d more", len(stmt.Sources)-1)
+		}
+	}
+	return line
+}
+
+// detailLines returns the lines of the open statement: its resources, the
+// resources requiring it, and each action with the resources requiring it
+func (b *Browser) detailLines() []string {
+	stmt := b.policy.Statement[b.detail]
+	lines := []string{"Statement " + stmt.Sid, ""}
+	if len(stmt.Sources) > 0 {
+		lines = append(lines, "Required by:")
+		for _, res := range stmt.Sources {
+			lines = append(lines, fmt.Sprintf("  %s (%s)", res.FullAddress(), res.Location))
+		}
+		lines = append(lines, "")
+	}
+	lines = append(lines, "Resources:")
+	for _, r := range stmt.Resource {
+		lines = append(lines, "  "+r)
+	}
+	lines = append(lines, "", "Actions:")
+	for _, action := range stmt.Action {
+		var sources []string
+		for _, res := range b.policy.SourcesOf(action) {
+			sources = append(sources, fmt.Sprintf("%s (%s)", res.FullAddress(), res.Location))
+		}
+		slices.Sort(sources)
+		line := "  " + action
+		if len(sources) > 0 {
+			line += " <- " + strings.Join(slices.Compact(sources), ", ")
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
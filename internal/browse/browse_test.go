@@ -0,0 +1,113 @@
+package browse
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/provider"
+)
+
+func testPolicy() *policy.IAMPolicy {
+	fn := provider.Resource{Type: "aws_lambda_function", Name: "api", Location: provider.SourceLocation{File: "lambda.tf", Line: 3}}
+	queue := provider.Resource{Type: "aws_sqs_queue", Name: "jobs", Module: "module.queue", Location: provider.SourceLocation{File: "queue/main.tf", Line: 1}}
+	return &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{
+				Sid:      "AwsLambdaFunctionApi",
+				Effect:   "Allow",
+				Action:   []string{"iam:PassRole", "lambda:CreateFunction"},
+				Resource: []string{"*"},
+				Sources:  []provider.Resource{fn},
+			},
+			{
+				Sid:      "AwsSqsQueueJobs",
+				Effect:   "Allow",
+				Action:   []string{"sqs:CreateQueue"},
+				Resource: []string{"arn:aws:sqs:*:*:jobs"},
+				Sources:  []provider.Resource{queue},
+			},
+			{
+				Effect: "Deny",
+				Action: []string{"sqs:DeleteQueue"},
+			},
+		},
+	}
+}
+
+func TestGroups(t *testing.T) {
+	tests := []struct {
+		by   Grouping
+		want []Group
+	}{
+		{ByService, []Group{{"iam", []int{0}}, {"lambda", []int{0}}, {"sqs", []int{1}}}},
+		{ByModule, []Group{{"(root module)", []int{0}}, {"module.queue", []int{1}}}},
+	}
+	for _, tt := range tests {
+		if got := Groups(testPolicy(), tt.by); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Groups(%d) = %v, want %v", tt.by, got, tt.want)
+		}
+	}
+}
+
+func TestBrowser(t *testing.T) {
+	b := New(testPolicy())
+	if b.Selected() != nil {
+		t.Errorf("Selected() on a group = %v, want nil", b.Selected())
+	}
+
+	// Open the lambda group and its statement
+	for _, key := range []Key{Down, Enter, Down} {
+		b.Handle(key)
+	}
+	lines := b.Render(10)
+	if want := ">     AwsLambdaFunctionApi: 2 actions for aws_lambda_function.api"; lines[2] != want {
+		t.Errorf("list line = %q, want %q", lines[2], want)
+	}
+	if stmt := b.Selected(); stmt == nil || stmt.Sid != "AwsLambdaFunctionApi" {
+		t.Fatalf("Selected() = %v, want AwsLambdaFunctionApi", stmt)
+	}
+
+	b.Handle(Enter)
+	lines = b.Render(20)
+	if !slices.Contains(lines, "  lambda:CreateFunction <- aws_lambda_function.api (lambda.tf:3)") {
+		t.Errorf("statement lines = %q, want the resource requiring lambda:CreateFunction", lines)
+	}
+
+	// Back to the list, then close the group
+	b.Handle(Back)
+	b.Handle(Back)
+	if got := len(b.rows()); got != 3 {
+		t.Errorf("rows after closing = %d, want 3", got)
+	}
+	if b.cursor != 1 {
+		t.Errorf("cursor after closing = %d, want 1 (the lambda group)", b.cursor)
+	}
+
+	b.Handle(Regroup)
+	if lines := b.Render(10); lines[1] != "  + module.queue (1)" {
+		t.Errorf("grouped by module = %q", lines)
+	}
+	if b.Handle(Quit) {
+		t.Error("Handle(Quit) = true, want false")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := map[string]Key{
+		"\x1b[A": Up,
+		"j":      Down,
+		"\r":     Enter,
+		"\x7f":   Back,
+		"g":      Regroup,
+		"c":      Copy,
+		"\x03":   Quit,
+		"x":      None,
+	}
+	for input, want := range tests {
+		if got := ParseKey([]byte(input)); got != want {
+			t.Errorf("ParseKey(%q) = %d, want %d", input, got, want)
+		}
+	}
+}
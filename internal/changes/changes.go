@@ -0,0 +1,101 @@
+// Package changes finds the IaC resources affected by uncommitted changes,
+// so that pre-commit hooks only check what a commit touches.
+package changes
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+// Files returns the absolute paths of the files in the git work tree of dir
+// that differ from HEAD, staged or not, and of untracked files. Deleted
+// files are included, since removing a file can change the resources of its
+// directory.
+func Files(ctx context.Context, dir string) ([]string, error) {
+	top, err := git(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(top)
+
+	diff, err := git(ctx, dir, "diff", "--name-only", "HEAD")
+	if err != nil {
+		// No commit yet: every staged file is new
+		if diff, err = git(ctx, dir, "diff", "--name-only", "--cached"); err != nil {
+			return nil, err
+		}
+	}
+	untracked, err := git(ctx, dir, "ls-files", "--others", "--exclude-standard", "--full-name")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(diff+"\n"+untracked, "\n") {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		files = append(files, filepath.Join(root, filepath.FromSlash(name)))
+	}
+	return files, nil
+}
+
+// git runs a git command in dir and returns its output
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("executing git: %w", err)
+	}
+	return string(output), nil
+}
+
+// Dirs returns the directories whose resources changed files affect: the
+// directories of the files, and the modules they call, transitively, since
+// changed module arguments change the resources of the module. modules
+// returns the local modules called from a directory.
+func Dirs(files []string, modules func(dir string) []string) map[string]bool {
+	dirs := make(map[string]bool)
+	var visit func(dir string)
+	visit = func(dir string) {
+		if dirs[dir] {
+			return
+		}
+		dirs[dir] = true
+		if modules != nil {
+			for _, m := range modules(dir) {
+				visit(filepath.Clean(m))
+			}
+		}
+	}
+	for _, f := range files {
+		visit(filepath.Dir(f))
+	}
+	return dirs
+}
+
+// Affected returns the resources defined in the directories, which must be
+// absolute
+func Affected(resources []provider.Resource, dirs map[string]bool) []provider.Resource {
+	var affected []provider.Resource
+	for _, res := range resources {
+		file, err := filepath.Abs(res.Location.File)
+		if err != nil {
+			continue
+		}
+		if dirs[filepath.Dir(file)] {
+			affected = append(affected, res)
+		}
+	}
+	return affected
+}
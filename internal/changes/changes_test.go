@@ -0,0 +1,118 @@
+package changes
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+func TestFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "-q")
+	write("stacks/app/main.tf", "resource \"aws_s3_bucket\" \"logs\" {}\n")
+	write("stacks/db/main.tf", "resource \"aws_dynamodb_table\" \"users\" {}\n")
+	write("modules/queue/main.tf", "resource \"aws_sqs_queue\" \"jobs\" {}\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "initial")
+
+	write("stacks/app/main.tf", "resource \"aws_s3_bucket\" \"logs\" {\n  bucket = \"logs\"\n}\n")
+	write("modules/queue/variables.tf", "variable \"name\" {}\n")
+	run("add", "modules/queue/variables.tf")
+	write("stacks/db/new.tf", "resource \"aws_dynamodb_table\" \"sessions\" {}\n")
+
+	got, err := Files(context.Background(), filepath.Join(dir, "stacks"))
+	if err != nil {
+		t.Fatalf("Files() error = %v", err)
+	}
+	sort.Strings(got)
+	want := []string{
+		filepath.Join(dir, "modules/queue/variables.tf"),
+		filepath.Join(dir, "stacks/app/main.tf"),
+		filepath.Join(dir, "stacks/db/new.tf"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Files() = %v, want %v", got, want)
+	}
+}
+
+func TestAffected(t *testing.T) {
+	root := t.TempDir()
+	app := filepath.Join(root, "stacks", "app")
+	queue := filepath.Join(root, "modules", "queue")
+	bucket := filepath.Join(root, "modules", "bucket")
+	calls := map[string][]string{
+		app:   {filepath.Join(app, "../../modules/queue")},
+		queue: {bucket},
+	}
+	modules := func(dir string) []string { return calls[dir] }
+
+	resources := []provider.Resource{
+		{Type: "aws_s3_bucket", Name: "logs", Location: provider.SourceLocation{File: filepath.Join(app, "main.tf")}},
+		{Type: "aws_sqs_queue", Name: "jobs", Location: provider.SourceLocation{File: filepath.Join(queue, "main.tf")}},
+		{Type: "aws_s3_bucket", Name: "dlq", Location: provider.SourceLocation{File: filepath.Join(bucket, "main.tf")}},
+		{Type: "aws_dynamodb_table", Name: "users", Location: provider.SourceLocation{File: filepath.Join(root, "stacks", "db", "main.tf")}},
+	}
+
+	tests := []struct {
+		name    string
+		changed []string
+		want    []string
+	}{
+		{
+			name:    "root with module calls",
+			changed: []string{filepath.Join(app, "main.tf")},
+			want:    []string{"aws_s3_bucket.logs", "aws_sqs_queue.jobs", "aws_s3_bucket.dlq"},
+		},
+		{
+			name:    "module",
+			changed: []string{filepath.Join(bucket, "variables.tf")},
+			want:    []string{"aws_s3_bucket.dlq"},
+		},
+		{
+			name:    "unrelated file",
+			changed: []string{filepath.Join(root, "README.md")},
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, res := range Affected(resources, Dirs(tt.changed, modules)) {
+				got = append(got, res.Address())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Affected() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package checker
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mizzy/least/internal/policy"
+)
+
+// CheckWithBoundary compares the effective permissions of an identity
+// policy under a permissions boundary against a required policy: an action
+// is granted only if the identity policy grants it and the boundary allows
+// it. Required actions the boundary does not allow are missing and also
+// listed in Blocked; granted actions the boundary blocks entirely are not
+// excessive, since the role cannot use them. Resources and conditions are
+// not compared. A nil boundary checks the identity policy alone.
+func CheckWithBoundary(existing, boundary, required *policy.IAMPolicy) *Result {
+	result := Check(existing, required)
+	if boundary == nil {
+		return result
+	}
+
+	var matched []string
+	for _, action := range result.Matched {
+		if boundaryAllows(boundary, action) {
+			matched = append(matched, action)
+		} else {
+			result.Missing = append(result.Missing, action)
+		}
+	}
+	result.Matched = matched
+
+	for _, action := range result.Missing {
+		if !boundaryAllows(boundary, action) {
+			result.Blocked = append(result.Blocked, action)
+		}
+	}
+
+	var excessive []string
+	for _, action := range result.Excessive {
+		// NotAction findings grant nearly everything; some of it always
+		// passes a boundary that allows anything
+		if strings.HasPrefix(action, "*") || boundaryMayAllow(boundary, action) {
+			excessive = append(excessive, action)
+		}
+	}
+	result.Excessive = excessive
+
+	sort.Strings(result.Missing)
+	sort.Strings(result.Matched)
+	sort.Strings(result.Blocked)
+	return result
+}
+
+// Ungranted returns the actions an identity policy does not grant, in
+// order. Missing actions it does grant are blocked by a permissions
+// boundary, which adding them to the policy does not resolve.
+func Ungranted(existing *policy.IAMPolicy, actions []string) []string {
+	existingActions := existing.GetAllActions()
+	notActionGrants := existing.GetNotActionGrants()
+
+	var ungranted []string
+	for _, action := range actions {
+		if !matchesAny(action, existingActions) && !grantedByNotAction(action, notActionGrants) {
+			ungranted = append(ungranted, action)
+		}
+	}
+	return ungranted
+}
+
+// boundaryAllows checks if a boundary allows every action an action
+// pattern covers: an Allow statement covers it and no Deny statement
+// covers any of it
+func boundaryAllows(boundary *policy.IAMPolicy, action string) bool {
+	allowed := false
+	for _, stmt := range boundary.Statement {
+		switch {
+		case stmt.Effect == "Deny" && (overlapsAny(action, stmt.Action) || len(stmt.NotAction) > 0 && !coversAny(stmt.NotAction, action)):
+			return false
+		case stmt.Effect != "Allow":
+		case coversAny(stmt.Action, action), len(stmt.NotAction) > 0 && !overlapsAny(action, stmt.NotAction):
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+// boundaryMayAllow checks if a boundary allows any of the actions an action
+// pattern covers
+func boundaryMayAllow(boundary *policy.IAMPolicy, action string) bool {
+	allowed := false
+	for _, stmt := range boundary.Statement {
+		switch {
+		case stmt.Effect == "Deny" && coversAny(stmt.Action, action):
+			return false
+		case stmt.Effect != "Allow":
+		case overlapsAny(action, stmt.Action), len(stmt.NotAction) > 0 && !coversAny(stmt.NotAction, action):
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+// coversAny checks if any of the patterns covers every action the action
+// pattern covers
+func coversAny(patterns []string, action string) bool {
+	for _, pattern := range patterns {
+		if covers(pattern, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlapsAny checks if the action pattern and any of the patterns cover a
+// common action
+func overlapsAny(action string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matchAction(pattern, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers checks if pattern covers every action the action pattern covers
+func covers(pattern, action string) bool {
+	return globContains(NormalizeAction(pattern), NormalizeAction(action))
+}
+
+// globContains checks if pattern a matches every string pattern b matches,
+// with * and ? wildcards in both. It runs a's matcher over b, tracking the
+// set of positions in a reached so far. The wildcards of b stand for
+// characters a has no literal for, the hardest strings for a to match; a *
+// in b is expanded one such character at a time until the set of positions
+// repeats, and the rest of b must be matched from every set.
+func globContains(a, b string) bool {
+	start := make([]bool, len(a)+1)
+	start[0] = true
+	memo := make(map[string]bool)
+
+	var contains func(reached []bool, j int) bool
+	contains = func(reached []bool, j int) bool {
+		reached = skipStars(a, reached)
+		if j == len(b) {
+			return reached[len(a)]
+		}
+		key := strconv.Itoa(j) + ":" + positionsKey(reached)
+		if result, ok := memo[key]; ok {
+			return result
+		}
+
+		result := false
+		switch b[j] {
+		case '*':
+			result = true
+			seen := make(map[string]bool)
+			for !seen[positionsKey(reached)] {
+				seen[positionsKey(reached)] = true
+				if !contains(reached, j+1) {
+					result = false
+					break
+				}
+				reached = skipStars(a, globStep(a, reached, 0))
+			}
+		case '?':
+			result = contains(globStep(a, reached, 0), j+1)
+		default:
+			result = contains(globStep(a, reached, b[j]), j+1)
+		}
+		memo[key] = result
+		return result
+	}
+	return contains(start, 0)
+}
+
+// globStep returns the positions of pattern reached from the reached ones by
+// matching character c, where 0 stands for a character without a literal in
+// pattern
+func globStep(pattern string, reached []bool, c byte) []bool {
+	next := make([]bool, len(pattern)+1)
+	for i, ok := range reached[:len(pattern)] {
+		if !ok {
+			continue
+		}
+		switch pattern[i] {
+		case '*':
+			next[i] = true
+		case '?':
+			next[i+1] = true
+		default:
+			if pattern[i] == c {
+				next[i+1] = true
+			}
+		}
+	}
+	return next
+}
+
+// positionsKey returns a map key for a set of positions
+func positionsKey(reached []bool) string {
+	key := make([]byte, len(reached))
+	for i, ok := range reached {
+		if ok {
+			key[i] = 1
+		}
+	}
+	return string(key)
+}
+
+// skipStars adds the positions reached by matching stars with nothing
+func skipStars(pattern string, reached []bool) []bool {
+	for i := range pattern {
+		if reached[i] && pattern[i] == '*' {
+			reached[i+1] = true
+		}
+	}
+	return reached
+}
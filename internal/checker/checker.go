@@ -15,6 +15,9 @@ type Result struct {
 	Excessive []string
 	// Actions that match
 	Matched []string
+	// Missing actions a permissions boundary does not allow (see
+	// CheckWithBoundary); granting them needs a boundary change
+	Blocked []string
 }
 
 // IsCompliant returns true if there are no missing or excessive permissions
@@ -32,10 +35,21 @@ func (r *Result) HasExcessive() bool {
 	return len(r.Excessive) > 0
 }
 
+// Coverage returns the percentage of required actions the existing policy
+// grants, 100 when nothing is required
+func (r *Result) Coverage() float64 {
+	total := len(r.Matched) + len(r.Missing)
+	if total == 0 {
+		return 100
+	}
+	return float64(len(r.Matched)) * 100 / float64(total)
+}
+
 // Check compares an existing policy against a required policy
 func Check(existing, required *policy.IAMPolicy) *Result {
-	existingActions := existing.GetAllActions()
-	requiredActions := required.GetAllActions()
+	existingActions := uniqueActions(existing.GetAllActions())
+	requiredActions := uniqueActions(required.GetAllActions())
+	notActionGrants := existing.GetNotActionGrants()
 
 	existingSet := make(map[string]bool)
 	for _, a := range existingActions {
@@ -51,7 +65,7 @@ func Check(existing, required *policy.IAMPolicy) *Result {
 
 	// Find missing actions (required but not existing)
 	for _, action := range requiredActions {
-		if matchesAny(action, existingActions) {
+		if matchesAny(action, existingActions) || grantedByNotAction(action, notActionGrants) {
 			result.Matched = append(result.Matched, action)
 		} else {
 			result.Missing = append(result.Missing, action)
@@ -65,6 +79,12 @@ func Check(existing, required *policy.IAMPolicy) *Result {
 		}
 	}
 
+	// NotAction grants allow everything outside their exclusions,
+	// which always exceeds what IaC requires
+	for _, excluded := range notActionGrants {
+		result.Excessive = append(result.Excessive, notActionFinding(excluded))
+	}
+
 	sort.Strings(result.Missing)
 	sort.Strings(result.Excessive)
 	sort.Strings(result.Matched)
@@ -82,6 +102,16 @@ func matchesAny(action string, actions []string) bool {
 	return false
 }
 
+// grantedByNotAction checks if an action is allowed by any NotAction grant
+func grantedByNotAction(action string, grants [][]string) bool {
+	for _, excluded := range grants {
+		if !matchesAny(action, excluded) {
+			return true
+		}
+	}
+	return false
+}
+
 // isMatchedByRequired checks if an existing action is covered by any required action
 func isMatchedByRequired(existing string, required []string) bool {
 	for _, req := range required {
@@ -92,23 +122,110 @@ func isMatchedByRequired(existing string, required []string) bool {
 	return false
 }
 
+// MatchAction checks if pattern matches action, where either may contain
+// * (any characters) and ? (any single character) wildcards as IAM
+// evaluates them. With wildcards on both sides, it checks whether they match
+// a common action. Action names are case-insensitive.
+func MatchAction(pattern, action string) bool {
+	return matchAction(pattern, action)
+}
+
 // matchAction checks if pattern matches action (supports wildcards)
 func matchAction(pattern, action string) bool {
-	if pattern == action {
-		return true
+	return globsOverlap(NormalizeAction(pattern), NormalizeAction(action))
+}
+
+// globsOverlap checks if two patterns with * and ? wildcards match a common
+// string. The results for suffixes are memoized, since stars on both sides
+// would otherwise revisit them exponentially often.
+func globsOverlap(a, b string) bool {
+	// memo holds 0 for unknown, 1 for no overlap and 2 for an overlap of
+	// a[i:] and b[j:] at i*(len(b)+1)+j
+	memo := make([]byte, (len(a)+1)*(len(b)+1))
+
+	var overlap func(i, j int) bool
+	overlap = func(i, j int) bool {
+		k := i*(len(b)+1) + j
+		if memo[k] != 0 {
+			return memo[k] == 2
+		}
+
+		var result bool
+		switch {
+		case i == len(a) || j == len(b):
+			result = strings.Trim(a[i:], "*") == "" && strings.Trim(b[j:], "*") == ""
+		case a[i] == '*':
+			// The star matches nothing, or also the first character of b
+			result = overlap(i+1, j) || overlap(i, j+1)
+		case b[j] == '*':
+			result = overlap(i, j+1) || overlap(i+1, j)
+		case a[i] == b[j] || a[i] == '?' || b[j] == '?':
+			result = overlap(i+1, j+1)
+		}
+
+		memo[k] = 1
+		if result {
+			memo[k] = 2
+		}
+		return result
 	}
+	return overlap(0, 0)
+}
 
-	// Handle wildcard patterns like "s3:GetBucket*"
-	if strings.HasSuffix(pattern, "*") {
-		prefix := strings.TrimSuffix(pattern, "*")
-		return strings.HasPrefix(action, prefix)
+// NormalizeAction returns the form actions are compared in: IAM treats
+// service prefixes and action names case-insensitively
+func NormalizeAction(action string) string {
+	return strings.ToLower(strings.TrimSpace(action))
+}
+
+// uniqueActions removes the actions differing from an earlier one only in
+// case, keeping the first spelling
+func uniqueActions(actions []string) []string {
+	seen := make(map[string]bool, len(actions))
+	unique := actions[:0:0]
+	for _, a := range actions {
+		if key := NormalizeAction(a); !seen[key] {
+			seen[key] = true
+			unique = append(unique, a)
+		}
 	}
+	return unique
+}
+
+// ServiceGroup holds the actions of a single service
+type ServiceGroup struct {
+	Service string
+	Actions []string
+}
 
-	// Handle pattern matching against wildcards in action
-	if strings.HasSuffix(action, "*") {
-		prefix := strings.TrimSuffix(action, "*")
-		return strings.HasPrefix(pattern, prefix)
+// GroupByService groups actions by service prefix (e.g., "s3" for "s3:GetObject").
+// Groups are sorted by service name and keep the order of actions within each group.
+func GroupByService(actions []string) []ServiceGroup {
+	index := make(map[string]int)
+	var groups []ServiceGroup
+
+	for _, action := range actions {
+		service := ServiceOf(action)
+		i, ok := index[service]
+		if !ok {
+			i = len(groups)
+			index[service] = i
+			groups = append(groups, ServiceGroup{Service: service})
+		}
+		groups[i].Actions = append(groups[i].Actions, action)
 	}
 
-	return false
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Service < groups[j].Service
+	})
+	return groups
+}
+
+// ServiceOf returns the service prefix of an action, or "*" for full wildcards
+func ServiceOf(action string) string {
+	service, _, ok := strings.Cut(action, ":")
+	if !ok || strings.HasPrefix(action, "*") {
+		return "*"
+	}
+	return strings.ToLower(service)
 }
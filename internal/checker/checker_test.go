@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/mizzy/least/internal/policy"
@@ -181,6 +182,47 @@ func TestCheckCompliant(t *testing.T) {
 	}
 }
 
+func TestCheckCaseInsensitive(t *testing.T) {
+	existing := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"S3:GetObject", "s3:getobject", "SQS:send*"}, Resource: []string{"*"}},
+		},
+	}
+	required := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:GetObject", "sqs:SendMessage"}, Resource: []string{"*"}},
+		},
+	}
+
+	result := Check(existing, required)
+	if !result.IsCompliant() {
+		t.Errorf("expected compliant, but got missing=%v, excessive=%v", result.Missing, result.Excessive)
+	}
+	if changes := Compare(existing, required); len(changes.AddedActions) != 1 || changes.AddedActions[0] != "sqs:SendMessage" || len(changes.RemovedActions) != 1 {
+		t.Errorf("Compare() = %+v, want only the sqs wildcard replaced", changes)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	existing := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:Get*", "sqs:SendMessage"}, Resource: []string{"*"}},
+		},
+	}
+	required := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:GetObject", "s3:GetBucketPolicy", "s3:PutObject", "sqs:SendMessage"}, Resource: []string{"*"}},
+		},
+	}
+
+	if got := Check(existing, required).Coverage(); got != 75 {
+		t.Errorf("Coverage() = %v, want 75", got)
+	}
+	if got := (&Result{}).Coverage(); got != 100 {
+		t.Errorf("Coverage() of an empty result = %v, want 100", got)
+	}
+}
+
 func TestCheckWildcard(t *testing.T) {
 	// Test wildcard matching - existing has wildcard, required has specific actions
 	existing := &policy.IAMPolicy{
@@ -250,6 +292,324 @@ func TestCheckWildcardExcessive(t *testing.T) {
 	}
 }
 
+func TestCheckNotAction(t *testing.T) {
+	// Managed policies like PowerUserAccess grant everything except some services
+	existing, err := policy.ParsePolicy([]byte(`{
+		"Version": "2012-10-17",
+		"Statement": {
+			"Effect": "Allow",
+			"NotAction": ["iam:*", "organizations:*"],
+			"Resource": "*"
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+
+	required := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{
+				Effect:   "Allow",
+				Action:   []string{"s3:CreateBucket", "iam:PassRole"},
+				Resource: []string{"*"},
+			},
+		},
+	}
+
+	result := Check(existing, required)
+
+	if len(result.Missing) != 1 || result.Missing[0] != "iam:PassRole" {
+		t.Errorf("expected only iam:PassRole missing, got: %v", result.Missing)
+	}
+	if !result.HasExcessive() {
+		t.Errorf("NotAction grant should be excessive")
+	}
+}
+
+func TestRemediate(t *testing.T) {
+	existing := &policy.IAMPolicy{
+		Version: "2012-10-17",
+		Statement: []policy.Statement{
+			{
+				Sid:      "Storage",
+				Effect:   "Allow",
+				Action:   []string{"s3:GetObject", "ec2:*"},
+				Resource: []string{"*"},
+				Condition: policy.Condition{
+					"StringEquals": {"aws:RequestedRegion": {"us-east-1"}},
+				},
+			},
+			{
+				Sid:      "Unused",
+				Effect:   "Allow",
+				Action:   []string{"sqs:SendMessage"},
+				Resource: []string{"*"},
+			},
+		},
+	}
+
+	required := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{
+				Sid:      "Storage",
+				Effect:   "Allow",
+				Action:   []string{"s3:GetObject", "s3:PutObject"},
+				Resource: []string{"arn:aws:s3:::my-bucket/*"},
+			},
+		},
+	}
+
+	fixed := Remediate(existing, required, Check(existing, required))
+
+	if len(fixed.Statement) != 2 {
+		t.Fatalf("expected 2 statements, got %d: %+v", len(fixed.Statement), fixed.Statement)
+	}
+
+	// Excessive ec2:* removed, original Sid kept
+	if got := fixed.Statement[0]; got.Sid != "Storage" || len(got.Action) != 1 || got.Action[0] != "s3:GetObject" {
+		t.Errorf("unexpected first statement: %+v", got)
+	}
+
+	if fixed.Statement[0].Condition["StringEquals"]["aws:RequestedRegion"][0] != "us-east-1" {
+		t.Errorf("condition not preserved: %+v", fixed.Statement[0].Condition)
+	}
+
+	// Missing s3:PutObject added with a non-conflicting Sid and the required scope
+	if got := fixed.Statement[1]; got.Sid != "StorageMissing" || got.Action[0] != "s3:PutObject" || got.Resource[0] != "arn:aws:s3:::my-bucket/*" {
+		t.Errorf("unexpected added statement: %+v", got)
+	}
+
+	if result := Check(fixed, required); !result.IsCompliant() {
+		t.Errorf("remediated policy should be compliant, got missing=%v excessive=%v", result.Missing, result.Excessive)
+	}
+}
+
+func TestRemediateActionCase(t *testing.T) {
+	// The excessive action is reported once, in the spelling seen first
+	existing := &policy.IAMPolicy{
+		Version: "2012-10-17",
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:GetObject", "SQS:SendMessage"}, Resource: []string{"*"}},
+			{Effect: "Allow", Action: []string{"sqs:sendmessage"}, Resource: []string{"*"}},
+		},
+	}
+	required := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: []string{"*"}},
+		},
+	}
+
+	fixed := Remediate(existing, required, Check(existing, required))
+	if len(fixed.Statement) != 1 || len(fixed.Statement[0].Action) != 1 || fixed.Statement[0].Action[0] != "s3:GetObject" {
+		t.Errorf("every spelling of the excessive action should be removed, got %+v", fixed.Statement)
+	}
+}
+
+func TestRemediateNotAction(t *testing.T) {
+	// PowerUserAccess-style grant: required actions only match through NotAction
+	existing, err := policy.ParsePolicy([]byte(`{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Sid": "PowerUser",
+				"Effect": "Allow",
+				"NotAction": ["iam:*", "organizations:*"],
+				"Resource": "*"
+			},
+			{
+				"Sid": "Logs",
+				"Effect": "Allow",
+				"Action": ["logs:PutLogEvents"],
+				"Resource": "*"
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+
+	required := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{
+				Effect:   "Allow",
+				Action:   []string{"s3:CreateBucket", "sqs:CreateQueue", "logs:PutLogEvents", "iam:PassRole"},
+				Resource: []string{"*"},
+			},
+		},
+	}
+
+	fixed := Remediate(existing, required, Check(existing, required))
+
+	// The unscoped statement also receives the missing iam:PassRole
+	got := fixed.Statement[0]
+	if got.Sid != "PowerUser" || len(got.NotAction) != 0 || strings.Join(got.Action, ",") != "s3:CreateBucket,sqs:CreateQueue,iam:PassRole" {
+		t.Errorf("NotAction statement = %+v, want the matched s3 and sqs actions", got)
+	}
+	if got := strings.Join(fixed.GetAllActions(), ","); got != "iam:PassRole,logs:PutLogEvents,s3:CreateBucket,sqs:CreateQueue" {
+		t.Errorf("Remediate actions = %s", got)
+	}
+	if result := Check(fixed, required); !result.IsCompliant() {
+		t.Errorf("remediated policy should be compliant, got missing=%v excessive=%v", result.Missing, result.Excessive)
+	}
+
+	// Trim narrows the grant the same way
+	trimmed, result := Trim(existing, required)
+	if got := strings.Join(trimmed.GetAllActions(), ","); got != "logs:PutLogEvents,s3:CreateBucket,sqs:CreateQueue" {
+		t.Errorf("Trim actions = %s", got)
+	}
+	if strings.Join(result.Missing, ",") != "iam:PassRole" {
+		t.Errorf("Trim missing = %v", result.Missing)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	existing := &policy.IAMPolicy{
+		Version: "2012-10-17",
+		Statement: []policy.Statement{
+			{
+				Sid:      "Storage",
+				Effect:   "Allow",
+				Action:   []string{"s3:GetObject", "s3:DeleteObject"},
+				Resource: []string{"arn:aws:s3:::my-bucket/*"},
+				Condition: policy.Condition{
+					"Bool": {"aws:SecureTransport": {"true"}},
+				},
+			},
+			{
+				Sid:      "Queues",
+				Effect:   "Allow",
+				Action:   []string{"sqs:*"},
+				Resource: []string{"*"},
+			},
+			{
+				Sid:      "DenyDelete",
+				Effect:   "Deny",
+				Action:   []string{"s3:DeleteBucket"},
+				Resource: []string{"*"},
+			},
+		},
+	}
+
+	required := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{
+				Effect:   "Allow",
+				Action:   []string{"s3:GetObject", "s3:PutObject"},
+				Resource: []string{"arn:aws:s3:::my-bucket/*"},
+			},
+		},
+	}
+
+	trimmed, result := Trim(existing, required)
+
+	if len(trimmed.Statement) != 2 {
+		t.Fatalf("expected 2 statements, got %d: %+v", len(trimmed.Statement), trimmed.Statement)
+	}
+
+	got := trimmed.Statement[0]
+	if got.Sid != "Storage" || len(got.Action) != 1 || got.Action[0] != "s3:GetObject" {
+		t.Errorf("unexpected first statement: %+v", got)
+	}
+	if got.Condition["Bool"]["aws:SecureTransport"][0] != "true" {
+		t.Errorf("condition not preserved: %+v", got.Condition)
+	}
+	if trimmed.Statement[1].Sid != "DenyDelete" {
+		t.Errorf("deny statement not preserved: %+v", trimmed.Statement[1])
+	}
+
+	if len(result.Excessive) != 2 || result.Excessive[0] != "s3:DeleteObject" || result.Excessive[1] != "sqs:*" {
+		t.Errorf("Excessive = %v, want [s3:DeleteObject sqs:*]", result.Excessive)
+	}
+
+	// Missing actions are reported but not added
+	if len(result.Missing) != 1 || result.Missing[0] != "s3:PutObject" {
+		t.Errorf("Missing = %v, want [s3:PutObject]", result.Missing)
+	}
+}
+
+func TestGroupByService(t *testing.T) {
+	groups := GroupByService([]string{"s3:GetObject", "ec2:RunInstances", "S3:PutObject", "*"})
+
+	want := []ServiceGroup{
+		{Service: "*", Actions: []string{"*"}},
+		{Service: "ec2", Actions: []string{"ec2:RunInstances"}},
+		{Service: "s3", Actions: []string{"s3:GetObject", "S3:PutObject"}},
+	}
+
+	if len(groups) != len(want) {
+		t.Fatalf("got %d groups, want %d: %+v", len(groups), len(want), groups)
+	}
+	for i := range want {
+		if groups[i].Service != want[i].Service || len(groups[i].Actions) != len(want[i].Actions) {
+			t.Errorf("group %d = %+v, want %+v", i, groups[i], want[i])
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	old := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:GetObject", "s3:PutObject"}, Resource: []string{"arn:aws:s3:::a/*"}},
+		},
+	}
+	updated := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:GetObject", "sqs:SendMessage"}, Resource: []string{"arn:aws:s3:::a/*", "arn:aws:sqs:*:*:q"}},
+			{Effect: "Deny", Action: []string{"s3:DeleteObject"}, Resource: []string{"*"}},
+		},
+	}
+
+	changes := Compare(old, updated)
+
+	if len(changes.AddedActions) != 1 || changes.AddedActions[0] != "sqs:SendMessage" {
+		t.Errorf("AddedActions = %v", changes.AddedActions)
+	}
+	if len(changes.RemovedActions) != 1 || changes.RemovedActions[0] != "s3:PutObject" {
+		t.Errorf("RemovedActions = %v", changes.RemovedActions)
+	}
+	if len(changes.AddedResources) != 1 || changes.AddedResources[0] != "arn:aws:sqs:*:*:q" {
+		t.Errorf("AddedResources = %v", changes.AddedResources)
+	}
+	if len(changes.RemovedResources) != 0 {
+		t.Errorf("RemovedResources = %v", changes.RemovedResources)
+	}
+	if Compare(old, old).IsEmpty() != true {
+		t.Error("Compare(old, old).IsEmpty() = false")
+	}
+}
+
+func TestCompareStatements(t *testing.T) {
+	before := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Sid: "Keep", Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: []string{"*"}},
+			{Sid: "Change", Effect: "Allow", Action: []string{"sqs:SendMessage"}, Resource: []string{"*"}},
+			{Sid: "Drop", Effect: "Allow", Action: []string{"sns:Publish"}, Resource: []string{"*"}},
+		},
+	}
+	after := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Sid: "Keep", Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: []string{"*"}},
+			{Sid: "Change", Effect: "Allow", Action: []string{"sqs:SendMessage", "sqs:DeleteMessage"}, Resource: []string{"*"}},
+			{Sid: "New", Effect: "Allow", Action: []string{"ec2:DescribeVpcs"}, Resource: []string{"*"}},
+		},
+	}
+
+	changes := CompareStatements(before, after)
+	if len(changes) != 3 {
+		t.Fatalf("got %d changes, want 3: %+v", len(changes), changes)
+	}
+
+	if c := changes[0]; c.Sid != "Change" || c.Added || c.Removed || len(c.Changes.AddedActions) != 1 {
+		t.Errorf("changes[0] = %+v, want Change with one added action", c)
+	}
+	if c := changes[1]; c.Sid != "New" || !c.Added {
+		t.Errorf("changes[1] = %+v, want New added", c)
+	}
+	if c := changes[2]; c.Sid != "Drop" || !c.Removed || len(c.Changes.RemovedActions) != 1 {
+		t.Errorf("changes[2] = %+v, want Drop removed", c)
+	}
+}
+
 func TestMatchAction(t *testing.T) {
 	tests := []struct {
 		pattern string
@@ -264,6 +624,21 @@ func TestMatchAction(t *testing.T) {
 		{"s3:Get*", "s3:GetBucketAcl", true},
 		{"s3:Get*", "s3:PutObject", false},
 		{"ec2:*", "s3:GetObject", false},
+		{"S3:GetObject", "s3:getobject", true},
+		{"S3:get*", "s3:GetObject", true},
+		{"s3:GetObject", "S3:Get*", true},
+		{"S3:PutObject", "s3:GetObject", false},
+		{"s3:*Object", "s3:GetObject", true},
+		{"s3:*Object", "s3:GetObjectAcl", false},
+		{"s3:Get*Tagging", "s3:GetBucketTagging", true},
+		{"s3:Get*Tagging", "s3:GetBucketAcl", false},
+		{"s3:?etObject", "s3:GetObject", true},
+		{"s3:?etObject", "s3:GetObjects", false},
+		{"*", "s3:GetObject", true},
+		{"s3:*Object", "s3:Get*", true},
+		{"s3:Put*", "s3:*Tagging", true},
+		{"s3:Get*Acl", "s3:Put*", false},
+		{"s3:Get?", "s3:G*", true},
 	}
 
 	for _, tt := range tests {
@@ -277,6 +652,18 @@ func TestMatchAction(t *testing.T) {
 	}
 }
 
+func TestGlobsOverlapManyStars(t *testing.T) {
+	// Without memoization, this takes exponential time
+	a := strings.Repeat("*a", 40) + "c"
+	b := strings.Repeat("*a", 40) + "b"
+	if globsOverlap(a, b) {
+		t.Errorf("globsOverlap(%q, %q) = true", a, b)
+	}
+	if !globsOverlap(a, strings.Repeat("*a", 40)+"*") {
+		t.Error("globsOverlap() = false for patterns matching a common string")
+	}
+}
+
 // findTestdataDir locates the testdata directory
 func findTestdataDir(t *testing.T) string {
 	// Try relative paths from test execution directory
@@ -356,3 +743,144 @@ func loadJSONPolicy(path string) (*policy.IAMPolicy, error) {
 
 	return result, nil
 }
+
+func TestTargetMissing(t *testing.T) {
+	existing := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Sid: "Everything", Effect: "Allow", Action: []string{"ec2:Describe*"}, Resource: []string{"*"}},
+			{Sid: "AppBuckets", Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: []string{"arn:aws:s3:::app-*/*"}},
+			{Sid: "Conditional", Effect: "Allow", Action: []string{"s3:PutObject"}, Resource: []string{"*"},
+				Condition: policy.Condition{"Bool": {"aws:SecureTransport": {"true"}}}},
+		},
+	}
+	required := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:PutObject", "s3:DeleteObject"}, Resource: []string{"arn:aws:s3:::app-data/*"}},
+			{Effect: "Allow", Action: []string{"sqs:SendMessage"}, Resource: []string{"arn:aws:sqs:*:*:jobs"}},
+			{Effect: "Allow", Action: []string{"ec2:RunInstances"}, Resource: []string{"*"}},
+		},
+	}
+
+	targets := TargetMissing(existing, required, []string{"ec2:RunInstances", "s3:DeleteObject", "s3:PutObject", "sqs:SendMessage"})
+
+	if len(targets) != 2 {
+		t.Fatalf("got %d targets, want 2: %+v", len(targets), targets)
+	}
+
+	// The same-service statement with the narrower scope is preferred over
+	// Resource "*" and the conditional statement is never amended; actions
+	// required on every resource only fit the unscoped statement
+	everything := targets[0]
+	if everything.Sid != "Everything" || strings.Join(everything.Actions, ",") != "ec2:RunInstances,sqs:SendMessage" {
+		t.Errorf("targets[0] = %+v, want ec2:RunInstances and sqs:SendMessage on Everything", everything)
+	}
+	buckets := targets[1]
+	if buckets.Sid != "AppBuckets" || buckets.IsNew() || strings.Join(buckets.Actions, ",") != "s3:DeleteObject,s3:PutObject" {
+		t.Errorf("targets[1] = %+v, want the s3 actions on AppBuckets", buckets)
+	}
+
+	// Without a covering statement, actions need a new statement
+	scoped := &policy.IAMPolicy{Statement: existing.Statement[1:]}
+	targets = TargetMissing(scoped, required, []string{"sqs:SendMessage"})
+	if len(targets) != 1 || !targets[0].IsNew() || targets[0].Resource[0] != "arn:aws:sqs:*:*:jobs" {
+		t.Errorf("targets = %+v, want a new statement on the queue", targets)
+	}
+}
+
+func TestMatchResource(t *testing.T) {
+	tests := []struct {
+		pattern, resource string
+		want              bool
+	}{
+		{"*", "arn:aws:s3:::bucket", true},
+		{"arn:aws:s3:::app-*", "arn:aws:s3:::app-data", true},
+		{"arn:aws:s3:::app-*", "arn:aws:s3:::*", false},
+		{"arn:aws:s3:::*", "arn:aws:s3:::app-*", true},
+		{"arn:aws:sqs:us-east-?:*:jobs", "arn:aws:sqs:us-east-1:123456789012:jobs", true},
+		{"arn:aws:sqs:us-east-?:*:jobs", "arn:aws:sqs:*:*:jobs", false},
+	}
+	for _, tt := range tests {
+		if got := matchResource(tt.pattern, tt.resource); got != tt.want {
+			t.Errorf("matchResource(%q, %q) = %v, want %v", tt.pattern, tt.resource, got, tt.want)
+		}
+	}
+}
+
+func TestCheckWithBoundary(t *testing.T) {
+	existing := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:*", "sqs:SendMessage", "sqs:DeleteQueue", "iam:PassRole", "ec2:RunInstances"}, Resource: []string{"*"}},
+		},
+	}
+	boundary := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:*", "sqs:*", "iam:*", "dynamodb:GetItem"}, Resource: []string{"*"}},
+			{Effect: "Deny", Action: []string{"iam:PassRole"}, Resource: []string{"*"}},
+		},
+	}
+	required := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:GetObject", "sqs:SendMessage", "iam:PassRole", "dynamodb:PutItem"}, Resource: []string{"*"}},
+		},
+	}
+
+	result := CheckWithBoundary(existing, boundary, required)
+
+	if got := strings.Join(result.Matched, ","); got != "s3:GetObject,sqs:SendMessage" {
+		t.Errorf("Matched = %v", result.Matched)
+	}
+	if got := strings.Join(result.Missing, ","); got != "dynamodb:PutItem,iam:PassRole" {
+		t.Errorf("Missing = %v", result.Missing)
+	}
+	if got := strings.Join(result.Blocked, ","); got != "dynamodb:PutItem,iam:PassRole" {
+		t.Errorf("Blocked = %v", result.Blocked)
+	}
+	// ec2:RunInstances is outside the boundary, so the role cannot use it
+	if got := strings.Join(result.Excessive, ","); got != "sqs:DeleteQueue" {
+		t.Errorf("Excessive = %v", result.Excessive)
+	}
+
+	// Only the actions the identity policy does not grant are added
+	if got := strings.Join(Ungranted(existing, result.Missing), ","); got != "dynamodb:PutItem" {
+		t.Errorf("Ungranted = %v", got)
+	}
+	fixed := Remediate(existing, required, result)
+	if got := strings.Join(fixed.GetAllActions(), ","); strings.Count(got, "iam:PassRole") != 1 || !strings.Contains(got, "dynamodb:PutItem") {
+		t.Errorf("Remediate actions = %s", got)
+	}
+
+	if got := CheckWithBoundary(existing, nil, required); len(got.Blocked) != 0 || len(got.Missing) != 1 {
+		t.Errorf("without boundary: %+v", got)
+	}
+}
+
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		pattern, action string
+		want            bool
+	}{
+		{"s3:*", "s3:Get*", true},
+		{"s3:*Object", "s3:GetObject", true},
+		{"s3:*Object", "s3:Get*", false},
+		{"s3:Get*", "s3:Get*Tagging", true},
+		{"s3:Get?bject", "s3:Get*", false},
+		{"S3:GET*", "s3:GetObject", true},
+		// ? matches exactly one character, * any number
+		{"s3:Get?", "s3:Get*", false},
+		{"s3:Get*", "s3:Get?", true},
+		{"s3:Get?", "s3:Get?", true},
+		{"s3:Get??", "s3:Get?", false},
+		{"s3:?*", "s3:*?", true},
+		{"s3:*?", "s3:?*", true},
+		{"s3:?*", "s3:*", false},
+		{"s3:*a*", "s3:*a?a*", true},
+		{"s3:*ab*", "s3:*a*b*", false},
+		{"s3:Get*Object", "s3:Get?Object", true},
+		{"s3:Get?Object", "s3:Get*Object", false},
+	}
+	for _, tt := range tests {
+		if got := covers(tt.pattern, tt.action); got != tt.want {
+			t.Errorf("covers(%q, %q) = %v, want %v", tt.pattern, tt.action, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package checker
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/mizzy/least/internal/policy"
+)
+
+// Changes represents the differences between two policies
+type Changes struct {
+	AddedActions     []string
+	RemovedActions   []string
+	AddedResources   []string
+	RemovedResources []string
+}
+
+// IsEmpty returns true if the policies grant the same actions on the same resources
+func (c *Changes) IsEmpty() bool {
+	return len(c.AddedActions) == 0 && len(c.RemovedActions) == 0 &&
+		len(c.AddedResources) == 0 && len(c.RemovedResources) == 0
+}
+
+// Compare reports the actions and resources added and removed between two
+// policies. Actions and resources are compared literally, so replacing
+// s3:GetObject with s3:Get* is reported as one removal and one addition;
+// actions differing only in case are the same.
+func Compare(before, after *policy.IAMPolicy) *Changes {
+	oldActions, newActions := uniqueActions(before.GetAllActions()), uniqueActions(after.GetAllActions())
+	oldResources, newResources := allowedResources(before), allowedResources(after)
+
+	return &Changes{
+		AddedActions:     subtractBy(newActions, oldActions, NormalizeAction),
+		RemovedActions:   subtractBy(oldActions, newActions, NormalizeAction),
+		AddedResources:   subtract(newResources, oldResources),
+		RemovedResources: subtract(oldResources, newResources),
+	}
+}
+
+// allowedResources returns the sorted, unique resources of Allow statements
+func allowedResources(p *policy.IAMPolicy) []string {
+	set := make(map[string]bool)
+	for _, stmt := range p.Statement {
+		if stmt.Effect == "Allow" {
+			for _, r := range stmt.Resource {
+				set[r] = true
+			}
+		}
+	}
+
+	resources := make([]string, 0, len(set))
+	for r := range set {
+		resources = append(resources, r)
+	}
+	sort.Strings(resources)
+	return resources
+}
+
+// subtract returns the elements of a that are not in b
+func subtract(a, b []string) []string {
+	return subtractBy(a, b, func(s string) string { return s })
+}
+
+// subtractBy returns the elements of a whose key is not the key of an
+// element of b
+func subtractBy(a, b []string, key func(string) string) []string {
+	set := make(map[string]bool, len(b))
+	for _, s := range b {
+		set[key(s)] = true
+	}
+
+	var result []string
+	for _, s := range a {
+		if !set[key(s)] {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// StatementChange describes how a single statement differs between two policies
+type StatementChange struct {
+	// Sid identifies the statement, or its 1-based position if it has no Sid
+	Sid string
+	// Added and Removed are set when the statement exists in only one policy
+	Added   bool
+	Removed bool
+	// Changes lists the differences of a statement present in both policies
+	Changes *Changes
+}
+
+// CompareStatements reports statement-level differences between two policies.
+// Statements are matched by Sid, or by position if they have none.
+func CompareStatements(before, after *policy.IAMPolicy) []StatementChange {
+	beforeByKey, beforeKeys := statementsByKey(before)
+	afterByKey, afterKeys := statementsByKey(after)
+
+	var changes []StatementChange
+	for _, key := range afterKeys {
+		stmt := afterByKey[key]
+		old, ok := beforeByKey[key]
+		if !ok {
+			changes = append(changes, StatementChange{Sid: key, Added: true, Changes: Compare(&policy.IAMPolicy{}, singleStatement(stmt))})
+			continue
+		}
+		if c := Compare(singleStatement(old), singleStatement(stmt)); !c.IsEmpty() {
+			changes = append(changes, StatementChange{Sid: key, Changes: c})
+		}
+	}
+	for _, key := range beforeKeys {
+		if _, ok := afterByKey[key]; !ok {
+			changes = append(changes, StatementChange{Sid: key, Removed: true, Changes: Compare(singleStatement(beforeByKey[key]), &policy.IAMPolicy{})})
+		}
+	}
+
+	return changes
+}
+
+// statementsByKey indexes statements by Sid or position, keeping their order
+func statementsByKey(p *policy.IAMPolicy) (map[string]policy.Statement, []string) {
+	byKey := make(map[string]policy.Statement)
+	var keys []string
+	for i, stmt := range p.Statement {
+		key := stmt.Sid
+		if key == "" {
+			key = "#" + strconv.Itoa(i+1)
+		}
+		byKey[key] = stmt
+		keys = append(keys, key)
+	}
+	return byKey, keys
+}
+
+func singleStatement(stmt policy.Statement) *policy.IAMPolicy {
+	return &policy.IAMPolicy{Statement: []policy.Statement{stmt}}
+}
@@ -0,0 +1,128 @@
+package checker
+
+import (
+	"strings"
+
+	"github.com/mizzy/least/internal/policy"
+)
+
+// Remediate returns a copy of the existing policy with the check findings resolved:
+// excessive actions are removed from their statements (dropping statements left empty),
+// excessive NotAction grants are narrowed to the matched actions they covered,
+// and the missing actions are added to the statements TargetMissing selects, or as
+// statements scoped like the required ones when none covers their resources.
+// Original statement order and Sids are preserved.
+func Remediate(existing, required *policy.IAMPolicy, result *Result) *policy.IAMPolicy {
+	excessive := make(map[string]bool)
+	for _, action := range result.Excessive {
+		excessive[NormalizeAction(action)] = true
+	}
+	// Actions only a permissions boundary blocks are granted already
+	ungranted := Ungranted(existing, result.Missing)
+	missing := make(map[string]bool)
+	for _, action := range ungranted {
+		missing[action] = true
+	}
+
+	fixed := &policy.IAMPolicy{
+		Version:   existing.Version,
+		Statement: make([]policy.Statement, 0, len(existing.Statement)),
+	}
+	if fixed.Version == "" {
+		fixed.Version = "2012-10-17"
+	}
+
+	explicit := existing.GetAllActions()
+	sids := make(map[string]bool)
+	for _, stmt := range existing.Statement {
+		if stmt.Effect == "Allow" {
+			if len(stmt.NotAction) > 0 && excessive[NormalizeAction(notActionFinding(stmt.NotAction))] {
+				// Keep the matched actions only this grant allowed
+				var covered []string
+				for _, action := range result.Matched {
+					if !matchesAny(action, stmt.NotAction) && !matchesAny(action, explicit) {
+						covered = append(covered, action)
+					}
+				}
+				if len(covered) == 0 {
+					continue
+				}
+				stmt.NotAction = nil
+				stmt.Action = covered
+			}
+
+			var kept []string
+			for _, action := range stmt.Action {
+				if !excessive[NormalizeAction(action)] {
+					kept = append(kept, action)
+				}
+			}
+			if len(stmt.Action) > 0 && len(kept) == 0 {
+				continue
+			}
+			stmt.Action = kept
+		}
+
+		if stmt.Sid != "" {
+			sids[stmt.Sid] = true
+		}
+		fixed.Statement = append(fixed.Statement, stmt)
+	}
+
+	for _, target := range TargetMissing(fixed, required, ungranted) {
+		if target.IsNew() {
+			continue
+		}
+		stmt := &fixed.Statement[target.Statement]
+		stmt.Action = append(append([]string(nil), stmt.Action...), target.Actions...)
+		for _, action := range target.Actions {
+			delete(missing, action)
+		}
+	}
+
+	for _, stmt := range required.Statement {
+		var actions []string
+		for _, action := range stmt.Action {
+			if missing[action] {
+				actions = append(actions, action)
+			}
+		}
+		if len(actions) == 0 {
+			continue
+		}
+
+		sid := stmt.Sid
+		for sid != "" && sids[sid] {
+			sid += "Missing"
+		}
+		sids[sid] = true
+
+		fixed.Statement = append(fixed.Statement, policy.Statement{
+			Sid:      sid,
+			Effect:   "Allow",
+			Action:   actions,
+			Resource: stmt.Resource,
+		})
+	}
+
+	return fixed
+}
+
+// notActionFinding formats the excessive finding reported for a NotAction grant
+func notActionFinding(excluded []string) string {
+	return "* (NotAction: " + strings.Join(excluded, ", ") + ")"
+}
+
+// Trim returns a copy of the existing policy pruned to the actions the required
+// policy needs, keeping statement order, Sids, resources and conditions. Unlike
+// Remediate it never adds statements; the returned result lists the required
+// actions the trimmed policy still does not grant.
+func Trim(existing, required *policy.IAMPolicy) (*policy.IAMPolicy, *Result) {
+	result := Check(existing, required)
+	trimmed := Remediate(existing, required, &Result{Excessive: result.Excessive, Matched: result.Matched})
+	return trimmed, &Result{
+		Missing:   Check(trimmed, required).Missing,
+		Excessive: result.Excessive,
+		Matched:   result.Matched,
+	}
+}
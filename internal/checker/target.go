@@ -0,0 +1,194 @@
+package checker
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/policy"
+)
+
+// Target is where missing actions are best added to an existing policy
+type Target struct {
+	// Statement is the index of the existing statement to amend, or -1 when
+	// the actions need a new statement
+	Statement int
+	// Sid is the Sid of the statement to amend
+	Sid string
+	// Resource is the scope of the statement to amend, or of the new statement
+	Resource []string
+	// Actions are the missing actions to add
+	Actions []string
+}
+
+// IsNew returns true if the actions need a new statement
+func (t Target) IsNew() bool {
+	return t.Statement < 0
+}
+
+// TargetMissing groups missing actions by where they are best added, so
+// fixes keep the structure of the existing policy. An action is added to the
+// Allow statement without conditions whose resources cover every resource
+// the action is required on, preferring statements that already grant
+// actions of its service, then the narrowest scope. Actions no statement
+// covers need a new statement scoped like the required ones.
+//
+// Targets amending statements come first, in statement order, followed by
+// new statements in the order their scopes are first required.
+func TargetMissing(existing, required *policy.IAMPolicy, missing []string) []Target {
+	var targets []Target
+	byStatement := make(map[int]int)
+	byScope := make(map[string]int)
+
+	for _, action := range missing {
+		resources := requiredResources(required, action)
+		if i := bestStatement(existing, action, resources); i >= 0 {
+			t, ok := byStatement[i]
+			if !ok {
+				t = len(targets)
+				byStatement[i] = t
+				stmt := existing.Statement[i]
+				targets = append(targets, Target{Statement: i, Sid: stmt.Sid, Resource: stmt.Resource})
+			}
+			targets[t].Actions = append(targets[t].Actions, action)
+			continue
+		}
+
+		key := strings.Join(resources, "\n")
+		t, ok := byScope[key]
+		if !ok {
+			t = len(targets)
+			byScope[key] = t
+			targets = append(targets, Target{Statement: -1, Resource: resources})
+		}
+		targets[t].Actions = append(targets[t].Actions, action)
+	}
+
+	sort.SliceStable(targets, func(i, j int) bool {
+		a, b := targets[i], targets[j]
+		if a.IsNew() != b.IsNew() {
+			return !a.IsNew()
+		}
+		return !a.IsNew() && a.Statement < b.Statement
+	})
+	return targets
+}
+
+// requiredResources returns the resources of the required statements
+// granting an action, in statement order and without duplicates
+func requiredResources(required *policy.IAMPolicy, action string) []string {
+	seen := make(map[string]bool)
+	var resources []string
+	for _, stmt := range required.Statement {
+		if stmt.Effect != "Allow" || !containsAction(stmt.Action, action) {
+			continue
+		}
+		for _, r := range stmt.Resource {
+			if !seen[r] {
+				seen[r] = true
+				resources = append(resources, r)
+			}
+		}
+	}
+	if len(resources) == 0 {
+		return []string{"*"}
+	}
+	return resources
+}
+
+// bestStatement returns the index of the existing statement an action is
+// best added to, or -1 if no statement covers its resources
+func bestStatement(existing *policy.IAMPolicy, action string, resources []string) int {
+	service, _, _ := strings.Cut(action, ":")
+
+	best, bestSameService, bestWidth := -1, false, 0
+	for i, stmt := range existing.Statement {
+		if stmt.Effect != "Allow" || len(stmt.Action) == 0 || len(stmt.NotResource) > 0 || len(stmt.Condition) > 0 {
+			continue
+		}
+		if !coversResources(stmt.Resource, resources) {
+			continue
+		}
+
+		sameService := grantsService(stmt.Action, service)
+		width := scopeWidth(stmt.Resource)
+		if best < 0 || sameService && !bestSameService || sameService == bestSameService && width < bestWidth {
+			best, bestSameService, bestWidth = i, sameService, width
+		}
+	}
+	return best
+}
+
+// containsAction checks if a statement's actions include an action
+// verbatim, ignoring case
+func containsAction(actions []string, action string) bool {
+	for _, a := range actions {
+		if NormalizeAction(a) == NormalizeAction(action) {
+			return true
+		}
+	}
+	return false
+}
+
+// grantsService checks if any of the actions belongs to a service
+func grantsService(actions []string, service string) bool {
+	for _, a := range actions {
+		if s, _, _ := strings.Cut(a, ":"); strings.EqualFold(s, service) {
+			return true
+		}
+	}
+	return false
+}
+
+// coversResources checks if every required resource is matched by one of
+// the granted resource patterns
+func coversResources(granted, required []string) bool {
+	for _, r := range required {
+		covered := false
+		for _, pattern := range granted {
+			if matchResource(pattern, r) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// scopeWidth estimates how broad resources are: "*" counts most, then each
+// wildcard in an ARN
+func scopeWidth(resources []string) int {
+	width := 0
+	for _, r := range resources {
+		if r == "*" {
+			width += 1000
+			continue
+		}
+		width += strings.Count(r, "*") + strings.Count(r, "?")
+	}
+	return width
+}
+
+// matchResource matches a resource against an ARN pattern with * and ?
+// wildcards. Wildcards in the resource are matched literally, so a pattern
+// only covers a wildcard resource if it is at least as broad.
+func matchResource(pattern, resource string) bool {
+	if pattern == "" {
+		return resource == ""
+	}
+	switch pattern[0] {
+	case '*':
+		for i := 0; i <= len(resource); i++ {
+			if matchResource(pattern[1:], resource[i:]) {
+				return true
+			}
+		}
+		return false
+	case '?':
+		return resource != "" && resource[0] != '*' && matchResource(pattern[1:], resource[1:])
+	default:
+		return resource != "" && pattern[0] == resource[0] && matchResource(pattern[1:], resource[1:])
+	}
+}
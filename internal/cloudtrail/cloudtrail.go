@@ -0,0 +1,327 @@
+// Package cloudtrail reads CloudTrail events to find which actions a principal actually used
+package cloudtrail
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	ct "github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/mizzy/least/internal/offline"
+)
+
+// Event is a CloudTrail event record
+type Event struct {
+	EventTime    time.Time    `json:"eventTime"`
+	EventSource  string       `json:"eventSource"`
+	EventName    string       `json:"eventName"`
+	UserIdentity UserIdentity `json:"userIdentity"`
+}
+
+// UserIdentity identifies the principal that made a request
+type UserIdentity struct {
+	Type           string `json:"type"`
+	ARN            string `json:"arn"`
+	SessionContext struct {
+		SessionIssuer struct {
+			ARN string `json:"arn"`
+		} `json:"sessionIssuer"`
+	} `json:"sessionContext"`
+}
+
+// Window is the time range to consider events in
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Contains checks if a time is within the window
+func (w Window) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// servicePrefixes maps event sources whose host differs from the IAM service prefix
+var servicePrefixes = map[string]string{
+	"monitoring": "cloudwatch",
+	"email":      "ses",
+	"tagging":    "tag",
+}
+
+// apiVersionSuffix matches the API version some services append to event names
+// (e.g., Lambda's GetFunction20150331v2)
+var apiVersionSuffix = regexp.MustCompile(`\d{8}(v\d+)?$`)
+
+// Action returns the IAM action for the event (e.g., s3:GetObject)
+func (e Event) Action() string {
+	service := strings.TrimSuffix(e.EventSource, ".amazonaws.com")
+	if prefix, ok := servicePrefixes[service]; ok {
+		service = prefix
+	}
+	return service + ":" + apiVersionSuffix.ReplaceAllString(e.EventName, "")
+}
+
+// IssuedBy checks if the event was made by the principal, directly or via an assumed-role session
+func (e Event) IssuedBy(principalARN string) bool {
+	return e.UserIdentity.ARN == principalARN || e.UserIdentity.SessionContext.SessionIssuer.ARN == principalARN
+}
+
+// UsedActions returns the lowercased set of actions the principal used
+func UsedActions(events []Event, principalARN string) map[string]bool {
+	used := make(map[string]bool)
+	for _, e := range events {
+		if principalARN == "" || e.IssuedBy(principalARN) {
+			used[strings.ToLower(e.Action())] = true
+		}
+	}
+	return used
+}
+
+// Unused returns the actions that do not appear in the used set
+func Unused(actions []string, used map[string]bool) []string {
+	var unused []string
+	for _, action := range actions {
+		if !used[strings.ToLower(action)] {
+			unused = append(unused, action)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
+
+// S3API is the subset of the S3 client used to read archived log files
+type S3API interface {
+	s3.ListObjectsV2APIClient
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// LakeAPI is the subset of the CloudTrail client used to query CloudTrail Lake
+type LakeAPI interface {
+	StartQuery(ctx context.Context, params *ct.StartQueryInput, optFns ...func(*ct.Options)) (*ct.StartQueryOutput, error)
+	GetQueryResults(ctx context.Context, params *ct.GetQueryResultsInput, optFns ...func(*ct.Options)) (*ct.GetQueryResultsOutput, error)
+}
+
+// loadConfig loads the default AWS configuration unless AWS calls are disabled
+func loadConfig(ctx context.Context) (aws.Config, error) {
+	if offline.Enabled() {
+		return aws.Config{}, offline.ErrOffline
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("loading AWS config: %w", err)
+	}
+	return cfg, nil
+}
+
+// ReadArchive reads CloudTrail log files (.json or .json.gz) from a local
+// directory or an s3:// prefix and returns the events within the window.
+// S3 archives are read with the S3 API in the configured region; narrow the
+// prefix to the accounts, regions, and dates of interest.
+func ReadArchive(ctx context.Context, location string, window Window) ([]Event, error) {
+	if strings.HasPrefix(location, "s3://") {
+		cfg, err := loadConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return readS3Archive(ctx, s3.NewFromConfig(cfg), location, window)
+	}
+
+	var events []Event
+	err := filepath.WalkDir(location, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isLogFile(path) {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		records, err := readLog(f, strings.HasSuffix(path, ".gz"))
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		events = append(events, inWindow(records, window)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// readS3Archive reads the log files under an s3:// prefix
+func readS3Archive(ctx context.Context, api S3API, location string, window Window) ([]Event, error) {
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(location, "s3://"), "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("invalid S3 location: %s", location)
+	}
+
+	var events []Event
+	paginator := s3.NewListObjectsV2Paginator(api, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing CloudTrail archive: %w", err)
+		}
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			if !isLogFile(key) {
+				continue
+			}
+			records, err := readS3Object(ctx, api, bucket, key)
+			if err != nil {
+				return nil, fmt.Errorf("reading s3://%s/%s: %w", bucket, key, err)
+			}
+			events = append(events, inWindow(records, window)...)
+		}
+	}
+	return events, nil
+}
+
+// readS3Object reads the records of a single log file stored in S3
+func readS3Object(ctx context.Context, api S3API, bucket, key string) ([]Event, error) {
+	out, err := api.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return readLog(out.Body, strings.HasSuffix(key, ".gz"))
+}
+
+// isLogFile checks if a path or key names a CloudTrail log file
+func isLogFile(name string) bool {
+	return strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".json.gz")
+}
+
+// inWindow returns the events within the window
+func inWindow(events []Event, window Window) []Event {
+	var in []Event
+	for _, e := range events {
+		if window.Contains(e.EventTime) {
+			in = append(in, e)
+		}
+	}
+	return in
+}
+
+// readLog reads the records of a single CloudTrail log file
+func readLog(r io.Reader, gzipped bool) ([]Event, error) {
+	if gzipped {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var log struct {
+		Records []Event `json:"Records"`
+	}
+	if err := json.NewDecoder(r).Decode(&log); err != nil {
+		return nil, err
+	}
+	return log.Records, nil
+}
+
+// lakePollInterval is the delay between CloudTrail Lake query status checks
+const lakePollInterval = 2 * time.Second
+
+// QueryLake returns the distinct actions the principal used according to a
+// CloudTrail Lake event data store
+func QueryLake(ctx context.Context, eventDataStore, principalARN string, window Window) ([]Event, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return queryLake(ctx, ct.NewFromConfig(cfg), eventDataStore, principalARN, window)
+}
+
+// queryLake runs the CloudTrail Lake query with the given client
+func queryLake(ctx context.Context, api LakeAPI, eventDataStore, principalARN string, window Window) ([]Event, error) {
+	statement := fmt.Sprintf(
+		"SELECT DISTINCT eventSource, eventName FROM %s WHERE (userIdentity.arn = '%s' OR userIdentity.sessionContext.sessionIssuer.arn = '%s') AND eventTime >= '%s' AND eventTime < '%s'",
+		lakeTableName(eventDataStore), principalARN, principalARN,
+		window.Start.UTC().Format("2006-01-02 15:04:05"), window.End.UTC().Format("2006-01-02 15:04:05"),
+	)
+
+	started, err := api.StartQuery(ctx, &ct.StartQueryInput{QueryStatement: aws.String(statement)})
+	if err != nil {
+		return nil, fmt.Errorf("starting CloudTrail Lake query: %w", err)
+	}
+
+	var events []Event
+	var nextToken *string
+	for {
+		results, err := api.GetQueryResults(ctx, &ct.GetQueryResultsInput{
+			QueryId:   started.QueryId,
+			NextToken: nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("getting CloudTrail Lake query results: %w", err)
+		}
+
+		switch results.QueryStatus {
+		case types.QueryStatusQueued, types.QueryStatusRunning:
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(lakePollInterval):
+			}
+			continue
+		case types.QueryStatusFinished:
+		default:
+			return nil, fmt.Errorf("CloudTrail Lake query %s: %s", strings.ToLower(string(results.QueryStatus)), aws.ToString(results.ErrorMessage))
+		}
+
+		for _, row := range results.QueryResultRows {
+			var e Event
+			for _, col := range row {
+				if v, ok := col["eventSource"]; ok {
+					e.EventSource = v
+				}
+				if v, ok := col["eventName"]; ok {
+					e.EventName = v
+				}
+			}
+			events = append(events, e)
+		}
+
+		if aws.ToString(results.NextToken) == "" {
+			return events, nil
+		}
+		nextToken = results.NextToken
+	}
+}
+
+// lakeTableName returns the table name for an event data store ARN or ID
+func lakeTableName(eventDataStore string) string {
+	if i := strings.LastIndex(eventDataStore, "/"); i >= 0 {
+		return eventDataStore[i+1:]
+	}
+	return eventDataStore
+}
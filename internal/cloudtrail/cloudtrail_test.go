@@ -0,0 +1,191 @@
+package cloudtrail
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ct "github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const roleARN = "arn:aws:iam::123456789012:role/deploy"
+
+func TestEventAction(t *testing.T) {
+	tests := []struct {
+		source string
+		name   string
+		want   string
+	}{
+		{"s3.amazonaws.com", "GetObject", "s3:GetObject"},
+		{"lambda.amazonaws.com", "GetFunction20150331v2", "lambda:GetFunction"},
+		{"lambda.amazonaws.com", "ListFunctions20150331", "lambda:ListFunctions"},
+		{"monitoring.amazonaws.com", "PutMetricAlarm", "cloudwatch:PutMetricAlarm"},
+	}
+
+	for _, tt := range tests {
+		e := Event{EventSource: tt.source, EventName: tt.name}
+		if got := e.Action(); got != tt.want {
+			t.Errorf("Action(%s, %s) = %s, want %s", tt.source, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadArchiveAndUnused(t *testing.T) {
+	dir := t.TempDir()
+	log := `{"Records": [
+		{"eventTime": "2026-01-10T00:00:00Z", "eventSource": "sqs.amazonaws.com", "eventName": "CreateQueue",
+		 "userIdentity": {"type": "AssumedRole", "arn": "arn:aws:sts::123456789012:assumed-role/deploy/ci",
+		  "sessionContext": {"sessionIssuer": {"arn": "` + roleARN + `"}}}},
+		{"eventTime": "2026-01-10T00:00:00Z", "eventSource": "sqs.amazonaws.com", "eventName": "DeleteQueue",
+		 "userIdentity": {"type": "IAMUser", "arn": "arn:aws:iam::123456789012:user/someone"}},
+		{"eventTime": "2025-01-01T00:00:00Z", "eventSource": "sqs.amazonaws.com", "eventName": "GetQueueAttributes",
+		 "userIdentity": {"type": "AssumedRole", "sessionContext": {"sessionIssuer": {"arn": "` + roleARN + `"}}}}
+	]}`
+
+	f, err := os.Create(filepath.Join(dir, "123456789012_CloudTrail_us-east-1_20260110T0000Z_abc.json.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write([]byte(log)); err != nil {
+		t.Fatal(err)
+	}
+	gz.Close()
+	f.Close()
+
+	window := Window{
+		Start: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	events, err := ReadArchive(context.Background(), dir, window)
+	if err != nil {
+		t.Fatalf("ReadArchive() error = %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events in window, want 2", len(events))
+	}
+
+	used := UsedActions(events, roleARN)
+	got := Unused([]string{"sqs:CreateQueue", "sqs:DeleteQueue", "sqs:GetQueueAttributes"}, used)
+	want := []string{"sqs:DeleteQueue", "sqs:GetQueueAttributes"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unused() = %v, want %v", got, want)
+	}
+}
+
+type fakeS3 struct {
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	out := &s3.ListObjectsV2Output{}
+	for key := range f.objects {
+		out.Contents = append(out.Contents, s3types.Object{Key: aws.String(key)})
+	}
+	return out, nil
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	data, ok := f.objects[aws.ToString(params.Key)]
+	if !ok {
+		return nil, fmt.Errorf("NoSuchKey: %s", aws.ToString(params.Key))
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func TestReadS3Archive(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(`{"Records": [{"eventTime": "2026-01-10T00:00:00Z", "eventSource": "sqs.amazonaws.com", "eventName": "CreateQueue"}]}`))
+	gz.Close()
+
+	api := &fakeS3{objects: map[string][]byte{
+		"AWSLogs/123456789012/CloudTrail/us-east-1/2026/01/10/a.json.gz": buf.Bytes(),
+		"AWSLogs/123456789012/CloudTrail/us-east-1/2026/01/10/b.json":    []byte(`{"Records": [{"eventTime": "2025-01-10T00:00:00Z", "eventSource": "sqs.amazonaws.com", "eventName": "DeleteQueue"}]}`),
+		"AWSLogs/123456789012/CloudTrail-Digest/manifest.txt":            []byte("not a log"),
+	}}
+
+	window := Window{
+		Start: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	events, err := readS3Archive(context.Background(), api, "s3://trail-bucket/AWSLogs/", window)
+	if err != nil {
+		t.Fatalf("readS3Archive() error = %v", err)
+	}
+	if len(events) != 1 || events[0].Action() != "sqs:CreateQueue" {
+		t.Errorf("readS3Archive() = %+v, want the CreateQueue event only", events)
+	}
+
+	if _, err := readS3Archive(context.Background(), api, "s3://", window); err == nil {
+		t.Error("readS3Archive() with no bucket should fail")
+	}
+}
+
+type fakeLake struct {
+	statement string
+	pages     []*ct.GetQueryResultsOutput
+}
+
+func (f *fakeLake) StartQuery(ctx context.Context, params *ct.StartQueryInput, optFns ...func(*ct.Options)) (*ct.StartQueryOutput, error) {
+	f.statement = aws.ToString(params.QueryStatement)
+	return &ct.StartQueryOutput{QueryId: aws.String("q-1")}, nil
+}
+
+func (f *fakeLake) GetQueryResults(ctx context.Context, params *ct.GetQueryResultsInput, optFns ...func(*ct.Options)) (*ct.GetQueryResultsOutput, error) {
+	page := f.pages[0]
+	f.pages = f.pages[1:]
+	return page, nil
+}
+
+func TestQueryLake(t *testing.T) {
+	api := &fakeLake{pages: []*ct.GetQueryResultsOutput{
+		{
+			QueryStatus:     types.QueryStatusFinished,
+			QueryResultRows: [][]map[string]string{{{"eventSource": "s3.amazonaws.com"}, {"eventName": "GetObject"}}},
+			NextToken:       aws.String("next"),
+		},
+		{
+			QueryStatus:     types.QueryStatusFinished,
+			QueryResultRows: [][]map[string]string{{{"eventSource": "sqs.amazonaws.com"}, {"eventName": "SendMessage"}}},
+		},
+	}}
+
+	window := Window{
+		Start: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	events, err := queryLake(context.Background(), api, "arn:aws:cloudtrail:us-east-1:123456789012:eventdatastore/eds-1", roleARN, window)
+	if err != nil {
+		t.Fatalf("queryLake() error = %v", err)
+	}
+
+	var got []string
+	for _, e := range events {
+		got = append(got, e.Action())
+	}
+	want := []string{"s3:GetObject", "sqs:SendMessage"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("queryLake() = %v, want %v", got, want)
+	}
+	if !strings.Contains(api.statement, "FROM eds-1 WHERE") {
+		t.Errorf("statement = %q, want the event data store ID as table", api.statement)
+	}
+
+	api = &fakeLake{pages: []*ct.GetQueryResultsOutput{{QueryStatus: types.QueryStatusFailed, ErrorMessage: aws.String("syntax error")}}}
+	if _, err := queryLake(context.Background(), api, "eds-1", roleARN, window); err == nil || err.Error() != "CloudTrail Lake query failed: syntax error" {
+		t.Errorf("queryLake() error = %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+// Package companion derives the resource-based policies that cross-service
+// access implied by IaC needs, such as the bucket policy letting a
+// CloudFront distribution read its S3 origin or the Lambda permission
+// letting an EventBridge rule invoke its target, and renders them as
+// Terraform snippets to add next to the resources.
+package companion
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+// Grant is access a service needs to a resource on behalf of another
+// resource, the source its requests are scoped to with aws:SourceArn
+type Grant struct {
+	// Target is the address of the resource accessed (e.g., "aws_s3_bucket.assets")
+	Target string
+	// Source is the address of the resource the service acts for
+	// (e.g., "aws_cloudfront_distribution.cdn")
+	Source string
+	// Service is the service principal (e.g., "cloudfront.amazonaws.com")
+	Service string
+	// Actions are the actions the service performs on the target
+	Actions []string
+}
+
+// wiring is a resource type connecting a source to targets, such as an
+// EventBridge target connecting a rule to a function. An empty via means
+// the source refers to its targets directly.
+type wiring struct {
+	via     string
+	sources []string
+	service string
+}
+
+// wirings are the cross-service connections resource policies are derived for
+var wirings = []wiring{
+	{sources: []string{"aws_cloudfront_distribution"}, service: "cloudfront.amazonaws.com"},
+	{via: "aws_cloudwatch_event_target", sources: []string{"aws_cloudwatch_event_rule"}, service: "events.amazonaws.com"},
+	{via: "aws_sns_topic_subscription", sources: []string{"aws_sns_topic"}, service: "sns.amazonaws.com"},
+	{via: "aws_s3_bucket_notification", sources: []string{"aws_s3_bucket"}, service: "s3.amazonaws.com"},
+	{via: "aws_apigatewayv2_integration", sources: []string{"aws_apigatewayv2_api"}, service: "apigateway.amazonaws.com"},
+	{via: "aws_api_gateway_integration", sources: []string{"aws_api_gateway_rest_api"}, service: "apigateway.amazonaws.com"},
+}
+
+// targetActions are the actions a service performs on each target type
+var targetActions = map[string]map[string][]string{
+	"aws_s3_bucket": {
+		"cloudfront.amazonaws.com": {"s3:GetObject"},
+	},
+	"aws_lambda_function": {
+		"events.amazonaws.com":     {"lambda:InvokeFunction"},
+		"sns.amazonaws.com":        {"lambda:InvokeFunction"},
+		"s3.amazonaws.com":         {"lambda:InvokeFunction"},
+		"apigateway.amazonaws.com": {"lambda:InvokeFunction"},
+	},
+	"aws_sqs_queue": {
+		"events.amazonaws.com": {"sqs:SendMessage"},
+		"sns.amazonaws.com":    {"sqs:SendMessage"},
+		"s3.amazonaws.com":     {"sqs:SendMessage"},
+	},
+	"aws_sns_topic": {
+		"events.amazonaws.com": {"sns:Publish"},
+		"s3.amazonaws.com":     {"sns:Publish"},
+	},
+}
+
+// policyTypes are the resource policy types that grant access to each target
+// type, used to skip targets the IaC already has a policy for
+var policyTypes = map[string]string{
+	"aws_s3_bucket":       "aws_s3_bucket_policy",
+	"aws_lambda_function": "aws_lambda_permission",
+	"aws_sqs_queue":       "aws_sqs_queue_policy",
+	"aws_sns_topic":       "aws_sns_topic_policy",
+}
+
+// Grants returns the grants implied by the references between resources of
+// the root module, sorted by target and source. Grants the IaC already has a
+// resource policy for (a policy resource referring to the target, and for
+// Lambda permissions to the source as well) are left out. Resources of child
+// modules are skipped, since snippets in the root module cannot refer to them.
+func Grants(resources []provider.Resource) []Grant {
+	types := make(map[string]string)
+	for _, res := range resources {
+		if res.Module == "" {
+			types[res.Address()] = res.Type
+		}
+	}
+
+	seen := make(map[string]bool)
+	var grants []Grant
+	add := func(source, target, service string) {
+		actions, ok := targetActions[types[target]][service]
+		if !ok {
+			return
+		}
+		key := source + " " + target
+		if seen[key] || hasPolicy(resources, types[target], source, target) {
+			return
+		}
+		seen[key] = true
+		grants = append(grants, Grant{Target: target, Source: source, Service: service, Actions: actions})
+	}
+
+	for _, res := range resources {
+		if res.Module != "" {
+			continue
+		}
+		for _, w := range wirings {
+			if w.via == "" {
+				if slices.Contains(w.sources, res.Type) {
+					for _, ref := range res.References {
+						add(res.Address(), ref, w.service)
+					}
+				}
+				continue
+			}
+			if res.Type != w.via {
+				continue
+			}
+			for _, source := range res.References {
+				if !slices.Contains(w.sources, types[source]) {
+					continue
+				}
+				for _, target := range res.References {
+					if target != source {
+						add(source, target, w.service)
+					}
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(grants, func(i, j int) bool {
+		if grants[i].Target != grants[j].Target {
+			return grants[i].Target < grants[j].Target
+		}
+		return grants[i].Source < grants[j].Source
+	})
+	return grants
+}
+
+// hasPolicy checks if a resource policy of the IaC already grants access to
+// the target; Lambda permissions must also refer to the source
+func hasPolicy(resources []provider.Resource, targetType, source, target string) bool {
+	policyType := policyTypes[targetType]
+	for _, res := range resources {
+		if res.Type != policyType || !slices.Contains(res.References, target) {
+			continue
+		}
+		if policyType != "aws_lambda_permission" || slices.Contains(res.References, source) {
+			return true
+		}
+	}
+	return false
+}
+
+// ToTerraform renders the grants as Terraform snippets: one aws_lambda_permission
+// per grant to a function, and one policy per bucket, queue or topic, with a
+// statement per source
+func ToTerraform(grants []Grant) string {
+	var b strings.Builder
+	var documents []string
+	statements := make(map[string][]Grant)
+	for _, g := range grants {
+		if strings.HasPrefix(g.Target, "aws_lambda_function.") {
+			writeLambdaPermission(&b, g)
+			continue
+		}
+		if _, ok := statements[g.Target]; !ok {
+			documents = append(documents, g.Target)
+		}
+		statements[g.Target] = append(statements[g.Target], g)
+	}
+	for _, target := range documents {
+		writeResourcePolicy(&b, target, statements[target])
+	}
+	return b.String()
+}
+
+// writeLambdaPermission writes the aws_lambda_permission of a grant
+func writeLambdaPermission(b *strings.Builder, g Grant) {
+	name := resourceName(g.Source) + "_" + resourceName(g.Target)
+	fmt.Fprintf(b, "resource \"aws_lambda_permission\" %q {\n", name)
+	fmt.Fprintf(b, "  statement_id  = %q\n", "Allow-"+strings.ReplaceAll(g.Source, ".", "-"))
+	fmt.Fprintf(b, "  action        = %q\n", g.Actions[0])
+	fmt.Fprintf(b, "  function_name = %s.function_name\n", g.Target)
+	fmt.Fprintf(b, "  principal     = %q\n", g.Service)
+	fmt.Fprintf(b, "  source_arn    = %s\n", sourceARN(g.Source))
+	b.WriteString("}\n\n")
+}
+
+// writeResourcePolicy writes the policy document and policy resource of a
+// bucket, queue or topic
+func writeResourcePolicy(b *strings.Builder, target string, grants []Grant) {
+	name := resourceName(target) + "_access"
+	fmt.Fprintf(b, "data \"aws_iam_policy_document\" %q {\n", name)
+	for _, g := range grants {
+		resource := g.Target + ".arn"
+		if strings.HasPrefix(target, "aws_s3_bucket.") {
+			resource = fmt.Sprintf("\"${%s.arn}/*\"", g.Target)
+		}
+		b.WriteString("  statement {\n")
+		fmt.Fprintf(b, "    actions   = [%s]\n", quoteAll(g.Actions))
+		fmt.Fprintf(b, "    resources = [%s]\n\n", resource)
+		b.WriteString("    principals {\n")
+		b.WriteString("      type        = \"Service\"\n")
+		fmt.Fprintf(b, "      identifiers = [%q]\n", g.Service)
+		b.WriteString("    }\n\n")
+		b.WriteString("    condition {\n")
+		b.WriteString("      test     = \"ArnEquals\"\n")
+		b.WriteString("      variable = \"aws:SourceArn\"\n")
+		fmt.Fprintf(b, "      values   = [%s]\n", sourceARN(g.Source))
+		b.WriteString("    }\n")
+		b.WriteString("  }\n")
+	}
+	b.WriteString("}\n\n")
+
+	switch {
+	case strings.HasPrefix(target, "aws_s3_bucket."):
+		fmt.Fprintf(b, "resource \"aws_s3_bucket_policy\" %q {\n", name)
+		fmt.Fprintf(b, "  bucket = %s.id\n", target)
+	case strings.HasPrefix(target, "aws_sqs_queue."):
+		fmt.Fprintf(b, "resource \"aws_sqs_queue_policy\" %q {\n", name)
+		fmt.Fprintf(b, "  queue_url = %s.id\n", target)
+	case strings.HasPrefix(target, "aws_sns_topic."):
+		fmt.Fprintf(b, "resource \"aws_sns_topic_policy\" %q {\n", name)
+		fmt.Fprintf(b, "  arn    = %s.arn\n", target)
+	}
+	fmt.Fprintf(b, "  policy = data.aws_iam_policy_document.%s.json\n", name)
+	b.WriteString("}\n\n")
+}
+
+// sourceARN returns the expression of the ARN requests of a source carry as
+// aws:SourceArn; API Gateway uses the execution ARN of its methods
+func sourceARN(source string) string {
+	if strings.HasPrefix(source, "aws_apigatewayv2_api.") || strings.HasPrefix(source, "aws_api_gateway_rest_api.") {
+		return fmt.Sprintf("\"${%s.execution_arn}/*\"", source)
+	}
+	return source + ".arn"
+}
+
+// resourceName returns the name of a resource address
+func resourceName(address string) string {
+	return address[strings.LastIndex(address, ".")+1:]
+}
+
+// quoteAll returns the values quoted and comma-separated
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(quoted, ", ")
+}
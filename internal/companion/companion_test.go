@@ -0,0 +1,182 @@
+package companion
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/provider"
+	"github.com/mizzy/least/internal/provider/terraform"
+)
+
+func TestGrants(t *testing.T) {
+	res := func(address string, refs ...string) provider.Resource {
+		typ, name, _ := strings.Cut(address, ".")
+		return provider.Resource{Type: typ, Name: name, References: refs}
+	}
+	resources := []provider.Resource{
+		res("aws_s3_bucket.assets"),
+		res("aws_cloudfront_distribution.cdn", "aws_s3_bucket.assets", "aws_cloudfront_origin_access_control.oac"),
+		res("aws_cloudwatch_event_rule.nightly"),
+		res("aws_lambda_function.report", "aws_iam_role.report"),
+		res("aws_sqs_queue.dlq"),
+		res("aws_cloudwatch_event_target.report", "aws_cloudwatch_event_rule.nightly", "aws_lambda_function.report", "aws_sqs_queue.dlq"),
+		res("aws_sns_topic.alerts"),
+		res("aws_lambda_function.notify"),
+		res("aws_sns_topic_subscription.notify", "aws_lambda_function.notify", "aws_sns_topic.alerts"),
+		// Already granted by a permission in the IaC
+		res("aws_lambda_permission.alerts", "aws_lambda_function.notify", "aws_sns_topic.alerts"),
+	}
+
+	var got []string
+	for _, g := range Grants(resources) {
+		got = append(got, g.Source+" -> "+g.Target+" ("+g.Service+")")
+	}
+	want := []string{
+		"aws_cloudwatch_event_rule.nightly -> aws_lambda_function.report (events.amazonaws.com)",
+		"aws_cloudfront_distribution.cdn -> aws_s3_bucket.assets (cloudfront.amazonaws.com)",
+		"aws_cloudwatch_event_rule.nightly -> aws_sqs_queue.dlq (events.amazonaws.com)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Grants() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	hcl := ToTerraform(Grants(resources))
+	for _, s := range []string{
+		`resource "aws_lambda_permission" "nightly_report" {`,
+		`  source_arn    = aws_cloudwatch_event_rule.nightly.arn`,
+		`    resources = ["${aws_s3_bucket.assets.arn}/*"]`,
+		`resource "aws_s3_bucket_policy" "assets_access" {`,
+		`  queue_url = aws_sqs_queue.dlq.id`,
+	} {
+		if !strings.Contains(hcl, s) {
+			t.Errorf("ToTerraform() missing %q:\n%s", s, hcl)
+		}
+	}
+}
+
+func TestEndpointPolicies(t *testing.T) {
+	endpoint := func(name, service string) provider.Resource {
+		return provider.Resource{Type: "aws_vpc_endpoint", Name: name, Attributes: map[string]interface{}{
+			"service_name": terraform.AttributeValue{Literal: service},
+		}}
+	}
+	resources := []provider.Resource{
+		endpoint("s3", "com.amazonaws.*.s3"),
+		endpoint("ecr", "com.amazonaws.us-east-1.ecr.dkr"),
+		endpoint("kms", "com.amazonaws.us-east-1.kms"),
+		endpoint("logs", "com.amazonaws.us-east-1.logs"),
+		{Type: "aws_vpc_endpoint_policy", Name: "logs", References: []string{"aws_vpc_endpoint.logs"}},
+	}
+	required := &policy.IAMPolicy{Statement: []policy.Statement{
+		{Effect: "Allow", Action: []string{"s3:CreateBucket"}, Resource: []string{"arn:aws:s3:::data", "arn:aws:s3:::data/*"}},
+		{Effect: "Allow", Action: []string{"lambda:CreateFunction", "s3:GetObject"}, Resource: []string{"arn:aws:lambda:*:*:function:fn"}},
+		{Effect: "Allow", Action: []string{"ecr:CreateRepository"}, Resource: []string{"*"}},
+		{Effect: "Allow", Action: []string{"logs:CreateLogGroup"}, Resource: []string{"*"}},
+	}}
+
+	var got []string
+	for _, ep := range EndpointPolicies(resources, required) {
+		got = append(got, ep.Endpoint+" "+ep.Service+" "+strings.Join(ep.Resources, ","))
+	}
+	want := []string{
+		"aws_vpc_endpoint.ecr ecr *",
+		"aws_vpc_endpoint.s3 s3 arn:aws:s3:::data,arn:aws:s3:::data/*",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("EndpointPolicies() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRepositoryAccesses(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "aws_ecr_repository", Name: "app"},
+		{Type: "aws_ecr_repository", Name: "unused"},
+		{Type: "aws_ecr_repository", Name: "managed"},
+		{Type: "aws_ecr_repository_policy", Name: "managed", References: []string{"aws_ecr_repository.managed"}},
+		{Type: "aws_ecs_task_definition", Name: "app", References: []string{"aws_ecr_repository.app", "aws_iam_role.exec"}, Attributes: map[string]interface{}{
+			"execution_role_arn": terraform.AttributeValue{Reference: "aws_iam_role.exec.arn"},
+		}},
+		{Type: "aws_lambda_function", Name: "worker", References: []string{"aws_ecr_repository.app"}},
+	}
+
+	got := RepositoryAccesses(resources)
+	if len(got) != 1 || got[0].Repository != "aws_ecr_repository.app" ||
+		strings.Join(got[0].Roles, ",") != "aws_iam_role.exec.arn" || strings.Join(got[0].Functions, ",") != "aws_lambda_function.worker" {
+		t.Fatalf("RepositoryAccesses() = %+v", got)
+	}
+
+	// Node roles of EKS node groups pull from every repository
+	resources = append(resources, provider.Resource{Type: "aws_eks_node_group", Name: "nodes", Attributes: map[string]interface{}{
+		"node_role_arn": terraform.AttributeValue{Literal: "arn:aws:iam::123456789012:role/nodes"},
+	}})
+	got = RepositoryAccesses(resources)
+	if len(got) != 2 || got[1].Repository != "aws_ecr_repository.unused" || strings.Join(got[1].Roles, ",") != `"arn:aws:iam::123456789012:role/nodes"` {
+		t.Fatalf("RepositoryAccesses() with node group = %+v", got)
+	}
+
+	hcl := RepositoryAccessesToTerraform(got)
+	for _, s := range []string{
+		`identifiers = ["arn:aws:iam::123456789012:role/nodes", aws_iam_role.exec.arn]`,
+		`values   = [aws_lambda_function.worker.arn]`,
+		`resource "aws_ecr_repository_policy" "app_pull" {`,
+		`actions   = ["ecr:GetAuthorizationToken"]`,
+		`"ecr:PutImage",`,
+		`      aws_ecr_repository.unused.arn,`,
+	} {
+		if !strings.Contains(hcl, s) {
+			t.Errorf("RepositoryAccessesToTerraform() missing %q:\n%s", s, hcl)
+		}
+	}
+}
+
+func TestRoleGrants(t *testing.T) {
+	res := func(address string, refs ...string) provider.Resource {
+		typ, name, _ := strings.Cut(address, ".")
+		return provider.Resource{Type: typ, Name: name, References: refs}
+	}
+	pipe := res("aws_pipes_pipe.orders", "aws_iam_role.pipe", "aws_sfn_state_machine.fulfil", "aws_sqs_queue.orders")
+	pipe.Attributes = map[string]interface{}{"source": terraform.AttributeValue{Reference: "aws_sqs_queue.orders.arn"}}
+	resources := []provider.Resource{
+		res("aws_iam_role.scheduler"),
+		res("aws_iam_role.pipe"),
+		res("aws_iam_role.events"),
+		res("aws_lambda_function.report"),
+		res("aws_sqs_queue.orders"),
+		res("aws_sqs_queue.dlq"),
+		res("aws_sfn_state_machine.fulfil"),
+		res("aws_kinesis_stream.audit"),
+		res("aws_cloudwatch_event_rule.audit"),
+		res("aws_scheduler_schedule.nightly", "aws_iam_role.scheduler", "aws_lambda_function.report", "aws_sqs_queue.dlq"),
+		pipe,
+		res("aws_cloudwatch_event_target.audit", "aws_cloudwatch_event_rule.audit", "aws_iam_role.events", "aws_kinesis_stream.audit", "aws_sqs_queue.dlq"),
+		// Already granted by an inline policy of the role
+		res("aws_iam_role_policy.scheduler", "aws_iam_role.scheduler", "aws_sqs_queue.dlq"),
+	}
+
+	var got []string
+	for _, g := range RoleGrants(resources) {
+		got = append(got, g.Role+" "+g.Source+" -> "+g.Target+" "+strings.Join(g.Actions, ","))
+	}
+	want := []string{
+		"aws_iam_role.events aws_cloudwatch_event_target.audit -> aws_kinesis_stream.audit kinesis:PutRecord,kinesis:PutRecords",
+		"aws_iam_role.pipe aws_pipes_pipe.orders -> aws_sfn_state_machine.fulfil states:StartExecution",
+		"aws_iam_role.pipe aws_pipes_pipe.orders -> aws_sqs_queue.orders sqs:DeleteMessage,sqs:GetQueueAttributes,sqs:ReceiveMessage",
+		"aws_iam_role.scheduler aws_scheduler_schedule.nightly -> aws_lambda_function.report lambda:InvokeFunction",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("RoleGrants() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	hcl := RoleGrantsToTerraform(RoleGrants(resources))
+	for _, s := range []string{
+		`data "aws_iam_policy_document" "pipe_targets" {`,
+		`    resources = [aws_sqs_queue.orders.arn]`,
+		`resource "aws_iam_role_policy" "scheduler_targets" {`,
+		`  role   = aws_iam_role.scheduler.id`,
+	} {
+		if !strings.Contains(hcl, s) {
+			t.Errorf("RoleGrantsToTerraform() missing %q:\n%s", s, hcl)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package companion
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/provider"
+	"github.com/mizzy/least/internal/provider/terraform"
+)
+
+// ecrPullActions let a principal pull the images of a repository
+var ecrPullActions = []string{"ecr:BatchCheckLayerAvailability", "ecr:BatchGetImage", "ecr:GetDownloadUrlForLayer"}
+
+// lambdaPullActions let Lambda pull the image of a function
+var lambdaPullActions = []string{"ecr:BatchGetImage", "ecr:GetDownloadUrlForLayer"}
+
+// ecrPushActions let a build role push images to a repository, and pull the
+// layers it builds on
+var ecrPushActions = []string{
+	"ecr:BatchCheckLayerAvailability",
+	"ecr:BatchGetImage",
+	"ecr:CompleteLayerUpload",
+	"ecr:GetDownloadUrlForLayer",
+	"ecr:InitiateLayerUpload",
+	"ecr:PutImage",
+	"ecr:UploadLayerPart",
+}
+
+// roleAttributes are the attributes of the ECR consumers whose role pulls
+// the images
+var roleAttributes = map[string]string{
+	"aws_ecs_task_definition": "execution_role_arn",
+	"aws_eks_node_group":      "node_role_arn",
+}
+
+// RepositoryAccess is the pull access the consumers of an ECR repository in
+// the configuration need
+type RepositoryAccess struct {
+	// Repository is the address of the aws_ecr_repository
+	Repository string
+	// Roles are Terraform expressions of the ARNs of the roles pulling the
+	// images: execution roles of ECS task definitions referring to the
+	// repository, and node roles of EKS node groups
+	Roles []string
+	// Functions are the addresses of the Lambda functions whose image is in
+	// the repository
+	Functions []string
+}
+
+// RepositoryAccesses returns the pull access of the ECR repositories of the
+// root module that ECS task definitions or Lambda functions refer to, or
+// that the nodes of EKS node groups may pull from, sorted by repository.
+// Repositories with an aws_ecr_repository_policy in the IaC are left out.
+func RepositoryAccesses(resources []provider.Resource) []RepositoryAccess {
+	var nodeRoles []string
+	for _, res := range resources {
+		if res.Type == "aws_eks_node_group" && res.Module == "" {
+			if role := roleExpression(res); role != "" && !slices.Contains(nodeRoles, role) {
+				nodeRoles = append(nodeRoles, role)
+			}
+		}
+	}
+
+	var accesses []RepositoryAccess
+	for _, repo := range resources {
+		if repo.Type != "aws_ecr_repository" || repo.Module != "" || hasRepositoryPolicy(resources, repo.Address()) {
+			continue
+		}
+		access := RepositoryAccess{Repository: repo.Address(), Roles: append([]string(nil), nodeRoles...)}
+		for _, res := range resources {
+			if res.Module != "" || !slices.Contains(res.References, repo.Address()) {
+				continue
+			}
+			switch res.Type {
+			case "aws_ecs_task_definition":
+				if role := roleExpression(res); role != "" && !slices.Contains(access.Roles, role) {
+					access.Roles = append(access.Roles, role)
+				}
+			case "aws_lambda_function":
+				access.Functions = append(access.Functions, res.Address())
+			}
+		}
+		if len(access.Roles) == 0 && len(access.Functions) == 0 {
+			continue
+		}
+		sort.Strings(access.Roles)
+		sort.Strings(access.Functions)
+		accesses = append(accesses, access)
+	}
+
+	sort.Slice(accesses, func(i, j int) bool { return accesses[i].Repository < accesses[j].Repository })
+	return accesses
+}
+
+// roleExpression returns the Terraform expression of the role ARN of an ECR
+// consumer, or "" if it is not set
+func roleExpression(res provider.Resource) string {
+	v, ok := res.Attributes[roleAttributes[res.Type]].(terraform.AttributeValue)
+	switch {
+	case !ok:
+		return ""
+	case v.Literal != "":
+		return fmt.Sprintf("%q", v.Literal)
+	default:
+		return v.Reference
+	}
+}
+
+// hasRepositoryPolicy checks if an aws_ecr_repository_policy of the IaC
+// refers to the repository
+func hasRepositoryPolicy(resources []provider.Resource, repository string) bool {
+	for _, res := range resources {
+		if res.Type == "aws_ecr_repository_policy" && slices.Contains(res.References, repository) {
+			return true
+		}
+	}
+	return false
+}
+
+// RepositoryAccessesToTerraform renders the repository policies of the
+// accesses, and the identity policy document of a build role pushing images
+// to the repositories
+func RepositoryAccessesToTerraform(accesses []RepositoryAccess) string {
+	if len(accesses) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, access := range accesses {
+		name := resourceName(access.Repository) + "_pull"
+		fmt.Fprintf(&b, "data \"aws_iam_policy_document\" %q {\n", name)
+		if len(access.Roles) > 0 {
+			b.WriteString("  statement {\n")
+			fmt.Fprintf(&b, "    actions = [%s]\n\n", quoteAll(ecrPullActions))
+			b.WriteString("    principals {\n")
+			b.WriteString("      type        = \"AWS\"\n")
+			fmt.Fprintf(&b, "      identifiers = [%s]\n", strings.Join(access.Roles, ", "))
+			b.WriteString("    }\n")
+			b.WriteString("  }\n")
+		}
+		if len(access.Functions) > 0 {
+			b.WriteString("  statement {\n")
+			fmt.Fprintf(&b, "    actions = [%s]\n\n", quoteAll(lambdaPullActions))
+			b.WriteString("    principals {\n")
+			b.WriteString("      type        = \"Service\"\n")
+			b.WriteString("      identifiers = [\"lambda.amazonaws.com\"]\n")
+			b.WriteString("    }\n\n")
+			b.WriteString("    condition {\n")
+			b.WriteString("      test     = \"ArnLike\"\n")
+			b.WriteString("      variable = \"aws:SourceArn\"\n")
+			arns := make([]string, len(access.Functions))
+			for i, fn := range access.Functions {
+				arns[i] = fn + ".arn"
+			}
+			fmt.Fprintf(&b, "      values   = [%s]\n", strings.Join(arns, ", "))
+			b.WriteString("    }\n")
+			b.WriteString("  }\n")
+		}
+		b.WriteString("}\n\n")
+
+		fmt.Fprintf(&b, "resource \"aws_ecr_repository_policy\" %q {\n", name)
+		fmt.Fprintf(&b, "  repository = %s.name\n", access.Repository)
+		fmt.Fprintf(&b, "  policy     = data.aws_iam_policy_document.%s.json\n", name)
+		b.WriteString("}\n\n")
+	}
+
+	b.WriteString("# Attach to the build role pushing the images\n")
+	b.WriteString("data \"aws_iam_policy_document\" \"ecr_build\" {\n")
+	b.WriteString("  statement {\n")
+	b.WriteString("    actions   = [\"ecr:GetAuthorizationToken\"]\n")
+	b.WriteString("    resources = [\"*\"]\n")
+	b.WriteString("  }\n\n")
+	b.WriteString("  statement {\n")
+	b.WriteString("    actions = [\n")
+	for _, action := range ecrPushActions {
+		fmt.Fprintf(&b, "      %q,\n", action)
+	}
+	b.WriteString("    ]\n\n")
+	b.WriteString("    resources = [\n")
+	for _, access := range accesses {
+		fmt.Fprintf(&b, "      %s.arn,\n", access.Repository)
+	}
+	b.WriteString("    ]\n")
+	b.WriteString("  }\n")
+	b.WriteString("}\n\n")
+	return b.String()
+}
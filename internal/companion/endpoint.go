@@ -0,0 +1,145 @@
+package companion
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/provider"
+	"github.com/mizzy/least/internal/provider/terraform"
+)
+
+// endpointServices are the IAM service prefixes of endpoint service names
+// whose last part is not the prefix (com.amazonaws.<region>.<name>)
+var endpointServices = map[string]string{
+	"ecr.api":          "ecr",
+	"ecr.dkr":          "ecr",
+	"ecs-agent":        "ecs",
+	"ecs-telemetry":    "ecs",
+	"email-smtp":       "ses",
+	"kinesis-firehose": "firehose",
+	"kinesis-streams":  "kinesis",
+	"monitoring":       "cloudwatch",
+}
+
+// EndpointPolicy is the policy of a VPC endpoint, limited to the resources
+// of its service the configuration defines
+type EndpointPolicy struct {
+	// Endpoint is the address of the aws_vpc_endpoint
+	Endpoint string
+	// Service is the IAM service prefix of the endpoint (e.g., "s3")
+	Service string
+	// Resources are the ARNs the endpoint allows access to
+	Resources []string
+}
+
+// EndpointPolicies returns the policies of the VPC endpoints of the root
+// module whose service is known, allowing the actions of the service only
+// on the resources the required policy grants actions of that service on.
+// Endpoints with an aws_vpc_endpoint_policy in the IaC, or whose service no
+// required action belongs to, are left out.
+func EndpointPolicies(resources []provider.Resource, required *policy.IAMPolicy) []EndpointPolicy {
+	var policies []EndpointPolicy
+	for _, res := range resources {
+		if res.Type != "aws_vpc_endpoint" || res.Module != "" || hasEndpointPolicy(resources, res.Address()) {
+			continue
+		}
+		service := endpointService(res)
+		if service == "" {
+			continue
+		}
+
+		arns := make(map[string]bool)
+		for _, stmt := range required.Statement {
+			if stmt.Effect != "Allow" || !slices.ContainsFunc(stmt.Action, func(a string) bool {
+				return strings.HasPrefix(a, service+":")
+			}) {
+				continue
+			}
+			for _, arn := range stmt.Resource {
+				// Statements of a resource may grant actions of other
+				// services on its ARNs (e.g., s3:GetObject for a function)
+				if arn == "*" || strings.HasPrefix(arn, "arn:aws:"+service+":") {
+					arns[arn] = true
+				}
+			}
+		}
+		if len(arns) == 0 {
+			continue
+		}
+
+		ep := EndpointPolicy{Endpoint: res.Address(), Service: service}
+		if arns["*"] {
+			ep.Resources = []string{"*"}
+		} else {
+			for arn := range arns {
+				ep.Resources = append(ep.Resources, arn)
+			}
+			sort.Strings(ep.Resources)
+		}
+		policies = append(policies, ep)
+	}
+
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Endpoint < policies[j].Endpoint })
+	return policies
+}
+
+// endpointService returns the IAM service prefix of a VPC endpoint from its
+// service name, or "" if it is unknown
+func endpointService(res provider.Resource) string {
+	v, ok := res.Attributes["service_name"].(terraform.AttributeValue)
+	if !ok || !strings.HasPrefix(v.Literal, "com.amazonaws.") {
+		return ""
+	}
+	// com.amazonaws.<region>.<name>
+	parts := strings.SplitN(v.Literal, ".", 4)
+	if len(parts) < 4 || strings.Contains(parts[3], "*") {
+		return ""
+	}
+	if service, ok := endpointServices[parts[3]]; ok {
+		return service
+	}
+	return strings.Split(parts[3], ".")[0]
+}
+
+// hasEndpointPolicy checks if an aws_vpc_endpoint_policy of the IaC refers
+// to the endpoint
+func hasEndpointPolicy(resources []provider.Resource, endpoint string) bool {
+	for _, res := range resources {
+		if res.Type == "aws_vpc_endpoint_policy" && slices.Contains(res.References, endpoint) {
+			return true
+		}
+	}
+	return false
+}
+
+// EndpointPoliciesToTerraform renders endpoint policies as policy documents
+// and aws_vpc_endpoint_policy resources
+func EndpointPoliciesToTerraform(policies []EndpointPolicy) string {
+	var b strings.Builder
+	for _, ep := range policies {
+		name := resourceName(ep.Endpoint) + "_endpoint"
+		fmt.Fprintf(&b, "data \"aws_iam_policy_document\" %q {\n", name)
+		b.WriteString("  statement {\n")
+		fmt.Fprintf(&b, "    actions   = [%q]\n", ep.Service+":*")
+		b.WriteString("    resources = [\n")
+		for _, arn := range ep.Resources {
+			fmt.Fprintf(&b, "      %q,\n", arn)
+		}
+		b.WriteString("    ]\n\n")
+		b.WriteString("    principals {\n")
+		b.WriteString("      type        = \"*\"\n")
+		b.WriteString("      identifiers = [\"*\"]\n")
+		b.WriteString("    }\n")
+		b.WriteString("  }\n")
+		b.WriteString("}\n\n")
+
+		fmt.Fprintf(&b, "resource \"aws_vpc_endpoint_policy\" %q {\n", name)
+		fmt.Fprintf(&b, "  vpc_endpoint_id = %s.id\n", ep.Endpoint)
+		fmt.Fprintf(&b, "  policy          = data.aws_iam_policy_document.%s.json\n", name)
+		b.WriteString("}\n\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,165 @@
+package companion
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/provider"
+	"github.com/mizzy/least/internal/provider/terraform"
+)
+
+// roleTargetActions are the actions the role of a schedule, pipe or
+// EventBridge target needs to deliver to each target type
+var roleTargetActions = map[string][]string{
+	"aws_lambda_function":                  {"lambda:InvokeFunction"},
+	"aws_sqs_queue":                        {"sqs:SendMessage"},
+	"aws_sns_topic":                        {"sns:Publish"},
+	"aws_sfn_state_machine":                {"states:StartExecution"},
+	"aws_kinesis_stream":                   {"kinesis:PutRecord", "kinesis:PutRecords"},
+	"aws_kinesis_firehose_delivery_stream": {"firehose:PutRecord", "firehose:PutRecordBatch"},
+	"aws_cloudwatch_event_bus":             {"events:PutEvents"},
+	"aws_ecs_task_definition":              {"ecs:RunTask"},
+}
+
+// pipeSourceActions are the actions the role of a pipe needs to read from
+// each source type
+var pipeSourceActions = map[string][]string{
+	"aws_sqs_queue":      {"sqs:DeleteMessage", "sqs:GetQueueAttributes", "sqs:ReceiveMessage"},
+	"aws_kinesis_stream": {"kinesis:DescribeStream", "kinesis:DescribeStreamSummary", "kinesis:GetRecords", "kinesis:GetShardIterator", "kinesis:ListShards"},
+	"aws_dynamodb_table": {"dynamodb:DescribeStream", "dynamodb:GetRecords", "dynamodb:GetShardIterator", "dynamodb:ListStreams"},
+}
+
+// roleSources are the resource types that deliver to their targets with a
+// role
+var roleSources = []string{"aws_cloudwatch_event_target", "aws_pipes_pipe", "aws_scheduler_schedule"}
+
+// RoleGrant is access the role of a schedule, pipe or EventBridge target
+// needs to one of its targets, or to the source of a pipe
+type RoleGrant struct {
+	// Role is the address of the role (e.g., "aws_iam_role.scheduler")
+	Role string
+	// Source is the address of the resource the role is used by (e.g.,
+	// "aws_scheduler_schedule.nightly")
+	Source string
+	// Target is the address of the resource accessed
+	Target string
+	// Actions are the actions the role performs on the target
+	Actions []string
+	// ARN is the Terraform expression of the ARN of the target
+	ARN string
+}
+
+// RoleGrants returns the access the roles of the schedules, pipes and
+// EventBridge targets of the root module need to deliver to their targets
+// and, for pipes, to read from their sources, sorted by role, source and
+// target. EventBridge delivers to functions, queues and topics with their
+// resource policies instead, so those targets of EventBridge targets are left
+// out, as are targets an aws_iam_role_policy of the role already refers to.
+func RoleGrants(resources []provider.Resource) []RoleGrant {
+	types := make(map[string]string)
+	for _, res := range resources {
+		if res.Module == "" {
+			types[res.Address()] = res.Type
+		}
+	}
+
+	var grants []RoleGrant
+	for _, res := range resources {
+		if res.Module != "" || !slices.Contains(roleSources, res.Type) {
+			continue
+		}
+		var roles []string
+		for _, ref := range res.References {
+			if types[ref] == "aws_iam_role" {
+				roles = append(roles, ref)
+			}
+		}
+		pipeSource := pipeSourceAddress(res)
+		for _, role := range roles {
+			for _, target := range res.References {
+				actions, arn := roleTargetActions[types[target]], target+".arn"
+				if target == pipeSource {
+					actions = pipeSourceActions[types[target]]
+					if types[target] == "aws_dynamodb_table" {
+						arn = target + ".stream_arn"
+					}
+				}
+				if len(actions) == 0 || hasRolePolicy(resources, role, target) {
+					continue
+				}
+				if res.Type == "aws_cloudwatch_event_target" && policyTypes[types[target]] != "" {
+					continue
+				}
+				grants = append(grants, RoleGrant{Role: role, Source: res.Address(), Target: target, Actions: actions, ARN: arn})
+			}
+		}
+	}
+
+	sort.SliceStable(grants, func(i, j int) bool {
+		a, b := grants[i], grants[j]
+		if a.Role != b.Role {
+			return a.Role < b.Role
+		}
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return a.Target < b.Target
+	})
+	return grants
+}
+
+// pipeSourceAddress returns the address of the resource the source of a
+// pipe refers to, or ""
+func pipeSourceAddress(res provider.Resource) string {
+	if res.Type != "aws_pipes_pipe" {
+		return ""
+	}
+	v, ok := res.Attributes["source"].(terraform.AttributeValue)
+	if !ok {
+		return ""
+	}
+	for _, ref := range res.References {
+		if strings.HasPrefix(v.Reference, ref+".") {
+			return ref
+		}
+	}
+	return ""
+}
+
+// hasRolePolicy checks if an inline policy of the role in the IaC refers to
+// the target
+func hasRolePolicy(resources []provider.Resource, role, target string) bool {
+	for _, res := range resources {
+		if res.Type == "aws_iam_role_policy" && slices.Contains(res.References, role) && slices.Contains(res.References, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleGrantsToTerraform renders the grants as an inline policy of each role,
+// with a statement per source and target
+func RoleGrantsToTerraform(grants []RoleGrant) string {
+	var b strings.Builder
+	for i := 0; i < len(grants); {
+		role := grants[i].Role
+		name := resourceName(role) + "_targets"
+		fmt.Fprintf(&b, "data \"aws_iam_policy_document\" %q {\n", name)
+		for ; i < len(grants) && grants[i].Role == role; i++ {
+			g := grants[i]
+			fmt.Fprintf(&b, "  # %s -> %s\n", g.Source, g.Target)
+			b.WriteString("  statement {\n")
+			fmt.Fprintf(&b, "    actions   = [%s]\n", quoteAll(g.Actions))
+			fmt.Fprintf(&b, "    resources = [%s]\n", g.ARN)
+			b.WriteString("  }\n")
+		}
+		b.WriteString("}\n\n")
+		fmt.Fprintf(&b, "resource \"aws_iam_role_policy\" %q {\n", name)
+		fmt.Fprintf(&b, "  role   = %s.id\n", role)
+		fmt.Fprintf(&b, "  policy = data.aws_iam_policy_document.%s.json\n", name)
+		b.WriteString("}\n\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,178 @@
+// Package config handles the least configuration file.
+//
+// The configuration file sets project defaults for command-line flags:
+//
+//	provider: terraform
+//	output: iam-policy.tf
+//	format: terraform
+//	strict: true
+//	include:
+//	  - "**/*.tf"
+//	exclude:
+//	  - .terraform/**
+//	  - examples/**
+//	gitignore: true
+//	offline: true
+//	schema_ttl: 30d
+//	policy_sentry: iam-definition.json
+//	policy_sentry_access_levels: [read, list, write, tagging]
+//	mappings:
+//	  - mappings/internal-modules.yaml
+//	arn_patterns:
+//	  aws_s3_bucket:
+//	    pattern: arn:aws:s3:::{bucket}
+//	    attribute: bucket
+//	    children: ["arn:aws:s3:::{bucket}/*"]
+//	    default: acme-*
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mizzy/least/internal/mapping"
+)
+
+// DefaultFile is the configuration file used when none is specified
+const DefaultFile = ".least.yaml"
+
+// Config holds project defaults
+type Config struct {
+	// Provider is the IaC provider (auto-detected if empty)
+	Provider string `yaml:"provider,omitempty"`
+	// Output is the file generate writes the policy to
+	Output string `yaml:"output,omitempty"`
+	// Format is the output format of generate (terraform or json)
+	Format string `yaml:"format,omitempty"`
+	// Strict makes generate fail when resources have no permission mapping
+	Strict bool `yaml:"strict,omitempty"`
+	// NoWildcardResources makes generate fail when a statement would be
+	// granted on Resource "*"
+	NoWildcardResources bool `yaml:"no_wildcard_resources,omitempty"`
+	// Include lists path patterns, relative to the analyzed path; when set,
+	// only resources and policies in matching files are analyzed
+	Include []string `yaml:"include,omitempty"`
+	// Exclude lists path patterns, relative to the analyzed path, whose
+	// resources and policies are ignored
+	Exclude []string `yaml:"exclude,omitempty"`
+	// GitIgnore skips files matched by .gitignore in addition to .terraformignore
+	GitIgnore bool `yaml:"gitignore,omitempty"`
+	// Offline disables all AWS CLI and API calls
+	Offline bool `yaml:"offline,omitempty"`
+	// SchemaTTL is how long cached CloudFormation schemas are used before
+	// they are fetched again (e.g., 30d, 720h, or 0 to never expire)
+	SchemaTTL string `yaml:"schema_ttl,omitempty"`
+	// PolicySentry is a policy_sentry IAM definition file used to resolve
+	// resource types without a built-in mapping or cached schema
+	PolicySentry string `yaml:"policy_sentry,omitempty"`
+	// PolicySentryAccessLevels limits the access levels of the actions taken
+	// from the policy_sentry dataset
+	PolicySentryAccessLevels []string `yaml:"policy_sentry_access_levels,omitempty"`
+	// Mappings lists mapping overlay files that add or override resource
+	// mappings and ARN patterns
+	Mappings []string `yaml:"mappings,omitempty"`
+	// ARNPatterns add or override the ARN patterns of resource types. Mappings
+	// files take precedence over them.
+	ARNPatterns map[string]mapping.ARNPattern `yaml:"arn_patterns,omitempty"`
+}
+
+// Load reads a configuration file
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+
+	switch c.Format {
+	case "", "terraform", "tf", "json":
+	default:
+		return nil, fmt.Errorf("config %s: unsupported format %q (use terraform or json)", path, c.Format)
+	}
+
+	return &c, nil
+}
+
+// Excluded checks if a file, relative to the analyzed path, matches an
+// exclude pattern or, when include patterns are set, matches none of them
+func (c *Config) Excluded(rel string) bool {
+	if len(c.Include) > 0 {
+		included := false
+		for _, pattern := range c.Include {
+			if MatchPath(pattern, rel) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return true
+		}
+	}
+	for _, pattern := range c.Exclude {
+		if MatchPath(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExcludedDir checks if a directory, relative to the analyzed path, matches
+// an exclude pattern. Include patterns select files and are not applied.
+func (c *Config) ExcludedDir(rel string) bool {
+	for _, pattern := range c.Exclude {
+		if MatchPath(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPath matches a slash-separated path against a glob pattern where "**"
+// matches any number of directories. A pattern without a slash matches the
+// file or directory name at any depth.
+func MatchPath(pattern, path string) bool {
+	path = filepath.ToSlash(filepath.Clean(path))
+	pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+
+	if !strings.Contains(pattern, "/") {
+		for _, part := range strings.Split(path, "/") {
+			if ok, _ := filepath.Match(pattern, part); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	return matchParts(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+// matchParts matches path segments against pattern segments. A pattern that
+// matches a directory also matches everything beneath it.
+func matchParts(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchParts(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+		return false
+	}
+	return matchParts(pattern[1:], path[1:])
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{".terraform/**", ".terraform/modules/vpc/main.tf", true},
+		{".terraform", "modules/.terraform/x.tf", true},
+		{"examples/**", "examples/basic/main.tf", true},
+		{"examples/**", "modules/examples/main.tf", false},
+		{"**/tests/**", "modules/vpc/tests/fixture.tf", true},
+		{"*_test.tf", "modules/vpc/s3_test.tf", true},
+		{"modules/*/main.tf", "modules/vpc/main.tf", true},
+		{"modules/*/main.tf", "modules/vpc/nested/main.tf", false},
+		{"examples/", "examples/main.tf", true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchPath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFile)
+	if err := os.WriteFile(path, []byte("provider: terraform\nformat: json\nexclude:\n  - examples/**\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if c.Provider != "terraform" || c.Format != "json" || !c.Excluded("examples/a/main.tf") {
+		t.Errorf("unexpected config: %+v", c)
+	}
+
+	arnPatterns := "arn_patterns:\n  aws_s3_bucket:\n    pattern: arn:aws:s3:::{bucket}\n    attribute: bucket\n    default: acme-*\n"
+	if err := os.WriteFile(path, []byte(arnPatterns), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if p := c.ARNPatterns["aws_s3_bucket"]; p.ResourceAttribute != "bucket" || p.Default != "acme-*" {
+		t.Errorf("arn_patterns = %+v", c.ARNPatterns)
+	}
+
+	if err := os.WriteFile(path, []byte("format: yaml\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
+
+func TestExcluded(t *testing.T) {
+	c := &Config{
+		Include: []string{"stacks/**", "main.tf"},
+		Exclude: []string{"**/tests/**"},
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"main.tf", false},
+		{"stacks/app/main.tf", false},
+		{"stacks/app/tests/fixture.tf", true},
+		{"examples/basic/main.tf", false},
+		{"examples/basic/variables.tf", true},
+		{"variables.tf", true},
+	}
+
+	for _, tt := range tests {
+		if got := c.Excluded(tt.path); got != tt.want {
+			t.Errorf("Excluded(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
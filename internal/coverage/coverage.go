@@ -0,0 +1,71 @@
+// Package coverage reports which IaC resources have permission mappings
+package coverage
+
+import (
+	"sort"
+
+	"github.com/mizzy/least/internal/mapping"
+	"github.com/mizzy/least/internal/provider"
+)
+
+// TypeCount holds the resources of a single unmapped type
+type TypeCount struct {
+	Type      string
+	Resources []provider.Resource
+}
+
+// Report summarizes mapping coverage of a set of resources
+type Report struct {
+	// Total is the number of AWS resources analyzed
+	Total int
+	// Mapped is the number of AWS resources with a permission mapping
+	Mapped int
+	// Unmapped lists resource types with neither a fallback mapping nor a
+	// CloudFormation schema mapping, most frequent first
+	Unmapped []TypeCount
+}
+
+// Percent returns the percentage of resources with a mapping
+func (r *Report) Percent() float64 {
+	if r.Total == 0 {
+		return 100
+	}
+	return float64(r.Mapped) * 100 / float64(r.Total)
+}
+
+// UnmappedCount returns the number of resources without a mapping
+func (r *Report) UnmappedCount() int {
+	return r.Total - r.Mapped
+}
+
+// Analyze computes mapping coverage. Resources of other cloud providers are
+// not counted since they need no IAM permissions.
+func Analyze(resources []provider.Resource) *Report {
+	report := &Report{}
+	unmapped := make(map[string][]provider.Resource)
+
+	for _, res := range resources {
+		if res.CloudProvider != "aws" {
+			continue
+		}
+		report.Total++
+		if len(mapping.GetActionsForResource(res.Type)) > 0 {
+			report.Mapped++
+			continue
+		}
+		unmapped[res.Type] = append(unmapped[res.Type], res)
+	}
+
+	for t, res := range unmapped {
+		report.Unmapped = append(report.Unmapped, TypeCount{Type: t, Resources: res})
+	}
+	sort.Slice(report.Unmapped, func(i, j int) bool {
+		a, b := report.Unmapped[i], report.Unmapped[j]
+		if len(a.Resources) != len(b.Resources) {
+			return len(a.Resources) > len(b.Resources)
+		}
+		return a.Type < b.Type
+	})
+
+	return report
+}
@@ -0,0 +1,29 @@
+package coverage
+
+import (
+	"testing"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+func TestAnalyze(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "aws_s3_bucket", Name: "a", CloudProvider: "aws"},
+		{Type: "aws_unknown_thing", Name: "a", CloudProvider: "aws"},
+		{Type: "aws_other_thing", Name: "a", CloudProvider: "aws"},
+		{Type: "aws_unknown_thing", Name: "b", CloudProvider: "aws"},
+		{Type: "random_id", Name: "suffix", CloudProvider: "random"},
+	}
+
+	report := Analyze(resources)
+
+	if report.Total != 4 || report.Mapped != 1 || report.UnmappedCount() != 3 {
+		t.Errorf("Total, Mapped, Unmapped = %d, %d, %d; want 4, 1, 3", report.Total, report.Mapped, report.UnmappedCount())
+	}
+	if report.Percent() != 25 {
+		t.Errorf("Percent() = %v, want 25", report.Percent())
+	}
+	if len(report.Unmapped) != 2 || report.Unmapped[0].Type != "aws_unknown_thing" || len(report.Unmapped[0].Resources) != 2 {
+		t.Errorf("Unmapped = %+v", report.Unmapped)
+	}
+}
@@ -0,0 +1,194 @@
+// Package diff renders line-based unified diffs.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change
+const contextLines = 3
+
+// opKind identifies a line operation in an edit script
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// op is a single line in an edit script
+type op struct {
+	kind opKind
+	line string
+}
+
+// Unified returns a unified diff between two texts, or "" if they are equal
+func Unified(fromName, toName, from, to string) string {
+	a := splitLines(from)
+	b := splitLines(to)
+
+	ops := editScript(a, b)
+
+	changed := false
+	for _, o := range ops {
+		if o.kind != opEqual {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	for _, h := range hunks(ops) {
+		out.WriteString(h)
+	}
+
+	return out.String()
+}
+
+// splitLines splits text into lines without trailing newlines
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// editScript computes a minimal line edit script using the longest common subsequence
+func editScript(a, b []string) []op {
+	n, m := len(a), len(b)
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]op, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{opEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{opDelete, a[i]})
+			i++
+		default:
+			ops = append(ops, op{opInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, op{opDelete, a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, op{opInsert, b[j]})
+	}
+
+	return ops
+}
+
+// hunks groups an edit script into unified diff hunks with surrounding context
+func hunks(ops []op) []string {
+	var result []string
+
+	// Line numbers (1-based) in the old and new text at each op index
+	aLine := make([]int, len(ops)+1)
+	bLine := make([]int, len(ops)+1)
+	aLine[0], bLine[0] = 1, 1
+	for k, o := range ops {
+		aLine[k+1], bLine[k+1] = aLine[k], bLine[k]
+		if o.kind != opInsert {
+			aLine[k+1]++
+		}
+		if o.kind != opDelete {
+			bLine[k+1]++
+		}
+	}
+
+	k := 0
+	for k < len(ops) {
+		// Find the next change
+		for k < len(ops) && ops[k].kind == opEqual {
+			k++
+		}
+		if k == len(ops) {
+			break
+		}
+
+		start := max(k-contextLines, 0)
+
+		// Extend the hunk until there are more than 2*context unchanged lines
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == opEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*contextLines {
+				end = min(end+contextLines, len(ops))
+				break
+			}
+			end = run
+		}
+
+		var b strings.Builder
+		aCount, bCount := 0, 0
+		for _, o := range ops[start:end] {
+			switch o.kind {
+			case opEqual:
+				b.WriteString(" " + o.line + "\n")
+				aCount++
+				bCount++
+			case opDelete:
+				b.WriteString("-" + o.line + "\n")
+				aCount++
+			case opInsert:
+				b.WriteString("+" + o.line + "\n")
+				bCount++
+			}
+		}
+
+		header := fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(aLine[start], aCount), hunkRange(bLine[start], bCount))
+		result = append(result, header+b.String())
+
+		k = end
+	}
+
+	return result
+}
+
+// hunkRange formats a hunk line range
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// Empty ranges point at the line before the change
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,54 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	from := "a\nb\nc\nd\n"
+	to := "a\nb\nx\nd\ne\n"
+
+	want := `--- old
++++ new
+@@ -1,4 +1,5 @@
+ a
+ b
+-c
++x
+ d
++e
+`
+
+	if got := Unified("old", "new", from, to); got != want {
+		t.Errorf("Unified() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnifiedEqual(t *testing.T) {
+	if got := Unified("old", "new", "a\nb\n", "a\nb\n"); got != "" {
+		t.Errorf("expected empty diff, got:\n%s", got)
+	}
+}
+
+func TestUnifiedSeparateHunks(t *testing.T) {
+	from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	to := "x\n2\n3\n4\n5\n6\n7\n8\n9\ny\n"
+
+	want := `--- old
++++ new
+@@ -1,4 +1,4 @@
+-1
++x
+ 2
+ 3
+ 4
+@@ -7,4 +7,4 @@
+ 7
+ 8
+ 9
+-10
++y
+`
+
+	if got := Unified("old", "new", from, to); got != want {
+		t.Errorf("Unified() =\n%s\nwant:\n%s", got, want)
+	}
+}
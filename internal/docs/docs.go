@@ -0,0 +1,141 @@
+// Package docs generates Markdown documentation of the IAM permissions
+// required by each Terraform module
+package docs
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/mapping"
+	"github.com/mizzy/least/internal/provider"
+)
+
+// Module holds the resources defined in a single module directory
+type Module struct {
+	// Dir is the module directory
+	Dir string
+	// Name is the directory relative to the analyzed path, or "." for the root module
+	Name      string
+	Resources []provider.Resource
+}
+
+// GroupByModule groups resources by the directory of the file defining them.
+// The root module comes first, followed by the others sorted by name.
+func GroupByModule(base string, resources []provider.Resource) []Module {
+	byDir := make(map[string]*Module)
+	var dirs []string
+	for _, res := range resources {
+		dir := filepath.Dir(res.Location.File)
+		m, ok := byDir[dir]
+		if !ok {
+			name, err := filepath.Rel(base, dir)
+			if err != nil {
+				name = dir
+			}
+			m = &Module{Dir: dir, Name: filepath.ToSlash(name)}
+			byDir[dir] = m
+			dirs = append(dirs, dir)
+		}
+		m.Resources = append(m.Resources, res)
+	}
+
+	modules := make([]Module, 0, len(dirs))
+	for _, dir := range dirs {
+		modules = append(modules, *byDir[dir])
+	}
+	sort.SliceStable(modules, func(i, j int) bool {
+		if (modules[i].Name == ".") != (modules[j].Name == ".") {
+			return modules[i].Name == "."
+		}
+		return modules[i].Name < modules[j].Name
+	})
+	return modules
+}
+
+// Markdown describes the permissions a module requires, grouped by the
+// operation (create, read, update, delete) that needs them
+func Markdown(m Module) string {
+	type operation struct {
+		title   string
+		actions map[string]map[string]bool
+	}
+	ops := []*operation{
+		{title: "Create"},
+		{title: "Read"},
+		{title: "Update"},
+		{title: "Delete"},
+	}
+	for _, op := range ops {
+		op.actions = make(map[string]map[string]bool)
+	}
+
+	typeSet := make(map[string]bool)
+	unmappedSet := make(map[string]bool)
+	for _, res := range m.Resources {
+		if res.CloudProvider != "" && res.CloudProvider != "aws" {
+			continue
+		}
+		rm, _, ok := mapping.GetMapping(res.Type)
+		if !ok {
+			unmappedSet[res.Type] = true
+			continue
+		}
+		typeSet[res.Type] = true
+		for i, actions := range [][]string{rm.Create, rm.Read, rm.Update, rm.Delete} {
+			for _, action := range actions {
+				if ops[i].actions[action] == nil {
+					ops[i].actions[action] = make(map[string]bool)
+				}
+				ops[i].actions[action][res.Type] = true
+			}
+		}
+	}
+
+	name := m.Name
+	if name == "." {
+		name = "root module"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# IAM permissions: %s\n\n", name)
+	if len(typeSet) == 0 {
+		b.WriteString("This module does not require any IAM permissions.\n")
+	} else {
+		fmt.Fprintf(&b, "Managing the resources of this module (%s) requires the following IAM actions.\n",
+			strings.Join(sortedKeys(typeSet), ", "))
+	}
+
+	for _, op := range ops {
+		if len(op.actions) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n", op.title)
+		b.WriteString("| Action | Required by |\n")
+		b.WriteString("|--------|-------------|\n")
+		for _, action := range sortedKeys(op.actions) {
+			fmt.Fprintf(&b, "| `%s` | %s |\n", action, strings.Join(sortedKeys(op.actions[action]), ", "))
+		}
+	}
+
+	if len(unmappedSet) > 0 {
+		b.WriteString("\n## Not covered\n\n")
+		b.WriteString("No permission mapping is available for these resource types:\n\n")
+		for _, t := range sortedKeys(unmappedSet) {
+			fmt.Fprintf(&b, "- `%s`\n", t)
+		}
+	}
+
+	return b.String()
+}
+
+// sortedKeys returns the keys of a set in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
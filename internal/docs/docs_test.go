@@ -0,0 +1,61 @@
+package docs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+func TestGroupByModule(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "aws_sqs_queue", Name: "jobs", Location: provider.SourceLocation{File: "infra/modules/queue/main.tf"}},
+		{Type: "aws_s3_bucket", Name: "logs", Location: provider.SourceLocation{File: "infra/main.tf"}},
+		{Type: "aws_sns_topic", Name: "alerts", Location: provider.SourceLocation{File: "infra/modules/alerts/main.tf"}},
+		{Type: "aws_sqs_queue", Name: "dlq", Location: provider.SourceLocation{File: "infra/modules/queue/dlq.tf"}},
+	}
+
+	modules := GroupByModule("infra", resources)
+
+	want := []struct {
+		name      string
+		resources int
+	}{
+		{".", 1},
+		{"modules/alerts", 1},
+		{"modules/queue", 2},
+	}
+	if len(modules) != len(want) {
+		t.Fatalf("got %d modules, want %d: %+v", len(modules), len(want), modules)
+	}
+	for i, m := range modules {
+		if m.Name != want[i].name || len(m.Resources) != want[i].resources {
+			t.Errorf("modules[%d] = %s with %d resources, want %s with %d", i, m.Name, len(m.Resources), want[i].name, want[i].resources)
+		}
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	m := Module{
+		Name: "modules/queue",
+		Resources: []provider.Resource{
+			{Type: "aws_sqs_queue", Name: "jobs", CloudProvider: "aws"},
+			{Type: "aws_sqs_queue", Name: "dlq", CloudProvider: "aws"},
+			{Type: "aws_unknown_thing", Name: "x", CloudProvider: "aws"},
+		},
+	}
+
+	got := Markdown(m)
+
+	for _, want := range []string{
+		"# IAM permissions: modules/queue\n",
+		"resources of this module (aws_sqs_queue) requires the following IAM actions.\n",
+		"\n## Create\n\n| Action | Required by |\n|--------|-------------|\n| `sqs:CreateQueue` | aws_sqs_queue |\n| `sqs:TagQueue` | aws_sqs_queue |\n",
+		"\n## Delete\n\n| Action | Required by |\n|--------|-------------|\n| `sqs:DeleteQueue` | aws_sqs_queue |\n",
+		"\n## Not covered\n\nNo permission mapping is available for these resource types:\n\n- `aws_unknown_thing`\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Markdown() missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Package drift compares the policies of a deployed IAM role with the policy
+// its IaC requires, and reconciles the role's inline policies with it.
+//
+// Missing actions are added to one inline policy of the role, and excessive
+// actions are removed from every inline policy. Attached managed policies may
+// be shared with other roles, so those granting excessive actions are only
+// reported.
+package drift
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/managedpolicy"
+	"github.com/mizzy/least/internal/offline"
+	"github.com/mizzy/least/internal/policy"
+)
+
+// DefaultPolicyName is the inline policy missing actions are added to
+const DefaultPolicyName = "least"
+
+// API is the subset of the IAM client used to read and reconcile roles
+type API interface {
+	managedpolicy.API
+	iam.ListRolePoliciesAPIClient
+	iam.ListAttachedRolePoliciesAPIClient
+	GetRolePolicy(ctx context.Context, params *iam.GetRolePolicyInput, optFns ...func(*iam.Options)) (*iam.GetRolePolicyOutput, error)
+	PutRolePolicy(ctx context.Context, params *iam.PutRolePolicyInput, optFns ...func(*iam.Options)) (*iam.PutRolePolicyOutput, error)
+	DeleteRolePolicy(ctx context.Context, params *iam.DeleteRolePolicyInput, optFns ...func(*iam.Options)) (*iam.DeleteRolePolicyOutput, error)
+}
+
+// NewAPI creates an IAM client using the default AWS configuration
+func NewAPI(ctx context.Context) (API, error) {
+	if offline.Enabled() {
+		return nil, offline.ErrOffline
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+	return iam.NewFromConfig(cfg), nil
+}
+
+// Policy is a policy of a deployed role
+type Policy struct {
+	// Name is the name of an inline policy, or the ARN of an attached one
+	Name     string
+	Inline   bool
+	Document *policy.IAMPolicy
+}
+
+// Role is a deployed IAM role and its policies
+type Role struct {
+	Name     string
+	ARN      string
+	Policies []Policy
+}
+
+// RoleName returns the name of a role from its ARN
+// (e.g., "deploy" for arn:aws:iam::123456789012:role/ci/deploy)
+func RoleName(roleARN string) (string, error) {
+	_, path, ok := strings.Cut(roleARN, ":role/")
+	if !ok || !strings.HasPrefix(roleARN, "arn:") || path == "" {
+		return "", fmt.Errorf("invalid role ARN %s (use arn:aws:iam::ACCOUNT:role/NAME)", roleARN)
+	}
+	return path[strings.LastIndex(path, "/")+1:], nil
+}
+
+// Fetch reads the inline and attached managed policies of a role
+func Fetch(ctx context.Context, api API, roleARN string) (*Role, error) {
+	name, err := RoleName(roleARN)
+	if err != nil {
+		return nil, err
+	}
+	role := &Role{Name: name, ARN: roleARN}
+
+	inline := iam.NewListRolePoliciesPaginator(api, &iam.ListRolePoliciesInput{RoleName: aws.String(name)})
+	for inline.HasMorePages() {
+		page, err := inline.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing inline policies of role %s: %w", name, err)
+		}
+		for _, policyName := range page.PolicyNames {
+			out, err := api.GetRolePolicy(ctx, &iam.GetRolePolicyInput{RoleName: aws.String(name), PolicyName: aws.String(policyName)})
+			if err != nil {
+				return nil, fmt.Errorf("getting inline policy %s of role %s: %w", policyName, name, err)
+			}
+			// The IAM API returns documents URL-encoded
+			document, err := url.QueryUnescape(aws.ToString(out.PolicyDocument))
+			if err != nil {
+				return nil, fmt.Errorf("decoding inline policy %s: %w", policyName, err)
+			}
+			p, err := policy.ParsePolicy([]byte(document))
+			if err != nil {
+				return nil, fmt.Errorf("parsing inline policy %s: %w", policyName, err)
+			}
+			role.Policies = append(role.Policies, Policy{Name: policyName, Inline: true, Document: p})
+		}
+	}
+
+	attached := iam.NewListAttachedRolePoliciesPaginator(api, &iam.ListAttachedRolePoliciesInput{RoleName: aws.String(name)})
+	for attached.HasMorePages() {
+		page, err := attached.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing attached policies of role %s: %w", name, err)
+		}
+		for _, a := range page.AttachedPolicies {
+			arn := aws.ToString(a.PolicyArn)
+			document, err := managedpolicy.FetchWithAPI(ctx, api, arn)
+			if err != nil {
+				return nil, err
+			}
+			p, err := policy.ParsePolicy(document)
+			if err != nil {
+				return nil, fmt.Errorf("parsing policy %s: %w", arn, err)
+			}
+			role.Policies = append(role.Policies, Policy{Name: arn, Document: p})
+		}
+	}
+	return role, nil
+}
+
+// Effective returns the statements of all policies of the role as one policy
+func (r *Role) Effective() *policy.IAMPolicy {
+	p := &policy.IAMPolicy{Version: "2012-10-17"}
+	for _, rp := range r.Policies {
+		p.Statement = append(p.Statement, rp.Document.Statement...)
+	}
+	return p
+}
+
+// Change reconciles one policy of a role with the required policy
+type Change struct {
+	Policy string
+	Inline bool
+	// New is set when the inline policy is created
+	New bool
+	// Document is the reconciled document of an inline policy, or nil if
+	// the policy is left empty and deleted
+	Document *policy.IAMPolicy
+	// Added and Removed are the actions the change grants and revokes
+	Added   []string
+	Removed []string
+}
+
+// Plan is the reconciliation of a role with the required policy
+type Plan struct {
+	// Result is the check of all policies of the role together
+	Result *checker.Result
+	// Changes are the changes to the inline policies of the role
+	Changes []Change
+	// Attached are the attached policies granting excessive actions, listed
+	// in Removed; they are left in place since other roles may use them
+	Attached []Change
+}
+
+// HasDrift checks if the role grants other actions than required
+func (p *Plan) HasDrift() bool {
+	return !p.Result.IsCompliant()
+}
+
+// Reconcile plans the changes reconciling the role with the required policy.
+// Missing actions are added to the inline policy named target, which is
+// created if needed; excessive actions are removed from all inline policies.
+func Reconcile(role *Role, required *policy.IAMPolicy, target string) *Plan {
+	plan := &Plan{Result: checker.Check(role.Effective(), required)}
+
+	targetFound := false
+	for _, rp := range role.Policies {
+		r := checker.Check(rp.Document, required)
+		if !rp.Inline {
+			if len(r.Excessive) > 0 {
+				plan.Attached = append(plan.Attached, Change{Policy: rp.Name, Removed: r.Excessive})
+			}
+			continue
+		}
+
+		var missing []string
+		if rp.Name == target {
+			targetFound = true
+			missing = plan.Result.Missing
+		}
+		if len(missing) == 0 && len(r.Excessive) == 0 {
+			continue
+		}
+		change := Change{Policy: rp.Name, Inline: true, Added: missing, Removed: r.Excessive}
+		fixed := checker.Remediate(rp.Document, required, &checker.Result{Missing: missing, Excessive: r.Excessive, Matched: r.Matched})
+		if len(fixed.Statement) > 0 {
+			change.Document = fixed
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	if !targetFound && len(plan.Result.Missing) > 0 {
+		empty := &policy.IAMPolicy{Version: "2012-10-17"}
+		plan.Changes = append(plan.Changes, Change{
+			Policy:   target,
+			Inline:   true,
+			New:      true,
+			Document: checker.Remediate(empty, required, &checker.Result{Missing: plan.Result.Missing}),
+			Added:    plan.Result.Missing,
+		})
+	}
+	return plan
+}
+
+// Apply puts the reconciled inline policies of a plan, and deletes those
+// left empty
+func Apply(ctx context.Context, api API, role *Role, plan *Plan) error {
+	for _, c := range plan.Changes {
+		if c.Document == nil {
+			if _, err := api.DeleteRolePolicy(ctx, &iam.DeleteRolePolicyInput{
+				RoleName:   aws.String(role.Name),
+				PolicyName: aws.String(c.Policy),
+			}); err != nil {
+				return fmt.Errorf("deleting inline policy %s of role %s: %w", c.Policy, role.Name, err)
+			}
+			continue
+		}
+
+		document, err := c.Document.ToJSON()
+		if err != nil {
+			return err
+		}
+		if _, err := api.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+			RoleName:       aws.String(role.Name),
+			PolicyName:     aws.String(c.Policy),
+			PolicyDocument: aws.String(document),
+		}); err != nil {
+			return fmt.Errorf("putting inline policy %s of role %s: %w", c.Policy, role.Name, err)
+		}
+	}
+	return nil
+}
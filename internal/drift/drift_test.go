@@ -0,0 +1,138 @@
+package drift
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+
+	"github.com/mizzy/least/internal/policy"
+)
+
+const managedARN = "arn:aws:iam::aws:policy/AmazonEC2ReadOnlyAccess"
+
+type fakeIAM struct {
+	inline  map[string]string
+	managed map[string]string
+
+	put     map[string]string
+	deleted []string
+}
+
+func (f *fakeIAM) ListRolePolicies(_ context.Context, _ *iam.ListRolePoliciesInput, _ ...func(*iam.Options)) (*iam.ListRolePoliciesOutput, error) {
+	out := &iam.ListRolePoliciesOutput{}
+	for _, name := range []string{"legacy", "least"} {
+		if _, ok := f.inline[name]; ok {
+			out.PolicyNames = append(out.PolicyNames, name)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeIAM) GetRolePolicy(_ context.Context, params *iam.GetRolePolicyInput, _ ...func(*iam.Options)) (*iam.GetRolePolicyOutput, error) {
+	return &iam.GetRolePolicyOutput{PolicyDocument: aws.String(url.QueryEscape(f.inline[aws.ToString(params.PolicyName)]))}, nil
+}
+
+func (f *fakeIAM) ListAttachedRolePolicies(_ context.Context, _ *iam.ListAttachedRolePoliciesInput, _ ...func(*iam.Options)) (*iam.ListAttachedRolePoliciesOutput, error) {
+	out := &iam.ListAttachedRolePoliciesOutput{}
+	for arn := range f.managed {
+		out.AttachedPolicies = append(out.AttachedPolicies, types.AttachedPolicy{PolicyArn: aws.String(arn)})
+	}
+	return out, nil
+}
+
+func (f *fakeIAM) GetPolicy(_ context.Context, params *iam.GetPolicyInput, _ ...func(*iam.Options)) (*iam.GetPolicyOutput, error) {
+	return &iam.GetPolicyOutput{Policy: &types.Policy{Arn: params.PolicyArn, DefaultVersionId: aws.String("v1")}}, nil
+}
+
+func (f *fakeIAM) GetPolicyVersion(_ context.Context, params *iam.GetPolicyVersionInput, _ ...func(*iam.Options)) (*iam.GetPolicyVersionOutput, error) {
+	return &iam.GetPolicyVersionOutput{PolicyVersion: &types.PolicyVersion{Document: aws.String(url.QueryEscape(f.managed[aws.ToString(params.PolicyArn)]))}}, nil
+}
+
+func (f *fakeIAM) PutRolePolicy(_ context.Context, params *iam.PutRolePolicyInput, _ ...func(*iam.Options)) (*iam.PutRolePolicyOutput, error) {
+	if f.put == nil {
+		f.put = make(map[string]string)
+	}
+	f.put[aws.ToString(params.PolicyName)] = aws.ToString(params.PolicyDocument)
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+func (f *fakeIAM) DeleteRolePolicy(_ context.Context, params *iam.DeleteRolePolicyInput, _ ...func(*iam.Options)) (*iam.DeleteRolePolicyOutput, error) {
+	f.deleted = append(f.deleted, aws.ToString(params.PolicyName))
+	return &iam.DeleteRolePolicyOutput{}, nil
+}
+
+func TestReconcile(t *testing.T) {
+	api := &fakeIAM{
+		inline: map[string]string{
+			"legacy": `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject","s3:DeleteBucket"],"Resource":"*"}]}`,
+		},
+		managed: map[string]string{
+			managedARN: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"ec2:Describe*","Resource":"*"}]}`,
+		},
+	}
+	required := &policy.IAMPolicy{Statement: []policy.Statement{
+		{Sid: "Bucket", Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: []string{"*"}},
+		{Sid: "Queue", Effect: "Allow", Action: []string{"sqs:SendMessage"}, Resource: []string{"arn:aws:sqs:us-east-1:123456789012:jobs"}},
+	}}
+
+	role, err := Fetch(context.Background(), api, "arn:aws:iam::123456789012:role/ci/deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role.Name != "deploy" || len(role.Policies) != 2 {
+		t.Fatalf("Fetch() = %+v", role)
+	}
+
+	plan := Reconcile(role, required, DefaultPolicyName)
+	if !plan.HasDrift() {
+		t.Fatal("HasDrift() = false")
+	}
+	if len(plan.Changes) != 2 {
+		t.Fatalf("Changes = %+v, want the legacy policy trimmed and the least policy created", plan.Changes)
+	}
+	if c := plan.Changes[0]; c.Policy != "legacy" || !reflect.DeepEqual(c.Removed, []string{"s3:DeleteBucket"}) || c.Document == nil {
+		t.Errorf("Changes[0] = %+v", c)
+	}
+	if c := plan.Changes[1]; c.Policy != "least" || !c.New || !reflect.DeepEqual(c.Added, []string{"sqs:SendMessage"}) {
+		t.Errorf("Changes[1] = %+v", c)
+	}
+	if len(plan.Attached) != 1 || plan.Attached[0].Policy != managedARN {
+		t.Errorf("Attached = %+v, want the EC2 policy reported", plan.Attached)
+	}
+
+	if err := Apply(context.Background(), api, role, plan); err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := policy.ParsePolicy([]byte(api.put["legacy"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := legacy.GetAllActions(); !reflect.DeepEqual(got, []string{"s3:GetObject"}) {
+		t.Errorf("legacy actions = %v", got)
+	}
+	least, err := policy.ParsePolicy([]byte(api.put["least"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := least.Statement; len(got) != 1 || got[0].Resource[0] != "arn:aws:sqs:us-east-1:123456789012:jobs" {
+		t.Errorf("least statements = %+v, want the queue statement", got)
+	}
+
+	// An inline policy granting only excessive actions is deleted
+	role.Policies = append(role.Policies, Policy{Name: "old", Inline: true, Document: &policy.IAMPolicy{Statement: []policy.Statement{
+		{Effect: "Allow", Action: []string{"iam:*"}, Resource: []string{"*"}},
+	}}})
+	plan = Reconcile(role, required, "legacy")
+	if c := plan.Changes[len(plan.Changes)-1]; c.Policy != "old" || c.Document != nil {
+		t.Errorf("Changes = %+v, want the old policy deleted", plan.Changes)
+	}
+
+	if _, err := RoleName("arn:aws:iam::123456789012:user/deploy"); err == nil {
+		t.Error("RoleName() of a user ARN succeeded")
+	}
+}
@@ -0,0 +1,297 @@
+// Package evidence builds compliance evidence reports from check results.
+//
+// A report maps the result of checking a deployed policy against the IaC
+// requirements onto the least-privilege controls of common frameworks (CIS
+// AWS Foundations, SOC 2, NIST 800-53), and records when and on which
+// policies the check ran. Reports are signed with an Ed25519 key, so an
+// auditor holding the public key can verify they were not edited after the
+// CI run produced them.
+package evidence
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/risk"
+)
+
+// version is the version of the report format
+const version = 1
+
+// Algorithm is the signature algorithm of reports
+const Algorithm = "ed25519"
+
+// Control status values
+const (
+	Pass = "pass"
+	Fail = "fail"
+)
+
+// Report is the compliance evidence of one check run
+type Report struct {
+	Version int `json:"version"`
+	// GeneratedAt is the RFC 3339 UTC time of the check
+	GeneratedAt string `json:"generated_at"`
+	Tool        string `json:"tool"`
+	// Path is the IaC path the required policy was generated from
+	Path string `json:"path"`
+	// PolicySource is the file, directory or ARN of the checked policy
+	PolicySource string `json:"policy_source"`
+	// PolicySHA256 and RequiredSHA256 are the digests of the JSON documents
+	// of the checked and the required policy
+	PolicySHA256   string     `json:"policy_sha256"`
+	RequiredSHA256 string     `json:"required_sha256"`
+	Summary        Summary    `json:"summary"`
+	Controls       []Control  `json:"controls"`
+	Signature      *Signature `json:"signature,omitempty"`
+}
+
+// Summary is the result of the check
+type Summary struct {
+	Required  int      `json:"required"`
+	Matched   int      `json:"matched"`
+	Missing   []string `json:"missing"`
+	Excessive []string `json:"excessive"`
+	// BroadGrants describes the dangerously broad grants of the policy
+	BroadGrants []string `json:"broad_grants"`
+}
+
+// Control is the status of one framework control
+type Control struct {
+	Framework string `json:"framework"`
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Status    string `json:"status"`
+	// Details are the findings failing the control
+	Details []string `json:"details,omitempty"`
+}
+
+// Signature signs a report without its signature
+type Signature struct {
+	Algorithm string `json:"algorithm"`
+	// PublicKey and Value are base64-encoded
+	PublicKey string `json:"public_key"`
+	Value     string `json:"value"`
+}
+
+// Input is what a report is built from
+type Input struct {
+	Tool         string
+	Path         string
+	PolicySource string
+	Existing     *policy.IAMPolicy
+	Required     *policy.IAMPolicy
+	Result       *checker.Result
+	// BroadGrants are the dangerously broad grants of the checked policy,
+	// found in its statements as written (see risk.FindBroadGrants)
+	BroadGrants []risk.BroadGrant
+	Time        time.Time
+}
+
+// Build returns the unsigned report of a check
+func Build(in Input) (*Report, error) {
+	policyDigest, err := digest(in.Existing)
+	if err != nil {
+		return nil, err
+	}
+	requiredDigest, err := digest(in.Required)
+	if err != nil {
+		return nil, err
+	}
+
+	described := make([]string, len(in.BroadGrants))
+	for i, g := range in.BroadGrants {
+		described[i] = g.String()
+	}
+
+	return &Report{
+		Version:        version,
+		GeneratedAt:    in.Time.UTC().Format(time.RFC3339),
+		Tool:           in.Tool,
+		Path:           in.Path,
+		PolicySource:   in.PolicySource,
+		PolicySHA256:   policyDigest,
+		RequiredSHA256: requiredDigest,
+		Summary: Summary{
+			Required:    len(in.Result.Matched) + len(in.Result.Missing),
+			Matched:     len(in.Result.Matched),
+			Missing:     nonNil(in.Result.Missing),
+			Excessive:   nonNil(in.Result.Excessive),
+			BroadGrants: described,
+		},
+		Controls: controls(in.Result, in.BroadGrants),
+	}, nil
+}
+
+// controls evaluates the least-privilege controls of each framework
+func controls(result *checker.Result, grants []risk.BroadGrant) []Control {
+	var admin, wildcards, broad []string
+	for _, g := range grants {
+		broad = append(broad, g.String())
+		if g.Kind == risk.WildcardResource {
+			continue
+		}
+		wildcards = append(wildcards, g.String())
+		if g.Kind == risk.FullWildcardAction && g.AllResources {
+			admin = append(admin, g.String())
+		}
+	}
+	var excessive []string
+	for _, action := range result.Excessive {
+		excessive = append(excessive, "grants "+action+", which the IaC does not require")
+	}
+
+	return []Control{
+		control("CIS AWS Foundations", "1.16", `IAM policies that allow full "*:*" administrative privileges are not attached`, admin),
+		control("SOC 2", "CC6.1", "Logical access is restricted to the services the system uses", wildcards),
+		control("SOC 2", "CC6.3", "Access is limited to what the role requires", slices.Concat(excessive, wildcards)),
+		control("NIST 800-53", "AC-6", "Least privilege", slices.Concat(excessive, broad)),
+	}
+}
+
+// control returns a control failing on any of the findings
+func control(framework, id, title string, findings []string) Control {
+	c := Control{Framework: framework, ID: id, Title: title, Status: Pass}
+	if len(findings) > 0 {
+		c.Status = Fail
+		c.Details = findings
+	}
+	return c
+}
+
+// Passed checks if the report passes every control
+func (r *Report) Passed() bool {
+	for _, c := range r.Controls {
+		if c.Status != Pass {
+			return false
+		}
+	}
+	return true
+}
+
+// Sign signs the report with an Ed25519 private key
+func (r *Report) Sign(key ed25519.PrivateKey) error {
+	r.Signature = nil
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	r.Signature = &Signature{
+		Algorithm: Algorithm,
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Value:     base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload)),
+	}
+	return nil
+}
+
+// Verify parses a signed report and verifies its signature with a public key
+func Verify(data []byte, pub ed25519.PublicKey) (*Report, error) {
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parsing evidence report: %w", err)
+	}
+	sig := r.Signature
+	if sig == nil {
+		return nil, errors.New("evidence report is not signed")
+	}
+	if sig.Algorithm != Algorithm {
+		return nil, fmt.Errorf("unsupported signature algorithm %q", sig.Algorithm)
+	}
+	if sig.PublicKey != base64.StdEncoding.EncodeToString(pub) {
+		return nil, errors.New("evidence report is signed with another key")
+	}
+	value, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return nil, fmt.Errorf("decoding signature: %w", err)
+	}
+
+	r.Signature = nil
+	payload, err := json.Marshal(&r)
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(pub, payload, value) {
+		return nil, errors.New("signature does not match the evidence report")
+	}
+	r.Signature = sig
+	return &r, nil
+}
+
+// LoadPrivateKey reads a PEM-encoded PKCS #8 Ed25519 private key
+// (e.g., written by openssl genpkey -algorithm ed25519)
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key %s: %w", path, err)
+	}
+	ed, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an Ed25519 private key", path)
+	}
+	return ed, nil
+}
+
+// LoadPublicKey reads a PEM-encoded PKIX Ed25519 public key
+// (e.g., written by openssl pkey -pubout)
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key %s: %w", path, err)
+	}
+	ed, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an Ed25519 public key", path)
+	}
+	return ed, nil
+}
+
+// readPEM reads the first PEM block of a file
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+	return block, nil
+}
+
+// digest returns the hex SHA-256 digest of the JSON document of a policy
+func digest(p *policy.IAMPolicy) (string, error) {
+	document, err := p.ToJSON()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(document))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// nonNil returns an empty list for nil, so reports list no findings as []
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package evidence
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/risk"
+)
+
+func TestBuild(t *testing.T) {
+	required := &policy.IAMPolicy{Version: "2012-10-17", Statement: []policy.Statement{
+		{Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: []string{"arn:aws:s3:::logs/*"}},
+	}}
+	existing := &policy.IAMPolicy{Version: "2012-10-17", Statement: []policy.Statement{
+		{Sid: "Logs", Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: []string{"arn:aws:s3:::logs/*"}},
+	}}
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.FixedZone("JST", 9*60*60))
+
+	report, err := Build(Input{Path: "infra", PolicySource: "policy.json", Existing: existing, Required: required,
+		Result: checker.Check(existing, required), BroadGrants: risk.FindBroadGrants(existing), Time: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.GeneratedAt != "2026-10-16T00:30:00Z" {
+		t.Errorf("GeneratedAt = %s, want UTC", report.GeneratedAt)
+	}
+	if !report.Passed() {
+		t.Errorf("Controls = %+v, want all passed", report.Controls)
+	}
+
+	existing.Statement = append(existing.Statement, policy.Statement{Sid: "Admin", Effect: "Allow", Action: []string{"*"}, Resource: []string{"*"}})
+	report, err = Build(Input{Existing: existing, Required: required, Result: checker.Check(existing, required),
+		BroadGrants: risk.FindBroadGrants(existing), Time: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := make(map[string]string)
+	for _, c := range report.Controls {
+		status[c.ID] = c.Status
+	}
+	for _, id := range []string{"1.16", "CC6.1", "CC6.3", "AC-6"} {
+		if status[id] != Fail {
+			t.Errorf("control %s = %s, want fail for an admin grant", id, status[id])
+		}
+	}
+
+	// A wildcard action on specific resources is not administrative access
+	existing.Statement[1].Resource = []string{"arn:aws:s3:::logs"}
+	report, err = Build(Input{Existing: existing, Required: required, Result: checker.Check(existing, required),
+		BroadGrants: risk.FindBroadGrants(existing), Time: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := report.Controls[0]; c.ID != "1.16" || c.Status != Pass {
+		t.Errorf("Controls[0] = %+v, want CIS 1.16 passed", c)
+	}
+
+	// Controls follow the grants found in the statements as written, not in
+	// the combined policy of --policy-dir, which puts every action under "*"
+	combined := &policy.IAMPolicy{Version: "2012-10-17", Statement: []policy.Statement{
+		{Effect: "Allow", Action: []string{"s3:GetObject", "s3:PutObject"}, Resource: []string{"*"}},
+	}}
+	scoped := &policy.IAMPolicy{Version: "2012-10-17", Statement: []policy.Statement{
+		{Effect: "Allow", Action: []string{"s3:GetObject", "s3:PutObject"}, Resource: []string{"arn:aws:s3:::logs/*"}},
+	}}
+	report, err = Build(Input{Existing: combined, Required: scoped, Result: checker.Check(combined, scoped),
+		BroadGrants: risk.FindBroadGrants(scoped), Time: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Passed() {
+		t.Errorf("Controls = %+v, want all passed for grants scoped as written", report.Controls)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &policy.IAMPolicy{Version: "2012-10-17", Statement: []policy.Statement{
+		{Effect: "Allow", Action: []string{"s3:GetObject", "s3:DeleteObject"}, Resource: []string{"*"}},
+	}}
+	report, err := Build(Input{Existing: p, Required: p, Result: checker.Check(p, p), BroadGrants: risk.FindBroadGrants(p), Time: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := report.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err := Verify(data, pub)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if verified.PolicySHA256 != report.PolicySHA256 || len(verified.Controls) != len(report.Controls) {
+		t.Errorf("Verify() = %+v, want %+v", verified, report)
+	}
+
+	tampered := bytes.Replace(data, []byte(`"status": "fail"`), []byte(`"status": "pass"`), 1)
+	if bytes.Equal(tampered, data) {
+		t.Fatal("report has no failed control to tamper with")
+	}
+	if _, err := Verify(tampered, pub); err == nil {
+		t.Error("Verify() of a tampered report succeeded")
+	}
+
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(data, other); err == nil {
+		t.Error("Verify() with another key succeeded")
+	}
+}
@@ -0,0 +1,52 @@
+// Package explain traces why an action appears in a generated policy
+package explain
+
+import (
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/mapping"
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/provider"
+)
+
+// Reason is one resource's contribution of an action to a generated policy
+type Reason struct {
+	// Resource is the IaC resource that requires the action
+	Resource provider.Resource
+	// Statement is the Sid of the statement the action lands in
+	Statement string
+	// MatchedBy is the policy entry granting the action, which differs from
+	// the queried action when a wildcard matched
+	MatchedBy string
+	// Source is the CloudFormation type the mapping was generated from, "fallback" or "custom"
+	Source string
+	// Operations are the resource operations that need the action
+	Operations []string
+}
+
+// Explain returns the reasons an action appears in a generated policy
+func Explain(p *policy.IAMPolicy, action string) []Reason {
+	var reasons []Reason
+
+	for _, stmt := range p.Statement {
+		if stmt.Effect != "Allow" {
+			continue
+		}
+		for _, entry := range stmt.Action {
+			if !checker.MatchAction(entry, action) {
+				continue
+			}
+			for _, res := range stmt.Sources {
+				source, _ := mapping.GetSource(res.Type)
+				reasons = append(reasons, Reason{
+					Resource:   res,
+					Statement:  stmt.Sid,
+					MatchedBy:  entry,
+					Source:     source,
+					Operations: mapping.GetOperations(res.Type, entry),
+				})
+			}
+		}
+	}
+
+	return reasons
+}
@@ -0,0 +1,43 @@
+package explain
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/provider"
+)
+
+func TestExplain(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "aws_s3_bucket", Name: "main"},
+		{Type: "aws_sqs_queue", Name: "jobs"},
+	}
+	p, err := policy.New().Generate(resources)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	reasons := Explain(p, "s3:PutBucketTagging")
+	if len(reasons) != 1 {
+		t.Fatalf("got %d reasons, want 1: %+v", len(reasons), reasons)
+	}
+
+	r := reasons[0]
+	if r.Resource.Address() != "aws_s3_bucket.main" {
+		t.Errorf("Resource = %s, want aws_s3_bucket.main", r.Resource.Address())
+	}
+	if r.Statement != "AwsS3BucketMain" {
+		t.Errorf("Statement = %s, want AwsS3BucketMain", r.Statement)
+	}
+	if r.Source != "AWS::S3::Bucket" {
+		t.Errorf("Source = %s, want AWS::S3::Bucket", r.Source)
+	}
+	if !reflect.DeepEqual(r.Operations, []string{"create", "update"}) {
+		t.Errorf("Operations = %v, want [create update]", r.Operations)
+	}
+
+	if reasons := Explain(p, "ec2:RunInstances"); len(reasons) != 0 {
+		t.Errorf("Explain(ec2:RunInstances) = %+v, want none", reasons)
+	}
+}
@@ -0,0 +1,305 @@
+// Package gcp generates Google Cloud custom roles granting the permissions
+// needed to manage the google_* resources of a configuration, the GCP
+// counterpart of the AWS IAM policies of package policy.
+package gcp
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+// Role is a GCP custom role, in the form the IAM API and
+// 'gcloud iam roles create --file' accept
+type Role struct {
+	Title               string   `json:"title"`
+	Description         string   `json:"description"`
+	Stage               string   `json:"stage"`
+	IncludedPermissions []string `json:"includedPermissions"`
+
+	// Sources maps each permission to the resources requiring it.
+	// They are not part of the role.
+	Sources map[string][]provider.Resource `json:"-"`
+}
+
+// Generate creates a custom role with the permissions the GCP resources
+// need. It also returns the resource types without a permission mapping,
+// sorted, whose permissions are missing from the role.
+func Generate(resources []provider.Resource) (*Role, []string) {
+	role := &Role{
+		Title:       "Least privilege",
+		Description: "Permissions required to manage the resources of the Terraform configuration",
+		Stage:       "GA",
+		Sources:     make(map[string][]provider.Resource),
+	}
+
+	unmapped := make(map[string]bool)
+	for _, res := range resources {
+		if res.CloudProvider != "gcp" {
+			continue
+		}
+		permissions := GetPermissionsForResource(res.Type)
+		if len(permissions) == 0 {
+			unmapped[res.Type] = true
+			continue
+		}
+		for _, permission := range permissions {
+			role.Sources[permission] = append(role.Sources[permission], res)
+		}
+	}
+
+	role.IncludedPermissions = make([]string, 0, len(role.Sources))
+	for permission := range role.Sources {
+		role.IncludedPermissions = append(role.IncludedPermissions, permission)
+	}
+	sort.Strings(role.IncludedPermissions)
+
+	types := make([]string, 0, len(unmapped))
+	for t := range unmapped {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	return role, types
+}
+
+// ToJSON converts the role to JSON
+func (r *Role) ToJSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ToTerraform converts the role to Terraform HCL
+// (google_project_iam_custom_role)
+func (r *Role) ToTerraform() string {
+	var b strings.Builder
+
+	b.WriteString(`resource "google_project_iam_custom_role" "least_privilege" {`)
+	b.WriteString("\n")
+	b.WriteString(`  role_id     = "leastPrivilege"`)
+	b.WriteString("\n")
+	b.WriteString(`  title       = "` + r.Title + `"`)
+	b.WriteString("\n")
+	b.WriteString(`  description = "` + r.Description + `"`)
+	b.WriteString("\n")
+	b.WriteString(`  stage       = "` + r.Stage + `"`)
+	b.WriteString("\n\n")
+
+	b.WriteString("  permissions = [\n")
+	for _, permission := range r.IncludedPermissions {
+		b.WriteString(`    "`)
+		b.WriteString(permission)
+		b.WriteString("\",\n")
+	}
+	b.WriteString("  ]\n")
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// GetPermissionsForResource returns the permissions needed to create, read,
+// update and delete a resource type, or nil if the type has no mapping
+func GetPermissionsForResource(resourceType string) []string {
+	return permissions[resourceType]
+}
+
+// permissions maps google_* resource types to the IAM permissions Terraform
+// needs to manage them
+var permissions = map[string][]string{
+	"google_storage_bucket": {
+		"storage.buckets.create",
+		"storage.buckets.delete",
+		"storage.buckets.get",
+		"storage.buckets.update",
+	},
+	"google_storage_bucket_object": {
+		"storage.objects.create",
+		"storage.objects.delete",
+		"storage.objects.get",
+	},
+	"google_storage_bucket_iam_member": {
+		"storage.buckets.getIamPolicy",
+		"storage.buckets.setIamPolicy",
+	},
+	"google_compute_instance": {
+		"compute.disks.create",
+		"compute.instances.create",
+		"compute.instances.delete",
+		"compute.instances.get",
+		"compute.instances.setLabels",
+		"compute.instances.setMachineType",
+		"compute.instances.setMetadata",
+		"compute.instances.setServiceAccount",
+		"compute.instances.setTags",
+		"compute.instances.start",
+		"compute.instances.stop",
+		"compute.subnetworks.use",
+		"compute.subnetworks.useExternalIp",
+		"compute.zoneOperations.get",
+		"iam.serviceAccounts.actAs",
+	},
+	"google_compute_network": {
+		"compute.globalOperations.get",
+		"compute.networks.create",
+		"compute.networks.delete",
+		"compute.networks.get",
+		"compute.networks.updatePolicy",
+	},
+	"google_compute_subnetwork": {
+		"compute.networks.updatePolicy",
+		"compute.regionOperations.get",
+		"compute.subnetworks.create",
+		"compute.subnetworks.delete",
+		"compute.subnetworks.get",
+		"compute.subnetworks.update",
+	},
+	"google_compute_firewall": {
+		"compute.firewalls.create",
+		"compute.firewalls.delete",
+		"compute.firewalls.get",
+		"compute.firewalls.update",
+		"compute.globalOperations.get",
+		"compute.networks.updatePolicy",
+	},
+	"google_compute_address": {
+		"compute.addresses.create",
+		"compute.addresses.delete",
+		"compute.addresses.get",
+		"compute.addresses.setLabels",
+		"compute.regionOperations.get",
+	},
+	"google_service_account": {
+		"iam.serviceAccounts.create",
+		"iam.serviceAccounts.delete",
+		"iam.serviceAccounts.get",
+		"iam.serviceAccounts.update",
+	},
+	"google_service_account_key": {
+		"iam.serviceAccountKeys.create",
+		"iam.serviceAccountKeys.delete",
+		"iam.serviceAccountKeys.get",
+	},
+	"google_project_iam_member": {
+		"resourcemanager.projects.getIamPolicy",
+		"resourcemanager.projects.setIamPolicy",
+	},
+	"google_project_iam_binding": {
+		"resourcemanager.projects.getIamPolicy",
+		"resourcemanager.projects.setIamPolicy",
+	},
+	"google_project_service": {
+		"serviceusage.services.disable",
+		"serviceusage.services.enable",
+		"serviceusage.services.get",
+	},
+	"google_pubsub_topic": {
+		"pubsub.topics.create",
+		"pubsub.topics.delete",
+		"pubsub.topics.get",
+		"pubsub.topics.update",
+	},
+	"google_pubsub_subscription": {
+		"pubsub.subscriptions.create",
+		"pubsub.subscriptions.delete",
+		"pubsub.subscriptions.get",
+		"pubsub.subscriptions.update",
+		"pubsub.topics.attachSubscription",
+	},
+	"google_sql_database_instance": {
+		"cloudsql.instances.create",
+		"cloudsql.instances.delete",
+		"cloudsql.instances.get",
+		"cloudsql.instances.update",
+	},
+	"google_sql_database": {
+		"cloudsql.databases.create",
+		"cloudsql.databases.delete",
+		"cloudsql.databases.get",
+		"cloudsql.databases.update",
+	},
+	"google_cloudfunctions_function": {
+		"cloudfunctions.functions.create",
+		"cloudfunctions.functions.delete",
+		"cloudfunctions.functions.get",
+		"cloudfunctions.functions.update",
+		"cloudfunctions.operations.get",
+		"iam.serviceAccounts.actAs",
+	},
+	"google_cloud_run_v2_service": {
+		"iam.serviceAccounts.actAs",
+		"run.operations.get",
+		"run.services.create",
+		"run.services.delete",
+		"run.services.get",
+		"run.services.update",
+	},
+	"google_bigquery_dataset": {
+		"bigquery.datasets.create",
+		"bigquery.datasets.delete",
+		"bigquery.datasets.get",
+		"bigquery.datasets.update",
+	},
+	"google_bigquery_table": {
+		"bigquery.tables.create",
+		"bigquery.tables.delete",
+		"bigquery.tables.get",
+		"bigquery.tables.update",
+	},
+	"google_kms_key_ring": {
+		"cloudkms.keyRings.create",
+		"cloudkms.keyRings.get",
+	},
+	"google_kms_crypto_key": {
+		"cloudkms.cryptoKeys.create",
+		"cloudkms.cryptoKeys.get",
+		"cloudkms.cryptoKeys.update",
+	},
+	"google_secret_manager_secret": {
+		"secretmanager.secrets.create",
+		"secretmanager.secrets.delete",
+		"secretmanager.secrets.get",
+		"secretmanager.secrets.update",
+	},
+	"google_secret_manager_secret_version": {
+		"secretmanager.versions.access",
+		"secretmanager.versions.add",
+		"secretmanager.versions.destroy",
+		"secretmanager.versions.get",
+	},
+	"google_container_cluster": {
+		"container.clusters.create",
+		"container.clusters.delete",
+		"container.clusters.get",
+		"container.clusters.update",
+		"container.operations.get",
+		"iam.serviceAccounts.actAs",
+	},
+	"google_container_node_pool": {
+		"container.clusters.get",
+		"container.nodePools.create",
+		"container.nodePools.delete",
+		"container.nodePools.get",
+		"container.nodePools.update",
+		"container.operations.get",
+	},
+	"google_dns_managed_zone": {
+		"dns.managedZones.create",
+		"dns.managedZones.delete",
+		"dns.managedZones.get",
+		"dns.managedZones.update",
+	},
+	"google_dns_record_set": {
+		"dns.changes.create",
+		"dns.changes.get",
+		"dns.resourceRecordSets.create",
+		"dns.resourceRecordSets.delete",
+		"dns.resourceRecordSets.get",
+		"dns.resourceRecordSets.update",
+	},
+}
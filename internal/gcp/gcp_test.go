@@ -0,0 +1,68 @@
+package gcp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+func TestGenerate(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "google_storage_bucket", Name: "assets", CloudProvider: "gcp"},
+		{Type: "google_storage_bucket", Name: "logs", CloudProvider: "gcp"},
+		{Type: "google_storage_bucket_object", Name: "index", CloudProvider: "gcp"},
+		{Type: "google_workflows_workflow", Name: "etl", CloudProvider: "gcp"},
+		{Type: "aws_s3_bucket", Name: "data", CloudProvider: "aws"},
+	}
+
+	role, unmapped := Generate(resources)
+
+	want := []string{
+		"storage.buckets.create",
+		"storage.buckets.delete",
+		"storage.buckets.get",
+		"storage.buckets.update",
+		"storage.objects.create",
+		"storage.objects.delete",
+		"storage.objects.get",
+	}
+	if got := strings.Join(role.IncludedPermissions, ","); got != strings.Join(want, ",") {
+		t.Errorf("IncludedPermissions = %v, want %v", role.IncludedPermissions, want)
+	}
+	if sources := role.Sources["storage.buckets.create"]; len(sources) != 2 {
+		t.Errorf("storage.buckets.create sources = %v, want both buckets", sources)
+	}
+	if len(unmapped) != 1 || unmapped[0] != "google_workflows_workflow" {
+		t.Errorf("unmapped = %v, want [google_workflows_workflow]", unmapped)
+	}
+}
+
+func TestToTerraform(t *testing.T) {
+	role, _ := Generate([]provider.Resource{
+		{Type: "google_pubsub_topic", Name: "events", CloudProvider: "gcp"},
+	})
+
+	got := role.ToTerraform()
+	for _, want := range []string{
+		`resource "google_project_iam_custom_role" "least_privilege" {`,
+		`role_id     = "leastPrivilege"`,
+		"  permissions = [\n    \"pubsub.topics.create\",\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	role, _ := Generate(nil)
+
+	got, err := role.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, `"includedPermissions": []`) || strings.Contains(got, "Sources") {
+		t.Errorf("unexpected JSON:\n%s", got)
+	}
+}
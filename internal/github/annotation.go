@@ -0,0 +1,57 @@
+// Package github formats check results for GitHub Actions.
+package github
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Annotation levels
+const (
+	Error   = "error"
+	Warning = "warning"
+	Notice  = "notice"
+)
+
+// Annotation is a workflow command that GitHub Actions shows as an
+// annotation, inline on the pull request diff when it has a file and line
+type Annotation struct {
+	Level string
+	// File is relative to the repository root; annotations without a file
+	// are shown on the workflow run only
+	File    string
+	Line    int
+	Title   string
+	Message string
+}
+
+// String formats the annotation as a workflow command
+// e.g., ::error file=s3.tf,line=12,title=Missing permissions::...
+func (a Annotation) String() string {
+	var props []string
+	if a.File != "" {
+		props = append(props, "file="+escapeProperty(a.File))
+		if a.Line > 0 {
+			props = append(props, fmt.Sprintf("line=%d", a.Line))
+		}
+	}
+	if a.Title != "" {
+		props = append(props, "title="+escapeProperty(a.Title))
+	}
+
+	command := "::" + a.Level
+	if len(props) > 0 {
+		command += " " + strings.Join(props, ",")
+	}
+	return command + "::" + escapeData(a.Message)
+}
+
+// escapeData escapes the message of a workflow command
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property value of a workflow command
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
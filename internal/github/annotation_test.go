@@ -0,0 +1,35 @@
+package github
+
+import "testing"
+
+func TestAnnotationString(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation Annotation
+		want       string
+	}{
+		{
+			name:       "file and line",
+			annotation: Annotation{Level: Error, File: "s3.tf", Line: 12, Title: "Missing permissions", Message: "aws_s3_bucket.logs requires s3:GetObject"},
+			want:       "::error file=s3.tf,line=12,title=Missing permissions::aws_s3_bucket.logs requires s3:GetObject",
+		},
+		{
+			name:       "file only",
+			annotation: Annotation{Level: Warning, File: "policy.json", Message: "s3:* is granted"},
+			want:       "::warning file=policy.json::s3:* is granted",
+		},
+		{
+			name:       "escaping",
+			annotation: Annotation{Level: Warning, Title: "a, b: c", Message: "100%\nnext"},
+			want:       "::warning title=a%2C b%3A c::100%25%0Anext",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.annotation.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// Package graph renders the resources, actions and statements of a generated
+// policy as a DOT or Mermaid graph
+package graph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mizzy/least/internal/policy"
+)
+
+// NodeKind is the kind of a graph node
+type NodeKind string
+
+const (
+	// ResourceNode is an IaC resource
+	ResourceNode NodeKind = "resource"
+	// ActionNode is an IAM action
+	ActionNode NodeKind = "action"
+	// StatementNode is a policy statement
+	StatementNode NodeKind = "statement"
+)
+
+// passRole is highlighted since it lets the caller hand roles to services
+const passRole = "iam:PassRole"
+
+// Node is a resource, action or statement
+type Node struct {
+	ID    string
+	Label string
+	Kind  NodeKind
+}
+
+// Edge connects a resource to an action it requires, or an action to the
+// statement granting it
+type Edge struct {
+	From string
+	To   string
+	// PassRole marks edges into or out of iam:PassRole
+	PassRole bool
+}
+
+// Graph is a resource → action → statement graph
+type Graph struct {
+	Nodes []Node
+	Edges []Edge
+}
+
+// Build creates the graph of a generated policy, whose statements record the
+// resources they were generated from. Nodes and edges are in policy order.
+func Build(p *policy.IAMPolicy) *Graph {
+	g := &Graph{}
+	ids := make(map[string]string)
+	edges := make(map[Edge]bool)
+
+	node := func(kind NodeKind, label string) string {
+		key := string(kind) + "\x00" + label
+		if id, ok := ids[key]; ok {
+			return id
+		}
+		id := fmt.Sprintf("%c%d", kind[0], len(g.Nodes))
+		ids[key] = id
+		g.Nodes = append(g.Nodes, Node{ID: id, Label: label, Kind: kind})
+		return id
+	}
+	edge := func(from, to string, passRole bool) {
+		e := Edge{From: from, To: to, PassRole: passRole}
+		if !edges[e] {
+			edges[e] = true
+			g.Edges = append(g.Edges, e)
+		}
+	}
+
+	for i, stmt := range p.Statement {
+		if stmt.Effect != "Allow" {
+			continue
+		}
+		label := stmt.Sid
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+		}
+		stmtID := node(StatementNode, label)
+
+		for _, action := range stmt.Action {
+			actionID := node(ActionNode, action)
+			isPassRole := strings.EqualFold(action, passRole)
+			for _, res := range stmt.Sources {
+				edge(node(ResourceNode, res.Address()), actionID, isPassRole)
+			}
+			edge(actionID, stmtID, isPassRole)
+		}
+	}
+
+	return g
+}
+
+// DOT renders the graph in Graphviz DOT format
+func (g *Graph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph least {\n")
+	b.WriteString("  rankdir=LR;\n")
+
+	shapes := map[NodeKind]string{ResourceNode: "box", ActionNode: "ellipse", StatementNode: "note"}
+	for _, n := range g.Nodes {
+		attrs := fmt.Sprintf("label=%q shape=%s", n.Label, shapes[n.Kind])
+		if n.Kind == ActionNode && strings.EqualFold(n.Label, passRole) {
+			attrs += " color=red"
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", n.ID, attrs)
+	}
+	for _, e := range g.Edges {
+		if e.PassRole {
+			fmt.Fprintf(&b, "  %s -> %s [color=red penwidth=2];\n", e.From, e.To)
+		} else {
+			fmt.Fprintf(&b, "  %s -> %s;\n", e.From, e.To)
+		}
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart
+func (g *Graph) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+
+	var passRoleIDs []string
+	for _, n := range g.Nodes {
+		label := strings.ReplaceAll(n.Label, `"`, "#quot;")
+		switch n.Kind {
+		case ResourceNode:
+			fmt.Fprintf(&b, "  %s[\"%s\"]\n", n.ID, label)
+		case ActionNode:
+			fmt.Fprintf(&b, "  %s([\"%s\"])\n", n.ID, label)
+			if strings.EqualFold(n.Label, passRole) {
+				passRoleIDs = append(passRoleIDs, n.ID)
+			}
+		case StatementNode:
+			fmt.Fprintf(&b, "  %s[/\"%s\"/]\n", n.ID, label)
+		}
+	}
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.PassRole {
+			arrow = "==>"
+		}
+		fmt.Fprintf(&b, "  %s %s %s\n", e.From, arrow, e.To)
+	}
+	if len(passRoleIDs) > 0 {
+		b.WriteString("  classDef passrole stroke:#d33,stroke-width:2px\n")
+		fmt.Fprintf(&b, "  class %s passrole\n", strings.Join(passRoleIDs, ","))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,113 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/provider"
+)
+
+func testPolicy() *policy.IAMPolicy {
+	fn := provider.Resource{Type: "aws_lambda_function", Name: "api"}
+	queue := provider.Resource{Type: "aws_sqs_queue", Name: "jobs"}
+	return &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{
+				Sid:     "AwsLambdaFunctionApi",
+				Effect:  "Allow",
+				Action:  []string{"iam:PassRole", "lambda:CreateFunction"},
+				Sources: []provider.Resource{fn},
+			},
+			{
+				Sid:     "AwsSqsQueueJobs",
+				Effect:  "Allow",
+				Action:  []string{"sqs:CreateQueue"},
+				Sources: []provider.Resource{queue},
+			},
+		},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	g := Build(testPolicy())
+
+	wantNodes := []Node{
+		{"s0", "AwsLambdaFunctionApi", StatementNode},
+		{"a1", "iam:PassRole", ActionNode},
+		{"r2", "aws_lambda_function.api", ResourceNode},
+		{"a3", "lambda:CreateFunction", ActionNode},
+		{"s4", "AwsSqsQueueJobs", StatementNode},
+		{"a5", "sqs:CreateQueue", ActionNode},
+		{"r6", "aws_sqs_queue.jobs", ResourceNode},
+	}
+	if len(g.Nodes) != len(wantNodes) {
+		t.Fatalf("Nodes = %+v, want %+v", g.Nodes, wantNodes)
+	}
+	for i, n := range g.Nodes {
+		if n != wantNodes[i] {
+			t.Errorf("Nodes[%d] = %+v, want %+v", i, n, wantNodes[i])
+		}
+	}
+
+	wantEdges := []Edge{
+		{"r2", "a1", true},
+		{"a1", "s0", true},
+		{"r2", "a3", false},
+		{"a3", "s0", false},
+		{"r6", "a5", false},
+		{"a5", "s4", false},
+	}
+	if len(g.Edges) != len(wantEdges) {
+		t.Fatalf("Edges = %+v, want %+v", g.Edges, wantEdges)
+	}
+	for i, e := range g.Edges {
+		if e != wantEdges[i] {
+			t.Errorf("Edges[%d] = %+v, want %+v", i, e, wantEdges[i])
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	g := Build(testPolicy())
+
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{
+			name:   "dot",
+			output: g.DOT(),
+			want: []string{
+				"digraph least {",
+				`  r2 [label="aws_lambda_function.api" shape=box];`,
+				`  a1 [label="iam:PassRole" shape=ellipse color=red];`,
+				"  r2 -> a1 [color=red penwidth=2];",
+				"  a5 -> s4;",
+			},
+		},
+		{
+			name:   "mermaid",
+			output: g.Mermaid(),
+			want: []string{
+				"flowchart LR",
+				`  s0[/"AwsLambdaFunctionApi"/]`,
+				`  a3(["lambda:CreateFunction"])`,
+				"  r2 ==> a1",
+				"  r6 --> a5",
+				"  class a1 passrole",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, line := range tt.want {
+				if !strings.Contains(tt.output, line+"\n") {
+					t.Errorf("output missing %q:\n%s", line, tt.output)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// Package ignore reads .terraformignore and .gitignore files so ignored
+// files and directories are skipped during discovery.
+package ignore
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mizzy/least/internal/config"
+)
+
+// TerraformIgnoreFile is the ignore file Terraform uses for uploads
+const TerraformIgnoreFile = ".terraformignore"
+
+// GitIgnoreFile is the Git ignore file
+const GitIgnoreFile = ".gitignore"
+
+// rule is a single ignore pattern
+type rule struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// Rules is an ordered list of ignore patterns; later patterns override
+// earlier ones
+type Rules struct {
+	rules []rule
+}
+
+// Parse reads ignore patterns in .gitignore syntax: blank lines and lines
+// starting with # are skipped, ! re-includes a path, a trailing / matches
+// only directories and a leading / anchors the pattern to the root.
+func Parse(r io.Reader) (*Rules, error) {
+	rules := &Rules{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var ru rule
+		if strings.HasPrefix(line, "!") {
+			ru.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			ru.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.HasPrefix(line, "/") {
+			// A pattern containing a slash is matched from the root
+			line = strings.TrimPrefix(line, "/")
+			if !strings.Contains(line, "/") {
+				line += "/**"
+			}
+		}
+		if line == "" {
+			continue
+		}
+		ru.pattern = line
+		rules.rules = append(rules.rules, ru)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Load reads the named ignore files in dir, in order. Missing files are skipped.
+func Load(dir string, names ...string) (*Rules, error) {
+	rules := &Rules{}
+	for _, name := range names {
+		f, err := os.Open(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := Parse(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		rules.rules = append(rules.rules, parsed.rules...)
+	}
+	return rules, nil
+}
+
+// Empty checks if there are no patterns
+func (r *Rules) Empty() bool {
+	return r == nil || len(r.rules) == 0
+}
+
+// Match checks if a path relative to the root of the ignore files, a
+// directory when dir is true, is ignored. A path inside an ignored directory
+// is ignored too.
+func (r *Rules) Match(rel string, dir bool) bool {
+	if r.Empty() {
+		return false
+	}
+
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	ignored := false
+	for _, ru := range r.rules {
+		target := rel
+		if ru.dirOnly && !dir {
+			// Only the directories containing the file can match
+			target = filepath.ToSlash(filepath.Dir(rel))
+			if target == "." {
+				continue
+			}
+		}
+		if config.MatchPath(ru.pattern, target) {
+			ignored = !ru.negate
+		}
+	}
+	return ignored
+}
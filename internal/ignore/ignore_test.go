@@ -0,0 +1,73 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	rules, err := Parse(strings.NewReader(`
+# vendored code and fixtures
+vendor
+/generated
+fixtures/
+**/testdata/*.tf
+*.bak.tf
+!keep.bak.tf
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		dir  bool
+		want bool
+	}{
+		{"main.tf", false, false},
+		{"vendor", true, true},
+		{"vendor/aws/main.tf", false, true},
+		{"modules/vendor/main.tf", false, true},
+		{"generated/main.tf", false, true},
+		{"modules/generated/main.tf", false, false},
+		{"fixtures", true, true},
+		{"fixtures/main.tf", false, true},
+		{"fixtures.tf", false, false},
+		{"modules/s3/testdata/case.tf", false, true},
+		{"old.bak.tf", false, true},
+		{"keep.bak.tf", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := rules.Match(tt.path, tt.dir); got != tt.want {
+				t.Errorf("Match(%q, %v) = %v, want %v", tt.path, tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, TerraformIgnoreFile), []byte("examples/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := Load(dir, TerraformIgnoreFile, GitIgnoreFile)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !rules.Match("examples/basic/main.tf", false) {
+		t.Error("expected examples/basic/main.tf to be ignored")
+	}
+
+	empty, err := Load(t.TempDir(), TerraformIgnoreFile)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !empty.Empty() {
+		t.Error("expected no rules without ignore files")
+	}
+}
@@ -0,0 +1,434 @@
+// Package irsa derives candidate IAM Roles for Service Accounts (IRSA)
+// policies from Kubernetes manifests and Helm values: service accounts
+// annotated with a role ARN, and the AWS resources the workloads running
+// under them refer to in their environment, such as S3 buckets and SQS
+// queue URLs.
+package irsa
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RoleAnnotation is the service account annotation naming the IAM role its
+// pods assume
+const RoleAnnotation = "eks.amazonaws.com/role-arn"
+
+// ServiceAccount is a Kubernetes service account with an IAM role, and the
+// AWS resources of the workloads running under it
+type ServiceAccount struct {
+	// Namespace is the namespace of the service account, empty for the
+	// release namespace of Helm values
+	Namespace string
+	// Name is the name of the service account
+	Name string
+	// RoleARN is the value of the RoleAnnotation annotation
+	RoleARN string
+	// File is the manifest or values file defining the service account
+	File string
+	// Resources are the AWS resources referred to, sorted and without
+	// duplicates
+	Resources []Resource
+}
+
+// ID returns the service account as namespace/name, or its name for Helm
+// values
+func (sa ServiceAccount) ID() string {
+	if sa.Namespace == "" {
+		return sa.Name
+	}
+	return sa.Namespace + "/" + sa.Name
+}
+
+// Resource is an AWS resource a workload refers to
+type Resource struct {
+	// Service is the IAM service prefix of the resource (e.g., "s3")
+	Service string
+	// ARN is the ARN of the resource; S3 ARNs name the bucket, and Prefix
+	// the objects
+	ARN string
+	// Prefix is the key prefix of S3 objects, "" for the whole bucket
+	Prefix string
+	// Source is where the resource is referred to (e.g., "deployment/api
+	// env QUEUE_URL")
+	Source string
+}
+
+// workloadKinds are the kinds of resources running pods, and the path to
+// their pod spec
+var workloadKinds = map[string][]string{
+	"Pod":         {"spec"},
+	"Deployment":  {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"DaemonSet":   {"spec", "template", "spec"},
+	"ReplicaSet":  {"spec", "template", "spec"},
+	"Job":         {"spec", "template", "spec"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// workload is the pod spec of a workload, with the resources of its
+// container environment
+type workload struct {
+	namespace      string
+	serviceAccount string
+	resources      []Resource
+}
+
+// Scan reads the Kubernetes manifests and Helm values (values*.yaml) under
+// paths, and returns the service accounts with an IAM role, sorted by ID.
+// Templates of Helm charts are skipped, since they are not YAML until
+// rendered; files that are not valid YAML are skipped with a warning.
+func Scan(paths []string) ([]ServiceAccount, []string, error) {
+	var accounts []ServiceAccount
+	var workloads []workload
+	var warnings []string
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if file != path && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules" || isChartTemplates(file)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if ext := filepath.Ext(file); ext != ".yaml" && ext != ".yml" {
+				return nil
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			a, w, err := parse(file, data)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: %v", file, err))
+				return nil
+			}
+			accounts = append(accounts, a...)
+			workloads = append(workloads, w...)
+			return nil
+		})
+		if err != nil {
+			return nil, warnings, err
+		}
+	}
+	return link(accounts, workloads), warnings, nil
+}
+
+// isChartTemplates checks if a directory holds the templates of a Helm chart
+func isChartTemplates(dir string) bool {
+	if filepath.Base(dir) != "templates" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(filepath.Dir(dir), "Chart.yaml"))
+	return err == nil
+}
+
+// parse returns the service accounts with an IAM role and the workloads of
+// the documents of a YAML file. Documents of Helm values files
+// (values*.yaml), which have no kind, define the service account of the
+// chart and refer to resources anywhere in the values.
+func parse(filename string, data []byte) ([]ServiceAccount, []workload, error) {
+	var accounts []ServiceAccount
+	var workloads []workload
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc map[string]interface{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if doc == nil {
+			continue
+		}
+
+		kind, _ := doc["kind"].(string)
+		switch {
+		case kind == "ServiceAccount":
+			if sa, ok := manifestServiceAccount(filename, doc); ok {
+				accounts = append(accounts, sa)
+			}
+		case workloadKinds[kind] != nil:
+			workloads = append(workloads, manifestWorkload(kind, doc))
+		case kind == "" && isValuesFile(filename):
+			if sa, ok := valuesServiceAccount(filename, doc); ok {
+				accounts = append(accounts, sa)
+			}
+		}
+	}
+	return accounts, workloads, nil
+}
+
+// isValuesFile checks if a file is named like Helm values (values.yaml,
+// values-prod.yaml)
+func isValuesFile(filename string) bool {
+	return strings.HasPrefix(filepath.Base(filename), "values")
+}
+
+// manifestServiceAccount returns a ServiceAccount manifest with an IAM role
+func manifestServiceAccount(filename string, doc map[string]interface{}) (ServiceAccount, bool) {
+	metadata := object(doc, "metadata")
+	role := stringValue(object(metadata, "annotations"), RoleAnnotation)
+	if role == "" {
+		return ServiceAccount{}, false
+	}
+	return ServiceAccount{
+		Namespace: namespace(metadata),
+		Name:      stringValue(metadata, "name"),
+		RoleARN:   role,
+		File:      filename,
+	}, true
+}
+
+// manifestWorkload returns the service account of a workload and the
+// resources of the environment of its containers
+func manifestWorkload(kind string, doc map[string]interface{}) workload {
+	metadata := object(doc, "metadata")
+	spec := doc
+	for _, key := range workloadKinds[kind] {
+		spec = object(spec, key)
+	}
+
+	w := workload{namespace: namespace(metadata), serviceAccount: stringValue(spec, "serviceAccountName")}
+	if w.serviceAccount == "" {
+		w.serviceAccount = "default"
+	}
+	source := strings.ToLower(kind) + "/" + stringValue(metadata, "name")
+	for _, key := range []string{"initContainers", "containers"} {
+		containers, _ := spec[key].([]interface{})
+		for _, c := range containers {
+			env, _ := c.(map[string]interface{})["env"].([]interface{})
+			for _, e := range env {
+				v, _ := e.(map[string]interface{})
+				name, value := stringValue(v, "name"), stringValue(v, "value")
+				if res, ok := detect(name, value); ok {
+					res.Source = source + " env " + name
+					w.resources = append(w.resources, res)
+				}
+			}
+		}
+	}
+	return w
+}
+
+// valuesServiceAccount returns the service account of Helm values with an
+// IAM role (serviceAccount.annotations, as charts scaffolded by helm create
+// have), and the resources any value refers to. The service account is
+// named after serviceAccount.name, or the chart.
+func valuesServiceAccount(filename string, doc map[string]interface{}) (ServiceAccount, bool) {
+	values := object(doc, "serviceAccount")
+	role := stringValue(object(values, "annotations"), RoleAnnotation)
+	if role == "" {
+		return ServiceAccount{}, false
+	}
+
+	sa := ServiceAccount{Name: stringValue(values, "name"), RoleARN: role, File: filename}
+	if sa.Name == "" {
+		sa.Name = chartName(filepath.Dir(filename))
+	}
+	var walk func(key string, v interface{})
+	walk = func(key string, v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			// Lists of environment variables name the value
+			if name, ok := v["name"].(string); ok {
+				if value, ok := v["value"].(string); ok {
+					key = name
+					v = map[string]interface{}{name: value}
+				}
+			}
+			for k, item := range v {
+				walk(k, item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(key, item)
+			}
+		case string:
+			if res, ok := detect(key, v); ok {
+				res.Source = "values " + key
+				sa.Resources = append(sa.Resources, res)
+			}
+		}
+	}
+	walk("", doc)
+	return sa, true
+}
+
+// chartName returns the name of the Helm chart in dir, from its Chart.yaml,
+// or the name of the directory
+func chartName(dir string) string {
+	var chart struct {
+		Name string `yaml:"name"`
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "Chart.yaml")); err == nil {
+		if yaml.Unmarshal(data, &chart) == nil && chart.Name != "" {
+			return chart.Name
+		}
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return filepath.Base(dir)
+}
+
+// link adds the resources of the workloads to the service accounts they run
+// under, and sorts the service accounts by ID and their resources by ARN
+func link(accounts []ServiceAccount, workloads []workload) []ServiceAccount {
+	for i := range accounts {
+		sa := &accounts[i]
+		for _, w := range workloads {
+			if w.serviceAccount == sa.Name && (sa.Namespace == "" || w.namespace == sa.Namespace) {
+				sa.Resources = append(sa.Resources, w.resources...)
+			}
+		}
+		sort.SliceStable(sa.Resources, func(i, j int) bool {
+			a, b := sa.Resources[i], sa.Resources[j]
+			if a.ARN != b.ARN {
+				return a.ARN < b.ARN
+			}
+			return a.Prefix < b.Prefix
+		})
+		sa.Resources = uniqueResources(sa.Resources)
+	}
+	sort.SliceStable(accounts, func(i, j int) bool { return accounts[i].ID() < accounts[j].ID() })
+	return accounts
+}
+
+// uniqueResources removes resources with the same ARN and prefix as the one
+// before them, keeping the first source
+func uniqueResources(resources []Resource) []Resource {
+	var unique []Resource
+	for _, res := range resources {
+		if n := len(unique); n > 0 && unique[n-1].ARN == res.ARN && unique[n-1].Prefix == res.Prefix {
+			continue
+		}
+		unique = append(unique, res)
+	}
+	return unique
+}
+
+var (
+	// sqsURLPattern matches an SQS queue URL, capturing the region, account
+	// and queue name
+	sqsURLPattern = regexp.MustCompile(`^https://sqs\.([a-z0-9-]+)\.amazonaws\.com/([0-9]{12})/([A-Za-z0-9_.-]+)$`)
+	// namePattern matches a plain resource name, as opposed to templates,
+	// URLs or sentences
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+)
+
+// nameSuffixes map the suffixes of environment variable names holding a
+// resource name to the service of the resource
+var nameSuffixes = []struct {
+	suffix  string
+	service string
+}{
+	{"_BUCKET", "s3"},
+	{"_BUCKET_NAME", "s3"},
+	{"_TABLE", "dynamodb"},
+	{"_TABLE_NAME", "dynamodb"},
+	{"_QUEUE", "sqs"},
+	{"_QUEUE_NAME", "sqs"},
+	{"_STREAM", "kinesis"},
+	{"_STREAM_NAME", "kinesis"},
+}
+
+// detect returns the AWS resource an environment variable or value refers
+// to: ARNs, s3:// URIs and SQS queue URLs, or names whose variable name
+// ends with a suffix of nameSuffixes (e.g., ORDERS_TABLE). Names of
+// resources other than buckets are in an unknown account and region.
+func detect(name, value string) (Resource, bool) {
+	value = strings.TrimSpace(value)
+	switch {
+	case strings.HasPrefix(value, "arn:aws:"):
+		parts := strings.SplitN(value, ":", 6)
+		if len(parts) < 6 || actions[parts[2]] == nil {
+			return Resource{}, false
+		}
+		if parts[2] == "s3" {
+			bucket, prefix, _ := strings.Cut(parts[5], "/")
+			return Resource{Service: "s3", ARN: "arn:aws:s3:::" + bucket, Prefix: prefix}, true
+		}
+		return Resource{Service: parts[2], ARN: value}, true
+	case strings.HasPrefix(value, "s3://"):
+		bucket, prefix, _ := strings.Cut(strings.TrimPrefix(value, "s3://"), "/")
+		if bucket == "" {
+			return Resource{}, false
+		}
+		return Resource{Service: "s3", ARN: "arn:aws:s3:::" + bucket, Prefix: prefix}, true
+	}
+	if m := sqsURLPattern.FindStringSubmatch(value); m != nil {
+		return Resource{Service: "sqs", ARN: fmt.Sprintf("arn:aws:sqs:%s:%s:%s", m[1], m[2], m[3])}, true
+	}
+	if !namePattern.MatchString(value) {
+		return Resource{}, false
+	}
+	key := screamingSnake(name)
+	for _, s := range nameSuffixes {
+		if !strings.HasSuffix(key, s.suffix) && key != strings.TrimPrefix(s.suffix, "_") {
+			continue
+		}
+		switch s.service {
+		case "s3":
+			return Resource{Service: "s3", ARN: "arn:aws:s3:::" + value}, true
+		case "dynamodb":
+			return Resource{Service: "dynamodb", ARN: "arn:aws:dynamodb:*:*:table/" + value}, true
+		case "sqs":
+			return Resource{Service: "sqs", ARN: "arn:aws:sqs:*:*:" + value}, true
+		case "kinesis":
+			return Resource{Service: "kinesis", ARN: "arn:aws:kinesis:*:*:stream/" + value}, true
+		}
+	}
+	return Resource{}, false
+}
+
+// screamingSnake converts a camelCase Helm value key to an environment
+// variable name (e.g., "ordersTable" to "ORDERS_TABLE")
+func screamingSnake(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		if unicode.IsUpper(r) && i > 0 && !unicode.IsUpper(rune(key[i-1])) && key[i-1] != '_' {
+			b.WriteByte('_')
+		}
+		if r == '-' || r == '.' {
+			r = '_'
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// object returns an object field of an object, or nil
+func object(v map[string]interface{}, key string) map[string]interface{} {
+	o, _ := v[key].(map[string]interface{})
+	return o
+}
+
+// stringValue returns a string field of an object, or ""
+func stringValue(v map[string]interface{}, key string) string {
+	s, _ := v[key].(string)
+	return s
+}
+
+// namespace returns the namespace of a manifest's metadata
+func namespace(metadata map[string]interface{}) string {
+	if ns := stringValue(metadata, "namespace"); ns != "" {
+		return ns
+	}
+	return "default"
+}
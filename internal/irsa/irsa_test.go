@@ -0,0 +1,124 @@
+package irsa
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("manifests/worker.yaml", `
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: worker
+  namespace: payments
+  annotations:
+    eks.amazonaws.com/role-arn: arn:aws:iam::123456789012:role/payments-worker
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: export
+  namespace: payments
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          serviceAccountName: worker
+          containers:
+            - name: export
+              env:
+                - name: JOBS_QUEUE_URL
+                  value: https://sqs.us-east-1.amazonaws.com/123456789012/jobs
+                - name: EXPORT_PATH
+                  value: s3://exports/payments/
+                - name: ORDERS_TABLE
+                  value: orders
+                - name: LOG_LEVEL
+                  value: debug
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: other
+spec:
+  template:
+    spec:
+      serviceAccountName: worker
+      containers:
+        - name: other
+          env:
+            - name: CACHE_BUCKET
+              value: other-namespace
+`)
+	write("chart/Chart.yaml", "name: uploader\n")
+	write("chart/values.yaml", `
+serviceAccount:
+  annotations:
+    eks.amazonaws.com/role-arn: arn:aws:iam::123456789012:role/uploader
+config:
+  uploadsBucket: uploads
+  topicArn: arn:aws:sns:us-east-1:123456789012:uploaded
+`)
+	write("chart/templates/deployment.yaml", "{{- if .Values.enabled }}\n: invalid\n")
+
+	accounts, warnings, err := Scan([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) > 0 {
+		t.Errorf("warnings = %v", warnings)
+	}
+
+	got := make(map[string][]string)
+	for _, sa := range accounts {
+		for _, res := range sa.Resources {
+			got[sa.ID()] = append(got[sa.ID()], res.ARN+" "+res.Source)
+		}
+	}
+	want := map[string][]string{
+		"payments/worker": {
+			"arn:aws:dynamodb:*:*:table/orders cronjob/export env ORDERS_TABLE",
+			"arn:aws:s3:::exports cronjob/export env EXPORT_PATH",
+			"arn:aws:sqs:us-east-1:123456789012:jobs cronjob/export env JOBS_QUEUE_URL",
+		},
+		"uploader": {
+			"arn:aws:s3:::uploads values uploadsBucket",
+			"arn:aws:sns:us-east-1:123456789012:uploaded values topicArn",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resources = %v, want %v", got, want)
+	}
+
+	p := accounts[0].Policy()
+	resources := make(map[string][]string)
+	for _, stmt := range p.Statement {
+		resources[stmt.Sid] = stmt.Resource
+	}
+	wantResources := map[string][]string{
+		"DynamoDBTables": {"arn:aws:dynamodb:*:123456789012:table/orders", "arn:aws:dynamodb:*:123456789012:table/orders/index/*"},
+		"S3Objects":      {"arn:aws:s3:::exports/payments/*"},
+		"SQSQueues":      {"arn:aws:sqs:us-east-1:123456789012:jobs"},
+		"S3Bucket":       {"arn:aws:s3:::exports"},
+	}
+	if !reflect.DeepEqual(resources, wantResources) {
+		t.Errorf("policy resources = %v, want %v", resources, wantResources)
+	}
+	if prefixes := p.Statement[3].Condition["StringLike"]["s3:prefix"]; !reflect.DeepEqual([]string(prefixes), []string{"payments/*"}) {
+		t.Errorf("s3:prefix = %v", prefixes)
+	}
+}
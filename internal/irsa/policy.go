@@ -0,0 +1,123 @@
+package irsa
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/policy"
+)
+
+// actions are the candidate actions of a workload on the resources of each
+// service: reading and writing, but not managing, them
+var actions = map[string][]string{
+	"dynamodb": {
+		"dynamodb:BatchGetItem", "dynamodb:BatchWriteItem", "dynamodb:ConditionCheckItem", "dynamodb:DeleteItem",
+		"dynamodb:GetItem", "dynamodb:PutItem", "dynamodb:Query", "dynamodb:Scan", "dynamodb:UpdateItem",
+	},
+	"kinesis": {
+		"kinesis:DescribeStreamSummary", "kinesis:GetRecords", "kinesis:GetShardIterator", "kinesis:ListShards",
+		"kinesis:PutRecord", "kinesis:PutRecords",
+	},
+	"kms":            {"kms:Decrypt", "kms:GenerateDataKey"},
+	"s3":             {"s3:DeleteObject", "s3:GetObject", "s3:PutObject"},
+	"secretsmanager": {"secretsmanager:DescribeSecret", "secretsmanager:GetSecretValue"},
+	"sns":            {"sns:Publish"},
+	"sqs": {
+		"sqs:ChangeMessageVisibility", "sqs:DeleteMessage", "sqs:GetQueueAttributes", "sqs:GetQueueUrl",
+		"sqs:ReceiveMessage", "sqs:SendMessage",
+	},
+	"ssm": {"ssm:GetParameter", "ssm:GetParameters", "ssm:GetParametersByPath"},
+}
+
+// sids are the statement Sids of each service
+var sids = map[string]string{
+	"dynamodb":       "DynamoDBTables",
+	"kinesis":        "KinesisStreams",
+	"kms":            "KMSKeys",
+	"s3":             "S3Objects",
+	"secretsmanager": "Secrets",
+	"sns":            "SNSTopics",
+	"sqs":            "SQSQueues",
+	"ssm":            "Parameters",
+}
+
+// Policy returns the candidate policy of the service account: a statement
+// per service granting the actions of the service on the resources the
+// workloads refer to. S3 buckets also get s3:ListBucket, limited to the
+// prefixes referred to, and DynamoDB tables their indexes. Names of
+// resources in an unknown account and region are assumed to be in the
+// account of the role.
+func (sa ServiceAccount) Policy() *policy.IAMPolicy {
+	account := ""
+	if parts := strings.Split(sa.RoleARN, ":"); len(parts) > 4 {
+		account = parts[4]
+	}
+
+	arns := make(map[string][]string)
+	prefixes := make(map[string][]string)
+	for _, res := range sa.Resources {
+		arn := res.ARN
+		if account != "" {
+			arn = strings.Replace(arn, ":*:*:", ":*:"+account+":", 1)
+		}
+		switch res.Service {
+		case "s3":
+			arns["s3"] = append(arns["s3"], arn+"/"+res.Prefix+"*")
+			prefixes[arn] = append(prefixes[arn], res.Prefix)
+		case "dynamodb":
+			arns["dynamodb"] = append(arns["dynamodb"], arn, arn+"/index/*")
+		default:
+			arns[res.Service] = append(arns[res.Service], arn)
+		}
+	}
+
+	p := &policy.IAMPolicy{Version: "2012-10-17"}
+	services := make([]string, 0, len(arns))
+	for service := range arns {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		p.Statement = append(p.Statement, policy.Statement{
+			Sid:      sids[service],
+			Effect:   "Allow",
+			Action:   actions[service],
+			Resource: unique(arns[service]),
+		})
+	}
+
+	buckets := make([]string, 0, len(prefixes))
+	for bucket := range prefixes {
+		buckets = append(buckets, bucket)
+	}
+	sort.Strings(buckets)
+	for i, bucket := range buckets {
+		stmt := policy.Statement{
+			Sid:      "S3Bucket",
+			Effect:   "Allow",
+			Action:   []string{"s3:ListBucket"},
+			Resource: []string{bucket},
+		}
+		if len(buckets) > 1 {
+			stmt.Sid = fmt.Sprintf("S3Bucket%d", i+1)
+		}
+		if !slices.Contains(prefixes[bucket], "") {
+			var patterns []string
+			for _, prefix := range unique(prefixes[bucket]) {
+				patterns = append(patterns, prefix+"*")
+			}
+			stmt.Condition = policy.Condition{"StringLike": {"s3:prefix": patterns}}
+		}
+		p.Statement = append(p.Statement, stmt)
+	}
+	return p
+}
+
+// unique returns the values sorted and without duplicates
+func unique(values []string) []string {
+	sorted := slices.Clone(values)
+	sort.Strings(sorted)
+	return slices.Compact(sorted)
+}
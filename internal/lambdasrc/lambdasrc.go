@@ -0,0 +1,312 @@
+// Package lambdasrc derives the runtime execution role policies of Lambda
+// functions from their source: the AWS SDK calls of Go (aws-sdk-go-v2),
+// Python (boto3) and Node.js (AWS SDK for JavaScript v2 and v3) code. The
+// analysis is static and experimental: clients passed across packages,
+// operation names built at run time and SDKs wrapped in helpers are not
+// followed.
+package lambdasrc
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Call is an AWS SDK call found in the source of a function
+type Call struct {
+	// Service is the IAM service prefix of the call (e.g., "s3")
+	Service string
+	// Operation is the API operation (e.g., "GetObject")
+	Operation string
+	// File is the source file of the call
+	File string
+	// Line is the line of the call in File
+	Line int
+}
+
+// Actions returns the IAM actions the call needs
+func (c Call) Actions() []string {
+	if actions, ok := operationActions[c.Service+":"+c.Operation]; ok {
+		return actions
+	}
+	return []string{c.Service + ":" + c.Operation}
+}
+
+// String returns the call as service:Operation (file:line)
+func (c Call) String() string {
+	return fmt.Sprintf("%s:%s (%s:%d)", c.Service, c.Operation, c.File, c.Line)
+}
+
+// scanners are the source scanners of each file extension
+var scanners = map[string]func(string) []match{
+	".go":  scanGo,
+	".py":  scanPython,
+	".js":  scanNode,
+	".mjs": scanNode,
+	".cjs": scanNode,
+	".ts":  scanNode,
+}
+
+// match is an SDK call found by a scanner, with the SDK's service name
+type match struct {
+	service   string
+	operation string
+	offset    int
+}
+
+// Scan reads the Go, Python and Node.js source files under dir and returns
+// the SDK calls found, sorted by service, operation and location and
+// without duplicates. Test
+// files, dependencies (node_modules, vendor) and hidden directories are
+// skipped.
+func Scan(dir string) ([]Call, error) {
+	var calls []Call
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if file != dir && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules" || d.Name() == "vendor" || d.Name() == "__pycache__") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		scan, ok := scanners[filepath.Ext(file)]
+		if !ok || isTestFile(d.Name()) {
+			return nil
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		src := string(data)
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			rel = file
+		}
+		for _, m := range scan(src) {
+			calls = append(calls, Call{
+				Service:   m.service,
+				Operation: m.operation,
+				File:      filepath.ToSlash(rel),
+				Line:      strings.Count(src[:m.offset], "\n") + 1,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		a, b := calls[i], calls[j]
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		if a.Operation != b.Operation {
+			return a.Operation < b.Operation
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return slices.Compact(calls), nil
+}
+
+// isTestFile checks if a source file holds tests
+func isTestFile(name string) bool {
+	return strings.HasSuffix(name, "_test.go") ||
+		strings.HasPrefix(name, "test_") || strings.HasSuffix(name, "_test.py") ||
+		strings.Contains(name, ".test.") || strings.Contains(name, ".spec.")
+}
+
+var (
+	// goImport matches an import of an aws-sdk-go-v2 service package,
+	// capturing the alias and the package
+	goImport = regexp.MustCompile(`(?m)^\s*(?:import\s+)?(\w+\s+)?"github\.com/aws/aws-sdk-go-v2/service/(\w+)"`)
+
+	// pythonClient matches a boto3 client or resource assigned to a
+	// variable, capturing the variable and the service
+	pythonClient = regexp.MustCompile(`([\w.]+)\s*=\s*(?:boto3|[\w.]*session\w*|[\w.]*Session\(\))\.(?:client|resource)\(\s*(?:service_name\s*=\s*)?['"]([\w-]+)['"]`)
+
+	// pythonSubresource matches an object of a boto3 resource assigned to
+	// a variable, such as a DynamoDB table, capturing the variable and the
+	// resource
+	pythonSubresource = regexp.MustCompile(`([\w.]+)\s*=\s*([\w.]+)\.[A-Z]\w*\(`)
+
+	// nodeImport matches the names imported from an AWS SDK v3 package,
+	// with import or require, capturing the names and the package
+	nodeImport = regexp.MustCompile(`(?s)(?:import\s*\{([^}]*)\}\s*from|\{([^}]*)\}\s*=\s*require\()\s*['"]@aws-sdk/(client-[\w-]+|lib-dynamodb)['"]`)
+
+	// nodeV2Client matches an AWS SDK v2 client assigned to a variable,
+	// capturing the variable and the client class
+	nodeV2Client = regexp.MustCompile(`([\w.]+)\s*=\s*new\s+(?:AWS\.)?([A-Z]\w*(?:\.DocumentClient)?)\(`)
+)
+
+// scanGo returns the calls of aws-sdk-go-v2 operations: their input
+// structs (&s3.GetObjectInput{}) and paginators (s3.NewListObjectsV2Paginator)
+func scanGo(src string) []match {
+	var matches []match
+	for _, m := range goImport.FindAllStringSubmatch(src, -1) {
+		pkg := m[2]
+		alias := strings.TrimSpace(m[1])
+		if alias == "" {
+			alias = pkg
+		}
+		if alias == "_" || pkg == "" {
+			continue
+		}
+		pattern := regexp.MustCompile(`\b` + regexp.QuoteMeta(alias) + `\.(?:(\w+)Input\s*\{|New(\w+)Paginator\()`)
+		for _, loc := range pattern.FindAllStringSubmatchIndex(src, -1) {
+			operation := submatch(src, loc, 1)
+			if operation == "" {
+				operation = submatch(src, loc, 2)
+			}
+			if service := goServices.lookup(pkg); service != "" {
+				matches = append(matches, match{service: service, operation: operation, offset: loc[0]})
+			}
+		}
+	}
+	return matches
+}
+
+// scanPython returns the calls of methods of boto3 clients, resources and
+// their objects, and of the operations of their paginators and waiters
+func scanPython(src string) []match {
+	variables := make(map[string]string)
+	for _, m := range pythonClient.FindAllStringSubmatch(src, -1) {
+		variables[m[1]] = m[2]
+	}
+	for _, m := range pythonSubresource.FindAllStringSubmatch(src, -1) {
+		if service, ok := variables[m[2]]; ok {
+			variables[m[1]] = service
+		}
+	}
+
+	var matches []match
+	for variable, name := range variables {
+		service := pythonServices.lookup(name)
+		if service == "" {
+			continue
+		}
+		pattern := regexp.MustCompile(`(?:^|[^\w.])` + regexp.QuoteMeta(variable) + `\.([a-z][a-z0-9_]*)\(\s*(?:['"](\w+)['"])?`)
+		for _, loc := range pattern.FindAllStringSubmatchIndex(src, -1) {
+			method, arg := submatch(src, loc, 1), submatch(src, loc, 2)
+			offset := loc[2]
+			switch method {
+			case "get_paginator", "get_waiter":
+				if arg == "" || method == "get_waiter" {
+					continue
+				}
+				method = arg
+			}
+			if operations, ok := pythonMethods[service+":"+method]; ok {
+				for _, operation := range operations {
+					matches = append(matches, match{service: service, operation: operation, offset: offset})
+				}
+				continue
+			}
+			if ignoredPythonMethods[method] {
+				continue
+			}
+			matches = append(matches, match{service: service, operation: pascalCase(method), offset: offset})
+		}
+	}
+	return matches
+}
+
+// scanNode returns the commands of AWS SDK v3 clients constructed with new,
+// and the calls of methods of AWS SDK v2 clients
+func scanNode(src string) []match {
+	var matches []match
+	for _, m := range nodeImport.FindAllStringSubmatch(src, -1) {
+		names := m[1] + m[2]
+		pkg := m[3]
+		for _, name := range strings.Split(names, ",") {
+			// import renames with as, require destructuring with a colon
+			imported, local, renamed := strings.Cut(name, " as ")
+			if !renamed {
+				imported, local, renamed = strings.Cut(name, ":")
+			}
+			imported = strings.TrimSpace(imported)
+			local = strings.TrimSpace(local)
+			if !renamed {
+				local = imported
+			}
+			operation, ok := strings.CutSuffix(imported, "Command")
+			if !ok || operation == "" {
+				continue
+			}
+			service, operation := nodeV3Operation(pkg, operation)
+			if service == "" {
+				continue
+			}
+			pattern := regexp.MustCompile(`\bnew\s+` + regexp.QuoteMeta(local) + `\s*\(`)
+			for _, loc := range pattern.FindAllStringIndex(src, -1) {
+				matches = append(matches, match{service: service, operation: operation, offset: loc[0]})
+			}
+		}
+	}
+
+	for _, m := range nodeV2Client.FindAllStringSubmatch(src, -1) {
+		variable, class := m[1], m[2]
+		service := nodeV2Services.lookup(class)
+		if service == "" {
+			continue
+		}
+		pattern := regexp.MustCompile(`(?:^|[^\w.])` + regexp.QuoteMeta(variable) + `\.([a-z]\w*)\(`)
+		for _, loc := range pattern.FindAllStringSubmatchIndex(src, -1) {
+			method, offset := submatch(src, loc, 1), loc[2]
+			if class == "DynamoDB.DocumentClient" {
+				if operation, ok := documentClientMethods[method]; ok {
+					matches = append(matches, match{service: service, operation: operation, offset: offset})
+				}
+				continue
+			}
+			if ignoredNodeMethods[method] {
+				continue
+			}
+			matches = append(matches, match{service: service, operation: strings.ToUpper(method[:1]) + method[1:], offset: offset})
+		}
+	}
+	return matches
+}
+
+// nodeV3Operation returns the service and operation of a command of an AWS
+// SDK v3 package; commands of the DynamoDB document client are named after
+// the operations without the Item suffix
+func nodeV3Operation(pkg, operation string) (string, string) {
+	if pkg == "lib-dynamodb" {
+		if op, ok := documentClientMethods[strings.ToLower(operation[:1])+operation[1:]]; ok {
+			return "dynamodb", op
+		}
+		return "dynamodb", operation
+	}
+	return nodeV3Services.lookup(strings.TrimPrefix(pkg, "client-")), operation
+}
+
+// submatch returns the text of a submatch, or "" if it did not match
+func submatch(src string, loc []int, n int) string {
+	if loc[2*n] < 0 {
+		return ""
+	}
+	return src[loc[2*n]:loc[2*n+1]]
+}
+
+// pascalCase converts a snake_case method name to its operation name
+// (e.g., "get_object" to "GetObject")
+func pascalCase(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
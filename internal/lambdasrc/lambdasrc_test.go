@@ -0,0 +1,176 @@
+package lambdasrc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.go": `package main
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	ddb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+)
+
+func handle() {
+	client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("b")})
+	p := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{})
+	db.PutItem(ctx, &ddb.PutItemInput{})
+	states.StartExecution(ctx, &sfn.StartExecutionInput{})
+}
+`,
+		"handler.py": `import boto3
+
+sqs = boto3.client("sqs")
+dynamodb = boto3.resource("dynamodb")
+table = dynamodb.Table(os.environ["TABLE"])
+s3 = boto3.client('s3')
+
+def handler(event, context):
+    sqs.send_message(QueueUrl=url, MessageBody="x")
+    table.query(KeyConditionExpression=k)
+    s3.upload_file(path, bucket, key)
+    s3.generate_presigned_url("get_object")
+    for page in s3.get_paginator("list_objects_v2").paginate(Bucket=b):
+        pass
+`,
+		"index.mjs": `import { SNSClient, PublishCommand as Publish } from "@aws-sdk/client-sns";
+import { DynamoDBDocumentClient, GetCommand } from "@aws-sdk/lib-dynamodb";
+const { SecretsManagerClient, GetSecretValueCommand } = require("@aws-sdk/client-secrets-manager");
+
+export const handler = async () => {
+  await sns.send(new Publish({ TopicArn: arn }));
+  await doc.send(new GetCommand({ TableName: t }));
+  await sm.send(new GetSecretValueCommand({ SecretId: id }));
+};
+`,
+		"legacy.js": `const AWS = require("aws-sdk");
+const lambda = new AWS.Lambda();
+const when = new Date();
+lambda.invoke({ FunctionName: f }).promise();
+when.getTime();
+`,
+		"handler_test.go":         "package main\nimport \"github.com/aws/aws-sdk-go-v2/service/kms\"\nvar _ = &kms.DecryptInput{}\n",
+		"node_modules/x/index.js": "const { X } = require('@aws-sdk/client-kms'); new DecryptCommand();\n",
+	})
+
+	calls, err := Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, call := range calls {
+		got = append(got, call.String())
+	}
+	want := []string{
+		"dynamodb:GetItem (index.mjs:7)",
+		"dynamodb:PutItem (main.go:12)",
+		"dynamodb:Query (handler.py:10)",
+		"lambda:Invoke (legacy.js:4)",
+		"s3:GetObject (main.go:10)",
+		"s3:ListObjectsV2 (handler.py:13)",
+		"s3:ListObjectsV2 (main.go:11)",
+		"s3:PutObject (handler.py:11)",
+		"secretsmanager:GetSecretValue (index.mjs:8)",
+		"sns:Publish (index.mjs:6)",
+		"sqs:SendMessage (handler.py:9)",
+		"states:StartExecution (main.go:13)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Scan() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p := Policy("api", []Call{
+		{Service: "s3", Operation: "ListObjectsV2"},
+		{Service: "s3", Operation: "CopyObject"},
+		{Service: "lambda", Operation: "Invoke"},
+		{Service: "s3", Operation: "GetObject"},
+	})
+	var got [][]string
+	for _, stmt := range p.Statement {
+		got = append(got, append([]string{stmt.Sid}, stmt.Action...))
+	}
+	want := [][]string{
+		{"FunctionLogs", "logs:CreateLogGroup", "logs:CreateLogStream", "logs:PutLogEvents"},
+		{"Lambda", "lambda:InvokeFunction"},
+		{"S3", "s3:GetObject", "s3:ListBucket", "s3:PutObject"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("statements = %v, want %v", got, want)
+	}
+	if r := p.Statement[0].Resource[0]; r != "arn:aws:logs:*:*:log-group:/aws/lambda/api:*" {
+		t.Errorf("log group = %s", r)
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.tf": `
+data "archive_file" "api" {
+  type        = "zip"
+  source_dir  = "${path.module}/src/api"
+  output_path = "${path.module}/build/api.zip"
+}
+
+data "archive_file" "worker" {
+  type        = "zip"
+  source_file = "worker/main.py"
+  output_path = "worker.zip"
+}
+
+resource "aws_lambda_function" "api" {
+  function_name = "api"
+  filename      = data.archive_file.api.output_path
+}
+
+resource "aws_lambda_function" "worker" {
+  function_name = var.name
+  filename      = data.archive_file.worker.output_path
+}
+
+resource "aws_lambda_function" "image" {
+  function_name = "image"
+  image_uri     = "123456789012.dkr.ecr.us-east-1.amazonaws.com/image:latest"
+}
+`,
+	})
+
+	functions, err := Functions(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][3]string
+	for _, fn := range functions {
+		got = append(got, [3]string{fn.Address, fn.Name, fn.SourceDir})
+	}
+	want := [][3]string{
+		{"aws_lambda_function.api", "api", filepath.Join(dir, "src/api")},
+		{"aws_lambda_function.image", "image", ""},
+		{"aws_lambda_function.worker", "", filepath.Join(dir, "worker")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Functions() = %v, want %v", got, want)
+	}
+}
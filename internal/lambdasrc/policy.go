@@ -0,0 +1,57 @@
+package lambdasrc
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/policy"
+)
+
+// Policy returns the runtime policy of a function: writing its logs to its
+// log group (/aws/lambda/NAME, or any function's when the name is not
+// known), and a statement per service granting the actions of the SDK calls
+// on any resource, since the resources a call acts on are not known from
+// the source.
+func Policy(name string, calls []Call) *policy.IAMPolicy {
+	if name == "" {
+		name = "*"
+	}
+	p := &policy.IAMPolicy{Version: "2012-10-17"}
+	p.Statement = append(p.Statement, policy.Statement{
+		Sid:      "FunctionLogs",
+		Effect:   "Allow",
+		Action:   []string{"logs:CreateLogGroup", "logs:CreateLogStream", "logs:PutLogEvents"},
+		Resource: []string{"arn:aws:logs:*:*:log-group:/aws/lambda/" + name + ":*"},
+	})
+
+	actions := make(map[string][]string)
+	for _, call := range calls {
+		for _, action := range call.Actions() {
+			service, _, _ := strings.Cut(action, ":")
+			actions[service] = append(actions[service], action)
+		}
+	}
+	services := make([]string, 0, len(actions))
+	for service := range actions {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		sorted := slices.Clone(actions[service])
+		sort.Strings(sorted)
+		p.Statement = append(p.Statement, policy.Statement{
+			Sid:      sid(service),
+			Effect:   "Allow",
+			Action:   slices.Compact(sorted),
+			Resource: []string{"*"},
+		})
+	}
+	return p
+}
+
+// sid returns the statement Sid of a service (e.g., "CognitoIdp" for
+// cognito-idp)
+func sid(service string) string {
+	return pascalCase(strings.ReplaceAll(service, "-", "_"))
+}
@@ -0,0 +1,191 @@
+package lambdasrc
+
+import "strings"
+
+// serviceNames maps the service names of an SDK to IAM service prefixes
+type serviceNames struct {
+	// names are the names whose prefix differs from the name
+	names map[string]string
+	// strict ignores names not in names, for SDKs whose client classes
+	// cannot be told from other classes
+	strict bool
+}
+
+// lookup returns the IAM service prefix of an SDK service name, or "" if
+// it is not known
+func (s serviceNames) lookup(name string) string {
+	if prefix, ok := s.names[name]; ok {
+		return prefix
+	}
+	if s.strict {
+		return ""
+	}
+	return strings.ReplaceAll(strings.ToLower(name), "-", "")
+}
+
+// goServices are the aws-sdk-go-v2 service packages
+var goServices = serviceNames{names: map[string]string{
+	"apigatewaymanagementapi": "execute-api",
+	"appconfigdata":           "appconfig",
+	"bedrockruntime":          "bedrock",
+	"cloudwatchevents":        "events",
+	"cloudwatchlogs":          "logs",
+	"cognitoidentityprovider": "cognito-idp",
+	"costexplorer":            "ce",
+	"dynamodbstreams":         "dynamodb",
+	"elasticloadbalancingv2":  "elasticloadbalancing",
+	"eventbridge":             "events",
+	"iotdataplane":            "iot",
+	"kinesisanalyticsv2":      "kinesisanalytics",
+	"rdsdata":                 "rds-data",
+	"sagemakerruntime":        "sagemaker",
+	"sesv2":                   "ses",
+	"sfn":                     "states",
+	"timestreamwrite":         "timestream",
+}}
+
+// pythonServices are the boto3 service names
+var pythonServices = serviceNames{names: map[string]string{
+	"apigatewaymanagementapi": "execute-api",
+	"appconfigdata":           "appconfig",
+	"bedrock-runtime":         "bedrock",
+	"cognito-idp":             "cognito-idp",
+	"dynamodbstreams":         "dynamodb",
+	"elbv2":                   "elasticloadbalancing",
+	"iot-data":                "iot",
+	"kinesisanalyticsv2":      "kinesisanalytics",
+	"rds-data":                "rds-data",
+	"sagemaker-runtime":       "sagemaker",
+	"sesv2":                   "ses",
+	"stepfunctions":           "states",
+	"timestream-write":        "timestream",
+}}
+
+// nodeV3Services are the AWS SDK for JavaScript v3 client packages, without
+// the client- prefix
+var nodeV3Services = serviceNames{names: map[string]string{
+	"apigatewaymanagementapi":   "execute-api",
+	"appconfigdata":             "appconfig",
+	"bedrock-runtime":           "bedrock",
+	"cloudwatch-events":         "events",
+	"cloudwatch-logs":           "logs",
+	"cognito-identity-provider": "cognito-idp",
+	"cost-explorer":             "ce",
+	"dynamodb-streams":          "dynamodb",
+	"elastic-load-balancing-v2": "elasticloadbalancing",
+	"eventbridge":               "events",
+	"iot-data-plane":            "iot",
+	"kinesis-analytics-v2":      "kinesisanalytics",
+	"rds-data":                  "rds-data",
+	"sagemaker-runtime":         "sagemaker",
+	"sesv2":                     "ses",
+	"sfn":                       "states",
+	"timestream-write":          "timestream",
+}}
+
+// nodeV2Services are the client classes of the AWS SDK for JavaScript v2
+// (and the aggregated clients of v3, which have the same methods)
+var nodeV2Services = serviceNames{strict: true, names: map[string]string{
+	"ApiGatewayManagementApi":        "execute-api",
+	"CloudWatch":                     "cloudwatch",
+	"CloudWatchEvents":               "events",
+	"CloudWatchLogs":                 "logs",
+	"CognitoIdentityServiceProvider": "cognito-idp",
+	"DynamoDB":                       "dynamodb",
+	"DynamoDB.DocumentClient":        "dynamodb",
+	"DynamoDBStreams":                "dynamodb",
+	"EventBridge":                    "events",
+	"Firehose":                       "firehose",
+	"KMS":                            "kms",
+	"Kinesis":                        "kinesis",
+	"Lambda":                         "lambda",
+	"S3":                             "s3",
+	"SES":                            "ses",
+	"SESV2":                          "ses",
+	"SNS":                            "sns",
+	"SQS":                            "sqs",
+	"SSM":                            "ssm",
+	"STS":                            "sts",
+	"SecretsManager":                 "secretsmanager",
+	"StepFunctions":                  "states",
+}}
+
+// operationActions are the IAM actions of operations not authorized by the
+// action of the same name
+var operationActions = map[string][]string{
+	"bedrock:Converse":                {"bedrock:InvokeModel"},
+	"bedrock:ConverseStream":          {"bedrock:InvokeModelWithResponseStream"},
+	"execute-api:DeleteConnection":    {"execute-api:ManageConnections"},
+	"execute-api:GetConnection":       {"execute-api:ManageConnections"},
+	"execute-api:PostToConnection":    {"execute-api:ManageConnections"},
+	"lambda:Invoke":                   {"lambda:InvokeFunction"},
+	"lambda:InvokeAsync":              {"lambda:InvokeFunction"},
+	"lambda:InvokeWithResponseStream": {"lambda:InvokeFunction"},
+	"s3:CompleteMultipartUpload":      {"s3:PutObject"},
+	"s3:CopyObject":                   {"s3:GetObject", "s3:PutObject"},
+	"s3:CreateMultipartUpload":        {"s3:PutObject"},
+	"s3:DeleteObjects":                {"s3:DeleteObject"},
+	"s3:HeadBucket":                   {"s3:ListBucket"},
+	"s3:HeadObject":                   {"s3:GetObject"},
+	"s3:ListBuckets":                  {"s3:ListAllMyBuckets"},
+	"s3:ListObjectVersions":           {"s3:ListBucketVersions"},
+	"s3:ListObjects":                  {"s3:ListBucket"},
+	"s3:ListObjectsV2":                {"s3:ListBucket"},
+	"s3:ListParts":                    {"s3:ListMultipartUploadParts"},
+	"s3:Upload":                       {"s3:PutObject"},
+	"s3:UploadPart":                   {"s3:PutObject"},
+	"s3:UploadPartCopy":               {"s3:GetObject", "s3:PutObject"},
+}
+
+// pythonMethods are the operations of boto3 methods that are not named after
+// an operation: the transfer methods of S3 clients, the actions of S3
+// objects and the batch writer of DynamoDB tables
+var pythonMethods = map[string][]string{
+	"dynamodb:batch_writer": {"BatchWriteItem"},
+	"s3:copy":               {"CopyObject"},
+	"s3:delete":             {"DeleteObject"},
+	"s3:download_file":      {"GetObject"},
+	"s3:download_fileobj":   {"GetObject"},
+	"s3:get":                {"GetObject"},
+	"s3:put":                {"PutObject"},
+	"s3:upload_file":        {"PutObject"},
+	"s3:upload_fileobj":     {"PutObject"},
+}
+
+// ignoredPythonMethods are methods of boto3 clients and resources that call
+// no operation
+var ignoredPythonMethods = map[string]bool{
+	"can_paginate":            true,
+	"close":                   true,
+	"generate_presigned_post": true,
+	"generate_presigned_url":  true,
+	"load":                    true,
+	"reload":                  true,
+}
+
+// ignoredNodeMethods are methods of AWS SDK v2 clients that call no
+// operation
+var ignoredNodeMethods = map[string]bool{
+	"createPresignedPost": true,
+	"getSignedUrl":        true,
+	"getSignedUrlPromise": true,
+	"makeRequest":         true,
+	"promise":             true,
+	"send":                true,
+	"waitFor":             true,
+}
+
+// documentClientMethods are the operations of the methods of the DynamoDB
+// document client
+var documentClientMethods = map[string]string{
+	"batchGet":      "BatchGetItem",
+	"batchWrite":    "BatchWriteItem",
+	"delete":        "DeleteItem",
+	"get":           "GetItem",
+	"put":           "PutItem",
+	"query":         "Query",
+	"scan":          "Scan",
+	"transactGet":   "TransactGetItems",
+	"transactWrite": "TransactWriteItems",
+	"update":        "UpdateItem",
+}
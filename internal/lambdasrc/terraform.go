@@ -0,0 +1,149 @@
+package lambdasrc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Function is an aws_lambda_function of a Terraform module
+type Function struct {
+	// Address is the address of the function (e.g., aws_lambda_function.api)
+	Address string
+	// Name is the literal function_name of the function, if any
+	Name string
+	// SourceDir is the directory of the function's source, the source_dir
+	// (or the directory of the source_file) of the archive_file data source
+	// its package is built by; empty if the package is not built from
+	// source in the module
+	SourceDir string
+	// File is the file defining the function
+	File string
+	// Line is the line of the function in File
+	Line int
+}
+
+// archive is the source of an archive_file data source
+type archive struct {
+	sourceDir  string
+	sourceFile string
+}
+
+// Functions reads the Terraform files of the module in dir and returns its
+// Lambda functions, sorted by address. The source of a function is found
+// through the archive_file data source its filename refers to, with
+// paths relative to dir.
+func Functions(dir string) ([]Function, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := &hcl.EvalContext{Variables: map[string]cty.Value{
+		"path": cty.ObjectVal(map[string]cty.Value{
+			"module": cty.StringVal("."),
+			"root":   cty.StringVal("."),
+			"cwd":    cty.StringVal("."),
+		}),
+	}}
+
+	parser := hclparse.NewParser()
+	archives := make(map[string]archive)
+	type function struct {
+		Function
+		filename hcl.Expression
+	}
+	var functions []function
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		f, diags := parser.ParseHCL(data, file)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("parsing %s: %s", file, diags.Error())
+		}
+		content, _, _ := f.Body.PartialContent(&hcl.BodySchema{
+			Blocks: []hcl.BlockHeaderSchema{
+				{Type: "resource", LabelNames: []string{"type", "name"}},
+				{Type: "data", LabelNames: []string{"type", "name"}},
+			},
+		})
+		for _, block := range content.Blocks {
+			attrs, _ := block.Body.JustAttributes()
+			switch {
+			case block.Type == "data" && block.Labels[0] == "archive_file":
+				archives[block.Labels[1]] = archive{
+					sourceDir:  stringAttribute(attrs, "source_dir", ctx),
+					sourceFile: stringAttribute(attrs, "source_file", ctx),
+				}
+			case block.Type == "resource" && block.Labels[0] == "aws_lambda_function":
+				fn := function{Function: Function{
+					Address: "aws_lambda_function." + block.Labels[1],
+					Name:    stringAttribute(attrs, "function_name", ctx),
+					File:    file,
+					Line:    block.DefRange.Start.Line,
+				}}
+				if attr, ok := attrs["filename"]; ok {
+					fn.filename = attr.Expr
+				}
+				functions = append(functions, fn)
+			}
+		}
+	}
+
+	result := make([]Function, 0, len(functions))
+	for _, fn := range functions {
+		if fn.filename != nil {
+			if source, ok := archives[archiveName(fn.filename)]; ok {
+				switch {
+				case source.sourceDir != "":
+					fn.SourceDir = filepath.Join(dir, source.sourceDir)
+				case source.sourceFile != "":
+					fn.SourceDir = filepath.Join(dir, filepath.Dir(source.sourceFile))
+				}
+			}
+		}
+		result = append(result, fn.Function)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Address < result[j].Address })
+	return result, nil
+}
+
+// archiveName returns the name of the archive_file data source an
+// expression refers to (data.archive_file.NAME.output_path), or ""
+func archiveName(expr hcl.Expression) string {
+	for _, traversal := range expr.Variables() {
+		if traversal.RootName() != "data" || len(traversal) < 3 {
+			continue
+		}
+		kind, ok := traversal[1].(hcl.TraverseAttr)
+		if !ok || kind.Name != "archive_file" {
+			continue
+		}
+		if name, ok := traversal[2].(hcl.TraverseAttr); ok {
+			return name.Name
+		}
+	}
+	return ""
+}
+
+// stringAttribute returns the value of a string attribute, or "" if it is
+// not set or not known
+func stringAttribute(attrs hcl.Attributes, name string, ctx *hcl.EvalContext) string {
+	attr, ok := attrs[name]
+	if !ok {
+		return ""
+	}
+	val, diags := attr.Expr.Value(ctx)
+	if diags.HasErrors() || !val.IsKnown() || val.IsNull() || val.Type() != cty.String {
+		return ""
+	}
+	return strings.TrimSpace(val.AsString())
+}
@@ -0,0 +1,267 @@
+// Package lint checks policies for mistakes that IAM would reject or
+// silently ignore: malformed or unknown actions, malformed ARNs, actions
+// that cannot apply to the statement's resources, and grants that are
+// missing the conditions that should constrain them.
+//
+// It runs over generated policies as a safety net for the generator and
+// its mappings.
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/risk"
+	"github.com/mizzy/least/internal/sar"
+)
+
+// Severity is how serious a finding is
+type Severity string
+
+const (
+	// Error is a finding that makes the policy invalid or wrong
+	Error Severity = "error"
+	// Warning is a finding that likely makes a grant ineffective or too broad
+	Warning Severity = "warning"
+)
+
+// Checks reported in findings
+const (
+	CheckInvalidStatement = "invalid-statement"
+	CheckDuplicateSid     = "duplicate-sid"
+	CheckMalformedAction  = "malformed-action"
+	CheckUnknownAction    = "unknown-action"
+	CheckMalformedARN     = "malformed-arn"
+	CheckResourceMismatch = "resource-mismatch"
+	CheckInvalidCondition = "invalid-condition"
+	CheckMissingCondition = "missing-condition"
+)
+
+// Finding is a problem found in a policy
+type Finding struct {
+	Severity  Severity
+	Check     string
+	Statement string
+	Message   string
+}
+
+// String formats the finding for display
+func (f Finding) String() string {
+	return fmt.Sprintf("[%s] %s: %s (%s)", f.Severity, f.Statement, f.Message, f.Check)
+}
+
+// HasErrors checks if any finding is an error
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// actionPattern matches an action in service:Action form, with wildcards
+var actionPattern = regexp.MustCompile(`^[A-Za-z0-9-]+:[A-Za-z0-9*?]+$`)
+
+// interpolation matches Terraform interpolations in ARNs of Terraform output
+var interpolation = regexp.MustCompile(`\$\{[^}]*\}`)
+
+// arnServices lists, by action service prefix, the other services whose
+// ARNs its actions apply to
+var arnServices = map[string][]string{
+	"sts": {"iam"},
+}
+
+// requiredConditions lists actions that, granted on every resource, must be
+// constrained by a condition key
+var requiredConditions = map[string]string{
+	"iam:passrole":                "iam:PassedToService",
+	"iam:createservicelinkedrole": "iam:AWSServiceName",
+}
+
+// conditionOperators are the base condition operators, in lowercase
+var conditionOperators = map[string]bool{
+	"stringequals": true, "stringnotequals": true, "stringequalsignorecase": true,
+	"stringnotequalsignorecase": true, "stringlike": true, "stringnotlike": true,
+	"numericequals": true, "numericnotequals": true, "numericlessthan": true,
+	"numericlessthanequals": true, "numericgreaterthan": true, "numericgreaterthanequals": true,
+	"dateequals": true, "datenotequals": true, "datelessthan": true,
+	"datelessthanequals": true, "dategreaterthan": true, "dategreaterthanequals": true,
+	"bool": true, "binaryequals": true, "ipaddress": true, "notipaddress": true,
+	"arnequals": true, "arnlike": true, "arnnotequals": true, "arnnotlike": true,
+	"null": true,
+}
+
+// Lint checks every statement of a policy
+func Lint(p *policy.IAMPolicy) []Finding {
+	var findings []Finding
+	sids := make(map[string]bool)
+
+	for i, stmt := range p.Statement {
+		label := risk.StatementLabel(stmt, i)
+		report := func(severity Severity, check, format string, args ...interface{}) {
+			findings = append(findings, Finding{
+				Severity:  severity,
+				Check:     check,
+				Statement: label,
+				Message:   fmt.Sprintf(format, args...),
+			})
+		}
+
+		if stmt.Sid != "" {
+			if sids[stmt.Sid] {
+				report(Error, CheckDuplicateSid, "Sid %q is used by another statement", stmt.Sid)
+			}
+			sids[stmt.Sid] = true
+		}
+
+		if stmt.Effect != "Allow" && stmt.Effect != "Deny" {
+			report(Error, CheckInvalidStatement, "Effect must be Allow or Deny, got %q", stmt.Effect)
+		}
+		if len(stmt.Action) == 0 && len(stmt.NotAction) == 0 {
+			report(Error, CheckInvalidStatement, "no Action or NotAction")
+		}
+		if len(stmt.Resource) == 0 && len(stmt.NotResource) == 0 {
+			report(Error, CheckInvalidStatement, "no Resource or NotResource")
+		}
+
+		for _, action := range append(append([]string{}, stmt.Action...), stmt.NotAction...) {
+			if action != "*" && !actionPattern.MatchString(action) {
+				report(Error, CheckMalformedAction, "%s is not in service:Action form", action)
+				continue
+			}
+			if problem, ok := sar.Check(action); !ok {
+				report(Warning, CheckUnknownAction, "%s", problem)
+			}
+		}
+
+		services := make(map[string]bool)
+		for _, resource := range append(append([]string{}, stmt.Resource...), stmt.NotResource...) {
+			service, err := arnService(resource)
+			if err != nil {
+				report(Error, CheckMalformedARN, "%s: %v", resource, err)
+				continue
+			}
+			if service != "" {
+				services[service] = true
+			}
+		}
+		if len(services) > 0 && len(stmt.NotResource) == 0 {
+			var mismatched []string
+			for _, action := range stmt.Action {
+				if !appliesTo(action, services) {
+					mismatched = append(mismatched, action)
+				}
+			}
+			if len(mismatched) > 0 {
+				report(Warning, CheckResourceMismatch, "%s cannot apply to the %s resources of the statement", strings.Join(mismatched, ", "), serviceList(services))
+			}
+		}
+
+		for operator := range stmt.Condition {
+			if !validOperator(operator) {
+				report(Error, CheckInvalidCondition, "unknown condition operator %s", operator)
+			}
+		}
+		if stmt.Effect == "Allow" && risk.HasWildcardResource(stmt) {
+			for _, action := range stmt.Action {
+				key, ok := requiredConditions[strings.ToLower(action)]
+				if ok && !hasConditionKey(stmt.Condition, key) {
+					report(Warning, CheckMissingCondition, `%s on Resource "*" should be constrained by a %s condition`, action, key)
+				}
+			}
+		}
+	}
+
+	return findings
+}
+
+// arnService validates a resource and returns the service of its ARN. It
+// returns an empty service for "*" and ARNs whose service is interpolated.
+func arnService(resource string) (string, error) {
+	if resource == "*" {
+		return "", nil
+	}
+	resolved := interpolation.ReplaceAllString(resource, "x")
+	if resolved == "x" {
+		// The whole ARN is a Terraform reference
+		return "", nil
+	}
+
+	parts := strings.SplitN(resolved, ":", 6)
+	if len(parts) < 6 || parts[0] != "arn" {
+		return "", fmt.Errorf("not in arn:partition:service:region:account:resource form")
+	}
+	if parts[1] == "" {
+		return "", fmt.Errorf("missing partition")
+	}
+	if parts[2] == "" {
+		return "", fmt.Errorf("missing service")
+	}
+	if parts[5] == "" {
+		return "", fmt.Errorf("missing resource")
+	}
+
+	original := strings.SplitN(resource, ":", 6)
+	if len(original) < 6 || strings.ContainsAny(original[2], "${}*?") {
+		return "", nil
+	}
+	return original[2], nil
+}
+
+// appliesTo checks if an action can apply to resources of the services
+func appliesTo(action string, services map[string]bool) bool {
+	service, _, ok := strings.Cut(action, ":")
+	if !ok {
+		return true
+	}
+	if services[service] {
+		return true
+	}
+	for _, s := range arnServices[service] {
+		if services[s] {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceList formats the services of a statement's resources
+func serviceList(services map[string]bool) string {
+	names := make([]string, 0, len(services))
+	for s := range services {
+		names = append(names, s)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "/")
+}
+
+// validOperator checks a condition operator, which may have a ForAllValues
+// or ForAnyValue qualifier and an IfExists suffix
+func validOperator(operator string) bool {
+	op := strings.ToLower(operator)
+	if rest, ok := strings.CutPrefix(op, "forallvalues:"); ok {
+		op = rest
+	} else if rest, ok := strings.CutPrefix(op, "foranyvalue:"); ok {
+		op = rest
+	}
+	if op != "null" {
+		op = strings.TrimSuffix(op, "ifexists")
+	}
+	return conditionOperators[op]
+}
+
+// hasConditionKey checks if a condition uses a key, under any operator
+func hasConditionKey(condition policy.Condition, key string) bool {
+	for _, keys := range condition {
+		for k := range keys {
+			if strings.EqualFold(k, key) {
+				return true
+			}
+		}
+	}
+	return false
+}
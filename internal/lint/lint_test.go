@@ -0,0 +1,141 @@
+package lint
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mizzy/least/internal/policy"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement policy.Statement
+		want      []string
+	}{
+		{
+			name: "valid statement",
+			statement: policy.Statement{
+				Effect:   "Allow",
+				Action:   policy.StringList{"s3:GetObject", "s3:List*"},
+				Resource: policy.StringList{"arn:aws:s3:::bucket", "arn:aws:s3:::bucket/*"},
+			},
+		},
+		{
+			name: "terraform interpolations",
+			statement: policy.Statement{
+				Effect: "Allow",
+				Action: policy.StringList{"sqs:SendMessage"},
+				Resource: policy.StringList{
+					"arn:aws:sqs:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:queue",
+					"${aws_sqs_queue.main.arn}",
+				},
+			},
+		},
+		{
+			name: "invalid effect and missing resource",
+			statement: policy.Statement{
+				Effect: "Permit",
+				Action: policy.StringList{"s3:GetObject"},
+			},
+			want: []string{CheckInvalidStatement, CheckInvalidStatement},
+		},
+		{
+			name: "malformed and unknown actions",
+			statement: policy.Statement{
+				Effect:   "Allow",
+				Action:   policy.StringList{"s3 GetObject", "s3:GetObjcet"},
+				Resource: policy.StringList{"*"},
+			},
+			want: []string{CheckMalformedAction, CheckUnknownAction},
+		},
+		{
+			name: "malformed ARNs",
+			statement: policy.Statement{
+				Effect:   "Allow",
+				Action:   policy.StringList{"s3:GetObject"},
+				Resource: policy.StringList{"s3:::bucket", "arn:aws::::bucket"},
+			},
+			want: []string{CheckMalformedARN, CheckMalformedARN},
+		},
+		{
+			name: "resource mismatch",
+			statement: policy.Statement{
+				Effect:   "Allow",
+				Action:   policy.StringList{"lambda:GetFunction", "ec2:DescribeSubnets", "iam:PassRole"},
+				Resource: policy.StringList{"arn:aws:lambda:*:*:function:processor"},
+			},
+			want: []string{CheckResourceMismatch},
+		},
+		{
+			name: "sts actions on IAM roles",
+			statement: policy.Statement{
+				Effect:   "Allow",
+				Action:   policy.StringList{"sts:AssumeRole"},
+				Resource: policy.StringList{"arn:aws:iam::*:role/deploy"},
+			},
+		},
+		{
+			name: "pass role without condition",
+			statement: policy.Statement{
+				Effect:   "Allow",
+				Action:   policy.StringList{"iam:PassRole"},
+				Resource: policy.StringList{"*"},
+			},
+			want: []string{CheckMissingCondition},
+		},
+		{
+			name: "pass role with condition",
+			statement: policy.Statement{
+				Effect:   "Allow",
+				Action:   policy.StringList{"iam:PassRole"},
+				Resource: policy.StringList{"*"},
+				Condition: policy.Condition{
+					"StringEquals": {"iam:PassedToService": {"lambda.amazonaws.com"}},
+				},
+			},
+		},
+		{
+			name: "condition operators",
+			statement: policy.Statement{
+				Effect:   "Allow",
+				Action:   policy.StringList{"s3:GetObject"},
+				Resource: policy.StringList{"*"},
+				Condition: policy.Condition{
+					"ForAnyValue:StringLikeIfExists": {"aws:TagKeys": {"env"}},
+					"StringEqual":                    {"aws:RequestedRegion": {"us-east-1"}},
+				},
+			},
+			want: []string{CheckInvalidCondition},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := Lint(&policy.IAMPolicy{Statement: []policy.Statement{tt.statement}})
+			var got []string
+			for _, f := range findings {
+				got = append(got, f.Check)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lint() checks = %v, want %v (%v)", got, tt.want, findings)
+			}
+		})
+	}
+}
+
+func TestLintDuplicateSid(t *testing.T) {
+	stmt := policy.Statement{
+		Sid:      "Bucket",
+		Effect:   "Allow",
+		Action:   policy.StringList{"s3:GetObject"},
+		Resource: policy.StringList{"*"},
+	}
+	findings := Lint(&policy.IAMPolicy{Statement: []policy.Statement{stmt, stmt}})
+	if len(findings) != 1 || findings[0].Check != CheckDuplicateSid {
+		t.Fatalf("Lint() = %v, want one %s finding", findings, CheckDuplicateSid)
+	}
+	if !HasErrors(findings) {
+		t.Error("HasErrors() = false, want true")
+	}
+}
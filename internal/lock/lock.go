@@ -0,0 +1,139 @@
+// Package lock handles the permission lockfile.
+//
+// A lockfile is a normalized snapshot of the permissions the IaC requires:
+// each action and the resources it is required on. It is committed next to
+// the IaC, so permission growth shows up as a reviewable change to it, and
+// check --lock fails on permissions beyond it:
+//
+//	version: 1
+//	permissions:
+//	  s3:CreateBucket:
+//	    - arn:aws:s3:::logs
+//	  sqs:CreateQueue:
+//	    - arn:aws:sqs:*:*:jobs
+package lock
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mizzy/least/internal/policy"
+)
+
+// DefaultFile is the lockfile used when none is specified
+const DefaultFile = "least.lock"
+
+// version is the version of the lockfile format
+const version = 1
+
+// header is written at the top of lockfiles
+const header = "# Permissions the IaC requires, written by least lock. Review changes to it like\n# code; least check --lock fails when the IaC requires permissions beyond it.\n"
+
+// Lock is the snapshot of the required permissions
+type Lock struct {
+	Version int `yaml:"version"`
+	// Permissions maps each action to the sorted resources it is required on
+	Permissions map[string][]string `yaml:"permissions"`
+}
+
+// Growth is an action required beyond a lockfile
+type Growth struct {
+	Action string
+	// New is true if the lockfile does not have the action at all
+	New bool
+	// Resources are the resources the action is required on beyond the
+	// lockfile
+	Resources []string
+}
+
+// FromPolicy returns the lock of the actions and resources the Allow
+// statements of a policy grant
+func FromPolicy(p *policy.IAMPolicy) *Lock {
+	l := &Lock{Version: version, Permissions: make(map[string][]string)}
+	for _, stmt := range p.Statement {
+		if stmt.Effect != "Allow" {
+			continue
+		}
+		for _, action := range stmt.Action {
+			l.Permissions[action] = append(l.Permissions[action], stmt.Resource...)
+		}
+	}
+	for action, resources := range l.Permissions {
+		slices.Sort(resources)
+		l.Permissions[action] = slices.Compact(resources)
+	}
+	return l
+}
+
+// Load reads a lockfile
+func Load(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading lockfile: %w", err)
+	}
+
+	var l Lock
+	if err := yaml.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("parsing lockfile: %w", err)
+	}
+	if l.Version != version {
+		return nil, fmt.Errorf("unsupported lockfile version %d (want %d); run least lock to rewrite it", l.Version, version)
+	}
+	if l.Permissions == nil {
+		l.Permissions = make(map[string][]string)
+	}
+	return &l, nil
+}
+
+// Save writes the lock to a file
+func (l *Lock) Save(path string) error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("encoding lockfile: %w", err)
+	}
+	return os.WriteFile(path, append([]byte(header), data...), 0644)
+}
+
+// Growth returns the permissions of current beyond the lock, sorted by
+// action. Resources are compared literally, except that "*" in the lock
+// covers any resource.
+func (l *Lock) Growth(current *Lock) []Growth {
+	var growth []Growth
+	for action, resources := range current.Permissions {
+		locked, ok := l.Permissions[action]
+		if !ok {
+			growth = append(growth, Growth{Action: action, New: true, Resources: resources})
+			continue
+		}
+		if slices.Contains(locked, "*") {
+			continue
+		}
+		var beyond []string
+		for _, r := range resources {
+			if !slices.Contains(locked, r) {
+				beyond = append(beyond, r)
+			}
+		}
+		if len(beyond) > 0 {
+			growth = append(growth, Growth{Action: action, Resources: beyond})
+		}
+	}
+	sort.Slice(growth, func(i, j int) bool { return growth[i].Action < growth[j].Action })
+	return growth
+}
+
+// Unused returns the actions of the lock current no longer requires, sorted
+func (l *Lock) Unused(current *Lock) []string {
+	var unused []string
+	for action := range l.Permissions {
+		if _, ok := current.Permissions[action]; !ok {
+			unused = append(unused, action)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
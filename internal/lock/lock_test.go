@@ -0,0 +1,50 @@
+package lock
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mizzy/least/internal/policy"
+)
+
+func TestLock(t *testing.T) {
+	p := &policy.IAMPolicy{Statement: []policy.Statement{
+		{Effect: "Allow", Action: []string{"s3:CreateBucket", "s3:PutObject"}, Resource: []string{"arn:aws:s3:::logs"}},
+		{Effect: "Allow", Action: []string{"s3:PutObject"}, Resource: []string{"arn:aws:s3:::data", "arn:aws:s3:::logs"}},
+		{Effect: "Allow", Action: []string{"sqs:CreateQueue"}, Resource: []string{"*"}},
+		{Effect: "Deny", Action: []string{"iam:*"}, Resource: []string{"*"}},
+	}}
+
+	path := filepath.Join(t.TempDir(), DefaultFile)
+	if err := FromPolicy(p).Save(path); err != nil {
+		t.Fatal(err)
+	}
+	locked, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	want := map[string][]string{
+		"s3:CreateBucket": {"arn:aws:s3:::logs"},
+		"s3:PutObject":    {"arn:aws:s3:::data", "arn:aws:s3:::logs"},
+		"sqs:CreateQueue": {"*"},
+	}
+	if !reflect.DeepEqual(locked.Permissions, want) {
+		t.Errorf("Permissions = %v, want %v", locked.Permissions, want)
+	}
+
+	current := FromPolicy(&policy.IAMPolicy{Statement: []policy.Statement{
+		{Effect: "Allow", Action: []string{"s3:PutObject"}, Resource: []string{"arn:aws:s3:::logs", "arn:aws:s3:::new"}},
+		{Effect: "Allow", Action: []string{"sqs:CreateQueue", "sqs:DeleteQueue"}, Resource: []string{"arn:aws:sqs:*:*:jobs"}},
+	}})
+	wantGrowth := []Growth{
+		{Action: "s3:PutObject", Resources: []string{"arn:aws:s3:::new"}},
+		{Action: "sqs:DeleteQueue", New: true, Resources: []string{"arn:aws:sqs:*:*:jobs"}},
+	}
+	if got := locked.Growth(current); !reflect.DeepEqual(got, wantGrowth) {
+		t.Errorf("Growth() = %+v, want %+v", got, wantGrowth)
+	}
+	if got := locked.Unused(current); !reflect.DeepEqual(got, []string{"s3:CreateBucket"}) {
+		t.Errorf("Unused() = %v, want [s3:CreateBucket]", got)
+	}
+}
@@ -0,0 +1,64 @@
+package managedpolicy
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+
+	"github.com/mizzy/least/internal/offline"
+)
+
+// API is the subset of the IAM client used to fetch managed policies
+type API interface {
+	GetPolicy(ctx context.Context, params *iam.GetPolicyInput, optFns ...func(*iam.Options)) (*iam.GetPolicyOutput, error)
+	GetPolicyVersion(ctx context.Context, params *iam.GetPolicyVersionInput, optFns ...func(*iam.Options)) (*iam.GetPolicyVersionOutput, error)
+}
+
+// Fetch returns the default version document of a managed IAM policy from
+// the IAM API. Works for both customer-managed and AWS-managed policies
+// (e.g., arn:aws:iam::aws:policy/PowerUserAccess).
+func Fetch(ctx context.Context, policyARN string) ([]byte, error) {
+	if offline.Enabled() {
+		return nil, offline.ErrOffline
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+	return FetchWithAPI(ctx, iam.NewFromConfig(cfg), policyARN)
+}
+
+// FetchWithAPI returns the default version document of a managed IAM policy
+// using the given client
+func FetchWithAPI(ctx context.Context, api API, policyARN string) ([]byte, error) {
+	pol, err := api.GetPolicy(ctx, &iam.GetPolicyInput{PolicyArn: aws.String(policyARN)})
+	if err != nil {
+		return nil, fmt.Errorf("getting policy %s: %w", policyARN, err)
+	}
+	if pol.Policy == nil || aws.ToString(pol.Policy.DefaultVersionId) == "" {
+		return nil, fmt.Errorf("policy %s has no default version", policyARN)
+	}
+
+	versionID := pol.Policy.DefaultVersionId
+	version, err := api.GetPolicyVersion(ctx, &iam.GetPolicyVersionInput{
+		PolicyArn: aws.String(policyARN),
+		VersionId: versionID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting policy version %s: %w", aws.ToString(versionID), err)
+	}
+	if version.PolicyVersion == nil || aws.ToString(version.PolicyVersion.Document) == "" {
+		return nil, fmt.Errorf("empty policy document")
+	}
+
+	// The IAM API returns documents URL-encoded
+	document, err := url.QueryUnescape(aws.ToString(version.PolicyVersion.Document))
+	if err != nil {
+		return nil, fmt.Errorf("decoding policy document: %w", err)
+	}
+	return []byte(document), nil
+}
@@ -0,0 +1,39 @@
+package managedpolicy
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+type fakeIAM struct {
+	document string
+}
+
+func (f *fakeIAM) GetPolicy(ctx context.Context, params *iam.GetPolicyInput, optFns ...func(*iam.Options)) (*iam.GetPolicyOutput, error) {
+	return &iam.GetPolicyOutput{Policy: &types.Policy{Arn: params.PolicyArn, DefaultVersionId: aws.String("v3")}}, nil
+}
+
+func (f *fakeIAM) GetPolicyVersion(ctx context.Context, params *iam.GetPolicyVersionInput, optFns ...func(*iam.Options)) (*iam.GetPolicyVersionOutput, error) {
+	if aws.ToString(params.VersionId) != "v3" {
+		return nil, &types.NoSuchEntityException{Message: aws.String("no such version")}
+	}
+	return &iam.GetPolicyVersionOutput{PolicyVersion: &types.PolicyVersion{Document: aws.String(f.document)}}, nil
+}
+
+func TestFetchWithAPI(t *testing.T) {
+	doc := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`
+	api := &fakeIAM{document: url.QueryEscape(doc)}
+
+	got, err := FetchWithAPI(context.Background(), api, "arn:aws:iam::123456789012:policy/deploy")
+	if err != nil {
+		t.Fatalf("FetchWithAPI() error = %v", err)
+	}
+	if string(got) != doc {
+		t.Errorf("FetchWithAPI() = %s, want %s", got, doc)
+	}
+}
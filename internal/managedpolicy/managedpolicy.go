@@ -0,0 +1,63 @@
+// Package managedpolicy provides an embedded catalog of AWS managed IAM policies.
+//
+// The catalog is a snapshot of the default versions of commonly attached
+// AWS managed policies, so they can be resolved without calling the IAM API.
+// Policies not in the catalog must be fetched from AWS.
+package managedpolicy
+
+import (
+	_ "embed"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+//go:embed data/policies.json
+var catalogData []byte
+
+// awsManagedPrefix is the ARN prefix shared by all AWS managed policies
+const awsManagedPrefix = "arn:aws:iam::aws:policy/"
+
+// catalog maps policy names to their raw policy documents
+var catalog = func() map[string]json.RawMessage {
+	m := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(catalogData, &m); err != nil {
+		panic("managedpolicy: invalid embedded catalog: " + err.Error())
+	}
+	return m
+}()
+
+// IsAWSManaged returns true if the ARN refers to an AWS managed policy
+func IsAWSManaged(arn string) bool {
+	return strings.HasPrefix(arn, awsManagedPrefix)
+}
+
+// NameFromARN extracts the policy name from an AWS managed policy ARN.
+// Paths are stripped (e.g., "service-role/AWSLambdaRole" -> "AWSLambdaRole").
+func NameFromARN(arn string) string {
+	name := strings.TrimPrefix(arn, awsManagedPrefix)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// Get returns the policy document for an AWS managed policy ARN or name
+func Get(arnOrName string) ([]byte, bool) {
+	name := arnOrName
+	if IsAWSManaged(arnOrName) {
+		name = NameFromARN(arnOrName)
+	}
+	doc, ok := catalog[name]
+	return doc, ok
+}
+
+// Names returns the names of all policies in the catalog
+func Names() []string {
+	names := make([]string, 0, len(catalog))
+	for name := range catalog {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
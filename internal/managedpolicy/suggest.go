@@ -0,0 +1,144 @@
+package managedpolicy
+
+import (
+	"sort"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/sar"
+)
+
+// Suggestion is a set of managed policies to attach instead of a generated
+// policy. Resource scoping and conditions are not compared: managed policies
+// grant their actions on every resource.
+type Suggestion struct {
+	// Policies are the names of the managed policies
+	Policies []string
+	// Granted is the number of actions the policies grant, counted with the
+	// Service Authorization Reference for each policy
+	Granted int
+	// Excess is the number of granted actions beyond the required ones
+	Excess int
+	// Uncovered are the required actions none of the policies grant
+	Uncovered []string
+}
+
+// candidate is a catalog policy and the number of actions it grants
+type candidate struct {
+	name    string
+	policy  *policy.IAMPolicy
+	granted int
+}
+
+// Suggest returns the managed policies of the catalog closest to the
+// required actions: the smallest single policy granting all of them, if any,
+// and a small combination of policies found greedily, when it grants fewer
+// actions or no single policy covers the requirements. Suggestions are
+// ordered by the number of actions they grant.
+func Suggest(required []string) []Suggestion {
+	if len(required) == 0 {
+		return nil
+	}
+
+	var candidates []candidate
+	for _, name := range Names() {
+		p, err := policy.ParsePolicy(catalog[name])
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, candidate{name: name, policy: p, granted: grantedCount(p)})
+	}
+
+	var suggestions []Suggestion
+
+	var single *Suggestion
+	for _, c := range candidates {
+		if len(missing(c.policy, required)) > 0 {
+			continue
+		}
+		if single == nil || c.granted < single.Granted {
+			single = &Suggestion{Policies: []string{c.name}, Granted: c.granted}
+		}
+	}
+	if single != nil {
+		single.Excess = max(0, single.Granted-len(required))
+		suggestions = append(suggestions, *single)
+	}
+
+	if combination := combine(candidates, required); len(combination.Policies) > 1 && (single == nil || combination.Granted < single.Granted) {
+		suggestions = append(suggestions, combination)
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Granted < suggestions[j].Granted
+	})
+	return suggestions
+}
+
+// combine picks policies greedily, each time the one granting the most
+// uncovered required actions per granted action, until all are covered or
+// no policy grants any of the rest
+func combine(candidates []candidate, required []string) Suggestion {
+	var s Suggestion
+	remaining := required
+	used := make(map[string]bool)
+
+	for len(remaining) > 0 {
+		var best *candidate
+		var bestRest []string
+		bestScore := 0.0
+		for i, c := range candidates {
+			if used[c.name] {
+				continue
+			}
+			rest := missing(c.policy, remaining)
+			covered := len(remaining) - len(rest)
+			if covered == 0 {
+				continue
+			}
+			score := float64(covered) / float64(max(c.granted, 1))
+			if best == nil || score > bestScore {
+				best, bestRest, bestScore = &candidates[i], rest, score
+			}
+		}
+		if best == nil {
+			break
+		}
+		used[best.name] = true
+		s.Policies = append(s.Policies, best.name)
+		s.Granted += best.granted
+		remaining = bestRest
+	}
+
+	s.Uncovered = remaining
+	s.Excess = max(0, s.Granted-(len(required)-len(remaining)))
+	return s
+}
+
+// missing returns the actions a policy does not grant
+func missing(p *policy.IAMPolicy, actions []string) []string {
+	required := &policy.IAMPolicy{Statement: []policy.Statement{{Effect: "Allow", Action: actions}}}
+	return checker.Check(p, required).Missing
+}
+
+// grantedCount returns the number of actions a policy grants. NotAction
+// statements grant every action they do not exclude.
+func grantedCount(p *policy.IAMPolicy) int {
+	count := 0
+	for _, stmt := range p.Statement {
+		if stmt.Effect != "Allow" {
+			continue
+		}
+		for _, action := range stmt.Action {
+			count += sar.Count(action)
+		}
+		if len(stmt.NotAction) > 0 {
+			excluded := 0
+			for _, action := range stmt.NotAction {
+				excluded += sar.Count(action)
+			}
+			count += max(0, sar.Total()-excluded)
+		}
+	}
+	return count
+}
@@ -0,0 +1,46 @@
+package managedpolicy
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSuggestSingle(t *testing.T) {
+	suggestions := Suggest([]string{"sqs:SendMessage", "sqs:ReceiveMessage"})
+	if len(suggestions) == 0 {
+		t.Fatal("Suggest() returned no suggestions")
+	}
+
+	got := suggestions[0]
+	if !slices.Equal(got.Policies, []string{"AmazonSQSFullAccess"}) {
+		t.Errorf("Policies = %v, want [AmazonSQSFullAccess]", got.Policies)
+	}
+	if got.Excess != got.Granted-2 || len(got.Uncovered) != 0 {
+		t.Errorf("unexpected suggestion %+v", got)
+	}
+}
+
+func TestSuggestCombination(t *testing.T) {
+	suggestions := Suggest([]string{"sqs:SendMessage", "sns:Publish"})
+	if len(suggestions) != 2 {
+		t.Fatalf("Suggest() = %+v, want a combination and a single policy", suggestions)
+	}
+
+	// The combination grants fewer actions than any single policy covering both
+	combination := suggestions[0]
+	if len(combination.Policies) < 2 || !slices.Contains(combination.Policies, "AmazonSQSFullAccess") {
+		t.Errorf("Policies = %v, want a combination with AmazonSQSFullAccess", combination.Policies)
+	}
+	if len(combination.Uncovered) != 0 {
+		t.Errorf("Uncovered = %v, want none", combination.Uncovered)
+	}
+	if single := suggestions[1]; len(single.Policies) != 1 || single.Granted <= combination.Granted {
+		t.Errorf("unexpected single policy suggestion %+v", single)
+	}
+}
+
+func TestSuggestEmpty(t *testing.T) {
+	if got := Suggest(nil); got != nil {
+		t.Errorf("Suggest(nil) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,22 @@
+package mapping
+
+// aliases maps resource types the AWS provider keeps for compatibility to
+// the canonical type of the same resource. Aliases share the mappings and ARN
+// patterns of their canonical type unless they have custom ones.
+var aliases = map[string]string{
+	"aws_alb":                         "aws_lb",
+	"aws_alb_listener":                "aws_lb_listener",
+	"aws_alb_listener_certificate":    "aws_lb_listener_certificate",
+	"aws_alb_listener_rule":           "aws_lb_listener_rule",
+	"aws_alb_target_group":            "aws_lb_target_group",
+	"aws_alb_target_group_attachment": "aws_lb_target_group_attachment",
+}
+
+// Canonical returns the canonical type of a resource type alias (e.g.,
+// aws_lb for aws_alb), or the type itself
+func Canonical(resourceType string) string {
+	if canonical, ok := aliases[resourceType]; ok {
+		return canonical
+	}
+	return resourceType
+}
@@ -1,13 +1,25 @@
 package mapping
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
 // ARNPattern defines how to construct an ARN for a resource type
 type ARNPattern struct {
 	// Pattern is the ARN template with placeholders: {account}, {region}, {attribute_name}
-	Pattern string
+	Pattern string `yaml:"pattern"`
 	// ResourceAttribute is the Terraform attribute name used in the ARN (e.g., "bucket", "function_name")
-	ResourceAttribute string
+	ResourceAttribute string `yaml:"attribute,omitempty"`
 	// ChildPatterns are additional ARN patterns for child resources (e.g., S3 objects)
-	ChildPatterns []string
+	ChildPatterns []string `yaml:"children,omitempty"`
+	// Default replaces the resource attribute placeholder when the attribute
+	// is neither a literal nor a reference, instead of "*" (e.g., "acme-*"
+	// when all buckets share a prefix)
+	Default string `yaml:"default,omitempty"`
 }
 
 // ARNPatterns maps Terraform resource types to their ARN patterns
@@ -48,6 +60,67 @@ var ARNPatterns = map[string]ARNPattern{
 		Pattern:           "arn:aws:iam::{account}:policy/{name}",
 		ResourceAttribute: "name",
 	},
+	"aws_iam_role_policy": {
+		Pattern:           "arn:aws:iam::{account}:role/{role}",
+		ResourceAttribute: "role",
+	},
+	"aws_iam_role_policy_attachment": {
+		Pattern:           "arn:aws:iam::{account}:role/{role}",
+		ResourceAttribute: "role",
+	},
+	"aws_iam_user": {
+		Pattern:           "arn:aws:iam::{account}:user/{name}",
+		ResourceAttribute: "name",
+	},
+	"aws_iam_user_policy": {
+		Pattern:           "arn:aws:iam::{account}:user/{user}",
+		ResourceAttribute: "user",
+	},
+	"aws_iam_user_policy_attachment": {
+		Pattern:           "arn:aws:iam::{account}:user/{user}",
+		ResourceAttribute: "user",
+	},
+	"aws_iam_user_group_membership": {
+		Pattern:           "arn:aws:iam::{account}:user/{user}",
+		ResourceAttribute: "user",
+	},
+	"aws_iam_access_key": {
+		Pattern:           "arn:aws:iam::{account}:user/{user}",
+		ResourceAttribute: "user",
+	},
+	"aws_iam_group": {
+		Pattern:           "arn:aws:iam::{account}:group/{name}",
+		ResourceAttribute: "name",
+	},
+	"aws_iam_group_policy": {
+		Pattern:           "arn:aws:iam::{account}:group/{group}",
+		ResourceAttribute: "group",
+	},
+	"aws_iam_group_policy_attachment": {
+		Pattern:           "arn:aws:iam::{account}:group/{group}",
+		ResourceAttribute: "group",
+	},
+	"aws_iam_group_membership": {
+		Pattern:           "arn:aws:iam::{account}:group/{group}",
+		ResourceAttribute: "group",
+	},
+	"aws_iam_instance_profile": {
+		Pattern:           "arn:aws:iam::{account}:instance-profile/{name}",
+		ResourceAttribute: "name",
+		// PassRole applies to the role added to the profile
+		ChildPatterns: []string{"arn:aws:iam::{account}:role/*"},
+	},
+	"aws_iam_openid_connect_provider": {
+		// The ARN uses the provider URL without its scheme, which the url attribute includes
+		Pattern: "arn:aws:iam::{account}:oidc-provider/*",
+	},
+	"aws_iam_saml_provider": {
+		Pattern:           "arn:aws:iam::{account}:saml-provider/{name}",
+		ResourceAttribute: "name",
+	},
+	"aws_iam_service_linked_role": {
+		Pattern: "arn:aws:iam::{account}:role/aws-service-role/*",
+	},
 
 	// RDS
 	"aws_db_instance": {
@@ -145,6 +218,63 @@ var ARNPatterns = map[string]ARNPattern{
 		ResourceAttribute: "name",
 	},
 
+	// EventBridge
+	"aws_cloudwatch_event_rule": {
+		// Rules on a custom event bus have the bus name in their ARN
+		Pattern:           "arn:aws:events:{region}:{account}:rule/{name}",
+		ResourceAttribute: "name",
+		ChildPatterns:     []string{"arn:aws:events:{region}:{account}:rule/*/{name}"},
+	},
+	"aws_cloudwatch_event_target": {
+		Pattern:           "arn:aws:events:{region}:{account}:rule/{rule}",
+		ResourceAttribute: "rule",
+		ChildPatterns:     []string{"arn:aws:events:{region}:{account}:rule/*/{rule}"},
+	},
+	"aws_cloudwatch_event_bus": {
+		Pattern:           "arn:aws:events:{region}:{account}:event-bus/{name}",
+		ResourceAttribute: "name",
+	},
+	"aws_cloudwatch_event_bus_policy": {
+		Pattern:           "arn:aws:events:{region}:{account}:event-bus/{event_bus_name}",
+		ResourceAttribute: "event_bus_name",
+	},
+	"aws_cloudwatch_event_permission": {
+		Pattern:           "arn:aws:events:{region}:{account}:event-bus/{event_bus_name}",
+		ResourceAttribute: "event_bus_name",
+	},
+	"aws_cloudwatch_event_archive": {
+		Pattern:           "arn:aws:events:{region}:{account}:archive/{name}",
+		ResourceAttribute: "name",
+	},
+	"aws_cloudwatch_event_connection": {
+		// Connection ARNs and their secrets end with a generated ID
+		Pattern:           "arn:aws:events:{region}:{account}:connection/{name}/*",
+		ResourceAttribute: "name",
+		ChildPatterns:     []string{"arn:aws:secretsmanager:{region}:{account}:secret:events!connection/{name}/*"},
+	},
+	"aws_cloudwatch_event_api_destination": {
+		Pattern:           "arn:aws:events:{region}:{account}:api-destination/{name}/*",
+		ResourceAttribute: "name",
+	},
+
+	// EventBridge Scheduler
+	"aws_scheduler_schedule": {
+		// The schedule group is part of the ARN; schedules without one are
+		// in the default group
+		Pattern:           "arn:aws:scheduler:{region}:{account}:schedule/*/{name}",
+		ResourceAttribute: "name",
+	},
+	"aws_scheduler_schedule_group": {
+		Pattern:           "arn:aws:scheduler:{region}:{account}:schedule-group/{name}",
+		ResourceAttribute: "name",
+	},
+
+	// EventBridge Pipes
+	"aws_pipes_pipe": {
+		Pattern:           "arn:aws:pipes:{region}:{account}:pipe/{name}",
+		ResourceAttribute: "name",
+	},
+
 	// Glue
 	"aws_glue_catalog_database": {
 		Pattern:           "arn:aws:glue:{region}:{account}:database/{name}",
@@ -238,14 +368,50 @@ var ARNPatterns = map[string]ARNPattern{
 
 // GetARNPattern returns the ARN pattern for a given resource type
 func GetARNPattern(resourceType string) (ARNPattern, bool) {
+	if p, ok := customARNPatterns[resourceType]; ok {
+		return p, true
+	}
+	resourceType = Canonical(resourceType)
+	if p, ok := customARNPatterns[resourceType]; ok {
+		return p, true
+	}
 	p, ok := ARNPatterns[resourceType]
 	return p, ok
 }
 
 // GetARNAttributes returns the attribute names needed to construct the ARN for a resource type
 func GetARNAttributes(resourceType string) []string {
-	if p, ok := ARNPatterns[resourceType]; ok && p.ResourceAttribute != "" {
+	if p, ok := GetARNPattern(resourceType); ok && p.ResourceAttribute != "" {
 		return []string{p.ResourceAttribute}
 	}
 	return nil
 }
+
+// ARNAttributesFingerprint returns a hash of the attributes used to construct
+// ARNs for all resource types, including custom patterns. It changes whenever
+// the attributes extracted from IaC code would change.
+func ARNAttributesFingerprint() string {
+	types := make(map[string]bool)
+	for t := range ARNPatterns {
+		types[t] = true
+	}
+	for t := range customARNPatterns {
+		types[t] = true
+	}
+	for alias := range aliases {
+		types[alias] = true
+	}
+	names := make([]string, 0, len(types))
+	for t := range types {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, t := range names {
+		if attrs := GetARNAttributes(t); len(attrs) > 0 {
+			fmt.Fprintf(h, "%s=%s\n", t, strings.Join(attrs, ","))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
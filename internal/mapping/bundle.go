@@ -0,0 +1,97 @@
+package mapping
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// bundleData holds the mappings of every resource type in the CloudFormation
+// registry schema bundle, generated by running the generator with -bundle
+//
+//go:embed bundle.json.gz
+var bundleData []byte
+
+// bundleEntry is a resource type's mapping in the schema bundle
+type bundleEntry struct {
+	CfnType string   `json:"cfn"`
+	Create  []string `json:"create,omitempty"`
+	Read    []string `json:"read,omitempty"`
+	Update  []string `json:"update,omitempty"`
+	Delete  []string `json:"delete,omitempty"`
+}
+
+var (
+	bundle map[string]bundleEntry
+	// bundleTypes maps the CloudFormation types of the bundle to their
+	// Terraform types
+	bundleTypes map[string]string
+	bundleOnce  sync.Once
+)
+
+// bundled returns the embedded schema bundle mappings, decoding them on first use
+func bundled() map[string]bundleEntry {
+	bundleOnce.Do(func() {
+		entries, err := decodeBundle(bundleData)
+		if err != nil {
+			panic(fmt.Sprintf("mapping: invalid embedded schema bundle: %v", err))
+		}
+		bundle = entries
+		bundleTypes = make(map[string]string, len(entries))
+		for t, e := range entries {
+			bundleTypes[e.CfnType] = t
+		}
+	})
+	return bundle
+}
+
+// decodeBundle decodes gzipped bundle JSON
+func decodeBundle(data []byte) (map[string]bundleEntry, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	entries := make(map[string]bundleEntry)
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// lookupBundle returns the schema bundle mapping of a resource type and the
+// CloudFormation type it was generated from
+func lookupBundle(resourceType string) (ResourceMapping, string, bool) {
+	e, ok := bundled()[resourceType]
+	if !ok {
+		return ResourceMapping{}, "", false
+	}
+	return ResourceMapping{
+		Create: e.Create,
+		Read:   e.Read,
+		Update: e.Update,
+		Delete: e.Delete,
+	}, e.CfnType, true
+}
+
+// BundledSchema returns the schema bundle mapping generated from a
+// CloudFormation type, for Terraform types named unlike their CloudFormation
+// type (e.g., aws_instance for AWS::EC2::Instance)
+func BundledSchema(cfnType string) (ResourceMapping, bool) {
+	bundled()
+	t, ok := bundleTypes[cfnType]
+	if !ok {
+		return ResourceMapping{}, false
+	}
+	m, _, _ := lookupBundle(t)
+	return m, true
+}
+
+// BundledTypes returns the number of resource types in the embedded schema bundle
+func BundledTypes() int {
+	return len(bundled())
+}
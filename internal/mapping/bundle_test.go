@@ -0,0 +1,51 @@
+package mapping
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeBundle(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`{"aws_glue_job":{"cfn":"AWS::Glue::Job","create":["glue:CreateJob"],"delete":["glue:DeleteJob"]}}`))
+	zw.Close()
+
+	entries, err := decodeBundle(buf.Bytes())
+	if err != nil {
+		t.Fatalf("decodeBundle() error = %v", err)
+	}
+
+	want := map[string]bundleEntry{
+		"aws_glue_job": {CfnType: "AWS::Glue::Job", Create: []string{"glue:CreateJob"}, Delete: []string{"glue:DeleteJob"}},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("decodeBundle() = %+v, want %+v", entries, want)
+	}
+
+	if _, err := decodeBundle([]byte("{}")); err == nil {
+		t.Error("decodeBundle() expected error for data that is not gzipped")
+	}
+}
+
+func TestEmbeddedBundle(t *testing.T) {
+	// Decoding the embedded bundle panics if it is invalid
+	if n := BundledTypes(); n < 1000 {
+		t.Errorf("BundledTypes() = %d, want the whole registry", n)
+	}
+
+	provenance, ok := GetProvenance("aws_glue_job")
+	if !ok || provenance != (Provenance{Kind: ProvenanceSchema, CfnType: "AWS::Glue::Job"}) {
+		t.Errorf("aws_glue_job provenance = %+v, want the AWS::Glue::Job schema", provenance)
+	}
+	if !IsBuiltin("aws_glue_job") {
+		t.Error("IsBuiltin(aws_glue_job) = false for a bundled type")
+	}
+
+	// Hand-written mappings take precedence over the bundle
+	if _, provenance, _ := lookup("aws_sqs_queue"); provenance.Kind != ProvenanceFallback {
+		t.Errorf("aws_sqs_queue provenance = %q, want %q", provenance.Kind, ProvenanceFallback)
+	}
+}
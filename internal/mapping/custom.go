@@ -0,0 +1,202 @@
+package mapping
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultCustomFile is the local mappings file loaded when present
+const DefaultCustomFile = ".least-mappings.yaml"
+
+// CustomMappings is a mappings file that adds or overrides built-in mappings
+// and ARN patterns:
+//
+//	mappings:
+//	  aws_glue_job:
+//	    create: [glue:CreateJob, iam:PassRole]
+//	    read: [glue:GetJob]
+//	    update: [glue:UpdateJob]
+//	    delete: [glue:DeleteJob]
+//	    arn:
+//	      pattern: arn:aws:glue:{region}:{account}:job/{name}
+//	      attribute: name
+//	      default: etl-*
+//
+// A file published as a mapping pack names itself and its version:
+//
+//	pack:
+//	  name: glue
+//	  version: 1.2.0
+type CustomMappings struct {
+	Pack     *PackInfo                `yaml:"pack,omitempty"`
+	Mappings map[string]CustomMapping `yaml:"mappings"`
+}
+
+// PackInfo names a mappings file published as a mapping pack
+type PackInfo struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version,omitempty"`
+}
+
+// CustomMapping is the mapping of a single resource type. Entries without
+// actions only override the ARN pattern.
+type CustomMapping struct {
+	ResourceMapping `yaml:",inline"`
+	ARN             *ARNPattern `yaml:"arn,omitempty"`
+}
+
+// HasActions checks if the mapping defines any actions
+func (m CustomMapping) HasActions() bool {
+	return len(m.Create)+len(m.Read)+len(m.Update)+len(m.Delete) > 0
+}
+
+// customMappings and customARNPatterns take precedence over all other mappings
+var (
+	customMappings    = map[string]ResourceMapping{}
+	customARNPatterns = map[string]ARNPattern{}
+)
+
+var (
+	resourceTypePattern = regexp.MustCompile(`^[a-z][a-z0-9]*_[a-z0-9_]+$`)
+	actionPattern       = regexp.MustCompile(`^[a-z0-9-]+:[A-Za-z0-9*?]+$`)
+	placeholderPattern  = regexp.MustCompile(`\{([^}]*)\}`)
+)
+
+// LoadCustomFile reads a mappings file
+func LoadCustomFile(path string) (*CustomMappings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading mappings: %w", err)
+	}
+	return ParseCustom(data, path)
+}
+
+// ParseCustom parses the content of a mappings file named name
+func ParseCustom(data []byte, name string) (*CustomMappings, error) {
+	var c CustomMappings
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing mappings %s: %w", name, err)
+	}
+	if c.Mappings == nil {
+		c.Mappings = make(map[string]CustomMapping)
+	}
+	return &c, nil
+}
+
+// Save writes the mappings file
+func (c *CustomMappings) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("encoding mappings: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Merge adds the entries of other, replacing entries for the same resource type
+func (c *CustomMappings) Merge(other *CustomMappings) {
+	if c.Mappings == nil {
+		c.Mappings = make(map[string]CustomMapping)
+	}
+	for t, m := range other.Mappings {
+		c.Mappings[t] = m
+	}
+}
+
+// Validate checks resource type names, action syntax and ARN patterns and
+// returns all problems found
+func (c *CustomMappings) Validate() []error {
+	types := make([]string, 0, len(c.Mappings))
+	for t := range c.Mappings {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	var errs []error
+	for _, t := range types {
+		if !resourceTypePattern.MatchString(t) {
+			errs = append(errs, fmt.Errorf("%s: invalid resource type name", t))
+		}
+
+		m := c.Mappings[t]
+		for _, op := range []struct {
+			name    string
+			actions []string
+		}{
+			{"create", m.Create},
+			{"read", m.Read},
+			{"update", m.Update},
+			{"delete", m.Delete},
+		} {
+			for _, action := range op.actions {
+				if !actionPattern.MatchString(action) {
+					errs = append(errs, fmt.Errorf("%s: %s: invalid action %q (expected service:Action)", t, op.name, action))
+				}
+			}
+		}
+		if !m.HasActions() && m.ARN == nil {
+			errs = append(errs, fmt.Errorf("%s: no actions", t))
+		}
+		if m.ARN != nil {
+			errs = append(errs, validateARNPattern(t, *m.ARN)...)
+		}
+	}
+	return errs
+}
+
+// validateARNPattern checks that ARN templates are ARNs and only use the
+// {account} and {region} placeholders besides the resource attribute
+func validateARNPattern(resourceType string, p ARNPattern) []error {
+	var errs []error
+	if p.Pattern == "" {
+		errs = append(errs, fmt.Errorf("%s: arn: missing pattern", resourceType))
+	}
+	if p.Default != "" && p.ResourceAttribute == "" {
+		errs = append(errs, fmt.Errorf("%s: arn: default requires attribute", resourceType))
+	}
+	for _, pattern := range append([]string{p.Pattern}, p.ChildPatterns...) {
+		if pattern == "" {
+			continue
+		}
+		if !strings.HasPrefix(pattern, "arn:") {
+			errs = append(errs, fmt.Errorf("%s: arn: %q is not an ARN", resourceType, pattern))
+		}
+		for _, match := range placeholderPattern.FindAllStringSubmatch(pattern, -1) {
+			switch match[1] {
+			case "account", "region", p.ResourceAttribute:
+			default:
+				errs = append(errs, fmt.Errorf("%s: arn: unknown placeholder {%s} in %q", resourceType, match[1], pattern))
+			}
+		}
+	}
+	return errs
+}
+
+// SetCustomMappings sets mappings and ARN patterns that add to or override
+// the built-in ones
+func SetCustomMappings(c *CustomMappings) {
+	customMappings = make(map[string]ResourceMapping)
+	customARNPatterns = make(map[string]ARNPattern)
+	for t, m := range c.Mappings {
+		if m.HasActions() {
+			customMappings[t] = m.ResourceMapping
+		}
+		if m.ARN != nil {
+			customARNPatterns[t] = *m.ARN
+		}
+	}
+}
+
+// IsBuiltin checks if a resource type has a built-in mapping
+func IsBuiltin(resourceType string) bool {
+	resourceType = Canonical(resourceType)
+	if _, ok := fallbackMappings[resourceType]; ok {
+		return true
+	}
+	_, ok := bundled()[resourceType]
+	return ok
+}
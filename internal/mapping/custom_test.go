@@ -0,0 +1,111 @@
+package mapping
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCustomMappings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultCustomFile)
+	data := `mappings:
+  aws_glue_job:
+    create: [glue:CreateJob, iam:PassRole]
+    delete: [glue:DeleteJob]
+    arn:
+      pattern: arn:aws:glue:{region}:{account}:job/{name}
+      attribute: name
+  aws_s3_bucket:
+    create: [s3:CreateBucket]
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := LoadCustomFile(path)
+	if err != nil {
+		t.Fatalf("LoadCustomFile() error = %v", err)
+	}
+	if errs := c.Validate(); len(errs) != 0 {
+		t.Fatalf("Validate() = %v", errs)
+	}
+
+	// An overlay replacing only the ARN pattern of a built-in type
+	c.Merge(&CustomMappings{Mappings: map[string]CustomMapping{
+		"aws_sqs_queue": {ARN: &ARNPattern{Pattern: "arn:aws:sqs:{region}:{account}:team-{name}", ResourceAttribute: "name"}},
+	}})
+
+	SetCustomMappings(c)
+	defer SetCustomMappings(&CustomMappings{})
+
+	tests := []struct {
+		resourceType string
+		wantActions  []string
+		wantSource   string
+		wantARN      string
+	}{
+		{"aws_glue_job", []string{"glue:CreateJob", "iam:PassRole", "glue:DeleteJob"}, "custom", "arn:aws:glue:{region}:{account}:job/{name}"},
+		{"aws_s3_bucket", []string{"s3:CreateBucket"}, "custom", "arn:aws:s3:::{bucket}"},
+		{"aws_sqs_queue", []string{"sqs:CreateQueue", "sqs:TagQueue", "sqs:GetQueueAttributes", "sqs:ListQueueTags", "sqs:SetQueueAttributes", "sqs:UntagQueue", "sqs:DeleteQueue"}, "fallback", "arn:aws:sqs:{region}:{account}:team-{name}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resourceType, func(t *testing.T) {
+			if got := GetActionsForResource(tt.resourceType); !reflect.DeepEqual(got, tt.wantActions) {
+				t.Errorf("GetActionsForResource() = %v, want %v", got, tt.wantActions)
+			}
+			if got, _ := GetSource(tt.resourceType); got != tt.wantSource {
+				t.Errorf("GetSource() = %q, want %q", got, tt.wantSource)
+			}
+			if got, _ := GetARNPattern(tt.resourceType); got.Pattern != tt.wantARN {
+				t.Errorf("GetARNPattern() = %q, want %q", got.Pattern, tt.wantARN)
+			}
+		})
+	}
+
+	if got := GetARNAttributes("aws_glue_job"); !reflect.DeepEqual(got, []string{"name"}) {
+		t.Errorf("GetARNAttributes() = %v, want [name]", got)
+	}
+
+	c.Mappings["aws_glue_job"] = CustomMapping{ResourceMapping: ResourceMapping{Read: []string{"glue:GetJob"}}}
+	if err := c.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	saved, err := LoadCustomFile(path)
+	if err != nil {
+		t.Fatalf("LoadCustomFile() error = %v", err)
+	}
+	if !reflect.DeepEqual(saved.Mappings, c.Mappings) {
+		t.Errorf("saved mappings = %v, want %v", saved.Mappings, c.Mappings)
+	}
+}
+
+func TestCustomMappingsValidate(t *testing.T) {
+	c := &CustomMappings{Mappings: map[string]CustomMapping{
+		"AwsGlueJob":        {ResourceMapping: ResourceMapping{Create: []string{"glue:CreateJob"}}},
+		"aws_glue_crawler":  {ResourceMapping: ResourceMapping{Create: []string{"CreateCrawler", "glue:Get*"}}},
+		"aws_glue_registry": {},
+		"aws_glue_schema":   {ARN: &ARNPattern{Pattern: "arn:aws:glue:{region}:{account}:schema/{registry}/{name}", ResourceAttribute: "name"}},
+		"aws_glue_trigger":  {ARN: &ARNPattern{Pattern: "glue/{name}", ResourceAttribute: "name"}},
+		"aws_glue_workflow": {ARN: &ARNPattern{Pattern: "arn:aws:glue:{region}:{account}:workflow/etl-*", Default: "etl-*"}},
+	}}
+
+	errs := c.Validate()
+	want := []string{
+		"AwsGlueJob: invalid resource type name",
+		`aws_glue_crawler: create: invalid action "CreateCrawler" (expected service:Action)`,
+		"aws_glue_registry: no actions",
+		`aws_glue_schema: arn: unknown placeholder {registry} in "arn:aws:glue:{region}:{account}:schema/{registry}/{name}"`,
+		`aws_glue_trigger: arn: "glue/{name}" is not an ARN`,
+		"aws_glue_workflow: arn: default requires attribute",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Validate() = %v, want %v", errs, want)
+	}
+	for i, err := range errs {
+		if err.Error() != want[i] {
+			t.Errorf("Validate()[%d] = %q, want %q", i, err, want[i])
+		}
+	}
+}
@@ -2,10 +2,20 @@
 
 // This program generates mapping code from CloudFormation resource schemas.
 // Run with: go generate ./internal/mapping
+//
+// With -bundle, it instead generates the embedded schema bundle
+// (internal/mapping/bundle.json.gz) from the CloudFormation registry schema
+// dump (CloudFormationSchema.zip, or a directory of extracted schemas):
+//
+//	go run ./internal/mapping/gen/main.go -bundle CloudFormationSchema.zip
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,6 +23,9 @@ import (
 	"strings"
 	"text/template"
 	"time"
+
+	"github.com/mizzy/least/internal/sar"
+	"github.com/mizzy/least/internal/schema"
 )
 
 type Schema struct {
@@ -76,6 +89,14 @@ var generatedMappings = map[string]ResourceMapping{
 {{- end }}
 }
 
+// generatedSources maps Terraform types to the CloudFormation types their
+// generated mappings were extracted from.
+var generatedSources = map[string]string{
+{{- range .Mappings }}
+	"{{ .TerraformType }}": "{{ .CfnType }}",
+{{- end }}
+}
+
 func init() {
 	// Merge generated mappings into fallback (generated takes precedence)
 	for k, v := range generatedMappings {
@@ -127,6 +148,13 @@ var cfnToTerraform = map[string]string{
 	"AWS::ElasticLoadBalancingV2::Listener":     "aws_lb_listener",
 	"AWS::StepFunctions::StateMachine":          "aws_sfn_state_machine",
 	"AWS::Events::Rule":                         "aws_cloudwatch_event_rule",
+	"AWS::Events::EventBus":                     "aws_cloudwatch_event_bus",
+	"AWS::Events::Archive":                      "aws_cloudwatch_event_archive",
+	"AWS::Events::Connection":                   "aws_cloudwatch_event_connection",
+	"AWS::Events::ApiDestination":               "aws_cloudwatch_event_api_destination",
+	"AWS::Scheduler::Schedule":                  "aws_scheduler_schedule",
+	"AWS::Scheduler::ScheduleGroup":             "aws_scheduler_schedule_group",
+	"AWS::Pipes::Pipe":                          "aws_pipes_pipe",
 	"AWS::Cognito::UserPool":                    "aws_cognito_user_pool",
 	"AWS::Kinesis::Stream":                      "aws_kinesis_stream",
 	"AWS::ECR::Repository":                      "aws_ecr_repository",
@@ -157,8 +185,20 @@ func findProjectRoot() string {
 }
 
 func main() {
+	bundlePath := flag.String("bundle", "", "CloudFormation registry schema dump (zip file or directory) to generate the schema bundle from")
+	flag.Parse()
+
 	// Find project root by looking for go.mod
 	root := findProjectRoot()
+
+	if *bundlePath != "" {
+		output := filepath.Join(root, "internal/mapping/bundle.json.gz")
+		if err := generateBundle(*bundlePath, output); err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating schema bundle: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 	schemaDir := filepath.Join(root, "internal/schema/data")
 	outputFile := filepath.Join(root, "internal/mapping/generated.go")
 
@@ -201,16 +241,16 @@ func main() {
 		}
 
 		if schema.Handlers.Create != nil {
-			mapping.Create = schema.Handlers.Create.Permissions
+			mapping.Create = validActions(schema.TypeName, schema.Handlers.Create.Permissions)
 		}
 		if schema.Handlers.Read != nil {
-			mapping.Read = schema.Handlers.Read.Permissions
+			mapping.Read = validActions(schema.TypeName, schema.Handlers.Read.Permissions)
 		}
 		if schema.Handlers.Update != nil {
-			mapping.Update = schema.Handlers.Update.Permissions
+			mapping.Update = validActions(schema.TypeName, schema.Handlers.Update.Permissions)
 		}
 		if schema.Handlers.Delete != nil {
-			mapping.Delete = schema.Handlers.Delete.Permissions
+			mapping.Delete = validActions(schema.TypeName, schema.Handlers.Delete.Permissions)
 		}
 
 		mappings = append(mappings, mapping)
@@ -255,3 +295,129 @@ func main() {
 
 	fmt.Printf("Generated %s with %d mappings\n", outputFile, len(mappings))
 }
+
+// validActions drops handler permissions that are not actions in the Service
+// Authorization Reference. Some schemas list API operations that are
+// authorized by another action (e.g., s3:DeleteBucketCORS), or services that
+// are not IAM service prefixes (e.g., efs instead of elasticfilesystem).
+func validActions(cfnType string, permissions []string) []string {
+	valid := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		if service := sar.Unverified([]string{p}); len(service) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %s: dropping %s: unknown service %s\n", cfnType, p, service[0])
+			continue
+		}
+		if problem, ok := sar.Check(p); !ok {
+			fmt.Fprintf(os.Stderr, "Warning: %s: dropping %s\n", cfnType, problem)
+			continue
+		}
+		valid = append(valid, p)
+	}
+	return valid
+}
+
+// bundleEntry is a resource type's mapping in the schema bundle
+type bundleEntry struct {
+	CfnType string   `json:"cfn"`
+	Create  []string `json:"create,omitempty"`
+	Read    []string `json:"read,omitempty"`
+	Update  []string `json:"update,omitempty"`
+	Delete  []string `json:"delete,omitempty"`
+}
+
+// generateBundle writes the gzipped mappings of every AWS resource type with
+// handler permissions in the schema dump
+func generateBundle(path, output string) error {
+	schemas, err := readSchemaDump(path)
+	if err != nil {
+		return err
+	}
+
+	entries := make(map[string]bundleEntry)
+	for _, data := range schemas {
+		var s Schema
+		if err := json.Unmarshal(data, &s); err != nil {
+			continue
+		}
+		if !strings.HasPrefix(s.TypeName, "AWS::") {
+			continue
+		}
+		tfType, ok := cfnToTerraform[s.TypeName]
+		if !ok {
+			tfType = schema.CfnToTerraformType(s.TypeName)
+		}
+		if tfType == "" {
+			continue
+		}
+
+		entry := bundleEntry{CfnType: s.TypeName}
+		if s.Handlers.Create != nil {
+			entry.Create = validActions(s.TypeName, s.Handlers.Create.Permissions)
+		}
+		if s.Handlers.Read != nil {
+			entry.Read = validActions(s.TypeName, s.Handlers.Read.Permissions)
+		}
+		if s.Handlers.Update != nil {
+			entry.Update = validActions(s.TypeName, s.Handlers.Update.Permissions)
+		}
+		if s.Handlers.Delete != nil {
+			entry.Delete = validActions(s.TypeName, s.Handlers.Delete.Permissions)
+		}
+		if len(entry.Create)+len(entry.Read)+len(entry.Update)+len(entry.Delete) == 0 {
+			continue
+		}
+		entries[tfType] = entry
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if _, err := zw.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated %s with %d resource types from %d schemas\n", output, len(entries), len(schemas))
+	return nil
+}
+
+// readSchemaDump returns the contents of the JSON schemas in a zip file or directory
+func readSchemaDump(path string) ([][]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var schemas [][]byte
+	if info.IsDir() {
+		err := filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(p, ".json") {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			schemas = append(schemas, data)
+			return nil
+		})
+		return schemas, err
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	return schema.ReadBundle(&zr.Reader)
+}
@@ -142,7 +142,6 @@ var generatedMappings = map[string]ResourceMapping{
 			"s3:PutLifecycleConfiguration",
 			"s3:PutMetricsConfiguration",
 			"s3:PutBucketNotification",
-			"s3:PutBucketReplication",
 			"s3:PutBucketWebsite",
 			"s3:PutAccelerateConfiguration",
 			"s3:PutBucketPublicAccessBlock",
@@ -178,27 +177,17 @@ var generatedMappings = map[string]ResourceMapping{
 		},
 		Update: []string{
 			"s3:PutBucketTagging",
-			"s3:DeleteBucketTagging",
 			"s3:PutAnalyticsConfiguration",
-			"s3:DeleteAnalyticsConfiguration",
 			"s3:PutEncryptionConfiguration",
-			"s3:DeleteEncryptionConfiguration",
 			"s3:PutBucketCORS",
-			"s3:DeleteBucketCORS",
 			"s3:PutInventoryConfiguration",
-			"s3:DeleteInventoryConfiguration",
 			"s3:PutLifecycleConfiguration",
-			"s3:DeleteLifecycleConfiguration",
 			"s3:PutMetricsConfiguration",
-			"s3:DeleteMetricsConfiguration",
 			"s3:PutBucketNotification",
-			"s3:PutBucketReplication",
-			"s3:DeleteBucketReplication",
 			"s3:PutBucketWebsite",
 			"s3:DeleteBucketWebsite",
 			"s3:PutAccelerateConfiguration",
 			"s3:PutBucketPublicAccessBlock",
-			"s3:DeleteBucketPublicAccessBlock",
 			"s3:PutReplicationConfiguration",
 			"s3:PutBucketVersioning",
 			"s3:PutBucketOwnershipControls",
@@ -211,6 +200,15 @@ var generatedMappings = map[string]ResourceMapping{
 	},
 }
 
+// generatedSources maps Terraform types to the CloudFormation types their
+// generated mappings were extracted from.
+var generatedSources = map[string]string{
+	"aws_iam_role":        "AWS::IAM::Role",
+	"aws_instance":        "AWS::EC2::Instance",
+	"aws_lambda_function": "AWS::Lambda::Function",
+	"aws_s3_bucket":       "AWS::S3::Bucket",
+}
+
 func init() {
 	// Merge generated mappings into fallback (generated takes precedence)
 	for k, v := range generatedMappings {
@@ -4,23 +4,118 @@
 //
 // Mappings are derived from CloudFormation Resource Schemas, which contain
 // the authoritative list of IAM permissions for each AWS resource type.
+// Every type in the CloudFormation registry schema bundle is embedded
+// (bundle.json.gz); the hand-written and generated mappings override it.
 //
 // To update mappings:
 //  1. Run scripts/fetch-schemas.sh to download latest schemas
 //  2. Run go generate ./internal/mapping to regenerate mapping code
+//
+// To update the schema bundle:
+//  1. Run scripts/fetch-schema-bundle.sh to download the registry schema dump
+//  2. Run go run ./internal/mapping/gen/main.go -bundle CloudFormationSchema.zip
 package mapping
 
 // ResourceMapping defines IAM actions required for a Terraform resource type
 type ResourceMapping struct {
-	Create []string
-	Read   []string
-	Update []string
-	Delete []string
+	Create []string `yaml:"create,omitempty"`
+	Read   []string `yaml:"read,omitempty"`
+	Update []string `yaml:"update,omitempty"`
+	Delete []string `yaml:"delete,omitempty"`
+}
+
+// Provenance kinds, from most to least trustworthy
+const (
+	// ProvenanceCustom is a mapping from a local mappings file
+	ProvenanceCustom = "custom"
+	// ProvenanceSchema is derived from the handler permissions of a
+	// CloudFormation resource schema
+	ProvenanceSchema = "cfn-schema"
+	// ProvenanceFallback is a hand-written built-in mapping
+	ProvenanceFallback = "fallback"
+	// ProvenancePolicySentry is derived from the actions a policy_sentry IAM
+	// definition dataset lists for the resource
+	ProvenancePolicySentry = "policy-sentry"
+	// ProvenanceHeuristic is derived from the schema of a CloudFormation type
+	// guessed from the Terraform type name
+	ProvenanceHeuristic = "heuristic"
+)
+
+// Provenance describes where the mapping of a resource type comes from
+type Provenance struct {
+	Kind string `json:"kind"`
+	// CfnType is the CloudFormation type of schema-derived mappings
+	CfnType string `json:"cfn_type,omitempty"`
+}
+
+// source returns the provenance in the form reported by GetSource
+func (p Provenance) source() string {
+	if p.CfnType != "" {
+		return p.CfnType
+	}
+	return p.Kind
+}
+
+// Resolver resolves the mapping of a resource type without a built-in
+// mapping, such as from cached CloudFormation schemas or an IAM action
+// dataset. It returns the mapping and its provenance.
+type Resolver func(resourceType string) (ResourceMapping, Provenance, bool)
+
+var resolvers []Resolver
+
+// SetResolvers sets the resolvers consulted, in order, for resource types
+// without a built-in mapping
+func SetResolvers(r ...Resolver) {
+	resolvers = r
+}
+
+// lookup returns the mapping for a resource type and where it comes from
+func lookup(resourceType string) (ResourceMapping, Provenance, bool) {
+	if mapping, ok := customMappings[resourceType]; ok {
+		return mapping, Provenance{Kind: ProvenanceCustom}, true
+	}
+	resourceType = Canonical(resourceType)
+	if mapping, ok := customMappings[resourceType]; ok {
+		return mapping, Provenance{Kind: ProvenanceCustom}, true
+	}
+	if mapping, ok := versioned(resourceType); ok {
+		return mapping, Provenance{Kind: ProvenanceFallback}, true
+	}
+	if mapping, ok := fallbackMappings[resourceType]; ok {
+		if cfnType, ok := generatedSources[resourceType]; ok {
+			return mapping, Provenance{Kind: ProvenanceSchema, CfnType: cfnType}, true
+		}
+		return mapping, Provenance{Kind: ProvenanceFallback}, true
+	}
+	if mapping, cfnType, ok := lookupBundle(resourceType); ok {
+		return mapping, Provenance{Kind: ProvenanceSchema, CfnType: cfnType}, true
+	}
+	for _, resolve := range resolvers {
+		if mapping, provenance, ok := resolve(resourceType); ok {
+			return mapping, provenance, true
+		}
+	}
+	return ResourceMapping{}, Provenance{}, false
+}
+
+// GetMapping returns the mapping for a resource type and where it comes from:
+// "custom" for a local mappings file, the CloudFormation type for schema-derived
+// mappings, or "fallback" for hand-written ones
+func GetMapping(resourceType string) (ResourceMapping, string, bool) {
+	mapping, provenance, ok := lookup(resourceType)
+	return mapping, provenance.source(), ok
+}
+
+// GetProvenance returns where the mapping for a resource type comes from.
+// Returns false if the type has no mapping.
+func GetProvenance(resourceType string) (Provenance, bool) {
+	_, provenance, ok := lookup(resourceType)
+	return provenance, ok
 }
 
 // GetActionsForResource returns all IAM actions needed for a resource type
 func GetActionsForResource(resourceType string) []string {
-	mapping, ok := fallbackMappings[resourceType]
+	mapping, _, ok := lookup(resourceType)
 	if !ok {
 		return nil
 	}
@@ -40,17 +135,66 @@ func GetActionsForResource(resourceType string) []string {
 	return actions
 }
 
-// GetSupportedResourceTypes returns list of supported Terraform resource types
+// GetSource returns where the mapping for a resource type comes from: "custom"
+// for a local mappings file, the CloudFormation type whose schema it was
+// generated from, or "fallback" for hand-written mappings. Returns false if
+// the type has no mapping.
+func GetSource(resourceType string) (string, bool) {
+	_, provenance, ok := lookup(resourceType)
+	return provenance.source(), ok
+}
+
+// GetOperations returns the operations (create, read, update, delete) of a
+// resource type's mapping that include the action
+func GetOperations(resourceType, action string) []string {
+	mapping, _, ok := lookup(resourceType)
+	if !ok {
+		return nil
+	}
+
+	var operations []string
+	for _, op := range []struct {
+		name    string
+		actions []string
+	}{
+		{"create", mapping.Create},
+		{"read", mapping.Read},
+		{"update", mapping.Update},
+		{"delete", mapping.Delete},
+	} {
+		for _, a := range op.actions {
+			if a == action {
+				operations = append(operations, op.name)
+				break
+			}
+		}
+	}
+	return operations
+}
+
+// GetSupportedResourceTypes returns list of supported Terraform resource
+// types, from both the built-in mappings and the schema bundle, including
+// aliases of supported types
 func GetSupportedResourceTypes() []string {
-	types := make([]string, 0, len(fallbackMappings))
+	types := make([]string, 0, len(fallbackMappings)+len(bundled()))
 	for t := range fallbackMappings {
 		types = append(types, t)
 	}
+	for t := range bundled() {
+		if _, ok := fallbackMappings[t]; !ok {
+			types = append(types, t)
+		}
+	}
+	for alias, canonical := range aliases {
+		if IsBuiltin(canonical) {
+			types = append(types, alias)
+		}
+	}
 	return types
 }
 
-// fallbackMappings contains hardcoded mappings for when schema is unavailable
-// These serve as a fallback and for common resource types
+// fallbackMappings contains hardcoded mappings for common resource types.
+// They override the schema bundle where its permissions are incomplete.
 var fallbackMappings = map[string]ResourceMapping{
 	"aws_s3_bucket": {
 		Create: []string{
@@ -123,6 +267,121 @@ var fallbackMappings = map[string]ResourceMapping{
 		Update: []string{"iam:CreatePolicyVersion", "iam:DeletePolicyVersion", "iam:TagPolicy", "iam:UntagPolicy"},
 		Delete: []string{"iam:DeletePolicy"},
 	},
+	"aws_iam_role_policy": {
+		Create: []string{"iam:PutRolePolicy"},
+		Read:   []string{"iam:GetRolePolicy"},
+		Update: []string{"iam:PutRolePolicy"},
+		Delete: []string{"iam:DeleteRolePolicy"},
+	},
+	"aws_iam_role_policy_attachment": {
+		Create: []string{"iam:AttachRolePolicy"},
+		Read:   []string{"iam:ListAttachedRolePolicies"},
+		Update: []string{},
+		Delete: []string{"iam:DetachRolePolicy"},
+	},
+	"aws_iam_user": {
+		Create: []string{"iam:CreateUser", "iam:TagUser"},
+		Read:   []string{"iam:GetUser", "iam:ListUserTags"},
+		Update: []string{"iam:UpdateUser", "iam:TagUser", "iam:UntagUser"},
+		Delete: []string{"iam:DeleteUser"},
+	},
+	"aws_iam_user_policy": {
+		Create: []string{"iam:PutUserPolicy"},
+		Read:   []string{"iam:GetUserPolicy"},
+		Update: []string{"iam:PutUserPolicy"},
+		Delete: []string{"iam:DeleteUserPolicy"},
+	},
+	"aws_iam_user_policy_attachment": {
+		Create: []string{"iam:AttachUserPolicy"},
+		Read:   []string{"iam:ListAttachedUserPolicies"},
+		Update: []string{},
+		Delete: []string{"iam:DetachUserPolicy"},
+	},
+	"aws_iam_user_group_membership": {
+		Create: []string{"iam:AddUserToGroup"},
+		Read:   []string{"iam:ListGroupsForUser"},
+		Update: []string{"iam:AddUserToGroup", "iam:RemoveUserFromGroup"},
+		Delete: []string{"iam:RemoveUserFromGroup"},
+	},
+	"aws_iam_access_key": {
+		Create: []string{"iam:CreateAccessKey"},
+		Read:   []string{"iam:ListAccessKeys"},
+		Update: []string{"iam:UpdateAccessKey"},
+		Delete: []string{"iam:DeleteAccessKey"},
+	},
+	"aws_iam_group": {
+		Create: []string{"iam:CreateGroup"},
+		Read:   []string{"iam:GetGroup"},
+		Update: []string{"iam:UpdateGroup"},
+		Delete: []string{"iam:DeleteGroup"},
+	},
+	"aws_iam_group_policy": {
+		Create: []string{"iam:PutGroupPolicy"},
+		Read:   []string{"iam:GetGroupPolicy"},
+		Update: []string{"iam:PutGroupPolicy"},
+		Delete: []string{"iam:DeleteGroupPolicy"},
+	},
+	"aws_iam_group_policy_attachment": {
+		Create: []string{"iam:AttachGroupPolicy"},
+		Read:   []string{"iam:ListAttachedGroupPolicies"},
+		Update: []string{},
+		Delete: []string{"iam:DetachGroupPolicy"},
+	},
+	"aws_iam_group_membership": {
+		Create: []string{"iam:AddUserToGroup"},
+		Read:   []string{"iam:GetGroup"},
+		Update: []string{"iam:AddUserToGroup", "iam:RemoveUserFromGroup"},
+		Delete: []string{"iam:RemoveUserFromGroup"},
+	},
+	"aws_iam_policy_attachment": {
+		Create: []string{"iam:AttachRolePolicy", "iam:AttachUserPolicy", "iam:AttachGroupPolicy"},
+		Read:   []string{"iam:ListEntitiesForPolicy"},
+		Update: []string{
+			"iam:AttachRolePolicy",
+			"iam:AttachUserPolicy",
+			"iam:AttachGroupPolicy",
+			"iam:DetachRolePolicy",
+			"iam:DetachUserPolicy",
+			"iam:DetachGroupPolicy",
+		},
+		Delete: []string{"iam:DetachRolePolicy", "iam:DetachUserPolicy", "iam:DetachGroupPolicy"},
+	},
+	"aws_iam_instance_profile": {
+		Create: []string{"iam:CreateInstanceProfile", "iam:TagInstanceProfile", "iam:AddRoleToInstanceProfile", "iam:PassRole"},
+		Read:   []string{"iam:GetInstanceProfile", "iam:ListInstanceProfileTags"},
+		Update: []string{
+			"iam:AddRoleToInstanceProfile",
+			"iam:RemoveRoleFromInstanceProfile",
+			"iam:PassRole",
+			"iam:TagInstanceProfile",
+			"iam:UntagInstanceProfile",
+		},
+		Delete: []string{"iam:RemoveRoleFromInstanceProfile", "iam:DeleteInstanceProfile"},
+	},
+	"aws_iam_openid_connect_provider": {
+		Create: []string{"iam:CreateOpenIDConnectProvider", "iam:TagOpenIDConnectProvider"},
+		Read:   []string{"iam:GetOpenIDConnectProvider"},
+		Update: []string{
+			"iam:UpdateOpenIDConnectProviderThumbprint",
+			"iam:AddClientIDToOpenIDConnectProvider",
+			"iam:RemoveClientIDFromOpenIDConnectProvider",
+			"iam:TagOpenIDConnectProvider",
+			"iam:UntagOpenIDConnectProvider",
+		},
+		Delete: []string{"iam:DeleteOpenIDConnectProvider"},
+	},
+	"aws_iam_saml_provider": {
+		Create: []string{"iam:CreateSAMLProvider", "iam:TagSAMLProvider"},
+		Read:   []string{"iam:GetSAMLProvider"},
+		Update: []string{"iam:UpdateSAMLProvider", "iam:TagSAMLProvider", "iam:UntagSAMLProvider"},
+		Delete: []string{"iam:DeleteSAMLProvider"},
+	},
+	"aws_iam_service_linked_role": {
+		Create: []string{"iam:CreateServiceLinkedRole"},
+		Read:   []string{"iam:GetRole"},
+		Update: []string{"iam:UpdateRoleDescription", "iam:TagRole", "iam:UntagRole"},
+		Delete: []string{"iam:DeleteServiceLinkedRole", "iam:GetServiceLinkedRoleDeletionStatus"},
+	},
 	"aws_lambda_function": {
 		Create: []string{"lambda:CreateFunction", "lambda:TagResource", "iam:PassRole"},
 		Read:   []string{"lambda:GetFunction", "lambda:GetFunctionConfiguration", "lambda:ListTags"},
@@ -273,6 +532,73 @@ var fallbackMappings = map[string]ResourceMapping{
 		Update: []string{"states:UpdateStateMachine", "states:TagResource", "states:UntagResource"},
 		Delete: []string{"states:DeleteStateMachine"},
 	},
+	"aws_cloudwatch_event_rule": {
+		Create: []string{"events:PutRule", "events:DescribeRule", "events:TagResource"},
+		Read:   []string{"events:DescribeRule", "events:ListTagsForResource", "events:ListTargetsByRule"},
+		Update: []string{"events:PutRule", "events:EnableRule", "events:DisableRule", "events:TagResource", "events:UntagResource"},
+		Delete: []string{"events:DeleteRule"},
+	},
+	"aws_cloudwatch_event_target": {
+		Create: []string{"events:PutTargets"},
+		Read:   []string{"events:ListTargetsByRule"},
+		Update: []string{"events:PutTargets"},
+		Delete: []string{"events:RemoveTargets"},
+	},
+	"aws_cloudwatch_event_bus": {
+		Create: []string{"events:CreateEventBus", "events:TagResource"},
+		Read:   []string{"events:DescribeEventBus", "events:ListTagsForResource"},
+		Update: []string{"events:UpdateEventBus", "events:TagResource", "events:UntagResource"},
+		Delete: []string{"events:DeleteEventBus"},
+	},
+	"aws_cloudwatch_event_bus_policy": {
+		Create: []string{"events:PutPermission"},
+		Read:   []string{"events:DescribeEventBus"},
+		Update: []string{"events:PutPermission"},
+		Delete: []string{"events:RemovePermission"},
+	},
+	"aws_cloudwatch_event_permission": {
+		Create: []string{"events:PutPermission"},
+		Read:   []string{"events:DescribeEventBus"},
+		Update: []string{"events:PutPermission", "events:RemovePermission"},
+		Delete: []string{"events:RemovePermission"},
+	},
+	"aws_cloudwatch_event_archive": {
+		Create: []string{"events:CreateArchive"},
+		Read:   []string{"events:DescribeArchive"},
+		Update: []string{"events:UpdateArchive"},
+		Delete: []string{"events:DeleteArchive"},
+	},
+	"aws_cloudwatch_event_connection": {
+		// The credentials of a connection are kept in a secret EventBridge manages
+		Create: []string{"events:CreateConnection", "secretsmanager:CreateSecret", "secretsmanager:GetSecretValue", "secretsmanager:PutSecretValue"},
+		Read:   []string{"events:DescribeConnection"},
+		Update: []string{"events:UpdateConnection", "secretsmanager:DescribeSecret", "secretsmanager:GetSecretValue", "secretsmanager:PutSecretValue"},
+		Delete: []string{"events:DeleteConnection", "secretsmanager:DeleteSecret"},
+	},
+	"aws_cloudwatch_event_api_destination": {
+		Create: []string{"events:CreateApiDestination"},
+		Read:   []string{"events:DescribeApiDestination"},
+		Update: []string{"events:UpdateApiDestination"},
+		Delete: []string{"events:DeleteApiDestination"},
+	},
+	"aws_scheduler_schedule": {
+		Create: []string{"scheduler:CreateSchedule"},
+		Read:   []string{"scheduler:GetSchedule"},
+		Update: []string{"scheduler:UpdateSchedule"},
+		Delete: []string{"scheduler:DeleteSchedule"},
+	},
+	"aws_scheduler_schedule_group": {
+		Create: []string{"scheduler:CreateScheduleGroup", "scheduler:TagResource"},
+		Read:   []string{"scheduler:GetScheduleGroup", "scheduler:ListTagsForResource"},
+		Update: []string{"scheduler:TagResource", "scheduler:UntagResource"},
+		Delete: []string{"scheduler:DeleteScheduleGroup"},
+	},
+	"aws_pipes_pipe": {
+		Create: []string{"pipes:CreatePipe", "pipes:TagResource"},
+		Read:   []string{"pipes:DescribePipe", "pipes:ListTagsForResource"},
+		Update: []string{"pipes:UpdatePipe", "pipes:StartPipe", "pipes:StopPipe", "pipes:TagResource", "pipes:UntagResource"},
+		Delete: []string{"pipes:DeletePipe"},
+	},
 	"aws_glue_catalog_database": {
 		Create: []string{"glue:CreateDatabase"},
 		Read:   []string{"glue:GetDatabase"},
@@ -0,0 +1,119 @@
+package mapping
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetProvenance(t *testing.T) {
+	SetCustomMappings(&CustomMappings{Mappings: map[string]CustomMapping{
+		"aws_glue_job": {ResourceMapping: ResourceMapping{Create: []string{"glue:CreateJob"}}},
+	}})
+	defer SetCustomMappings(&CustomMappings{})
+
+	SetResolvers(func(resourceType string) (ResourceMapping, Provenance, bool) {
+		if resourceType != "aws_example_widget" {
+			return ResourceMapping{}, Provenance{}, false
+		}
+		return ResourceMapping{Create: []string{"example:CreateWidget"}}, Provenance{Kind: ProvenanceHeuristic, CfnType: "AWS::Example::Widget"}, true
+	})
+	defer SetResolvers()
+
+	tests := []struct {
+		resourceType string
+		want         Provenance
+		wantOK       bool
+	}{
+		{"aws_glue_job", Provenance{Kind: ProvenanceCustom}, true},
+		{"aws_s3_bucket", Provenance{Kind: ProvenanceSchema, CfnType: "AWS::S3::Bucket"}, true},
+		{"aws_sqs_queue", Provenance{Kind: ProvenanceFallback}, true},
+		{"aws_example_widget", Provenance{Kind: ProvenanceHeuristic, CfnType: "AWS::Example::Widget"}, true},
+		{"aws_unknown_thing", Provenance{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resourceType, func(t *testing.T) {
+			got, ok := GetProvenance(tt.resourceType)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("GetProvenance() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	// GetSource keeps reporting the CloudFormation type of schema-derived mappings
+	if got, _ := GetSource("aws_example_widget"); got != "AWS::Example::Widget" {
+		t.Errorf("GetSource() = %q, want AWS::Example::Widget", got)
+	}
+}
+
+func TestVersionedMappings(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"3.76.1", "4.0.0", -1},
+		{"4.0", "4.0.0", 0},
+		{"5.10.0", "5.9.2", 1},
+		{"4.0.0-beta1", "4.0.0", 0},
+	} {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	hasAction := func(actions []string, action string) bool {
+		for _, a := range actions {
+			if a == action {
+				return true
+			}
+		}
+		return false
+	}
+
+	SetAWSProviderVersion("3.76.1")
+	defer SetAWSProviderVersion("")
+	m, _, ok := GetMapping("aws_s3_bucket")
+	if !ok || !hasAction(m.Create, "s3:PutBucketVersioning") || hasAction(m.Create, "s3:PutBucketOwnershipControls") {
+		t.Errorf("aws_s3_bucket for provider v3 = %v, want the inline configuration actions", m.Create)
+	}
+	if p, _ := GetProvenance("aws_s3_bucket"); p.Kind != ProvenanceFallback {
+		t.Errorf("GetProvenance(aws_s3_bucket) for provider v3 = %+v, want fallback", p)
+	}
+
+	SetAWSProviderVersion("5.31.0")
+	if p, _ := GetProvenance("aws_s3_bucket"); p.Kind != ProvenanceSchema {
+		t.Errorf("GetProvenance(aws_s3_bucket) for provider v5 = %+v, want schema", p)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	for alias, canonical := range map[string]string{
+		"aws_alb":              "aws_lb",
+		"aws_alb_target_group": "aws_lb_target_group",
+	} {
+		want, _, _ := GetMapping(canonical)
+		got, _, ok := GetMapping(alias)
+		if !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("GetMapping(%s) = %+v, %v, want the mapping of %s", alias, got, ok, canonical)
+		}
+		wantARN, _ := GetARNPattern(canonical)
+		if gotARN, ok := GetARNPattern(alias); !ok || !reflect.DeepEqual(gotARN, wantARN) {
+			t.Errorf("GetARNPattern(%s) = %+v, %v, want the pattern of %s", alias, gotARN, ok, canonical)
+		}
+		if !IsBuiltin(alias) {
+			t.Errorf("IsBuiltin(%s) = false", alias)
+		}
+	}
+
+	// Custom mappings of an alias override those of its canonical type
+	SetCustomMappings(&CustomMappings{Mappings: map[string]CustomMapping{
+		"aws_alb": {ResourceMapping: ResourceMapping{Create: []string{"elasticloadbalancing:CreateLoadBalancer"}}},
+	}})
+	defer SetCustomMappings(&CustomMappings{})
+	if p, _ := GetProvenance("aws_alb"); p.Kind != ProvenanceCustom {
+		t.Errorf("GetProvenance(aws_alb) = %+v, want custom", p)
+	}
+	if p, _ := GetProvenance("aws_lb"); p.Kind == ProvenanceCustom {
+		t.Errorf("GetProvenance(aws_lb) = %+v, want a built-in mapping", p)
+	}
+}
@@ -0,0 +1,135 @@
+package mapping
+
+import (
+	"strconv"
+	"strings"
+)
+
+// versionedMapping is the mapping of a resource type for the hashicorp/aws
+// provider versions before Before
+type versionedMapping struct {
+	Before  string
+	Mapping ResourceMapping
+}
+
+// versionedMappings replace the built-in mappings of resource types whose
+// behavior differs in older hashicorp/aws provider versions, ordered by
+// Before. They are used when the provider version is known.
+var versionedMappings = map[string][]versionedMapping{
+	// Before v4, aws_s3_bucket configured ACLs, policies, versioning,
+	// logging, lifecycle, CORS, websites, replication, encryption,
+	// acceleration, request payment and object lock inline. It never
+	// managed ownership controls, public access blocks, notifications or
+	// analytics, inventory and metrics configurations, which are resources
+	// of their own.
+	"aws_s3_bucket": {{
+		Before: "4.0.0",
+		Mapping: ResourceMapping{
+			Create: []string{
+				"s3:CreateBucket",
+				"s3:PutBucketAcl",
+				"s3:PutBucketTagging",
+				"s3:PutBucketPolicy",
+				"s3:PutBucketCORS",
+				"s3:PutBucketWebsite",
+				"s3:PutBucketVersioning",
+				"s3:PutBucketLogging",
+				"s3:PutLifecycleConfiguration",
+				"s3:PutReplicationConfiguration",
+				"s3:PutEncryptionConfiguration",
+				"s3:PutAccelerateConfiguration",
+				"s3:PutBucketRequestPayment",
+				"s3:PutBucketObjectLockConfiguration",
+			},
+			Read: []string{
+				"s3:ListBucket",
+				"s3:GetBucketLocation",
+				"s3:GetBucketAcl",
+				"s3:GetBucketTagging",
+				"s3:GetBucketPolicy",
+				"s3:GetBucketCORS",
+				"s3:GetBucketWebsite",
+				"s3:GetBucketVersioning",
+				"s3:GetBucketLogging",
+				"s3:GetLifecycleConfiguration",
+				"s3:GetReplicationConfiguration",
+				"s3:GetEncryptionConfiguration",
+				"s3:GetAccelerateConfiguration",
+				"s3:GetBucketRequestPayment",
+				"s3:GetBucketObjectLockConfiguration",
+			},
+			Update: []string{
+				"s3:PutBucketAcl",
+				"s3:PutBucketTagging",
+				"s3:PutBucketPolicy",
+				"s3:DeleteBucketPolicy",
+				"s3:PutBucketCORS",
+				"s3:PutBucketWebsite",
+				"s3:DeleteBucketWebsite",
+				"s3:PutBucketVersioning",
+				"s3:PutBucketLogging",
+				"s3:PutLifecycleConfiguration",
+				"s3:PutReplicationConfiguration",
+				"s3:PutEncryptionConfiguration",
+				"s3:PutAccelerateConfiguration",
+				"s3:PutBucketRequestPayment",
+				"s3:PutBucketObjectLockConfiguration",
+			},
+			Delete: []string{"s3:DeleteBucket"},
+		},
+	}},
+}
+
+// awsProviderVersion is the hashicorp/aws provider version mappings are
+// selected for, or "" if unknown
+var awsProviderVersion string
+
+// SetAWSProviderVersion selects the mappings for a hashicorp/aws provider
+// version (e.g., "3.76.1"); with "", the built-in mappings for current
+// versions are used
+func SetAWSProviderVersion(v string) {
+	awsProviderVersion = v
+}
+
+// versioned returns the mapping of a resource type for the provider version
+func versioned(resourceType string) (ResourceMapping, bool) {
+	if awsProviderVersion == "" {
+		return ResourceMapping{}, false
+	}
+	for _, m := range versionedMappings[resourceType] {
+		if CompareVersions(awsProviderVersion, m.Before) < 0 {
+			return m.Mapping, true
+		}
+	}
+	return ResourceMapping{}, false
+}
+
+// CompareVersions compares two dotted versions numerically (e.g., "3.76.1"
+// and "4.0.0"), returning -1, 0 or 1. Missing components are 0, and
+// prerelease suffixes are ignored.
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		x, y := versionComponent(as, i), versionComponent(bs, i)
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionComponent returns the numeric component i of a version, or 0
+func versionComponent(parts []string, i int) int {
+	if i >= len(parts) {
+		return 0
+	}
+	digits := parts[i]
+	if j := strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }); j >= 0 {
+		digits = digits[:j]
+	}
+	n, _ := strconv.Atoi(digits)
+	return n
+}
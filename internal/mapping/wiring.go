@@ -0,0 +1,47 @@
+package mapping
+
+// wiringActions are the actions a wiring resource, which connects resources
+// of other services, needs on the resources it refers to, by wiring type and
+// referenced type. Creating an event source mapping, for example, reads the
+// attributes of its queue as well as calling lambda:CreateEventSourceMapping.
+var wiringActions = map[string]map[string][]string{
+	"aws_lambda_event_source_mapping": {
+		"aws_sqs_queue":       {"sqs:GetQueueAttributes"},
+		"aws_kinesis_stream":  {"kinesis:DescribeStream", "kinesis:DescribeStreamSummary", "kinesis:ListShards"},
+		"aws_dynamodb_table":  {"dynamodb:DescribeStream", "dynamodb:ListStreams"},
+		"aws_lambda_function": {"lambda:GetFunction"},
+	},
+	"aws_s3_bucket_notification": {
+		"aws_s3_bucket":       {"s3:GetBucketNotification", "s3:PutBucketNotification"},
+		"aws_sqs_queue":       {"sqs:GetQueueAttributes"},
+		"aws_sns_topic":       {"sns:GetTopicAttributes"},
+		"aws_lambda_function": {"lambda:GetFunction"},
+	},
+	"aws_sns_topic_subscription": {
+		"aws_sns_topic":       {"sns:GetSubscriptionAttributes", "sns:SetSubscriptionAttributes", "sns:Subscribe", "sns:Unsubscribe"},
+		"aws_sqs_queue":       {"sqs:GetQueueAttributes"},
+		"aws_lambda_function": {"lambda:GetFunction"},
+	},
+	"aws_cloudwatch_event_target": {
+		"aws_cloudwatch_event_rule": {"events:ListTargetsByRule", "events:PutTargets", "events:RemoveTargets"},
+		"aws_iam_role":              {"iam:PassRole"},
+	},
+	"aws_cloudwatch_event_rule": {
+		"aws_iam_role": {"iam:PassRole"},
+	},
+	"aws_scheduler_schedule": {
+		"aws_iam_role": {"iam:PassRole"},
+	},
+	"aws_pipes_pipe": {
+		"aws_iam_role": {"iam:PassRole"},
+	},
+	"aws_lambda_permission": {
+		"aws_lambda_function": {"lambda:AddPermission", "lambda:GetPolicy", "lambda:RemovePermission"},
+	},
+}
+
+// GetWiringActions returns the actions a resource of wiringType needs on a
+// resource of targetType it refers to, or nil
+func GetWiringActions(wiringType, targetType string) []string {
+	return wiringActions[wiringType][targetType]
+}
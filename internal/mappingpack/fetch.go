@@ -0,0 +1,246 @@
+package mappingpack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// maxPackSize limits the size of downloaded packs and manifests
+const maxPackSize = 10 << 20
+
+// Manifest and layer media types of OCI artifacts
+const (
+	ociManifestType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestType = "application/vnd.docker.distribution.manifest.v2+json"
+	titleAnnotation    = "org.opencontainers.image.title"
+)
+
+// Fetched is a pack downloaded from its source
+type Fetched struct {
+	Data []byte
+	// SHA256 is the hex checksum the pack must have
+	SHA256 string
+	// Name is the name of the pack derived from its source
+	Name string
+}
+
+// Fetch downloads the pack at source, an https URL or an OCI reference
+// (oci://registry/repository:tag or @sha256:digest). sum is the expected hex
+// SHA-256 checksum. It may only be empty for OCI references pinned to a
+// manifest digest, whose layer digest is then trusted: a checksum served
+// next to the pack would come from the same place as the pack and verify
+// nothing. Plain http is only accepted for localhost.
+func Fetch(ctx context.Context, client *http.Client, source, sum string) (*Fetched, error) {
+	if strings.HasPrefix(source, "oci://") {
+		return fetchOCI(ctx, client, strings.TrimPrefix(source, "oci://"), sum)
+	}
+	u, err := url.Parse(source)
+	if err != nil || u.Scheme != "https" && !(u.Scheme == "http" && isLocalhost(u.Hostname())) {
+		return nil, fmt.Errorf("unsupported mapping pack source %s (use an https:// URL or an oci:// reference)", source)
+	}
+	if sum == "" {
+		return nil, fmt.Errorf("no checksum for %s: pass --sha256", source)
+	}
+
+	data, err := get(ctx, client, source, "", "")
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(path.Base(u.Path), path.Ext(u.Path))
+	return &Fetched{Data: data, SHA256: strings.ToLower(sum), Name: name}, nil
+}
+
+// isLocalhost checks if a host name is the local machine, which plain http
+// is accepted for
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+// ociManifest is the part of an OCI image manifest naming its layers
+type ociManifest struct {
+	Layers []struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+// fetchOCI downloads the pack layer of an OCI artifact with the registry
+// HTTP API, verifying the manifest digest of references pinned to one
+func fetchOCI(ctx context.Context, client *http.Client, ref, sum string) (*Fetched, error) {
+	host, repo, reference, err := parseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	pinned := strings.HasPrefix(reference, "sha256:")
+	if sum == "" && !pinned {
+		return nil, fmt.Errorf("no checksum for oci://%s: pass --sha256 or pin the manifest with @sha256:digest", ref)
+	}
+	base := "https://" + host
+	if u, err := url.Parse("//" + host); err == nil && isLocalhost(u.Hostname()) {
+		base = "http://" + host
+	}
+
+	token := ""
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", base, repo, reference)
+	data, err := get(ctx, client, manifestURL, ociManifestType+", "+dockerManifestType, token)
+	var challenge *authChallenge
+	if errors.As(err, &challenge) {
+		if token, err = fetchToken(ctx, client, challenge.header, repo); err != nil {
+			return nil, err
+		}
+		data, err = get(ctx, client, manifestURL, ociManifestType+", "+dockerManifestType, token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if digest, _ := strings.CutPrefix(reference, "sha256:"); pinned && checksum(data) != digest {
+		return nil, fmt.Errorf("manifest of oci://%s does not match its digest", ref)
+	}
+
+	var m ociManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing manifest of oci://%s: %w", ref, err)
+	}
+	layer := -1
+	for i, l := range m.Layers {
+		title := l.Annotations[titleAnnotation]
+		if strings.HasSuffix(title, ".yaml") || strings.HasSuffix(title, ".yml") || len(m.Layers) == 1 {
+			layer = i
+			break
+		}
+	}
+	if layer < 0 {
+		return nil, fmt.Errorf("oci://%s has no mappings file layer (a layer titled *.yaml)", ref)
+	}
+	digest, ok := strings.CutPrefix(m.Layers[layer].Digest, "sha256:")
+	if !ok {
+		return nil, fmt.Errorf("unsupported layer digest %s in oci://%s", m.Layers[layer].Digest, ref)
+	}
+	if sum == "" {
+		sum = digest
+	}
+
+	blob, err := get(ctx, client, fmt.Sprintf("%s/v2/%s/blobs/%s", base, repo, m.Layers[layer].Digest), "", token)
+	if err != nil {
+		return nil, err
+	}
+	if checksum(blob) != digest {
+		return nil, fmt.Errorf("layer of oci://%s does not match its digest", ref)
+	}
+	return &Fetched{Data: blob, SHA256: strings.ToLower(sum), Name: path.Base(repo)}, nil
+}
+
+// parseReference splits an OCI reference into its registry, repository and
+// tag or digest (latest if neither is given)
+func parseReference(ref string) (host, repo, reference string, err error) {
+	host, rest, ok := strings.Cut(ref, "/")
+	if !ok || host == "" || rest == "" {
+		return "", "", "", fmt.Errorf("invalid OCI reference oci://%s (use oci://registry/repository:tag)", ref)
+	}
+	if repo, digest, ok := strings.Cut(rest, "@"); ok {
+		// A tag next to the digest is informational
+		if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+			repo = repo[:i]
+		}
+		return host, repo, digest, nil
+	}
+	reference = "latest"
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		rest, reference = rest[:i], rest[i+1:]
+	}
+	return host, rest, reference, nil
+}
+
+// authChallenge is a 401 response asking for a bearer token
+type authChallenge struct {
+	header string
+}
+
+func (c *authChallenge) Error() string {
+	return "authentication required: " + c.header
+}
+
+// fetchToken gets an anonymous pull token for repo from the realm of a
+// WWW-Authenticate challenge
+func fetchToken(ctx context.Context, client *http.Client, challenge, repo string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported registry authentication: %s", challenge)
+	}
+	values := url.Values{}
+	realm := ""
+	for _, param := range strings.Split(params, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		value = strings.Trim(value, `"`)
+		switch key {
+		case "realm":
+			realm = value
+		case "service", "scope":
+			values.Set(key, value)
+		}
+	}
+	if realm == "" {
+		return "", fmt.Errorf("registry authentication challenge without realm: %s", challenge)
+	}
+	if values.Get("scope") == "" {
+		values.Set("scope", "repository:"+repo+":pull")
+	}
+
+	data, err := get(ctx, client, realm+"?"+values.Encode(), "", "")
+	if err != nil {
+		return "", fmt.Errorf("getting registry token: %w", err)
+	}
+	var resp struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", fmt.Errorf("parsing registry token: %w", err)
+	}
+	if resp.Token != "" {
+		return resp.Token, nil
+	}
+	return resp.AccessToken, nil
+}
+
+// get downloads a URL, returning an *authChallenge on 401 responses asking
+// for a bearer token
+func get(ctx context.Context, client *http.Client, rawURL, accept, token string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if challenge := resp.Header.Get("WWW-Authenticate"); resp.StatusCode == http.StatusUnauthorized && challenge != "" && token == "" {
+		return nil, &authChallenge{header: challenge}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s: HTTP %d", rawURL, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPackSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", rawURL, err)
+	}
+	if len(data) > maxPackSize {
+		return nil, fmt.Errorf("downloading %s: larger than %d bytes", rawURL, maxPackSize)
+	}
+	return data, nil
+}
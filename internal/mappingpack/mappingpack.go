@@ -0,0 +1,165 @@
+// Package mappingpack installs mapping packs: mappings files published at a
+// URL or in an OCI registry, for niche services or third-party Terraform
+// providers.
+//
+// Packs are verified by their SHA-256 checksum and installed into a
+// directory with an index recording where each came from. Installed packs are
+// loaded under the local mappings file and overlays, which override them:
+//
+//	packs:
+//	  - name: glue
+//	    version: 1.2.0
+//	    source: oci://ghcr.io/example/least-glue:1.2.0
+//	    sha256: 3f5a...
+//	    file: glue.yaml
+package mappingpack
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mizzy/least/internal/mapping"
+)
+
+// DefaultDir is the directory packs are installed into
+const DefaultDir = ".least/mappings"
+
+// indexFile is the index of the packs in a directory
+const indexFile = "index.yaml"
+
+// namePattern matches pack names, which name their file
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// reservedName is the pack name whose file would be the index
+const reservedName = "index"
+
+// Index lists the packs installed in a directory
+type Index struct {
+	Packs []Pack `yaml:"packs"`
+}
+
+// Pack is an installed mapping pack
+type Pack struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version,omitempty"`
+	// Source is the URL or OCI reference the pack was installed from
+	Source string `yaml:"source"`
+	// SHA256 is the hex SHA-256 checksum of the pack
+	SHA256 string `yaml:"sha256"`
+	// File is the file of the pack in the directory
+	File string `yaml:"file"`
+}
+
+// LoadIndex reads the index of the packs installed in dir; a directory
+// without one has no packs
+func LoadIndex(dir string) (*Index, error) {
+	data, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return &Index{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading mapping pack index: %w", err)
+	}
+	var idx Index
+	if err := yaml.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("parsing mapping pack index: %w", err)
+	}
+	return &idx, nil
+}
+
+// Save writes the index into dir
+func (idx *Index) Save(dir string) error {
+	data, err := yaml.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("encoding mapping pack index: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dir, indexFile), data, 0644)
+}
+
+// Files returns the files of the packs installed in dir, sorted by name, and
+// fails if one was modified since it was installed
+func Files(dir string) ([]string, error) {
+	idx, err := LoadIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, p := range idx.Packs {
+		file := filepath.Join(dir, p.File)
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading mapping pack %s: %w", p.Name, err)
+		}
+		if sum := checksum(data); sum != p.SHA256 {
+			return nil, fmt.Errorf("mapping pack %s was modified since it was installed (sha256 %s, want %s); reinstall it from %s", p.Name, sum, p.SHA256, p.Source)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// Install verifies a pack fetched from source against its checksum, and
+// installs it into dir, replacing an installed pack of the same name. The
+// pack is named by its pack section, or name if it has none.
+func Install(dir, source, name string, data []byte, sum string) (*Pack, error) {
+	if got := checksum(data); got != sum {
+		return nil, fmt.Errorf("checksum mismatch for %s: got sha256 %s, want %s", source, got, sum)
+	}
+	c, err := mapping.ParseCustom(data, source)
+	if err != nil {
+		return nil, err
+	}
+	if errs := c.Validate(); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid mapping pack %s: %w", source, errs[0])
+	}
+
+	p := Pack{Name: name, Source: source, SHA256: sum}
+	if c.Pack != nil {
+		p.Name, p.Version = c.Pack.Name, c.Pack.Version
+	}
+	if !namePattern.MatchString(p.Name) {
+		return nil, fmt.Errorf("invalid mapping pack name %q (use lowercase letters, digits, '.', '_' and '-')", p.Name)
+	}
+	if p.Name == reservedName {
+		return nil, fmt.Errorf("mapping pack name %q is reserved for the index file %s", p.Name, indexFile)
+	}
+	p.File = p.Name + ".yaml"
+
+	idx, err := LoadIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating mapping pack directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, p.File), data, 0644); err != nil {
+		return nil, fmt.Errorf("writing mapping pack: %w", err)
+	}
+
+	packs := []Pack{p}
+	for _, installed := range idx.Packs {
+		if installed.Name != p.Name {
+			packs = append(packs, installed)
+		}
+	}
+	sort.Slice(packs, func(i, j int) bool { return packs[i].Name < packs[j].Name })
+	idx.Packs = packs
+	if err := idx.Save(dir); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// checksum returns the hex SHA-256 checksum of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,150 @@
+package mappingpack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPack = `pack:
+  name: glue
+  version: 1.2.0
+mappings:
+  aws_glue_job:
+    create: [glue:CreateJob, iam:PassRole]
+    delete: [glue:DeleteJob]
+`
+
+func TestFetchURL(t *testing.T) {
+	sum := checksum([]byte(testPack))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/packs/glue.yaml":
+			fmt.Fprint(w, testPack)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	fetched, err := Fetch(context.Background(), server.Client(), server.URL+"/packs/glue.yaml", sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched.SHA256 != sum || fetched.Name != "glue" {
+		t.Errorf("Fetch() = sha256 %s, name %s; want %s, glue", fetched.SHA256, fetched.Name, sum)
+	}
+
+	dir := t.TempDir()
+	if _, err := Install(dir, server.URL, "glue", fetched.Data, strings.Repeat("0", 64)); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Install() with a wrong checksum = %v, want a checksum mismatch", err)
+	}
+	pack, err := Install(dir, server.URL, "other", fetched.Data, fetched.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pack.Name != "glue" || pack.Version != "1.2.0" {
+		t.Errorf("Install() = %+v, want glue 1.2.0 from the pack section", pack)
+	}
+
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0] != filepath.Join(dir, "glue.yaml") {
+		t.Errorf("Files() = %v", files)
+	}
+	if err := os.WriteFile(files[0], []byte(testPack+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Files(dir); err == nil || !strings.Contains(err.Error(), "modified") {
+		t.Errorf("Files() of a modified pack = %v, want an error", err)
+	}
+
+	if _, err := Fetch(context.Background(), server.Client(), server.URL+"/packs/missing.yaml", sum); err == nil {
+		t.Error("Fetch() of a missing pack succeeded")
+	}
+	// A checksum published next to the pack would not verify it
+	if _, err := Fetch(context.Background(), server.Client(), server.URL+"/packs/glue.yaml", ""); err == nil || !strings.Contains(err.Error(), "--sha256") {
+		t.Errorf("Fetch() without a checksum = %v, want an error asking for --sha256", err)
+	}
+	if _, err := Fetch(context.Background(), server.Client(), "http://example.com/packs/glue.yaml", sum); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("Fetch() over plain http = %v, want an error", err)
+	}
+}
+
+func TestFetchOCI(t *testing.T) {
+	layer := "sha256:" + checksum([]byte(testPack))
+	manifest, _ := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     ociManifestType,
+		"layers": []map[string]any{{
+			"mediaType":   "application/vnd.least.mappings.v1+yaml",
+			"digest":      layer,
+			"annotations": map[string]string{titleAnnotation: "glue.yaml"},
+		}},
+	})
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:example/least-glue:pull" {
+				t.Errorf("token scope = %s", r.URL.Query().Get("scope"))
+			}
+			fmt.Fprint(w, `{"token":"anonymous"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer anonymous" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/example/least-glue/manifests/1.2.0", "/v2/example/least-glue/manifests/sha256:" + checksum(manifest):
+			w.Write(manifest)
+		case "/v2/example/least-glue/blobs/" + layer:
+			fmt.Fprint(w, testPack)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	// A tag needs a checksum; a pinned manifest vouches for its layer digest
+	tag := "oci://" + host + "/example/least-glue:1.2.0"
+	pinned := "oci://" + host + "/example/least-glue@sha256:" + checksum(manifest)
+	for source, sum := range map[string]string{tag: strings.TrimPrefix(layer, "sha256:"), pinned: ""} {
+		fetched, err := Fetch(context.Background(), server.Client(), source, sum)
+		if err != nil {
+			t.Fatalf("Fetch(%s) failed: %v", source, err)
+		}
+		if fetched.Data == nil || "sha256:"+fetched.SHA256 != layer || fetched.Name != "least-glue" {
+			t.Errorf("Fetch(%s) = sha256 %s, name %s", source, fetched.SHA256, fetched.Name)
+		}
+	}
+
+	if _, err := Fetch(context.Background(), server.Client(), "oci://"+host+"/example/least-glue@sha256:"+strings.Repeat("0", 64), ""); err == nil {
+		t.Error("Fetch() of a manifest not matching its digest succeeded")
+	}
+	if _, err := Fetch(context.Background(), server.Client(), tag, ""); err == nil || !strings.Contains(err.Error(), "@sha256:") {
+		t.Errorf("Fetch() of a tag without a checksum = %v, want an error", err)
+	}
+}
+
+func TestInstallReservedName(t *testing.T) {
+	data := []byte(strings.Replace(testPack, "name: glue", "name: index", 1))
+	dir := t.TempDir()
+	if _, err := Install(dir, "https://example.com/index.yaml", "index", data, checksum(data)); err == nil || !strings.Contains(err.Error(), "reserved") {
+		t.Errorf("Install() of a pack named index = %v, want an error", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, indexFile)); !os.IsNotExist(err) {
+		t.Errorf("the pack overwrote the index: %v", err)
+	}
+}
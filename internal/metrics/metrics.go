@@ -0,0 +1,148 @@
+// Package metrics exposes the results of a long-running least server in the
+// Prometheus text exposition format, so they can be tracked on dashboards
+// over time.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentType is the media type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Run statuses
+const (
+	StatusPassed = "passed"
+	StatusFailed = "failed"
+	StatusError  = "error"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the parse duration
+// histogram buckets
+var durationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// findings are the latest check results of a project
+type findings struct {
+	missing, excessive, broad int
+}
+
+// Metrics collects the results of the runs a server evaluates
+type Metrics struct {
+	mu        sync.Mutex
+	runs      map[string]int
+	buckets   []int
+	durations float64
+	parses    int
+	resources int
+	projects  map[string]findings
+}
+
+// New returns empty metrics
+func New() *Metrics {
+	return &Metrics{
+		runs:     make(map[string]int),
+		buckets:  make([]int, len(durationBuckets)),
+		projects: make(map[string]findings),
+	}
+}
+
+// ObserveRun counts a run evaluated with a status: StatusPassed,
+// StatusFailed or StatusError
+func (m *Metrics) ObserveRun(status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs[status]++
+}
+
+// ObserveParse records how long parsing took and how many resources it found
+func (m *Metrics) ObserveParse(d time.Duration, resources int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seconds := d.Seconds()
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			m.buckets[i]++
+		}
+	}
+	m.durations += seconds
+	m.parses++
+	m.resources += resources
+}
+
+// SetFindings records the latest check results of a project
+func (m *Metrics) SetFindings(project string, missing, excessive, broad int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.projects[project] = findings{missing: missing, excessive: excessive, broad: broad}
+}
+
+// Write writes the metrics in the Prometheus text exposition format, with
+// label values in a stable order
+func (m *Metrics) Write(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	header := func(name, kind, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	header("least_runs_total", "counter", "Runs evaluated, by status.")
+	for _, status := range []string{StatusPassed, StatusFailed, StatusError} {
+		fmt.Fprintf(&b, "least_runs_total{status=%q} %d\n", status, m.runs[status])
+	}
+
+	header("least_parse_duration_seconds", "histogram", "Time spent parsing IaC and generating the required policy.")
+	for i, bound := range durationBuckets {
+		fmt.Fprintf(&b, "least_parse_duration_seconds_bucket{le=%q} %d\n", strconv.FormatFloat(bound, 'g', -1, 64), m.buckets[i])
+	}
+	fmt.Fprintf(&b, "least_parse_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.parses)
+	fmt.Fprintf(&b, "least_parse_duration_seconds_sum %s\n", strconv.FormatFloat(m.durations, 'g', -1, 64))
+	fmt.Fprintf(&b, "least_parse_duration_seconds_count %d\n", m.parses)
+
+	header("least_resources_analyzed_total", "counter", "Resources analyzed.")
+	fmt.Fprintf(&b, "least_resources_analyzed_total %d\n", m.resources)
+
+	projects := make([]string, 0, len(m.projects))
+	for project := range m.projects {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+
+	for _, gauge := range []struct {
+		name, help string
+		value      func(findings) int
+	}{
+		{"least_missing_permissions", "Actions required but not granted in the latest run of a project.", func(f findings) int { return f.missing }},
+		{"least_excessive_permissions", "Actions granted but not required in the latest run of a project.", func(f findings) int { return f.excessive }},
+		{"least_broad_grants", "Dangerously broad grants in the latest run of a project.", func(f findings) int { return f.broad }},
+	} {
+		header(gauge.name, "gauge", gauge.help)
+		for _, project := range projects {
+			fmt.Fprintf(&b, "%s{project=\"%s\"} %d\n", gauge.name, escapeLabel(project), gauge.value(m.projects[project]))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Handler serves the metrics
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		_ = m.Write(w)
+	})
+}
+
+// escapeLabel escapes a label value as the text exposition format requires
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	m := New()
+	m.ObserveRun(StatusPassed)
+	m.ObserveRun(StatusFailed)
+	m.ObserveRun(StatusFailed)
+	m.ObserveParse(300*time.Millisecond, 4)
+	m.ObserveParse(3*time.Second, 6)
+	m.SetFindings("acme/prod", 2, 1, 0)
+	m.SetFindings("acme/dev", 5, 0, 1)
+	m.SetFindings("acme/prod", 1, 0, 0)
+
+	var b strings.Builder
+	if err := m.Write(&b); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	got := b.String()
+
+	for _, want := range []string{
+		"# TYPE least_runs_total counter\n",
+		`least_runs_total{status="passed"} 1` + "\n",
+		`least_runs_total{status="failed"} 2` + "\n",
+		`least_runs_total{status="error"} 0` + "\n",
+		`least_parse_duration_seconds_bucket{le="0.25"} 0` + "\n",
+		`least_parse_duration_seconds_bucket{le="0.5"} 1` + "\n",
+		`least_parse_duration_seconds_bucket{le="5"} 2` + "\n",
+		`least_parse_duration_seconds_bucket{le="+Inf"} 2` + "\n",
+		"least_parse_duration_seconds_sum 3.3\n",
+		"least_parse_duration_seconds_count 2\n",
+		"least_resources_analyzed_total 10\n",
+		"# TYPE least_missing_permissions gauge\n" +
+			"least_missing_permissions{project=\"acme/dev\"} 5\n" +
+			"least_missing_permissions{project=\"acme/prod\"} 1\n",
+		`least_excessive_permissions{project="acme/prod"} 0` + "\n",
+		`least_broad_grants{project="acme/dev"} 1` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if got, want := escapeLabel("a\"b\\c\nd"), `a\"b\\c\nd`; got != want {
+		t.Errorf("escapeLabel() = %q, want %q", got, want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := New()
+	m.ObserveRun(StatusError)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body, _ := io.ReadAll(rec.Body)
+	if !strings.Contains(string(body), `least_runs_total{status="error"} 1`) {
+		t.Errorf("unexpected body:\n%s", body)
+	}
+}
@@ -0,0 +1,112 @@
+// Package notify sends check failures to webhooks, for checks that run on a
+// schedule rather than on pull requests.
+//
+// The payload is a JSON object that Slack incoming webhooks (and compatible
+// services such as Mattermost or Discord's Slack endpoint) display through
+// its text field, while generic consumers read the structured fields.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Summary is the outcome of a failed check
+type Summary struct {
+	// Path is the analyzed IaC path
+	Path string `json:"path"`
+	// Policy is the checked policy (file, directory or ARN)
+	Policy    string   `json:"policy"`
+	Missing   []string `json:"missing"`
+	Excessive []string `json:"excessive"`
+	// Broad are the dangerously broad grants of the policy
+	Broad []string `json:"broad"`
+	// ReportURL links to the full report, e.g., the CI job log
+	ReportURL string `json:"report_url,omitempty"`
+}
+
+// Payload is the JSON body posted to a webhook
+type Payload struct {
+	// Text is the message Slack-compatible webhooks display
+	Text    string  `json:"text"`
+	Status  string  `json:"status"`
+	Summary Summary `json:"summary"`
+}
+
+// maxListed is how many actions of each kind the text lists
+const maxListed = 10
+
+// NewPayload builds the webhook payload of a failed check
+func NewPayload(s Summary) Payload {
+	var b strings.Builder
+	fmt.Fprintf(&b, ":warning: *least check failed* for `%s` against `%s`: %d missing, %d excessive, %d broad",
+		s.Path, s.Policy, len(s.Missing), len(s.Excessive), len(s.Broad))
+	list := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n*%s:*", title)
+		for i, item := range items {
+			if i == maxListed {
+				fmt.Fprintf(&b, "\n• … and %d more", len(items)-maxListed)
+				break
+			}
+			fmt.Fprintf(&b, "\n• `%s`", item)
+		}
+	}
+	list("Missing", s.Missing)
+	list("Excessive", s.Excessive)
+	list("Broad grants", s.Broad)
+	if s.ReportURL != "" {
+		fmt.Fprintf(&b, "\n<%s|Full report>", s.ReportURL)
+	}
+
+	return Payload{Text: b.String(), Status: "failed", Summary: s}
+}
+
+// Send posts the payload of a failed check to a webhook
+func Send(ctx context.Context, url string, s Summary) error {
+	body, err := json.Marshal(NewPayload(s))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sending webhook: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// RunURL returns the URL of the CI job running least, detected from the
+// environment variables of GitHub Actions, GitLab CI, CircleCI and Buildkite
+func RunURL(getenv func(string) string) string {
+	if id := getenv("GITHUB_RUN_ID"); id != "" && getenv("GITHUB_REPOSITORY") != "" {
+		server := getenv("GITHUB_SERVER_URL")
+		if server == "" {
+			server = "https://github.com"
+		}
+		return fmt.Sprintf("%s/%s/actions/runs/%s", server, getenv("GITHUB_REPOSITORY"), id)
+	}
+	for _, name := range []string{"CI_JOB_URL", "CIRCLE_BUILD_URL", "BUILDKITE_BUILD_URL"} {
+		if url := getenv(name); url != "" {
+			return url
+		}
+	}
+	return ""
+}
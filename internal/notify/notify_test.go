@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewPayload(t *testing.T) {
+	var excessive []string
+	for i := 0; i < 12; i++ {
+		excessive = append(excessive, fmt.Sprintf("ec2:Action%02d", i))
+	}
+	p := NewPayload(Summary{
+		Path:      "./terraform",
+		Policy:    "policy.json",
+		Missing:   []string{"s3:GetObject"},
+		Excessive: excessive,
+		ReportURL: "https://ci.example.com/jobs/1",
+	})
+
+	if p.Status != "failed" {
+		t.Errorf("Status = %q, want failed", p.Status)
+	}
+	for _, want := range []string{
+		"1 missing, 12 excessive, 0 broad",
+		"*Missing:*\n• `s3:GetObject`",
+		"• `ec2:Action09`\n• … and 2 more",
+		"<https://ci.example.com/jobs/1|Full report>",
+	} {
+		if !strings.Contains(p.Text, want) {
+			t.Errorf("Text does not contain %q:\n%s", want, p.Text)
+		}
+	}
+	if strings.Contains(p.Text, "Broad grants") {
+		t.Errorf("Text lists empty broad grants:\n%s", p.Text)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got Payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding payload: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	if err := Send(context.Background(), server.URL, Summary{Path: ".", Policy: "policy.json", Missing: []string{"s3:GetObject"}}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got.Text == "" || len(got.Summary.Missing) != 1 {
+		t.Errorf("unexpected payload %+v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer failing.Close()
+	if err := Send(context.Background(), failing.URL, Summary{}); err == nil {
+		t.Error("expected error for HTTP 404")
+	}
+}
+
+func TestRunURL(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "github",
+			env:  map[string]string{"GITHUB_SERVER_URL": "https://github.com", "GITHUB_REPOSITORY": "o/r", "GITHUB_RUN_ID": "42"},
+			want: "https://github.com/o/r/actions/runs/42",
+		},
+		{
+			name: "gitlab",
+			env:  map[string]string{"CI_JOB_URL": "https://gitlab.com/o/r/-/jobs/7"},
+			want: "https://gitlab.com/o/r/-/jobs/7",
+		},
+		{
+			name: "unknown",
+			env:  map[string]string{},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RunURL(func(k string) string { return tt.env[k] }); got != tt.want {
+				t.Errorf("RunURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// Package observed reads the API calls recorded while IaC was applied and
+// compares them with a generated policy.
+//
+// Two recordings are supported: the policy document written by iamlive
+// (--output-file), and AWS SDK client-side monitoring (CSM) events, one JSON
+// object per line, as captured by iamlive or any CSM listener.
+package observed
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/policy"
+)
+
+// csmEvent is an AWS SDK client-side monitoring event
+type csmEvent struct {
+	Type    string `json:"Type"`
+	Service string `json:"Service"`
+	Api     string `json:"Api"`
+}
+
+// servicePrefixes maps CSM service IDs, lowercased without spaces, whose
+// IAM service prefix differs
+var servicePrefixes = map[string]string{
+	"applicationautoscaling":  "application-autoscaling",
+	"cloudwatchevents":        "events",
+	"cloudwatchlogs":          "logs",
+	"cognitoidentity":         "cognito-identity",
+	"cognitoidentityprovider": "cognito-idp",
+	"configservice":           "config",
+	"efs":                     "elasticfilesystem",
+	"elasticloadbalancingv2":  "elasticloadbalancing",
+	"elasticsearchservice":    "es",
+	"emr":                     "elasticmapreduce",
+	"eventbridge":             "events",
+	"opensearch":              "es",
+	"sesv2":                   "ses",
+	"sfn":                     "states",
+}
+
+// Load reads the observed actions from an iamlive policy or CSM event file
+func Load(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading observed calls: %w", err)
+	}
+	actions, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing observed calls %s: %w", path, err)
+	}
+	return actions, nil
+}
+
+// Parse returns the sorted, unique actions of an iamlive policy document or
+// CSM events
+func Parse(data []byte) ([]string, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err == nil {
+		if _, ok := probe["Statement"]; ok {
+			p, err := policy.ParsePolicy(data)
+			if err != nil {
+				return nil, err
+			}
+			return p.GetAllActions(), nil
+		}
+	}
+	return parseCSM(data)
+}
+
+// parseCSM returns the actions of the API calls in CSM events. Per-attempt
+// events are ignored since every call also has an ApiCall event.
+func parseCSM(data []byte) ([]string, error) {
+	set := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var e csmEvent
+		if err := json.Unmarshal(text, &e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if e.Type != "ApiCall" || e.Service == "" || e.Api == "" {
+			continue
+		}
+		set[actionOf(e)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	actions := make([]string, 0, len(set))
+	for a := range set {
+		actions = append(actions, a)
+	}
+	sort.Strings(actions)
+	return actions, nil
+}
+
+// actionOf returns the IAM action of a CSM event
+func actionOf(e csmEvent) string {
+	service := strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(e.Service))
+	if prefix, ok := servicePrefixes[service]; ok {
+		service = prefix
+	}
+	return service + ":" + e.Api
+}
+
+// Comparison is the result of comparing a generated policy with observed calls
+type Comparison struct {
+	// Confirmed are generated actions that were observed
+	Confirmed []string
+	// Missed are observed actions the generated policy does not grant
+	Missed []string
+	// OverPredicted are generated actions that were not observed. Actions
+	// needed only to update or destroy resources are expected here when the
+	// recording covers a single apply.
+	OverPredicted []string
+}
+
+// Compare compares the actions of a generated policy with observed actions
+func Compare(generated *policy.IAMPolicy, observed []string) Comparison {
+	granted := generated.GetAllActions()
+
+	var c Comparison
+	for _, action := range granted {
+		if matchesAny(action, observed) {
+			c.Confirmed = append(c.Confirmed, action)
+		} else {
+			c.OverPredicted = append(c.OverPredicted, action)
+		}
+	}
+	for _, action := range observed {
+		if !matchesAny(action, granted) {
+			c.Missed = append(c.Missed, action)
+		}
+	}
+	return c
+}
+
+// matchesAny checks if an action matches or is matched by any of actions
+func matchesAny(action string, actions []string) bool {
+	for _, a := range actions {
+		if checker.MatchAction(a, action) || checker.MatchAction(action, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge returns a copy of the generated policy with a statement granting the
+// missed actions. Observed calls carry no resource, so the statement applies
+// to all resources and should be scoped by hand.
+func Merge(generated *policy.IAMPolicy, missed []string) *policy.IAMPolicy {
+	merged := &policy.IAMPolicy{
+		Version:   generated.Version,
+		Statement: append([]policy.Statement(nil), generated.Statement...),
+	}
+	if len(missed) == 0 {
+		return merged
+	}
+	merged.Statement = append(merged.Statement, policy.Statement{
+		Sid:      "ObservedActions",
+		Effect:   "Allow",
+		Action:   append(policy.StringList(nil), missed...),
+		Resource: policy.StringList{"*"},
+	})
+	return merged
+}
@@ -0,0 +1,83 @@
+package observed
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mizzy/least/internal/policy"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "iamlive policy",
+			data: `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": ["s3:CreateBucket", "sts:GetCallerIdentity"], "Resource": "*"}]}`,
+			want: []string{"s3:CreateBucket", "sts:GetCallerIdentity"},
+		},
+		{
+			name: "CSM events",
+			data: `{"Version":1,"Type":"ApiCall","Service":"S3","Api":"CreateBucket"}
+{"Version":1,"Type":"ApiCallAttempt","Service":"S3","Api":"PutBucketTagging"}
+{"Version":1,"Type":"ApiCall","Service":"Elastic Load Balancing v2","Api":"DescribeLoadBalancers"}
+{"Version":1,"Type":"ApiCall","Service":"SFN","Api":"CreateStateMachine"}
+
+{"Version":1,"Type":"ApiCall","Service":"S3","Api":"CreateBucket"}`,
+			want: []string{"elasticloadbalancing:DescribeLoadBalancers", "s3:CreateBucket", "states:CreateStateMachine"},
+		},
+		{
+			name: "empty",
+			data: "\n",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if len(got) != 0 || len(tt.want) != 0 {
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Parse() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	if _, err := Parse([]byte("not json")); err == nil {
+		t.Error("Parse() expected error for invalid input")
+	}
+}
+
+func TestCompareAndMerge(t *testing.T) {
+	generated := &policy.IAMPolicy{
+		Version: "2012-10-17",
+		Statement: []policy.Statement{
+			{Sid: "Bucket", Effect: "Allow", Action: []string{"s3:CreateBucket", "s3:DeleteBucket", "s3:GetBucket*"}, Resource: []string{"arn:aws:s3:::logs"}},
+		},
+	}
+	observedActions := []string{"s3:CreateBucket", "s3:GetBucketPolicy", "sts:GetCallerIdentity"}
+
+	c := Compare(generated, observedActions)
+	want := Comparison{
+		Confirmed:     []string{"s3:CreateBucket", "s3:GetBucket*"},
+		Missed:        []string{"sts:GetCallerIdentity"},
+		OverPredicted: []string{"s3:DeleteBucket"},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("Compare() = %+v, want %+v", c, want)
+	}
+
+	merged := Merge(generated, c.Missed)
+	if len(merged.Statement) != 2 || len(generated.Statement) != 1 {
+		t.Fatalf("Merge() statements = %d (generated %d), want 2 (1)", len(merged.Statement), len(generated.Statement))
+	}
+	if s := merged.Statement[1]; s.Sid != "ObservedActions" || !reflect.DeepEqual([]string(s.Action), c.Missed) {
+		t.Errorf("Merge() added %+v", s)
+	}
+}
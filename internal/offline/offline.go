@@ -0,0 +1,28 @@
+// Package offline holds the switch that disables the AWS calls least makes.
+//
+// AWS APIs are called with the AWS SDK, which resolves credentials,
+// profiles, and SSO sessions like the AWS CLI does. The aws binary itself
+// is only run as a fallback for fetching CloudFormation schemas. Every
+// package calling AWS, by either means, checks Enabled first.
+package offline
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrOffline is returned instead of calling AWS in offline mode
+var ErrOffline = errors.New("AWS calls are disabled in offline mode")
+
+var enabled atomic.Bool
+
+// Set disables all AWS CLI and API calls made by least, including those
+// made with the SDK, when v is true
+func Set(v bool) {
+	enabled.Store(v)
+}
+
+// Enabled reports whether AWS calls are disabled
+func Enabled() bool {
+	return enabled.Load()
+}
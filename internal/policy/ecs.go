@@ -0,0 +1,198 @@
+package policy
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/mizzy/least/internal/mapping"
+	"github.com/mizzy/least/internal/provider"
+)
+
+// ecrImagePattern matches an image in a private ECR repository, capturing
+// the account, region and repository name
+var ecrImagePattern = regexp.MustCompile(`^([0-9*]+)\.dkr\.ecr\.([a-z0-9*-]+)\.amazonaws\.com(?:\.cn)?/([^:@]+)`)
+
+// ExecutionRolePolicy returns the policy of the task execution role of an
+// ECS task definition, derived from its container definitions: pulling the
+// images from ECR, writing to the log groups of the awslogs log driver,
+// reading the Secrets Manager secrets and SSM parameters injected into the
+// containers, and reading their environment files from S3. Values that are
+// not known, and references in JSON output or in child modules, are matched
+// with wildcards.
+func (g *Generator) ExecutionRolePolicy(task provider.Resource) *IAMPolicy {
+	defs, _ := task.Attributes[provider.ContainerDefinitionsAttribute].([]provider.ContainerDefinition)
+
+	var repositories, logStreams, logGroups, secrets, parameters, objects, buckets []string
+	for _, def := range defs {
+		if repository := g.repositoryARN(task, def.Image); repository != "" {
+			repositories = append(repositories, repository)
+		}
+		if def.LogGroup != "" {
+			group := g.logGroupARN(task, def.LogGroup)
+			logStreams = append(logStreams, group+":log-stream:*")
+			if def.CreateLogGroup {
+				logGroups = append(logGroups, group)
+			}
+		}
+		for _, secret := range def.Secrets {
+			if arn, parameter := g.secretARN(task, secret); parameter {
+				parameters = append(parameters, arn)
+			} else {
+				secrets = append(secrets, arn)
+			}
+		}
+		if def.RepositoryCredentials != "" {
+			// Registry credentials are always a secret
+			arn, parameter := g.secretARN(task, def.RepositoryCredentials)
+			if parameter {
+				arn = g.secretWildcard()
+			}
+			secrets = append(secrets, arn)
+		}
+		for _, file := range def.EnvironmentFiles {
+			object, bucket := g.environmentFileARNs(task, file)
+			objects = append(objects, object)
+			buckets = append(buckets, bucket)
+		}
+	}
+
+	p := &IAMPolicy{Version: "2012-10-17"}
+	add := func(sid string, actions, resources []string) {
+		if len(resources) == 0 {
+			return
+		}
+		p.Statement = append(p.Statement, Statement{
+			Sid:        sid,
+			Effect:     "Allow",
+			Action:     actions,
+			Resource:   uniqueSorted(resources),
+			Sources:    []provider.Resource{task},
+			Provenance: mapping.Provenance{Kind: mapping.ProvenanceFallback},
+		})
+	}
+	if len(repositories) > 0 {
+		add("ECRAuthorization", []string{"ecr:GetAuthorizationToken"}, []string{"*"})
+	}
+	add("ECRPull", []string{"ecr:BatchCheckLayerAvailability", "ecr:BatchGetImage", "ecr:GetDownloadUrlForLayer"}, repositories)
+	add("LogGroups", []string{"logs:CreateLogGroup"}, logGroups)
+	add("LogStreams", []string{"logs:CreateLogStream", "logs:PutLogEvents"}, logStreams)
+	add("Secrets", []string{"secretsmanager:GetSecretValue"}, secrets)
+	add("Parameters", []string{"ssm:GetParameters"}, parameters)
+	add("EnvironmentFiles", []string{"s3:GetObject"}, objects)
+	add("EnvironmentFileBuckets", []string{"s3:GetBucketLocation"}, buckets)
+	return p
+}
+
+// referencedAddress returns the address of the resource or data source a
+// value of provider.ContainerDefinition refers to (e.g., "aws_ecr_repository.app"
+// for "${aws_ecr_repository.app.repository_url}"), or "" for literals
+func referencedAddress(value string) string {
+	if !strings.HasPrefix(value, "${") {
+		return ""
+	}
+	steps := strings.Split(strings.TrimSuffix(strings.TrimPrefix(value, "${"), "}"), ".")
+	n := 2
+	if steps[0] == "data" {
+		n = 3
+	}
+	if len(steps) < n {
+		return ""
+	}
+	return strings.Join(steps[:n], ".")
+}
+
+// referenceAttribute returns a reference to an attribute of the resource a
+// value refers to, or "" if Terraform output cannot refer to it
+func (g *Generator) referenceAttribute(task provider.Resource, address, attribute string) string {
+	if address == "" || task.Module != "" || g.options.OutputFormat != "terraform" {
+		return ""
+	}
+	return "${" + address + "." + attribute + "}"
+}
+
+// resourceType returns the type of a resource or data source address
+func resourceType(address string) string {
+	return strings.Split(strings.TrimPrefix(address, "data."), ".")[0]
+}
+
+// repositoryARN returns the ARN of the ECR repository of an image, or "" if
+// the image is not in a private ECR repository. Images that are not known
+// may be in any repository.
+func (g *Generator) repositoryARN(task provider.Resource, image string) string {
+	wildcard := g.buildARN("arn:aws:ecr:{region}:{account}:repository/*", "", "", provider.Resource{})
+	if address := referencedAddress(image); address != "" {
+		if resourceType(address) != "aws_ecr_repository" {
+			return wildcard
+		}
+		if ref := g.referenceAttribute(task, address, "arn"); ref != "" {
+			return ref
+		}
+		return wildcard
+	}
+	if m := ecrImagePattern.FindStringSubmatch(image); m != nil {
+		return "arn:aws:ecr:" + m[2] + ":" + m[1] + ":repository/" + m[3]
+	}
+	if image == "" || strings.HasPrefix(image, "*") {
+		return wildcard
+	}
+	return ""
+}
+
+// logGroupARN returns the ARN of a log group of the awslogs log driver
+func (g *Generator) logGroupARN(task provider.Resource, group string) string {
+	if address := referencedAddress(group); address != "" {
+		if resourceType(address) == "aws_cloudwatch_log_group" {
+			if ref := g.referenceAttribute(task, address, "arn"); ref != "" {
+				return ref
+			}
+		}
+		group = "*"
+	}
+	return g.buildARN("arn:aws:logs:{region}:{account}:log-group:"+group, "", "", provider.Resource{})
+}
+
+// secretARN returns the ARN of the Secrets Manager secret or SSM parameter a
+// valueFrom names, and whether it is a parameter. Secret ARNs may end with
+// the JSON key, version stage and version ID to inject; parameters of the
+// task's region may be named without an ARN, so values that are not known
+// are taken as parameters.
+func (g *Generator) secretARN(task provider.Resource, value string) (string, bool) {
+	if address := referencedAddress(value); address != "" {
+		parameter := !strings.HasPrefix(resourceType(address), "aws_secretsmanager_")
+		if ref := g.referenceAttribute(task, address, "arn"); ref != "" {
+			return ref, parameter
+		}
+		if parameter {
+			return g.buildARN("arn:aws:ssm:{region}:{account}:parameter/*", "", "", provider.Resource{}), true
+		}
+		return g.secretWildcard(), false
+	}
+	switch {
+	case strings.HasPrefix(value, "arn:aws:secretsmanager:"):
+		if parts := strings.Split(value, ":"); len(parts) > 7 {
+			return strings.Join(parts[:7], ":"), false
+		}
+		return value, false
+	case strings.HasPrefix(value, "arn:"):
+		return value, true
+	default:
+		return g.buildARN("arn:aws:ssm:{region}:{account}:parameter/"+strings.TrimPrefix(value, "/"), "", "", provider.Resource{}), true
+	}
+}
+
+// secretWildcard returns the ARN matching any Secrets Manager secret
+func (g *Generator) secretWildcard() string {
+	return g.buildARN("arn:aws:secretsmanager:{region}:{account}:secret:*", "", "", provider.Resource{})
+}
+
+// environmentFileARNs returns the ARNs of an environment file and its bucket
+func (g *Generator) environmentFileARNs(task provider.Resource, file string) (string, string) {
+	if address := referencedAddress(file); address != "" {
+		if object := g.referenceAttribute(task, address, "arn"); object != "" {
+			return object, "arn:aws:s3:::" + g.referenceAttribute(task, address, "bucket")
+		}
+		return "arn:aws:s3:::*/*", "arn:aws:s3:::*"
+	}
+	bucket, _, _ := strings.Cut(file, "/")
+	return file, bucket
+}
@@ -0,0 +1,105 @@
+package policy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+// KMSKeysSid is the Sid of the statement granting the use of the
+// customer-managed KMS keys resources encrypt with
+const KMSKeysSid = "CustomerManagedKeys"
+
+// kmsActions let services encrypt the resources they create for the deployer
+// with a customer-managed key, on the deployer's behalf
+var kmsActions = []string{"kms:CreateGrant", "kms:Decrypt", "kms:Encrypt", "kms:GenerateDataKey"}
+
+// kmsStatement returns the statement granting kmsActions on the keys of the
+// resources, with the resources using them as its sources
+func (g *Generator) kmsStatement(resources []provider.Resource) (Statement, bool) {
+	arns := make(map[string]bool)
+	var sources []provider.Resource
+	for _, res := range sortResources(resources) {
+		if len(res.KMSKeys) == 0 {
+			continue
+		}
+		sources = append(sources, res)
+		for _, key := range res.KMSKeys {
+			arns[g.keyARN(key)] = true
+		}
+	}
+	if len(sources) == 0 {
+		return Statement{}, false
+	}
+
+	stmt := Statement{
+		Sid:     KMSKeysSid,
+		Effect:  "Allow",
+		Action:  append([]string(nil), kmsActions...),
+		Sources: sources,
+	}
+	for arn := range arns {
+		stmt.Resource = append(stmt.Resource, arn)
+	}
+	sort.Strings(stmt.Resource)
+	return stmt, true
+}
+
+// keyARN returns the ARN of a key of provider.Resource.KMSKeys. Keys of the
+// root module are referenced in Terraform output; keys known only by alias
+// or from child modules are matched with a wildcard, since permissions are
+// evaluated on the key an alias points to.
+func (g *Generator) keyARN(key string) string {
+	wildcard := g.buildARN("arn:aws:kms:{region}:{account}:key/*", "", "", provider.Resource{})
+	switch {
+	case strings.HasPrefix(key, "arn:"):
+		if i := strings.Index(key, ":alias/"); i >= 0 {
+			return key[:i] + ":key/*"
+		}
+		return key
+	case strings.HasPrefix(key, "alias/"):
+		return wildcard
+	case strings.HasPrefix(key, "aws_"), strings.HasPrefix(key, "data."):
+		if g.options.OutputFormat != "terraform" {
+			return wildcard
+		}
+		if strings.HasPrefix(key, "aws_kms_alias.") || strings.HasPrefix(key, "data.aws_kms_alias.") {
+			return "${" + key + ".target_key_arn}"
+		}
+		return "${" + key + ".arn}"
+	case strings.HasPrefix(key, "module."):
+		return wildcard
+	default:
+		// A key ID
+		return g.buildARN("arn:aws:kms:{region}:{account}:key/"+key, "", "", provider.Resource{})
+	}
+}
+
+// KMSKeyPolicyStatement returns the key policy statement that lets principal
+// use the customer-managed keys of the policy as the KMSKeysSid statement
+// does. A key policy must allow what an identity policy grants on its key.
+func (p *IAMPolicy) KMSKeyPolicyStatement(principal string) (KeyPolicyStatement, bool) {
+	for _, stmt := range p.Statement {
+		if stmt.Sid == KMSKeysSid {
+			return KeyPolicyStatement{
+				Sid:       "AllowLeastPrivilegeDeployer",
+				Effect:    "Allow",
+				Principal: map[string]string{"AWS": principal},
+				Action:    stmt.Action,
+				Resource:  "*",
+			}, true
+		}
+	}
+	return KeyPolicyStatement{}, false
+}
+
+// KeyPolicyStatement is a statement of a KMS key policy, which names the
+// principals it applies to; its resource is always the key itself ("*")
+type KeyPolicyStatement struct {
+	Sid       string            `json:"Sid"`
+	Effect    string            `json:"Effect"`
+	Principal map[string]string `json:"Principal"`
+	Action    []string          `json:"Action"`
+	Resource  string            `json:"Resource"`
+}
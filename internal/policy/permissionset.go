@@ -0,0 +1,94 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PermissionSetPolicyLimit is the maximum size of the inline policy of an
+// IAM Identity Center permission set, in characters of its JSON
+const PermissionSetPolicyLimit = 32768
+
+// permissionSetName matches valid permission set names
+var permissionSetName = regexp.MustCompile(`^[\w+=,.@-]{1,32}$`)
+
+// referencePattern matches a Terraform interpolation in an ARN
+var referencePattern = regexp.MustCompile(`\$\{[^}]*\}`)
+
+// PermissionSetOptions configures the permission set output
+type PermissionSetOptions struct {
+	// Name is the name of the permission set (default: LeastPrivilege)
+	Name string
+}
+
+// ToPermissionSet converts the policy to Terraform HCL that provisions it as
+// the inline policy of an IAM Identity Center permission set, with the
+// policy JSON in a heredoc. A permission set is provisioned into many
+// accounts, so Terraform references in resources, which would resolve in
+// the account running Terraform, are replaced with "*"; the number of
+// resources changed is returned with the HCL.
+func (p *IAMPolicy) ToPermissionSet(opts PermissionSetOptions) (string, int, error) {
+	name := opts.Name
+	if name == "" {
+		name = "LeastPrivilege"
+	}
+	if !permissionSetName.MatchString(name) {
+		return "", 0, fmt.Errorf("invalid permission set name: %s (up to 32 letters, digits and +=,.@_- characters)", name)
+	}
+
+	doc, widened := p.withoutReferences()
+
+	document, err := doc.ToJSON()
+	if err != nil {
+		return "", 0, err
+	}
+	if len(document) > PermissionSetPolicyLimit {
+		return "", 0, fmt.Errorf("inline policy is %d characters, over the %d limit of permission sets", len(document), PermissionSetPolicyLimit)
+	}
+
+	var b strings.Builder
+	b.WriteString(`data "aws_ssoadmin_instances" "current" {}`)
+	b.WriteString("\n\n")
+
+	b.WriteString(`resource "aws_ssoadmin_permission_set" "least_privilege" {`)
+	b.WriteString("\n")
+	b.WriteString(`  name         = "` + name + "\"\n")
+	b.WriteString(`  instance_arn = tolist(data.aws_ssoadmin_instances.current.arns)[0]`)
+	b.WriteString("\n}\n\n")
+
+	b.WriteString(`resource "aws_ssoadmin_permission_set_inline_policy" "least_privilege" {`)
+	b.WriteString("\n")
+	b.WriteString("  instance_arn       = aws_ssoadmin_permission_set.least_privilege.instance_arn\n")
+	b.WriteString("  permission_set_arn = aws_ssoadmin_permission_set.least_privilege.arn\n")
+	b.WriteString("  inline_policy      = <<-EOT\n")
+	// Escape template sequences so Terraform takes the JSON literally
+	escaped := strings.NewReplacer("${", "$${", "%{", "%%{").Replace(document)
+	for _, line := range strings.Split(escaped, "\n") {
+		b.WriteString("    " + line + "\n")
+	}
+	b.WriteString("  EOT\n")
+	b.WriteString("}\n")
+
+	return b.String(), widened, nil
+}
+
+// withoutReferences returns a copy of the policy with the Terraform
+// references in resources replaced with "*", for policies deployed outside
+// the account running Terraform, and the number of resources changed
+func (p *IAMPolicy) withoutReferences() (*IAMPolicy, int) {
+	doc := &IAMPolicy{Version: p.Version}
+	widened := 0
+	for _, stmt := range p.Statement {
+		resources := make([]string, len(stmt.Resource))
+		for i, r := range stmt.Resource {
+			resources[i] = referencePattern.ReplaceAllString(r, "*")
+			if resources[i] != r {
+				widened++
+			}
+		}
+		stmt.Resource = resources
+		doc.Statement = append(doc.Statement, stmt)
+	}
+	return doc, widened
+}
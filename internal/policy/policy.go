@@ -3,6 +3,7 @@ package policy
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"unicode"
@@ -15,37 +16,82 @@ import (
 type IAMPolicy struct {
 	Version   string      `json:"Version"`
 	Statement []Statement `json:"Statement"`
+
+	// Uncovered are the AWS resources skipped by Generate because their type
+	// has no mapping. It is not part of the policy document.
+	Uncovered []provider.Resource `json:"-"`
 }
 
 // Statement represents a single IAM policy statement
 type Statement struct {
-	Sid      string     `json:"Sid,omitempty"`
-	Effect   string     `json:"Effect"`
-	Action   StringList `json:"Action"`
-	Resource StringList `json:"Resource"`
+	Sid         string     `json:"Sid,omitempty"`
+	Effect      string     `json:"Effect"`
+	Action      StringList `json:"Action,omitempty"`
+	NotAction   StringList `json:"NotAction,omitempty"`
+	Resource    StringList `json:"Resource,omitempty"`
+	NotResource StringList `json:"NotResource,omitempty"`
+	Condition   Condition  `json:"Condition,omitempty"`
+
+	// Sources are the IaC resources that require this statement.
+	// They are not part of the policy document.
+	Sources []provider.Resource `json:"-"`
+	// Requirements are the permissions of the IaC tool itself that require
+	// this statement. They are not part of the policy document.
+	Requirements []provider.Requirement `json:"-"`
+	// Provenance is where the mapping the actions come from was derived.
+	// It is not part of the policy document.
+	Provenance mapping.Provenance `json:"-"`
 }
 
+// Condition maps condition operators to condition keys and their values
+// e.g., {"StringEquals": {"aws:RequestedRegion": ["us-east-1"]}}
+type Condition map[string]map[string]StringList
+
 // StringList handles both single string and array of strings in JSON
 type StringList []string
 
-// UnmarshalJSON handles both "action" and ["action1", "action2"] formats
+// UnmarshalJSON handles both "action" and ["action1", "action2"] formats.
+// Boolean and numeric scalars (as used in condition values) are kept as strings.
 func (s *StringList) UnmarshalJSON(data []byte) error {
-	// Try to unmarshal as a single string first
-	var single string
-	if err := json.Unmarshal(data, &single); err == nil {
-		*s = []string{single}
-		return nil
+	// Try to unmarshal as a single value first
+	var single interface{}
+	if err := json.Unmarshal(data, &single); err != nil {
+		return err
 	}
 
-	// Try to unmarshal as an array
-	var multiple []string
-	if err := json.Unmarshal(data, &multiple); err != nil {
-		return err
+	switch v := single.(type) {
+	case []interface{}:
+		multiple := make([]string, 0, len(v))
+		for _, item := range v {
+			str, err := scalarToString(item)
+			if err != nil {
+				return err
+			}
+			multiple = append(multiple, str)
+		}
+		*s = multiple
+	default:
+		str, err := scalarToString(v)
+		if err != nil {
+			return err
+		}
+		*s = []string{str}
 	}
-	*s = multiple
 	return nil
 }
 
+// scalarToString converts a JSON scalar to its string form
+func scalarToString(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case bool, float64:
+		return fmt.Sprint(val), nil
+	default:
+		return "", fmt.Errorf("expected string or array of strings, got %T", v)
+	}
+}
+
 // GeneratorOptions configures how policies are generated
 type GeneratorOptions struct {
 	// OutputFormat is "terraform" or "json"
@@ -81,15 +127,23 @@ func NewWithOptions(opts GeneratorOptions) *Generator {
 	return &Generator{options: opts}
 }
 
-// Generate creates a minimal IAM policy for the given resources
+// Generate creates a minimal IAM policy for the given resources.
+// Statements are ordered by resource address and source location rather than
+// parse order, so the policy only changes when the resources do.
 func (g *Generator) Generate(resources []provider.Resource) (*IAMPolicy, error) {
 	statements := make([]Statement, 0)
+	sids := make(map[string]int)
+	var uncovered []provider.Resource
 
-	for _, res := range resources {
+	for _, res := range sortResources(resources) {
 		actions := mapping.GetActionsForResource(res.Type)
 		if len(actions) == 0 {
+			if res.CloudProvider == "aws" {
+				uncovered = append(uncovered, res)
+			}
 			continue
 		}
+		provenance, _ := mapping.GetProvenance(res.Type)
 
 		// Sort actions for consistent output
 		sort.Strings(actions)
@@ -97,33 +151,107 @@ func (g *Generator) Generate(resources []provider.Resource) (*IAMPolicy, error)
 		// Build ARNs for this resource
 		arns := g.buildARNsForResource(res)
 
-		// Generate Sid from resource type and name
+		// Generate Sid from resource type and name, numbering resources with
+		// the same address in different modules or roots to keep Sids unique
 		sid := g.generateSid(res.Type, res.Name)
+		sids[sid]++
+		if n := sids[sid]; n > 1 {
+			sid = fmt.Sprintf("%s%d", sid, n)
+		}
 
 		statements = append(statements, Statement{
-			Sid:      sid,
-			Effect:   "Allow",
-			Action:   actions,
-			Resource: arns,
+			Sid:        sid,
+			Effect:     "Allow",
+			Action:     actions,
+			Resource:   arns,
+			Sources:    []provider.Resource{res},
+			Provenance: provenance,
 		})
 	}
 
-	// If no statements were generated, return empty policy
-	if len(statements) == 0 {
-		return &IAMPolicy{
-			Version:   "2012-10-17",
-			Statement: []Statement{},
-		}, nil
+	statements = append(statements, g.wiringStatements(resources, sids)...)
+
+	if stmt, ok := g.kmsStatement(resources); ok {
+		statements = append(statements, stmt)
 	}
 
 	policy := &IAMPolicy{
 		Version:   "2012-10-17",
 		Statement: statements,
+		Uncovered: uncovered,
 	}
 
 	return policy, nil
 }
 
+// wiringStatements returns, for each resource wiring others together, a
+// statement granting the actions it needs on the resources it refers to,
+// on their ARNs. sids are the Sids taken, numbered as in Generate.
+func (g *Generator) wiringStatements(resources []provider.Resource, sids map[string]int) []Statement {
+	byAddress := make(map[string]provider.Resource)
+	for _, res := range resources {
+		byAddress[res.FullAddress()] = res
+	}
+
+	var statements []Statement
+	for _, res := range sortResources(resources) {
+		var actions, arns []string
+		for _, ref := range res.References {
+			target, ok := byAddress[ref]
+			if !ok {
+				continue
+			}
+			wiring := mapping.GetWiringActions(res.Type, target.Type)
+			if len(wiring) == 0 {
+				continue
+			}
+			actions = append(actions, wiring...)
+			arns = append(arns, g.buildARNsForResource(target)...)
+		}
+		if len(actions) == 0 {
+			continue
+		}
+
+		sid := g.generateSid(res.Type, res.Name) + "Targets"
+		sids[sid]++
+		if n := sids[sid]; n > 1 {
+			sid = fmt.Sprintf("%s%d", sid, n)
+		}
+		statements = append(statements, Statement{
+			Sid:        sid,
+			Effect:     "Allow",
+			Action:     uniqueSorted(actions),
+			Resource:   uniqueSorted(arns),
+			Sources:    []provider.Resource{res},
+			Provenance: mapping.Provenance{Kind: mapping.ProvenanceFallback},
+		})
+	}
+	return statements
+}
+
+// sortResources returns a copy of resources ordered by address, then by
+// source location for resources with the same address in different modules
+func sortResources(resources []provider.Resource) []provider.Resource {
+	sorted := append([]provider.Resource(nil), resources...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Location.File != b.Location.File {
+			return a.Location.File < b.Location.File
+		}
+		if a.Location.Line != b.Location.Line {
+			return a.Location.Line < b.Location.Line
+		}
+		return a.Location.Column < b.Location.Column
+	})
+	return sorted
+}
+
 // generateSid creates a statement ID from resource type and name
 func (g *Generator) generateSid(resourceType, resourceName string) string {
 	// Convert aws_s3_bucket to AwsS3Bucket
@@ -162,20 +290,21 @@ func (g *Generator) buildARNsForResource(res provider.Resource) []string {
 	arns := []string{}
 
 	// Build main ARN
-	mainARN := g.buildARN(pattern.Pattern, pattern.ResourceAttribute, res)
+	mainARN := g.buildARN(pattern.Pattern, pattern.ResourceAttribute, pattern.Default, res)
 	arns = append(arns, mainARN)
 
 	// Add child patterns (e.g., S3 objects)
 	for _, childPattern := range pattern.ChildPatterns {
-		childARN := g.buildARN(childPattern, pattern.ResourceAttribute, res)
+		childARN := g.buildARN(childPattern, pattern.ResourceAttribute, pattern.Default, res)
 		arns = append(arns, childARN)
 	}
 
 	return arns
 }
 
-// buildARN constructs a single ARN from a pattern
-func (g *Generator) buildARN(pattern, attrName string, res provider.Resource) string {
+// buildARN constructs a single ARN from a pattern. An attribute that is not
+// known is replaced with fallback, or * if it is empty.
+func (g *Generator) buildARN(pattern, attrName, fallback string, res provider.Resource) string {
 	arn := pattern
 
 	// Replace {account} placeholder
@@ -226,9 +355,12 @@ func (g *Generator) buildARN(pattern, attrName string, res provider.Resource) st
 			}
 		}
 
-		// If still contains placeholder, fall back to *
+		// If still contains placeholder, fall back to the default or *
 		if strings.Contains(arn, "{"+attrName+"}") {
-			arn = strings.ReplaceAll(arn, "{"+attrName+"}", "*")
+			if fallback == "" {
+				fallback = "*"
+			}
+			arn = strings.ReplaceAll(arn, "{"+attrName+"}", fallback)
 		}
 	}
 
@@ -281,10 +413,119 @@ func (p *IAMPolicy) ToJSON() (string, error) {
 	return string(data), nil
 }
 
+// Metadata describes how a generated policy was derived. It is written next
+// to the policy document, never into it.
+type Metadata struct {
+	// Actions maps each action to the resources requiring it
+	Actions map[string][]ActionSource `json:"actions"`
+	// Resources maps each resource to the Sids of the statements it requires
+	Resources map[string][]string `json:"resources"`
+	// Uncovered lists the resource types without a mapping, most frequent
+	// first, whose permissions the policy does not grant
+	Uncovered []UncoveredType `json:"uncovered"`
+}
+
+// UncoveredType is a resource type without a mapping and its resources
+type UncoveredType struct {
+	Type      string   `json:"type"`
+	Resources []string `json:"resources"`
+}
+
+// ActionSource is a resource requiring an action, where it is defined, and
+// where the mapping granting the action comes from
+type ActionSource struct {
+	// Resource is the address of the resource, including its module
+	Resource   string             `json:"resource"`
+	Type       string             `json:"type"`
+	Name       string             `json:"name"`
+	Module     string             `json:"module,omitempty"`
+	File       string             `json:"file,omitempty"`
+	Line       int                `json:"line,omitempty"`
+	Provenance mapping.Provenance `json:"provenance"`
+}
+
+// Metadata returns the metadata of a generated policy
+func (p *IAMPolicy) Metadata() Metadata {
+	m := Metadata{Actions: make(map[string][]ActionSource), Resources: make(map[string][]string)}
+	for _, stmt := range p.Statement {
+		if stmt.Effect != "Allow" {
+			continue
+		}
+		for _, res := range stmt.Sources {
+			address := res.FullAddress()
+			if !slices.Contains(m.Resources[address], stmt.Sid) {
+				m.Resources[address] = append(m.Resources[address], stmt.Sid)
+			}
+		}
+		for _, action := range stmt.Action {
+			for _, res := range stmt.Sources {
+				m.Actions[action] = append(m.Actions[action], ActionSource{
+					Resource:   res.FullAddress(),
+					Type:       res.Type,
+					Name:       res.Name,
+					Module:     res.Module,
+					File:       res.Location.File,
+					Line:       res.Location.Line,
+					Provenance: stmt.Provenance,
+				})
+			}
+		}
+	}
+	m.Uncovered = p.UncoveredTypes()
+	return m
+}
+
+// UncoveredTypes groups the uncovered resources by type, most frequent first
+func (p *IAMPolicy) UncoveredTypes() []UncoveredType {
+	types := make([]UncoveredType, 0)
+	index := make(map[string]int)
+	for _, res := range p.Uncovered {
+		i, ok := index[res.Type]
+		if !ok {
+			i = len(types)
+			index[res.Type] = i
+			types = append(types, UncoveredType{Type: res.Type})
+		}
+		types[i].Resources = append(types[i].Resources, res.Address())
+	}
+	sort.SliceStable(types, func(i, j int) bool {
+		if len(types[i].Resources) != len(types[j].Resources) {
+			return len(types[i].Resources) > len(types[j].Resources)
+		}
+		return types[i].Type < types[j].Type
+	})
+	return types
+}
+
+// MetadataJSON returns the metadata of the policy as JSON
+func (p *IAMPolicy) MetadataJSON() (string, error) {
+	data, err := json.MarshalIndent(p.Metadata(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ToJSONWithMetadata converts the policy to a JSON object holding the policy
+// document under "policy" and its metadata under "metadata"
+func (p *IAMPolicy) ToJSONWithMetadata() (string, error) {
+	data, err := json.MarshalIndent(struct {
+		Policy   *IAMPolicy `json:"policy"`
+		Metadata Metadata   `json:"metadata"`
+	}{p, p.Metadata()}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 // TerraformOutputOptions configures the Terraform output
 type TerraformOutputOptions struct {
 	NeedCallerIdentity bool
 	NeedRegion         bool
+	// Name is the name of the aws_iam_policy_document data source
+	// (default: least_privilege)
+	Name string
 }
 
 // ToTerraform converts the policy to Terraform HCL (aws_iam_policy_document)
@@ -306,10 +547,31 @@ func (p *IAMPolicy) ToTerraformWithOptions(opts TerraformOutputOptions) string {
 		b.WriteString("\n\n")
 	}
 
-	b.WriteString(`data "aws_iam_policy_document" "least_privilege" {`)
+	name := opts.Name
+	if name == "" {
+		name = "least_privilege"
+	}
+	b.WriteString(`data "aws_iam_policy_document" "` + name + `" {`)
 	b.WriteString("\n")
 
 	for _, stmt := range p.Statement {
+		// Let reviewers trace the statement back to the resources needing it
+		for _, res := range stmt.Sources {
+			b.WriteString("  # from ")
+			b.WriteString(res.FullAddress())
+			if loc := res.Location.String(); loc != "" {
+				b.WriteString(" (" + loc + ")")
+			}
+			b.WriteString("\n")
+		}
+		for _, req := range stmt.Requirements {
+			b.WriteString("  # for ")
+			b.WriteString(req.Description)
+			if loc := req.Location.String(); loc != "" {
+				b.WriteString(" (" + loc + ")")
+			}
+			b.WriteString("\n")
+		}
 		b.WriteString("  statement {\n")
 
 		if stmt.Sid != "" {
@@ -322,7 +584,18 @@ func (p *IAMPolicy) ToTerraformWithOptions(opts TerraformOutputOptions) string {
 		b.WriteString(stmt.Effect)
 		b.WriteString("\"\n")
 
-		b.WriteString("\n    actions = [\n")
+		b.WriteString("\n")
+		if kind := stmt.Provenance.Kind; kind != "" {
+			// Let reviewers judge how much to trust the actions
+			b.WriteString("    # provenance: ")
+			b.WriteString(kind)
+			if stmt.Provenance.CfnType != "" {
+				b.WriteString(" ")
+				b.WriteString(stmt.Provenance.CfnType)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("    actions = [\n")
 		for _, action := range stmt.Action {
 			b.WriteString(`      "`)
 			b.WriteString(action)
@@ -337,6 +610,7 @@ func (p *IAMPolicy) ToTerraformWithOptions(opts TerraformOutputOptions) string {
 			b.WriteString("\",\n")
 		}
 		b.WriteString("    ]\n")
+		writeTerraformConditions(&b, stmt.Condition)
 
 		b.WriteString("  }\n")
 	}
@@ -346,13 +620,63 @@ func (p *IAMPolicy) ToTerraformWithOptions(opts TerraformOutputOptions) string {
 	return b.String()
 }
 
+// writeTerraformConditions writes a condition block per operator and key,
+// sorted
+func writeTerraformConditions(b *strings.Builder, condition Condition) {
+	operators := make([]string, 0, len(condition))
+	for operator := range condition {
+		operators = append(operators, operator)
+	}
+	sort.Strings(operators)
+	for _, operator := range operators {
+		keys := make([]string, 0, len(condition[operator]))
+		for key := range condition[operator] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			b.WriteString("\n    condition {\n")
+			fmt.Fprintf(b, "      test     = %q\n", operator)
+			fmt.Fprintf(b, "      variable = %q\n", key)
+			values := make([]string, len(condition[operator][key]))
+			for i, v := range condition[operator][key] {
+				values[i] = fmt.Sprintf("%q", v)
+			}
+			fmt.Fprintf(b, "      values   = [%s]\n", strings.Join(values, ", "))
+			b.WriteString("    }\n")
+		}
+	}
+}
+
 // ParsePolicy parses a JSON IAM policy
 func ParsePolicy(data []byte) (*IAMPolicy, error) {
-	var policy IAMPolicy
-	if err := json.Unmarshal(data, &policy); err != nil {
+	var raw struct {
+		Version   string          `json:"Version"`
+		Statement json.RawMessage `json:"Statement"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	policy := &IAMPolicy{Version: raw.Version}
+	if len(raw.Statement) == 0 {
+		return policy, nil
+	}
+
+	// Statement may be a single object instead of an array
+	if raw.Statement[0] == '{' {
+		var stmt Statement
+		if err := json.Unmarshal(raw.Statement, &stmt); err != nil {
+			return nil, err
+		}
+		policy.Statement = []Statement{stmt}
+		return policy, nil
+	}
+
+	if err := json.Unmarshal(raw.Statement, &policy.Statement); err != nil {
 		return nil, err
 	}
-	return &policy, nil
+	return policy, nil
 }
 
 // GetAllActions extracts all actions from a policy
@@ -374,16 +698,70 @@ func (p *IAMPolicy) GetAllActions() []string {
 	return actions
 }
 
-// FromProviderPolicies creates an IAMPolicy from provider-parsed IAM policies
+// SourcesOf returns the resources that require an action, in statement order
+func (p *IAMPolicy) SourcesOf(action string) []provider.Resource {
+	var sources []provider.Resource
+	for _, stmt := range p.Statement {
+		if stmt.Effect != "Allow" {
+			continue
+		}
+		for _, a := range stmt.Action {
+			if a == action {
+				sources = append(sources, stmt.Sources...)
+				break
+			}
+		}
+	}
+	return sources
+}
+
+// WidenReferences replaces resources containing Terraform references, which
+// only resolve within Terraform, with "*" and returns the number replaced
+func (p *IAMPolicy) WidenReferences() int {
+	widened := 0
+	for i := range p.Statement {
+		for j, r := range p.Statement[i].Resource {
+			if strings.Contains(r, "${") {
+				p.Statement[i].Resource[j] = "*"
+				widened++
+			}
+		}
+	}
+	return widened
+}
+
+// GetNotActionGrants returns the NotAction lists of Allow statements.
+// Each list grants every action except those it matches.
+func (p *IAMPolicy) GetNotActionGrants() [][]string {
+	var grants [][]string
+	for _, stmt := range p.Statement {
+		if stmt.Effect == "Allow" && len(stmt.NotAction) > 0 {
+			grants = append(grants, stmt.NotAction)
+		}
+	}
+	return grants
+}
+
+// FromProviderPolicies creates an IAMPolicy from provider-parsed IAM policies.
+// Unconditional Allow statements are combined into one statement; statements
+// with conditions are kept with their conditions. Trust and resource-based
+// policy statements, which name principals, grant no permissions to the
+// identity and are skipped.
 func FromProviderPolicies(policies []provider.IAMPolicy) *IAMPolicy {
 	actionSet := make(map[string]bool)
+	var conditional []Statement
 
 	for _, pol := range policies {
 		for _, stmt := range pol.Statements {
-			if strings.EqualFold(stmt.Effect, "Allow") {
-				for _, action := range stmt.Actions {
-					actionSet[action] = true
-				}
+			if !strings.EqualFold(stmt.Effect, "Allow") || stmt.IsTrust() {
+				continue
+			}
+			if len(stmt.Conditions) > 0 {
+				conditional = append(conditional, StatementFromProvider(stmt))
+				continue
+			}
+			for _, action := range stmt.Actions {
+				actionSet[action] = true
 			}
 		}
 	}
@@ -396,13 +774,43 @@ func FromProviderPolicies(policies []provider.IAMPolicy) *IAMPolicy {
 
 	return &IAMPolicy{
 		Version: "2012-10-17",
-		Statement: []Statement{
+		Statement: append([]Statement{
 			{
 				Sid:      "CombinedPolicy",
 				Effect:   "Allow",
 				Action:   actions,
 				Resource: []string{"*"},
 			},
-		},
+		}, conditional...),
+	}
+}
+
+// StatementFromProvider converts a provider-parsed statement as written
+func StatementFromProvider(stmt provider.IAMStatement) Statement {
+	s := Statement{
+		Sid:      stmt.Sid,
+		Effect:   "Allow",
+		Action:   stmt.Actions,
+		Resource: stmt.Resources,
+	}
+	if strings.EqualFold(stmt.Effect, "Deny") {
+		s.Effect = "Deny"
+	}
+	if len(stmt.Conditions) > 0 {
+		s.Condition = conditionFromProvider(stmt.Conditions)
+	}
+	return s
+}
+
+// conditionFromProvider converts condition blocks to a Condition. Blocks
+// with the same operator and key are combined.
+func conditionFromProvider(conditions []provider.IAMCondition) Condition {
+	condition := make(Condition)
+	for _, c := range conditions {
+		if condition[c.Test] == nil {
+			condition[c.Test] = make(map[string]StringList)
+		}
+		condition[c.Test][c.Variable] = append(condition[c.Test][c.Variable], c.Values...)
 	}
+	return condition
 }
@@ -0,0 +1,756 @@
+package policy
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mizzy/least/internal/mapping"
+	"github.com/mizzy/least/internal/provider"
+)
+
+func TestSourcesOf(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "aws_s3_bucket", Name: "logs", Location: provider.SourceLocation{File: "s3.tf", Line: 12}},
+		{Type: "aws_s3_bucket", Name: "data", Location: provider.SourceLocation{File: "s3.tf", Line: 20}},
+		{Type: "aws_sqs_queue", Name: "jobs", Location: provider.SourceLocation{File: "sqs.tf", Line: 1}},
+	}
+
+	p, err := New().Generate(resources)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	sources := p.SourcesOf("s3:CreateBucket")
+	if len(sources) != 2 {
+		t.Fatalf("got %d sources, want 2", len(sources))
+	}
+	if sources[0].Address() != "aws_s3_bucket.data" || sources[0].Location.String() != "s3.tf:20" {
+		t.Errorf("unexpected first source: %s at %s", sources[0].Address(), sources[0].Location)
+	}
+
+	if got := p.SourcesOf("ec2:RunInstances"); len(got) != 0 {
+		t.Errorf("expected no sources for unrequired action, got %v", got)
+	}
+}
+
+func TestGenerateUniqueSids(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "aws_s3_bucket", Name: "main", Location: provider.SourceLocation{File: "network/main.tf"}},
+		{Type: "aws_s3_bucket", Name: "main", Location: provider.SourceLocation{File: "app/main.tf"}},
+		{Type: "aws_sqs_queue", Name: "main"},
+	}
+
+	p, err := New().Generate(resources)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	want := []string{"AwsS3BucketMain", "AwsS3BucketMain2", "AwsSqsQueueMain"}
+	if len(p.Statement) != len(want) {
+		t.Fatalf("got %d statements, want %d", len(p.Statement), len(want))
+	}
+	for i, sid := range want {
+		if p.Statement[i].Sid != sid {
+			t.Errorf("statement %d Sid = %q, want %q", i, p.Statement[i].Sid, sid)
+		}
+	}
+	if file := p.Statement[0].Sources[0].Location.File; file != "app/main.tf" {
+		t.Errorf("AwsS3BucketMain is from %s, want app/main.tf", file)
+	}
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "aws_sqs_queue", Name: "jobs", Location: provider.SourceLocation{File: "sqs.tf", Line: 1}},
+		{Type: "aws_s3_bucket", Name: "main", Location: provider.SourceLocation{File: "modules/b/main.tf", Line: 3}},
+		{Type: "aws_s3_bucket", Name: "logs", Location: provider.SourceLocation{File: "s3.tf", Line: 12}},
+		{Type: "aws_s3_bucket", Name: "main", Location: provider.SourceLocation{File: "modules/a/main.tf", Line: 7}},
+	}
+
+	generate := func(resources []provider.Resource) (string, string) {
+		p, err := NewWithOptions(GeneratorOptions{OutputFormat: "json"}).Generate(resources)
+		if err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+		document, err := p.ToJSON()
+		if err != nil {
+			t.Fatalf("ToJSON failed: %v", err)
+		}
+		return document, p.ToTerraform()
+	}
+
+	wantJSON, wantHCL := generate(resources)
+	for i := 0; i < len(resources); i++ {
+		// Rotate the resources, as parsing files in another order would
+		rotated := append(append([]provider.Resource(nil), resources[i:]...), resources[:i]...)
+		gotJSON, gotHCL := generate(rotated)
+		if gotJSON != wantJSON {
+			t.Errorf("rotation %d: JSON differs:\n%s\nwant:\n%s", i, gotJSON, wantJSON)
+		}
+		if gotHCL != wantHCL {
+			t.Errorf("rotation %d: Terraform differs:\n%s\nwant:\n%s", i, gotHCL, wantHCL)
+		}
+	}
+
+	p, _ := New().Generate(resources)
+	var sids []string
+	for _, stmt := range p.Statement {
+		sids = append(sids, stmt.Sid)
+		if !sort.StringsAreSorted(stmt.Action) {
+			t.Errorf("%s actions are not sorted: %v", stmt.Sid, stmt.Action)
+		}
+	}
+	want := "AwsS3BucketLogs,AwsS3BucketMain,AwsS3BucketMain2,AwsSqsQueueJobs"
+	if got := strings.Join(sids, ","); got != want {
+		t.Errorf("Sids = %s, want %s", got, want)
+	}
+	if p.Statement[1].Sources[0].Location.File != "modules/a/main.tf" {
+		t.Errorf("AwsS3BucketMain is from %s, want modules/a/main.tf", p.Statement[1].Sources[0].Location.File)
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "aws_s3_bucket", Name: "logs"},
+		{Type: "aws_sqs_queue", Name: "jobs"},
+	}
+
+	p, err := New().Generate(resources)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	meta := p.Metadata()
+	want := map[string]mapping.Provenance{
+		"s3:CreateBucket": {Kind: mapping.ProvenanceSchema, CfnType: "AWS::S3::Bucket"},
+		"sqs:CreateQueue": {Kind: mapping.ProvenanceFallback},
+	}
+	for action, provenance := range want {
+		sources := meta.Actions[action]
+		if len(sources) != 1 || sources[0].Provenance != provenance {
+			t.Errorf("metadata of %s = %+v, want provenance %+v", action, sources, provenance)
+		}
+	}
+
+	tf := p.ToTerraform()
+	for _, comment := range []string{"# provenance: cfn-schema AWS::S3::Bucket\n", "# provenance: fallback\n"} {
+		if !strings.Contains(tf, comment) {
+			t.Errorf("ToTerraform() missing %q", comment)
+		}
+	}
+}
+
+func TestTerraformSourceComments(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "aws_s3_bucket", Name: "logs", Location: provider.SourceLocation{File: "s3.tf", Line: 12}},
+		{Type: "aws_sqs_queue", Name: "jobs", Module: "module.queue", Location: provider.SourceLocation{File: "modules/queue/main.tf", Line: 1}},
+	}
+
+	p, err := New().Generate(resources)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	tf := p.ToTerraform()
+	for _, comment := range []string{
+		"  # from aws_s3_bucket.logs (s3.tf:12)\n  statement {\n",
+		"  # from module.queue.aws_sqs_queue.jobs (modules/queue/main.tf:1)\n  statement {\n",
+	} {
+		if !strings.Contains(tf, comment) {
+			t.Errorf("ToTerraform() missing %q", comment)
+		}
+	}
+}
+
+func TestMetadataResources(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "aws_s3_bucket", Name: "logs", Location: provider.SourceLocation{File: "s3.tf", Line: 12}},
+		{Type: "aws_sqs_queue", Name: "jobs", Module: "module.queue", Location: provider.SourceLocation{File: "modules/queue/main.tf", Line: 1}},
+	}
+
+	p, err := New().Generate(resources)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	meta := p.Metadata()
+	sources := meta.Actions["sqs:CreateQueue"]
+	want := ActionSource{
+		Resource:   "module.queue.aws_sqs_queue.jobs",
+		Type:       "aws_sqs_queue",
+		Name:       "jobs",
+		Module:     "module.queue",
+		File:       "modules/queue/main.tf",
+		Line:       1,
+		Provenance: mapping.Provenance{Kind: mapping.ProvenanceFallback},
+	}
+	if len(sources) != 1 || sources[0] != want {
+		t.Errorf("sources of sqs:CreateQueue = %+v, want %+v", sources, want)
+	}
+	if sources := meta.Actions["s3:CreateBucket"]; len(sources) != 1 || sources[0].File != "s3.tf" || sources[0].Line != 12 {
+		t.Errorf("sources of s3:CreateBucket = %+v, want s3.tf:12", sources)
+	}
+	if got := meta.Resources["aws_s3_bucket.logs"]; strings.Join(got, ",") != "AwsS3BucketLogs" {
+		t.Errorf("statements of aws_s3_bucket.logs = %v, want [AwsS3BucketLogs]", got)
+	}
+	if got := meta.Resources["module.queue.aws_sqs_queue.jobs"]; len(got) != 1 {
+		t.Errorf("statements of module.queue.aws_sqs_queue.jobs = %v, want one", got)
+	}
+}
+
+func TestUncovered(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "aws_mq_broker", Name: "jobs", CloudProvider: "aws"},
+		{Type: "aws_elastictranscoder_pipeline", Name: "media", CloudProvider: "aws"},
+		{Type: "aws_mq_broker", Name: "events", CloudProvider: "aws"},
+		{Type: "aws_s3_bucket", Name: "logs", CloudProvider: "aws"},
+		{Type: "google_workflows_workflow", Name: "etl", CloudProvider: "gcp"},
+	}
+
+	p, err := New().Generate(resources)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	want := []UncoveredType{
+		{Type: "aws_mq_broker", Resources: []string{"aws_mq_broker.events", "aws_mq_broker.jobs"}},
+		{Type: "aws_elastictranscoder_pipeline", Resources: []string{"aws_elastictranscoder_pipeline.media"}},
+	}
+	got := p.Metadata().Uncovered
+	if len(got) != len(want) {
+		t.Fatalf("Uncovered = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Type != want[i].Type || strings.Join(got[i].Resources, ",") != strings.Join(want[i].Resources, ",") {
+			t.Errorf("Uncovered[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestWidenReferences(t *testing.T) {
+	p := &IAMPolicy{
+		Statement: []Statement{
+			{Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: []string{"arn:aws:s3:::${aws_s3_bucket.logs.id}", "arn:aws:s3:::fixed"}},
+		},
+	}
+
+	if got := p.WidenReferences(); got != 1 {
+		t.Errorf("WidenReferences() = %d, want 1", got)
+	}
+	if got := p.Statement[0].Resource; got[0] != "*" || got[1] != "arn:aws:s3:::fixed" {
+		t.Errorf("Resource = %v", got)
+	}
+}
+
+func TestWildcardResources(t *testing.T) {
+	p, err := New().Generate([]provider.Resource{
+		{Type: "aws_iam_policy_attachment", Name: "ci", CloudProvider: "aws"},
+		{Type: "aws_s3_bucket", Name: "logs", CloudProvider: "aws", Attributes: map[string]interface{}{"bucket": map[string]interface{}{"Literal": "logs"}}},
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	p.Statement = append(p.Statement, Statement{Sid: "ECRAuthorization", Effect: "Allow", Action: []string{"ecr:GetAuthorizationToken"}, Resource: []string{"*"}})
+
+	got := p.WildcardResources()
+	if len(got) != 2 {
+		t.Fatalf("WildcardResources() = %+v, want the attachment and ECR statements", got)
+	}
+	if got[0].Sid != "AwsIamPolicyAttachmentCi" || strings.Join(got[0].Types, ",") != "aws_iam_policy_attachment" || !strings.Contains(got[0].Reason, "no ARN pattern") {
+		t.Errorf("WildcardResources()[0] = %+v", got[0])
+	}
+	if len(got[1].Types) != 0 || !strings.Contains(got[1].Reason, "resource-level permissions") {
+		t.Errorf("WildcardResources()[1] = %+v", got[1])
+	}
+}
+
+func TestToRego(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "aws_sqs_queue", Name: "jobs", Location: provider.SourceLocation{File: "sqs.tf", Line: 1}},
+	}
+
+	p, err := New().Generate(resources)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	rego, err := p.ToRego("least.requirements")
+	if err != nil {
+		t.Fatalf("ToRego failed: %v", err)
+	}
+	for _, want := range []string{
+		"package least.requirements\n",
+		"import rego.v1\n",
+		"\"sqs:CreateQueue\": [\n\t\t\"aws_sqs_queue.jobs (sqs.tf:1)\"\n\t]",
+		"deny contains msg if {",
+	} {
+		if !strings.Contains(rego, want) {
+			t.Errorf("ToRego() does not contain %q:\n%s", want, rego)
+		}
+	}
+}
+
+func TestARNPatternDefault(t *testing.T) {
+	mapping.SetCustomMappings(&mapping.CustomMappings{Mappings: map[string]mapping.CustomMapping{
+		"aws_s3_bucket": {ARN: &mapping.ARNPattern{
+			Pattern:           "arn:aws:s3:::{bucket}",
+			ResourceAttribute: "bucket",
+			ChildPatterns:     []string{"arn:aws:s3:::{bucket}/*"},
+			Default:           "acme-*",
+		}},
+	}})
+	defer mapping.SetCustomMappings(&mapping.CustomMappings{})
+
+	resources := []provider.Resource{
+		{Type: "aws_s3_bucket", Name: "prefixed"},
+		{Type: "aws_s3_bucket", Name: "named", Attributes: map[string]interface{}{
+			"bucket": map[string]interface{}{"Literal": "acme-logs"},
+		}},
+	}
+	p, err := NewWithOptions(GeneratorOptions{OutputFormat: "json"}).Generate(resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"arn:aws:s3:::acme-logs", "arn:aws:s3:::acme-logs/*"},
+		{"arn:aws:s3:::acme-*", "arn:aws:s3:::acme-*/*"},
+	}
+	for i, stmt := range p.Statement {
+		if strings.Join(stmt.Resource, ",") != strings.Join(want[i], ",") {
+			t.Errorf("%s Resource = %v, want %v", stmt.Sid, stmt.Resource, want[i])
+		}
+	}
+}
+
+func TestGenerateIAMResources(t *testing.T) {
+	literal := func(v string) map[string]interface{} {
+		return map[string]interface{}{"Literal": v}
+	}
+
+	tests := []struct {
+		resource     provider.Resource
+		wantAction   string
+		wantResource []string
+	}{
+		{
+			resource:     provider.Resource{Type: "aws_iam_role_policy_attachment", Name: "deploy", Attributes: map[string]interface{}{"role": literal("deploy")}},
+			wantAction:   "iam:AttachRolePolicy",
+			wantResource: []string{"arn:aws:iam::*:role/deploy"},
+		},
+		{
+			resource:     provider.Resource{Type: "aws_iam_user_policy_attachment", Name: "ci", Attributes: map[string]interface{}{"user": literal("ci")}},
+			wantAction:   "iam:DetachUserPolicy",
+			wantResource: []string{"arn:aws:iam::*:user/ci"},
+		},
+		{
+			resource:     provider.Resource{Type: "aws_iam_instance_profile", Name: "web", Attributes: map[string]interface{}{"name": literal("web")}},
+			wantAction:   "iam:AddRoleToInstanceProfile",
+			wantResource: []string{"arn:aws:iam::*:instance-profile/web", "arn:aws:iam::*:role/*"},
+		},
+		{
+			resource:     provider.Resource{Type: "aws_iam_openid_connect_provider", Name: "github"},
+			wantAction:   "iam:CreateOpenIDConnectProvider",
+			wantResource: []string{"arn:aws:iam::*:oidc-provider/*"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resource.Type, func(t *testing.T) {
+			p, err := NewWithOptions(GeneratorOptions{OutputFormat: "json"}).Generate([]provider.Resource{tt.resource})
+			if err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+			if len(p.Statement) != 1 {
+				t.Fatalf("got %d statements, want 1", len(p.Statement))
+			}
+
+			stmt := p.Statement[0]
+			found := false
+			for _, a := range stmt.Action {
+				if a == tt.wantAction {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Action = %v, want it to include %s", stmt.Action, tt.wantAction)
+			}
+			if len(stmt.Resource) != len(tt.wantResource) {
+				t.Fatalf("Resource = %v, want %v", stmt.Resource, tt.wantResource)
+			}
+			for i, r := range stmt.Resource {
+				if r != tt.wantResource[i] {
+					t.Errorf("Resource[%d] = %s, want %s", i, r, tt.wantResource[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFromProviderPolicies(t *testing.T) {
+	policies := []provider.IAMPolicy{
+		{
+			Address: "data.aws_iam_policy_document.trust",
+			Statements: []provider.IAMStatement{{
+				Effect:     "Allow",
+				Actions:    []string{"sts:AssumeRole"},
+				Principals: []provider.IAMPrincipal{{Type: "Service", Identifiers: []string{"lambda.amazonaws.com"}}},
+			}},
+		},
+		{
+			Address: "data.aws_iam_policy_document.app",
+			Statements: []provider.IAMStatement{
+				{Effect: "Allow", Actions: []string{"s3:GetObject"}, Resources: []string{"*"}},
+				{
+					Sid:       "Regional",
+					Effect:    "Allow",
+					Actions:   []string{"sqs:SendMessage"},
+					Resources: []string{"*"},
+					Conditions: []provider.IAMCondition{
+						{Test: "StringEquals", Variable: "aws:RequestedRegion", Values: []string{"us-east-1"}},
+					},
+				},
+			},
+		},
+	}
+
+	p := FromProviderPolicies(policies)
+	if got := p.GetAllActions(); strings.Join(got, ",") != "s3:GetObject,sqs:SendMessage" {
+		t.Errorf("actions = %v, want s3:GetObject and sqs:SendMessage without the trust policy's sts:AssumeRole", got)
+	}
+	if len(p.Statement) != 2 {
+		t.Fatalf("got %d statements, want the combined and the conditional statement", len(p.Statement))
+	}
+	if got := p.Statement[1].Condition["StringEquals"]["aws:RequestedRegion"]; len(got) != 1 || got[0] != "us-east-1" {
+		t.Errorf("condition = %+v, want aws:RequestedRegion us-east-1", p.Statement[1].Condition)
+	}
+}
+
+func TestToSessionPolicy(t *testing.T) {
+	p := &IAMPolicy{
+		Version: "2012-10-17",
+		Statement: []Statement{
+			{Sid: "Bucket", Effect: "Allow", Action: []string{"s3:GetObject", "s3:PutObject"}, Resource: []string{"arn:aws:s3:::app/*"}},
+			{Sid: "Queue", Effect: "Allow", Action: []string{"sqs:SendMessage"}, Resource: []string{"arn:aws:sqs:*:*:${var.queue}"}},
+			{Sid: "Describe", Effect: "Allow", Action: []string{"ec2:DescribeVpcs"}, Resource: []string{"*"}},
+		},
+	}
+
+	got, reductions, err := p.ToSessionPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject","s3:PutObject"],"Resource":"arn:aws:s3:::app/*"},{"Effect":"Allow","Action":["ec2:DescribeVpcs","sqs:SendMessage"],"Resource":"*"}]}`
+	if got != want || len(reductions) != 0 {
+		t.Errorf("ToSessionPolicy() = %s, %v\nwant %s", got, reductions, want)
+	}
+	if p.Statement[1].Resource[0] != "arn:aws:sqs:*:*:${var.queue}" {
+		t.Error("ToSessionPolicy() modified the policy")
+	}
+
+	// Oversized policies are reduced until they fit
+	large := &IAMPolicy{Version: "2012-10-17"}
+	for i := 0; i < 40; i++ {
+		large.Statement = append(large.Statement, Statement{
+			Effect:   "Allow",
+			Action:   []string{"dynamodb:GetItem", "dynamodb:GetRecords", "dynamodb:PutItem"},
+			Resource: []string{fmt.Sprintf("arn:aws:dynamodb:us-east-1:123456789012:table/table-%d", i)},
+		})
+	}
+	got, reductions, err = large.ToSessionPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) > SessionPolicyLimit || len(reductions) != 2 || !strings.Contains(got, `"Action":["dynamodb:Get*","dynamodb:PutItem"],"Resource":"*"`) {
+		t.Errorf("ToSessionPolicy() = %s (%d characters), reductions %v", got, len(got), reductions)
+	}
+}
+
+func TestToPermissionSet(t *testing.T) {
+	p := &IAMPolicy{
+		Version: "2012-10-17",
+		Statement: []Statement{
+			{Sid: "Queue", Effect: "Allow", Action: []string{"sqs:SendMessage"}, Resource: []string{"arn:aws:sqs:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:jobs"}},
+			{Sid: "Describe", Effect: "Allow", Action: []string{"ec2:DescribeVpcs"}, Resource: []string{"*"}},
+		},
+	}
+
+	got, widened, err := p.ToPermissionSet(PermissionSetOptions{Name: "Deploy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if widened != 1 {
+		t.Errorf("widened = %d, want 1", widened)
+	}
+	for _, want := range []string{
+		`name         = "Deploy"`,
+		`permission_set_arn = aws_ssoadmin_permission_set.least_privilege.arn`,
+		`"arn:aws:sqs:*:*:jobs"`,
+		"  EOT\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("ToPermissionSet() missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(p.Statement[0].Resource[0], "*:*") {
+		t.Error("ToPermissionSet() modified the policy")
+	}
+
+	if _, _, err := p.ToPermissionSet(PermissionSetOptions{Name: "least privilege"}); err == nil {
+		t.Error("ToPermissionSet() accepted an invalid name")
+	}
+}
+
+func TestToStackSetExecutionRole(t *testing.T) {
+	p := &IAMPolicy{
+		Version: "2012-10-17",
+		Statement: []Statement{
+			{Sid: "Bucket", Effect: "Allow", Action: []string{"s3:CreateBucket"}, Resource: []string{"arn:aws:s3:::${var.bucket}"}},
+		},
+	}
+
+	got, widened, err := p.ToStackSetExecutionRole()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if widened != 1 {
+		t.Errorf("widened = %d, want 1", widened)
+	}
+	for _, want := range []string{
+		"RoleName: AWSCloudFormationStackSetExecutionRole",
+		":role/AWSCloudFormationStackSetAdministrationRole",
+		`"arn:aws:s3:::*"`,
+		`"Sid": "StackSetInstances"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("ToStackSetExecutionRole() missing %q:\n%s", want, got)
+		}
+	}
+	if len(p.Statement) != 1 {
+		t.Error("ToStackSetExecutionRole() modified the policy")
+	}
+}
+
+func TestToRolesAnywhere(t *testing.T) {
+	p := &IAMPolicy{
+		Version: "2012-10-17",
+		Statement: []Statement{
+			{Sid: "Queue", Effect: "Allow", Action: []string{"sqs:SendMessage"}, Resource: []string{"arn:aws:sqs:*:*:${var.queue}"}},
+		},
+	}
+
+	got, reductions, err := p.ToRolesAnywhere(TerraformOutputOptions{Name: "ignored"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reductions) != 0 {
+		t.Errorf("reductions = %v, want none", reductions)
+	}
+	for _, want := range []string{
+		`data "aws_iam_policy_document" "least_privilege" {`,
+		`"arn:aws:sqs:*:*:${var.queue}"`,
+		`identifiers = ["rolesanywhere.amazonaws.com"]`,
+		`values   = [var.trust_anchor_arn]`,
+		`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"sqs:SendMessage","Resource":"*"}]}`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("ToRolesAnywhere() missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestGenerateKMSKeys(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "aws_sqs_queue", Name: "jobs", CloudProvider: "aws", KMSKeys: []string{"aws_kms_key.data"}},
+		{Type: "aws_sns_topic", Name: "events", CloudProvider: "aws", KMSKeys: []string{"module.app.aws_kms_key.app", "alias/app"}},
+		{Type: "aws_ebs_volume", Name: "disk", CloudProvider: "aws", KMSKeys: []string{"1234abcd", "arn:aws:kms:us-east-1:123456789012:alias/disk"}},
+	}
+
+	for _, tt := range []struct {
+		format string
+		want   []string
+	}{
+		{"terraform", []string{
+			"${aws_kms_key.data.arn}",
+			"arn:aws:kms:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:key/*",
+			"arn:aws:kms:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:key/1234abcd",
+			"arn:aws:kms:us-east-1:123456789012:key/*",
+		}},
+		{"json", []string{
+			"arn:aws:kms:*:*:key/*",
+			"arn:aws:kms:*:*:key/1234abcd",
+			"arn:aws:kms:us-east-1:123456789012:key/*",
+		}},
+	} {
+		gen := NewWithOptions(GeneratorOptions{
+			OutputFormat: tt.format,
+			AccountRef:   "${data.aws_caller_identity.current.account_id}",
+			RegionRef:    "${data.aws_region.current.name}",
+		})
+		p, err := gen.Generate(resources)
+		if err != nil {
+			t.Fatal(err)
+		}
+		last := p.Statement[len(p.Statement)-1]
+		if last.Sid != KMSKeysSid || len(last.Sources) != 3 {
+			t.Fatalf("%s: last statement = %+v, want %s with 3 sources", tt.format, last, KMSKeysSid)
+		}
+		if strings.Join(last.Resource, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: key ARNs =\n%s\nwant\n%s", tt.format, strings.Join(last.Resource, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+
+	stmt, ok := (&IAMPolicy{}).KMSKeyPolicyStatement("arn:aws:iam::123456789012:role/deploy")
+	if ok {
+		t.Errorf("KMSKeyPolicyStatement() without keys = %+v", stmt)
+	}
+}
+
+func TestGenerateWiring(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "aws_sqs_queue", Name: "jobs", CloudProvider: "aws", Module: "module.app", Attributes: map[string]interface{}{"name": map[string]interface{}{"Literal": "jobs"}}},
+		{Type: "aws_lambda_function", Name: "worker", CloudProvider: "aws", Module: "module.app"},
+		{Type: "aws_lambda_event_source_mapping", Name: "jobs", CloudProvider: "aws", Module: "module.app",
+			References: []string{"module.app.aws_lambda_function.worker", "module.app.aws_sqs_queue.jobs", "module.app.aws_iam_role.unknown"}},
+	}
+
+	p, err := NewWithOptions(GeneratorOptions{OutputFormat: "json"}).Generate(resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wiring *Statement
+	for i := range p.Statement {
+		if p.Statement[i].Sid == "AwsLambdaEventSourceMappingJobsTargets" {
+			wiring = &p.Statement[i]
+		}
+	}
+	if wiring == nil {
+		t.Fatalf("no wiring statement in %+v", p.Statement)
+	}
+	if got := strings.Join(wiring.Action, ","); got != "lambda:GetFunction,sqs:GetQueueAttributes" {
+		t.Errorf("wiring actions = %s", got)
+	}
+	if got := strings.Join(wiring.Resource, ","); got != "arn:aws:lambda:*:*:function:*,arn:aws:sqs:*:*:jobs" {
+		t.Errorf("wiring resources = %s", got)
+	}
+}
+
+func TestExecutionRolePolicy(t *testing.T) {
+	task := provider.Resource{
+		Type: "aws_ecs_task_definition", Name: "app", CloudProvider: "aws",
+		Attributes: map[string]interface{}{
+			provider.ContainerDefinitionsAttribute: []provider.ContainerDefinition{
+				{
+					Image:    "${aws_ecr_repository.app.repository_url}",
+					LogGroup: "${aws_cloudwatch_log_group.app.name}",
+					Secrets:  []string{"arn:aws:secretsmanager:us-east-1:123456789012:secret:db-AbCdEf:password::", "/app/token"},
+				},
+				{
+					Image:            "public.ecr.aws/nginx/nginx:latest",
+					LogGroup:         "/ecs/sidecar",
+					CreateLogGroup:   true,
+					EnvironmentFiles: []string{"arn:aws:s3:::config/app.env"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		format string
+		want   map[string][]string
+	}{
+		{"terraform", map[string][]string{
+			"ECRAuthorization":       {"*"},
+			"ECRPull":                {"${aws_ecr_repository.app.arn}"},
+			"LogGroups":              {"arn:aws:logs:us-east-1:123456789012:log-group:/ecs/sidecar"},
+			"LogStreams":             {"${aws_cloudwatch_log_group.app.arn}:log-stream:*", "arn:aws:logs:us-east-1:123456789012:log-group:/ecs/sidecar:log-stream:*"},
+			"Secrets":                {"arn:aws:secretsmanager:us-east-1:123456789012:secret:db-AbCdEf"},
+			"Parameters":             {"arn:aws:ssm:us-east-1:123456789012:parameter/app/token"},
+			"EnvironmentFiles":       {"arn:aws:s3:::config/app.env"},
+			"EnvironmentFileBuckets": {"arn:aws:s3:::config"},
+		}},
+		{"json", map[string][]string{
+			"ECRAuthorization":       {"*"},
+			"ECRPull":                {"arn:aws:ecr:*:*:repository/*"},
+			"LogGroups":              {"arn:aws:logs:*:*:log-group:/ecs/sidecar"},
+			"LogStreams":             {"arn:aws:logs:*:*:log-group:*:log-stream:*", "arn:aws:logs:*:*:log-group:/ecs/sidecar:log-stream:*"},
+			"Secrets":                {"arn:aws:secretsmanager:us-east-1:123456789012:secret:db-AbCdEf"},
+			"Parameters":             {"arn:aws:ssm:*:*:parameter/app/token"},
+			"EnvironmentFiles":       {"arn:aws:s3:::config/app.env"},
+			"EnvironmentFileBuckets": {"arn:aws:s3:::config"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			gen := NewWithOptions(GeneratorOptions{OutputFormat: tt.format, AccountRef: "123456789012", RegionRef: "us-east-1"})
+			got := make(map[string][]string)
+			for _, stmt := range gen.ExecutionRolePolicy(task).Statement {
+				got[stmt.Sid] = stmt.Resource
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resources = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteTemplate(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "aws_sqs_queue", Name: "jobs", Location: provider.SourceLocation{File: "sqs.tf", Line: 4}},
+	}
+	p, err := NewWithOptions(GeneratorOptions{OutputFormat: "json"}).Generate(resources)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	tmpl, err := ParseTemplate("table", `{{range .Statement}}| {{.Sid}} | {{service (index .Action 0)}} | {{range .Sources}}{{.Address}} ({{.Location}}){{end}} |
+{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.ExecuteTemplate(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "| AwsSqsQueueJobs | sqs | aws_sqs_queue.jobs (sqs.tf:4) |\n"; got != want {
+		t.Errorf("ExecuteTemplate() = %q, want %q", got, want)
+	}
+
+	if _, err := ParseTemplate("bad", "{{range .Statement}"); err == nil {
+		t.Error("ParseTemplate() of an unterminated action succeeded")
+	}
+}
+
+func TestAddRequirements(t *testing.T) {
+	state := provider.SourceLocation{File: "backend.tf", Line: 2}
+	p := &IAMPolicy{Statement: []Statement{{Sid: "ProviderAuth", Effect: "Allow", Action: StringList{"sqs:CreateQueue"}}}}
+	p.AddRequirements([]provider.Requirement{
+		{Kind: provider.RequirementRegistry, Description: "module.vpc source", Actions: []string{"s3:GetObject"}, Resources: []string{"arn:aws:s3:::modules/vpc.zip"}},
+		{Kind: provider.RequirementState, Description: "S3 backend bucket", Actions: []string{"s3:ListBucket"}, Resources: []string{"arn:aws:s3:::state"}, Location: state},
+		{Kind: provider.RequirementState, Description: "S3 backend state", Actions: []string{"s3:PutObject", "s3:GetObject"}, Resources: []string{"arn:aws:s3:::state/app.tfstate"}, Location: state},
+		{Kind: provider.RequirementState, Description: "S3 backend state", Actions: []string{"s3:GetObject", "s3:PutObject"}, Resources: []string{"arn:aws:s3:::state/db.tfstate"}},
+		{Kind: provider.RequirementAuth, Description: "aws provider role", Actions: []string{"sts:AssumeRole"}, Resources: []string{"arn:aws:iam::123456789012:role/deploy"}},
+	})
+
+	var sids []string
+	for _, stmt := range p.Statement {
+		sids = append(sids, stmt.Sid)
+	}
+	if want := []string{"ProviderAuth", "BackendState", "BackendState2", "ProviderAuth2", "ModuleRegistry"}; !reflect.DeepEqual(sids, want) {
+		t.Fatalf("Sids = %v, want %v", sids, want)
+	}
+	objects := p.Statement[2]
+	if !reflect.DeepEqual([]string(objects.Action), []string{"s3:GetObject", "s3:PutObject"}) ||
+		!reflect.DeepEqual([]string(objects.Resource), []string{"arn:aws:s3:::state/app.tfstate", "arn:aws:s3:::state/db.tfstate"}) ||
+		len(objects.Requirements) != 2 {
+		t.Errorf("state statement = %+v, want both state objects", objects)
+	}
+
+	tf := p.ToTerraform()
+	if !strings.Contains(tf, "  # for S3 backend bucket (backend.tf:2)\n  statement {\n    sid    = \"BackendState\"") {
+		t.Errorf("ToTerraform() does not comment the requirement of a statement:\n%s", tf)
+	}
+}
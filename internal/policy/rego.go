@@ -0,0 +1,73 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// regoRules checks an IAM policy document given as input against the
+// required actions. Wildcards in granted actions are matched with glob.match,
+// and actions are compared case-insensitively, as IAM does.
+const regoRules = `
+# statements are the statements of the checked policy document
+statements := input.Statement if is_array(input.Statement)
+
+statements := [input.Statement] if is_object(input.Statement)
+
+actions(stmt) := stmt.Action if is_array(stmt.Action)
+
+actions(stmt) := [stmt.Action] if is_string(stmt.Action)
+
+# granted checks if an Allow statement of the policy grants an action
+granted(action) if {
+	some stmt in statements
+	stmt.Effect == "Allow"
+	some pattern in actions(stmt)
+	glob.match(lower(pattern), [], lower(action))
+}
+
+deny contains msg if {
+	some action, resources in required
+	not granted(action)
+	msg := sprintf("%s is required by %s but not granted", [action, concat(", ", resources)])
+}
+`
+
+// ToRego converts the required actions of the policy to a Rego module in
+// package pkg, usable as a conftest policy: its deny rule reports every
+// required action that an IAM policy document given as input does not grant.
+func (p *IAMPolicy) ToRego(pkg string) (string, error) {
+	required := make(map[string][]string)
+	for _, stmt := range p.Statement {
+		if stmt.Effect != "Allow" {
+			continue
+		}
+		for _, action := range stmt.Action {
+			if _, ok := required[action]; !ok {
+				required[action] = []string{}
+			}
+			for _, res := range stmt.Sources {
+				source := res.Address()
+				if loc := res.Location.String(); loc != "" {
+					source += " (" + loc + ")"
+				}
+				required[action] = append(required[action], source)
+			}
+		}
+	}
+
+	// A JSON object is a valid Rego object literal
+	data, err := json.MarshalIndent(required, "", "\t")
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("# Generated by least from IaC code. Do not edit.\n")
+	fmt.Fprintf(&b, "package %s\n\nimport rego.v1\n\n", pkg)
+	b.WriteString("# required maps the actions the IaC resources require to the resources\n")
+	fmt.Fprintf(&b, "required := %s\n", data)
+	b.WriteString(regoRules)
+	return b.String(), nil
+}
@@ -0,0 +1,72 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mizzy/least/internal/mapping"
+	"github.com/mizzy/least/internal/provider"
+)
+
+// requirementSids are the Sids of the statements of each requirement kind,
+// in the order they are added
+var requirementSids = []struct {
+	kind string
+	sid  string
+}{
+	{provider.RequirementState, "BackendState"},
+	{provider.RequirementAuth, "ProviderAuth"},
+	{provider.RequirementRegistry, "ModuleRegistry"},
+}
+
+// AddRequirements appends statements granting the permissions the IaC tool
+// itself needs. Requirements of a kind needing the same actions share a
+// statement, in the order they are first required; statements of a kind
+// after the first are numbered.
+func (p *IAMPolicy) AddRequirements(reqs []provider.Requirement) {
+	sids := make(map[string]bool)
+	for _, stmt := range p.Statement {
+		sids[stmt.Sid] = true
+	}
+
+	for _, k := range requirementSids {
+		var keys []string
+		groups := make(map[string]*Statement)
+		for _, req := range reqs {
+			if req.Kind != k.kind || len(req.Actions) == 0 {
+				continue
+			}
+			actions := uniqueSorted(req.Actions)
+			key := strings.Join(actions, ",")
+			stmt, ok := groups[key]
+			if !ok {
+				stmt = &Statement{
+					Effect:     "Allow",
+					Action:     actions,
+					Provenance: mapping.Provenance{Kind: mapping.ProvenanceFallback},
+				}
+				groups[key] = stmt
+				keys = append(keys, key)
+			}
+			stmt.Resource = uniqueSorted(append(stmt.Resource, req.Resources...))
+			stmt.Requirements = append(stmt.Requirements, req)
+		}
+
+		n := 0
+		for _, key := range keys {
+			stmt := groups[key]
+			for {
+				n++
+				stmt.Sid = k.sid
+				if n > 1 {
+					stmt.Sid = fmt.Sprintf("%s%d", k.sid, n)
+				}
+				if !sids[stmt.Sid] {
+					break
+				}
+			}
+			sids[stmt.Sid] = true
+			p.Statement = append(p.Statement, *stmt)
+		}
+	}
+}
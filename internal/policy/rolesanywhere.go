@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// rolesAnywhereTemplate provisions a role that IAM Roles Anywhere sessions
+// of a trust anchor can assume, and a profile for it. The role's policy is
+// the aws_iam_policy_document data source written before it.
+const rolesAnywhereTemplate = `variable "trust_anchor_arn" {
+  description = "ARN of the Roles Anywhere trust anchor of the certificate authority issuing the runner certificates"
+  type        = string
+}
+
+data "aws_iam_policy_document" "least_privilege_trust" {
+  statement {
+    effect  = "Allow"
+    actions = ["sts:AssumeRole", "sts:SetSourceIdentity", "sts:TagSession"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["rolesanywhere.amazonaws.com"]
+    }
+
+    condition {
+      test     = "ArnEquals"
+      variable = "aws:SourceArn"
+      values   = [var.trust_anchor_arn]
+    }
+  }
+}
+
+resource "aws_iam_role" "least_privilege" {
+  name               = "least-privilege"
+  assume_role_policy = data.aws_iam_policy_document.least_privilege_trust.json
+}
+
+resource "aws_iam_role_policy" "least_privilege" {
+  role   = aws_iam_role.least_privilege.id
+  policy = data.aws_iam_policy_document.least_privilege.json
+}
+
+resource "aws_rolesanywhere_profile" "least_privilege" {
+  name      = "least-privilege"
+  enabled   = true
+  role_arns = [aws_iam_role.least_privilege.arn]
+
+  # Also limits the sessions, should the role be given broader policies
+  session_policy = <<-EOT
+    %s
+  EOT
+}
+`
+
+// ToRolesAnywhere converts the policy to Terraform HCL for deploying from
+// outside AWS with IAM Roles Anywhere: the policy document, a role trusting
+// Roles Anywhere sessions of the trust anchor given by the trust_anchor_arn
+// variable, and a profile with the policy as its session policy. The session
+// policy is built by ToSessionPolicy, whose reductions are returned.
+func (p *IAMPolicy) ToRolesAnywhere(opts TerraformOutputOptions) (string, []string, error) {
+	session, reductions, err := p.ToSessionPolicy()
+	if err != nil {
+		return "", reductions, err
+	}
+
+	opts.Name = "least_privilege"
+	var b strings.Builder
+	b.WriteString(p.ToTerraformWithOptions(opts))
+	b.WriteString("\n")
+	// Escape template sequences so Terraform takes the JSON literally
+	escaped := strings.NewReplacer("${", "$${", "%{", "%%{").Replace(session)
+	fmt.Fprintf(&b, rolesAnywhereTemplate, escaped)
+	return b.String(), reductions, nil
+}
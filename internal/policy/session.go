@@ -0,0 +1,195 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SessionPolicyLimit is the maximum size of an inline session policy (the
+// Policy parameter of sts:AssumeRole), in characters of its JSON
+const SessionPolicyLimit = 2048
+
+// ToSessionPolicy converts the policy to compact JSON for an inline session
+// policy. Sids are dropped and statements with the same resources and
+// conditions are merged. While the document exceeds SessionPolicyLimit,
+// it is reduced further, at the cost of granting more: actions sharing a
+// service and verb are collapsed into a wildcard (e.g., s3:Get*), then all
+// actions are granted on every resource. The reductions applied are
+// returned with the document; a document still over the limit is an error.
+func (p *IAMPolicy) ToSessionPolicy() (string, []string, error) {
+	var statements []Statement
+	for _, stmt := range p.Statement {
+		stmt.Sid = ""
+		stmt.Resource = sessionResources(stmt.Resource)
+		statements = append(statements, stmt)
+	}
+	statements = mergeStatements(statements)
+
+	var reductions []string
+	document, err := sessionJSON(statements)
+	if err != nil || len(document) <= SessionPolicyLimit {
+		return document, reductions, err
+	}
+
+	for i := range statements {
+		statements[i].Action = collapseVerbs(statements[i].Action)
+	}
+	statements = mergeStatements(statements)
+	reductions = append(reductions, "actions sharing a service and verb collapsed into wildcards (e.g., s3:Get*)")
+	if document, err = sessionJSON(statements); err != nil || len(document) <= SessionPolicyLimit {
+		return document, reductions, err
+	}
+
+	for i := range statements {
+		if len(statements[i].Condition) == 0 {
+			statements[i].Resource = []string{"*"}
+		}
+	}
+	statements = mergeStatements(statements)
+	reductions = append(reductions, `resources widened to "*"`)
+	if document, err = sessionJSON(statements); err != nil {
+		return "", reductions, err
+	}
+	if len(document) > SessionPolicyLimit {
+		return "", reductions, fmt.Errorf("session policy is %d characters, over the %d limit even after reductions", len(document), SessionPolicyLimit)
+	}
+	return document, reductions, nil
+}
+
+// sessionResources replaces resources containing Terraform references, which
+// a session policy cannot resolve, with "*"
+func sessionResources(resources []string) []string {
+	result := make([]string, 0, len(resources))
+	for _, r := range resources {
+		if strings.Contains(r, "${") {
+			return []string{"*"}
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// mergeStatements merges statements with the same effect, resources and
+// conditions, in the order they first appear, with sorted unique actions
+func mergeStatements(statements []Statement) []Statement {
+	var merged []Statement
+	index := make(map[string]int)
+	for _, stmt := range statements {
+		key, _ := json.Marshal([]interface{}{stmt.Effect, stmt.NotAction, stmt.Resource, stmt.NotResource, stmt.Condition})
+		i, ok := index[string(key)]
+		if !ok {
+			i = len(merged)
+			index[string(key)] = i
+			stmt.Action = append([]string(nil), stmt.Action...)
+			merged = append(merged, stmt)
+			continue
+		}
+		merged[i].Action = append(merged[i].Action, stmt.Action...)
+	}
+
+	for i := range merged {
+		merged[i].Action = uniqueSorted(merged[i].Action)
+	}
+	return merged
+}
+
+// collapseVerbs replaces actions sharing a service and verb, the leading
+// capitalized word of the action name, with a wildcard. Actions alone in
+// their group are kept.
+func collapseVerbs(actions []string) []string {
+	groups := make(map[string][]string)
+	var keys []string
+	for _, action := range actions {
+		service, name, ok := strings.Cut(action, ":")
+		if !ok || strings.Contains(name, "*") {
+			keys = append(keys, action)
+			groups[action] = []string{action}
+			continue
+		}
+		key := service + ":" + verbOf(name) + "*"
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], action)
+	}
+
+	var collapsed []string
+	for _, key := range keys {
+		if len(groups[key]) == 1 {
+			collapsed = append(collapsed, groups[key][0])
+		} else {
+			collapsed = append(collapsed, key)
+		}
+	}
+	return collapsed
+}
+
+// verbOf returns the leading capitalized word of an action name
+// (e.g., Get for GetBucketPolicy)
+func verbOf(name string) string {
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) {
+			return name[:i]
+		}
+	}
+	return name
+}
+
+// uniqueSorted returns the sorted distinct strings
+func uniqueSorted(values []string) []string {
+	sort.Strings(values)
+	result := values[:0]
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// sessionJSON marshals statements as a compact policy document, with single
+// values written as strings rather than arrays
+func sessionJSON(statements []Statement) (string, error) {
+	type sessionStatement struct {
+		Effect      string      `json:"Effect"`
+		Action      interface{} `json:"Action,omitempty"`
+		NotAction   interface{} `json:"NotAction,omitempty"`
+		Resource    interface{} `json:"Resource,omitempty"`
+		NotResource interface{} `json:"NotResource,omitempty"`
+		Condition   Condition   `json:"Condition,omitempty"`
+	}
+	compact := func(values []string) interface{} {
+		switch len(values) {
+		case 0:
+			return nil
+		case 1:
+			return values[0]
+		default:
+			return values
+		}
+	}
+
+	doc := struct {
+		Version   string             `json:"Version"`
+		Statement []sessionStatement `json:"Statement"`
+	}{Version: "2012-10-17", Statement: make([]sessionStatement, 0, len(statements))}
+	for _, stmt := range statements {
+		doc.Statement = append(doc.Statement, sessionStatement{
+			Effect:      stmt.Effect,
+			Action:      compact(stmt.Action),
+			NotAction:   compact(stmt.NotAction),
+			Resource:    compact(stmt.Resource),
+			NotResource: compact(stmt.NotResource),
+			Condition:   stmt.Condition,
+		})
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,79 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RoleInlinePolicyLimit is the maximum total size of the inline policies of
+// an IAM role, in characters of their JSON without whitespace
+const RoleInlinePolicyLimit = 10240
+
+// stackSetOperations lets CloudFormation manage the stack instances of stack
+// sets, named StackSet-<stack set name>-<id>, in target accounts
+var stackSetOperations = Statement{
+	Sid:      "StackSetInstances",
+	Effect:   "Allow",
+	Action:   []string{"cloudformation:*"},
+	Resource: []string{"arn:aws:cloudformation:*:*:stack/StackSet-*"},
+}
+
+// stackSetTemplate is the CloudFormation template of a self-managed stack
+// set execution role, as AWS publishes it, with the policy in place of
+// AdministratorAccess
+const stackSetTemplate = `AWSTemplateFormatVersion: "2010-09-09"
+Description: Least-privilege execution role for self-managed stack sets
+
+Parameters:
+  AdministratorAccountId:
+    Type: String
+    Description: ID of the account whose AWSCloudFormationStackSetAdministrationRole manages the stack sets
+    AllowedPattern: "[0-9]{12}"
+
+Resources:
+  ExecutionRole:
+    Type: AWS::IAM::Role
+    Properties:
+      RoleName: AWSCloudFormationStackSetExecutionRole
+      AssumeRolePolicyDocument:
+        Version: "2012-10-17"
+        Statement:
+          - Effect: Allow
+            Principal:
+              AWS: !Sub arn:${AWS::Partition}:iam::${AdministratorAccountId}:role/AWSCloudFormationStackSetAdministrationRole
+            Action: sts:AssumeRole
+      Policies:
+        - PolicyName: LeastPrivilege
+          PolicyDocument:
+%s`
+
+// ToStackSetExecutionRole converts the policy to a CloudFormation template
+// of the AWSCloudFormationStackSetExecutionRole of self-managed stack sets:
+// the role trusts the administration role of the account given as a template
+// parameter, and its inline policy grants the policy plus the management of
+// stack set instances. Stack sets deploy into many accounts, so Terraform
+// references in resources are replaced with "*"; the number of resources
+// changed is returned with the template.
+func (p *IAMPolicy) ToStackSetExecutionRole() (string, int, error) {
+	doc, widened := p.withoutReferences()
+	doc.Statement = append(doc.Statement, stackSetOperations)
+
+	compact, err := sessionJSON(doc.Statement)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(compact) > RoleInlinePolicyLimit {
+		return "", 0, fmt.Errorf("execution role policy is %d characters, over the %d limit of role inline policies", len(compact), RoleInlinePolicyLimit)
+	}
+
+	document, err := doc.ToJSON()
+	if err != nil {
+		return "", 0, err
+	}
+	// JSON is YAML, so the document is indented under PolicyDocument as is
+	var b strings.Builder
+	for _, line := range strings.Split(document, "\n") {
+		b.WriteString("            " + line + "\n")
+	}
+	return fmt.Sprintf(stackSetTemplate, b.String()), widened, nil
+}
@@ -0,0 +1,51 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are the functions output templates can call besides the
+// text/template builtins
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	// service returns the service prefix of an action (e.g., "s3")
+	"service": func(action string) string {
+		service, _, _ := strings.Cut(action, ":")
+		return service
+	},
+	"json": func(v any) (string, error) {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	},
+}
+
+// ParseTemplate parses a text/template for rendering policies. Besides the
+// builtins, templates can call join, lower, upper, service (the service
+// prefix of an action) and json (a value as indented JSON).
+func ParseTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template: %w", err)
+	}
+	return t, nil
+}
+
+// ExecuteTemplate renders the policy with a template from ParseTemplate.
+// The template is executed on the policy: its Version and Statement, the
+// Sources (resources with Type, Name, Module and Location) and Provenance of
+// each statement, and methods such as GetAllActions and Metadata.
+func (p *IAMPolicy) ExecuteTemplate(t *template.Template) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, p); err != nil {
+		return "", fmt.Errorf("executing template: %w", err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,96 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mizzy/least/internal/mapping"
+)
+
+// unscopedActions are generated actions that do not support resource-level
+// permissions, so they are only granted on "*"
+var unscopedActions = map[string]bool{
+	"ecr:GetAuthorizationToken": true,
+}
+
+// WildcardResource is an Allow statement granting actions on Resource "*"
+type WildcardResource struct {
+	Sid string
+	// Types are the resource types whose ARN could not be constructed
+	Types []string
+	// Reason is why the statement is granted on "*"
+	Reason string
+}
+
+// String returns a human-readable description of the statement
+func (w WildcardResource) String() string {
+	if len(w.Types) == 0 {
+		return fmt.Sprintf("%s: %s", w.Sid, w.Reason)
+	}
+	return fmt.Sprintf("%s (%s): %s", w.Sid, strings.Join(w.Types, ", "), w.Reason)
+}
+
+// WildcardResources returns the Allow statements of a generated policy
+// granting actions on Resource "*", and why their ARNs could not be
+// constructed
+func (p *IAMPolicy) WildcardResources() []WildcardResource {
+	var wildcards []WildcardResource
+	for i, stmt := range p.Statement {
+		if stmt.Effect != "Allow" || !slices.Contains(stmt.Resource, "*") {
+			continue
+		}
+		w := WildcardResource{Sid: stmt.Sid}
+		if w.Sid == "" {
+			w.Sid = fmt.Sprintf("statement #%d", i+1)
+		}
+
+		switch {
+		case allUnscoped(stmt.Action):
+			w.Reason = fmt.Sprintf("%s cannot be scoped to resources (no resource-level permissions)", strings.Join(stmt.Action, ", "))
+		case len(stmt.Sources) > 0:
+			w.Types = unpatternedTypes(stmt)
+			w.Reason = "no ARN pattern is mapped for the resource type; add one with least mappings add --arn"
+		default:
+			w.Reason = "the statement is granted on every resource"
+		}
+		wildcards = append(wildcards, w)
+	}
+	return wildcards
+}
+
+// allUnscoped checks if none of the actions support resource-level
+// permissions
+func allUnscoped(actions []string) bool {
+	for _, action := range actions {
+		if !unscopedActions[action] {
+			return false
+		}
+	}
+	return len(actions) > 0
+}
+
+// unpatternedTypes returns the types without an ARN pattern of the source of
+// a statement and, for wiring statements, of the resources it refers to
+func unpatternedTypes(stmt Statement) []string {
+	var types []string
+	for _, res := range stmt.Sources {
+		if _, ok := mapping.GetARNPattern(res.Type); !ok {
+			types = append(types, res.Type)
+		}
+		for _, ref := range res.References {
+			parts := strings.Split(ref, ".")
+			if len(parts) < 2 {
+				continue
+			}
+			target := parts[len(parts)-2]
+			if mapping.GetWiringActions(res.Type, target) == nil {
+				continue
+			}
+			if _, ok := mapping.GetARNPattern(target); !ok {
+				types = append(types, target)
+			}
+		}
+	}
+	return uniqueSorted(types)
+}
@@ -0,0 +1,267 @@
+// Package policysentry resolves resource permission mappings from the IAM
+// definition dataset of policy_sentry (iam-definition.json), which lists the
+// actions, access levels and resource types of every AWS service.
+//
+// It fills gaps for resource types that have neither a built-in mapping nor
+// a CloudFormation schema. Terraform types are matched to dataset resources
+// by name (aws_glue_job is the job resource of glue), and actions are
+// assigned to operations by access level and verb.
+package policysentry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/mapping"
+)
+
+// Access levels of dataset privileges, in the form used by SetAccessLevels
+const (
+	AccessRead                  = "read"
+	AccessList                  = "list"
+	AccessWrite                 = "write"
+	AccessTagging               = "tagging"
+	AccessPermissionsManagement = "permissions-management"
+)
+
+// DefaultAccessLevels excludes permissions management, whose actions (e.g.,
+// s3:PutBucketPolicy) are rarely needed to manage a resource and easily
+// escalate privileges
+var DefaultAccessLevels = []string{AccessRead, AccessList, AccessWrite, AccessTagging}
+
+// accessLevels lists all access levels
+var accessLevels = []string{AccessRead, AccessList, AccessWrite, AccessTagging, AccessPermissionsManagement}
+
+// Dataset is a loaded policy_sentry IAM definition
+type Dataset struct {
+	services map[string]service
+	levels   map[string]bool
+}
+
+// service is the IAM definition of an AWS service
+type service struct {
+	Prefix     string               `json:"prefix"`
+	Privileges map[string]privilege `json:"privileges"`
+	Resources  map[string]resource  `json:"resources"`
+}
+
+// privilege is an IAM action
+type privilege struct {
+	Privilege     string                  `json:"privilege"`
+	AccessLevel   string                  `json:"access_level"`
+	ResourceTypes map[string]resourceType `json:"resource_types"`
+}
+
+// resourceType is a resource an action applies to; a trailing * in the
+// name marks it as required
+type resourceType struct {
+	ResourceType string `json:"resource_type"`
+}
+
+// resource is a resource type of a service
+type resource struct {
+	Resource string `json:"resource"`
+	ARN      string `json:"arn"`
+}
+
+// serviceAliases maps Terraform service names, as they appear after "aws_",
+// to IAM service prefixes where they differ
+var serviceAliases = map[string]string{
+	"cloudwatch_event": "events",
+	"cloudwatch_log":   "logs",
+	"cognito":          "cognito-idp",
+	"db":               "rds",
+	"efs":              "elasticfilesystem",
+	"elasticsearch":    "es",
+	"emr":              "elasticmapreduce",
+	"lb":               "elasticloadbalancing",
+	"opensearch":       "es",
+	"sfn":              "states",
+}
+
+// Load reads a policy_sentry IAM definition file
+func Load(path string) (*Dataset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading policy_sentry dataset: %w", err)
+	}
+
+	services := make(map[string]service)
+	if err := json.Unmarshal(data, &services); err != nil {
+		return nil, fmt.Errorf("parsing policy_sentry dataset %s: %w", path, err)
+	}
+
+	d := &Dataset{services: services}
+	if err := d.SetAccessLevels(DefaultAccessLevels); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// SetAccessLevels sets the access levels of the actions included in mappings
+func (d *Dataset) SetAccessLevels(levels []string) error {
+	d.levels = make(map[string]bool)
+	for _, level := range levels {
+		switch level = strings.TrimSpace(level); level {
+		case AccessRead, AccessList, AccessWrite, AccessTagging, AccessPermissionsManagement:
+			d.levels[level] = true
+		default:
+			return fmt.Errorf("invalid access level: %s (use %s)", level, strings.Join(accessLevels, ", "))
+		}
+	}
+	return nil
+}
+
+// Resolve returns the mapping of a Terraform resource type. It can be used
+// as a mapping.Resolver.
+func (d *Dataset) Resolve(resourceType string) (mapping.ResourceMapping, mapping.Provenance, bool) {
+	svc, name, ok := d.match(resourceType)
+	if !ok {
+		return mapping.ResourceMapping{}, mapping.Provenance{}, false
+	}
+
+	names := make([]string, 0, len(svc.Privileges))
+	for n := range svc.Privileges {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var m mapping.ResourceMapping
+	for _, n := range names {
+		p := svc.Privileges[n]
+		level := accessLevel(p.AccessLevel)
+		if !d.levels[level] || !p.appliesTo(name) {
+			continue
+		}
+
+		action := svc.Prefix + ":" + p.Privilege
+		switch level {
+		case AccessRead, AccessList:
+			m.Read = append(m.Read, action)
+		case AccessTagging:
+			if strings.HasPrefix(p.Privilege, "Tag") {
+				m.Create = append(m.Create, action)
+			}
+			m.Update = append(m.Update, action)
+		default:
+			switch operation(p.Privilege) {
+			case "create":
+				m.Create = append(m.Create, action)
+			case "delete":
+				m.Delete = append(m.Delete, action)
+			default:
+				m.Update = append(m.Update, action)
+			}
+		}
+	}
+
+	if len(m.Create)+len(m.Read)+len(m.Update)+len(m.Delete) == 0 {
+		return mapping.ResourceMapping{}, mapping.Provenance{}, false
+	}
+	return m, mapping.Provenance{Kind: mapping.ProvenancePolicySentry}, true
+}
+
+// match finds the service and resource name of a Terraform resource type,
+// trying each split of the type into service and resource words
+func (d *Dataset) match(resourceType string) (service, string, bool) {
+	words := strings.Split(strings.TrimPrefix(resourceType, "aws_"), "_")
+	if len(words) < 2 || !strings.HasPrefix(resourceType, "aws_") {
+		return service{}, "", false
+	}
+
+	for i := 1; i < len(words); i++ {
+		prefix := strings.Join(words[:i], "_")
+		if alias, ok := serviceAliases[prefix]; ok {
+			prefix = alias
+		} else {
+			prefix = strings.Join(words[:i], "")
+		}
+		svc, ok := d.services[prefix]
+		if !ok {
+			continue
+		}
+
+		// The last service word may belong to the resource name, as in
+		// aws_cloudwatch_log_group (logs:log-group)
+		for _, candidate := range []string{strings.Join(words[i:], ""), strings.Join(words[i-1:], "")} {
+			for _, r := range svc.Resources {
+				if normalize(r.Resource) == candidate {
+					if svc.Prefix == "" {
+						svc.Prefix = prefix
+					}
+					return svc, r.Resource, true
+				}
+			}
+		}
+	}
+	return service{}, "", false
+}
+
+// appliesTo checks if the privilege acts on the named resource: it lists the
+// resource type, or it has none and is named after the resource (e.g.,
+// CreateJob for job, which does not exist yet when created)
+func (p privilege) appliesTo(name string) bool {
+	for key, rt := range p.ResourceTypes {
+		t := strings.TrimSuffix(rt.ResourceType, "*")
+		if t == "" {
+			t = key
+		}
+		if t == name {
+			return true
+		}
+	}
+	if len(p.ResourceTypes) > 1 || len(p.ResourceTypes) == 1 && !hasEmptyType(p.ResourceTypes) {
+		return false
+	}
+
+	verbless := normalize(strings.TrimPrefix(p.Privilege, verb(p.Privilege)))
+	n := normalize(name)
+	return verbless == n || verbless == n+"s"
+}
+
+// hasEmptyType checks if the only resource type is the empty one, which
+// datasets use for actions without a resource
+func hasEmptyType(types map[string]resourceType) bool {
+	for key, rt := range types {
+		if key != "" || strings.TrimSuffix(rt.ResourceType, "*") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// accessLevel converts a dataset access level ("Permissions management")
+// to the form used by SetAccessLevels
+func accessLevel(level string) string {
+	return strings.ReplaceAll(strings.ToLower(level), " ", "-")
+}
+
+// operation returns the resource operation a write action belongs to
+func operation(action string) string {
+	switch verb(action) {
+	case "Create", "Run", "Register", "Allocate", "Request", "Import", "Launch", "Provision":
+		return "create"
+	case "Delete", "Deregister", "Terminate", "Release", "Remove":
+		return "delete"
+	}
+	return "update"
+}
+
+// verb returns the leading word of an action name (e.g., "Create" for CreateJob)
+func verb(action string) string {
+	for i := 1; i < len(action); i++ {
+		if action[i] >= 'A' && action[i] <= 'Z' {
+			return action[:i]
+		}
+	}
+	return action
+}
+
+// normalize lowercases a name and drops separators, so "log-group" and
+// log_group compare equal
+func normalize(name string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", "_", "", " ", "").Replace(name))
+}
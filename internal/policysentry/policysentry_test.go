@@ -0,0 +1,116 @@
+package policysentry
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mizzy/least/internal/mapping"
+)
+
+const dataset = `{
+  "glue": {
+    "prefix": "glue",
+    "privileges": {
+      "CreateJob": {"privilege": "CreateJob", "access_level": "Write", "resource_types": {"": {"resource_type": ""}}},
+      "GetJob": {"privilege": "GetJob", "access_level": "Read", "resource_types": {"job": {"resource_type": "job*"}}},
+      "GetJobs": {"privilege": "GetJobs", "access_level": "Read", "resource_types": {"": {"resource_type": ""}}},
+      "UpdateJob": {"privilege": "UpdateJob", "access_level": "Write", "resource_types": {"job": {"resource_type": "job*"}}},
+      "DeleteJob": {"privilege": "DeleteJob", "access_level": "Write", "resource_types": {"job": {"resource_type": "job*"}}},
+      "TagResource": {"privilege": "TagResource", "access_level": "Tagging", "resource_types": {"job": {"resource_type": "job"}, "crawler": {"resource_type": "crawler"}}},
+      "UntagResource": {"privilege": "UntagResource", "access_level": "Tagging", "resource_types": {"job": {"resource_type": "job"}}},
+      "PutResourcePolicy": {"privilege": "PutResourcePolicy", "access_level": "Permissions management", "resource_types": {"job": {"resource_type": "job"}}},
+      "CreateCrawler": {"privilege": "CreateCrawler", "access_level": "Write", "resource_types": {"": {"resource_type": ""}}}
+    },
+    "resources": {
+      "job": {"resource": "job", "arn": "arn:${Partition}:glue:${Region}:${Account}:job/${JobName}"},
+      "crawler": {"resource": "crawler", "arn": "arn:${Partition}:glue:${Region}:${Account}:crawler/${CrawlerName}"}
+    }
+  },
+  "logs": {
+    "prefix": "logs",
+    "privileges": {
+      "CreateLogGroup": {"privilege": "CreateLogGroup", "access_level": "Write", "resource_types": {"log-group": {"resource_type": "log-group*"}}}
+    },
+    "resources": {
+      "log-group": {"resource": "log-group", "arn": "arn:${Partition}:logs:${Region}:${Account}:log-group:${LogGroupName}"}
+    }
+  }
+}`
+
+func load(t *testing.T) *Dataset {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "iam-definition.json")
+	if err := os.WriteFile(path, []byte(dataset), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return d
+}
+
+func TestResolve(t *testing.T) {
+	d := load(t)
+
+	tests := []struct {
+		resourceType string
+		want         mapping.ResourceMapping
+		wantOK       bool
+	}{
+		{
+			resourceType: "aws_glue_job",
+			want: mapping.ResourceMapping{
+				Create: []string{"glue:CreateJob", "glue:TagResource"},
+				Read:   []string{"glue:GetJob", "glue:GetJobs"},
+				Update: []string{"glue:TagResource", "glue:UntagResource", "glue:UpdateJob"},
+				Delete: []string{"glue:DeleteJob"},
+			},
+			wantOK: true,
+		},
+		{
+			resourceType: "aws_cloudwatch_log_group",
+			want:         mapping.ResourceMapping{Create: []string{"logs:CreateLogGroup"}},
+			wantOK:       true,
+		},
+		{resourceType: "aws_glue_trigger", wantOK: false},
+		{resourceType: "aws_mq_broker", wantOK: false},
+		{resourceType: "google_storage_bucket", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resourceType, func(t *testing.T) {
+			got, provenance, ok := d.Resolve(tt.resourceType)
+			if ok != tt.wantOK {
+				t.Fatalf("Resolve() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve() = %+v, want %+v", got, tt.want)
+			}
+			if provenance.Kind != mapping.ProvenancePolicySentry {
+				t.Errorf("provenance = %q, want %q", provenance.Kind, mapping.ProvenancePolicySentry)
+			}
+		})
+	}
+}
+
+func TestSetAccessLevels(t *testing.T) {
+	d := load(t)
+
+	if err := d.SetAccessLevels([]string{AccessPermissionsManagement}); err != nil {
+		t.Fatalf("SetAccessLevels() error = %v", err)
+	}
+	got, _, _ := d.Resolve("aws_glue_job")
+	if want := (mapping.ResourceMapping{Update: []string{"glue:PutResourcePolicy"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Resolve() = %+v, want %+v", got, want)
+	}
+
+	if err := d.SetAccessLevels([]string{"admin"}); err == nil {
+		t.Error("SetAccessLevels() expected error for unknown level")
+	}
+}
@@ -0,0 +1,233 @@
+package prcomment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Commenter posts a report on the pull or merge request of a CI run
+type Commenter interface {
+	// Upsert updates the comment least posted before, or posts a new one
+	Upsert(ctx context.Context, body string) error
+}
+
+// FromEnv detects the CI platform from environment variables
+// (GitHub Actions or GitLab CI) and creates its commenter
+func FromEnv(getenv func(string) string) (Commenter, error) {
+	switch {
+	case getenv("GITHUB_ACTIONS") == "true":
+		return gitHubFromEnv(getenv)
+	case getenv("GITLAB_CI") == "true":
+		return gitLabFromEnv(getenv)
+	default:
+		return nil, fmt.Errorf("not running in GitHub Actions or GitLab CI")
+	}
+}
+
+// GitHub posts comments on a GitHub pull request
+type GitHub struct {
+	// APIURL is the REST API endpoint, e.g., https://api.github.com
+	APIURL     string
+	Token      string
+	Repository string
+	Number     int
+}
+
+// pullRef matches the ref of a pull request workflow run
+var pullRef = regexp.MustCompile(`^refs/pull/(\d+)/`)
+
+func gitHubFromEnv(getenv func(string) string) (*GitHub, error) {
+	g := &GitHub{
+		APIURL:     getenv("GITHUB_API_URL"),
+		Token:      getenv("GITHUB_TOKEN"),
+		Repository: getenv("GITHUB_REPOSITORY"),
+	}
+	if g.APIURL == "" {
+		g.APIURL = "https://api.github.com"
+	}
+	if g.Token == "" {
+		return nil, fmt.Errorf("GITHUB_TOKEN is not set")
+	}
+	if g.Repository == "" {
+		return nil, fmt.Errorf("GITHUB_REPOSITORY is not set")
+	}
+
+	if path := getenv("GITHUB_EVENT_PATH"); path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			var event struct {
+				Number      int `json:"number"`
+				PullRequest struct {
+					Number int `json:"number"`
+				} `json:"pull_request"`
+			}
+			if json.Unmarshal(data, &event) == nil {
+				g.Number = event.PullRequest.Number
+				if g.Number == 0 {
+					g.Number = event.Number
+				}
+			}
+		}
+	}
+	if g.Number == 0 {
+		if m := pullRef.FindStringSubmatch(getenv("GITHUB_REF")); m != nil {
+			g.Number, _ = strconv.Atoi(m[1])
+		}
+	}
+	if g.Number == 0 {
+		return nil, fmt.Errorf("workflow run is not for a pull request")
+	}
+	return g, nil
+}
+
+// Upsert updates the comment least posted before, or posts a new one
+func (g *GitHub) Upsert(ctx context.Context, body string) error {
+	base := strings.TrimSuffix(g.APIURL, "/") + "/repos/" + g.Repository
+	payload := map[string]string{"body": body}
+
+	for page := 1; ; page++ {
+		var comments []struct {
+			ID   int64  `json:"id"`
+			Body string `json:"body"`
+		}
+		endpoint := fmt.Sprintf("%s/issues/%d/comments?per_page=100&page=%d", base, g.Number, page)
+		if err := g.do(ctx, http.MethodGet, endpoint, nil, &comments); err != nil {
+			return err
+		}
+		for _, c := range comments {
+			if strings.HasPrefix(c.Body, Marker) {
+				return g.do(ctx, http.MethodPatch, fmt.Sprintf("%s/issues/comments/%d", base, c.ID), payload, nil)
+			}
+		}
+		if len(comments) < 100 {
+			break
+		}
+	}
+
+	return g.do(ctx, http.MethodPost, fmt.Sprintf("%s/issues/%d/comments", base, g.Number), payload, nil)
+}
+
+func (g *GitHub) do(ctx context.Context, method, endpoint string, payload, out interface{}) error {
+	return request(ctx, method, endpoint, payload, out, map[string]string{
+		"Authorization": "Bearer " + g.Token,
+		"Accept":        "application/vnd.github+json",
+	})
+}
+
+// GitLab posts notes on a GitLab merge request
+type GitLab struct {
+	// APIURL is the v4 API endpoint, e.g., https://gitlab.com/api/v4
+	APIURL    string
+	Token     string
+	ProjectID string
+	IID       int
+}
+
+func gitLabFromEnv(getenv func(string) string) (*GitLab, error) {
+	g := &GitLab{
+		APIURL:    getenv("CI_API_V4_URL"),
+		Token:     getenv("GITLAB_TOKEN"),
+		ProjectID: getenv("CI_PROJECT_ID"),
+	}
+	if g.APIURL == "" {
+		g.APIURL = "https://gitlab.com/api/v4"
+	}
+	if g.Token == "" {
+		return nil, fmt.Errorf("GITLAB_TOKEN is not set")
+	}
+	if g.ProjectID == "" {
+		return nil, fmt.Errorf("CI_PROJECT_ID is not set")
+	}
+	iid := getenv("CI_MERGE_REQUEST_IID")
+	if iid == "" {
+		return nil, fmt.Errorf("pipeline is not for a merge request")
+	}
+	n, err := strconv.Atoi(iid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CI_MERGE_REQUEST_IID %q", iid)
+	}
+	g.IID = n
+	return g, nil
+}
+
+// Upsert updates the note least posted before, or posts a new one
+func (g *GitLab) Upsert(ctx context.Context, body string) error {
+	base := fmt.Sprintf("%s/projects/%s/merge_requests/%d/notes",
+		strings.TrimSuffix(g.APIURL, "/"), url.PathEscape(g.ProjectID), g.IID)
+	payload := map[string]string{"body": body}
+
+	for page := 1; ; page++ {
+		var notes []struct {
+			ID   int64  `json:"id"`
+			Body string `json:"body"`
+		}
+		endpoint := fmt.Sprintf("%s?per_page=100&page=%d", base, page)
+		if err := g.do(ctx, http.MethodGet, endpoint, nil, &notes); err != nil {
+			return err
+		}
+		for _, n := range notes {
+			if strings.HasPrefix(n.Body, Marker) {
+				return g.do(ctx, http.MethodPut, fmt.Sprintf("%s/%d", base, n.ID), payload, nil)
+			}
+		}
+		if len(notes) < 100 {
+			break
+		}
+	}
+
+	return g.do(ctx, http.MethodPost, base, payload, nil)
+}
+
+func (g *GitLab) do(ctx context.Context, method, endpoint string, payload, out interface{}) error {
+	return request(ctx, method, endpoint, payload, out, map[string]string{
+		"PRIVATE-TOKEN": g.Token,
+	})
+}
+
+// request sends a JSON API request and decodes the response into out
+func request(ctx context.Context, method, endpoint string, payload, out interface{}, headers map[string]string) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: HTTP %d", method, req.URL.Path, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding %s response: %w", req.URL.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package prcomment
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/provider"
+	"github.com/mizzy/least/internal/risk"
+)
+
+func TestMarkdown(t *testing.T) {
+	required := &policy.IAMPolicy{
+		Version: "2012-10-17",
+		Statement: []policy.Statement{
+			{
+				Effect:   "Allow",
+				Action:   policy.StringList{"s3:GetObject"},
+				Resource: policy.StringList{"*"},
+				Sources: []provider.Resource{
+					{Type: "aws_s3_bucket", Name: "logs", Location: provider.SourceLocation{File: "s3.tf", Line: 3}},
+				},
+			},
+		},
+	}
+	report := Report{
+		Path:         "./terraform",
+		PolicySource: "policy.json",
+		Required:     required,
+		Result: &checker.Result{
+			Missing:   []string{"s3:GetObject"},
+			Excessive: []string{"ec2:TerminateInstances"},
+		},
+		BroadGrants: []risk.BroadGrant{
+			{Kind: risk.FullWildcardAction, Statement: "statement #1", Action: "*", Severity: risk.Critical},
+		},
+	}
+
+	got := report.Markdown()
+	for _, want := range []string{
+		Marker + "\n",
+		"1 missing, 1 excessive, 1 broad",
+		"<details><summary><b>ec2</b>: 0 missing, 1 excessive</summary>",
+		"<details><summary><b>s3</b>: 1 missing, 0 excessive</summary>",
+		"| ✗ missing | `s3:GetObject` | `aws_s3_bucket.logs` (s3.tf:3) |",
+		"| ⚠ excessive | `ec2:TerminateInstances` |",
+		"* grants every action in statement #1",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Markdown() does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Index(got, "<b>ec2</b>") > strings.Index(got, "<b>s3</b>") {
+		t.Error("services are not sorted")
+	}
+
+	compliant := Report{Path: ".", PolicySource: "policy.json", Result: &checker.Result{Matched: []string{"s3:GetObject"}}}
+	if got := compliant.Markdown(); !strings.Contains(got, "compliant") || strings.Contains(got, "<details>") {
+		t.Errorf("Markdown() of compliant report = %q", got)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	event := filepath.Join(t.TempDir(), "event.json")
+	if err := os.WriteFile(event, []byte(`{"pull_request": {"number": 42}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Commenter
+		wantErr bool
+	}{
+		{
+			name: "github event",
+			env: map[string]string{
+				"GITHUB_ACTIONS": "true", "GITHUB_TOKEN": "t", "GITHUB_REPOSITORY": "o/r",
+				"GITHUB_EVENT_PATH": event,
+			},
+			want: &GitHub{APIURL: "https://api.github.com", Token: "t", Repository: "o/r", Number: 42},
+		},
+		{
+			name: "github ref",
+			env: map[string]string{
+				"GITHUB_ACTIONS": "true", "GITHUB_TOKEN": "t", "GITHUB_REPOSITORY": "o/r",
+				"GITHUB_REF": "refs/pull/7/merge",
+			},
+			want: &GitHub{APIURL: "https://api.github.com", Token: "t", Repository: "o/r", Number: 7},
+		},
+		{
+			name: "github push",
+			env: map[string]string{
+				"GITHUB_ACTIONS": "true", "GITHUB_TOKEN": "t", "GITHUB_REPOSITORY": "o/r",
+				"GITHUB_REF": "refs/heads/main",
+			},
+			wantErr: true,
+		},
+		{
+			name: "gitlab",
+			env: map[string]string{
+				"GITLAB_CI": "true", "GITLAB_TOKEN": "t", "CI_API_V4_URL": "https://gitlab.example.com/api/v4",
+				"CI_PROJECT_ID": "12", "CI_MERGE_REQUEST_IID": "3",
+			},
+			want: &GitLab{APIURL: "https://gitlab.example.com/api/v4", Token: "t", ProjectID: "12", IID: 3},
+		},
+		{
+			name:    "gitlab without token",
+			env:     map[string]string{"GITLAB_CI": "true", "CI_PROJECT_ID": "12", "CI_MERGE_REQUEST_IID": "3"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown platform",
+			env:     map[string]string{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromEnv(func(k string) string { return tt.env[k] })
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("FromEnv() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromEnv() error = %v", err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tt.want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("FromEnv() = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+// fakeAPI serves comments and records the requests that change them
+type fakeAPI struct {
+	comments []map[string]interface{}
+	changes  []string
+}
+
+func (f *fakeAPI) handler(t *testing.T, list string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == list {
+			json.NewEncoder(w).Encode(f.comments)
+			return
+		}
+		var payload map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decoding %s %s: %v", r.Method, r.URL.Path, err)
+		}
+		f.changes = append(f.changes, r.Method+" "+r.URL.Path)
+		w.Write([]byte("{}"))
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	body := Marker + "\nreport"
+	existing := []map[string]interface{}{
+		{"id": 1, "body": "LGTM"},
+		{"id": 5, "body": Marker + "\nold report"},
+	}
+
+	tests := []struct {
+		name      string
+		comments  []map[string]interface{}
+		commenter func(url string) Commenter
+		list      string
+		want      string
+	}{
+		{
+			name:      "github create",
+			comments:  existing[:1],
+			commenter: func(url string) Commenter { return &GitHub{APIURL: url, Token: "t", Repository: "o/r", Number: 42} },
+			list:      "/repos/o/r/issues/42/comments",
+			want:      "POST /repos/o/r/issues/42/comments",
+		},
+		{
+			name:      "github update",
+			comments:  existing,
+			commenter: func(url string) Commenter { return &GitHub{APIURL: url, Token: "t", Repository: "o/r", Number: 42} },
+			list:      "/repos/o/r/issues/42/comments",
+			want:      "PATCH /repos/o/r/issues/comments/5",
+		},
+		{
+			name:      "gitlab create",
+			comments:  existing[:1],
+			commenter: func(url string) Commenter { return &GitLab{APIURL: url, Token: "t", ProjectID: "12", IID: 3} },
+			list:      "/projects/12/merge_requests/3/notes",
+			want:      "POST /projects/12/merge_requests/3/notes",
+		},
+		{
+			name:      "gitlab update",
+			comments:  existing,
+			commenter: func(url string) Commenter { return &GitLab{APIURL: url, Token: "t", ProjectID: "12", IID: 3} },
+			list:      "/projects/12/merge_requests/3/notes",
+			want:      "PUT /projects/12/merge_requests/3/notes/5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeAPI{comments: tt.comments}
+			server := httptest.NewServer(api.handler(t, tt.list))
+			defer server.Close()
+
+			if err := tt.commenter(server.URL).Upsert(context.Background(), body); err != nil {
+				t.Fatalf("Upsert() error = %v", err)
+			}
+			if len(api.changes) != 1 || api.changes[0] != tt.want {
+				t.Errorf("Upsert() requests = %v, want [%s]", api.changes, tt.want)
+			}
+		})
+	}
+}
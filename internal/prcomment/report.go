@@ -0,0 +1,107 @@
+// Package prcomment posts check reports as comments on GitHub pull requests
+// and GitLab merge requests. A report is posted once per pull request and
+// updated in place on later runs.
+package prcomment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/risk"
+)
+
+// Marker identifies the comment least posted, so later runs update it
+const Marker = "<!-- least check -->"
+
+// Report is the result of a check run
+type Report struct {
+	// Path is the analyzed IaC path
+	Path string
+	// PolicySource is the checked policy (file, directory or ARN)
+	PolicySource string
+	Required     *policy.IAMPolicy
+	Result       *checker.Result
+	BroadGrants  []risk.BroadGrant
+}
+
+// service collects the findings of one service
+type service struct {
+	missing   []string
+	excessive []string
+}
+
+// Markdown renders the report with a collapsible section per service
+func (r Report) Markdown() string {
+	var b strings.Builder
+	b.WriteString(Marker + "\n")
+
+	switch {
+	case r.Result.IsCompliant() && len(r.BroadGrants) == 0:
+		b.WriteString("### ✓ least: policy is compliant with least-privilege requirements\n\n")
+	default:
+		fmt.Fprintf(&b, "### ✗ least: %d missing, %d excessive, %d broad\n\n",
+			len(r.Result.Missing), len(r.Result.Excessive), len(r.BroadGrants))
+	}
+	fmt.Fprintf(&b, "Checked `%s` against the IaC code in `%s`.\n", r.PolicySource, r.Path)
+
+	services := make(map[string]*service)
+	get := func(name string) *service {
+		if services[name] == nil {
+			services[name] = &service{}
+		}
+		return services[name]
+	}
+	for _, g := range checker.GroupByService(r.Result.Missing) {
+		get(g.Service).missing = g.Actions
+	}
+	for _, g := range checker.GroupByService(r.Result.Excessive) {
+		get(g.Service).excessive = g.Actions
+	}
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := services[name]
+		fmt.Fprintf(&b, "\n<details><summary><b>%s</b>: %d missing, %d excessive</summary>\n\n", name, len(s.missing), len(s.excessive))
+		b.WriteString("| | Action | Details |\n|---|---|---|\n")
+		for _, action := range s.missing {
+			fmt.Fprintf(&b, "| ✗ missing | `%s` | %s |\n", action, r.requiredBy(action))
+		}
+		for _, action := range s.excessive {
+			fmt.Fprintf(&b, "| ⚠ excessive | `%s` | %s risk |\n", action, risk.Classify(action))
+		}
+		b.WriteString("\n</details>\n")
+	}
+
+	if len(r.BroadGrants) > 0 {
+		b.WriteString("\n**Dangerously broad grants**\n\n")
+		for _, g := range r.BroadGrants {
+			fmt.Fprintf(&b, "- [%s] %s\n", g.Severity, escape(g.String()))
+		}
+	}
+
+	return b.String()
+}
+
+// requiredBy lists the resources requiring an action
+func (r Report) requiredBy(action string) string {
+	if r.Required == nil {
+		return ""
+	}
+	var parts []string
+	for _, res := range r.Required.SourcesOf(action) {
+		parts = append(parts, fmt.Sprintf("`%s` (%s)", res.Address(), res.Location))
+	}
+	return strings.Join(parts, "<br>")
+}
+
+// escape keeps Markdown table and HTML syntax in text from being rendered
+func escape(s string) string {
+	return strings.NewReplacer("|", `\|`, "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -1,5 +1,7 @@
 // Package cloudformation implements the Provider interface for AWS CloudFormation.
-// This is a stub implementation showing how to add new providers.
+// Resources are read from the Resources section of templates and given the
+// Terraform type of their CloudFormation type, so they share the Terraform
+// permission mappings. Properties are not evaluated, so ARNs use wildcards.
 package cloudformation
 
 import (
@@ -7,9 +9,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	"gopkg.in/yaml.v3"
+
 	"github.com/mizzy/least/internal/provider"
+	"github.com/mizzy/least/internal/schema"
 )
 
 // Provider implements the provider.Provider interface for CloudFormation
@@ -91,13 +97,110 @@ func (p *Provider) isCloudFormationFile(path string) (bool, error) {
 	return false, nil
 }
 
-// Parse parses CloudFormation templates and returns resources and policies
+// Parse parses the CloudFormation templates at path, a template file or a
+// directory whose templates are parsed, and returns their resources.
+// YAML and JSON files without a Resources section of AWS types are skipped.
 func (p *Provider) Parse(ctx context.Context, path string) (*provider.ParseResult, error) {
-	// TODO: Implement CloudFormation parsing
-	// This would involve:
-	// 1. Parsing YAML/JSON templates
-	// 2. Extracting resources (AWS::S3::Bucket -> aws_s3_bucket equivalent)
-	// 3. Mapping CloudFormation resource types to IAM actions
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		files = nil
+		for _, entry := range entries {
+			if !entry.IsDir() && p.hasExtension(entry.Name()) {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(files)
+	}
+
+	result := &provider.ParseResult{}
+	for _, filename := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+		resources, err := parseTemplate(filename, src)
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+		result.Resources = append(result.Resources, resources...)
+	}
+	return result, nil
+}
+
+// hasExtension checks if a file has one of the template extensions
+func (p *Provider) hasExtension(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range p.FileExtensions() {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
 
-	return nil, fmt.Errorf("CloudFormation provider not yet implemented")
+// parseTemplate returns the resources of a template, in template order. JSON
+// templates are parsed as YAML, of which JSON is a subset; intrinsic function
+// tags such as !Ref are kept on the nodes and ignored.
+func parseTemplate(filename string, src []byte) ([]provider.Resource, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(src, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	section := mappingValue(doc.Content[0], "Resources")
+	if section == nil || section.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	var resources []provider.Resource
+	for i := 0; i+1 < len(section.Content); i += 2 {
+		key, body := section.Content[i], section.Content[i+1]
+		typeNode := mappingValue(body, "Type")
+		if typeNode == nil || !strings.HasPrefix(typeNode.Value, "AWS::") {
+			continue
+		}
+		resourceType := schema.CfnToTerraformType(typeNode.Value)
+		if resourceType == "" {
+			resourceType = typeNode.Value
+		}
+		resources = append(resources, provider.Resource{
+			Provider:      "cloudformation",
+			Type:          resourceType,
+			Name:          key.Value,
+			CloudProvider: "aws",
+			Attributes:    map[string]interface{}{},
+			Location:      provider.SourceLocation{File: filename, Line: key.Line, Column: key.Column},
+		})
+	}
+	return resources, nil
+}
+
+// mappingValue returns the value of a key of a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
 }
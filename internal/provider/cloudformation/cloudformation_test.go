@@ -0,0 +1,57 @@
+package cloudformation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("template.yaml", `AWSTemplateFormatVersion: "2010-09-09"
+Resources:
+  Logs:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: !Sub ${AWS::StackName}-logs
+  Jobs:
+    Type: AWS::SQS::Queue
+  Custom:
+    Type: Custom::Thing
+`)
+	write("network.json", `{"Resources": {"Vpc": {"Type": "AWS::EC2::VPC"}}}`)
+	write("workflow.yml", `on: push`)
+
+	result, err := New().Parse(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Errors) > 0 {
+		t.Fatalf("Parse errors: %v", result.Errors)
+	}
+
+	var got []string
+	for _, res := range result.Resources {
+		got = append(got, res.Address()+"@"+res.Location.String())
+	}
+	want := []string{
+		"aws_vpc.Vpc@" + filepath.Join(dir, "network.json") + ":1",
+		"aws_s3_bucket.Logs@" + filepath.Join(dir, "template.yaml") + ":3",
+		"aws_sqs_queue.Jobs@" + filepath.Join(dir, "template.yaml") + ":7",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("resources = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("resource %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
@@ -3,7 +3,10 @@
 // such as Terraform, CloudFormation, Pulumi, CDK, etc.
 package provider
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
 
 // Resource represents a cloud resource defined in IaC code.
 // This is the common representation across all providers.
@@ -25,6 +28,61 @@ type Resource struct {
 
 	// Location contains source file information
 	Location SourceLocation
+
+	// Module is the address of the module the resource is declared in
+	// (e.g., "module.app"), empty for the root module
+	Module string
+
+	// References are the full addresses of the resources and modules the
+	// resource depends on, through references in its arguments or
+	// depends_on, or through the arguments of the module calls it is in
+	// (e.g., "aws_iam_role.app", "module.network")
+	References []string
+
+	// KMSKeys are the customer-managed KMS keys the resource encrypts with:
+	// full addresses of the key resources or data sources it refers to
+	// (e.g., "aws_kms_key.data"), or literal key IDs, ARNs and aliases
+	KMSKeys []string
+}
+
+// Address returns the resource address (e.g., "aws_s3_bucket.logs")
+func (r Resource) Address() string {
+	return r.Type + "." + r.Name
+}
+
+// FullAddress returns the resource address including its module
+// (e.g., "module.app.aws_s3_bucket.logs")
+func (r Resource) FullAddress() string {
+	if r.Module == "" {
+		return r.Address()
+	}
+	return r.Module + "." + r.Address()
+}
+
+// ContainerDefinitionsAttribute is the attribute of a task definition (e.g.,
+// aws_ecs_task_definition) holding its []ContainerDefinition
+const ContainerDefinitionsAttribute = "container_definitions"
+
+// ContainerDefinition is what a container of a task definition needs the
+// task execution role to access. Values are literals, with "*" for the parts
+// that cannot be evaluated, or Terraform references to the resources they
+// come from (e.g., "${aws_ecr_repository.app.repository_url}").
+type ContainerDefinition struct {
+	Name string
+	// Image is the image the container runs
+	Image string
+	// LogGroup is the CloudWatch Logs group of the awslogs log driver
+	LogGroup string
+	// CreateLogGroup is set when the log driver creates the log group
+	CreateLogGroup bool
+	// Secrets are the Secrets Manager secrets and SSM parameters injected
+	// into the container or its log configuration
+	Secrets []string
+	// RepositoryCredentials is the secret of the private registry the image
+	// is pulled from
+	RepositoryCredentials string
+	// EnvironmentFiles are the S3 objects of the container's environment files
+	EnvironmentFiles []string
 }
 
 // SourceLocation identifies where a resource is defined
@@ -34,19 +92,103 @@ type SourceLocation struct {
 	Column int
 }
 
+// String returns the location as "file:line"
+func (l SourceLocation) String() string {
+	if l.Line == 0 {
+		return l.File
+	}
+	return fmt.Sprintf("%s:%d", l.File, l.Line)
+}
+
 // IAMStatement represents a policy statement from IaC code
 type IAMStatement struct {
 	Sid       string
 	Effect    string
 	Actions   []string
 	Resources []string
+	// Principals are set in trust and resource-based policies, which grant
+	// access to others rather than permissions to the identity they are
+	// attached to
+	Principals []IAMPrincipal
+	Conditions []IAMCondition
+}
+
+// IsTrust checks if the statement names principals, as trust and
+// resource-based policy statements do
+func (s IAMStatement) IsTrust() bool {
+	return len(s.Principals) > 0
+}
+
+// IAMPrincipal is a principals block of a statement
+// e.g., {Type: "Service", Identifiers: ["lambda.amazonaws.com"]}
+type IAMPrincipal struct {
+	// Type is AWS, Service, Federated, CanonicalUser or *
+	Type        string
+	Identifiers []string
+}
+
+// IAMCondition is a condition block of a statement
+// e.g., {Test: "StringEquals", Variable: "aws:RequestedRegion", Values: ["us-east-1"]}
+type IAMCondition struct {
+	Test     string
+	Variable string
+	Values   []string
 }
 
 // IAMPolicy represents an IAM policy defined in IaC code
 type IAMPolicy struct {
-	Name       string
+	Name string
+	// Address is the provider-specific address of the policy
+	// (e.g., "aws_iam_policy.deploy", "data.aws_iam_policy_document.ci")
+	Address string
+	// ARN is set when the policy refers to an existing managed policy
+	// whose statements are not defined in the IaC code
+	ARN        string
 	Statements []IAMStatement
-	Location   SourceLocation
+	// SourceDocuments and OverrideDocuments are the addresses of policy
+	// documents this one is composed from; their statements are merged into
+	// Statements once every document of the module is parsed
+	SourceDocuments   []string
+	OverrideDocuments []string
+	Location          SourceLocation
+}
+
+// PolicyAttachment represents a managed policy attached to a role, user, or group
+type PolicyAttachment struct {
+	// Address is the address of the attachment (e.g., "aws_iam_role_policy_attachment.deploy")
+	Address string
+	// PolicyARN is the literal ARN of the attached policy, if known
+	PolicyARN string
+	// PolicyRef is a reference to the attached policy (e.g., "aws_iam_policy.deploy.arn")
+	PolicyRef string
+	Location  SourceLocation
+}
+
+// Requirement kinds
+const (
+	// RequirementState is access to the state the IaC tool stores in a
+	// backend (e.g., an S3 bucket and DynamoDB lock table)
+	RequirementState = "state"
+	// RequirementAuth is a call the IaC tool makes to authenticate (e.g.,
+	// assuming the role a provider is configured with)
+	RequirementAuth = "auth"
+	// RequirementRegistry is access to a registry modules or templates are
+	// downloaded from (e.g., an S3 bucket of module archives)
+	RequirementRegistry = "registry"
+)
+
+// Requirement is a permission the IaC tool itself needs to deploy, rather
+// than one a resource requires
+type Requirement struct {
+	// Kind is one of the Requirement kinds
+	Kind string
+	// Description says what needs the permission (e.g., "S3 backend state")
+	Description string
+	Actions     []string
+	// Resources are the ARNs the actions are needed on, with "*" for the
+	// parts that cannot be evaluated
+	Resources []string
+	Location  SourceLocation
 }
 
 // ParseResult contains the results of parsing IaC files
@@ -57,6 +199,9 @@ type ParseResult struct {
 	// Policies are IAM policies defined in the IaC code
 	Policies []IAMPolicy
 
+	// PolicyAttachments are managed policy attachments defined in the IaC code
+	PolicyAttachments []PolicyAttachment
+
 	// AccountRef is the reference to use for AWS account ID in ARNs
 	// e.g., "${data.aws_caller_identity.current.account_id}" or "${var.account_id}"
 	AccountRef string
@@ -71,10 +216,77 @@ type ParseResult struct {
 	// HasRegionData indicates if the source code already has aws_region data source
 	HasRegionData bool
 
+	// Requirements are the permissions the IaC tool itself needs, such as
+	// access to backend state
+	Requirements []Requirement
+
+	// AWSProviderVersion is the version of the hashicorp/aws provider the
+	// IaC code uses, from the dependency lock file or the lowest version its
+	// constraint allows; empty if unknown
+	AWSProviderVersion string
+
 	// Errors encountered during parsing (non-fatal)
 	Errors []error
 }
 
+// Merge appends the results of other. Account and region references already
+// set are kept, so results merged in source order resolve like a single pass.
+func (r *ParseResult) Merge(other *ParseResult) {
+	r.Resources = append(r.Resources, other.Resources...)
+	r.Policies = append(r.Policies, other.Policies...)
+	r.PolicyAttachments = append(r.PolicyAttachments, other.PolicyAttachments...)
+	r.Requirements = append(r.Requirements, other.Requirements...)
+	r.Errors = append(r.Errors, other.Errors...)
+
+	if r.AccountRef == "" {
+		r.AccountRef = other.AccountRef
+	}
+	if r.RegionRef == "" {
+		r.RegionRef = other.RegionRef
+	}
+	if r.AWSProviderVersion == "" {
+		r.AWSProviderVersion = other.AWSProviderVersion
+	}
+	r.HasCallerIdentity = r.HasCallerIdentity || other.HasCallerIdentity
+	r.HasRegionData = r.HasRegionData || other.HasRegionData
+}
+
+// Filter removes the resources, policies, policy attachments and
+// requirements whose location is not kept
+func (r *ParseResult) Filter(keep func(SourceLocation) bool) {
+	resources := r.Resources[:0]
+	for _, res := range r.Resources {
+		if keep(res.Location) {
+			resources = append(resources, res)
+		}
+	}
+	r.Resources = resources
+
+	policies := r.Policies[:0]
+	for _, pol := range r.Policies {
+		if keep(pol.Location) {
+			policies = append(policies, pol)
+		}
+	}
+	r.Policies = policies
+
+	attachments := r.PolicyAttachments[:0]
+	for _, a := range r.PolicyAttachments {
+		if keep(a.Location) {
+			attachments = append(attachments, a)
+		}
+	}
+	r.PolicyAttachments = attachments
+
+	requirements := r.Requirements[:0]
+	for _, req := range r.Requirements {
+		if keep(req.Location) {
+			requirements = append(requirements, req)
+		}
+	}
+	r.Requirements = requirements
+}
+
 // Provider is the interface that IaC tool parsers must implement
 type Provider interface {
 	// Name returns the provider identifier (e.g., "terraform", "cloudformation")
@@ -90,6 +302,24 @@ type Provider interface {
 	FileExtensions() []string
 }
 
+// PathFilter reports whether a file, or a directory when dir is true, found
+// during discovery should be skipped
+type PathFilter func(path string, dir bool) bool
+
+// Filterable is implemented by providers that can skip files and directories
+// during discovery instead of parsing them
+type Filterable interface {
+	// SetPathFilter sets the filter used by subsequent Parse calls; nil disables filtering
+	SetPathFilter(filter PathFilter)
+}
+
+// SourceParser is implemented by providers that can parse a single document
+// that is not read from a file, such as stdin
+type SourceParser interface {
+	// ParseSource parses src; filename is used in source locations and errors
+	ParseSource(ctx context.Context, filename string, src []byte) (*ParseResult, error)
+}
+
 // Registry manages available providers
 type Registry struct {
 	providers []Provider
@@ -0,0 +1,288 @@
+package terraform
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/mapping"
+	"github.com/mizzy/least/internal/provider"
+)
+
+// cacheFormat is bumped whenever the parser changes what it extracts, so
+// entries written by older versions are ignored
+const cacheFormat = "11"
+
+func init() {
+	gob.Register(AttributeValue{})
+	gob.Register([]provider.ContainerDefinition{})
+}
+
+// Cache stores per-directory parse results keyed by file content hashes, so
+// repeated runs only re-parse files that changed
+type Cache struct {
+	dir string
+}
+
+// DefaultCacheDir returns the parse cache directory: $LEAST_PARSE_CACHE if
+// set, otherwise least/parse under the user cache directory
+func DefaultCacheDir() string {
+	if dir := os.Getenv("LEAST_PARSE_CACHE"); dir != "" {
+		return dir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "least", "parse")
+	}
+	return filepath.Join(dir, "least", "parse")
+}
+
+// NewCache creates a parse cache stored in dir
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// CacheDir returns the directory the cache is stored in
+func (c *Cache) CacheDir() string {
+	return c.dir
+}
+
+// ClearCache removes all cached parse results and returns the number removed
+func (c *Cache) ClearCache() (int, error) {
+	entries, err := os.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".gob" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, entry.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// dirEntry is the cached state of one Terraform directory
+type dirEntry struct {
+	// Salt identifies the parser and ARN attribute configuration the entry was written with
+	Salt string
+	// DirHash covers every Terraform file in the directory; module calls are
+	// reused only while it is unchanged
+	DirHash          string
+	Modules          map[string]string
+	ModuleReferences map[string][]string
+	LoadError        string
+	Files            map[string]fileEntry
+}
+
+// fileEntry is the cached parse result of one file
+type fileEntry struct {
+	Hash string
+	// Templates are the cache keys of the templatefile() templates the file
+	// reads; the entry is reused only while they are unchanged
+	Templates map[string]string
+	Result    cachedResult
+}
+
+// cachedResult is a ParseResult with errors flattened to strings
+type cachedResult struct {
+	Resources         []provider.Resource
+	Policies          []provider.IAMPolicy
+	PolicyAttachments []provider.PolicyAttachment
+	Requirements      []provider.Requirement
+	AccountRef        string
+	RegionRef         string
+	HasCallerIdentity bool
+	HasRegionData     bool
+	Errors            []string
+}
+
+func toCached(r *provider.ParseResult) cachedResult {
+	c := cachedResult{
+		Resources:         r.Resources,
+		Policies:          r.Policies,
+		PolicyAttachments: r.PolicyAttachments,
+		Requirements:      r.Requirements,
+		AccountRef:        r.AccountRef,
+		RegionRef:         r.RegionRef,
+		HasCallerIdentity: r.HasCallerIdentity,
+		HasRegionData:     r.HasRegionData,
+	}
+	for _, err := range r.Errors {
+		c.Errors = append(c.Errors, err.Error())
+	}
+	return c
+}
+
+func (c cachedResult) result() *provider.ParseResult {
+	r := &provider.ParseResult{
+		Resources:         c.Resources,
+		Policies:          c.Policies,
+		PolicyAttachments: c.PolicyAttachments,
+		Requirements:      c.Requirements,
+		AccountRef:        c.AccountRef,
+		RegionRef:         c.RegionRef,
+		HasCallerIdentity: c.HasCallerIdentity,
+		HasRegionData:     c.HasRegionData,
+	}
+	for _, msg := range c.Errors {
+		r.Errors = append(r.Errors, errors.New(msg))
+	}
+	return r
+}
+
+// cacheSalt identifies the configuration that affects parse results
+func cacheSalt() string {
+	return cacheFormat + ":" + mapping.ARNAttributesFingerprint()
+}
+
+// hashFile returns the cache key of a file: its path as given and its content
+func hashFile(path string, src []byte) string {
+	h := sha256.New()
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(src)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashDir combines the file hashes of a directory
+func hashDir(hashes map[string]string) string {
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		h.Write([]byte(name + "=" + hashes[name] + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// path returns the cache file of a directory
+func (c *Cache) path(absDir string) string {
+	sum := sha256.Sum256([]byte(absDir))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".gob")
+}
+
+// load returns the cached entry of a directory, or nil when there is none
+// or it was written with a different salt
+func (c *Cache) load(absDir, salt string) *dirEntry {
+	data, err := os.ReadFile(c.path(absDir))
+	if err != nil {
+		return nil
+	}
+
+	var entry dirEntry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil {
+		return nil
+	}
+	if entry.Salt != salt {
+		return nil
+	}
+	return &entry
+}
+
+// save writes the entry of a directory
+func (c *Cache) save(absDir string, entry *dirEntry) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entry); err != nil {
+		return err
+	}
+
+	// Write atomically so concurrent runs never read a partial entry
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(absDir))
+}
+
+// filesEntry returns the cached entry of a file; e may be nil
+func (e *dirEntry) filesEntry(path string) (fileEntry, bool) {
+	if e == nil {
+		return fileEntry{}, false
+	}
+	f, ok := e.Files[path]
+	return f, ok
+}
+
+// hashTerraformFiles returns the cache keys of the Terraform files in dir.
+// Files that cannot be read are left out and always re-parsed.
+func hashTerraformFiles(dir string) map[string]string {
+	hashes := make(map[string]string)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return hashes
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, ".tf") || strings.HasSuffix(name, ".tf.json")) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		src, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		hashes[path] = hashFile(path, src)
+	}
+	return hashes
+}
+
+// hashTemplates returns the cache keys of template files. Templates that
+// cannot be read get an empty key, which never matches.
+func hashTemplates(paths []string) map[string]string {
+	if len(paths) == 0 {
+		return nil
+	}
+	hashes := make(map[string]string, len(paths))
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			hashes[path] = ""
+			continue
+		}
+		hashes[path] = hashFile(path, src)
+	}
+	return hashes
+}
+
+// templatesUnchanged checks if cached template keys match the files on disk
+func templatesUnchanged(hashes map[string]string) bool {
+	for path, hash := range hashes {
+		src, err := os.ReadFile(path)
+		if err != nil || hash == "" || hashFile(path, src) != hash {
+			return false
+		}
+	}
+	return true
+}
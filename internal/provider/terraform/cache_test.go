@@ -0,0 +1,95 @@
+package terraform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCache(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("main.tf", `
+resource "aws_s3_bucket" "logs" {
+  bucket = "my-logs"
+}
+
+module "queue" {
+  source = "./modules/queue"
+}
+`)
+	write("modules/queue/main.tf", `
+resource "aws_sqs_queue" "jobs" {
+  name = "jobs"
+}
+`)
+
+	cache := NewCache(t.TempDir())
+	p := New()
+	p.SetCache(cache)
+
+	parse := func() map[string]interface{} {
+		t.Helper()
+		result, err := p.Parse(context.Background(), dir)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		if len(result.Errors) > 0 {
+			t.Fatalf("unexpected errors: %v", result.Errors)
+		}
+		got := make(map[string]interface{})
+		for _, r := range result.Resources {
+			got[r.Address()] = r.Attributes["bucket"]
+		}
+		return got
+	}
+
+	first := parse()
+	entries, err := os.ReadDir(cache.CacheDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d cache entries, want one per directory (2)", len(entries))
+	}
+
+	second := parse()
+	if len(second) != 2 || len(first) != 2 {
+		t.Fatalf("got %v then %v, want 2 resources each", first, second)
+	}
+	if got, ok := second["aws_s3_bucket.logs"].(AttributeValue); !ok || got.Literal != "my-logs" {
+		t.Errorf("cached bucket attribute = %#v, want literal my-logs", second["aws_s3_bucket.logs"])
+	}
+
+	// A changed file is re-parsed, and a removed module call is dropped
+	write("main.tf", `
+resource "aws_s3_bucket" "logs" {
+  bucket = "other-logs"
+}
+`)
+	third := parse()
+	if len(third) != 1 {
+		t.Fatalf("got %v, want only aws_s3_bucket.logs", third)
+	}
+	if got, _ := third["aws_s3_bucket.logs"].(AttributeValue); got.Literal != "other-logs" {
+		t.Errorf("bucket attribute = %#v, want literal other-logs", third["aws_s3_bucket.logs"])
+	}
+
+	removed, err := cache.ClearCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("ClearCache removed %d entries, want 2", removed)
+	}
+}
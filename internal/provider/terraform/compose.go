@@ -0,0 +1,158 @@
+package terraform
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+// documentRefs returns the policy documents referenced by a
+// source_policy_documents or override_policy_documents list, or by a single
+// source_json or override_json value, as data.aws_iam_policy_document.NAME.
+// Elements that are not such references are ignored.
+func documentRefs(expr hcl.Expression) []string {
+	var exprs []hclsyntax.Expression
+	switch e := expr.(type) {
+	case *hclsyntax.TupleConsExpr:
+		exprs = e.Exprs
+	case hclsyntax.Expression:
+		exprs = []hclsyntax.Expression{e}
+	}
+
+	var refs []string
+	for _, e := range exprs {
+		traversal, ok := e.(*hclsyntax.ScopeTraversalExpr)
+		if !ok || len(traversal.Traversal) < 3 || traversal.Traversal.RootName() != "data" {
+			continue
+		}
+		typ, ok1 := traversal.Traversal[1].(hcl.TraverseAttr)
+		name, ok2 := traversal.Traversal[2].(hcl.TraverseAttr)
+		if !ok1 || !ok2 || typ.Name != "aws_iam_policy_document" {
+			continue
+		}
+		refs = append(refs, "data."+typ.Name+"."+name.Name)
+	}
+	return refs
+}
+
+// composePolicyDocuments merges the source and override documents of the
+// policies of a module into their statements, following the
+// aws_iam_policy_document semantics: statements of source documents come
+// first, the document's own statements replace source statements with the
+// same Sid, and statements of override documents, applied in order, replace
+// statements with the same Sid or are appended. It returns an error for
+// each document that cannot be resolved.
+func composePolicyDocuments(policies []provider.IAMPolicy) []error {
+	index := make(map[string]int, len(policies))
+	for i, p := range policies {
+		if p.Address != "" {
+			index[p.Address] = i
+		}
+	}
+
+	c := &composer{
+		policies: policies,
+		index:    index,
+		done:     make(map[int]bool),
+		visiting: make(map[int]bool),
+	}
+	for i := range policies {
+		c.compose(i)
+	}
+	return c.errs
+}
+
+// composer resolves the documents of a module, each at most once
+type composer struct {
+	policies []provider.IAMPolicy
+	index    map[string]int
+	done     map[int]bool
+	visiting map[int]bool
+	errs     []error
+}
+
+// compose resolves the statements of a policy and the documents it uses
+func (c *composer) compose(i int) {
+	if c.done[i] {
+		return
+	}
+	p := &c.policies[i]
+	if len(p.SourceDocuments) == 0 && len(p.OverrideDocuments) == 0 {
+		c.done[i] = true
+		return
+	}
+	if c.visiting[i] {
+		c.errs = append(c.errs, fmt.Errorf("%s: policy documents refer to each other", p.Address))
+		return
+	}
+	c.visiting[i] = true
+	defer delete(c.visiting, i)
+
+	var merged statementSet
+	for _, ref := range p.SourceDocuments {
+		for _, stmt := range c.statements(p.Address, ref) {
+			if merged.has(stmt.Sid) {
+				c.errs = append(c.errs, fmt.Errorf("%s: duplicate Sid %q in source_policy_documents", p.Address, stmt.Sid))
+			}
+			merged.put(stmt)
+		}
+	}
+	for _, stmt := range p.Statements {
+		merged.put(stmt)
+	}
+	for _, ref := range p.OverrideDocuments {
+		for _, stmt := range c.statements(p.Address, ref) {
+			merged.put(stmt)
+		}
+	}
+
+	p.Statements = merged.statements
+	c.done[i] = true
+}
+
+// statements returns the composed statements of a referenced document
+func (c *composer) statements(from, ref string) []provider.IAMStatement {
+	j, ok := c.index[ref]
+	if !ok {
+		c.errs = append(c.errs, fmt.Errorf("%s: %s not found in the module", from, ref))
+		return nil
+	}
+	c.compose(j)
+	return c.policies[j].Statements
+}
+
+// statementSet is an ordered list of statements where a statement with a
+// Sid replaces an earlier one with the same Sid
+type statementSet struct {
+	statements []provider.IAMStatement
+}
+
+// has checks if a statement with the Sid exists; statements without a Sid
+// never match
+func (s *statementSet) has(sid string) bool {
+	if sid == "" {
+		return false
+	}
+	for _, stmt := range s.statements {
+		if stmt.Sid == sid {
+			return true
+		}
+	}
+	return false
+}
+
+// put replaces the statement with the same Sid or appends the statement
+func (s *statementSet) put(stmt provider.IAMStatement) {
+	if stmt.Sid != "" {
+		for i := range s.statements {
+			if s.statements[i].Sid == stmt.Sid {
+				s.statements[i] = stmt
+				return
+			}
+		}
+	}
+	s.statements = append(s.statements, stmt)
+}
@@ -0,0 +1,183 @@
+package terraform
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+// extractContainerDefinitions adds the container definitions of an
+// aws_ecs_task_definition to attrs, from its container_definitions argument
+// written with jsonencode or as a JSON string
+func extractContainerDefinitions(body hcl.Body, resourceType string, ctx *hcl.EvalContext, attrs map[string]interface{}) {
+	if resourceType != "aws_ecs_task_definition" {
+		return
+	}
+	content, _, _ := body.PartialContent(&hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: provider.ContainerDefinitionsAttribute}},
+	})
+	if content == nil {
+		return
+	}
+	attr, ok := content.Attributes[provider.ContainerDefinitionsAttribute]
+	if !ok {
+		return
+	}
+
+	var value interface{}
+	if call, ok := attr.Expr.(*hclsyntax.FunctionCallExpr); ok && call.Name == "jsonencode" && len(call.Args) == 1 {
+		value = consValue(call.Args[0], ctx)
+	} else if s := wildcardString(attr.Expr, ctx); s == "" || json.Unmarshal([]byte(s), &value) != nil {
+		return
+	}
+	if defs := decodeContainerDefinitions(value); len(defs) > 0 {
+		attrs[provider.ContainerDefinitionsAttribute] = defs
+	}
+}
+
+// planContainerDefinitions returns the container definitions of the values
+// of a plan resource, where container_definitions is a JSON string
+func planContainerDefinitions(values map[string]interface{}) []provider.ContainerDefinition {
+	s, ok := values[provider.ContainerDefinitionsAttribute].(string)
+	if !ok {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(s), &value); err != nil {
+		return nil
+	}
+	return decodeContainerDefinitions(value)
+}
+
+// consValue converts the argument of jsonencode to JSON-like values. Strings
+// referring to resources or data sources become the reference (e.g.,
+// "${aws_ecr_repository.app.repository_url}"), and the parts of other
+// strings, or whole values, that cannot be evaluated become "*".
+func consValue(expr hcl.Expression, ctx *hcl.EvalContext) interface{} {
+	if val, diags := expr.Value(ctx); !diags.HasErrors() && val.IsWhollyKnown() && !val.IsNull() {
+		if data, err := ctyjson.Marshal(val, val.Type()); err == nil {
+			var value interface{}
+			if json.Unmarshal(data, &value) == nil {
+				return value
+			}
+		}
+	}
+
+	switch e := expr.(type) {
+	case *hclsyntax.TupleConsExpr:
+		items := make([]interface{}, len(e.Exprs))
+		for i, item := range e.Exprs {
+			items[i] = consValue(item, ctx)
+		}
+		return items
+	case *hclsyntax.ObjectConsExpr:
+		object := make(map[string]interface{})
+		for _, item := range e.Items {
+			key := hcl.ExprAsKeyword(item.KeyExpr)
+			if key == "" {
+				if val, diags := item.KeyExpr.Value(ctx); !diags.HasErrors() && val.IsWhollyKnown() && val.Type() == cty.String {
+					key = val.AsString()
+				}
+			}
+			if key != "" {
+				object[key] = consValue(item.ValueExpr, ctx)
+			}
+		}
+		return object
+	}
+
+	for _, traversal := range expr.Variables() {
+		if ref := resourceTraversal(traversal); ref != "" {
+			return "${" + ref + "}"
+		}
+	}
+	if s := wildcardString(expr, ctx); s != "" {
+		return s
+	}
+	return "*"
+}
+
+// resourceTraversal returns a traversal of a resource or data source
+// attribute as a string (e.g., "aws_ecr_repository.app.repository_url"), or
+// "" for other traversals
+func resourceTraversal(traversal hcl.Traversal) string {
+	var steps []string
+	for _, step := range traversal {
+		switch s := step.(type) {
+		case hcl.TraverseRoot:
+			steps = append(steps, s.Name)
+		case hcl.TraverseAttr:
+			steps = append(steps, s.Name)
+		}
+	}
+	if len(steps) < 2 || (!strings.HasPrefix(steps[0], "aws_") && steps[0] != "data") {
+		return ""
+	}
+	return strings.Join(steps, ".")
+}
+
+// decodeContainerDefinitions returns what the execution role needs for each
+// container of a container_definitions value
+func decodeContainerDefinitions(value interface{}) []provider.ContainerDefinition {
+	items, _ := value.([]interface{})
+	var defs []provider.ContainerDefinition
+	for _, item := range items {
+		container, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		def := provider.ContainerDefinition{
+			Name:  stringField(container, "name"),
+			Image: stringField(container, "image"),
+		}
+		if logging, ok := container["logConfiguration"].(map[string]interface{}); ok {
+			if options, ok := logging["options"].(map[string]interface{}); ok && stringField(logging, "logDriver") == "awslogs" {
+				def.LogGroup = stringField(options, "awslogs-group")
+				create := options["awslogs-create-group"]
+				def.CreateLogGroup = create == true || create == "true"
+			}
+			def.Secrets = append(def.Secrets, valuesFrom(logging["secretOptions"])...)
+		}
+		def.Secrets = append(def.Secrets, valuesFrom(container["secrets"])...)
+		if credentials, ok := container["repositoryCredentials"].(map[string]interface{}); ok {
+			def.RepositoryCredentials = stringField(credentials, "credentialsParameter")
+		}
+		if files, ok := container["environmentFiles"].([]interface{}); ok {
+			for _, file := range files {
+				if f, ok := file.(map[string]interface{}); ok {
+					if v := stringField(f, "value"); v != "" {
+						def.EnvironmentFiles = append(def.EnvironmentFiles, v)
+					}
+				}
+			}
+		}
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+// valuesFrom returns the valueFrom fields of a list of secrets
+func valuesFrom(value interface{}) []string {
+	items, _ := value.([]interface{})
+	var values []string
+	for _, item := range items {
+		if secret, ok := item.(map[string]interface{}); ok {
+			if v := stringField(secret, "valueFrom"); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// stringField returns a string field of an object, or "" if it is not a string
+func stringField(object map[string]interface{}, name string) string {
+	s, _ := object[name].(string)
+	return s
+}
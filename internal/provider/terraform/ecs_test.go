@@ -0,0 +1,73 @@
+package terraform
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+func TestParseContainerDefinitions(t *testing.T) {
+	src := []byte(`
+resource "aws_ecs_task_definition" "app" {
+  family = "app"
+  container_definitions = jsonencode([
+    {
+      name  = "app"
+      image = "${aws_ecr_repository.app.repository_url}:latest"
+      logConfiguration = {
+        logDriver = "awslogs"
+        options = {
+          "awslogs-group"        = aws_cloudwatch_log_group.app.name
+          "awslogs-create-group" = "true"
+        }
+      }
+      secrets = [
+        { name = "DB_PASSWORD", valueFrom = aws_secretsmanager_secret.db.arn },
+        { name = "API_KEY", valueFrom = var.api_key },
+      ]
+    },
+  ])
+}
+
+resource "aws_ecs_task_definition" "worker" {
+  family                = "worker"
+  container_definitions = <<-EOT
+    [{
+      "name": "worker",
+      "image": "123456789012.dkr.ecr.us-east-1.amazonaws.com/worker:v1",
+      "repositoryCredentials": {"credentialsParameter": "arn:aws:secretsmanager:us-east-1:123456789012:secret:registry"},
+      "environmentFiles": [{"value": "arn:aws:s3:::config/${var.env}.env", "type": "s3"}]
+    }]
+  EOT
+}
+`)
+	result, err := New().ParseSource(context.Background(), "main.tf", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]interface{})
+	for _, res := range result.Resources {
+		got[res.Address()] = res.Attributes[provider.ContainerDefinitionsAttribute]
+	}
+	want := map[string]interface{}{
+		"aws_ecs_task_definition.app": []provider.ContainerDefinition{{
+			Name:           "app",
+			Image:          "${aws_ecr_repository.app.repository_url}",
+			LogGroup:       "${aws_cloudwatch_log_group.app.name}",
+			CreateLogGroup: true,
+			Secrets:        []string{"${aws_secretsmanager_secret.db.arn}", "*"},
+		}},
+		"aws_ecs_task_definition.worker": []provider.ContainerDefinition{{
+			Name:                  "worker",
+			Image:                 "123456789012.dkr.ecr.us-east-1.amazonaws.com/worker:v1",
+			RepositoryCredentials: "arn:aws:secretsmanager:us-east-1:123456789012:secret:registry",
+			EnvironmentFiles:      []string{"arn:aws:s3:::config/*.env"},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("container definitions = %+v, want %+v", got, want)
+	}
+}
@@ -3,6 +3,7 @@ package terraform
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 
 	"github.com/mizzy/least/internal/provider"
 )
@@ -11,10 +12,12 @@ import (
 func parseJSONPolicy(jsonStr string, filename string, line int) (*provider.IAMPolicy, error) {
 	var rawPolicy struct {
 		Statement []struct {
-			Sid      string      `json:"Sid"`
-			Effect   string      `json:"Effect"`
-			Action   interface{} `json:"Action"`
-			Resource interface{} `json:"Resource"`
+			Sid       string                            `json:"Sid"`
+			Effect    string                            `json:"Effect"`
+			Action    interface{}                       `json:"Action"`
+			Resource  interface{}                       `json:"Resource"`
+			Principal interface{}                       `json:"Principal"`
+			Condition map[string]map[string]interface{} `json:"Condition"`
 		} `json:"Statement"`
 	}
 
@@ -59,8 +62,75 @@ func parseJSONPolicy(jsonStr string, filename string, line int) (*provider.IAMPo
 			}
 		}
 
+		iamStmt.Principals = jsonPrincipals(stmt.Principal)
+		iamStmt.Conditions = jsonConditions(stmt.Condition)
+
 		policy.Statements = append(policy.Statements, iamStmt)
 	}
 
 	return policy, nil
 }
+
+// jsonPrincipals converts a Principal element, "*" or a map of principal
+// types to identifiers
+func jsonPrincipals(v interface{}) []provider.IAMPrincipal {
+	switch p := v.(type) {
+	case string:
+		return []provider.IAMPrincipal{{Type: "*", Identifiers: []string{p}}}
+	case map[string]interface{}:
+		types := make([]string, 0, len(p))
+		for typ := range p {
+			types = append(types, typ)
+		}
+		sort.Strings(types)
+
+		principals := make([]provider.IAMPrincipal, 0, len(types))
+		for _, typ := range types {
+			principals = append(principals, provider.IAMPrincipal{Type: typ, Identifiers: jsonStrings(p[typ])})
+		}
+		return principals
+	}
+	return nil
+}
+
+// jsonConditions converts a Condition element, sorted by operator and key
+func jsonConditions(c map[string]map[string]interface{}) []provider.IAMCondition {
+	tests := make([]string, 0, len(c))
+	for test := range c {
+		tests = append(tests, test)
+	}
+	sort.Strings(tests)
+
+	var conditions []provider.IAMCondition
+	for _, test := range tests {
+		variables := make([]string, 0, len(c[test]))
+		for variable := range c[test] {
+			variables = append(variables, variable)
+		}
+		sort.Strings(variables)
+		for _, variable := range variables {
+			conditions = append(conditions, provider.IAMCondition{
+				Test:     test,
+				Variable: variable,
+				Values:   jsonStrings(c[test][variable]),
+			})
+		}
+	}
+	return conditions
+}
+
+// jsonStrings converts a JSON scalar or array to strings
+func jsonStrings(v interface{}) []string {
+	switch val := v.(type) {
+	case []interface{}:
+		values := make([]string, 0, len(val))
+		for _, item := range val {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	case nil:
+		return nil
+	default:
+		return []string{fmt.Sprint(val)}
+	}
+}
@@ -0,0 +1,156 @@
+package terraform
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// kmsAttributes are the arguments naming the KMS key a resource encrypts
+// with, at the top level or in nested blocks (e.g., kms_master_key_id in the
+// server-side encryption rule of a bucket)
+var kmsAttributes = map[string]bool{
+	"kms_key_id":        true,
+	"kms_key_arn":       true,
+	"kms_master_key_id": true,
+}
+
+// kmsKeyTypes are the resource and data source types a key argument can
+// refer to, and the attribute holding the ARN of their key
+var kmsKeyTypes = map[string]string{
+	"aws_kms_key":         "arn",
+	"aws_kms_alias":       "target_key_arn",
+	"aws_kms_replica_key": "arn",
+}
+
+// bodyKMSKeys returns the customer-managed KMS keys the key arguments of a
+// body name, sorted and without duplicates: key addresses relative to the
+// module (e.g., "aws_kms_key.data", "data.aws_kms_key.shared") for
+// references, or literal key IDs, ARNs and aliases. AWS managed keys
+// (alias/aws/...) and keys from variables are left out.
+func bodyKMSKeys(body hcl.Body, ctx *hcl.EvalContext) []string {
+	syntaxBody, ok := body.(*hclsyntax.Body)
+	if !ok {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var walk func(b *hclsyntax.Body)
+	walk = func(b *hclsyntax.Body) {
+		for name, attr := range b.Attributes {
+			if !kmsAttributes[name] {
+				continue
+			}
+			if key := kmsKey(attr.Expr, ctx); key != "" {
+				seen[key] = true
+			}
+		}
+		for _, block := range b.Blocks {
+			walk(block.Body)
+		}
+	}
+	walk(syntaxBody)
+
+	return sortedKeys(seen)
+}
+
+// kmsKey returns the key a key argument names, or "" if it is unknown
+func kmsKey(expr hcl.Expression, ctx *hcl.EvalContext) string {
+	for _, traversal := range expr.Variables() {
+		if address := kmsKeyAddress(traversal); address != "" {
+			return address
+		}
+	}
+	val, diags := expr.Value(ctx)
+	if diags.HasErrors() || !val.IsWhollyKnown() || val.IsNull() || val.Type() != cty.String {
+		return ""
+	}
+	return customerManagedKey(val.AsString())
+}
+
+// kmsKeyAddress returns the address of the key resource or data source a
+// traversal starts with (e.g., "aws_kms_key.data" for aws_kms_key.data.arn)
+func kmsKeyAddress(traversal hcl.Traversal) string {
+	var steps []string
+	for _, step := range traversal {
+		switch s := step.(type) {
+		case hcl.TraverseRoot:
+			steps = append(steps, s.Name)
+		case hcl.TraverseAttr:
+			steps = append(steps, s.Name)
+		}
+		if len(steps) == 3 {
+			break
+		}
+	}
+	if len(steps) >= 3 && steps[0] == "data" {
+		if _, ok := kmsKeyTypes[steps[1]]; ok {
+			return strings.Join(steps[:3], ".")
+		}
+		return ""
+	}
+	if len(steps) >= 2 {
+		if _, ok := kmsKeyTypes[steps[0]]; ok {
+			return strings.Join(steps[:2], ".")
+		}
+	}
+	return ""
+}
+
+// isKeyAddress checks if a key of bodyKMSKeys is an address rather than a
+// literal key
+func isKeyAddress(key string) bool {
+	return strings.HasPrefix(key, "aws_") || strings.HasPrefix(key, "data.")
+}
+
+// customerManagedKey returns a literal key ID, ARN or alias, or "" for the
+// AWS managed keys, whose key policies are not under the account's control
+func customerManagedKey(key string) string {
+	if key == "" || strings.HasPrefix(key, "alias/aws/") || strings.Contains(key, ":alias/aws/") {
+		return ""
+	}
+	return key
+}
+
+// planKMSKeys returns the keys of the key arguments in the values of a plan
+// resource, at the top level or in nested blocks
+func planKMSKeys(values map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for name, value := range v {
+				if s, ok := value.(string); ok && kmsAttributes[name] {
+					if key := customerManagedKey(s); key != "" {
+						seen[key] = true
+					}
+					continue
+				}
+				walk(value)
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(values)
+	return sortedKeys(seen)
+}
+
+// sortedKeys returns the keys of a set, sorted
+func sortedKeys(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,76 @@
+package terraform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseKMSKeys(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("main.tf", `
+resource "aws_kms_key" "data" {}
+
+resource "aws_sqs_queue" "jobs" {
+  kms_master_key_id = aws_kms_key.data.arn
+}
+
+resource "aws_s3_bucket_server_side_encryption_configuration" "logs" {
+  bucket = "logs"
+  rule {
+    apply_server_side_encryption_by_default {
+      kms_master_key_id = "alias/aws/s3"
+    }
+  }
+}
+
+resource "aws_ebs_volume" "disk" {
+  kms_key_id = "arn:aws:kms:us-east-1:123456789012:key/abcd"
+}
+
+resource "aws_lambda_function" "fn" {
+  kms_key_arn = var.key_arn
+}
+
+module "app" {
+  source = "./app"
+}
+`)
+	write("app/main.tf", `
+resource "aws_kms_key" "app" {}
+
+resource "aws_sns_topic" "events" {
+  kms_master_key_id = aws_kms_key.app.id
+}
+`)
+
+	result, err := New().Parse(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][]string)
+	for _, res := range result.Resources {
+		if len(res.KMSKeys) > 0 {
+			got[res.FullAddress()] = res.KMSKeys
+		}
+	}
+	want := map[string][]string{
+		"aws_sqs_queue.jobs":              {"aws_kms_key.data"},
+		"aws_ebs_volume.disk":             {"arn:aws:kms:us-east-1:123456789012:key/abcd"},
+		"module.app.aws_sns_topic.events": {"module.app.aws_kms_key.app"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("KMS keys = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/mizzy/least/internal/mapping"
+	"github.com/mizzy/least/internal/provider"
+)
+
+// plan is the part of a plan JSON representation (terraform show -json)
+// that resources are read from
+type plan struct {
+	FormatVersion   string `json:"format_version"`
+	ResourceChanges []struct {
+		Mode   string `json:"mode"`
+		Type   string `json:"type"`
+		Name   string `json:"name"`
+		Change struct {
+			Actions []string               `json:"actions"`
+			Before  map[string]interface{} `json:"before"`
+			After   map[string]interface{} `json:"after"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+// ParsePlan parses the resources of a plan JSON representation. Unlike HCL,
+// a plan has the values of attributes computed from variables and other
+// resources, so ARNs can be built for them. Resources being deleted keep the
+// attributes they had before the change.
+func ParsePlan(filename string, data []byte) (*provider.ParseResult, error) {
+	var p plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parsing plan JSON: %w", err)
+	}
+	if p.FormatVersion == "" {
+		return nil, fmt.Errorf("%s is not a plan JSON representation (missing format_version)", filename)
+	}
+
+	result := &provider.ParseResult{}
+	for _, rc := range p.ResourceChanges {
+		if rc.Mode != "managed" {
+			continue
+		}
+
+		values := rc.Change.After
+		if values == nil {
+			values = rc.Change.Before
+		}
+		attrs := make(map[string]interface{})
+		for _, name := range slices.Concat(mapping.GetARNAttributes(rc.Type), contextAttributes[rc.Type]) {
+			if s, ok := values[name].(string); ok && s != "" {
+				attrs[name] = AttributeValue{Literal: s}
+			}
+		}
+		if defs := planContainerDefinitions(values); len(defs) > 0 {
+			attrs[provider.ContainerDefinitionsAttribute] = defs
+		}
+
+		result.Resources = append(result.Resources, provider.Resource{
+			Provider:      "terraform",
+			Type:          rc.Type,
+			Name:          rc.Name,
+			CloudProvider: detectCloudProvider(rc.Type),
+			Attributes:    attrs,
+			Location:      provider.SourceLocation{File: filename},
+			KMSKeys:       planKMSKeys(values),
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,128 @@
+package terraform
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+// bodyReferences returns the addresses of the resources and module calls
+// the arguments and nested blocks of a body refer to, relative to its
+// module, sorted and without duplicates. depends_on is an argument like
+// any other.
+func bodyReferences(body hcl.Body) []string {
+	syntaxBody, ok := body.(*hclsyntax.Body)
+	if !ok {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var walk func(b *hclsyntax.Body)
+	walk = func(b *hclsyntax.Body) {
+		for _, attr := range b.Attributes {
+			for _, traversal := range attr.Expr.Variables() {
+				if address := referenceAddress(traversal); address != "" {
+					seen[address] = true
+				}
+			}
+		}
+		for _, block := range b.Blocks {
+			walk(block.Body)
+		}
+	}
+	walk(syntaxBody)
+
+	if len(seen) == 0 {
+		return nil
+	}
+	addresses := make([]string, 0, len(seen))
+	for address := range seen {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// referenceAddress returns the address of the managed resource or module
+// call a traversal starts with (e.g., "aws_iam_role.app" for
+// aws_iam_role.app.arn, "module.network" for module.network.vpc_id), or ""
+// for variables, locals, data sources and other symbols
+func referenceAddress(traversal hcl.Traversal) string {
+	if len(traversal) < 2 {
+		return ""
+	}
+	attr, ok := traversal[1].(hcl.TraverseAttr)
+	if !ok {
+		return ""
+	}
+
+	switch root := traversal.RootName(); {
+	case root == "module":
+		return "module." + attr.Name
+	case root == "data" || !strings.Contains(root, "_"):
+		// Resource types are prefixed with their provider name; var, local,
+		// each, count, path, self and terraform are not
+		return ""
+	default:
+		return root + "." + attr.Name
+	}
+}
+
+// moduleCallReferences returns the references of the arguments of each
+// module call in files, relative to their module
+func moduleCallReferences(files []string) map[string][]string {
+	references := make(map[string][]string)
+	parser := hclparse.NewParser()
+	for _, filename := range files {
+		file, diags := parser.ParseHCLFile(filename)
+		if diags.HasErrors() {
+			continue
+		}
+		content, _, _ := file.Body.PartialContent(&hcl.BodySchema{
+			Blocks: []hcl.BlockHeaderSchema{{Type: "module", LabelNames: []string{"name"}}},
+		})
+		for _, block := range content.Blocks {
+			if refs := bodyReferences(block.Body); len(refs) > 0 {
+				references[block.Labels[0]] = refs
+			}
+		}
+	}
+	return references
+}
+
+// qualify prefixes addresses relative to a module with its address
+func qualify(module string, addresses []string) []string {
+	if module == "" || len(addresses) == 0 {
+		return addresses
+	}
+	qualified := make([]string, len(addresses))
+	for i, address := range addresses {
+		qualified[i] = module + "." + address
+	}
+	return qualified
+}
+
+// addressResources sets the module of resources parsed in the scope and
+// makes their references and key addresses full addresses, including the
+// references of the calls of the module
+func (s moduleScope) addressResources(resources []provider.Resource) {
+	for i := range resources {
+		resources[i].Module = s.address
+		resources[i].References = append(qualify(s.address, resources[i].References), s.references...)
+		if s.address != "" && len(resources[i].KMSKeys) > 0 {
+			keys := make([]string, len(resources[i].KMSKeys))
+			for j, key := range resources[i].KMSKeys {
+				if isKeyAddress(key) {
+					key = s.address + "." + key
+				}
+				keys[j] = key
+			}
+			resources[i].KMSKeys = keys
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package terraform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseReferences(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("main.tf", `
+resource "aws_kms_key" "logs" {}
+
+resource "aws_s3_bucket" "logs" {
+  bucket = "logs-${var.env}"
+
+  depends_on = [aws_sqs_queue.events]
+
+  server_side_encryption_configuration {
+    rule {
+      kms_master_key_id = aws_kms_key.logs.arn
+    }
+  }
+}
+
+resource "aws_sqs_queue" "events" {
+  name = data.aws_region.current.name
+}
+
+module "app" {
+  source = "./modules/app"
+  bucket = aws_s3_bucket.logs.id
+}
+
+module "app_copy" {
+  source = "./modules/app"
+}
+`)
+	write("modules/app/main.tf", `
+resource "aws_iam_role" "worker" {}
+
+resource "aws_lambda_function" "worker" {
+  role = aws_iam_role.worker.arn
+}
+`)
+
+	result, err := New().Parse(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	got := make(map[string][]string)
+	for _, res := range result.Resources {
+		got[res.FullAddress()] = res.References
+	}
+	want := map[string][]string{
+		"aws_kms_key.logs":                      nil,
+		"aws_s3_bucket.logs":                    {"aws_kms_key.logs", "aws_sqs_queue.events"},
+		"aws_sqs_queue.events":                  nil,
+		"module.app.aws_iam_role.worker":        {"aws_s3_bucket.logs"},
+		"module.app.aws_lambda_function.worker": {"module.app.aws_iam_role.worker", "aws_s3_bucket.logs"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("references = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,226 @@
+package terraform
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+// extractRequirements returns the permissions Terraform itself needs for a
+// file: access to the state of an S3 backend, the roles the aws provider and
+// the backend assume, and module archives downloaded from S3
+func extractRequirements(body hcl.Body, filename string) []provider.Requirement {
+	syntax, ok := body.(*hclsyntax.Body)
+	if !ok {
+		return nil
+	}
+
+	var reqs []provider.Requirement
+	for _, block := range syntax.Blocks {
+		loc := provider.SourceLocation{File: filename, Line: block.DefRange().Start.Line}
+		switch block.Type {
+		case "terraform":
+			for _, backend := range block.Body.Blocks {
+				if backend.Type == "backend" && len(backend.Labels) == 1 && backend.Labels[0] == "s3" {
+					loc.Line = backend.DefRange().Start.Line
+					reqs = append(reqs, s3BackendRequirements(backend.Body, loc)...)
+				}
+			}
+		case "provider":
+			if len(block.Labels) == 1 && block.Labels[0] == "aws" {
+				reqs = append(reqs, assumeRoleRequirements(block.Body, "aws provider", loc)...)
+			}
+		case "module":
+			source, _ := literalString(block.Body, "source")
+			if arn, ok := s3ModuleARN(source); ok && len(block.Labels) == 1 {
+				reqs = append(reqs, provider.Requirement{
+					Kind:        provider.RequirementRegistry,
+					Description: "module." + block.Labels[0] + " source",
+					Actions:     []string{"s3:GetObject"},
+					Resources:   []string{arn},
+					Location:    loc,
+				})
+			}
+		}
+	}
+	return reqs
+}
+
+// s3BackendRequirements returns the permissions to read and write the state
+// of an S3 backend and to hold its lock. Settings left to -backend-config
+// become "*".
+func s3BackendRequirements(body *hclsyntax.Body, loc provider.SourceLocation) []provider.Requirement {
+	bucket := literalOr(body, "bucket", "*")
+	key := literalOr(body, "key", "*")
+	region := literalOr(body, "region", "*")
+
+	objects := []string{"arn:aws:s3:::" + bucket + "/" + key}
+	if prefix, ok := literalString(body, "workspace_key_prefix"); ok {
+		objects = append(objects, "arn:aws:s3:::"+bucket+"/"+prefix+"/*/"+key)
+	}
+	reqs := []provider.Requirement{
+		{
+			Kind:        provider.RequirementState,
+			Description: "S3 backend bucket",
+			Actions:     []string{"s3:ListBucket"},
+			Resources:   []string{"arn:aws:s3:::" + bucket},
+			Location:    loc,
+		},
+		{
+			Kind:        provider.RequirementState,
+			Description: "S3 backend state",
+			Actions:     []string{"s3:GetObject", "s3:PutObject"},
+			Resources:   objects,
+			Location:    loc,
+		},
+	}
+
+	if lockfile, _ := literalBool(body, "use_lockfile"); lockfile {
+		locks := make([]string, len(objects))
+		for i, object := range objects {
+			locks[i] = object + ".tflock"
+		}
+		reqs = append(reqs, provider.Requirement{
+			Kind:        provider.RequirementState,
+			Description: "S3 backend lock file",
+			Actions:     []string{"s3:DeleteObject", "s3:GetObject", "s3:PutObject"},
+			Resources:   locks,
+			Location:    loc,
+		})
+	}
+	if table, ok := literalString(body, "dynamodb_table"); ok {
+		reqs = append(reqs, provider.Requirement{
+			Kind:        provider.RequirementState,
+			Description: "S3 backend lock table",
+			Actions:     []string{"dynamodb:DeleteItem", "dynamodb:DescribeTable", "dynamodb:GetItem", "dynamodb:PutItem"},
+			Resources:   []string{"arn:aws:dynamodb:" + region + ":*:table/" + table},
+			Location:    loc,
+		})
+	}
+
+	// The backend authenticates on its own, with role_arn before Terraform 1.6
+	if _, ok := body.Attributes["role_arn"]; ok {
+		reqs = append(reqs, provider.Requirement{
+			Kind:        provider.RequirementAuth,
+			Description: "S3 backend role",
+			Actions:     []string{"sts:AssumeRole"},
+			Resources:   []string{roleARN(body)},
+			Location:    loc,
+		})
+	}
+	return append(reqs, assumeRoleRequirements(body, "S3 backend", loc)...)
+}
+
+// assumeRoleRequirements returns the permissions to assume the roles of the
+// assume_role and assume_role_with_web_identity blocks of a provider or
+// backend configuration
+func assumeRoleRequirements(body *hclsyntax.Body, what string, loc provider.SourceLocation) []provider.Requirement {
+	var reqs []provider.Requirement
+	for _, block := range body.Blocks {
+		var actions []string
+		switch block.Type {
+		case "assume_role":
+			actions = []string{"sts:AssumeRole"}
+			_, tags := block.Body.Attributes["tags"]
+			_, transitive := block.Body.Attributes["transitive_tag_keys"]
+			if tags || transitive {
+				actions = append(actions, "sts:TagSession")
+			}
+		case "assume_role_with_web_identity":
+			actions = []string{"sts:AssumeRoleWithWebIdentity"}
+		default:
+			continue
+		}
+		reqs = append(reqs, provider.Requirement{
+			Kind:        provider.RequirementAuth,
+			Description: what + " role",
+			Actions:     actions,
+			Resources:   []string{roleARN(block.Body)},
+			Location:    provider.SourceLocation{File: loc.File, Line: block.DefRange().Start.Line},
+		})
+	}
+	return reqs
+}
+
+// s3ModuleARN returns the object ARN of an s3:: module source
+// (e.g., "s3::https://s3-eu-west-1.amazonaws.com/bucket/vpc.zip")
+func s3ModuleARN(source string) (string, bool) {
+	rest, ok := strings.CutPrefix(source, "s3::")
+	if !ok {
+		return "", false
+	}
+	u, err := url.Parse(rest)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	path := strings.TrimPrefix(u.Path, "/")
+	// Virtual-hosted style URLs name the bucket in the host
+	if !strings.HasPrefix(u.Host, "s3.") && !strings.HasPrefix(u.Host, "s3-") {
+		bucket, _, _ := strings.Cut(u.Host, ".s3")
+		path = bucket + "/" + path
+	}
+	if !strings.Contains(path, "/") {
+		return "", false
+	}
+	return "arn:aws:s3:::" + path, true
+}
+
+// roleARN returns the role_arn of a body, matching any role if it is not a
+// literal
+func roleARN(body *hclsyntax.Body) string {
+	if role := literalOr(body, "role_arn", "*"); role != "*" {
+		return role
+	}
+	return "arn:aws:iam::*:role/*"
+}
+
+// literalString returns the string value of an attribute, with "*" for one
+// that is not a literal, and whether the attribute is set
+func literalString(body *hclsyntax.Body, name string) (string, bool) {
+	attr, ok := body.Attributes[name]
+	if !ok {
+		return "", false
+	}
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || val.Type() != cty.String || !val.IsKnown() || val.IsNull() {
+		return "*", true
+	}
+	return val.AsString(), true
+}
+
+// literalOr returns the string value of an attribute, or def if it is not set
+func literalOr(body *hclsyntax.Body, name, def string) string {
+	if v, ok := literalString(body, name); ok {
+		return v
+	}
+	return def
+}
+
+// literalBool returns the value of a literal bool attribute
+func literalBool(body *hclsyntax.Body, name string) (bool, bool) {
+	attr, ok := body.Attributes[name]
+	if !ok {
+		return false, false
+	}
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || val.Type() != cty.Bool || val.IsNull() {
+		return false, false
+	}
+	return val.True(), true
+}
+
+// withoutState returns the requirements that are not backend state access
+func withoutState(reqs []provider.Requirement) []provider.Requirement {
+	var kept []provider.Requirement
+	for _, req := range reqs {
+		if req.Kind != provider.RequirementState {
+			kept = append(kept, req)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,83 @@
+package terraform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+func TestParseRequirements(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.tf": `terraform {
+  backend "s3" {
+    bucket         = "acme-tfstate"
+    key            = "app/terraform.tfstate"
+    region         = "us-east-1"
+    dynamodb_table = "tf-locks"
+    use_lockfile   = true
+  }
+}
+
+provider "aws" {
+  assume_role {
+    role_arn = var.role_arn
+    tags     = { team = "platform" }
+  }
+}
+
+module "network" {
+  source = "./modules/network"
+}
+`,
+		"modules/network/main.tf": `terraform {
+  backend "s3" {}
+}
+
+module "vpc" {
+  source = "s3::https://acme-modules.s3.eu-west-1.amazonaws.com/vpc.zip?version=2"
+}
+
+resource "aws_vpc" "main" {}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := New().Parse(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type requirement struct {
+		kind, description  string
+		actions, resources []string
+	}
+	var got []requirement
+	for _, req := range result.Requirements {
+		got = append(got, requirement{req.Kind, req.Description, req.Actions, req.Resources})
+	}
+	want := []requirement{
+		{provider.RequirementState, "S3 backend bucket", []string{"s3:ListBucket"}, []string{"arn:aws:s3:::acme-tfstate"}},
+		{provider.RequirementState, "S3 backend state", []string{"s3:GetObject", "s3:PutObject"}, []string{"arn:aws:s3:::acme-tfstate/app/terraform.tfstate"}},
+		{provider.RequirementState, "S3 backend lock file", []string{"s3:DeleteObject", "s3:GetObject", "s3:PutObject"}, []string{"arn:aws:s3:::acme-tfstate/app/terraform.tfstate.tflock"}},
+		{provider.RequirementState, "S3 backend lock table", []string{"dynamodb:DeleteItem", "dynamodb:DescribeTable", "dynamodb:GetItem", "dynamodb:PutItem"}, []string{"arn:aws:dynamodb:us-east-1:*:table/tf-locks"}},
+		{provider.RequirementAuth, "aws provider role", []string{"sts:AssumeRole", "sts:TagSession"}, []string{"arn:aws:iam::*:role/*"}},
+		// The backend of the child module is ignored, as by Terraform
+		{provider.RequirementRegistry, "module.vpc source", []string{"s3:GetObject"}, []string{"arn:aws:s3:::acme-modules/vpc.zip"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Requirements =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+// FindRoots walks dir and returns the Terraform root modules below it, in
+// lexical order: directories whose files configure a backend, Terraform
+// Cloud, or a provider. Hidden directories such as .terraform and .git are
+// skipped, as are directories matched by skip, which may be nil.
+func FindRoots(dir string, skip provider.PathFilter) ([]string, error) {
+	var roots []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != dir && (strings.HasPrefix(d.Name(), ".") || (skip != nil && skip(path, true))) {
+			return filepath.SkipDir
+		}
+		if isRoot(path) {
+			roots = append(roots, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return roots, nil
+}
+
+// isRoot checks if the Terraform files in dir contain a backend, cloud or
+// provider block. Files that fail to parse are ignored.
+func isRoot(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".tf") {
+			continue
+		}
+		filename := filepath.Join(dir, entry.Name())
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			continue
+		}
+		file, diags := hclsyntax.ParseConfig(src, filename, hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			continue
+		}
+
+		content, _, _ := file.Body.PartialContent(&hcl.BodySchema{
+			Blocks: []hcl.BlockHeaderSchema{
+				{Type: "terraform"},
+				{Type: "provider", LabelNames: []string{"name"}},
+			},
+		})
+		for _, block := range content.Blocks {
+			if block.Type == "provider" {
+				return true
+			}
+			settings, _, _ := block.Body.PartialContent(&hcl.BodySchema{
+				Blocks: []hcl.BlockHeaderSchema{
+					{Type: "backend", LabelNames: []string{"type"}},
+					{Type: "cloud"},
+				},
+			})
+			if len(settings.Blocks) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LocalModules returns the directories of the modules that the module in dir
+// calls with local sources, in lexical order. Registry and remote modules are
+// not part of the repository and are left out.
+func LocalModules(dir string) []string {
+	module, diags := tfconfig.LoadModule(dir)
+	if diags.HasErrors() {
+		return nil
+	}
+
+	var dirs []string
+	for _, call := range module.ModuleCalls {
+		if strings.HasPrefix(call.Source, "./") || strings.HasPrefix(call.Source, "../") {
+			dirs = append(dirs, filepath.Join(dir, call.Source))
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
@@ -0,0 +1,78 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindRoots(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"stacks/network/main.tf": "provider \"aws\" {\n  region = \"us-east-1\"\n}\n",
+		"stacks/app/backend.tf":  "terraform {\n  backend \"s3\" {}\n}\n",
+		"stacks/app/main.tf":     "module \"queue\" {\n  source = \"../../modules/queue\"\n}\n",
+		"stacks/cloud/main.tf":   "terraform {\n  cloud {}\n}\n",
+		"stacks/legacy/main.tf":  "terraform {\n  required_version = \">= 1.0\"\n}\n",
+		"modules/queue/main.tf":  "resource \"aws_sqs_queue\" \"jobs\" {}\n",
+		"examples/basic/main.tf": "provider \"aws\" {}\n",
+		".terraform/mod/main.tf": "provider \"aws\" {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	skipExamples := func(path string, isDir bool) bool {
+		return isDir && filepath.Base(path) == "examples"
+	}
+
+	roots, err := FindRoots(dir, skipExamples)
+	if err != nil {
+		t.Fatalf("FindRoots failed: %v", err)
+	}
+
+	want := []string{
+		filepath.Join(dir, "stacks/app"),
+		filepath.Join(dir, "stacks/cloud"),
+		filepath.Join(dir, "stacks/network"),
+	}
+	if !reflect.DeepEqual(roots, want) {
+		t.Errorf("FindRoots() = %v, want %v", roots, want)
+	}
+}
+
+func TestLocalModules(t *testing.T) {
+	dir := t.TempDir()
+	main := `
+module "queue" {
+  source = "../../modules/queue"
+}
+
+module "vpc" {
+  source = "terraform-aws-modules/vpc/aws"
+}
+
+module "bucket" {
+  source = "./bucket"
+}
+`
+	app := filepath.Join(dir, "stacks", "app")
+	if err := os.MkdirAll(app, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(app, "main.tf"), []byte(main), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{filepath.Join(dir, "modules", "queue"), filepath.Join(app, "bucket")}
+	if got := LocalModules(app); !reflect.DeepEqual(got, want) {
+		t.Errorf("LocalModules() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,213 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// fileScope holds what expressions of a file can be evaluated against
+type fileScope struct {
+	// dir is the directory of the file, used as path.module
+	dir string
+	ctx *hcl.EvalContext
+	// templates are the template files read while parsing the file
+	templates []string
+}
+
+// newFileScope creates the scope of a file. vars override the variable
+// defaults of the file.
+func newFileScope(filename string, body hcl.Body, vars Variables) *fileScope {
+	return &fileScope{
+		dir: filepath.Dir(filename),
+		ctx: fileEvalContext(body, vars),
+	}
+}
+
+// renderTemplateFile renders the template of a templatefile() call. Template
+// variables that cannot be evaluated are wildcarded like unresolved
+// interpolations.
+func (s *fileScope) renderTemplateFile(pathExpr, varsExpr hcl.Expression) (string, error) {
+	pathCtx := s.ctx.NewChild()
+	pathCtx.Variables = map[string]cty.Value{
+		"path": cty.ObjectVal(map[string]cty.Value{
+			"module": cty.StringVal(s.dir),
+			"root":   cty.StringVal(s.dir),
+			"cwd":    cty.StringVal("."),
+		}),
+	}
+	path, ok := templateValue(pathExpr, pathCtx)
+	if !ok {
+		return "", fmt.Errorf("template path uses dynamic reference")
+	}
+	if !filepath.IsAbs(path) {
+		if _, err := os.Stat(path); err != nil {
+			path = filepath.Join(s.dir, path)
+		}
+	}
+
+	s.templates = append(s.templates, path)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading template: %w", err)
+	}
+
+	vars := make(map[string]cty.Value)
+	if obj, ok := varsExpr.(*hclsyntax.ObjectConsExpr); ok {
+		for _, item := range obj.Items {
+			key, ok := templateValue(item.KeyExpr, nil)
+			if !ok {
+				continue
+			}
+			if val, diags := item.ValueExpr.Value(s.ctx); !diags.HasErrors() && val.IsWhollyKnown() {
+				vars[key] = val
+			}
+		}
+	}
+
+	expr, diags := hclsyntax.ParseTemplate(src, path, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return "", fmt.Errorf("parsing template %s: %s", path, diags.Error())
+	}
+	ctx := &hcl.EvalContext{Variables: vars}
+	if tmpl, ok := expr.(*hclsyntax.TemplateExpr); ok {
+		return renderTemplate(tmpl, ctx), nil
+	}
+	rendered, ok := templateValue(expr, ctx)
+	if !ok {
+		return "", fmt.Errorf("template %s cannot be rendered", path)
+	}
+	return rendered, nil
+}
+
+// fileEvalContext builds an evaluation context from the variable defaults
+// and locals of a file that evaluate to known values, with vars taking
+// precedence over defaults. Locals may refer to variables and to other
+// locals.
+func fileEvalContext(body hcl.Body, vars Variables) *hcl.EvalContext {
+	content, _, _ := body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "variable", LabelNames: []string{"name"}},
+			{Type: "locals"},
+		},
+	})
+
+	values := make(map[string]cty.Value)
+	pending := make(map[string]hcl.Expression)
+	for _, block := range content.Blocks {
+		switch block.Type {
+		case "variable":
+			attrs, _ := block.Body.JustAttributes()
+			if def, ok := attrs["default"]; ok {
+				if val, diags := def.Expr.Value(nil); !diags.HasErrors() && val.IsWhollyKnown() {
+					values[block.Labels[0]] = val
+				}
+			}
+		case "locals":
+			attrs, _ := block.Body.JustAttributes()
+			for name, attr := range attrs {
+				pending[name] = attr.Expr
+			}
+		}
+	}
+
+	for name, val := range vars {
+		values[name] = val
+	}
+
+	ctx := &hcl.EvalContext{Variables: map[string]cty.Value{
+		"var":   cty.ObjectVal(values),
+		"local": cty.EmptyObjectVal,
+	}}
+
+	// Resolve locals until no more can be evaluated
+	locals := make(map[string]cty.Value)
+	for len(pending) > 0 {
+		resolved := false
+		for name, expr := range pending {
+			val, diags := expr.Value(ctx)
+			if diags.HasErrors() || !val.IsWhollyKnown() {
+				continue
+			}
+			locals[name] = val
+			delete(pending, name)
+			resolved = true
+		}
+		if !resolved {
+			break
+		}
+		ctx.Variables["local"] = cty.ObjectVal(locals)
+	}
+
+	return ctx
+}
+
+// renderTemplate renders a heredoc or quoted template holding a JSON
+// document. Interpolations that evaluate in ctx are substituted; others
+// become "*", quoted when they stand for a whole JSON value.
+func renderTemplate(expr *hclsyntax.TemplateExpr, ctx *hcl.EvalContext) string {
+	var b strings.Builder
+	inString := false
+
+	for _, part := range expr.Parts {
+		if lit, ok := part.(*hclsyntax.LiteralValueExpr); ok && lit.Val.Type() == cty.String {
+			s := lit.Val.AsString()
+			b.WriteString(s)
+			inString = scanJSONString(s, inString)
+			continue
+		}
+
+		s, ok := templateValue(part, ctx)
+		switch {
+		case !ok && inString:
+			b.WriteString("*")
+		case !ok:
+			b.WriteString(`"*"`)
+		case inString:
+			quoted, _ := json.Marshal(s)
+			b.Write(quoted[1 : len(quoted)-1])
+		default:
+			b.WriteString(s)
+			inString = scanJSONString(s, inString)
+		}
+	}
+
+	return b.String()
+}
+
+// templateValue evaluates an interpolation to a string
+func templateValue(expr hcl.Expression, ctx *hcl.EvalContext) (string, bool) {
+	val, diags := expr.Value(ctx)
+	if diags.HasErrors() || !val.IsWhollyKnown() || val.IsNull() {
+		return "", false
+	}
+	str, err := convert.Convert(val, cty.String)
+	if err != nil {
+		return "", false
+	}
+	return str.AsString(), true
+}
+
+// scanJSONString returns whether a JSON string is open after s, given
+// whether one was open before it
+func scanJSONString(s string, inString bool) bool {
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && inString:
+			escaped = true
+		case r == '"':
+			inString = !inString
+		}
+	}
+	return inString
+}
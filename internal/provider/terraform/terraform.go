@@ -7,10 +7,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/hcl/v2"
-	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/terraform-config-inspect/tfconfig"
 	"github.com/zclconf/go-cty/cty"
@@ -21,16 +23,28 @@ import (
 
 // Provider implements the provider.Provider interface for Terraform
 type Provider struct {
-	parser *hclparse.Parser
+	skip    provider.PathFilter
+	workers int
+	cache   *Cache
+	vars    Variables
 }
 
 // New creates a new Terraform provider
 func New() *Provider {
 	return &Provider{
-		parser: hclparse.NewParser(),
+		workers: runtime.GOMAXPROCS(0),
 	}
 }
 
+// SetWorkers sets the number of files parsed concurrently; values below 1
+// parse serially
+func (p *Provider) SetWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	p.workers = n
+}
+
 // Name returns the provider identifier
 func (p *Provider) Name() string {
 	return "terraform"
@@ -71,28 +85,91 @@ func (p *Provider) Detect(path string) (bool, error) {
 	return false, nil
 }
 
-// Parse parses Terraform files and returns resources and policies
+// SetPathFilter sets the filter for files and module directories to skip
+func (p *Provider) SetPathFilter(filter provider.PathFilter) {
+	p.skip = filter
+}
+
+// SetCache sets the cache of parse results; nil disables caching
+func (p *Provider) SetCache(c *Cache) {
+	p.cache = c
+}
+
+// Parse parses Terraform files and returns resources and policies.
+// Files and module subtrees are parsed concurrently; results are merged in
+// file and module name order so the output order is stable across runs.
 func (p *Provider) Parse(ctx context.Context, path string) (*provider.ParseResult, error) {
-	result := &provider.ParseResult{
-		Resources: make([]provider.Resource, 0),
-		Policies:  make([]provider.IAMPolicy, 0),
+	workers := p.workers
+	if workers < 1 {
+		workers = 1
+	}
+	state := &parseState{
+		sem:     make(chan struct{}, workers),
+		visited: make(map[string]string),
+	}
+	if p.cache != nil {
+		state.salt = cacheSalt()
 	}
 
-	// Track visited paths to prevent infinite loops
-	visited := make(map[string]bool)
-
-	if err := p.parseWithModules(ctx, path, result, visited); err != nil {
+	result, err := p.parseWithModules(ctx, path, moduleScope{vars: p.vars}, state)
+	if err != nil {
 		return nil, err
 	}
+	result.AWSProviderVersion = awsProviderVersion(path)
 
 	return result, nil
 }
 
-// parseWithModules parses Terraform files and recursively processes module calls
-func (p *Provider) parseWithModules(ctx context.Context, path string, result *provider.ParseResult, visited map[string]bool) error {
+// parseState is shared by the goroutines of a single Parse call
+type parseState struct {
+	// sem bounds the number of files parsed at once
+	sem chan struct{}
+	// salt is the cache salt computed once per Parse
+	salt string
+
+	mu sync.Mutex
+	// visited maps the directories claimed for parsing to the address of
+	// the module they are parsed as
+	visited map[string]string
+}
+
+// visit claims a directory for the module address and reports whether it
+// is to be parsed: a directory is parsed once, as the first module that
+// claims it
+func (s *parseState) visit(dir, address string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if claimed, ok := s.visited[dir]; ok {
+		return claimed == address
+	}
+	s.visited[dir] = address
+	return true
+}
+
+// moduleScope is a module directory being parsed and how it was called
+type moduleScope struct {
+	// address is the module address (e.g., "module.app.module.db"), empty
+	// for the root module
+	address string
+	// references are the full addresses the arguments of the module call,
+	// and of the calls enclosing it, refer to
+	references []string
+	// vars are the input variables of the root module, nil for child modules
+	vars Variables
+}
+
+// parseWithModules parses Terraform files and recursively processes module
+// calls
+func (p *Provider) parseWithModules(ctx context.Context, path string, scope moduleScope, state *parseState) (*provider.ParseResult, error) {
+	vars := scope.vars
+	result := &provider.ParseResult{
+		Resources: make([]provider.Resource, 0),
+		Policies:  make([]provider.IAMPolicy, 0),
+	}
+
 	info, err := os.Stat(path)
 	if err != nil {
-		return fmt.Errorf("accessing path: %w", err)
+		return nil, fmt.Errorf("accessing path: %w", err)
 	}
 
 	var dir string
@@ -102,14 +179,15 @@ func (p *Provider) parseWithModules(ctx context.Context, path string, result *pr
 		dir = path
 		entries, err := os.ReadDir(path)
 		if err != nil {
-			return fmt.Errorf("reading directory: %w", err)
+			return nil, fmt.Errorf("reading directory: %w", err)
 		}
 		for _, entry := range entries {
 			if entry.IsDir() {
 				continue
 			}
-			if strings.HasSuffix(entry.Name(), ".tf") {
-				files = append(files, filepath.Join(path, entry.Name()))
+			file := filepath.Join(path, entry.Name())
+			if strings.HasSuffix(entry.Name(), ".tf") && (p.skip == nil || !p.skip(file, false)) {
+				files = append(files, file)
 			}
 		}
 	} else {
@@ -120,49 +198,176 @@ func (p *Provider) parseWithModules(ctx context.Context, path string, result *pr
 	// Normalize and check if already visited
 	absDir, err := filepath.Abs(dir)
 	if err != nil {
-		return fmt.Errorf("resolving absolute path: %w", err)
+		return nil, fmt.Errorf("resolving absolute path: %w", err)
 	}
-	if visited[absDir] {
-		return nil // Already processed this directory
+	if !state.visit(absDir, scope.address) {
+		return result, nil // Already processed this directory
 	}
-	visited[absDir] = true
 
-	// Parse files in current directory
-	for _, filePath := range files {
-		if err := p.parseFile(ctx, filePath, result); err != nil {
-			result.Errors = append(result.Errors, fmt.Errorf("parsing %s: %w", filePath, err))
+	if vars != nil {
+		vars = withDefaults(files, vars)
+	}
+
+	// Reuse cached results of unchanged files. Results depend on the
+	// variables, so directories parsed with them are not cached.
+	var cached, entry *dirEntry
+	var hashes map[string]string
+	if p.cache != nil && info.IsDir() && vars == nil {
+		cached = p.cache.load(absDir, state.salt)
+		hashes = hashTerraformFiles(path)
+		entry = &dirEntry{
+			Salt:    state.salt,
+			DirHash: hashDir(hashes),
+			Files:   make(map[string]fileEntry),
 		}
 	}
 
-	// Use terraform-config-inspect to find module calls
+	var calls map[string]string
+	var callReferences map[string][]string
 	if info.IsDir() {
-		module, diags := tfconfig.LoadModule(path)
-		if diags.HasErrors() {
-			result.Errors = append(result.Errors, fmt.Errorf("loading module info: %s", diags.Error()))
-			return nil // Continue without module parsing
+		var loadErr string
+		if cached != nil && cached.DirHash == entry.DirHash {
+			calls, callReferences, loadErr = cached.Modules, cached.ModuleReferences, cached.LoadError
+		} else {
+			module, diags := tfconfig.LoadModule(path)
+			if diags.HasErrors() {
+				loadErr = diags.Error()
+			} else {
+				calls = make(map[string]string, len(module.ModuleCalls))
+				for name, modCall := range module.ModuleCalls {
+					calls[name] = modCall.Source
+				}
+				callReferences = moduleCallReferences(files)
+			}
+		}
+		if entry != nil {
+			entry.Modules, entry.ModuleReferences, entry.LoadError = calls, callReferences, loadErr
+		}
+		if loadErr != "" {
+			result.Errors = append(result.Errors, fmt.Errorf("loading module info: %s", loadErr))
+		}
+	}
+
+	// Module calls are resolved while the files of this directory are parsed
+	names := make([]string, 0, len(calls))
+	for name := range calls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	modules := make([]*provider.ParseResult, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		modules[i] = &provider.ParseResult{}
+
+		modPath, err := p.resolveModuleSource(path, calls[name])
+		if err != nil {
+			modules[i].Errors = append(modules[i].Errors, fmt.Errorf("resolving module %q: %w", name, err))
+			continue
+		}
+
+		if p.skip != nil && modPath != "" && p.skip(modPath, true) {
+			continue
+		}
+
+		if modPath == "" {
+			// Remote module not downloaded yet
+			modules[i].Errors = append(modules[i].Errors, fmt.Errorf("module %q: remote module not found in .terraform/modules (run 'terraform init' first)", name))
+			continue
+		}
+
+		// Claim the module before parsing it concurrently, so a directory
+		// called several times is parsed as the first call in name order
+		child := moduleScope{
+			address:    qualify(scope.address, []string{"module." + name})[0],
+			references: append(qualify(scope.address, callReferences[name]), scope.references...),
+		}
+		if absMod, err := filepath.Abs(modPath); err == nil && !state.visit(absMod, child.address) {
+			continue
 		}
 
-		for name, modCall := range module.ModuleCalls {
-			modPath, err := p.resolveModuleSource(path, modCall.Source)
+		// Recursively parse the module
+		wg.Add(1)
+		go func(i int, name, modPath string) {
+			defer wg.Done()
+			modResult, err := p.parseWithModules(ctx, modPath, child, state)
 			if err != nil {
-				result.Errors = append(result.Errors, fmt.Errorf("resolving module %q: %w", name, err))
-				continue
+				modules[i].Errors = append(modules[i].Errors, fmt.Errorf("parsing module %q: %w", name, err))
+				return
 			}
+			modules[i] = modResult
+		}(i, name, modPath)
+	}
 
-			if modPath == "" {
-				// Remote module not downloaded yet
-				result.Errors = append(result.Errors, fmt.Errorf("module %q: remote module not found in .terraform/modules (run 'terraform init' first)", name))
+	// Parse files in current directory
+	fileResults := make([]*provider.ParseResult, len(files))
+	fileTemplates := make([]map[string]string, len(files))
+	for i, filePath := range files {
+		if cached != nil {
+			if c, ok := cached.Files[filePath]; ok && c.Hash != "" && c.Hash == hashes[filePath] && templatesUnchanged(c.Templates) {
+				fileResults[i] = c.Result.result()
+				fileTemplates[i] = c.Templates
 				continue
 			}
+		}
 
-			// Recursively parse the module
-			if err := p.parseWithModules(ctx, modPath, result, visited); err != nil {
-				result.Errors = append(result.Errors, fmt.Errorf("parsing module %q: %w", name, err))
+		fileResults[i] = &provider.ParseResult{}
+		wg.Add(1)
+		go func(i int, filePath string) {
+			defer wg.Done()
+			state.sem <- struct{}{}
+			defer func() { <-state.sem }()
+
+			if err := ctx.Err(); err != nil {
+				fileResults[i].Errors = append(fileResults[i].Errors, fmt.Errorf("parsing %s: %w", filePath, err))
+				return
+			}
+			templates, err := p.parseFile(ctx, filePath, vars, fileResults[i])
+			if err != nil {
+				fileResults[i].Errors = append(fileResults[i].Errors, fmt.Errorf("parsing %s: %w", filePath, err))
 			}
+			fileTemplates[i] = hashTemplates(templates)
+		}(i, filePath)
+	}
+	wg.Wait()
+
+	if entry != nil && ctx.Err() == nil {
+		changed := cached == nil || cached.DirHash != entry.DirHash
+		for i, filePath := range files {
+			hash, ok := hashes[filePath]
+			if !ok {
+				continue
+			}
+			entry.Files[filePath] = fileEntry{Hash: hash, Templates: fileTemplates[i], Result: toCached(fileResults[i])}
+			if _, ok := cached.filesEntry(filePath); !ok {
+				changed = true
+			}
+		}
+		if changed {
+			// The cache is best effort; a failed write only costs a re-parse
+			_ = p.cache.save(absDir, entry)
+		}
+	}
+
+	// Module loading errors are reported after the files of this directory,
+	// matching the order of a serial walk
+	loadErrors := result.Errors
+	result.Errors = nil
+	for _, r := range fileResults {
+		scope.addressResources(r.Resources)
+		if scope.address != "" {
+			// Terraform ignores the backends of child modules
+			r.Requirements = withoutState(r.Requirements)
 		}
+		result.Merge(r)
+	}
+	result.Errors = append(result.Errors, composePolicyDocuments(result.Policies)...)
+	result.Errors = append(result.Errors, loadErrors...)
+	for _, r := range modules {
+		result.Merge(r)
 	}
 
-	return nil
+	return result, nil
 }
 
 // resolveModuleSource resolves a module source to a local path
@@ -243,15 +448,36 @@ func (p *Provider) findModuleInManifest(manifestPath, source string) (string, er
 	return "", nil
 }
 
-func (p *Provider) parseFile(ctx context.Context, filename string, result *provider.ParseResult) error {
+// ParseSource parses a single HCL document. Module calls are not followed,
+// since relative sources cannot be resolved without a directory.
+func (p *Provider) ParseSource(ctx context.Context, filename string, src []byte) (*provider.ParseResult, error) {
+	result := &provider.ParseResult{
+		Resources: make([]provider.Resource, 0),
+		Policies:  make([]provider.IAMPolicy, 0),
+	}
+	if _, err := p.parseSource(ctx, filename, src, p.vars, result); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	result.Errors = append(result.Errors, composePolicyDocuments(result.Policies)...)
+	return result, nil
+}
+
+// parseFile parses a Terraform file and returns the template files it reads
+func (p *Provider) parseFile(ctx context.Context, filename string, vars Variables, result *provider.ParseResult) ([]string, error) {
 	src, err := os.ReadFile(filename)
 	if err != nil {
-		return fmt.Errorf("reading file: %w", err)
+		return nil, fmt.Errorf("reading file: %w", err)
 	}
+	return p.parseSource(ctx, filename, src, vars, result)
+}
 
-	file, diags := p.parser.ParseHCL(src, filename)
+// parseSource parses a Terraform document and returns the template files
+// read for templatefile() policies. With vars, resource attributes are
+// evaluated against them.
+func (p *Provider) parseSource(ctx context.Context, filename string, src []byte, vars Variables, result *provider.ParseResult) ([]string, error) {
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.Pos{Line: 1, Column: 1})
 	if diags.HasErrors() {
-		return fmt.Errorf("parsing HCL: %s", diags.Error())
+		return nil, fmt.Errorf("parsing HCL: %s", diags.Error())
 	}
 
 	// Extract AWS context (account/region references)
@@ -273,6 +499,14 @@ func (p *Provider) parseFile(ctx context.Context, filename string, result *provi
 		result.RegionRef = awsCtx.RegionRef
 	}
 
+	result.Requirements = append(result.Requirements, extractRequirements(file.Body, filename)...)
+
+	scope := newFileScope(filename, file.Body, vars)
+	var attrCtx *hcl.EvalContext
+	if vars != nil {
+		attrCtx = scope.ctx
+	}
+
 	content, _, diags := file.Body.PartialContent(&hcl.BodySchema{
 		Blocks: []hcl.BlockHeaderSchema{
 			{Type: "resource", LabelNames: []string{"type", "name"}},
@@ -280,7 +514,7 @@ func (p *Provider) parseFile(ctx context.Context, filename string, result *provi
 		},
 	})
 	if diags.HasErrors() {
-		return fmt.Errorf("extracting content: %s", diags.Error())
+		return nil, fmt.Errorf("extracting content: %s", diags.Error())
 	}
 
 	for _, block := range content.Blocks {
@@ -294,7 +528,9 @@ func (p *Provider) parseFile(ctx context.Context, filename string, result *provi
 		switch block.Type {
 		case "resource":
 			// Extract resource attributes needed for ARN construction
-			attrs := extractResourceAttributes(block.Body, resourceType)
+			attrs := extractResourceAttributes(block.Body, resourceType, attrCtx)
+			extractContextAttributes(block.Body, resourceType, attrCtx, attrs)
+			extractContainerDefinitions(block.Body, resourceType, attrCtx, attrs)
 
 			// Add to resources list
 			res := provider.Resource{
@@ -307,30 +543,54 @@ func (p *Provider) parseFile(ctx context.Context, filename string, result *provi
 					File: filename,
 					Line: block.DefRange.Start.Line,
 				},
+				References: bodyReferences(block.Body),
+				KMSKeys:    bodyKMSKeys(block.Body, attrCtx),
 			}
 			result.Resources = append(result.Resources, res)
 
 			// Check if this is an IAM policy resource
 			if isIAMPolicyResource(resourceType) {
-				policy, err := p.parseInlinePolicy(block, filename)
+				policy, err := p.parseInlinePolicy(block, filename, scope)
 				if err == nil && policy != nil {
+					policy.Name = resourceName
+					policy.Address = resourceType + "." + resourceName
 					result.Policies = append(result.Policies, *policy)
 				}
 			}
 
+			// Record managed policy attachments so they can be resolved later
+			if isPolicyAttachmentResource(resourceType) {
+				if attachment := parsePolicyAttachment(block, filename); attachment != nil {
+					result.PolicyAttachments = append(result.PolicyAttachments, *attachment)
+				}
+			}
+
 		case "data":
-			// Parse aws_iam_policy_document data sources
-			if resourceType == "aws_iam_policy_document" {
+			switch resourceType {
+			case "aws_iam_policy_document":
+				// Parse aws_iam_policy_document data sources
 				policy, err := p.parseIAMPolicyDocument(block, filename)
 				if err == nil && policy != nil {
 					policy.Name = resourceName
+					policy.Address = "data." + resourceType + "." + resourceName
 					result.Policies = append(result.Policies, *policy)
 				}
+			case "aws_iam_policy":
+				// Existing managed policy looked up by ARN
+				result.Policies = append(result.Policies, provider.IAMPolicy{
+					Name:    resourceName,
+					Address: "data." + resourceType + "." + resourceName,
+					ARN:     extractLiteralAttribute(block.Body, "arn"),
+					Location: provider.SourceLocation{
+						File: filename,
+						Line: block.DefRange.Start.Line,
+					},
+				})
 			}
 		}
 	}
 
-	return nil
+	return scope.templates, nil
 }
 
 func detectCloudProvider(resourceType string) string {
@@ -367,6 +627,74 @@ func isIAMPolicyResource(resourceType string) bool {
 	return false
 }
 
+func isPolicyAttachmentResource(resourceType string) bool {
+	attachmentResources := []string{
+		"aws_iam_role_policy_attachment",
+		"aws_iam_user_policy_attachment",
+		"aws_iam_group_policy_attachment",
+		"aws_iam_policy_attachment",
+	}
+	for _, ar := range attachmentResources {
+		if resourceType == ar {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePolicyAttachment extracts the attached policy ARN or reference
+func parsePolicyAttachment(block *hcl.Block, filename string) *provider.PolicyAttachment {
+	content, _, _ := block.Body.PartialContent(&hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: "policy_arn"}},
+	})
+	if content == nil {
+		return nil
+	}
+
+	attr, ok := content.Attributes["policy_arn"]
+	if !ok {
+		return nil
+	}
+
+	attachment := &provider.PolicyAttachment{
+		Address: block.Labels[0] + "." + block.Labels[1],
+		Location: provider.SourceLocation{
+			File: filename,
+			Line: block.DefRange.Start.Line,
+		},
+	}
+
+	val, diags := attr.Expr.Value(nil)
+	if !diags.HasErrors() && val.Type() == cty.String {
+		attachment.PolicyARN = val.AsString()
+	} else {
+		attachment.PolicyRef = extractExprReference(attr.Expr)
+	}
+
+	return attachment
+}
+
+// extractLiteralAttribute returns the literal string value of an attribute, or ""
+func extractLiteralAttribute(body hcl.Body, name string) string {
+	content, _, _ := body.PartialContent(&hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: name}},
+	})
+	if content == nil {
+		return ""
+	}
+
+	attr, ok := content.Attributes[name]
+	if !ok {
+		return ""
+	}
+
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || val.Type() != cty.String {
+		return ""
+	}
+	return val.AsString()
+}
+
 func (p *Provider) parseIAMPolicyDocument(block *hcl.Block, filename string) (*provider.IAMPolicy, error) {
 	policy := &provider.IAMPolicy{
 		Location: provider.SourceLocation{
@@ -376,6 +704,12 @@ func (p *Provider) parseIAMPolicyDocument(block *hcl.Block, filename string) (*p
 	}
 
 	content, _, diags := block.Body.PartialContent(&hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "source_policy_documents"},
+			{Name: "override_policy_documents"},
+			{Name: "source_json"},
+			{Name: "override_json"},
+		},
 		Blocks: []hcl.BlockHeaderSchema{
 			{Type: "statement"},
 		},
@@ -384,6 +718,18 @@ func (p *Provider) parseIAMPolicyDocument(block *hcl.Block, filename string) (*p
 		return nil, fmt.Errorf("extracting statements: %s", diags.Error())
 	}
 
+	// source_json and override_json are the deprecated single-document forms
+	for _, name := range []string{"source_json", "source_policy_documents"} {
+		if attr, ok := content.Attributes[name]; ok {
+			policy.SourceDocuments = append(policy.SourceDocuments, documentRefs(attr.Expr)...)
+		}
+	}
+	for _, name := range []string{"override_json", "override_policy_documents"} {
+		if attr, ok := content.Attributes[name]; ok {
+			policy.OverrideDocuments = append(policy.OverrideDocuments, documentRefs(attr.Expr)...)
+		}
+	}
+
 	for _, stmtBlock := range content.Blocks {
 		if stmtBlock.Type != "statement" {
 			continue
@@ -420,6 +766,23 @@ func (p *Provider) parseStatement(block *hcl.Block) (*provider.IAMStatement, err
 		return nil, fmt.Errorf("parsing statement: %s", diags.Error())
 	}
 
+	for _, b := range content.Blocks {
+		attrs, _ := b.Body.JustAttributes()
+		switch b.Type {
+		case "principals":
+			stmt.Principals = append(stmt.Principals, provider.IAMPrincipal{
+				Type:        attributeString(attrs, "type"),
+				Identifiers: attributeStrings(attrs, "identifiers"),
+			})
+		case "condition":
+			stmt.Conditions = append(stmt.Conditions, provider.IAMCondition{
+				Test:     attributeString(attrs, "test"),
+				Variable: attributeString(attrs, "variable"),
+				Values:   attributeStrings(attrs, "values"),
+			})
+		}
+	}
+
 	for name, attr := range content.Attributes {
 		val, valDiags := attr.Expr.Value(nil)
 		if valDiags.HasErrors() {
@@ -445,7 +808,7 @@ func (p *Provider) parseStatement(block *hcl.Block) (*provider.IAMStatement, err
 	return stmt, nil
 }
 
-func (p *Provider) parseInlinePolicy(block *hcl.Block, filename string) (*provider.IAMPolicy, error) {
+func (p *Provider) parseInlinePolicy(block *hcl.Block, filename string, scope *fileScope) (*provider.IAMPolicy, error) {
 	attrs, diags := block.Body.JustAttributes()
 	if diags.HasErrors() {
 		content, _, pDiags := block.Body.PartialContent(&hcl.BodySchema{
@@ -470,11 +833,24 @@ func (p *Provider) parseInlinePolicy(block *hcl.Block, filename string) (*provid
 		return parseJSONPolicy(val.AsString(), filename, block.DefRange.Start.Line)
 	}
 
+	// Render heredoc and quoted templates with interpolations, wildcarding
+	// the ones that cannot be resolved
+	if tmplExpr, ok := policyAttr.Expr.(*hclsyntax.TemplateExpr); ok {
+		return parseJSONPolicy(renderTemplate(tmplExpr, scope.ctx), filename, block.DefRange.Start.Line)
+	}
+
 	// Try to extract from jsonencode function call
 	if funcExpr, ok := policyAttr.Expr.(*hclsyntax.FunctionCallExpr); ok {
 		if funcExpr.Name == "jsonencode" && len(funcExpr.Args) > 0 {
 			return p.parseJsonencodeArg(funcExpr.Args[0], filename, block.DefRange.Start.Line)
 		}
+		if funcExpr.Name == "templatefile" && len(funcExpr.Args) == 2 {
+			rendered, err := scope.renderTemplateFile(funcExpr.Args[0], funcExpr.Args[1])
+			if err != nil {
+				return nil, err
+			}
+			return parseJSONPolicy(rendered, filename, block.DefRange.Start.Line)
+		}
 	}
 
 	return nil, fmt.Errorf("policy uses dynamic reference")
@@ -544,12 +920,93 @@ func parseStatementFromExpr(expr hcl.Expression) *provider.IAMStatement {
 			stmt.Actions = ctyToStringSlice(val)
 		case "Resource":
 			stmt.Resources = ctyToStringSlice(val)
+		case "Principal":
+			if types, values := objectItems(item.ValueExpr); len(types) > 0 {
+				for i, typ := range types {
+					stmt.Principals = append(stmt.Principals, provider.IAMPrincipal{Type: typ, Identifiers: exprStrings(values[i])})
+				}
+			} else if val.Type() == cty.String && val.IsKnown() && val.AsString() == "*" {
+				stmt.Principals = append(stmt.Principals, provider.IAMPrincipal{Type: "*", Identifiers: []string{"*"}})
+			}
+		case "Condition":
+			tests, keys := objectItems(item.ValueExpr)
+			for i, test := range tests {
+				variables, values := objectItems(keys[i])
+				for j, variable := range variables {
+					stmt.Conditions = append(stmt.Conditions, provider.IAMCondition{Test: test, Variable: variable, Values: exprStrings(values[j])})
+				}
+			}
 		}
 	}
 
 	return stmt
 }
 
+// attributeString returns the literal string value of an attribute
+func attributeString(attrs hcl.Attributes, name string) string {
+	attr, ok := attrs[name]
+	if !ok {
+		return ""
+	}
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || val.Type() != cty.String {
+		return ""
+	}
+	return val.AsString()
+}
+
+// attributeStrings returns the literal string values of a list attribute.
+// Values that are not literals (e.g., references) become "*".
+func attributeStrings(attrs hcl.Attributes, name string) []string {
+	attr, ok := attrs[name]
+	if !ok {
+		return nil
+	}
+	return exprStrings(attr.Expr)
+}
+
+// exprStrings returns the literal string values of a string or list
+// expression. Values that are not literals become "*".
+func exprStrings(expr hcl.Expression) []string {
+	if tuple, ok := expr.(*hclsyntax.TupleConsExpr); ok {
+		values := make([]string, 0, len(tuple.Exprs))
+		for _, e := range tuple.Exprs {
+			val, diags := e.Value(nil)
+			if diags.HasErrors() || val.Type() != cty.String {
+				values = append(values, "*")
+				continue
+			}
+			values = append(values, val.AsString())
+		}
+		return values
+	}
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() || !val.IsWhollyKnown() {
+		return []string{"*"}
+	}
+	return ctyToStringSlice(val)
+}
+
+// objectItems returns the keys and value expressions of an object
+// expression, in source order
+func objectItems(expr hcl.Expression) ([]string, []hcl.Expression) {
+	obj, ok := expr.(*hclsyntax.ObjectConsExpr)
+	if !ok {
+		return nil, nil
+	}
+	var keys []string
+	var values []hcl.Expression
+	for _, item := range obj.Items {
+		key, diags := item.KeyExpr.Value(nil)
+		if diags.HasErrors() || key.Type() != cty.String {
+			continue
+		}
+		keys = append(keys, key.AsString())
+		values = append(values, item.ValueExpr)
+	}
+	return keys, values
+}
+
 func ctyToStringSlice(val cty.Value) []string {
 	var result []string
 
@@ -580,8 +1037,9 @@ type AttributeValue struct {
 	Reference string // Variable reference (e.g., "var.bucket_name")
 }
 
-// extractResourceAttributes extracts attributes needed for ARN construction
-func extractResourceAttributes(body hcl.Body, resourceType string) map[string]interface{} {
+// extractResourceAttributes extracts attributes needed for ARN construction.
+// Expressions that evaluate to a string in ctx become literals; ctx may be nil.
+func extractResourceAttributes(body hcl.Body, resourceType string, ctx *hcl.EvalContext) map[string]interface{} {
 	attrs := make(map[string]interface{})
 
 	// Get the list of attributes we need for ARN construction
@@ -613,8 +1071,8 @@ func extractResourceAttributes(body hcl.Body, resourceType string) map[string]in
 		}
 
 		// Try to evaluate as a literal value
-		val, valDiags := attr.Expr.Value(nil)
-		if !valDiags.HasErrors() && val.Type() == cty.String {
+		val, valDiags := attr.Expr.Value(ctx)
+		if !valDiags.HasErrors() && val.IsWhollyKnown() && !val.IsNull() && val.Type() == cty.String {
 			attrs[attrName] = AttributeValue{Literal: val.AsString()}
 		} else {
 			// Extract as a variable reference
@@ -628,6 +1086,66 @@ func extractResourceAttributes(body hcl.Body, resourceType string) map[string]in
 	return attrs
 }
 
+// contextAttributes are the attributes besides those of ARNs that analyses
+// of a resource type need (e.g., the service of a VPC endpoint)
+var contextAttributes = map[string][]string{
+	"aws_vpc_endpoint":        {"service_name"},
+	"aws_ecs_task_definition": {"execution_role_arn"},
+	"aws_eks_node_group":      {"node_role_arn"},
+	"aws_pipes_pipe":          {"source"},
+}
+
+// extractContextAttributes adds the context attributes of a resource type to
+// attrs. Interpolations that cannot be evaluated in ctx, which may be nil,
+// become wildcards (e.g., "com.amazonaws.*.s3"); other expressions that
+// cannot be evaluated are kept as references.
+func extractContextAttributes(body hcl.Body, resourceType string, ctx *hcl.EvalContext, attrs map[string]interface{}) {
+	names := contextAttributes[resourceType]
+	if len(names) == 0 {
+		return
+	}
+	schema := make([]hcl.AttributeSchema, len(names))
+	for i, name := range names {
+		schema[i] = hcl.AttributeSchema{Name: name}
+	}
+	content, _, _ := body.PartialContent(&hcl.BodySchema{Attributes: schema})
+	if content == nil {
+		return
+	}
+	for _, name := range names {
+		if attr, ok := content.Attributes[name]; ok {
+			if literal := wildcardString(attr.Expr, ctx); literal != "" {
+				attrs[name] = AttributeValue{Literal: literal}
+			} else if ref := extractExprReference(attr.Expr); ref != "" {
+				attrs[name] = AttributeValue{Reference: ref}
+			}
+		}
+	}
+}
+
+// wildcardString evaluates a string expression, replacing the parts of a
+// template that cannot be evaluated with "*". Returns "" for other
+// expressions that cannot be evaluated.
+func wildcardString(expr hcl.Expression, ctx *hcl.EvalContext) string {
+	if val, diags := expr.Value(ctx); !diags.HasErrors() && val.IsWhollyKnown() && !val.IsNull() && val.Type() == cty.String {
+		return val.AsString()
+	}
+	tmpl, ok := expr.(*hclsyntax.TemplateExpr)
+	if !ok {
+		return ""
+	}
+	var b strings.Builder
+	for _, part := range tmpl.Parts {
+		val, diags := part.Value(ctx)
+		if !diags.HasErrors() && val.IsWhollyKnown() && !val.IsNull() && val.Type() == cty.String {
+			b.WriteString(val.AsString())
+		} else {
+			b.WriteString("*")
+		}
+	}
+	return b.String()
+}
+
 // extractExprReference extracts a Terraform reference string from an HCL expression
 func extractExprReference(expr hcl.Expression) string {
 	vars := expr.Variables()
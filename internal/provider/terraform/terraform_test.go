@@ -4,7 +4,11 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/mizzy/least/internal/provider"
 )
 
 func TestParse(t *testing.T) {
@@ -138,6 +142,432 @@ func TestParseRelativePath(t *testing.T) {
 	}
 }
 
+func TestParseWorkers(t *testing.T) {
+	testdataDir := findTestdataDir(t)
+
+	for _, pattern := range []string{"multi-file", "local-module", "nested-modules", "circular-ref"} {
+		t.Run(pattern, func(t *testing.T) {
+			path := filepath.Join(testdataDir, pattern)
+
+			serial := New()
+			serial.SetWorkers(1)
+			want, err := serial.Parse(context.Background(), path)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+
+			parallel := New()
+			parallel.SetWorkers(8)
+			for i := 0; i < 5; i++ {
+				got, err := parallel.Parse(context.Background(), path)
+				if err != nil {
+					t.Fatalf("Parse failed: %v", err)
+				}
+				if len(got.Resources) != len(want.Resources) {
+					t.Fatalf("got %d resources, want %d", len(got.Resources), len(want.Resources))
+				}
+				for j := range want.Resources {
+					if got.Resources[j].Type != want.Resources[j].Type || got.Resources[j].Name != want.Resources[j].Name {
+						t.Errorf("resource %d = %s.%s, want %s.%s", j,
+							got.Resources[j].Type, got.Resources[j].Name, want.Resources[j].Type, want.Resources[j].Name)
+					}
+				}
+				if len(got.Errors) != len(want.Errors) {
+					t.Errorf("got %d errors, want %d", len(got.Errors), len(want.Errors))
+				}
+			}
+		})
+	}
+}
+
+func TestParsePolicyAttachments(t *testing.T) {
+	testdataDir := findTestdataDir(t)
+	provider := New()
+
+	result, err := provider.Parse(context.Background(), filepath.Join(testdataDir, "policy-attachment", "iam"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if len(result.PolicyAttachments) != 2 {
+		t.Fatalf("got %d policy attachments, want 2", len(result.PolicyAttachments))
+	}
+
+	byAddress := make(map[string]string)
+	for _, att := range result.PolicyAttachments {
+		byAddress[att.Address] = att.PolicyARN + att.PolicyRef
+	}
+
+	if got := byAddress["aws_iam_role_policy_attachment.s3"]; got != "arn:aws:iam::aws:policy/AmazonS3FullAccess" {
+		t.Errorf("s3 attachment = %q, want literal ARN", got)
+	}
+	if got := byAddress["aws_iam_role_policy_attachment.logs"]; got != "data.aws_iam_policy.logs.arn" {
+		t.Errorf("logs attachment = %q, want data source reference", got)
+	}
+
+	if len(result.Policies) != 1 || result.Policies[0].ARN != "arn:aws:iam::aws:policy/CloudWatchLogsFullAccess" {
+		t.Errorf("expected data.aws_iam_policy.logs with literal ARN, got %+v", result.Policies)
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	src := []byte(`
+resource "aws_s3_bucket" "logs" {
+  bucket = "my-logs"
+}
+
+module "vpc" {
+  source = "./modules/vpc"
+}
+`)
+
+	result, err := New().ParseSource(context.Background(), "stdin", src)
+	if err != nil {
+		t.Fatalf("ParseSource failed: %v", err)
+	}
+	if len(result.Resources) != 1 || result.Resources[0].Address() != "aws_s3_bucket.logs" {
+		t.Fatalf("got %+v, want only aws_s3_bucket.logs", result.Resources)
+	}
+	if got := result.Resources[0].Location.String(); got != "stdin:2" {
+		t.Errorf("location = %q, want stdin:2", got)
+	}
+
+	if _, err := New().ParseSource(context.Background(), "stdin", []byte(`resource "x" {`)); err == nil {
+		t.Error("expected an error for invalid HCL")
+	}
+}
+
+func TestParseHeredocPolicy(t *testing.T) {
+	src := []byte(`
+variable "bucket" {
+  default = "my-logs"
+}
+
+locals {
+  prefix = "${var.bucket}/app"
+}
+
+resource "aws_iam_policy" "logs" {
+  policy = <<EOF
+{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": ["s3:GetObject", "s3:PutObject"],
+      "Resource": "arn:aws:s3:::${local.prefix}/*"
+    },
+    {
+      "Effect": "Allow",
+      "Action": "sqs:SendMessage",
+      "Resource": "arn:aws:sqs:${data.aws_region.current.name}:${var.account_id}:jobs"
+    },
+    {
+      "Effect": "Allow",
+      "Action": "sns:Publish",
+      "Resource": ${jsonencode(var.topic_arns)}
+    }
+  ]
+}
+EOF
+}
+`)
+
+	result, err := New().ParseSource(context.Background(), "stdin", src)
+	if err != nil {
+		t.Fatalf("ParseSource failed: %v", err)
+	}
+	if len(result.Policies) != 1 {
+		t.Fatalf("got %d policies, want 1", len(result.Policies))
+	}
+
+	statements := result.Policies[0].Statements
+	if len(statements) != 3 {
+		t.Fatalf("got %d statements, want 3", len(statements))
+	}
+	want := []string{
+		"arn:aws:s3:::my-logs/app/*",
+		"arn:aws:sqs:*:*:jobs",
+		"*",
+	}
+	for i, stmt := range statements {
+		if len(stmt.Resources) != 1 || stmt.Resources[0] != want[i] {
+			t.Errorf("statement %d resources = %v, want [%s]", i, stmt.Resources, want[i])
+		}
+	}
+	if got := statements[0].Actions; len(got) != 2 || got[1] != "s3:PutObject" {
+		t.Errorf("statement 0 actions = %v", got)
+	}
+}
+
+func TestParseTemplatefilePolicy(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("main.tf", `
+variable "bucket" {
+  default = "my-logs"
+}
+
+resource "aws_iam_policy" "logs" {
+  policy = templatefile("${path.module}/policies/logs.json.tpl", {
+    bucket = var.bucket
+    queue  = aws_sqs_queue.jobs.arn
+  })
+}
+`)
+	write("policies/logs.json.tpl", `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": "s3:GetObject",
+      "Resource": "arn:aws:s3:::${bucket}/*"
+    },
+    {
+      "Effect": "Allow",
+      "Action": "sqs:SendMessage",
+      "Resource": "${queue}"
+    }
+  ]
+}`)
+
+	p := New()
+	p.SetCache(NewCache(t.TempDir()))
+	resources := func() []string {
+		t.Helper()
+		result, err := p.Parse(context.Background(), dir)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		if len(result.Policies) != 1 {
+			t.Fatalf("got %d policies, want 1 (errors: %v)", len(result.Policies), result.Errors)
+		}
+		var got []string
+		for _, stmt := range result.Policies[0].Statements {
+			got = append(got, stmt.Resources...)
+		}
+		return got
+	}
+
+	if got, want := resources(), []string{"arn:aws:s3:::my-logs/*", "*"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resources = %v, want %v", got, want)
+	}
+
+	// A changed template invalidates the cached result of the file using it
+	write("policies/logs.json.tpl", `{"Statement": [{"Effect": "Allow", "Action": "s3:PutObject", "Resource": "arn:aws:s3:::${bucket}"}]}`)
+	if got, want := resources(), []string{"arn:aws:s3:::my-logs"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resources after template change = %v, want %v", got, want)
+	}
+}
+
+func TestParsePolicyDocumentComposition(t *testing.T) {
+	src := []byte(`
+data "aws_iam_policy_document" "base" {
+  statement {
+    sid       = "Read"
+    actions   = ["s3:GetObject"]
+    resources = ["*"]
+  }
+  statement {
+    sid       = "Queue"
+    actions   = ["sqs:SendMessage"]
+    resources = ["*"]
+  }
+}
+
+data "aws_iam_policy_document" "restrict" {
+  statement {
+    sid       = "Queue"
+    effect    = "Deny"
+    actions   = ["sqs:*"]
+    resources = ["*"]
+  }
+  statement {
+    actions   = ["logs:PutLogEvents"]
+    resources = ["*"]
+  }
+}
+
+data "aws_iam_policy_document" "app" {
+  source_policy_documents   = [data.aws_iam_policy_document.base.json]
+  override_policy_documents = [data.aws_iam_policy_document.restrict.json]
+
+  statement {
+    sid       = "Read"
+    actions   = ["s3:GetObject", "s3:ListBucket"]
+    resources = ["*"]
+  }
+  statement {
+    actions   = ["sns:Publish"]
+    resources = ["*"]
+  }
+}
+
+data "aws_iam_policy_document" "broken" {
+  source_policy_documents = [data.aws_iam_policy_document.missing.json]
+}
+`)
+
+	result, err := New().ParseSource(context.Background(), "stdin", src)
+	if err != nil {
+		t.Fatalf("ParseSource failed: %v", err)
+	}
+
+	var app []provider.IAMStatement
+	for _, p := range result.Policies {
+		if p.Address == "data.aws_iam_policy_document.app" {
+			app = p.Statements
+		}
+	}
+	want := []provider.IAMStatement{
+		{Sid: "Read", Effect: "Allow", Actions: []string{"s3:GetObject", "s3:ListBucket"}, Resources: []string{"*"}},
+		{Sid: "Queue", Effect: "Deny", Actions: []string{"sqs:*"}, Resources: []string{"*"}},
+		{Effect: "Allow", Actions: []string{"sns:Publish"}, Resources: []string{"*"}},
+		{Effect: "Allow", Actions: []string{"logs:PutLogEvents"}, Resources: []string{"*"}},
+	}
+	if !reflect.DeepEqual(app, want) {
+		t.Errorf("composed statements = %+v, want %+v", app, want)
+	}
+
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Error(), "data.aws_iam_policy_document.missing not found") {
+		t.Errorf("errors = %v, want one for the missing document", result.Errors)
+	}
+}
+
+func TestParsePrincipalsAndConditions(t *testing.T) {
+	src := []byte(`
+data "aws_iam_policy_document" "trust" {
+  statement {
+    actions = ["sts:AssumeRole"]
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+    condition {
+      test     = "StringEquals"
+      variable = "aws:SourceAccount"
+      values   = [data.aws_caller_identity.current.account_id]
+    }
+  }
+}
+
+resource "aws_iam_policy" "json" {
+  policy = <<EOF
+{
+  "Statement": [{
+    "Effect": "Allow",
+    "Action": "s3:GetObject",
+    "Resource": "*",
+    "Condition": {"Bool": {"aws:SecureTransport": true}}
+  }]
+}
+EOF
+}
+
+resource "aws_iam_role" "app" {
+  assume_role_policy = jsonencode({})
+}
+
+resource "aws_iam_policy" "encoded" {
+  policy = jsonencode({
+    Statement = [{
+      Effect    = "Allow"
+      Action    = "sts:AssumeRole"
+      Principal = { AWS = [aws_iam_role.app.arn] }
+    }]
+  })
+}
+`)
+
+	result, err := New().ParseSource(context.Background(), "stdin", src)
+	if err != nil {
+		t.Fatalf("ParseSource failed: %v", err)
+	}
+	statements := make(map[string]provider.IAMStatement)
+	for _, p := range result.Policies {
+		if len(p.Statements) == 1 {
+			statements[p.Address] = p.Statements[0]
+		}
+	}
+
+	trust := statements["data.aws_iam_policy_document.trust"]
+	wantPrincipals := []provider.IAMPrincipal{{Type: "Service", Identifiers: []string{"lambda.amazonaws.com"}}}
+	if !reflect.DeepEqual(trust.Principals, wantPrincipals) || !trust.IsTrust() {
+		t.Errorf("principals = %+v, want %+v", trust.Principals, wantPrincipals)
+	}
+	wantConditions := []provider.IAMCondition{{Test: "StringEquals", Variable: "aws:SourceAccount", Values: []string{"*"}}}
+	if !reflect.DeepEqual(trust.Conditions, wantConditions) {
+		t.Errorf("conditions = %+v, want %+v", trust.Conditions, wantConditions)
+	}
+
+	json := statements["aws_iam_policy.json"]
+	wantConditions = []provider.IAMCondition{{Test: "Bool", Variable: "aws:SecureTransport", Values: []string{"true"}}}
+	if !reflect.DeepEqual(json.Conditions, wantConditions) || json.IsTrust() {
+		t.Errorf("JSON statement = %+v, want conditions %+v and no principals", json, wantConditions)
+	}
+
+	encoded := statements["aws_iam_policy.encoded"]
+	wantPrincipals = []provider.IAMPrincipal{{Type: "AWS", Identifiers: []string{"*"}}}
+	if !reflect.DeepEqual(encoded.Principals, wantPrincipals) {
+		t.Errorf("jsonencode principals = %+v, want %+v", encoded.Principals, wantPrincipals)
+	}
+}
+
+func TestParsePlan(t *testing.T) {
+	plan := `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {
+      "address": "aws_s3_bucket.logs",
+      "mode": "managed", "type": "aws_s3_bucket", "name": "logs",
+      "change": {"actions": ["create"], "before": null, "after": {"bucket": "acme-logs"}}
+    },
+    {
+      "address": "module.queue.aws_sqs_queue.jobs",
+      "mode": "managed", "type": "aws_sqs_queue", "name": "jobs",
+      "change": {"actions": ["delete"], "before": {"name": "jobs"}, "after": null}
+    },
+    {
+      "address": "data.aws_caller_identity.current",
+      "mode": "data", "type": "aws_caller_identity", "name": "current",
+      "change": {"actions": ["read"], "before": null, "after": {}}
+    }
+  ]
+}`
+
+	result, err := ParsePlan("plan.json", []byte(plan))
+	if err != nil {
+		t.Fatalf("ParsePlan() error = %v", err)
+	}
+	if len(result.Resources) != 2 {
+		t.Fatalf("expected 2 managed resources, got %d", len(result.Resources))
+	}
+
+	bucket := result.Resources[0]
+	if bucket.Address() != "aws_s3_bucket.logs" || bucket.CloudProvider != "aws" {
+		t.Errorf("unexpected resource %s (%s)", bucket.Address(), bucket.CloudProvider)
+	}
+	if got, ok := bucket.Attributes["bucket"].(AttributeValue); !ok || got.Literal != "acme-logs" {
+		t.Errorf("bucket attribute = %#v, want literal acme-logs", bucket.Attributes["bucket"])
+	}
+	if got, ok := result.Resources[1].Attributes["name"].(AttributeValue); !ok || got.Literal != "jobs" {
+		t.Errorf("deleted queue name = %#v, want literal jobs from before", result.Resources[1].Attributes["name"])
+	}
+
+	if _, err := ParsePlan("main.tf.json", []byte(`{"resource": {}}`)); err == nil {
+		t.Error("expected error for a non-plan JSON document")
+	}
+}
+
 func TestDetect(t *testing.T) {
 	testdataDir := findTestdataDir(t)
 	provider := New()
@@ -215,3 +645,41 @@ func findTestdataDir(t *testing.T) string {
 	t.Fatal("testdata directory not found")
 	return ""
 }
+
+func TestParseContextAttributes(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte(`
+resource "aws_vpc_endpoint" "s3" {
+  service_name = "com.amazonaws.${var.region}.s3"
+}
+
+resource "aws_vpc_endpoint" "sqs" {
+  service_name = "com.amazonaws.us-east-1.sqs"
+}
+
+resource "aws_ecs_task_definition" "app" {
+  execution_role_arn = aws_iam_role.exec.arn
+}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := New().Parse(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]AttributeValue)
+	for _, res := range result.Resources {
+		for _, v := range res.Attributes {
+			got[res.Name] = v.(AttributeValue)
+		}
+	}
+	want := map[string]AttributeValue{
+		"s3":  {Literal: "com.amazonaws.*.s3"},
+		"sqs": {Literal: "com.amazonaws.us-east-1.sqs"},
+		"app": {Reference: "aws_iam_role.exec.arn"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("context attributes = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,82 @@
+package terraform
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Variables are values of root module input variables, as set by a var-file
+type Variables map[string]cty.Value
+
+// LoadVarFile reads the variables of a var-file (.tfvars, or .tfvars.json
+// in JSON syntax). Values must be constants, as Terraform requires.
+func LoadVarFile(path string) (Variables, error) {
+	parser := hclparse.NewParser()
+
+	var file *hcl.File
+	var diags hcl.Diagnostics
+	if strings.HasSuffix(path, ".json") {
+		file, diags = parser.ParseJSONFile(path)
+	} else {
+		file, diags = parser.ParseHCLFile(path)
+	}
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("parsing var-file: %s", diags.Error())
+	}
+
+	attrs, diags := file.Body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("reading var-file %s: %s", path, diags.Error())
+	}
+
+	vars := make(Variables, len(attrs))
+	for name, attr := range attrs {
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("variable %q in %s: %s", name, path, diags.Error())
+		}
+		vars[name] = val
+	}
+	return vars, nil
+}
+
+// SetVariables sets the input variables of the root module. Attributes of
+// root module resources are then evaluated with them, so ARNs are built
+// from the values of one environment instead of variable references.
+// Variables of child modules come from module blocks and are not affected.
+func (p *Provider) SetVariables(vars Variables) {
+	p.vars = vars
+}
+
+// withDefaults adds the defaults of the variables declared in files that
+// vars does not set, so expressions in one file can use the defaults of
+// variables declared in another
+func withDefaults(files []string, vars Variables) Variables {
+	merged := make(Variables, len(vars))
+	parser := hclparse.NewParser()
+	for _, filename := range files {
+		file, diags := parser.ParseHCLFile(filename)
+		if diags.HasErrors() {
+			continue
+		}
+		content, _, _ := file.Body.PartialContent(&hcl.BodySchema{
+			Blocks: []hcl.BlockHeaderSchema{{Type: "variable", LabelNames: []string{"name"}}},
+		})
+		for _, block := range content.Blocks {
+			attrs, _ := block.Body.JustAttributes()
+			if def, ok := attrs["default"]; ok {
+				if val, diags := def.Expr.Value(nil); !diags.HasErrors() && val.IsWhollyKnown() {
+					merged[block.Labels[0]] = val
+				}
+			}
+		}
+	}
+	for name, val := range vars {
+		merged[name] = val
+	}
+	return merged
+}
@@ -0,0 +1,89 @@
+package terraform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseVariables(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("variables.tf", `
+variable "env" {}
+
+variable "project" {
+  default = "shop"
+}
+`)
+	write("main.tf", `
+locals {
+  prefix = "${var.project}-${var.env}"
+}
+
+resource "aws_s3_bucket" "data" {
+  bucket = "${local.prefix}-data"
+}
+
+resource "aws_sqs_queue" "jobs" {
+  name = var.queue_name
+}
+`)
+	write("prod.tfvars", `env = "prod"`)
+	write("dev.tfvars.json", `{"env": "dev", "project": "lab"}`)
+
+	attribute := func(vars Variables, resourceType, name string) AttributeValue {
+		t.Helper()
+		p := New()
+		p.SetVariables(vars)
+		result, err := p.Parse(context.Background(), dir)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		for _, res := range result.Resources {
+			if res.Type == resourceType {
+				v, _ := res.Attributes[name].(AttributeValue)
+				return v
+			}
+		}
+		t.Fatalf("no %s resource", resourceType)
+		return AttributeValue{}
+	}
+
+	prod, err := LoadVarFile(filepath.Join(dir, "prod.tfvars"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := attribute(prod, "aws_s3_bucket", "bucket"); got.Literal != "shop-prod-data" {
+		t.Errorf("prod bucket = %+v, want shop-prod-data", got)
+	}
+
+	dev, err := LoadVarFile(filepath.Join(dir, "dev.tfvars.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := attribute(dev, "aws_s3_bucket", "bucket"); got.Literal != "lab-dev-data" {
+		t.Errorf("dev bucket = %+v, want lab-dev-data", got)
+	}
+
+	// Variables the var-file does not set stay references
+	if got := attribute(dev, "aws_sqs_queue", "name"); got.Literal != "" || got.Reference != "var.queue_name" {
+		t.Errorf("queue name = %+v, want reference var.queue_name", got)
+	}
+
+	// Without variables, attributes are not evaluated
+	if got := attribute(nil, "aws_s3_bucket", "bucket"); got.Literal != "" {
+		t.Errorf("bucket without variables = %+v, want a reference", got)
+	}
+
+	write("bad.tfvars", `env = var.other`)
+	if _, err := LoadVarFile(filepath.Join(dir, "bad.tfvars")); err == nil {
+		t.Error("LoadVarFile accepted a non-constant value")
+	}
+}
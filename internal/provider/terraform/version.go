@@ -0,0 +1,97 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/mizzy/least/internal/mapping"
+)
+
+// lockFile is the dependency lock file terraform init writes
+const lockFile = ".terraform.lock.hcl"
+
+// awsProviderAddress is the registry address of the hashicorp/aws provider
+const awsProviderAddress = "registry.terraform.io/hashicorp/aws"
+
+// awsProviderVersion returns the version of the hashicorp/aws provider of
+// the root module in dir: the version locked in its dependency lock file,
+// or else the lowest version the constraints of its required_providers
+// allow. It returns "" if neither is known.
+func awsProviderVersion(dir string) string {
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	if v := lockedVersion(filepath.Join(dir, lockFile)); v != "" {
+		return v
+	}
+
+	module, diags := tfconfig.LoadModule(dir)
+	if diags.HasErrors() {
+		return ""
+	}
+	req, ok := module.RequiredProviders["aws"]
+	if !ok || req.Source != "" && !strings.HasSuffix(strings.ToLower(req.Source), "hashicorp/aws") {
+		return ""
+	}
+	return lowestVersion(req.VersionConstraints)
+}
+
+// lockedVersion returns the hashicorp/aws version in a dependency lock file
+func lockedVersion(path string) string {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	file, diags := hclsyntax.ParseConfig(src, path, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return ""
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return ""
+	}
+	for _, block := range body.Blocks {
+		if block.Type != "provider" || len(block.Labels) != 1 || block.Labels[0] != awsProviderAddress {
+			continue
+		}
+		attr, ok := block.Body.Attributes["version"]
+		if !ok {
+			return ""
+		}
+		v, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() || v.Type() != cty.String {
+			return ""
+		}
+		return v.AsString()
+	}
+	return ""
+}
+
+// lowestVersion returns the lowest version version constraints (e.g.,
+// "~> 3.0", ">= 4.9, < 6.0") allow, ignoring upper bounds and exclusions
+func lowestVersion(constraints []string) string {
+	lowest := ""
+	for _, constraint := range constraints {
+		for _, part := range strings.Split(constraint, ",") {
+			part = strings.TrimSpace(part)
+			if strings.HasPrefix(part, "<") || strings.HasPrefix(part, "!=") {
+				continue
+			}
+			v := strings.TrimSpace(strings.TrimLeft(part, "~>="))
+			v = strings.TrimPrefix(v, "v")
+			if v == "" {
+				continue
+			}
+			if lowest == "" || mapping.CompareVersions(v, lowest) > 0 {
+				lowest = v
+			}
+		}
+	}
+	return lowest
+}
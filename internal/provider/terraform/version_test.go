@@ -0,0 +1,42 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAWSProviderVersion(t *testing.T) {
+	const versions = "terraform {\n  required_providers {\n    aws = {\n      source  = \"hashicorp/aws\"\n      version = \"~> 3.70, < 4.0\"\n    }\n  }\n}\n"
+	const lock = "provider \"registry.terraform.io/hashicorp/aws\" {\n  version     = \"3.76.1\"\n  constraints = \"~> 3.70\"\n}\n"
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "versions.tf"), []byte(versions), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := awsProviderVersion(dir); got != "3.70" {
+		t.Errorf("awsProviderVersion() from required_providers = %q, want 3.70", got)
+	}
+	if err := os.WriteFile(filepath.Join(dir, lockFile), []byte(lock), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := awsProviderVersion(dir); got != "3.76.1" {
+		t.Errorf("awsProviderVersion() from the lock file = %q, want 3.76.1", got)
+	}
+	if got := awsProviderVersion(t.TempDir()); got != "" {
+		t.Errorf("awsProviderVersion() without constraints = %q, want empty", got)
+	}
+
+	for _, tt := range []struct {
+		constraints []string
+		want        string
+	}{
+		{[]string{">= 4.9, < 6.0"}, "4.9"},
+		{[]string{"~> 5.0", ">= 5.20"}, "5.20"},
+		{[]string{"!= 5.1.0"}, ""},
+	} {
+		if got := lowestVersion(tt.constraints); got != tt.want {
+			t.Errorf("lowestVersion(%v) = %q, want %q", tt.constraints, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package risk
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mizzy/least/internal/policy"
+)
+
+// BroadGrantKind identifies why a grant is considered dangerously broad
+type BroadGrantKind string
+
+const (
+	// FullWildcardAction is an Action of "*" (or a NotAction grant)
+	FullWildcardAction BroadGrantKind = "full-wildcard-action"
+	// SensitiveServiceWildcard is a service-wide wildcard on a sensitive service (e.g., iam:*)
+	SensitiveServiceWildcard BroadGrantKind = "sensitive-service-wildcard"
+	// WildcardResource is a Resource of "*" combined with write actions
+	WildcardResource BroadGrantKind = "wildcard-resource"
+)
+
+// BroadGrant is a dangerously broad grant in a policy, reported even when the
+// policy covers every required action
+type BroadGrant struct {
+	Kind      BroadGrantKind
+	Statement string
+	Action    string
+	Severity  Severity
+	// Index is the position of the statement in the policy
+	Index int
+	// AllResources is whether the statement applies to Resource "*"
+	AllResources bool
+}
+
+// String returns a human-readable description of the grant
+func (g BroadGrant) String() string {
+	switch g.Kind {
+	case FullWildcardAction:
+		return fmt.Sprintf("%s grants every action in %s", g.Action, g.Statement)
+	case SensitiveServiceWildcard:
+		return fmt.Sprintf("%s grants a sensitive service in %s", g.Action, g.Statement)
+	default:
+		return fmt.Sprintf(`Resource "*" with write actions (e.g., %s) in %s`, g.Action, g.Statement)
+	}
+}
+
+// FindBroadGrants analyzes the Allow statements of a policy for wildcard risks
+func FindBroadGrants(p *policy.IAMPolicy) []BroadGrant {
+	var grants []BroadGrant
+
+	for i, stmt := range p.Statement {
+		if stmt.Effect != "Allow" {
+			continue
+		}
+		label := StatementLabel(stmt, i)
+		allResources := HasWildcardResource(stmt)
+
+		if len(stmt.NotAction) > 0 {
+			grants = append(grants, BroadGrant{
+				Kind:         FullWildcardAction,
+				Statement:    label,
+				Index:        i,
+				Action:       "NotAction " + strings.Join(stmt.NotAction, ", "),
+				Severity:     Critical,
+				AllResources: allResources,
+			})
+		}
+
+		for _, action := range stmt.Action {
+			switch {
+			case action == "*":
+				grants = append(grants, BroadGrant{
+					Kind:         FullWildcardAction,
+					Statement:    label,
+					Index:        i,
+					Action:       action,
+					Severity:     Critical,
+					AllResources: allResources,
+				})
+			case strings.HasSuffix(action, ":*") && Classify(action) >= High:
+				grants = append(grants, BroadGrant{
+					Kind:         SensitiveServiceWildcard,
+					Statement:    label,
+					Index:        i,
+					Action:       action,
+					Severity:     Classify(action),
+					AllResources: allResources,
+				})
+			}
+		}
+
+		if allResources {
+			if action, severity, ok := mostSevereWrite(stmt.Action); ok {
+				grants = append(grants, BroadGrant{
+					Kind:         WildcardResource,
+					Statement:    label,
+					Index:        i,
+					Action:       action,
+					Severity:     severity,
+					AllResources: true,
+				})
+			}
+		}
+	}
+
+	return grants
+}
+
+// StatementLabel identifies a statement by Sid or 1-based position
+func StatementLabel(stmt policy.Statement, index int) string {
+	if stmt.Sid != "" {
+		return fmt.Sprintf("statement %q", stmt.Sid)
+	}
+	return fmt.Sprintf("statement #%d", index+1)
+}
+
+// HasWildcardResource checks if a statement applies to every resource
+func HasWildcardResource(stmt policy.Statement) bool {
+	for _, r := range stmt.Resource {
+		if r == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// mostSevereWrite returns the most severe non-read-only action, if any.
+// Full wildcards are reported separately and skipped here.
+func mostSevereWrite(actions []string) (string, Severity, bool) {
+	var worst string
+	severity := Low
+	found := false
+
+	for _, action := range actions {
+		if action == "*" || isReadOnly(action) {
+			continue
+		}
+		s := Classify(action)
+		if s < Medium {
+			s = Medium
+		}
+		if !found || s > severity {
+			worst, severity, found = action, s, true
+		}
+	}
+
+	return worst, severity, found
+}
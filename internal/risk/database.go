@@ -0,0 +1,88 @@
+package risk
+
+// sensitiveActions is the built-in database of sensitive IAM actions.
+// Patterns may use a trailing wildcard.
+var sensitiveActions = []Rule{
+	// Privilege escalation
+	{"iam:PassRole", Critical, "can hand any role to a service"},
+	{"iam:CreatePolicyVersion", Critical, "can rewrite managed policies"},
+	{"iam:SetDefaultPolicyVersion", Critical, "can activate a more permissive policy version"},
+	{"iam:AttachRolePolicy", Critical, "can attach arbitrary policies to roles"},
+	{"iam:AttachUserPolicy", Critical, "can attach arbitrary policies to users"},
+	{"iam:AttachGroupPolicy", Critical, "can attach arbitrary policies to groups"},
+	{"iam:PutRolePolicy", Critical, "can write inline role policies"},
+	{"iam:PutUserPolicy", Critical, "can write inline user policies"},
+	{"iam:PutGroupPolicy", Critical, "can write inline group policies"},
+	{"iam:UpdateAssumeRolePolicy", Critical, "can change who may assume a role"},
+	{"iam:CreateAccessKey", Critical, "can mint credentials for other users"},
+	{"iam:CreateLoginProfile", Critical, "can set console passwords for other users"},
+	{"iam:UpdateLoginProfile", Critical, "can reset console passwords"},
+	{"iam:AddUserToGroup", Critical, "can join privileged groups"},
+	{"iam:DeleteRolePermissionsBoundary", Critical, "can lift permissions boundaries"},
+	{"iam:DeleteUserPermissionsBoundary", Critical, "can lift permissions boundaries"},
+	{"iam:CreateUser", High, "can create new principals"},
+	{"iam:CreateRole", High, "can create new principals"},
+	{"sts:AssumeRole", High, "can pivot into other roles"},
+	{"lambda:UpdateFunctionCode", High, "can run arbitrary code as the function role"},
+	{"lambda:AddPermission", High, "can expose functions to other principals"},
+	{"ec2:RunInstances", Medium, "can launch compute with instance profiles"},
+
+	// Organization and account control
+	{"organizations:LeaveOrganization", Critical, "can detach the account from SCPs"},
+	{"organizations:DeleteOrganization", Critical, "can delete the organization"},
+	{"organizations:DetachPolicy", Critical, "can remove service control policies"},
+	{"organizations:UpdatePolicy", Critical, "can rewrite service control policies"},
+	{"organizations:Create*", High, "can change organization structure"},
+	{"organizations:Invite*", High, "can change organization membership"},
+	{"organizations:Remove*", High, "can change organization membership"},
+	{"account:CloseAccount", Critical, "can close the account"},
+	{"account:Put*", High, "can change account settings"},
+
+	// Defense evasion
+	{"cloudtrail:StopLogging", Critical, "can disable audit logging"},
+	{"cloudtrail:DeleteTrail", Critical, "can delete audit trails"},
+	{"cloudtrail:UpdateTrail", High, "can redirect audit logs"},
+	{"cloudtrail:PutEventSelectors", High, "can filter audit events"},
+	{"guardduty:DeleteDetector", Critical, "can disable threat detection"},
+	{"guardduty:UpdateDetector", High, "can weaken threat detection"},
+	{"config:DeleteConfigurationRecorder", Critical, "can disable configuration recording"},
+	{"config:StopConfigurationRecorder", Critical, "can disable configuration recording"},
+	{"securityhub:DisableSecurityHub", Critical, "can disable Security Hub"},
+
+	// Encryption keys
+	{"kms:ScheduleKeyDeletion", Critical, "can destroy encryption keys and the data they protect"},
+	{"kms:PutKeyPolicy", Critical, "can grant anyone access to a key"},
+	{"kms:DisableKey", High, "can make encrypted data unreadable"},
+	{"kms:CreateGrant", High, "can delegate key usage"},
+	{"kms:Decrypt", High, "can read encrypted data"},
+
+	// Resource policies and public exposure
+	{"s3:PutBucketPolicy", High, "can make buckets public or cross-account"},
+	{"s3:DeleteBucketPolicy", High, "can remove bucket protections"},
+	{"s3:PutBucketAcl", High, "can make buckets public"},
+	{"s3:PutObjectAcl", High, "can make objects public"},
+	{"s3:PutBucketPublicAccessBlock", High, "can lift public access blocks"},
+	{"s3:PutAccountPublicAccessBlock", Critical, "can lift account-wide public access blocks"},
+	{"ec2:AuthorizeSecurityGroupIngress", Medium, "can open network access"},
+	{"ec2:ModifyImageAttribute", High, "can share machine images"},
+	{"ec2:ModifySnapshotAttribute", High, "can share volume snapshots"},
+	{"rds:ModifyDBSnapshotAttribute", High, "can share database snapshots"},
+
+	// Secrets and data access
+	{"secretsmanager:GetSecretValue", High, "can read secrets"},
+	{"ssm:GetParameter*", High, "can read parameters, including SecureStrings"},
+	{"s3:GetObject", Medium, "can read object data"},
+	{"dynamodb:Scan", Medium, "can read table data"},
+	{"dynamodb:Query", Medium, "can read table data"},
+	{"dynamodb:GetItem", Medium, "can read table data"},
+
+	// Destruction
+	{"s3:DeleteBucket", High, "can delete buckets"},
+	{"s3:DeleteObject", Medium, "can delete object data"},
+	{"dynamodb:DeleteTable", High, "can delete tables"},
+	{"rds:DeleteDBInstance", High, "can delete databases"},
+	{"rds:DeleteDBCluster", High, "can delete databases"},
+	{"ec2:TerminateInstances", High, "can terminate instances"},
+	{"backup:DeleteBackupVault", Critical, "can delete backups"},
+	{"backup:DeleteRecoveryPoint", Critical, "can delete backups"},
+}
@@ -0,0 +1,93 @@
+package risk
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/policy"
+)
+
+// denyServices are the services that control identities, the organization,
+// the account and auditing. They are denied entirely when no required
+// action belongs to them.
+var denyServices = []string{"account", "cloudtrail", "config", "guardduty", "iam", "organizations", "securityhub"}
+
+// DenyPolicy returns a policy of explicit Deny statements for the sensitive
+// actions the required policy does not grant, to layer on top of a broader
+// existing role as a stopgap until it is replaced by the required policy.
+// Services of denyServices no required action belongs to are denied with a
+// service wildcard (e.g., iam:*); otherwise the actions of the
+// sensitive-action database at or above min are denied unless a required
+// action overlaps them, so the Deny never blocks what the IaC needs.
+func DenyPolicy(required *policy.IAMPolicy, min Severity) *policy.IAMPolicy {
+	deny := &policy.IAMPolicy{Version: "2012-10-17"}
+
+	var actions []string
+	used := make(map[string]bool)
+	for _, stmt := range required.Statement {
+		if stmt.Effect != "Allow" {
+			continue
+		}
+		for _, action := range stmt.Action {
+			if action == "*" {
+				// Every action is required; nothing can be denied
+				return deny
+			}
+			actions = append(actions, action)
+			service, _, _ := strings.Cut(action, ":")
+			used[strings.ToLower(service)] = true
+		}
+	}
+
+	var services []string
+	denied := make(map[string]bool)
+	for _, service := range denyServices {
+		if !used[service] {
+			services = append(services, service+":*")
+			denied[service] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	var sensitive []string
+	for _, rule := range sensitiveActions {
+		service, _, _ := strings.Cut(rule.Action, ":")
+		if rule.Severity < min || denied[service] || seen[rule.Action] {
+			continue
+		}
+		if overlapsAny(rule.Action, actions) {
+			continue
+		}
+		seen[rule.Action] = true
+		sensitive = append(sensitive, rule.Action)
+	}
+	sort.Strings(sensitive)
+
+	if len(services) > 0 {
+		deny.Statement = append(deny.Statement, policy.Statement{
+			Sid:      "DenyUnusedServices",
+			Effect:   "Deny",
+			Action:   services,
+			Resource: []string{"*"},
+		})
+	}
+	if len(sensitive) > 0 {
+		deny.Statement = append(deny.Statement, policy.Statement{
+			Sid:      "DenyUnusedSensitiveActions",
+			Effect:   "Deny",
+			Action:   sensitive,
+			Resource: []string{"*"},
+		})
+	}
+	return deny
+}
+
+// overlapsAny checks if an action pattern overlaps any of the actions
+func overlapsAny(pattern string, actions []string) bool {
+	for _, action := range actions {
+		if overlaps(pattern, action) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+// Package risk classifies IAM actions by how dangerous it is to grant them.
+//
+// Classification is driven by a built-in database of sensitive actions
+// (privilege escalation, defense evasion, data access, destruction).
+// Actions not in the database fall back to a heuristic based on the
+// action verb, so an extra Describe is always ranked below an extra iam:*.
+package risk
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mizzy/least/internal/checker"
+)
+
+// Severity is the risk tier of a granted action
+type Severity int
+
+const (
+	Low Severity = iota
+	Medium
+	High
+	Critical
+)
+
+// String returns the lowercase name of the severity
+func (s Severity) String() string {
+	switch s {
+	case Critical:
+		return "critical"
+	case High:
+		return "high"
+	case Medium:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// ParseSeverity converts a severity name to a Severity
+func ParseSeverity(name string) (Severity, bool) {
+	switch strings.ToLower(name) {
+	case "critical":
+		return Critical, true
+	case "high":
+		return High, true
+	case "medium":
+		return Medium, true
+	case "low":
+		return Low, true
+	}
+	return Low, false
+}
+
+// readOnlyPrefixes are action verbs that only read metadata
+var readOnlyPrefixes = []string{"Describe", "Get", "List", "BatchGet", "Head"}
+
+// Classify returns the severity of granting an action or action pattern.
+// Wildcard patterns are ranked by the most sensitive action they cover.
+func Classify(action string) Severity {
+	// Full wildcards (including NotAction grants) cover every sensitive action
+	if strings.HasPrefix(action, "*") {
+		return Critical
+	}
+
+	severity := Low
+	matched := false
+	for _, rule := range sensitiveActions {
+		if overlaps(action, rule.Action) {
+			matched = true
+			if rule.Severity > severity {
+				severity = rule.Severity
+			}
+		}
+	}
+	if matched {
+		return severity
+	}
+
+	if isReadOnly(action) {
+		return Low
+	}
+	return Medium
+}
+
+// Rule describes a sensitive action and why it is sensitive
+type Rule struct {
+	Action   string
+	Severity Severity
+	Reason   string
+}
+
+// Rules returns the rules in the sensitive-action database that an action or pattern covers,
+// ordered from most to least severe
+func Rules(action string) []Rule {
+	var rules []Rule
+	for _, rule := range sensitiveActions {
+		if strings.HasPrefix(action, "*") || overlaps(action, rule.Action) {
+			rules = append(rules, rule)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Severity > rules[j].Severity
+	})
+	return rules
+}
+
+// SortBySeverity sorts actions from most to least severe, then alphabetically
+func SortBySeverity(actions []string) {
+	sort.SliceStable(actions, func(i, j int) bool {
+		si, sj := Classify(actions[i]), Classify(actions[j])
+		if si != sj {
+			return si > sj
+		}
+		return actions[i] < actions[j]
+	})
+}
+
+// isReadOnly checks if an action's verb only reads data or metadata
+func isReadOnly(action string) bool {
+	_, verb, ok := strings.Cut(action, ":")
+	if !ok {
+		return false
+	}
+	for _, prefix := range readOnlyPrefixes {
+		if strings.HasPrefix(verb, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlaps checks if two action patterns can match a common action, with
+// wildcards anywhere, as the checker matches them
+func overlaps(a, b string) bool {
+	return checker.MatchAction(a, b)
+}
@@ -0,0 +1,121 @@
+package risk
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mizzy/least/internal/policy"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		action string
+		want   Severity
+	}{
+		{"*", Critical},
+		{"* (NotAction: iam:*)", Critical},
+		{"iam:*", Critical},
+		{"iam:PassRole", Critical},
+		{"kms:ScheduleKeyDeletion", Critical},
+		{"organizations:*", Critical},
+		{"secretsmanager:GetSecretValue", High},
+		{"ec2:TerminateInstances", High},
+		{"sqs:SendMessage", Medium},
+		{"ec2:DescribeInstances", Low},
+		{"s3:ListBucket", Low},
+		{"s3:Get*", Medium},  // covers s3:GetObject
+		{"s3:Put*Acl", High}, // covers s3:PutBucketAcl
+		{"s3:*Acl", High},
+		{"sqs:Send?essage", Medium},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			if got := Classify(tt.action); got != tt.want {
+				t.Errorf("Classify(%q) = %v, want %v", tt.action, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortBySeverity(t *testing.T) {
+	actions := []string{"ec2:DescribeInstances", "sqs:SendMessage", "iam:*"}
+	SortBySeverity(actions)
+
+	want := []string{"iam:*", "sqs:SendMessage", "ec2:DescribeInstances"}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Fatalf("SortBySeverity() = %v, want %v", actions, want)
+		}
+	}
+}
+
+func TestFindBroadGrants(t *testing.T) {
+	p := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Sid: "Admin", Effect: "Allow", Action: []string{"*"}, Resource: []string{"*"}},
+			{Effect: "Allow", Action: []string{"iam:*", "sqs:*"}, Resource: []string{"arn:aws:iam::123456789012:role/app"}},
+			{Sid: "Read", Effect: "Allow", Action: []string{"ec2:Describe*"}, Resource: []string{"*"}},
+			{Sid: "Write", Effect: "Allow", Action: []string{"sqs:SendMessage"}, Resource: []string{"*"}},
+		},
+	}
+
+	grants := FindBroadGrants(p)
+
+	want := []struct {
+		kind      BroadGrantKind
+		statement string
+		action    string
+	}{
+		{FullWildcardAction, `statement "Admin"`, "*"},
+		{SensitiveServiceWildcard, "statement #2", "iam:*"},
+		{WildcardResource, `statement "Write"`, "sqs:SendMessage"},
+	}
+
+	if len(grants) != len(want) {
+		t.Fatalf("got %d grants, want %d: %+v", len(grants), len(want), grants)
+	}
+	for i, w := range want {
+		g := grants[i]
+		if g.Kind != w.kind || g.Statement != w.statement || g.Action != w.action {
+			t.Errorf("grant %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestDenyPolicy(t *testing.T) {
+	required := &policy.IAMPolicy{
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"iam:CreateRole", "iam:PassRole", "lambda:UpdateFunctionCode"}, Resource: []string{"*"}},
+			{Effect: "Allow", Action: []string{"s3:Put*"}, Resource: []string{"arn:aws:s3:::app/*"}},
+		},
+	}
+
+	deny := DenyPolicy(required, High)
+	if len(deny.Statement) != 2 {
+		t.Fatalf("got %d statements, want 2: %+v", len(deny.Statement), deny.Statement)
+	}
+
+	services := deny.Statement[0]
+	if services.Effect != "Deny" || strings.Join(services.Action, ",") != "account:*,cloudtrail:*,config:*,guardduty:*,organizations:*,securityhub:*" {
+		t.Errorf("services statement = %+v, want every unused control service except iam", services)
+	}
+
+	actions := strings.Join(deny.Statement[1].Action, ",")
+	for _, want := range []string{"iam:AttachRolePolicy", "iam:CreateUser", "kms:ScheduleKeyDeletion", "s3:DeleteBucket"} {
+		if !strings.Contains(actions, want) {
+			t.Errorf("sensitive actions %s do not deny %s", actions, want)
+		}
+	}
+	// Required actions, patterns they overlap, services denied entirely and
+	// actions below the severity stay out
+	for _, unwanted := range []string{"iam:PassRole", "iam:CreateRole", "lambda:UpdateFunctionCode", "s3:PutBucketPolicy", "organizations:", "ec2:RunInstances"} {
+		if strings.Contains(actions, unwanted) {
+			t.Errorf("sensitive actions %s deny %s", actions, unwanted)
+		}
+	}
+
+	if deny := DenyPolicy(&policy.IAMPolicy{Statement: []policy.Statement{{Effect: "Allow", Action: []string{"*"}}}}, Low); len(deny.Statement) != 0 {
+		t.Errorf("a full wildcard requirement left %+v", deny.Statement)
+	}
+}
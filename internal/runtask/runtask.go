@@ -0,0 +1,348 @@
+// Package runtask implements an HCP Terraform (Terraform Cloud) run task.
+//
+// HCP Terraform calls the run task with a run's details. The task answers
+// right away, then downloads the plan JSON, evaluates it, and reports
+// whether the run passes back to HCP Terraform, with a link to the detailed
+// results, which the task serves itself.
+package runtask
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureHeader carries the HMAC-SHA512 of the request body, set when the
+// run task is configured with an HMAC key
+const SignatureHeader = "X-Tfc-Task-Signature"
+
+// verificationToken is the access token of the request HCP Terraform sends to
+// verify a run task when it is created
+const verificationToken = "test-token"
+
+// DefaultHostname is the host of HCP Terraform
+const DefaultHostname = "app.terraform.io"
+
+const (
+	// defaultResultTTL is how long detailed results are served by default
+	defaultResultTTL = 24 * time.Hour
+	// defaultMaxResults bounds the detailed results kept by default
+	defaultMaxResults = 1000
+)
+
+// Request is the payload HCP Terraform sends to a run task
+type Request struct {
+	PayloadVersion        int    `json:"payload_version"`
+	Stage                 string `json:"stage"`
+	AccessToken           string `json:"access_token"`
+	TaskResultID          string `json:"task_result_id"`
+	TaskResultCallbackURL string `json:"task_result_callback_url"`
+	PlanJSONAPIURL        string `json:"plan_json_api_url"`
+	RunID                 string `json:"run_id"`
+	RunAppURL             string `json:"run_app_url"`
+	OrganizationName      string `json:"organization_name"`
+	WorkspaceName         string `json:"workspace_name"`
+}
+
+// Result is the outcome of evaluating a plan
+type Result struct {
+	Passed bool
+	// Message is the summary shown on the run
+	Message string
+	// Details are served at the results URL of the run
+	Details string
+}
+
+// Evaluator evaluates the plan JSON of a run
+type Evaluator func(ctx context.Context, req Request, plan []byte) (Result, error)
+
+// Server handles run task requests
+type Server struct {
+	// HMACKey verifies request signatures. Without it, requests are
+	// rejected unless Insecure is set.
+	HMACKey string
+	// Insecure accepts unsigned requests when HMACKey is empty
+	Insecure bool
+	// Hostname is the HCP Terraform or Terraform Enterprise host (with an
+	// optional port) plans are downloaded from and results are reported to;
+	// requests naming other hosts are rejected. Defaults to DefaultHostname.
+	Hostname string
+	// Client makes the requests to HCP Terraform (default: http.DefaultClient)
+	Client *http.Client
+	// PublicURL is where HCP Terraform users reach the server, used to link
+	// the detailed results of a run
+	PublicURL string
+	Evaluate  Evaluator
+	// Timeout bounds downloading and evaluating the plan of a run
+	Timeout time.Duration
+	// ResultTTL is how long detailed results are served (default: 24h)
+	ResultTTL time.Duration
+	// MaxResults bounds the detailed results kept; the oldest are dropped
+	// first (default: 1000)
+	MaxResults int
+
+	mu      sync.Mutex
+	results map[string]storedResult
+	// order lists the keys of results from oldest to newest
+	order []string
+	wg    sync.WaitGroup
+}
+
+// storedResult is the detailed result of a run served until it expires
+type storedResult struct {
+	details string
+	expires time.Time
+}
+
+// Handler returns the HTTP handler of the server: run task requests are
+// posted to / and results are served at /results/{key}, where the key is
+// random so results cannot be enumerated
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /{$}", s.handleTask)
+	mux.HandleFunc("GET /results/{id}", s.handleResults)
+	return mux
+}
+
+// Wait waits for the runs being evaluated to finish
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Server) handleTask(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "reading request", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case s.HMACKey != "":
+		if !VerifySignature(body, r.Header.Get(SignatureHeader), s.HMACKey) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+	case !s.Insecure:
+		http.Error(w, "no HMAC key configured", http.StatusUnauthorized)
+		return
+	}
+
+	var req Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if req.AccessToken == verificationToken || req.TaskResultCallbackURL == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// The access token is sent to these URLs, so they must be on the
+	// configured host
+	for _, u := range []string{req.TaskResultCallbackURL, req.PlanJSONAPIURL} {
+		if err := s.checkURL(u); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+
+	// HCP Terraform expects the request to be answered right away; the
+	// result is reported through the callback URL
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.run(context.Background(), req); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: run %s: %v\n", req.RunID, err)
+		}
+	}()
+}
+
+// checkURL checks that a URL of a run is an HTTPS URL on the configured host
+func (s *Server) checkURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	hostname := s.Hostname
+	if hostname == "" {
+		hostname = DefaultHostname
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || !strings.EqualFold(u.Host, hostname) {
+		return fmt.Errorf("URL not on https://%s: %s", hostname, raw)
+	}
+	return nil
+}
+
+func (s *Server) handleResults(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	stored, ok := s.results[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok || time.Now().After(stored.expires) {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	io.WriteString(w, stored.details)
+}
+
+// store keeps the detailed result of a run, dropping expired results and
+// the oldest ones beyond MaxResults, and returns the key it is served at
+func (s *Server) store(details string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(b)
+
+	ttl := s.ResultTTL
+	if ttl <= 0 {
+		ttl = defaultResultTTL
+	}
+	limit := s.MaxResults
+	if limit <= 0 {
+		limit = defaultMaxResults
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.results == nil {
+		s.results = make(map[string]storedResult)
+	}
+	now := time.Now()
+	s.results[key] = storedResult{details: details, expires: now.Add(ttl)}
+	s.order = append(s.order, key)
+
+	// Results expire in the order they are stored
+	for len(s.order) > 0 && (len(s.order) > limit || now.After(s.results[s.order[0]].expires)) {
+		delete(s.results, s.order[0])
+		s.order = s.order[1:]
+	}
+	return key, nil
+}
+
+// run evaluates the plan of a run and reports the result
+func (s *Server) run(ctx context.Context, req Request) error {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	result, err := s.evaluate(ctx, req)
+	if err != nil {
+		result = Result{Message: fmt.Sprintf("least failed to evaluate the plan: %v", err)}
+	}
+
+	resultsURL := ""
+	if result.Details != "" {
+		key, err := s.store(result.Details)
+		if err != nil {
+			return err
+		}
+		resultsURL = strings.TrimSuffix(s.PublicURL, "/") + "/results/" + key
+	}
+
+	return s.callback(ctx, req, result, resultsURL)
+}
+
+func (s *Server) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return http.DefaultClient
+}
+
+func (s *Server) evaluate(ctx context.Context, req Request) (Result, error) {
+	if req.PlanJSONAPIURL == "" {
+		return Result{Passed: true, Message: fmt.Sprintf("least has nothing to check in the %s stage", req.Stage)}, nil
+	}
+	plan, err := s.download(ctx, req)
+	if err != nil {
+		return Result{}, err
+	}
+	return s.Evaluate(ctx, req, plan)
+}
+
+// download fetches the plan JSON of a run
+func (s *Server) download(ctx context.Context, req Request) ([]byte, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.PlanJSONAPIURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+req.AccessToken)
+
+	resp, err := s.client().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("downloading plan: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading plan: HTTP %d", resp.StatusCode)
+	}
+	plan, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("downloading plan: %w", err)
+	}
+	return plan, nil
+}
+
+// callback reports the result of a run to HCP Terraform
+func (s *Server) callback(ctx context.Context, req Request, result Result, url string) error {
+	status := "failed"
+	if result.Passed {
+		status = "passed"
+	}
+	attributes := map[string]string{"status": status, "message": result.Message}
+	if url != "" {
+		attributes["url"] = url
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"data": map[string]interface{}{
+			"type":       "task-results",
+			"attributes": attributes,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPatch, req.TaskResultCallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+req.AccessToken)
+	httpReq.Header.Set("Content-Type", "application/vnd.api+json")
+
+	resp, err := s.client().Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("reporting result: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("reporting result: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// VerifySignature checks the HMAC-SHA512 signature of a request body
+func VerifySignature(body []byte, signature, key string) bool {
+	mac := hmac.New(sha512.New, []byte(key))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
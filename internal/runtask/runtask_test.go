@@ -0,0 +1,283 @@
+package runtask
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sign(body []byte, key string) string {
+	mac := hmac.New(sha512.New, []byte(key))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// fakeTFC serves plan JSON and records task result callbacks
+type fakeTFC struct {
+	callbacks []map[string]string
+}
+
+func (f *fakeTFC) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer run-token" {
+			t.Errorf("%s %s: Authorization = %q", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/plan":
+			io.WriteString(w, `{"format_version": "1.2"}`)
+		case r.Method == http.MethodPatch && r.URL.Path == "/callback":
+			var payload struct {
+				Data struct {
+					Type       string            `json:"type"`
+					Attributes map[string]string `json:"attributes"`
+				} `json:"data"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Errorf("decoding callback: %v", err)
+			}
+			f.callbacks = append(f.callbacks, payload.Data.Attributes)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	tfc := &fakeTFC{}
+	tfcServer := httptest.NewTLSServer(tfc.handler(t))
+	defer tfcServer.Close()
+	tfcURL, _ := url.Parse(tfcServer.URL)
+
+	tests := []struct {
+		name       string
+		result     Result
+		signature  func(body []byte) string
+		wantStatus int
+		want       map[string]string
+	}{
+		{
+			name:       "passed",
+			result:     Result{Passed: true, Message: "compliant"},
+			signature:  func(body []byte) string { return sign(body, "secret") },
+			wantStatus: http.StatusOK,
+			want:       map[string]string{"status": "passed", "message": "compliant"},
+		},
+		{
+			name:       "failed with details",
+			result:     Result{Message: "2 missing", Details: "# report"},
+			signature:  func(body []byte) string { return sign(body, "secret") },
+			wantStatus: http.StatusOK,
+			want:       map[string]string{"status": "failed", "message": "2 missing", "url": "https://least.example.com/results/"},
+		},
+		{
+			name:       "bad signature",
+			signature:  func(body []byte) string { return sign(body, "other") },
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tfc.callbacks = nil
+			var gotPlan string
+			server := &Server{
+				HMACKey:   "secret",
+				Hostname:  tfcURL.Host,
+				Client:    tfcServer.Client(),
+				PublicURL: "https://least.example.com/",
+				Evaluate: func(ctx context.Context, req Request, plan []byte) (Result, error) {
+					gotPlan = string(plan)
+					return tt.result, nil
+				},
+			}
+			ts := httptest.NewServer(server.Handler())
+			defer ts.Close()
+
+			body, _ := json.Marshal(Request{
+				PayloadVersion:        1,
+				Stage:                 "post_plan",
+				AccessToken:           "run-token",
+				TaskResultID:          "taskresult-1",
+				TaskResultCallbackURL: tfcServer.URL + "/callback",
+				PlanJSONAPIURL:        tfcServer.URL + "/plan",
+				RunID:                 "run-1",
+			})
+			req, _ := http.NewRequest(http.MethodPost, ts.URL+"/", bytes.NewReader(body))
+			req.Header.Set(SignatureHeader, tt.signature(body))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			server.Wait()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.want == nil {
+				if len(tfc.callbacks) != 0 {
+					t.Errorf("unexpected callbacks %v", tfc.callbacks)
+				}
+				return
+			}
+			if gotPlan != `{"format_version": "1.2"}` {
+				t.Errorf("evaluated plan = %q", gotPlan)
+			}
+			if len(tfc.callbacks) != 1 {
+				t.Fatalf("got %d callbacks, want 1", len(tfc.callbacks))
+			}
+			// Results are served at a random key
+			callback := tfc.callbacks[0]
+			resultsURL := callback["url"]
+			if !strings.HasPrefix(resultsURL, tt.want["url"]) || strings.Contains(resultsURL, "taskresult-1") {
+				t.Errorf("results URL = %q, want a random key under %q", resultsURL, tt.want["url"])
+			}
+			if resultsURL != "" {
+				callback["url"] = tt.want["url"]
+			}
+			got, _ := json.Marshal(callback)
+			want, _ := json.Marshal(tt.want)
+			if string(got) != string(want) {
+				t.Errorf("callback = %s, want %s", got, want)
+			}
+
+			if tt.result.Details != "" {
+				resp, err := http.Get(ts.URL + strings.TrimPrefix(resultsURL, "https://least.example.com"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				details, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if !strings.Contains(string(details), tt.result.Details) {
+					t.Errorf("results = %q, want %q", details, tt.result.Details)
+				}
+			}
+		})
+	}
+}
+
+func TestVerificationRequest(t *testing.T) {
+	server := &Server{
+		Insecure: true,
+		Evaluate: func(ctx context.Context, req Request, plan []byte) (Result, error) {
+			t.Error("verification request was evaluated")
+			return Result{}, nil
+		},
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/", "application/json", strings.NewReader(`{"payload_version": 1, "access_token": "test-token", "task_result_callback_url": "https://app.terraform.io/api/v2/task-results/x/callback"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	server.Wait()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestUnsignedRequests(t *testing.T) {
+	server := &Server{
+		Evaluate: func(ctx context.Context, req Request, plan []byte) (Result, error) {
+			t.Error("unsigned request was evaluated")
+			return Result{}, nil
+		},
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	// Without an HMAC key, requests are rejected unless the server is insecure
+	resp, err := http.Post(ts.URL+"/", "application/json", strings.NewReader(`{"payload_version": 1, "access_token": "test-token"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestUntrustedURLs(t *testing.T) {
+	server := &Server{
+		HMACKey: "secret",
+		Evaluate: func(ctx context.Context, req Request, plan []byte) (Result, error) {
+			t.Error("request with untrusted URLs was evaluated")
+			return Result{}, nil
+		},
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	tests := []struct {
+		callback, plan string
+	}{
+		{"https://169.254.169.254/latest/meta-data", "https://app.terraform.io/api/v2/plans/1/json-output"},
+		{"https://app.terraform.io/api/v2/task-results/1/callback", "http://app.terraform.io/api/v2/plans/1/json-output"},
+		{"https://app.terraform.io.evil.example/callback", ""},
+	}
+	for _, tt := range tests {
+		body, _ := json.Marshal(Request{AccessToken: "run-token", TaskResultCallbackURL: tt.callback, PlanJSONAPIURL: tt.plan})
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/", bytes.NewReader(body))
+		req.Header.Set(SignatureHeader, sign(body, "secret"))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		server.Wait()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("callback %s, plan %s: status = %d, want 400", tt.callback, tt.plan, resp.StatusCode)
+		}
+	}
+}
+
+func TestStoreBounded(t *testing.T) {
+	server := &Server{MaxResults: 2, ResultTTL: time.Hour}
+
+	var keys []string
+	for _, details := range []string{"first", "second", "third"} {
+		key, err := server.store(details)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+
+	if len(server.results) != 2 {
+		t.Errorf("kept %d results, want 2", len(server.results))
+	}
+	if _, ok := server.results[keys[0]]; ok {
+		t.Error("oldest result should be dropped")
+	}
+
+	// Expired results are not served and are dropped on the next store
+	server.results[keys[1]] = storedResult{details: "second", expires: time.Now().Add(-time.Minute)}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/results/" + keys[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expired result status = %d, want 404", resp.StatusCode)
+	}
+	if _, err := server.store("fourth"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.results[keys[1]]; ok {
+		t.Error("expired result should be dropped")
+	}
+}
@@ -0,0 +1,325 @@
+//go:build ignore
+
+// This program regenerates the embedded Service Authorization Reference
+// snapshot (internal/sar/data/actions.json) from the machine-readable
+// reference published by AWS.
+// Run with: go generate ./internal/sar
+//
+// With -models, services the snapshot lacks are added from the API models
+// of the AWS SDK (the models/apis directory of github.com/aws/aws-sdk-go):
+// the actions of a service are its API operations, plus the actions without
+// an operation listed in permissionOnly. Services newer than those models
+// are added with -sdk from a directory of service modules of
+// github.com/aws/aws-sdk-go-v2 (downloaded with go mod download), whose
+// operations are their api_op_*.go files. Only add modules whose operations
+// are named like their IAM actions (e.g., not signin). With -offline, the
+// reference is not fetched and the services of the current snapshot are kept
+// instead:
+//
+//	go run ./gen/main.go -offline -models $(go env GOMODCACHE)/github.com/aws/aws-sdk-go@v1.55.8/models/apis -sdk DIR
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// indexURL lists every service and the URL of its reference
+const indexURL = "https://servicereference.us-east-1.amazonaws.com/"
+
+type indexEntry struct {
+	Service string `json:"service"`
+	URL     string `json:"url"`
+}
+
+type serviceReference struct {
+	Name    string `json:"Name"`
+	Actions []struct {
+		Name string `json:"Name"`
+	} `json:"Actions"`
+}
+
+// apiModel is the part of an AWS SDK API model naming its operations
+type apiModel struct {
+	Metadata struct {
+		EndpointPrefix string `json:"endpointPrefix"`
+		SigningName    string `json:"signingName"`
+	} `json:"metadata"`
+	Operations map[string]json.RawMessage `json:"operations"`
+}
+
+// prefixes maps the signing names of API models whose service prefix in IAM
+// differs
+var prefixes = map[string]string{
+	"awsssoportal": "sso",
+	"email":        "ses",
+	"iotdata":      "iot",
+	"monitoring":   "cloudwatch",
+	"tagging":      "tag",
+}
+
+// signingNamePattern finds the signing name in the auth.go of an AWS SDK for
+// Go v2 service module
+var signingNamePattern = regexp.MustCompile(`SetSigV4SigningName\(&props, "([^"]+)"\)`)
+
+// operationless are services whose actions are not named after their API
+// operations; only their permissionOnly actions are added
+var operationless = map[string]bool{
+	"apigateway":  true,
+	"execute-api": true,
+}
+
+// permissionOnly are actions without an API operation of the same name,
+// which API models cannot supply
+var permissionOnly = map[string][]string{
+	"apigateway":           {"AddCertificateToDomain", "DELETE", "GET", "PATCH", "POST", "PUT", "RemoveCertificateFromDomain", "SetWebACL", "UpdateRestApiPolicy"},
+	"dsql":                 {"DbConnect", "DbConnectAdmin"},
+	"ec2":                  {"CreateTags", "DeleteTags"},
+	"elasticloadbalancing": {"AddTags", "RemoveTags"},
+	"events":               {"InvokeApiDestination"},
+	"execute-api":          {"InvalidateCache", "Invoke", "ManageConnections"},
+	"neptune-graph":        {"DeleteDataViaQuery", "ReadDataViaQuery", "WriteDataViaQuery"},
+	"rds":                  {"CreateTenantDatabase", "DeleteTenantDatabase", "ModifyTenantDatabase"},
+}
+
+var client = &http.Client{Timeout: 30 * time.Second}
+
+func main() {
+	output := flag.String("output", "data/actions.json", "Output file")
+	models := flag.String("models", "", "Directory of AWS SDK API models to add the services the reference lacks from")
+	sdk := flag.String("sdk", "", "Directory of AWS SDK for Go v2 service modules to add the services the reference and the API models lack from")
+	offline := flag.Bool("offline", false, "Keep the services of the current output file instead of fetching the reference")
+	flag.Parse()
+
+	var services map[string][]string
+	var err error
+	if *offline {
+		services, err = load(*output)
+	} else {
+		services, err = fetchReference()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *models != "" {
+		added, err := addModels(services, *models)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading API models: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Added %d services from the API models in %s\n", added, *models)
+	}
+
+	if *sdk != "" {
+		added, err := addSDK(services, *sdk)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading SDK modules: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Added %d services from the SDK modules in %s\n", added, *sdk)
+	}
+
+	if err := write(*output, services); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", *output, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Generated %s with %d services\n", *output, len(services))
+}
+
+// fetchReference fetches the actions of every service in the reference
+func fetchReference() (map[string][]string, error) {
+	var index []indexEntry
+	if err := getJSON(indexURL, &index); err != nil {
+		return nil, fmt.Errorf("fetching service index: %w", err)
+	}
+
+	services := make(map[string][]string, len(index))
+	for _, entry := range index {
+		var ref serviceReference
+		if err := getJSON(entry.URL, &ref); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: fetching %s: %v\n", entry.Service, err)
+			continue
+		}
+		actions := make([]string, 0, len(ref.Actions))
+		for _, a := range ref.Actions {
+			actions = append(actions, a.Name)
+		}
+		sort.Strings(actions)
+		services[entry.Service] = actions
+	}
+	return services, nil
+}
+
+// load reads a snapshot written by write
+func load(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var services map[string][]string
+	if err := json.Unmarshal(data, &services); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return services, nil
+}
+
+// addModels adds the services of the API models in dir (dir/<model>/<api
+// version>/api-2.json) that services lacks, and returns the number added
+func addModels(services map[string][]string, dir string) (int, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*", "api-2.json"))
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, fmt.Errorf("no api-2.json files in %s", dir)
+	}
+
+	// Several models can share a service prefix (e.g., the v1 and v2 load
+	// balancer APIs), so their operations are collected first
+	found := make(map[string]map[string]bool)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return 0, err
+		}
+		var model apiModel
+		if err := json.Unmarshal(data, &model); err != nil {
+			return 0, fmt.Errorf("parsing %s: %w", file, err)
+		}
+		prefix := model.Metadata.SigningName
+		if prefix == "" {
+			prefix = model.Metadata.EndpointPrefix
+		}
+		if p, ok := prefixes[prefix]; ok {
+			prefix = p
+		}
+		if found[prefix] == nil {
+			found[prefix] = make(map[string]bool)
+		}
+		if operationless[prefix] {
+			continue
+		}
+		for op := range model.Operations {
+			found[prefix][op] = true
+		}
+	}
+
+	return addServices(services, found), nil
+}
+
+// addSDK adds the services of the AWS SDK for Go v2 service modules in dir
+// (dir/<service>@<version>/) that services lacks, and returns the number
+// added
+func addSDK(services map[string][]string, dir string) (int, error) {
+	modules, err := filepath.Glob(filepath.Join(dir, "*@*"))
+	if err != nil {
+		return 0, err
+	}
+	if len(modules) == 0 {
+		return 0, fmt.Errorf("no service modules in %s", dir)
+	}
+
+	found := make(map[string]map[string]bool)
+	for _, module := range modules {
+		auth, err := os.ReadFile(filepath.Join(module, "auth.go"))
+		if err != nil {
+			continue
+		}
+		match := signingNamePattern.FindSubmatch(auth)
+		if match == nil {
+			continue
+		}
+		prefix := string(match[1])
+		if p, ok := prefixes[prefix]; ok {
+			prefix = p
+		}
+		if found[prefix] == nil {
+			found[prefix] = make(map[string]bool)
+		}
+		if operationless[prefix] {
+			continue
+		}
+
+		files, err := filepath.Glob(filepath.Join(module, "api_op_*.go"))
+		if err != nil {
+			return 0, err
+		}
+		for _, file := range files {
+			found[prefix][strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "api_op_"), ".go")] = true
+		}
+	}
+	return addServices(services, found), nil
+}
+
+// addServices adds the operations found for each service prefix, plus its
+// permissionOnly actions, for the services that services lacks, and returns
+// the number added
+func addServices(services map[string][]string, found map[string]map[string]bool) int {
+	added := 0
+	for prefix, ops := range found {
+		if _, ok := services[prefix]; ok || len(ops)+len(permissionOnly[prefix]) == 0 {
+			continue
+		}
+		for _, a := range permissionOnly[prefix] {
+			ops[a] = true
+		}
+		actions := make([]string, 0, len(ops))
+		for op := range ops {
+			actions = append(actions, op)
+		}
+		sort.Strings(actions)
+		services[prefix] = actions
+		added++
+	}
+	return added
+}
+
+// getJSON fetches a URL and decodes its JSON body into v
+func getJSON(url string, v interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// write writes the services sorted by name, one per line, so updates diff well
+func write(path string, services map[string][]string) error {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("{\n")
+	for i, name := range names {
+		actions, err := json.Marshal(services[name])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "  %q: %s", name, actions)
+		if i < len(names)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
@@ -0,0 +1,215 @@
+//go:generate go run ./gen/main.go
+
+// Package sar validates IAM action names against the AWS Service
+// Authorization Reference, which lists the actions of every service.
+//
+// A snapshot of the reference is embedded (data/actions.json). The services
+// of the built-in mappings come from the reference itself; the other
+// services are derived from the API models of the AWS SDK for Go (v1, and v2
+// for services newer than v1), so their actions without an API operation may
+// be missing. Actions of services the snapshot
+// does not cover are not validated, and Unverified reports their services so
+// callers can say so. To update it, run go generate ./internal/sar.
+package sar
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed data/actions.json
+var referenceData []byte
+
+// reference maps service prefixes to their actions, keyed by the lowercase
+// action name since IAM matches actions case-insensitively
+var reference = func() map[string]map[string]string {
+	var services map[string][]string
+	if err := json.Unmarshal(referenceData, &services); err != nil {
+		panic("sar: invalid embedded reference: " + err.Error())
+	}
+
+	m := make(map[string]map[string]string, len(services))
+	for service, actions := range services {
+		m[service] = make(map[string]string, len(actions))
+		for _, a := range actions {
+			m[service][strings.ToLower(a)] = a
+		}
+	}
+	return m
+}()
+
+// Problem is an action that does not exist
+type Problem struct {
+	Action string
+	// Reason explains why the action is invalid
+	Reason string
+	// Suggestion is the closest existing action, if any
+	Suggestion string
+}
+
+// String formats the problem for display
+func (p Problem) String() string {
+	s := p.Action + ": " + p.Reason
+	if p.Suggestion != "" {
+		s += fmt.Sprintf(" (did you mean %s?)", p.Suggestion)
+	}
+	return s
+}
+
+// Known checks if the reference covers a service
+func Known(service string) bool {
+	_, ok := reference[service]
+	return ok
+}
+
+// Unverified returns the services of actions that the reference does not
+// cover, sorted and once each. Such actions pass Check without having been
+// validated.
+func Unverified(actions []string) []string {
+	seen := make(map[string]bool)
+	var services []string
+	for _, action := range actions {
+		service, _, ok := strings.Cut(strings.ToLower(action), ":")
+		if !ok || service == "" || seen[service] || Known(service) {
+			continue
+		}
+		seen[service] = true
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	return services
+}
+
+// Check validates an action, which may contain * and ? wildcards. Actions
+// of services the reference does not cover are considered valid.
+func Check(action string) (Problem, bool) {
+	if action == "*" {
+		return Problem{}, true
+	}
+
+	service, name, ok := strings.Cut(action, ":")
+	if !ok || service == "" || name == "" {
+		return Problem{Action: action, Reason: "not in service:action form"}, false
+	}
+
+	actions, ok := reference[strings.ToLower(service)]
+	if !ok {
+		return Problem{}, true
+	}
+
+	lower := strings.ToLower(name)
+	if !strings.ContainsAny(lower, "*?") {
+		if _, ok := actions[lower]; ok {
+			return Problem{}, true
+		}
+		return Problem{Action: action, Reason: "unknown action", Suggestion: suggest(service, lower, actions)}, false
+	}
+
+	for a := range actions {
+		if matched, _ := path.Match(lower, a); matched {
+			return Problem{}, true
+		}
+	}
+	return Problem{Action: action, Reason: "matches no action"}, false
+}
+
+// Validate returns the problems of the actions that do not exist, once per
+// action and in the order given
+func Validate(actions []string) []Problem {
+	seen := make(map[string]bool)
+	var problems []Problem
+	for _, action := range actions {
+		if seen[action] {
+			continue
+		}
+		seen[action] = true
+		if p, ok := Check(action); !ok {
+			problems = append(problems, p)
+		}
+	}
+	return problems
+}
+
+// Count returns the number of actions an action pattern, which may contain *
+// and ? wildcards, matches. A pattern of a service the reference does not
+// cover counts as one action.
+func Count(pattern string) int {
+	service, name, ok := strings.Cut(strings.ToLower(pattern), ":")
+	if pattern == "*" {
+		service, name, ok = "*", "*", true
+	}
+	if !ok {
+		return 0
+	}
+
+	count, known := 0, false
+	for s, actions := range reference {
+		if matched, _ := path.Match(service, s); !matched {
+			continue
+		}
+		known = true
+		if name == "*" {
+			count += len(actions)
+			continue
+		}
+		for a := range actions {
+			if matched, _ := path.Match(name, a); matched {
+				count++
+			}
+		}
+	}
+	if !known {
+		return 1
+	}
+	return count
+}
+
+// Total returns the number of actions in the reference
+func Total() int {
+	total := 0
+	for _, actions := range reference {
+		total += len(actions)
+	}
+	return total
+}
+
+// suggest returns the action of a service closest to a misspelled one, or
+// "" if none is close enough to be a likely typo
+func suggest(service, name string, actions map[string]string) string {
+	best, bestDistance := "", max(2, len(name)/6)+1
+	for lower, a := range actions {
+		d := distance(name, lower)
+		if d < bestDistance || d == bestDistance && best != "" && a < best {
+			best, bestDistance = a, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return service + ":" + best
+}
+
+// distance returns the Levenshtein distance between two strings
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
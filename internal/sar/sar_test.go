@@ -0,0 +1,118 @@
+package sar
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mizzy/least/internal/mapping"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		action         string
+		wantOK         bool
+		wantReason     string
+		wantSuggestion string
+	}{
+		{"s3:GetObject", true, "", ""},
+		{"s3:getobject", true, "", ""},
+		{"*", true, "", ""},
+		{"s3:*", true, "", ""},
+		{"s3:Get*", true, "", ""},
+		{"s3:Get?bject", true, "", ""},
+		{"ec2:RunInstances", true, "", ""},
+		{"ecs:UpdateClsuter", false, "unknown action", "ecs:UpdateCluster"},
+		{"iam:PassRoel", false, "unknown action", "iam:PassRole"},
+		{"s3:DeleteBucketCORS", false, "unknown action", ""},
+		{"s3:Frob*", false, "matches no action", ""},
+		{"GetObject", false, "not in service:action form", ""},
+		{"s3:", false, "not in service:action form", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			p, ok := Check(tt.action)
+			if ok != tt.wantOK {
+				t.Fatalf("Check() ok = %v, want %v (%s)", ok, tt.wantOK, p)
+			}
+			if p.Reason != tt.wantReason || p.Suggestion != tt.wantSuggestion {
+				t.Errorf("Check() = %+v, want reason %q, suggestion %q", p, tt.wantReason, tt.wantSuggestion)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	problems := Validate([]string{"sqs:SendMesage", "sqs:SendMessage", "sqs:SendMesage", "kms:Decrpyt"})
+	if len(problems) != 2 {
+		t.Fatalf("Validate() = %v, want 2 problems", problems)
+	}
+	if got := problems[0].String(); got != "sqs:SendMesage: unknown action (did you mean sqs:SendMessage?)" {
+		t.Errorf("problems[0] = %q", got)
+	}
+	if problems[1].Action != "kms:Decrpyt" {
+		t.Errorf("problems[1] = %q, want kms:Decrpyt", problems[1].Action)
+	}
+}
+
+func TestCount(t *testing.T) {
+	sqs := len(reference["sqs"])
+	tests := []struct {
+		pattern string
+		want    int
+	}{
+		{"sqs:SendMessage", 1},
+		{"SQS:sendmessage", 1},
+		{"sqs:*", sqs},
+		{"sqs:NoSuchAction", 0},
+		{"example:Anything", 1},
+		{"*", Total()},
+		{"*:*", Total()},
+		{"sqs:GetQueue*", 2},
+		{"sqs:*Queue?", 2},
+	}
+	for _, tt := range tests {
+		if got := Count(tt.pattern); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestBuiltinMappings(t *testing.T) {
+	for _, resourceType := range mapping.GetSupportedResourceTypes() {
+		for _, p := range Validate(mapping.GetActionsForResource(resourceType)) {
+			t.Errorf("%s: %s", resourceType, p)
+		}
+	}
+}
+
+func TestBuiltinServices(t *testing.T) {
+	for _, resourceType := range mapping.GetSupportedResourceTypes() {
+		for _, service := range Unverified(mapping.GetActionsForResource(resourceType)) {
+			t.Errorf("%s: service %s is missing from the snapshot", resourceType, service)
+		}
+	}
+	for _, action := range []string{"ec2:RunInstances", "apigateway:PATCH", "elasticloadbalancing:AddTags", "cloudwatch:PutMetricAlarm"} {
+		if p, ok := Check(action); !ok {
+			t.Errorf("Check(%s) = %s", action, p)
+		}
+	}
+}
+
+func TestUnverified(t *testing.T) {
+	if !Known("lambda") {
+		t.Fatal("the snapshot should cover lambda")
+	}
+	if p, ok := Check("lambda:InvokeFunction"); !ok {
+		t.Errorf("Check(lambda:InvokeFunction) = %s", p)
+	}
+	if _, ok := Check("lambda:InvokeFunctoin"); ok {
+		t.Error("Check(lambda:InvokeFunctoin) should fail")
+	}
+
+	got := Unverified([]string{"rds:CreateDBInstance", "lambda:InvokeFunction", "acme:RunJob", "ACME:DescribeJobs", "widgets:List", "*"})
+	want := []string{"acme", "widgets"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unverified() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,100 @@
+package schema
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mizzy/least/internal/offline"
+)
+
+// BundleURL returns the URL of the zip of all resource provider schemas
+// AWS publishes for a region
+func BundleURL(region string) string {
+	domain := "amazonaws.com"
+	if strings.HasPrefix(region, "cn-") {
+		domain = "amazonaws.com.cn"
+	}
+	return fmt.Sprintf("https://schema.cloudformation.%s.%s/CloudFormationSchema.zip", region, domain)
+}
+
+// ReadBundle returns the schema documents in a schema bundle zip
+func ReadBundle(zr *zip.Reader) ([][]byte, error) {
+	var schemas [][]byte
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, ".json") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", f.Name, err)
+		}
+		schemas = append(schemas, data)
+	}
+	return schemas, nil
+}
+
+// DownloadBundle downloads a schema bundle zip and saves every schema in it
+// to the cache, returning the number of schemas saved
+func (s *Store) DownloadBundle(ctx context.Context, url string) (int, error) {
+	if offline.Enabled() {
+		return 0, offline.ErrOffline
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("downloading schema bundle: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("downloading schema bundle: HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("downloading schema bundle: %w", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return 0, fmt.Errorf("opening schema bundle: %w", err)
+	}
+	return s.ImportBundle(zr)
+}
+
+// ImportBundle saves every schema in a schema bundle zip to the cache and
+// returns the number of schemas saved
+func (s *Store) ImportBundle(zr *zip.Reader) (int, error) {
+	documents, err := ReadBundle(zr)
+	if err != nil {
+		return 0, err
+	}
+
+	saved := 0
+	for _, data := range documents {
+		var schema ResourceSchema
+		if err := json.Unmarshal(data, &schema); err != nil || schema.TypeName == "" {
+			continue
+		}
+		schema.FetchedAt = time.Now()
+		if err := s.SaveToCache(&schema); err != nil {
+			return saved, err
+		}
+		s.add(&schema)
+		saved++
+	}
+	return saved, nil
+}
@@ -0,0 +1,79 @@
+package schema
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDownloadBundle(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := map[string]string{
+		"aws-s3-bucket.json": bucketSchema,
+		"aws-sqs-queue.json": `{"typeName":"AWS::SQS::Queue","handlers":{"create":{"permissions":["sqs:CreateQueue"]}}}`,
+		"README.txt":         "not a schema",
+	}
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/CloudFormationSchema.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	store := NewStore(t.TempDir())
+	saved, err := store.DownloadBundle(context.Background(), server.URL+"/CloudFormationSchema.zip")
+	if err != nil {
+		t.Fatalf("DownloadBundle failed: %v", err)
+	}
+	if saved != 2 {
+		t.Errorf("saved %d schemas, want 2", saved)
+	}
+
+	types, err := store.ListCachedTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"AWS::S3::Bucket", "AWS::SQS::Queue"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("cached types = %v, want %v", types, want)
+	}
+
+	// Imported types resolve from the cache without fetching
+	perms, err := NewStore(store.CacheDir()).GetPermissions("AWS::SQS::Queue")
+	if err != nil {
+		t.Fatalf("GetPermissions failed: %v", err)
+	}
+	if !reflect.DeepEqual(perms.Create, []string{"sqs:CreateQueue"}) {
+		t.Errorf("AWS::SQS::Queue create permissions = %v, want [sqs:CreateQueue]", perms.Create)
+	}
+
+	if _, err := store.DownloadBundle(context.Background(), server.URL+"/missing.zip"); err == nil {
+		t.Error("expected an error for a missing bundle")
+	}
+}
+
+func TestBundleURL(t *testing.T) {
+	if got := BundleURL("eu-west-1"); got != "https://schema.cloudformation.eu-west-1.amazonaws.com/CloudFormationSchema.zip" {
+		t.Errorf("BundleURL(eu-west-1) = %s", got)
+	}
+	if got := BundleURL("cn-north-1"); got != "https://schema.cloudformation.cn-north-1.amazonaws.com.cn/CloudFormationSchema.zip" {
+		t.Errorf("BundleURL(cn-north-1) = %s", got)
+	}
+}
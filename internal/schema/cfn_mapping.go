@@ -40,6 +40,13 @@ func TerraformToCfnType(tfType string) string {
 	return "AWS::" + service + "::" + resource
 }
 
+// IsExplicitMapping checks if the CloudFormation type of a Terraform resource
+// type is known rather than guessed from its name by TerraformToCfnType
+func IsExplicitMapping(tfType string) bool {
+	_, ok := tfToCfnMappings[tfType]
+	return ok
+}
+
 // CfnToTerraformType converts a CloudFormation type to Terraform resource type
 // e.g., "AWS::S3::Bucket" -> "aws_s3_bucket"
 func CfnToTerraformType(cfnType string) string {
@@ -65,17 +72,32 @@ func CfnToTerraformType(cfnType string) string {
 	return "aws_" + service + "_" + resource
 }
 
+// toSnakeCase converts a PascalCase name to snake_case, keeping acronyms
+// together (e.g., "DBEngineVersion" -> "db_engine_version")
 func toSnakeCase(s string) string {
+	runes := []rune(s)
 	var result strings.Builder
-	for i, r := range s {
-		if i > 0 && r >= 'A' && r <= 'Z' {
-			result.WriteRune('_')
+	for i, r := range runes {
+		if i > 0 && isUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && !isUpper(runes[i+1]) && !isDigit(runes[i+1])
+			if !isUpper(prev) || nextLower {
+				result.WriteRune('_')
+			}
 		}
 		result.WriteRune(r)
 	}
 	return strings.ToLower(result.String())
 }
 
+func isUpper(r rune) bool {
+	return r >= 'A' && r <= 'Z'
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
 // Explicit mappings where automatic conversion doesn't work
 var tfToCfnMappings = map[string]string{
 	// EC2
@@ -219,8 +241,17 @@ var tfToCfnMappings = map[string]string{
 	"aws_sfn_activity":      "AWS::StepFunctions::Activity",
 
 	// EventBridge
-	"aws_cloudwatch_event_rule":   "AWS::Events::Rule",
-	"aws_cloudwatch_event_target": "AWS::Events::Rule",
+	"aws_cloudwatch_event_rule":            "AWS::Events::Rule",
+	"aws_cloudwatch_event_target":          "AWS::Events::Rule",
+	"aws_cloudwatch_event_bus":             "AWS::Events::EventBus",
+	"aws_cloudwatch_event_archive":         "AWS::Events::Archive",
+	"aws_cloudwatch_event_connection":      "AWS::Events::Connection",
+	"aws_cloudwatch_event_api_destination": "AWS::Events::ApiDestination",
+
+	// EventBridge Scheduler and Pipes
+	"aws_scheduler_schedule":       "AWS::Scheduler::Schedule",
+	"aws_scheduler_schedule_group": "AWS::Scheduler::ScheduleGroup",
+	"aws_pipes_pipe":               "AWS::Pipes::Pipe",
 
 	// CodeBuild/CodePipeline
 	"aws_codebuild_project": "AWS::CodeBuild::Project",
@@ -277,8 +308,10 @@ var tfToCfnMappings = map[string]string{
 var cfnToTfMappings = func() map[string]string {
 	m := make(map[string]string)
 	for tf, cfn := range tfToCfnMappings {
-		// Only add if not already present (prefer first mapping)
-		if _, ok := m[cfn]; !ok {
+		// Several Terraform types split one CloudFormation type; prefer the
+		// shortest (aws_s3_bucket over aws_s3_bucket_versioning), so the
+		// result does not depend on map iteration order
+		if prev, ok := m[cfn]; !ok || len(tf) < len(prev) || (len(tf) == len(prev) && tf < prev) {
 			m[cfn] = tf
 		}
 	}
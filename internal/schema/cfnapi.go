@@ -0,0 +1,128 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+
+	"github.com/mizzy/least/internal/offline"
+)
+
+// defaultRegion is used when no region is configured
+const defaultRegion = "us-east-1"
+
+// cfnAPI is the subset of the CloudFormation client used to fetch schemas
+type cfnAPI interface {
+	DescribeType(ctx context.Context, params *cloudformation.DescribeTypeInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeTypeOutput, error)
+	cloudformation.ListTypesAPIClient
+}
+
+// loadConfig loads the default AWS configuration, which resolves
+// credentials, profiles and the region like the AWS CLI does
+func loadConfig(ctx context.Context) (aws.Config, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("loading AWS config: %w", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
+	}
+	return cfg, nil
+}
+
+// Region returns the configured AWS region, or us-east-1 when none is set
+func Region(ctx context.Context) string {
+	if offline.Enabled() {
+		return defaultRegion
+	}
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return defaultRegion
+	}
+	return cfg.Region
+}
+
+// sdkSource fetches schemas with the CloudFormation client of the AWS SDK
+type sdkSource struct {
+	once sync.Once
+	api  cfnAPI
+	err  error
+}
+
+// client creates the CloudFormation client on first use, unless one is set
+func (s *sdkSource) client(ctx context.Context) (cfnAPI, error) {
+	if offline.Enabled() {
+		return nil, offline.ErrOffline
+	}
+	s.once.Do(func() {
+		if s.api != nil {
+			return
+		}
+		cfg, err := loadConfig(ctx)
+		if err != nil {
+			s.err = err
+			return
+		}
+		s.api = cloudformation.NewFromConfig(cfg)
+	})
+	if s.err != nil {
+		return nil, fmt.Errorf("aws sdk: %w", s.err)
+	}
+	return s.api, nil
+}
+
+// DescribeType calls CloudFormation DescribeType
+func (s *sdkSource) DescribeType(ctx context.Context, cfnType string) (string, error) {
+	api, err := s.client(ctx)
+	if err != nil {
+		return "", err
+	}
+	out, err := api.DescribeType(ctx, &cloudformation.DescribeTypeInput{
+		Type:     types.RegistryTypeResource,
+		TypeName: aws.String(cfnType),
+	})
+	if err != nil {
+		return "", fmt.Errorf("aws sdk: %w", err)
+	}
+	if aws.ToString(out.Schema) == "" {
+		return "", fmt.Errorf("aws sdk: empty schema for %s", cfnType)
+	}
+	return aws.ToString(out.Schema), nil
+}
+
+// ListTypes calls CloudFormation ListTypes and returns the names of all
+// public, provisionable resource types in the region
+func (s *sdkSource) ListTypes(ctx context.Context) ([]string, error) {
+	api, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	// ListTypes only returns fully mutable types unless asked otherwise
+	for _, provisioning := range []types.ProvisioningType{types.ProvisioningTypeFullyMutable, types.ProvisioningTypeImmutable} {
+		paginator := cloudformation.NewListTypesPaginator(api, &cloudformation.ListTypesInput{
+			Type:             types.RegistryTypeResource,
+			Visibility:       types.VisibilityPublic,
+			ProvisioningType: provisioning,
+			MaxResults:       aws.Int32(100),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("aws sdk: %w", err)
+			}
+			for _, summary := range page.TypeSummaries {
+				names = append(names, aws.ToString(summary.TypeName))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
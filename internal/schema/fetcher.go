@@ -3,25 +3,111 @@ package schema
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+
+	"github.com/mizzy/least/internal/offline"
 )
 
+// Source retrieves CloudFormation resource schema documents
+type Source interface {
+	// DescribeType returns the schema document of a resource type
+	DescribeType(ctx context.Context, cfnType string) (string, error)
+}
+
 // Fetcher retrieves CloudFormation resource schemas from AWS
 type Fetcher struct {
-	store *Store
+	store   *Store
+	sources []Source
 }
 
-// NewFetcher creates a new schema fetcher
+// NewFetcher creates a schema fetcher that calls CloudFormation with the AWS
+// SDK and the standard credential chain, falling back to the AWS CLI when
+// it is installed
 func NewFetcher(store *Store) *Fetcher {
-	return &Fetcher{store: store}
+	return NewFetcherWithSources(store, &sdkSource{}, cliSource{})
 }
 
-// FetchSchema retrieves a schema from AWS CloudFormation Registry
-// Requires AWS CLI to be installed and configured
+// NewFetcherWithSources creates a schema fetcher that tries sources in order
+func NewFetcherWithSources(store *Store, sources ...Source) *Fetcher {
+	return &Fetcher{store: store, sources: sources}
+}
+
+// FetchSchema retrieves a schema from the AWS CloudFormation Registry,
+// trying each source until one succeeds
 func (f *Fetcher) FetchSchema(ctx context.Context, cfnType string) (*ResourceSchema, error) {
-	// Use AWS CLI to get the schema
+	var errs []error
+	for _, source := range f.sources {
+		document, err := source.DescribeType(ctx, cfnType)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// Parse the schema JSON
+		var schema ResourceSchema
+		if err := json.Unmarshal([]byte(document), &schema); err != nil {
+			return nil, fmt.Errorf("parsing schema: %w", err)
+		}
+		schema.FetchedAt = time.Now()
+
+		// Cache the schema (ignore errors, just best-effort caching)
+		f.store.add(&schema)
+		_ = f.store.SaveToCache(&schema)
+
+		return &schema, nil
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no schema source configured")
+	}
+	return nil, errors.Join(errs...)
+}
+
+// typeLister is implemented by sources that can list resource types
+type typeLister interface {
+	ListTypes(ctx context.Context) ([]string, error)
+}
+
+// ListTypes returns the public resource types in the CloudFormation Registry
+// from the first source that can list them
+func (f *Fetcher) ListTypes(ctx context.Context) ([]string, error) {
+	var errs []error
+	for _, source := range f.sources {
+		lister, ok := source.(typeLister)
+		if !ok {
+			continue
+		}
+		types, err := lister.ListTypes(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return types, nil
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no schema source can list resource types")
+	}
+	return nil, errors.Join(errs...)
+}
+
+// cliSource fetches schemas by running the AWS CLI
+type cliSource struct{}
+
+// DescribeType runs aws cloudformation describe-type
+func (cliSource) DescribeType(ctx context.Context, cfnType string) (string, error) {
+	if offline.Enabled() {
+		return "", offline.ErrOffline
+	}
+	if !IsAWSCLIAvailable() {
+		return "", fmt.Errorf("aws cli: not installed")
+	}
+
 	cmd := exec.CommandContext(ctx, "aws", "cloudformation", "describe-type",
 		"--type", "RESOURCE",
 		"--type-name", cfnType,
@@ -31,9 +117,9 @@ func (f *Fetcher) FetchSchema(ctx context.Context, cfnType string) (*ResourceSch
 	output, err := cmd.Output()
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
-			return nil, fmt.Errorf("aws cli error: %s", string(exitErr.Stderr))
+			return "", fmt.Errorf("aws cli error: %s", strings.TrimSpace(string(exitErr.Stderr)))
 		}
-		return nil, fmt.Errorf("executing aws cli: %w", err)
+		return "", fmt.Errorf("executing aws cli: %w", err)
 	}
 
 	// Parse the response
@@ -41,19 +127,9 @@ func (f *Fetcher) FetchSchema(ctx context.Context, cfnType string) (*ResourceSch
 		Schema string `json:"Schema"`
 	}
 	if err := json.Unmarshal(output, &response); err != nil {
-		return nil, fmt.Errorf("parsing aws response: %w", err)
-	}
-
-	// Parse the schema JSON
-	var schema ResourceSchema
-	if err := json.Unmarshal([]byte(response.Schema), &schema); err != nil {
-		return nil, fmt.Errorf("parsing schema: %w", err)
+		return "", fmt.Errorf("parsing aws response: %w", err)
 	}
-
-	// Cache the schema (ignore error, just best-effort caching)
-	_ = f.store.LoadSchema([]byte(response.Schema))
-
-	return &schema, nil
+	return response.Schema, nil
 }
 
 // FetchForTerraformType fetches schema for a Terraform resource type
@@ -65,35 +141,121 @@ func (f *Fetcher) FetchForTerraformType(ctx context.Context, tfType string) (*Re
 	return f.FetchSchema(ctx, cfnType)
 }
 
-// FetchMultiple fetches schemas for multiple types in parallel
-func (f *Fetcher) FetchMultiple(ctx context.Context, cfnTypes []string) map[string]*ResourceSchema {
-	results := make(map[string]*ResourceSchema)
+// DefaultWorkers is the number of schemas FetchMultiple fetches at once by
+// default
+const DefaultWorkers = 8
+
+// FetchOptions configures FetchMultiple
+type FetchOptions struct {
+	// Workers is the number of schemas fetched at once (DefaultWorkers if 0)
+	Workers int
+	// Retries is the number of times a failed fetch is retried
+	Retries int
+	// Backoff is the delay before the first retry, doubled for each retry
+	Backoff time.Duration
+	// Progress, if set, is called after each type is fetched or fails.
+	// Calls are serialized.
+	Progress func(Progress)
+}
+
+// Progress reports the outcome of fetching one type of FetchMultiple
+type Progress struct {
+	Type   string
+	Schema *ResourceSchema
+	Err    error
+	// Done is the number of types finished so far, out of Total
+	Done  int
+	Total int
+}
+
+// FetchMultiple fetches schemas for multiple types concurrently, retrying
+// failures that may be transient with exponential backoff. It returns the
+// fetched schemas by type, and the errors of the types that failed joined.
+// When ctx is canceled, types not yet fetched are skipped and ctx's error is
+// returned.
+func (f *Fetcher) FetchMultiple(ctx context.Context, cfnTypes []string, opts FetchOptions) (map[string]*ResourceSchema, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	workers = min(workers, len(cfnTypes))
+
+	var (
+		mu      sync.Mutex
+		results = make(map[string]*ResourceSchema)
+		errs    []error
+		done    int
+	)
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for cfnType := range queue {
+				schema, err := f.fetchWithRetry(ctx, cfnType, opts)
+
+				mu.Lock()
+				done++
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", cfnType, err))
+				} else {
+					results[cfnType] = schema
+				}
+				if opts.Progress != nil {
+					opts.Progress(Progress{Type: cfnType, Schema: schema, Err: err, Done: done, Total: len(cfnTypes)})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
 
-	// Simple sequential fetching for now
-	// Could be parallelized with goroutines
+feed:
 	for _, cfnType := range cfnTypes {
+		select {
+		case queue <- cfnType:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	return results, errors.Join(errs...)
+}
+
+// fetchWithRetry fetches a schema, retrying errors that may be transient
+func (f *Fetcher) fetchWithRetry(ctx context.Context, cfnType string, opts FetchOptions) (*ResourceSchema, error) {
+	delay := opts.Backoff
+	for attempt := 0; ; attempt++ {
 		schema, err := f.FetchSchema(ctx, cfnType)
-		if err != nil {
-			continue
+		if err == nil || attempt >= opts.Retries || permanent(ctx, err) {
+			return schema, err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
-		results[cfnType] = schema
+		delay *= 2
 	}
+}
 
-	return results
+// permanent checks if retrying a failed fetch cannot succeed: offline mode,
+// types the registry does not have, and canceled fetches
+func permanent(ctx context.Context, err error) bool {
+	var notFound *types.TypeNotFoundException
+	return ctx.Err() != nil || errors.Is(err, offline.ErrOffline) || errors.As(err, &notFound)
 }
 
 // IsAWSCLIAvailable checks if AWS CLI is installed and accessible
 func IsAWSCLIAvailable() bool {
+	if offline.Enabled() {
+		return false
+	}
 	cmd := exec.Command("aws", "--version")
 	return cmd.Run() == nil
 }
-
-// GetAWSRegion returns the current AWS region from environment/config
-func GetAWSRegion() string {
-	cmd := exec.Command("aws", "configure", "get", "region")
-	output, err := cmd.Output()
-	if err != nil {
-		return "us-east-1" // default
-	}
-	return strings.TrimSpace(string(output))
-}
@@ -0,0 +1,189 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+const bucketSchema = `{"typeName":"AWS::S3::Bucket","handlers":{"create":{"permissions":["s3:CreateBucket"]}}}`
+
+// fakeCFN serves DescribeType and paginated ListTypes responses
+type fakeCFN struct{}
+
+func (fakeCFN) DescribeType(ctx context.Context, params *cloudformation.DescribeTypeInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeTypeOutput, error) {
+	if params.Type != types.RegistryTypeResource || aws.ToString(params.TypeName) != "AWS::S3::Bucket" {
+		return nil, &types.TypeNotFoundException{Message: aws.String("not found")}
+	}
+	return &cloudformation.DescribeTypeOutput{Schema: aws.String(bucketSchema)}, nil
+}
+
+func (fakeCFN) ListTypes(ctx context.Context, params *cloudformation.ListTypesInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListTypesOutput, error) {
+	summary := func(name string) types.TypeSummary { return types.TypeSummary{TypeName: aws.String(name)} }
+	switch {
+	case params.ProvisioningType == types.ProvisioningTypeImmutable:
+		return &cloudformation.ListTypesOutput{TypeSummaries: []types.TypeSummary{summary("AWS::EC2::VPCGatewayAttachment")}}, nil
+	case params.NextToken == nil:
+		return &cloudformation.ListTypesOutput{TypeSummaries: []types.TypeSummary{summary("AWS::SQS::Queue")}, NextToken: aws.String("page2")}, nil
+	default:
+		return &cloudformation.ListTypesOutput{TypeSummaries: []types.TypeSummary{summary("AWS::S3::Bucket")}}, nil
+	}
+}
+
+func TestSDKSource(t *testing.T) {
+	source := &sdkSource{api: fakeCFN{}}
+	ctx := context.Background()
+
+	document, err := source.DescribeType(ctx, "AWS::S3::Bucket")
+	if err != nil {
+		t.Fatalf("DescribeType failed: %v", err)
+	}
+	if document != bucketSchema {
+		t.Errorf("DescribeType() = %q", document)
+	}
+
+	_, err = source.DescribeType(ctx, "AWS::Nope::Nope")
+	var notFound *types.TypeNotFoundException
+	if !errors.As(err, &notFound) {
+		t.Errorf("expected TypeNotFoundException, got %v", err)
+	}
+
+	names, err := source.ListTypes(ctx)
+	if err != nil {
+		t.Fatalf("ListTypes failed: %v", err)
+	}
+	want := []string{"AWS::EC2::VPCGatewayAttachment", "AWS::S3::Bucket", "AWS::SQS::Queue"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("ListTypes() = %v, want %v", names, want)
+	}
+}
+
+// stubSource returns a fixed document or error
+type stubSource struct {
+	document string
+	err      error
+	calls    int
+}
+
+func (s *stubSource) DescribeType(ctx context.Context, cfnType string) (string, error) {
+	s.calls++
+	return s.document, s.err
+}
+
+func TestFetchSchemaFallback(t *testing.T) {
+	sdk := &stubSource{err: errors.New("aws sdk: no credentials")}
+	cli := &stubSource{document: bucketSchema}
+	fetcher := NewFetcherWithSources(NewStore(t.TempDir()), sdk, cli)
+
+	s, err := fetcher.FetchSchema(context.Background(), "AWS::S3::Bucket")
+	if err != nil {
+		t.Fatalf("FetchSchema failed: %v", err)
+	}
+	if s.TypeName != "AWS::S3::Bucket" || sdk.calls != 1 || cli.calls != 1 {
+		t.Errorf("got %s after %d sdk and %d cli calls", s.TypeName, sdk.calls, cli.calls)
+	}
+
+	cli.err = errors.New("aws cli: not installed")
+	_, err = fetcher.FetchSchema(context.Background(), "AWS::S3::Bucket")
+	if err == nil || !strings.Contains(err.Error(), "no credentials") || !strings.Contains(err.Error(), "not installed") {
+		t.Errorf("expected both source errors, got %v", err)
+	}
+}
+
+// flakySource fails each type a number of times before serving its schema,
+// and records how many calls are in flight at once
+type flakySource struct {
+	mu       sync.Mutex
+	failures map[string]int
+	calls    map[string]int
+	inFlight int
+	peak     int
+	delay    time.Duration
+}
+
+func (s *flakySource) DescribeType(ctx context.Context, cfnType string) (string, error) {
+	s.mu.Lock()
+	s.calls[cfnType]++
+	s.inFlight++
+	s.peak = max(s.peak, s.inFlight)
+	fail := s.calls[cfnType] <= s.failures[cfnType]
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if cfnType == "AWS::Nope::Nope" {
+		return "", fmt.Errorf("aws sdk: %w", &types.TypeNotFoundException{Message: aws.String("not found")})
+	}
+	if fail {
+		return "", errors.New("aws sdk: throttled")
+	}
+	return fmt.Sprintf(`{"typeName":%q}`, cfnType), nil
+}
+
+func TestFetchMultiple(t *testing.T) {
+	var cfnTypes []string
+	for i := range 10 {
+		cfnTypes = append(cfnTypes, fmt.Sprintf("AWS::Test::Type%d", i))
+	}
+	source := &flakySource{
+		failures: map[string]int{"AWS::Test::Type3": 2},
+		calls:    make(map[string]int),
+		delay:    10 * time.Millisecond,
+	}
+	fetcher := NewFetcherWithSources(NewStore(t.TempDir()), source)
+
+	var progress []Progress
+	results, err := fetcher.FetchMultiple(context.Background(), append(cfnTypes, "AWS::Nope::Nope"), FetchOptions{
+		Workers:  3,
+		Retries:  2,
+		Backoff:  time.Millisecond,
+		Progress: func(p Progress) { progress = append(progress, p) },
+	})
+	if err == nil || !strings.Contains(err.Error(), "AWS::Nope::Nope") {
+		t.Errorf("FetchMultiple() error = %v, want the missing type", err)
+	}
+	if len(results) != len(cfnTypes) {
+		t.Errorf("fetched %d schemas, want %d", len(results), len(cfnTypes))
+	}
+	if source.peak > 3 {
+		t.Errorf("%d fetches in flight, want at most 3", source.peak)
+	}
+	if source.calls["AWS::Test::Type3"] != 3 || source.calls["AWS::Nope::Nope"] != 1 {
+		t.Errorf("calls = %v, want transient failures retried and missing types not", source.calls)
+	}
+	if last := progress[len(progress)-1]; len(progress) != 11 || last.Done != 11 || last.Total != 11 {
+		t.Errorf("progress = %+v", progress)
+	}
+}
+
+func TestFetchMultipleCanceled(t *testing.T) {
+	source := &flakySource{calls: make(map[string]int), delay: time.Hour}
+	fetcher := NewFetcherWithSources(NewStore(t.TempDir()), source)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	results, err := fetcher.FetchMultiple(ctx, []string{"AWS::Test::A", "AWS::Test::B", "AWS::Test::C"}, FetchOptions{Workers: 1, Retries: 3})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("FetchMultiple() error = %v, want the deadline", err)
+	}
+	if len(results) != 0 || len(source.calls) != 1 {
+		t.Errorf("fetched %d schemas in %d calls, want the remaining types skipped", len(results), len(source.calls))
+	}
+}
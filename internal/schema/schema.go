@@ -11,8 +11,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 // ResourceSchema represents a CloudFormation Resource Schema
@@ -20,6 +22,8 @@ type ResourceSchema struct {
 	TypeName    string   `json:"typeName"`
 	Description string   `json:"description"`
 	Handlers    Handlers `json:"handlers"`
+	// FetchedAt records when the schema was fetched into the cache
+	FetchedAt time.Time `json:"x-least-fetched-at,omitzero"`
 }
 
 // Handlers contains the CRUD handlers for a resource
@@ -43,6 +47,19 @@ type Store struct {
 	mu       sync.RWMutex
 }
 
+// DefaultCacheDir returns the schema cache directory: $LEAST_SCHEMA_CACHE if
+// set, otherwise least/schemas under the user cache directory
+func DefaultCacheDir() string {
+	if dir := os.Getenv("LEAST_SCHEMA_CACHE"); dir != "" {
+		return dir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "least", "schemas")
+	}
+	return filepath.Join(dir, "least", "schemas")
+}
+
 // NewStore creates a new schema store
 func NewStore(cacheDir string) *Store {
 	return &Store{
@@ -108,6 +125,47 @@ func extractPermissions(schema *ResourceSchema) *Permissions {
 	return p
 }
 
+// FetchedAt returns when the schema of a resource type was fetched into the
+// cache. Schemas cached before fetch times were recorded use the cache
+// file's modification time.
+func (s *Store) FetchedAt(cfnType string) (time.Time, bool) {
+	s.mu.RLock()
+	schema, ok := s.schemas[cfnType]
+	s.mu.RUnlock()
+	if !ok {
+		var err error
+		if schema, err = s.loadFromCache(cfnType); err != nil {
+			return time.Time{}, false
+		}
+	}
+	if !schema.FetchedAt.IsZero() {
+		return schema.FetchedAt, true
+	}
+
+	info, err := os.Stat(s.cachePath(cfnType))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
+
+// Stale reports whether the cached schema of a resource type is older than
+// ttl. Missing schemas are not stale, and a ttl of zero never expires.
+func (s *Store) Stale(cfnType string, ttl time.Duration) bool {
+	if ttl <= 0 {
+		return false
+	}
+	fetched, ok := s.FetchedAt(cfnType)
+	return ok && time.Since(fetched) > ttl
+}
+
+// add stores a schema in memory
+func (s *Store) add(schema *ResourceSchema) {
+	s.mu.Lock()
+	s.schemas[schema.TypeName] = schema
+	s.mu.Unlock()
+}
+
 // LoadSchema loads a schema from JSON data
 func (s *Store) LoadSchema(data []byte) error {
 	var schema ResourceSchema
@@ -160,11 +218,7 @@ func (s *Store) loadFromCache(cfnType string) (*ResourceSchema, error) {
 		return nil, fmt.Errorf("no cache directory configured")
 	}
 
-	// Convert AWS::S3::Bucket to aws-s3-bucket.json
-	filename := strings.ToLower(strings.ReplaceAll(cfnType, "::", "-")) + ".json"
-	path := filepath.Join(s.cacheDir, filename)
-
-	data, err := os.ReadFile(path)
+	data, err := os.ReadFile(s.cachePath(cfnType))
 	if err != nil {
 		return nil, err
 	}
@@ -187,15 +241,81 @@ func (s *Store) SaveToCache(schema *ResourceSchema) error {
 		return err
 	}
 
-	filename := strings.ToLower(strings.ReplaceAll(schema.TypeName, "::", "-")) + ".json"
-	path := filepath.Join(s.cacheDir, filename)
-
 	data, err := json.MarshalIndent(schema, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(path, data, 0644)
+	return os.WriteFile(s.cachePath(schema.TypeName), data, 0644)
+}
+
+// cachePath returns the cache file of a resource type, e.g. aws-s3-bucket.json
+// for AWS::S3::Bucket
+func (s *Store) cachePath(cfnType string) string {
+	filename := strings.ToLower(strings.ReplaceAll(cfnType, "::", "-")) + ".json"
+	return filepath.Join(s.cacheDir, filename)
+}
+
+// CacheDir returns the cache directory of the store
+func (s *Store) CacheDir() string {
+	return s.cacheDir
+}
+
+// ListCachedTypes returns the CloudFormation types in the cache directory
+func (s *Store) ListCachedTypes() ([]string, error) {
+	entries, err := os.ReadDir(s.cacheDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading schema cache: %w", err)
+	}
+
+	var types []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.cacheDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var schema ResourceSchema
+		if err := json.Unmarshal(data, &schema); err != nil || schema.TypeName == "" {
+			continue
+		}
+		types = append(types, schema.TypeName)
+	}
+	sort.Strings(types)
+	return types, nil
+}
+
+// ClearCache removes all cached schemas and returns how many were removed
+func (s *Store) ClearCache() (int, error) {
+	entries, err := os.ReadDir(s.cacheDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("reading schema cache: %w", err)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.cacheDir, entry.Name())); err != nil {
+			return removed, fmt.Errorf("removing cached schema: %w", err)
+		}
+		removed++
+	}
+
+	s.mu.Lock()
+	s.schemas = make(map[string]*ResourceSchema)
+	s.mu.Unlock()
+
+	return removed, nil
 }
 
 // ListLoadedTypes returns all loaded resource types
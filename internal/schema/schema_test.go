@@ -0,0 +1,72 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mizzy/least/internal/offline"
+)
+
+func TestStale(t *testing.T) {
+	store := NewStore(t.TempDir())
+	fresh := &ResourceSchema{TypeName: "AWS::S3::Bucket", FetchedAt: time.Now().Add(-time.Hour)}
+	old := &ResourceSchema{TypeName: "AWS::SQS::Queue", FetchedAt: time.Now().Add(-60 * 24 * time.Hour)}
+	legacy := &ResourceSchema{TypeName: "AWS::SNS::Topic"}
+	for _, s := range []*ResourceSchema{fresh, old, legacy} {
+		if err := store.SaveToCache(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Schemas cached without a fetch time fall back to the file's modification time
+	past := time.Now().Add(-45 * 24 * time.Hour)
+	if err := os.Chtimes(store.cachePath("AWS::SNS::Topic"), past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	ttl := 30 * 24 * time.Hour
+	tests := []struct {
+		cfnType string
+		ttl     time.Duration
+		want    bool
+	}{
+		{"AWS::S3::Bucket", ttl, false},
+		{"AWS::SQS::Queue", ttl, true},
+		{"AWS::SNS::Topic", ttl, true},
+		{"AWS::SQS::Queue", 0, false},
+		{"AWS::Glue::Job", ttl, false},
+	}
+	for _, tt := range tests {
+		if got := store.Stale(tt.cfnType, tt.ttl); got != tt.want {
+			t.Errorf("Stale(%s, %v) = %v, want %v", tt.cfnType, tt.ttl, got, tt.want)
+		}
+	}
+}
+
+func TestFetchSchemaOffline(t *testing.T) {
+	offline.Set(true)
+	defer offline.Set(false)
+
+	_, err := NewFetcher(NewStore(t.TempDir())).FetchSchema(context.Background(), "AWS::S3::Bucket")
+	if !errors.Is(err, offline.ErrOffline) {
+		t.Errorf("expected ErrOffline, got %v", err)
+	}
+}
+
+func TestCfnToTerraformType(t *testing.T) {
+	tests := map[string]string{
+		"AWS::Glue::Job":                              "aws_glue_job",
+		"AWS::Location::APIKey":                       "aws_location_api_key",
+		"AWS::RDS::CustomDBEngineVersion":             "aws_rds_custom_db_engine_version",
+		"AWS::EC2::EC2Fleet":                          "aws_ec2_ec2_fleet",
+		"AWS::ObservabilityAdmin::S3TableIntegration": "aws_observabilityadmin_s3_table_integration",
+		"Custom::Thing":                               "",
+	}
+	for cfnType, want := range tests {
+		if got := CfnToTerraformType(cfnType); got != want {
+			t.Errorf("CfnToTerraformType(%s) = %q, want %q", cfnType, got, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Package stats computes aggregate least-privilege statistics for a set of
+// IaC resources and the policy generated from them
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/provider"
+)
+
+// TypeCount is the number of resources of a type
+type TypeCount struct {
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+// Stats summarizes a repository's permission footprint
+type Stats struct {
+	// Resources is the number of resources analyzed
+	Resources int `json:"resources"`
+	// ByType counts resources per type, most frequent first
+	ByType []TypeCount `json:"by_type"`
+	// Actions is the number of distinct actions in the policy
+	Actions int `json:"actions"`
+	// Services lists the services the policy grants actions on
+	Services []string `json:"services"`
+	// SpecificARNs is the number of resources whose statement names specific ARNs
+	SpecificARNs int `json:"specific_arns"`
+	// WildcardARNs is the number of resources whose statement uses Resource "*"
+	WildcardARNs int `json:"wildcard_arns"`
+	// PolicyBytes is the size of the minified policy document, which IAM
+	// limits to 6,144 bytes for managed policies
+	PolicyBytes int `json:"policy_bytes"`
+}
+
+// SpecificPercent returns the percentage of mapped resources with specific ARNs
+func (s *Stats) SpecificPercent() float64 {
+	total := s.SpecificARNs + s.WildcardARNs
+	if total == 0 {
+		return 100
+	}
+	return float64(s.SpecificARNs) * 100 / float64(total)
+}
+
+// Compute computes statistics for resources and the policy generated from them
+func Compute(resources []provider.Resource, p *policy.IAMPolicy) (*Stats, error) {
+	s := &Stats{Resources: len(resources)}
+
+	counts := make(map[string]int)
+	for _, res := range resources {
+		counts[res.Type]++
+	}
+	for t, n := range counts {
+		s.ByType = append(s.ByType, TypeCount{Type: t, Count: n})
+	}
+	sort.Slice(s.ByType, func(i, j int) bool {
+		if s.ByType[i].Count != s.ByType[j].Count {
+			return s.ByType[i].Count > s.ByType[j].Count
+		}
+		return s.ByType[i].Type < s.ByType[j].Type
+	})
+
+	actions := p.GetAllActions()
+	s.Actions = len(actions)
+	s.Services = make([]string, 0)
+	for _, g := range checker.GroupByService(actions) {
+		s.Services = append(s.Services, g.Service)
+	}
+
+	for _, stmt := range p.Statement {
+		wildcard := false
+		for _, r := range stmt.Resource {
+			if r == "*" {
+				wildcard = true
+				break
+			}
+		}
+		if wildcard {
+			s.WildcardARNs += len(stmt.Sources)
+		} else {
+			s.SpecificARNs += len(stmt.Sources)
+		}
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("encoding policy: %w", err)
+	}
+	s.PolicyBytes = len(data)
+
+	return s, nil
+}
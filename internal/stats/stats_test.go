@@ -0,0 +1,51 @@
+package stats
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/provider"
+)
+
+func TestCompute(t *testing.T) {
+	bucket := provider.Resource{Type: "aws_s3_bucket", Name: "logs"}
+	q1 := provider.Resource{Type: "aws_sqs_queue", Name: "jobs"}
+	q2 := provider.Resource{Type: "aws_sqs_queue", Name: "dlq"}
+	gateway := provider.Resource{Type: "aws_api_gateway_rest_api", Name: "api"}
+
+	p := &policy.IAMPolicy{
+		Version: "2012-10-17",
+		Statement: []policy.Statement{
+			{Effect: "Allow", Action: []string{"s3:CreateBucket", "s3:GetBucket*"}, Resource: []string{"arn:aws:s3:::logs"}, Sources: []provider.Resource{bucket}},
+			{Effect: "Allow", Action: []string{"sqs:CreateQueue"}, Resource: []string{"arn:aws:sqs:*:*:jobs"}, Sources: []provider.Resource{q1}},
+			{Effect: "Allow", Action: []string{"sqs:CreateQueue"}, Resource: []string{"arn:aws:sqs:*:*:dlq"}, Sources: []provider.Resource{q2}},
+			{Effect: "Allow", Action: []string{"apigateway:POST"}, Resource: []string{"*"}, Sources: []provider.Resource{gateway}},
+		},
+	}
+
+	s, err := Compute([]provider.Resource{bucket, q1, q2, gateway}, p)
+	if err != nil {
+		t.Fatalf("Compute() error = %v", err)
+	}
+
+	wantTypes := []TypeCount{{"aws_sqs_queue", 2}, {"aws_api_gateway_rest_api", 1}, {"aws_s3_bucket", 1}}
+	if !reflect.DeepEqual(s.ByType, wantTypes) {
+		t.Errorf("ByType = %v, want %v", s.ByType, wantTypes)
+	}
+	if s.Resources != 4 || s.Actions != 4 {
+		t.Errorf("Resources, Actions = %d, %d, want 4, 4", s.Resources, s.Actions)
+	}
+	if want := []string{"apigateway", "s3", "sqs"}; !reflect.DeepEqual(s.Services, want) {
+		t.Errorf("Services = %v, want %v", s.Services, want)
+	}
+	if s.SpecificARNs != 3 || s.WildcardARNs != 1 || s.SpecificPercent() != 75 {
+		t.Errorf("SpecificARNs, WildcardARNs = %d, %d (%.1f%%), want 3, 1 (75%%)", s.SpecificARNs, s.WildcardARNs, s.SpecificPercent())
+	}
+
+	minified, _ := json.Marshal(p)
+	if s.PolicyBytes != len(minified) {
+		t.Errorf("PolicyBytes = %d, want %d", s.PolicyBytes, len(minified))
+	}
+}
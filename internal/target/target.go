@@ -0,0 +1,122 @@
+// Package target selects the resources a targeted apply (terraform apply
+// -target) manages: the targeted resources and modules and, transitively,
+// the resources they depend on.
+package target
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+// Normalize validates a resource or module address in Terraform syntax and
+// removes its instance keys (e.g., module.app["eu"].aws_s3_bucket.logs[0]
+// becomes module.app.aws_s3_bucket.logs), since resources are not expanded
+// into instances
+func Normalize(address string) (string, error) {
+	var b strings.Builder
+	depth := 0
+	inString := false
+	for i := 0; i < len(address); i++ {
+		c := address[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"' && depth > 0:
+			inString = true
+		case c == '[':
+			depth++
+		case c == ']':
+			if depth == 0 {
+				return "", fmt.Errorf("invalid address %q: unbalanced brackets", address)
+			}
+			depth--
+		case depth == 0:
+			b.WriteByte(c)
+		}
+	}
+	if depth > 0 || inString {
+		return "", fmt.Errorf("invalid address %q: unbalanced brackets", address)
+	}
+
+	normalized := b.String()
+	parts := strings.Split(normalized, ".")
+	for _, part := range parts {
+		if part == "" {
+			return "", fmt.Errorf("invalid address %q", address)
+		}
+	}
+	for len(parts) >= 2 && parts[0] == "module" {
+		parts = parts[2:]
+	}
+	if len(parts) > 0 && parts[0] == "data" {
+		parts = parts[1:]
+	}
+	if len(parts) != 0 && len(parts) != 2 {
+		return "", fmt.Errorf("invalid address %q (use [module.NAME.]TYPE.NAME or module.NAME)", address)
+	}
+	return normalized, nil
+}
+
+// Select returns the resources matching the targets, which must be
+// normalized addresses, and the resources they depend on, in the order of
+// resources. A target matches a resource by its full address, and every
+// resource of a module by the module address. Targets matching no resource
+// are an error, as they are most likely mistyped.
+func Select(resources []provider.Resource, targets []string) ([]provider.Resource, error) {
+	selected := make([]bool, len(resources))
+	var queue []int
+	selectMatching := func(address string) bool {
+		found := false
+		for i, res := range resources {
+			if !matches(res, address) {
+				continue
+			}
+			found = true
+			if !selected[i] {
+				selected[i] = true
+				queue = append(queue, i)
+			}
+		}
+		return found
+	}
+
+	var unmatched []string
+	for _, t := range targets {
+		if !selectMatching(t) {
+			unmatched = append(unmatched, t)
+		}
+	}
+	if len(unmatched) > 0 {
+		return nil, fmt.Errorf("targets match no resource: %s", strings.Join(unmatched, ", "))
+	}
+
+	// References to resources outside the parsed code, such as data
+	// sources, select nothing
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for _, ref := range resources[i].References {
+			selectMatching(ref)
+		}
+	}
+
+	var result []provider.Resource
+	for i, res := range resources {
+		if selected[i] {
+			result = append(result, res)
+		}
+	}
+	return result, nil
+}
+
+// matches checks if a resource has the address or is in the module at it
+func matches(res provider.Resource, address string) bool {
+	full := res.FullAddress()
+	return full == address || strings.HasPrefix(full, address+".")
+}
@@ -0,0 +1,96 @@
+package target
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mizzy/least/internal/provider"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+		wantErr bool
+	}{
+		{address: "aws_s3_bucket.logs", want: "aws_s3_bucket.logs"},
+		{address: "aws_s3_bucket.logs[0]", want: "aws_s3_bucket.logs"},
+		{address: `module.app["eu.west"].aws_s3_bucket.logs`, want: "module.app.aws_s3_bucket.logs"},
+		{address: "module.app.module.db", want: "module.app.module.db"},
+		{address: "data.aws_iam_policy_document.ci", want: "data.aws_iam_policy_document.ci"},
+		{address: "aws_s3_bucket", wantErr: true},
+		{address: "module.app.aws_s3_bucket", wantErr: true},
+		{address: "aws_s3_bucket..logs", wantErr: true},
+		{address: "aws_s3_bucket.logs[0", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.address)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Normalize(%q) error = %v, wantErr %v", tt.address, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	resources := []provider.Resource{
+		{Type: "aws_s3_bucket", Name: "logs", References: []string{"aws_kms_key.logs"}},
+		{Type: "aws_kms_key", Name: "logs"},
+		{Type: "aws_sqs_queue", Name: "jobs"},
+		{Type: "aws_lambda_function", Name: "worker", Module: "module.app", References: []string{"module.app.aws_iam_role.worker", "module.network"}},
+		{Type: "aws_iam_role", Name: "worker", Module: "module.app"},
+		{Type: "aws_security_group", Name: "lambda", Module: "module.network", References: []string{"data.aws_vpc.main"}},
+	}
+	addresses := func(resources []provider.Resource) []string {
+		var got []string
+		for _, res := range resources {
+			got = append(got, res.FullAddress())
+		}
+		return got
+	}
+
+	tests := []struct {
+		name    string
+		targets []string
+		want    []string
+	}{
+		{
+			name:    "resource and its dependencies",
+			targets: []string{"aws_s3_bucket.logs"},
+			want:    []string{"aws_s3_bucket.logs", "aws_kms_key.logs"},
+		},
+		{
+			name:    "resource in a module depending on another module",
+			targets: []string{"module.app.aws_lambda_function.worker"},
+			want:    []string{"module.app.aws_lambda_function.worker", "module.app.aws_iam_role.worker", "module.network.aws_security_group.lambda"},
+		},
+		{
+			name:    "whole module",
+			targets: []string{"module.network"},
+			want:    []string{"module.network.aws_security_group.lambda"},
+		},
+		{
+			name:    "several targets",
+			targets: []string{"aws_sqs_queue.jobs", "aws_kms_key.logs"},
+			want:    []string{"aws_kms_key.logs", "aws_sqs_queue.jobs"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Select(resources, tt.targets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(addresses(got), tt.want) {
+				t.Errorf("Select() = %v, want %v", addresses(got), tt.want)
+			}
+		})
+	}
+
+	if _, err := Select(resources, []string{"aws_s3_bucket.log", "module.ap"}); err == nil {
+		t.Error("Select() accepted targets matching no resource")
+	}
+}
@@ -0,0 +1,172 @@
+// Package least is the Go API of least: parse IaC files, generate the minimal
+// IAM policy they require, and check existing policies against it.
+//
+//	result, err := least.Parse(ctx, "./terraform", least.ParseOptions{})
+//	if err != nil {
+//		return err
+//	}
+//	required, err := least.Generate(result.Resources, least.GenerateOptions{})
+//	if err != nil {
+//		return err
+//	}
+//	existing, err := least.ParsePolicy(document)
+//	if err != nil {
+//		return err
+//	}
+//	findings, err := least.Check(existing, required, least.CheckOptions{})
+package least
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mizzy/least/internal/baseline"
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/config"
+	"github.com/mizzy/least/internal/policy"
+	"github.com/mizzy/least/internal/provider"
+	"github.com/mizzy/least/internal/provider/cloudformation"
+	"github.com/mizzy/least/internal/provider/terraform"
+)
+
+type (
+	// Resource is a cloud resource defined in IaC code
+	Resource = provider.Resource
+	// ParseResult contains the resources and IAM policies found in IaC files
+	ParseResult = provider.ParseResult
+	// Requirement is a permission the IaC tool itself needs, such as access
+	// to backend state; add them to a policy with Policy.AddRequirements
+	Requirement = provider.Requirement
+	// Policy is an IAM policy document
+	Policy = policy.IAMPolicy
+	// Statement is a statement of an IAM policy
+	Statement = policy.Statement
+	// CheckResult lists the missing, excessive and matched actions of a policy
+	CheckResult = checker.Result
+)
+
+// ParseOptions configures Parse
+type ParseOptions struct {
+	// Provider is the IaC provider (auto-detected if empty)
+	Provider string
+	// Include lists path patterns, relative to the parsed path; when set,
+	// only resources and policies in matching files are kept
+	Include []string
+	// Exclude lists path patterns, relative to the parsed path, whose
+	// resources and policies are dropped; "**" matches any directories
+	Exclude []string
+}
+
+// GenerateOptions configures Generate
+type GenerateOptions struct {
+	// Format is the output the policy is rendered to: "json" (default) or
+	// "terraform", which fills ARNs with AccountRef and RegionRef
+	Format string
+	// AccountRef is the reference for the AWS account ID in Terraform output
+	// (e.g., "${data.aws_caller_identity.current.account_id}")
+	AccountRef string
+	// RegionRef is the reference for the AWS region in Terraform output
+	// (e.g., "${data.aws_region.current.name}")
+	RegionRef string
+}
+
+// CheckOptions configures Check
+type CheckOptions struct {
+	// Baseline is a baseline file whose accepted findings are removed from the result
+	Baseline string
+	// Now is the time baseline expiry dates are compared with (default: current time)
+	Now time.Time
+}
+
+// providers returns the registry of supported IaC providers
+func providers() *provider.Registry {
+	r := provider.NewRegistry()
+	r.Register(terraform.New())
+	r.Register(cloudformation.New())
+	return r
+}
+
+// Parse parses the IaC files at path
+func Parse(ctx context.Context, path string, opts ParseOptions) (*ParseResult, error) {
+	registry := providers()
+
+	var p provider.Provider
+	if opts.Provider != "" {
+		if p = registry.Get(opts.Provider); p == nil {
+			return nil, fmt.Errorf("unknown provider: %s", opts.Provider)
+		}
+	} else {
+		detected, err := registry.Detect(path)
+		if err != nil {
+			return nil, fmt.Errorf("detecting provider: %w", err)
+		}
+		if len(detected) == 0 {
+			return nil, fmt.Errorf("no supported IaC files found in %s", path)
+		}
+		p = detected[0]
+	}
+
+	result, err := p.Parse(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.Include) > 0 || len(opts.Exclude) > 0 {
+		exclude := &config.Config{Include: opts.Include, Exclude: opts.Exclude}
+		base := path
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			base = filepath.Dir(path)
+		}
+		result.Filter(func(loc provider.SourceLocation) bool {
+			rel, err := filepath.Rel(base, loc.File)
+			return err != nil || !exclude.Excluded(rel)
+		})
+	}
+
+	return result, nil
+}
+
+// Generate returns the minimal IAM policy required to manage the resources
+func Generate(resources []Resource, opts GenerateOptions) (*Policy, error) {
+	format := opts.Format
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "terraform" {
+		return nil, fmt.Errorf("unsupported format: %s (use json or terraform)", format)
+	}
+
+	gen := policy.NewWithOptions(policy.GeneratorOptions{
+		OutputFormat: format,
+		AccountRef:   opts.AccountRef,
+		RegionRef:    opts.RegionRef,
+	})
+	return gen.Generate(resources)
+}
+
+// ParsePolicy parses an IAM policy JSON document
+func ParsePolicy(data []byte) (*Policy, error) {
+	return policy.ParsePolicy(data)
+}
+
+// Check compares an existing policy against the required one
+func Check(existing, required *Policy, opts CheckOptions) (*CheckResult, error) {
+	result := checker.Check(existing, required)
+
+	if opts.Baseline != "" {
+		b, err := baseline.Load(opts.Baseline)
+		if err != nil {
+			return nil, err
+		}
+		now := opts.Now
+		if now.IsZero() {
+			now = time.Now()
+		}
+		result = b.Apply(result, now).Result
+	}
+
+	return result, nil
+}
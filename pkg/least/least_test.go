@@ -0,0 +1,72 @@
+package least
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseGenerateCheck(t *testing.T) {
+	ctx := context.Background()
+
+	result, err := Parse(ctx, "../../testdata/multi-file", ParseOptions{})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	excluded, err := Parse(ctx, "../../testdata/multi-file", ParseOptions{Provider: "terraform", Exclude: []string{"networking.tf"}})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(excluded.Resources) >= len(result.Resources) {
+		t.Errorf("Exclude kept %d of %d resources", len(excluded.Resources), len(result.Resources))
+	}
+
+	required, err := Generate(result.Resources, GenerateOptions{})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(required.Statement) == 0 {
+		t.Fatal("Generate() returned no statements")
+	}
+
+	existing, err := ParsePolicy([]byte(`{
+		"Version": "2012-10-17",
+		"Statement": [{"Effect": "Allow", "Action": ["s3:*", "sqs:SendMessage"], "Resource": "*"}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParsePolicy() error = %v", err)
+	}
+
+	findings, err := Check(existing, required, CheckOptions{})
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if !findings.HasMissing() || len(findings.Excessive) != 1 || findings.Excessive[0] != "sqs:SendMessage" {
+		t.Errorf("Check() missing=%d excessive=%v, want missing actions and [sqs:SendMessage]", len(findings.Missing), findings.Excessive)
+	}
+
+	baselineFile := filepath.Join(t.TempDir(), "baseline.yaml")
+	if err := os.WriteFile(baselineFile, []byte("excessive:\n  - action: sqs:SendMessage\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	findings, err = Check(existing, required, CheckOptions{Baseline: baselineFile})
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if findings.HasExcessive() {
+		t.Errorf("Check() with baseline excessive = %v, want none", findings.Excessive)
+	}
+}
+
+func TestParseUnknownProvider(t *testing.T) {
+	if _, err := Parse(context.Background(), "../../testdata/simple", ParseOptions{Provider: "pulumi"}); err == nil {
+		t.Error("Parse() expected error for unknown provider")
+	}
+}
+
+func TestGenerateUnsupportedFormat(t *testing.T) {
+	if _, err := Generate(nil, GenerateOptions{Format: "yaml"}); err == nil {
+		t.Error("Generate() expected error for unsupported format")
+	}
+}
@@ -0,0 +1,127 @@
+// Package leasttest asserts the permission footprint of an IaC module in Go
+// tests, so that module CI catches accidental permission creep:
+//
+//	func TestPermissions(t *testing.T) {
+//		leasttest.AssertGolden(t, ".", "testdata/least-policy.json")
+//	}
+//
+// The golden file is the JSON policy document the module requires. Run the
+// tests with LEAST_UPDATE_GOLDEN=1 to write it, and review its diff like any
+// other change.
+package leasttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mizzy/least/internal/checker"
+	"github.com/mizzy/least/internal/diff"
+	"github.com/mizzy/least/pkg/least"
+)
+
+// UpdateEnv is the environment variable that makes AssertGolden write the
+// golden file instead of comparing with it, when set to 1 or true
+const UpdateEnv = "LEAST_UPDATE_GOLDEN"
+
+// Options configures AssertGoldenWithOptions
+type Options struct {
+	// Parse configures parsing the module
+	Parse least.ParseOptions
+	// Update writes the golden file instead of comparing with it
+	Update bool
+}
+
+// Footprint returns the policy the IaC files at path require, failing the
+// test if they cannot be parsed
+func Footprint(t testing.TB, path string, opts least.ParseOptions) *least.Policy {
+	t.Helper()
+	result, err := least.Parse(context.Background(), path, opts)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", path, err)
+	}
+	for _, err := range result.Errors {
+		t.Errorf("parsing %s: %v", path, err)
+	}
+	required, err := least.Generate(result.Resources, least.GenerateOptions{})
+	if err != nil {
+		t.Fatalf("generating the policy of %s: %v", path, err)
+	}
+	return required
+}
+
+// AssertGolden fails the test when the policy the IaC files at path require
+// differs from the golden policy document, reporting the actions added
+// (permission creep) and removed, and a diff of the documents
+func AssertGolden(t testing.TB, path, golden string) {
+	t.Helper()
+	AssertGoldenWithOptions(t, path, golden, Options{})
+}
+
+// AssertGoldenWithOptions is AssertGolden with parse options
+func AssertGoldenWithOptions(t testing.TB, path, golden string, opts Options) {
+	t.Helper()
+	required := Footprint(t, path, opts.Parse)
+	document, err := required.ToJSON()
+	if err != nil {
+		t.Fatalf("converting the policy of %s to JSON: %v", path, err)
+	}
+	document += "\n"
+
+	if opts.Update || updating() {
+		if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+			t.Fatalf("creating the directory of %s: %v", golden, err)
+		}
+		if err := os.WriteFile(golden, []byte(document), 0644); err != nil {
+			t.Fatalf("writing %s: %v", golden, err)
+		}
+		return
+	}
+
+	data, err := os.ReadFile(golden)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("golden policy %s does not exist; run the test with %s=1 to write it", golden, UpdateEnv)
+	}
+	if err != nil {
+		t.Fatalf("reading %s: %v", golden, err)
+	}
+	if string(data) == document {
+		return
+	}
+
+	want, err := least.ParsePolicy(data)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", golden, err)
+	}
+	t.Errorf("the policy %s requires differs from %s (run the test with %s=1 to accept it):\n%s",
+		path, golden, UpdateEnv, describe(checker.Compare(want, required), diff.Unified(golden, path, string(data), document)))
+}
+
+// describe summarizes the changes of a policy for a test failure
+func describe(changes *checker.Changes, unified string) string {
+	var b strings.Builder
+	if len(changes.AddedActions) > 0 {
+		fmt.Fprintf(&b, "new actions: %s\n", strings.Join(changes.AddedActions, ", "))
+	}
+	if len(changes.RemovedActions) > 0 {
+		fmt.Fprintf(&b, "removed actions: %s\n", strings.Join(changes.RemovedActions, ", "))
+	}
+	if len(changes.AddedResources) > 0 {
+		fmt.Fprintf(&b, "new resources: %s\n", strings.Join(changes.AddedResources, ", "))
+	}
+	if len(changes.RemovedResources) > 0 {
+		fmt.Fprintf(&b, "removed resources: %s\n", strings.Join(changes.RemovedResources, ", "))
+	}
+	b.WriteString(unified)
+	return b.String()
+}
+
+// updating checks if UpdateEnv asks to write golden files
+func updating() bool {
+	v := os.Getenv(UpdateEnv)
+	return v == "1" || strings.EqualFold(v, "true")
+}
@@ -0,0 +1,72 @@
+package leasttest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// recorder is a testing.TB recording failures instead of failing the test
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// run calls f with a recorder and returns its failures
+func run(t *testing.T, f func(testing.TB)) []string {
+	r := &recorder{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(r)
+	}()
+	<-done
+	return r.failures
+}
+
+func TestAssertGolden(t *testing.T) {
+	dir := t.TempDir()
+	module := filepath.Join(dir, "module")
+	golden := filepath.Join(dir, "testdata", "least-policy.json")
+	if err := os.MkdirAll(module, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(content string) {
+		if err := os.WriteFile(filepath.Join(module, "main.tf"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("resource \"aws_s3_bucket\" \"logs\" {\n  bucket = \"logs\"\n}\n")
+
+	failures := run(t, func(tb testing.TB) { AssertGolden(tb, module, golden) })
+	if len(failures) != 1 || !strings.Contains(failures[0], UpdateEnv+"=1") {
+		t.Errorf("without a golden file, failures = %q", failures)
+	}
+
+	if failures := run(t, func(tb testing.TB) { AssertGoldenWithOptions(tb, module, golden, Options{Update: true}) }); len(failures) > 0 {
+		t.Fatalf("updating failed: %q", failures)
+	}
+	if failures := run(t, func(tb testing.TB) { AssertGolden(tb, module, golden) }); len(failures) > 0 {
+		t.Errorf("unchanged module failed: %q", failures)
+	}
+
+	write("resource \"aws_s3_bucket\" \"logs\" {\n  bucket = \"logs\"\n}\n\nresource \"aws_sqs_queue\" \"jobs\" {\n  name = \"jobs\"\n}\n")
+	failures = run(t, func(tb testing.TB) { AssertGolden(tb, module, golden) })
+	if len(failures) != 1 || !strings.Contains(failures[0], "new actions: sqs:CreateQueue") {
+		t.Errorf("grown module failures = %q, want the new sqs actions", failures)
+	}
+}
@@ -0,0 +1,24 @@
+#!/bin/bash
+# Fetch the CloudFormation registry schema dump and regenerate the embedded
+# schema bundle (internal/mapping/bundle.json.gz)
+#
+# Prerequisites:
+#   - curl
+#
+# Usage:
+#   ./scripts/fetch-schema-bundle.sh [region]
+
+set -e
+
+SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
+REGION="${1:-us-east-1}"
+URL="https://schema.cloudformation.${REGION}.amazonaws.com/CloudFormationSchema.zip"
+
+TMP_DIR="$(mktemp -d)"
+trap 'rm -rf "$TMP_DIR"' EXIT
+
+echo "Downloading ${URL}..."
+curl -fsSL -o "${TMP_DIR}/CloudFormationSchema.zip" "$URL"
+
+cd "${SCRIPT_DIR}/.."
+go run ./internal/mapping/gen/main.go -bundle "${TMP_DIR}/CloudFormationSchema.zip"
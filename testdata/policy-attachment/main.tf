@@ -0,0 +1,4 @@
+# Pattern: Existing permissions granted through managed policy attachments
+resource "aws_s3_bucket" "main" {
+  bucket = "my-bucket"
+}